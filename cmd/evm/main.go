@@ -50,6 +50,15 @@ var (
 		Name:  "statdump",
 		Usage: "displays stack and heap memory information",
 	}
+	EVMProfileFlag = cli.StringFlag{
+		Name:  "evmprofile",
+		Usage: "write an opcode and program counter level execution profile to the given file ('-' for stderr)",
+	}
+	EVMProfileFormatFlag = cli.StringFlag{
+		Name:  "evmprofile.format",
+		Usage: "format of the execution profile (table, json or pprof)",
+		Value: "table",
+	}
 	CodeFlag = cli.StringFlag{
 		Name:  "code",
 		Usage: "EVM code",
@@ -134,6 +143,10 @@ var (
 		Usage: "External EVM configuration (default = built-in interpreter)",
 		Value: "",
 	}
+	VMEIPsFlag = cli.StringFlag{
+		Name:  "vm.eips",
+		Usage: "comma separated list of EIPs to activate on top of the chain rules (e.g. 3540,3670)",
+	}
 )
 
 var stateTransitionCommand = cli.Command{
@@ -177,6 +190,8 @@ func init() {
 		MemProfileFlag,
 		CPUProfileFlag,
 		StatDumpFlag,
+		EVMProfileFlag,
+		EVMProfileFormatFlag,
 		GenesisFlag,
 		MachineFlag,
 		SenderFlag,
@@ -186,6 +201,7 @@ func init() {
 		DisableStorageFlag,
 		DisableReturnDataFlag,
 		EVMInterpreterFlag,
+		VMEIPsFlag,
 	}
 	app.Commands = []cli.Command{
 		compileCommand,
@@ -0,0 +1,71 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of go-highcoin.
+//
+// go-highcoin is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-highcoin is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-highcoin. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/420integrated/go-highcoin/core/vm"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// newProfiler creates an execution profiler if one was requested, nil otherwise.
+func newProfiler(ctx *cli.Context) (*vm.Profiler, error) {
+	if ctx.GlobalString(EVMProfileFlag.Name) == "" {
+		return nil, nil
+	}
+	if ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name) {
+		return nil, errors.New("--evmprofile can't be combined with --debug or --json")
+	}
+	switch format := ctx.GlobalString(EVMProfileFormatFlag.Name); format {
+	case "table", "json", "pprof":
+	default:
+		return nil, fmt.Errorf("unknown execution profile format %q, want table, json or pprof", format)
+	}
+	return vm.NewProfiler(), nil
+}
+
+// writeProfile writes the execution profile aggregated by the profiler into the
+// requested output, in the requested format.
+func writeProfile(ctx *cli.Context, profiler *vm.Profiler) error {
+	var out io.Writer = os.Stderr
+	if path := ctx.GlobalString(EVMProfileFlag.Name); path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("could not create execution profile: %v", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	profile := profiler.Profile()
+
+	switch ctx.GlobalString(EVMProfileFormatFlag.Name) {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(profile)
+	case "pprof":
+		return vm.WriteProfilePprof(out, profile)
+	default:
+		vm.WriteProfileTable(out, profile)
+		return nil
+	}
+}
@@ -25,6 +25,8 @@ import (
 	"os"
 	goruntime "runtime"
 	"runtime/pprof"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -41,6 +43,23 @@ import (
 	"gopkg.in/urfave/cli.v1"
 )
 
+// parseEIPs parses a comma separated list of EIPs to activate on top of the
+// chain rules.
+func parseEIPs(list string) ([]int, error) {
+	var eips []int
+	for _, field := range strings.Split(list, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		eip, err := strconv.Atoi(field)
+		if err != nil || !vm.ValidEip(eip) {
+			return nil, fmt.Errorf("invalid eip %q, activateable eips: %s", field, strings.Join(vm.ActivateableEips(), ", "))
+		}
+		eips = append(eips, eip)
+	}
+	return eips, nil
+}
+
 var runCommand = cli.Command{
 	Action:      runCmd,
 	Name:        "run",
@@ -124,11 +143,17 @@ func runCmd(ctx *cli.Context) error {
 		receiver      = common.BytesToAddress([]byte("receiver"))
 		genesisConfig *core.Genesis
 	)
+	profiler, err := newProfiler(ctx)
+	if err != nil {
+		return err
+	}
 	if ctx.GlobalBool(MachineFlag.Name) {
 		tracer = vm.NewJSONLogger(logconfig, os.Stdout)
 	} else if ctx.GlobalBool(DebugFlag.Name) {
 		debugLogger = vm.NewStructLogger(logconfig)
 		tracer = debugLogger
+	} else if profiler != nil {
+		tracer = profiler
 	} else {
 		debugLogger = vm.NewStructLogger(logconfig)
 	}
@@ -200,6 +225,10 @@ func runCmd(ctx *cli.Context) error {
 	if genesisConfig.SmokeLimit != 0 {
 		initialSmoke = genesisConfig.SmokeLimit
 	}
+	eips, err := parseEIPs(ctx.GlobalString(VMEIPsFlag.Name))
+	if err != nil {
+		return err
+	}
 	runtimeConfig := runtime.Config{
 		Origin:      sender,
 		State:       statedb,
@@ -212,8 +241,9 @@ func runCmd(ctx *cli.Context) error {
 		BlockNumber: new(big.Int).SetUint64(genesisConfig.Number),
 		EVMConfig: vm.Config{
 			Tracer:         tracer,
-			Debug:          ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name),
+			Debug:          ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name) || profiler != nil,
 			EVMInterpreter: ctx.GlobalString(EVMInterpreterFlag.Name),
+			ExtraEips:      eips,
 		},
 	}
 
@@ -295,6 +325,12 @@ func runCmd(ctx *cli.Context) error {
 		vm.WriteLogs(os.Stderr, statedb.Logs())
 	}
 
+	if profiler != nil {
+		if err := writeProfile(ctx, profiler); err != nil {
+			return err
+		}
+	}
+
 	if bench || ctx.GlobalBool(StatDumpFlag.Name) {
 		fmt.Fprintf(os.Stderr, `EVM smoke used:    %d
 execution time:  %v
@@ -302,7 +338,7 @@ allocations:     %d
 allocated bytes: %d
 `, initialSmoke-leftOverSmoke, stats.time, stats.allocs, stats.bytesAllocated)
 	}
-	if tracer == nil {
+	if tracer == nil || profiler != nil {
 		fmt.Printf("0x%x\n", output)
 		if err != nil {
 			fmt.Printf(" error: %v\n", err)
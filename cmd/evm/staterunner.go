@@ -64,6 +64,10 @@ func stateTestCmd(ctx *cli.Context) error {
 		DisableStorage:    ctx.GlobalBool(DisableStorageFlag.Name),
 		DisableReturnData: ctx.GlobalBool(DisableReturnDataFlag.Name),
 	}
+	profiler, err := newProfiler(ctx)
+	if err != nil {
+		return err
+	}
 	var (
 		tracer   vm.Tracer
 		debugger *vm.StructLogger
@@ -76,6 +80,9 @@ func stateTestCmd(ctx *cli.Context) error {
 		debugger = vm.NewStructLogger(config)
 		tracer = debugger
 
+	case profiler != nil:
+		tracer = profiler
+
 	default:
 		debugger = vm.NewStructLogger(config)
 	}
@@ -91,7 +98,7 @@ func stateTestCmd(ctx *cli.Context) error {
 	// Iterate over all the tests, run them and aggregate the results
 	cfg := vm.Config{
 		Tracer: tracer,
-		Debug:  ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name),
+		Debug:  ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name) || profiler != nil,
 	}
 	results := make([]StatetestResult, 0, len(tests))
 	for key, test := range tests {
@@ -123,6 +130,11 @@ func stateTestCmd(ctx *cli.Context) error {
 			}
 		}
 	}
+	if profiler != nil {
+		if err := writeProfile(ctx, profiler); err != nil {
+			return err
+		}
+	}
 	out, _ := json.MarshalIndent(results, "", "  ")
 	fmt.Println(string(out))
 	return nil
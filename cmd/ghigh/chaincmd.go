@@ -118,6 +118,49 @@ Optional second and third arguments control the first and
 last block to write. In this mode, the file will be appended
 if already existing. If the file ends with .gz, the output will
 be gzipped.`,
+	}
+	exportHistoryCommand = cli.Command{
+		Action:    utils.MigrateFlags(exportHistory),
+		Name:      "export-history",
+		Usage:     "Export blockchain history into era archives",
+		ArgsUsage: "<dir> <blockNumFirst> <blockNumLast>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The export-history command writes the blocks, receipts and total difficulties in
+the given range into the directory, one era archive per epoch of 8192 blocks. Each
+archive is named after its network, epoch and accumulator root. Epochs already
+exported are skipped, so an interrupted export can be resumed by running it again.`,
+	}
+	importHistoryCommand = cli.Command{
+		Action:    utils.MigrateFlags(importHistory),
+		Name:      "import-history",
+		Usage:     "Import blockchain history from era archives",
+		ArgsUsage: "<dir>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			utils.GCModeFlag,
+			utils.SnapshotFlag,
+			utils.CacheDatabaseFlag,
+			utils.CacheGCFlag,
+			utils.TxLookupLimitFlag,
+			utils.HistorySeedFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The import-history command imports the era archives of the chain's network found
+in the directory. Every epoch is verified against its accumulator root and the
+local chain before importing it, and the epochs already imported are skipped, so
+an interrupted import can be resumed by running it again.
+
+With --history.seed the blocks and receipts are written directly into the ancient
+store without re-executing them. The state needs to be synced afterwards.`,
 	}
 	importPreimagesCommand = cli.Command{
 		Action:    utils.MigrateFlags(importPreimages),
@@ -289,7 +332,7 @@ func importChain(ctx *cli.Context) error {
 	fmt.Printf("Import done in %v.\n\n", time.Since(start))
 
 	// Output pre-compaction stats mostly to see the import trashing
-	showLeveldbStats(db)
+	showDBStats(db)
 
 	// Print the memory statistics used by the importing
 	mem := new(runtime.MemStats)
@@ -312,7 +355,7 @@ func importChain(ctx *cli.Context) error {
 	}
 	fmt.Printf("Compaction done in %v.\n\n", time.Since(start))
 
-	showLeveldbStats(db)
+	showDBStats(db)
 	return importErr
 }
 
@@ -351,6 +394,48 @@ func exportChain(ctx *cli.Context) error {
 	return nil
 }
 
+func exportHistory(ctx *cli.Context) error {
+	if len(ctx.Args()) != 3 {
+		utils.Fatalf("This command requires three arguments.")
+	}
+	first, ferr := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+	last, lerr := strconv.ParseUint(ctx.Args().Get(2), 10, 64)
+	if ferr != nil || lerr != nil {
+		utils.Fatalf("Export error in parsing parameters: block number not a positive integer\n")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, _ := utils.MakeChain(ctx, stack, true)
+	start := time.Now()
+
+	if err := utils.ExportHistory(chain, ctx.Args().First(), first, last); err != nil {
+		utils.Fatalf("Export error: %v\n", err)
+	}
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
+
+func importHistory(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, db := utils.MakeChain(ctx, stack, false)
+	defer db.Close()
+
+	start := time.Now()
+	err := utils.ImportHistory(chain, ctx.Args().First(), ctx.Bool(utils.HistorySeedFlag.Name))
+	chain.Stop()
+	if err != nil {
+		utils.Fatalf("Import error: %v\n", err)
+	}
+	fmt.Printf("Import done in %v\n", time.Since(start))
+	return nil
+}
+
 // importPreimages imports preimage data from the specified file.
 func importPreimages(ctx *cli.Context) error {
 	if len(ctx.Args()) < 1 {
@@ -17,6 +17,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -28,9 +29,7 @@ import (
 	"github.com/420integrated/go-highcoin/console/prompt"
 	"github.com/420integrated/go-highcoin/core/rawdb"
 	"github.com/420integrated/go-highcoin/highdb"
-	"github.com/420integrated/go-highcoin/highdb/leveldb"
 	"github.com/420integrated/go-highcoin/log"
-	"github.com/syndtr/goleveldb/leveldb/opt"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -59,6 +58,7 @@ Remove blockchain and state databases`,
 			dbGetCmd,
 			dbDeleteCmd,
 			dbPutCmd,
+			dbPruneHistoryCmd,
 		},
 	}
 	dbInspectCmd = cli.Command{
@@ -72,12 +72,12 @@ Remove blockchain and state databases`,
 	dbStatCmd = cli.Command{
 		Action: dbStats,
 		Name:   "stats",
-		Usage:  "Print leveldb statistics",
+		Usage:  "Print database statistics",
 	}
 	dbCompactCmd = cli.Command{
 		Action: dbCompact,
 		Name:   "compact",
-		Usage:  "Compact leveldb database. WARNING: May take a very long time",
+		Usage:  "Compact database. WARNING: May take a very long time",
 		Description: `This command performs a database compaction. 
 WARNING: This operation may take a very long time to finish, and may cause database
 corruption if it is aborted during execution'!`,
@@ -105,6 +105,22 @@ WARNING: This is a low-level operation which may cause database corruption!`,
 		Description: `This command sets a given database key to the given value. 
 WARNING: This is a low-level operation which may cause database corruption!`,
 	}
+	dbPruneHistoryCmd = cli.Command{
+		Action:    utils.MigrateFlags(pruneHistory),
+		Name:      "prune-history",
+		Usage:     "Prune the bodies and receipts of old blocks (WARNING: they can only be restored by resyncing)",
+		ArgsUsage: "",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.HistoryRetainFlag,
+		},
+		Description: `This command deletes the bodies and receipts of the blocks older than the
+number of recent blocks given by --history.retain from the ancient database. Headers
+and block hashes are kept, so the chain remains verifiable. The ancient data is
+deleted in whole files, so a few more blocks than requested might be retained.
+WARNING: Pruned data can only be restored by resyncing the chain!`,
+	}
 )
 
 func removeDB(ctx *cli.Context) error {
@@ -198,7 +214,13 @@ func inspect(ctx *cli.Context) error {
 	return rawdb.InspectDatabase(chainDb, prefix, start)
 }
 
-func showLeveldbStats(db highdb.Stater) {
+// showDBStats prints the internal statistics of a database, whichever of the
+// supported engines backs it.
+func showDBStats(db highdb.Stater) {
+	if stats, err := db.Stat("pebble.stats"); err == nil {
+		fmt.Println(stats)
+		return
+	}
 	if stats, err := db.Stat("leveldb.stats"); err != nil {
 		log.Warn("Failed to read database stats", "error", err)
 	} else {
@@ -215,13 +237,11 @@ func dbStats(ctx *cli.Context) error {
 	stack, _ := makeConfigNode(ctx)
 	defer stack.Close()
 	path := stack.ResolvePath("chaindata")
-	db, err := leveldb.NewCustom(path, "", func(options *opt.Options) {
-		options.ReadOnly = true
-	})
+	db, err := rawdb.NewKeyValueStore(stack.Config().DBEngine, path, 0, 0, "", true)
 	if err != nil {
 		return err
 	}
-	showLeveldbStats(db)
+	showDBStats(db)
 	err = db.Close()
 	if err != nil {
 		log.Info("Close err", "error", err)
@@ -234,21 +254,17 @@ func dbCompact(ctx *cli.Context) error {
 	defer stack.Close()
 	path := stack.ResolvePath("chaindata")
 	cache := ctx.GlobalInt(utils.CacheFlag.Name) * ctx.GlobalInt(utils.CacheDatabaseFlag.Name) / 100
-	db, err := leveldb.NewCustom(path, "", func(options *opt.Options) {
-		options.OpenFilesCacheCapacity = utils.MakeDatabaseHandles()
-		options.BlockCacheCapacity = cache / 2 * opt.MiB
-		options.WriteBuffer = cache / 4 * opt.MiB // Two of these are used internally
-	})
+	db, err := rawdb.NewKeyValueStore(stack.Config().DBEngine, path, cache, utils.MakeDatabaseHandles(), "", false)
 	if err != nil {
 		return err
 	}
-	showLeveldbStats(db)
+	showDBStats(db)
 	log.Info("Triggering compaction")
 	err = db.Compact(nil, nil)
 	if err != nil {
 		log.Info("Compact err", "error", err)
 	}
-	showLeveldbStats(db)
+	showDBStats(db)
 	log.Info("Closing db")
 	err = db.Close()
 	if err != nil {
@@ -266,9 +282,7 @@ func dbGet(ctx *cli.Context) error {
 	stack, _ := makeConfigNode(ctx)
 	defer stack.Close()
 	path := stack.ResolvePath("chaindata")
-	db, err := leveldb.NewCustom(path, "", func(options *opt.Options) {
-		options.ReadOnly = true
-	})
+	db, err := rawdb.NewKeyValueStore(stack.Config().DBEngine, path, 0, 0, "", true)
 	if err != nil {
 		return err
 	}
@@ -339,3 +353,35 @@ func dbPut(ctx *cli.Context) error {
 	}
 	return db.Put(key, value)
 }
+
+// pruneHistory deletes the bodies and receipts of the blocks older than the
+// requested retention from the ancient database.
+func pruneHistory(ctx *cli.Context) error {
+	stack, cfg := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	head := rawdb.ReadHeaderNumber(db, rawdb.ReadHeadBlockHash(db))
+	if head == nil {
+		return errors.New("head block missing")
+	}
+	// Use the retention of the node config unless overridden on the command line
+	retain := ctx.Uint64(utils.HistoryRetainFlag.Name)
+	if !ctx.IsSet(utils.HistoryRetainFlag.Name) && cfg.High.HistoryRetain > 0 {
+		retain = cfg.High.HistoryRetain
+	}
+	if *head <= retain {
+		log.Info("Chain history within retention, nothing to prune", "head", *head, "retain", retain)
+		return nil
+	}
+	start := time.Now()
+	tail, err := rawdb.PruneChainHistory(db, *head-retain)
+	if err != nil {
+		log.Error("Failed to prune chain history", "err", err)
+		return err
+	}
+	log.Info("Pruned chain history", "head", *head, "retain", retain, "tail", tail, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
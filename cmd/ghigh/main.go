@@ -63,6 +63,7 @@ var (
 		utils.BootnodesFlag,
 		utils.DataDirFlag,
 		utils.AncientFlag,
+		utils.DBEngineFlag,
 		utils.MinFreeDiskSpaceFlag,
 		utils.KeyStoreDirFlag,
 		utils.ExternalSignerFlag,
@@ -81,6 +82,8 @@ var (
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolJournalFlag,
 		utils.TxPoolRejournalFlag,
+		utils.TxPoolJournalRemotesFlag,
+		utils.TxPoolJournalRemoteSizeFlag,
 		utils.TxPoolPriceLimitFlag,
 		utils.TxPoolPriceBumpFlag,
 		utils.TxPoolAccountSlotsFlag,
@@ -88,11 +91,14 @@ var (
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPoolPolicyFlag,
 		utils.SyncModeFlag,
 		utils.ExitWhenSyncedFlag,
 		utils.GCModeFlag,
 		utils.SnapshotFlag,
+		utils.StateHistoryFlag,
 		utils.TxLookupLimitFlag,
+		utils.HistoryRetainFlag,
 		utils.LightServeFlag,
 		utils.LightIngressFlag,
 		utils.LightEgressFlag,
@@ -127,6 +133,8 @@ var (
 		utils.MinerExtraDataFlag,
 		utils.MinerRecommitIntervalFlag,
 		utils.MinerNoVerfiyFlag,
+		utils.MinerOrderingFlag,
+		utils.MinerLocalReserveFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
@@ -142,6 +150,8 @@ var (
 		utils.GoerliFlag,
 		utils.YoloV3Flag,
 		utils.VMEnableDebugFlag,
+		utils.VMTraceFlag,
+		utils.VMTraceConfigFlag,
 		utils.NetworkIdFlag,
 		utils.HighStatsURLFlag,
 		utils.FakePoWFlag,
@@ -177,12 +187,22 @@ var (
 		utils.WSApiFlag,
 		utils.WSAllowedOriginsFlag,
 		utils.WSPathPrefixFlag,
+		utils.AuthEnabledFlag,
+		utils.AuthListenAddrFlag,
+		utils.AuthPortFlag,
+		utils.AuthVirtualHostsFlag,
+		utils.AuthApiFlag,
+		utils.AuthRestrictFlag,
+		utils.JWTSecretFlag,
 		utils.IPCDisabledFlag,
 		utils.IPCPathFlag,
 		utils.InsecureUnlockAllowedFlag,
 		utils.RPCGlobalSmokeCapFlag,
 		utils.RPCGlobalTxFeeCapFlag,
 		utils.AllowUnprotectedTxs,
+		utils.BatchRequestLimitFlag,
+		utils.BatchResponseMaxSizeFlag,
+		utils.RPCRateLimitFlag,
 	}
 
 	whisperFlags = []cli.Flag{
@@ -216,6 +236,8 @@ func init() {
 		initCommand,
 		importCommand,
 		exportCommand,
+		importHistoryCommand,
+		exportHistoryCommand,
 		importPreimagesCommand,
 		exportPreimagesCommand,
 		copydbCommand,
@@ -243,6 +265,8 @@ func init() {
 		utils.ShowDeprecated,
 		// See snapshot.go
 		snapshotCommand,
+		// See txjournalcmd.go
+		txJournalCommand,
 	}
 	sort.Sort(cli.CommandsByName(app.Commands))
 
@@ -0,0 +1,115 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of go-highcoin.
+//
+// go-highcoin is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-highcoin is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-highcoin. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/420integrated/go-highcoin/cmd/utils"
+	"github.com/420integrated/go-highcoin/core"
+	"github.com/420integrated/go-highcoin/log"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	txJournalCommand = cli.Command{
+		Name:      "txjournal",
+		Usage:     "Offline operations on the transaction pool journal",
+		ArgsUsage: "",
+		Category:  "MISCELLANEOUS COMMANDS",
+		Subcommands: []cli.Command{
+			txJournalInspectCmd,
+			txJournalCompactCmd,
+		},
+	}
+	txJournalInspectCmd = cli.Command{
+		Action:    utils.MigrateFlags(inspectTxJournal),
+		Name:      "inspect",
+		Usage:     "Print the contents and integrity of the transaction journal",
+		ArgsUsage: "[<journal file>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.TxPoolJournalFlag,
+		},
+		Description: `This command reads the transaction journal and reports its format version,
+the number of local and remote transactions, and any corrupted or partially
+written records. Without an explicit file, the journal configured by --txpool.journal
+is used.`,
+	}
+	txJournalCompactCmd = cli.Command{
+		Action:    utils.MigrateFlags(compactTxJournal),
+		Name:      "compact",
+		Usage:     "Rewrite the transaction journal without corrupted and duplicate records",
+		ArgsUsage: "[<journal file>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.TxPoolJournalFlag,
+		},
+		Description: `This command rewrites the transaction journal in the current format, dropping
+the corrupted records and the duplicate transactions. The node must not be running.
+Without an explicit file, the journal configured by --txpool.journal is used.`,
+	}
+)
+
+// txJournalPath resolves the journal file to operate on.
+func txJournalPath(ctx *cli.Context) (string, error) {
+	if ctx.NArg() > 1 {
+		return "", errors.New("too many arguments given")
+	}
+	if ctx.NArg() == 1 {
+		return ctx.Args().First(), nil
+	}
+	stack, config := makeConfigNode(ctx)
+	defer stack.Close()
+
+	if config.High.TxPool.Journal == "" {
+		return "", errors.New("transaction journal disabled")
+	}
+	return stack.ResolvePath(config.High.TxPool.Journal), nil
+}
+
+func inspectTxJournal(ctx *cli.Context) error {
+	path, err := txJournalPath(ctx)
+	if err != nil {
+		return err
+	}
+	stats, err := core.InspectTxJournal(path)
+	if stats != nil {
+		fmt.Printf("Journal:   %s\n", path)
+		fmt.Printf("Version:   %d\n", stats.Version)
+		fmt.Printf("Locals:    %d\n", stats.Locals)
+		fmt.Printf("Remotes:   %d\n", stats.Remotes)
+		fmt.Printf("Corrupted: %d\n", stats.Corrupted)
+		fmt.Printf("Truncated: %v\n", stats.Truncated)
+	}
+	return err
+}
+
+func compactTxJournal(ctx *cli.Context) error {
+	path, err := txJournalPath(ctx)
+	if err != nil {
+		return err
+	}
+	stats, kept, err := core.CompactTxJournal(path)
+	if err != nil {
+		return err
+	}
+	log.Info("Compacted transaction journal", "path", path, "version", stats.Version,
+		"records", stats.Locals+stats.Remotes, "kept", kept, "corrupted", stats.Corrupted, "truncated", stats.Truncated)
+	return nil
+}
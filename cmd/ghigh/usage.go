@@ -36,6 +36,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			configFileFlag,
 			utils.DataDirFlag,
 			utils.AncientFlag,
+			utils.DBEngineFlag,
 			utils.MinFreeDiskSpaceFlag,
 			utils.KeyStoreDirFlag,
 			utils.USBFlag,
@@ -50,6 +51,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.ExitWhenSyncedFlag,
 			utils.GCModeFlag,
 			utils.TxLookupLimitFlag,
+			utils.HistoryRetainFlag,
 			utils.HighStatsURLFlag,
 			utils.IdentityFlag,
 			utils.LightKDFFlag,
@@ -97,6 +99,8 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.TxPoolNoLocalsFlag,
 			utils.TxPoolJournalFlag,
 			utils.TxPoolRejournalFlag,
+			utils.TxPoolJournalRemotesFlag,
+			utils.TxPoolJournalRemoteSizeFlag,
 			utils.TxPoolPriceLimitFlag,
 			utils.TxPoolPriceBumpFlag,
 			utils.TxPoolAccountSlotsFlag,
@@ -104,6 +108,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.TxPoolAccountQueueFlag,
 			utils.TxPoolGlobalQueueFlag,
 			utils.TxPoolLifetimeFlag,
+			utils.TxPoolPolicyFlag,
 		},
 	},
 	{
@@ -147,11 +152,21 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.WSApiFlag,
 			utils.WSPathPrefixFlag,
 			utils.WSAllowedOriginsFlag,
+			utils.AuthEnabledFlag,
+			utils.AuthListenAddrFlag,
+			utils.AuthPortFlag,
+			utils.AuthVirtualHostsFlag,
+			utils.AuthApiFlag,
+			utils.AuthRestrictFlag,
+			utils.JWTSecretFlag,
 			utils.GraphQLEnabledFlag,
 			utils.GraphQLCORSDomainFlag,
 			utils.GraphQLVirtualHostsFlag,
 			utils.RPCGlobalSmokeCapFlag,
 			utils.RPCGlobalTxFeeCapFlag,
+			utils.BatchRequestLimitFlag,
+			utils.BatchResponseMaxSizeFlag,
+			utils.RPCRateLimitFlag,
 			utils.JSpathFlag,
 			utils.ExecFlag,
 			utils.PreloadJSFlag,
@@ -186,6 +201,8 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.MinerExtraDataFlag,
 			utils.MinerRecommitIntervalFlag,
 			utils.MinerNoVerfiyFlag,
+			utils.MinerOrderingFlag,
+			utils.MinerLocalReserveFlag,
 		},
 	},
 	{
@@ -200,6 +217,8 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 		Name: "VIRTUAL MACHINE",
 		Flags: []cli.Flag{
 			utils.VMEnableDebugFlag,
+			utils.VMTraceFlag,
+			utils.VMTraceConfigFlag,
 			utils.EVMInterpreterFlag,
 			utils.EWASMInterpreterFlag,
 		},
@@ -235,6 +254,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 		Name: "MISC",
 		Flags: []cli.Flag{
 			utils.SnapshotFlag,
+			utils.StateHistoryFlag,
 			utils.BloomFilterSizeFlag,
 			cli.HelpFlag,
 		},
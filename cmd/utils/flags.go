@@ -64,6 +64,7 @@ import (
 	"github.com/420integrated/go-highcoin/p2p/nat"
 	"github.com/420integrated/go-highcoin/p2p/netutil"
 	"github.com/420integrated/go-highcoin/params"
+	"github.com/420integrated/go-highcoin/rpc"
 	pcsclite "github.com/gballet/go-libpcsclite"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -114,6 +115,10 @@ var (
 		Name:  "datadir.ancient",
 		Usage: "Data directory for ancient chain segments (default = inside chaindata)",
 	}
+	DBEngineFlag = cli.StringFlag{
+		Name:  "db.engine",
+		Usage: "Backing database implementation to use ('leveldb' or 'pebble', default = existing database or leveldb)",
+	}
 	MinFreeDiskSpaceFlag = DirectoryFlag{
 		Name:  "datadir.minfreedisk",
 		Usage: "Minimum free disk space in MB, once reached triggers auto shut down (default = --cache.gc converted to MB, 0 = disabled)",
@@ -212,11 +217,24 @@ var (
 		Name:  "snapshot",
 		Usage: `Enables snapshot-database mode (default = enable)`,
 	}
+	StateHistoryFlag = cli.BoolFlag{
+		Name:  "state.history",
+		Usage: "Record reverse state diffs to serve state reads of the last 8192 blocks without archive mode (requires --snapshot)",
+	}
 	TxLookupLimitFlag = cli.Uint64Flag{
 		Name:  "txlookuplimit",
 		Usage: "Number of recent blocks to maintain transactions index for (default = about one year, 0 = entire chain)",
 		Value: highconfig.Defaults.TxLookupLimit,
 	}
+	HistoryRetainFlag = cli.Uint64Flag{
+		Name:  "history.retain",
+		Usage: "Number of recent blocks to retain bodies and receipts for, pruning older ones from the ancient store (default = entire chain when running a node)",
+		Value: params.FullImmutabilityThreshold,
+	}
+	HistorySeedFlag = cli.BoolFlag{
+		Name:  "history.seed",
+		Usage: "Write imported history directly into the ancient store without re-executing it",
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -330,6 +348,15 @@ var (
 		Usage: "Time interval to regenerate the local transaction journal",
 		Value: core.DefaultTxPoolConfig.Rejournal,
 	}
+	TxPoolJournalRemotesFlag = cli.BoolFlag{
+		Name:  "txpool.journalremotes",
+		Usage: "Persists the best paying remote pending transactions into the journal too",
+	}
+	TxPoolJournalRemoteSizeFlag = cli.Uint64Flag{
+		Name:  "txpool.journalremotesize",
+		Usage: "Maximum size of the remote transactions persisted into the journal (MB)",
+		Value: core.DefaultTxPoolConfig.JournalRemoteSize / 1024 / 1024,
+	}
 	TxPoolPriceLimitFlag = cli.Uint64Flag{
 		Name:  "txpool.pricelimit",
 		Usage: "Minimum smoke price limit to enforce for acceptance into the pool",
@@ -365,6 +392,10 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: highconfig.Defaults.TxPool.Lifetime,
 	}
+	TxPoolPolicyFlag = cli.StringFlag{
+		Name:  "txpool.policy",
+		Usage: "JSON file with the transaction admission policy (reloaded on change)",
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -456,6 +487,15 @@ var (
 		Name:  "miner.noverify",
 		Usage: "Disable remote sealing verification",
 	}
+	MinerOrderingFlag = cli.StringFlag{
+		Name:  "miner.ordering",
+		Usage: `Transaction ordering policy of mined blocks ("local", "price" or "fifo")`,
+		Value: miner.LocalOrderingName,
+	}
+	MinerLocalReserveFlag = cli.Uint64Flag{
+		Name:  "miner.localreserve",
+		Usage: "Smoke of each block reserved for local transactions (local ordering only)",
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -476,6 +516,14 @@ var (
 		Name:  "vmdebug",
 		Usage: "Record information useful for VM and contract debugging",
 	}
+	VMTraceFlag = cli.StringFlag{
+		Name:  "vmtrace",
+		Usage: "Name of the live tracer tracing the blocks during import (e.g. json, callTracer)",
+	}
+	VMTraceConfigFlag = cli.StringFlag{
+		Name:  "vmtrace.config",
+		Usage: `Live tracer configuration in JSON (e.g. '{"path":"trace.jsonl"}')`,
+	}
 	InsecureUnlockAllowedFlag = cli.BoolFlag{
 		Name:  "allow-insecure-unlock",
 		Usage: "Allow insecure account unlocking when account-related RPCs are exposed by http",
@@ -589,6 +637,39 @@ var (
 		Usage: "HTTP path prefix on which JSON-RPC is served. Use '/' to serve on all paths.",
 		Value: "",
 	}
+	AuthEnabledFlag = cli.BoolFlag{
+		Name:  "authrpc",
+		Usage: "Enable the JWT authenticated HTTP and WS-RPC server",
+	}
+	AuthListenAddrFlag = cli.StringFlag{
+		Name:  "authrpc.addr",
+		Usage: "Authenticated RPC server listening interface",
+		Value: node.DefaultAuthHost,
+	}
+	AuthPortFlag = cli.IntFlag{
+		Name:  "authrpc.port",
+		Usage: "Authenticated RPC server listening port",
+		Value: node.DefaultAuthPort,
+	}
+	AuthVirtualHostsFlag = cli.StringFlag{
+		Name:  "authrpc.vhosts",
+		Usage: "Comma separated list of virtual hostnames from which to accept authenticated requests (server enforced). Accepts '*' wildcard.",
+		Value: strings.Join(node.DefaultConfig.AuthVirtualHosts, ","),
+	}
+	AuthApiFlag = cli.StringFlag{
+		Name:  "authrpc.api",
+		Usage: "API's offered over the authenticated RPC interface",
+		Value: strings.Join(node.DefaultConfig.AuthModules, ","),
+	}
+	AuthRestrictFlag = cli.BoolFlag{
+		Name:  "authrpc.restrict",
+		Usage: "Serve the admin, personal and debug APIs only to authenticated callers (and over IPC)",
+	}
+	JWTSecretFlag = cli.StringFlag{
+		Name:  "authrpc.jwtsecret",
+		Usage: "Path to a hex encoded JWT secret for authenticated RPC (generated if missing)",
+		Value: "",
+	}
 	ExecFlag = cli.StringFlag{
 		Name:  "exec",
 		Usage: "Execute JavaScript statement",
@@ -601,6 +682,21 @@ var (
 		Name:  "rpc.allow-unprotected-txs",
 		Usage: "Allow for unprotected (non EIP155 signed) transactions to be submitted via RPC",
 	}
+	BatchRequestLimitFlag = cli.IntFlag{
+		Name:  "rpc.batch-request-limit",
+		Usage: "Maximum number of requests in a batch (0 = no limit)",
+		Value: node.DefaultConfig.BatchRequestLimit,
+	}
+	BatchResponseMaxSizeFlag = cli.IntFlag{
+		Name:  "rpc.batch-response-max-size",
+		Usage: "Maximum number of bytes returned from a batched call (0 = no limit)",
+		Value: node.DefaultConfig.BatchResponseMaxSize,
+	}
+	RPCRateLimitFlag = cli.StringFlag{
+		Name:  "rpc.ratelimit",
+		Usage: "Comma separated per-connection call rate limits by API namespace, as namespace=rate:burst ('*' for all other namespaces)",
+		Value: "",
+	}
 
 	// Network Settings
 	MaxPeersFlag = cli.IntFlag{
@@ -965,6 +1061,45 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(AllowUnprotectedTxs.Name) {
 		cfg.AllowUnprotectedTxs = ctx.GlobalBool(AllowUnprotectedTxs.Name)
 	}
+	if ctx.GlobalIsSet(BatchRequestLimitFlag.Name) {
+		cfg.BatchRequestLimit = ctx.GlobalInt(BatchRequestLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(BatchResponseMaxSizeFlag.Name) {
+		cfg.BatchResponseMaxSize = ctx.GlobalInt(BatchResponseMaxSizeFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCRateLimitFlag.Name) {
+		limits, err := parseRateLimits(ctx.GlobalString(RPCRateLimitFlag.Name))
+		if err != nil {
+			Fatalf("Invalid --%s: %v", RPCRateLimitFlag.Name, err)
+		}
+		cfg.RPCRateLimits = limits
+	}
+}
+
+// parseRateLimits parses a comma separated list of namespace=rate:burst RPC rate
+// limits.
+func parseRateLimits(input string) (map[string]rpc.RateLimit, error) {
+	limits := make(map[string]rpc.RateLimit)
+	for _, entry := range SplitAndTrim(input) {
+		parts := strings.Split(entry, "=")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid rate limit %q, want namespace=rate:burst", entry)
+		}
+		values := strings.Split(parts[1], ":")
+		if len(values) != 2 {
+			return nil, fmt.Errorf("invalid rate limit %q, want namespace=rate:burst", entry)
+		}
+		rate, err := strconv.ParseFloat(values[0], 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid rate in %q", entry)
+		}
+		burst, err := strconv.Atoi(values[1])
+		if err != nil || burst <= 0 {
+			return nil, fmt.Errorf("invalid burst in %q", entry)
+		}
+		limits[parts[0]] = rpc.RateLimit{Rate: rate, Burst: burst}
+	}
+	return limits, nil
 }
 
 // setGraphQL creates the GraphQL listener interface string from the set
@@ -1004,6 +1139,32 @@ func setWS(ctx *cli.Context, cfg *node.Config) {
 	}
 }
 
+// setAuth creates the authenticated RPC listener configuration from the set
+// command line flags.
+func setAuth(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalBool(AuthEnabledFlag.Name) && cfg.AuthHost == "" {
+		cfg.AuthHost = "127.0.0.1"
+		if ctx.GlobalIsSet(AuthListenAddrFlag.Name) {
+			cfg.AuthHost = ctx.GlobalString(AuthListenAddrFlag.Name)
+		}
+	}
+	if ctx.GlobalIsSet(AuthPortFlag.Name) {
+		cfg.AuthPort = ctx.GlobalInt(AuthPortFlag.Name)
+	}
+	if ctx.GlobalIsSet(AuthVirtualHostsFlag.Name) {
+		cfg.AuthVirtualHosts = SplitAndTrim(ctx.GlobalString(AuthVirtualHostsFlag.Name))
+	}
+	if ctx.GlobalIsSet(AuthApiFlag.Name) {
+		cfg.AuthModules = SplitAndTrim(ctx.GlobalString(AuthApiFlag.Name))
+	}
+	if ctx.GlobalIsSet(AuthRestrictFlag.Name) {
+		cfg.AuthRestrict = ctx.GlobalBool(AuthRestrictFlag.Name)
+	}
+	if ctx.GlobalIsSet(JWTSecretFlag.Name) {
+		cfg.JWTSecret = ctx.GlobalString(JWTSecretFlag.Name)
+	}
+}
+
 // setIPC creates an IPC path configuration from the set command line flags,
 // returning an empty string if IPC was explicitly disabled, or the set path.
 func setIPC(ctx *cli.Context, cfg *node.Config) {
@@ -1209,10 +1370,19 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setHTTP(ctx, cfg)
 	setGraphQL(ctx, cfg)
 	setWS(ctx, cfg)
+	setAuth(ctx, cfg)
 	setNodeUserIdent(ctx, cfg)
 	setDataDir(ctx, cfg)
 	setSmartCard(ctx, cfg)
 
+	if ctx.GlobalIsSet(DBEngineFlag.Name) {
+		engine := ctx.GlobalString(DBEngineFlag.Name)
+		if engine != rawdb.DBLeveldb && engine != rawdb.DBPebble {
+			Fatalf("Invalid choice for db.engine '%s', allowed 'leveldb' or 'pebble'", engine)
+		}
+		cfg.DBEngine = engine
+	}
+
 	if ctx.GlobalIsSet(ExternalSignerFlag.Name) {
 		cfg.ExternalSigner = ctx.GlobalString(ExternalSignerFlag.Name)
 	}
@@ -1319,6 +1489,12 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolRejournalFlag.Name) {
 		cfg.Rejournal = ctx.GlobalDuration(TxPoolRejournalFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolJournalRemotesFlag.Name) {
+		cfg.JournalRemotes = ctx.GlobalBool(TxPoolJournalRemotesFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolJournalRemoteSizeFlag.Name) {
+		cfg.JournalRemoteSize = ctx.GlobalUint64(TxPoolJournalRemoteSizeFlag.Name) * 1024 * 1024
+	}
 	if ctx.GlobalIsSet(TxPoolPriceLimitFlag.Name) {
 		cfg.PriceLimit = ctx.GlobalUint64(TxPoolPriceLimitFlag.Name)
 	}
@@ -1340,6 +1516,12 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolPolicyFlag.Name) {
+		cfg.PolicyFile = ctx.GlobalString(TxPoolPolicyFlag.Name)
+		if _, err := core.LoadTxPolicy(cfg.PolicyFile); err != nil {
+			Fatalf("Failed to load txpool policy: %v", err)
+		}
+	}
 }
 
 func setEthash(ctx *cli.Context, cfg *highconfig.Config) {
@@ -1391,6 +1573,13 @@ func setMiner(ctx *cli.Context, cfg *miner.Config) {
 	if ctx.GlobalIsSet(MinerNoVerfiyFlag.Name) {
 		cfg.Noverify = ctx.GlobalBool(MinerNoVerfiyFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerOrderingFlag.Name) || ctx.GlobalIsSet(MinerLocalReserveFlag.Name) {
+		ordering, err := miner.NewOrderingPolicy(ctx.GlobalString(MinerOrderingFlag.Name), ctx.GlobalUint64(MinerLocalReserveFlag.Name))
+		if err != nil {
+			Fatalf("Invalid miner ordering: %v", err)
+		}
+		cfg.Ordering = ordering
+	}
 }
 
 func setWhitelist(ctx *cli.Context, cfg *highconfig.Config) {
@@ -1512,6 +1701,9 @@ func SetHighConfig(ctx *cli.Context, stack *node.Node, cfg *highconfig.Config) {
 	if ctx.GlobalIsSet(CacheNoPrefetchFlag.Name) {
 		cfg.NoPrefetch = ctx.GlobalBool(CacheNoPrefetchFlag.Name)
 	}
+	if ctx.GlobalIsSet(StateHistoryFlag.Name) {
+		cfg.StateHistory = ctx.GlobalBool(StateHistoryFlag.Name)
+	}
 	// Read the value from the flag no matter if it's set or not.
 	cfg.Preimages = ctx.GlobalBool(CachePreimagesFlag.Name)
 	if cfg.NoPruning && !cfg.Preimages {
@@ -1521,6 +1713,9 @@ func SetHighConfig(ctx *cli.Context, stack *node.Node, cfg *highconfig.Config) {
 	if ctx.GlobalIsSet(TxLookupLimitFlag.Name) {
 		cfg.TxLookupLimit = ctx.GlobalUint64(TxLookupLimitFlag.Name)
 	}
+	if ctx.GlobalIsSet(HistoryRetainFlag.Name) {
+		cfg.HistoryRetain = ctx.GlobalUint64(HistoryRetainFlag.Name)
+	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
 		cfg.TrieCleanCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
 	}
@@ -1560,6 +1755,12 @@ func SetHighConfig(ctx *cli.Context, stack *node.Node, cfg *highconfig.Config) {
 	if ctx.GlobalIsSet(EVMInterpreterFlag.Name) {
 		cfg.EVMInterpreter = ctx.GlobalString(EVMInterpreterFlag.Name)
 	}
+	if ctx.GlobalIsSet(VMTraceFlag.Name) {
+		cfg.VMTrace = ctx.GlobalString(VMTraceFlag.Name)
+	}
+	if ctx.GlobalIsSet(VMTraceConfigFlag.Name) {
+		cfg.VMTraceConfig = ctx.GlobalString(VMTraceConfigFlag.Name)
+	}
 	if ctx.GlobalIsSet(RPCGlobalSmokeCapFlag.Name) {
 		cfg.RPCSmokeCap = ctx.GlobalUint64(RPCGlobalSmokeCapFlag.Name)
 	}
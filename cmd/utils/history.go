@@ -0,0 +1,319 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of go-highcoin.
+//
+// go-highcoin is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-highcoin is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-highcoin. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/core"
+	"github.com/420integrated/go-highcoin/core/types"
+	"github.com/420integrated/go-highcoin/internal/era"
+	"github.com/420integrated/go-highcoin/log"
+	"github.com/420integrated/go-highcoin/params"
+)
+
+// errInterrupted is returned if a history export or import is interrupted by
+// the user. The work done until the last completed epoch is kept.
+var errInterrupted = errors.New("interrupted")
+
+// historyNetwork returns the name of the network used in the era file names,
+// derived from the genesis block.
+func historyNetwork(genesis common.Hash) string {
+	switch genesis {
+	case params.MainnetGenesisHash:
+		return "mainnet"
+	case params.RopstenGenesisHash:
+		return "ropsten"
+	case params.RuderalisGenesisHash:
+		return "ruderalis"
+	case params.GoerliGenesisHash:
+		return "goerli"
+	default:
+		return fmt.Sprintf("%x", genesis[:4])
+	}
+}
+
+// watchInterrupt returns a channel closed when the user interrupts the process,
+// along with a function to stop watching.
+func watchInterrupt() (chan struct{}, func()) {
+	interrupt := make(chan os.Signal, 1)
+	stop := make(chan struct{})
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			log.Info("Interrupted, stopping after the current epoch")
+		}
+		close(stop)
+	}()
+	return stop, func() {
+		signal.Stop(interrupt)
+		close(interrupt)
+	}
+}
+
+// ExportHistory exports the blocks in the given range, along with their receipts
+// and total difficulties, into era files in the given directory, one file per
+// epoch. Epochs already exported and intact are skipped, so an interrupted export
+// can be resumed by running it again.
+func ExportHistory(bc *core.BlockChain, dir string, first, last uint64) error {
+	if head := bc.CurrentBlock().NumberU64(); last > head {
+		return fmt.Errorf("export range end %d beyond chain head %d", last, head)
+	}
+	if first > last {
+		return fmt.Errorf("invalid export range %d > %d", first, last)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	stop, unwatch := watchInterrupt()
+	defer unwatch()
+
+	var (
+		network = historyNetwork(bc.Genesis().Hash())
+		start   = time.Now()
+	)
+	log.Info("Exporting chain history", "dir", dir, "first", first, "last", last)
+	for epoch := first / era.MaxSize; epoch <= last/era.MaxSize; epoch++ {
+		select {
+		case <-stop:
+			return errInterrupted
+		default:
+		}
+		from, to := epoch*era.MaxSize, (epoch+1)*era.MaxSize-1
+		if from < first {
+			from = first
+		}
+		if to > last {
+			to = last
+		}
+		if err := exportEpoch(bc, dir, network, epoch, from, to); err != nil {
+			return fmt.Errorf("epoch %d: %v", epoch, err)
+		}
+	}
+	log.Info("Exported chain history", "dir", dir, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// exportEpoch writes the blocks in the given range of an epoch into an era file,
+// unless an intact file with the same range already exists.
+func exportEpoch(bc *core.BlockChain, dir, network string, epoch, from, to uint64) error {
+	existing, err := filepath.Glob(filepath.Join(dir, fmt.Sprintf("%s-%05d-*.era", network, epoch)))
+	if err != nil {
+		return err
+	}
+	for _, path := range existing {
+		if e, err := era.Open(path); err == nil {
+			intact := e.Start() == from && e.Count() == to-from+1 && e.Verify() == nil
+			e.Close()
+			if intact {
+				log.Info("Skipping exported epoch", "epoch", epoch, "file", filepath.Base(path))
+				return nil
+			}
+		}
+		log.Warn("Replacing damaged or partial epoch", "epoch", epoch, "file", filepath.Base(path))
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	// Write the epoch into a temporary file, only naming it after completion
+	tmp := filepath.Join(dir, fmt.Sprintf("%s-%05d.era.tmp", network, epoch))
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	builder := era.NewBuilder(f)
+	for number := from; number <= to; number++ {
+		block := bc.GetBlockByNumber(number)
+		if block == nil {
+			f.Close()
+			return fmt.Errorf("block %d not found", number)
+		}
+		td := bc.GetTd(block.Hash(), number)
+		if td == nil {
+			f.Close()
+			return fmt.Errorf("total difficulty of block %d not found", number)
+		}
+		if err := builder.Add(block, bc.GetReceiptsByHash(block.Hash()), td); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	root, err := builder.Finalize()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+
+	name := era.Filename(network, epoch, root)
+	if err := os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+		return err
+	}
+	log.Info("Exported epoch", "epoch", epoch, "file", name, "blocks", to-from+1)
+	return nil
+}
+
+// ImportHistory imports the era files of the chain's network from the given
+// directory. Every epoch is verified before importing it and the epochs already
+// present in the chain are skipped, so an interrupted import can be resumed by
+// running it again.
+//
+// If seed is set, the blocks and receipts are written directly into the database
+// without re-executing them, advancing only the fast sync head. The blocks deep
+// enough below the last imported one to be final are written into the ancient
+// store, the recent ones into the key-value store so they can still be reorged.
+// The state of the imported blocks is not available afterwards, it needs to be
+// synced.
+func ImportHistory(bc *core.BlockChain, dir string, seed bool) error {
+	network := historyNetwork(bc.Genesis().Hash())
+	files, err := era.ReadDir(dir, network)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no era files of network %s found in %s", network, dir)
+	}
+	var ancientLimit uint64
+	if seed {
+		e, err := era.Open(files[len(files)-1])
+		if err != nil {
+			return fmt.Errorf("%s: %v", filepath.Base(files[len(files)-1]), err)
+		}
+		if tip := e.Start() + e.Count() - 1; tip > params.FullImmutabilityThreshold {
+			ancientLimit = tip - params.FullImmutabilityThreshold
+		}
+		e.Close()
+	}
+	stop, unwatch := watchInterrupt()
+	defer unwatch()
+
+	start := time.Now()
+	log.Info("Importing chain history", "dir", dir, "files", len(files), "seed", seed)
+	for _, path := range files {
+		select {
+		case <-stop:
+			return errInterrupted
+		default:
+		}
+		if err := importEpoch(bc, path, seed, ancientLimit); err != nil {
+			return fmt.Errorf("%s: %v", filepath.Base(path), err)
+		}
+	}
+	log.Info("Imported chain history", "dir", dir, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// importEpoch verifies and imports the blocks of a single era file missing from
+// the chain. In seed mode, the blocks up to the ancient limit are written into
+// the ancient store.
+func importEpoch(bc *core.BlockChain, path string, seed bool, ancientLimit uint64) error {
+	e, err := era.Open(path)
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+
+	head := bc.CurrentBlock().NumberU64()
+	if seed {
+		head = bc.CurrentFastBlock().NumberU64()
+	}
+	end := e.Start() + e.Count() - 1
+	if end <= head {
+		log.Info("Skipping imported epoch", "file", filepath.Base(path), "head", head)
+		return nil
+	}
+	if e.Start() > head+1 {
+		return fmt.Errorf("gap in chain history: epoch starts at %d, chain head at %d", e.Start(), head)
+	}
+	// Verify the integrity of the whole epoch and the file name checksum
+	if err := e.Verify(); err != nil {
+		return err
+	}
+	root, err := e.Accumulator()
+	if err != nil {
+		return err
+	}
+	if !strings.HasSuffix(path, fmt.Sprintf("-%x.era", root[:4])) {
+		return fmt.Errorf("accumulator %x doesn't match file name", root)
+	}
+	// Ensure the epoch extends the local chain
+	from := head + 1
+	if from < e.Start() {
+		from = e.Start()
+	}
+	parent := bc.GetBlockByNumber(from - 1)
+	if parent == nil {
+		return fmt.Errorf("parent block %d not found", from-1)
+	}
+	block, _, td, err := e.GetBlockByNumber(from)
+	if err != nil {
+		return err
+	}
+	if block.ParentHash() != parent.Hash() {
+		return fmt.Errorf("block %d doesn't extend the chain: parent %x, head %x", from, block.ParentHash(), parent.Hash())
+	}
+	if want := new(big.Int).Add(bc.GetTd(parent.Hash(), parent.NumberU64()), block.Difficulty()); td.Cmp(want) != 0 {
+		return fmt.Errorf("block %d total difficulty mismatch: have %v, want %v", from, td, want)
+	}
+	// Import the missing blocks in batches
+	for batch := from; batch <= end; batch += importBatchSize {
+		var (
+			blocks   types.Blocks
+			receipts []types.Receipts
+		)
+		for number := batch; number <= end && number < batch+importBatchSize; number++ {
+			block, rs, _, err := e.GetBlockByNumber(number)
+			if err != nil {
+				return err
+			}
+			blocks, receipts = append(blocks, block), append(receipts, rs)
+		}
+		if seed {
+			headers := make([]*types.Header, len(blocks))
+			for i, block := range blocks {
+				headers[i] = block.Header()
+			}
+			if _, err := bc.InsertHeaderChain(headers, 100); err != nil {
+				return err
+			}
+			if _, err := bc.InsertReceiptChain(blocks, receipts, ancientLimit); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := bc.InsertChain(blocks); err != nil {
+			return err
+		}
+	}
+	log.Info("Imported epoch", "file", filepath.Base(path), "blocks", end-from+1)
+	return nil
+}
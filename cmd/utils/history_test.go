@@ -0,0 +1,267 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of go-highcoin.
+//
+// go-highcoin is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-highcoin is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-highcoin. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/consensus/ethash"
+	"github.com/420integrated/go-highcoin/core"
+	"github.com/420integrated/go-highcoin/core/rawdb"
+	"github.com/420integrated/go-highcoin/core/types"
+	"github.com/420integrated/go-highcoin/core/vm"
+	"github.com/420integrated/go-highcoin/crypto"
+	"github.com/420integrated/go-highcoin/highdb"
+	"github.com/420integrated/go-highcoin/highdb/memorydb"
+	"github.com/420integrated/go-highcoin/internal/era"
+	"github.com/420integrated/go-highcoin/params"
+)
+
+var (
+	historyKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	historyAddr    = crypto.PubkeyToAddress(historyKey.PublicKey)
+	historyGenesis = &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{historyAddr: {Balance: big.NewInt(params.Highcoin)}},
+	}
+)
+
+// newHistoryChain creates a chain on top of the test genesis in the given
+// database, with the given blocks inserted.
+func newHistoryChain(t *testing.T, db highdb.Database, blocks types.Blocks) *core.BlockChain {
+	historyGenesis.MustCommit(db)
+	chain, err := core.NewBlockChain(db, nil, historyGenesis.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	return chain
+}
+
+// makeHistoryBlocks generates a chain of blocks with a transfer in each.
+func makeHistoryBlocks(n int) types.Blocks {
+	db := rawdb.NewMemoryDatabase()
+	signer := types.LatestSigner(historyGenesis.Config)
+	blocks, _ := core.GenerateChain(historyGenesis.Config, historyGenesis.MustCommit(db), ethash.NewFaker(), db, n, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(historyAddr), common.Address{0x01}, big.NewInt(1), params.TxSmoke, nil, nil), signer, historyKey)
+		b.AddTx(tx)
+	})
+	return blocks
+}
+
+// historyFiles returns the era files in the given directory along with their
+// modification times.
+func historyFiles(t *testing.T, dir string) map[string]int64 {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	files := make(map[string]int64)
+	for _, entry := range entries {
+		files[entry.Name()] = entry.ModTime().UnixNano()
+	}
+	return files
+}
+
+// Tests that exports write intact epochs once, replacing partial ones, and that
+// imports verify the epochs, skip the imported blocks and resume from the head.
+func TestHistoryExportImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	blocks := makeHistoryBlocks(30)
+	source := newHistoryChain(t, rawdb.NewMemoryDatabase(), blocks)
+	defer source.Stop()
+
+	// Export a partial epoch, then make sure a repeated export skips it
+	if err := ExportHistory(source, dir, 0, 20); err != nil {
+		t.Fatalf("failed to export history: %v", err)
+	}
+	exported := historyFiles(t, dir)
+	if len(exported) != 1 {
+		t.Fatalf("exported file count mismatch: have %d, want 1", len(exported))
+	}
+	if err := ExportHistory(source, dir, 0, 20); err != nil {
+		t.Fatalf("failed to re-export history: %v", err)
+	}
+	if have := historyFiles(t, dir); len(have) != 1 || have[firstKey(exported)] != exported[firstKey(exported)] {
+		t.Fatalf("intact epoch rewritten: have %v, want %v", have, exported)
+	}
+	// Import the partial epoch, and again to make sure it's skipped
+	target := newHistoryChain(t, rawdb.NewMemoryDatabase(), nil)
+	defer target.Stop()
+
+	for i := 0; i < 2; i++ {
+		if err := ImportHistory(target, dir, false); err != nil {
+			t.Fatalf("import %d: failed to import history: %v", i, err)
+		}
+		if head := target.CurrentBlock(); head.Hash() != blocks[19].Hash() {
+			t.Fatalf("import %d: head mismatch: have #%d, want #20", i, head.NumberU64())
+		}
+	}
+	// Extend the export, which should replace the partial epoch, and resume the
+	// import from the current head
+	if err := ExportHistory(source, dir, 0, 30); err != nil {
+		t.Fatalf("failed to extend export: %v", err)
+	}
+	if have := historyFiles(t, dir); len(have) != 1 || have[firstKey(exported)] != 0 {
+		t.Fatalf("partial epoch not replaced: have %v", have)
+	}
+	if err := ImportHistory(target, dir, false); err != nil {
+		t.Fatalf("failed to resume import: %v", err)
+	}
+	if head := target.CurrentBlock(); head.Hash() != blocks[29].Hash() {
+		t.Fatalf("resumed head mismatch: have #%d, want #30", head.NumberU64())
+	}
+	for _, block := range blocks {
+		if have, want := target.GetReceiptsByHash(block.Hash()), source.GetReceiptsByHash(block.Hash()); len(have) != len(want) {
+			t.Fatalf("block #%d: receipt count mismatch: have %d, want %d", block.NumberU64(), len(have), len(want))
+		}
+	}
+	// Damaged epochs must be rejected
+	path := filepath.Join(dir, firstKey(historyFiles(t, dir)))
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob[len(blob)/2] ^= 0xff
+	if err := ioutil.WriteFile(path, blob, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fresh := newHistoryChain(t, rawdb.NewMemoryDatabase(), nil)
+	defer fresh.Stop()
+
+	if err := ImportHistory(fresh, dir, false); err == nil {
+		t.Fatalf("damaged epoch imported")
+	}
+	if head := fresh.CurrentBlock().NumberU64(); head != 0 {
+		t.Fatalf("damaged epoch partially imported: head #%d", head)
+	}
+}
+
+// Tests that epochs not extending the local chain are rejected.
+func TestHistoryImportGap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	blocks := makeHistoryBlocks(20)
+	source := newHistoryChain(t, rawdb.NewMemoryDatabase(), blocks)
+	defer source.Stop()
+
+	if err := ExportHistory(source, dir, 10, 20); err != nil {
+		t.Fatalf("failed to export history: %v", err)
+	}
+	target := newHistoryChain(t, rawdb.NewMemoryDatabase(), nil)
+	defer target.Stop()
+
+	if err := ImportHistory(target, dir, false); err == nil {
+		t.Fatalf("gapped epoch imported")
+	}
+}
+
+// Tests that seeding writes the blocks into the database without executing
+// them, keeping the recent blocks out of the ancient store.
+func TestHistorySeed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	blocks := makeHistoryBlocks(30)
+	source := newHistoryChain(t, rawdb.NewMemoryDatabase(), blocks)
+	defer source.Stop()
+
+	if err := ExportHistory(source, dir, 0, 30); err != nil {
+		t.Fatalf("failed to export history: %v", err)
+	}
+	files, err := era.ReadDir(dir, historyNetwork(source.Genesis().Hash()))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("failed to list era files: %v, %d files", err, len(files))
+	}
+	ancients, err := ioutil.TempDir("", "ancients")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(ancients)
+
+	// The whole chain is recent, none of it may be frozen
+	seed := func(ancientLimit uint64) (*core.BlockChain, highdb.Database) {
+		t.Helper()
+
+		db, err := rawdb.NewDatabaseWithFreezer(memorydb.New(), filepath.Join(ancients, new(big.Int).SetUint64(ancientLimit).String()), "")
+		if err != nil {
+			t.Fatalf("failed to create database: %v", err)
+		}
+		chain := newHistoryChain(t, db, nil)
+		if ancientLimit == 0 {
+			err = ImportHistory(chain, dir, true)
+		} else {
+			err = importEpoch(chain, files[0], true, ancientLimit)
+		}
+		if err != nil {
+			t.Fatalf("failed to seed history: %v", err)
+		}
+		if head := chain.CurrentFastBlock(); head.Hash() != blocks[29].Hash() {
+			t.Fatalf("fast head mismatch: have #%d, want #30", head.NumberU64())
+		}
+		if head := chain.CurrentBlock().NumberU64(); head != 0 {
+			t.Fatalf("seeded blocks executed: head #%d", head)
+		}
+		return chain, db
+	}
+	chain, db := seed(0)
+	if frozen, _ := db.Ancients(); frozen > 1 {
+		t.Errorf("recent blocks frozen: %d ancients", frozen)
+	}
+	for _, block := range blocks {
+		if !rawdb.HasBody(db, block.Hash(), block.NumberU64()) || rawdb.ReadReceiptsRLP(db, block.Hash(), block.NumberU64()) == nil {
+			t.Fatalf("block #%d: seeded data missing", block.NumberU64())
+		}
+	}
+	chain.Stop()
+	db.Close()
+
+	// Blocks up to the ancient limit should be frozen
+	chain, db = seed(10)
+	if frozen, _ := db.Ancients(); frozen != 11 {
+		t.Errorf("frozen block count mismatch: have %d, want 11", frozen)
+	}
+	chain.Stop()
+	db.Close()
+}
+
+// firstKey returns any key of a single entry map.
+func firstKey(files map[string]int64) string {
+	for name := range files {
+		return name
+	}
+	return ""
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/420integrated/go-highcoin/consensus"
 	"github.com/420integrated/go-highcoin/consensus/misc"
 	"github.com/420integrated/go-highcoin/core/state"
+	"github.com/420integrated/go-highcoin/core/tracing"
 	"github.com/420integrated/go-highcoin/core/types"
 	"github.com/420integrated/go-highcoin/params"
 	"github.com/420integrated/go-highcoin/rlp"
@@ -714,9 +715,9 @@ func AccumulateNewRewards(config *params.ChainConfig, state *state.StateDB, head
 			// Calculating "followers" rewards to be sent to the Cannasseur Network contract post Sativa Fork
 			sativaFollowerReward.Mul(r, sativaRewardDistFollower)
 			sativaFollowerReward.Div(sativaFollowerReward, rewardDivisor)
-		state.AddBalance(uncle.Coinbase, minerReward)
-		state.AddBalance(vetRewardAddress, sativaVetReward)
-		state.AddBalance(followerRewardAddress, sativaFollowerReward)
+		state.AddBalanceWithReason(uncle.Coinbase, minerReward, tracing.BalanceChangeReward)
+		state.AddBalanceWithReason(vetRewardAddress, sativaVetReward, tracing.BalanceChangeReward)
+		state.AddBalanceWithReason(followerRewardAddress, sativaFollowerReward, tracing.BalanceChangeReward)
 		r.Div(reward, big32)
 		reward.Add(reward, r)
 	}
@@ -731,9 +732,9 @@ func AccumulateNewRewards(config *params.ChainConfig, state *state.StateDB, head
 		        sativaFollowerReward.Mul(reward, sativaRewardDistFollower)
 		        sativaFollowerReward.Div(sativaFollowerReward, rewardDivisor)
 
-		state.AddBalance(vetRewardAddress, sativaVetReward)
-		state.AddBalance(followerRewardAddress, sativaFollowerReward)
-		state.AddBalance(header.Coinbase, minerReward)
+		state.AddBalanceWithReason(vetRewardAddress, sativaVetReward, tracing.BalanceChangeReward)
+		state.AddBalanceWithReason(followerRewardAddress, sativaFollowerReward, tracing.BalanceChangeReward)
+		state.AddBalanceWithReason(header.Coinbase, minerReward, tracing.BalanceChangeReward)
 			} else {
 
     	for _, uncle := range uncles {
@@ -751,10 +752,10 @@ func AccumulateNewRewards(config *params.ChainConfig, state *state.StateDB, head
 	                contractReward.Mul(r, cumulativeReward)
 	                contractReward.Div(contractReward, rewardDivisor)
 
-	        state.AddBalance(uncle.Coinbase, minerReward)
+	        state.AddBalanceWithReason(uncle.Coinbase, minerReward, tracing.BalanceChangeReward)
 	        contractRewardSplit.Div(contractReward, big.NewInt(2))
-	        state.AddBalance(vetRewardAddress, contractRewardSplit)
-	        state.AddBalance(followerRewardAddress, contractRewardSplit)
+	        state.AddBalanceWithReason(vetRewardAddress, contractRewardSplit, tracing.BalanceChangeReward)
+	        state.AddBalanceWithReason(followerRewardAddress, contractRewardSplit, tracing.BalanceChangeReward)
 	        r.Div(reward, big32)
 	        reward.Add(reward, r)
 	    }
@@ -768,10 +769,10 @@ func AccumulateNewRewards(config *params.ChainConfig, state *state.StateDB, head
 	                contractReward.Div(contractReward, rewardDivisor)
 
                 contractRewardSplit.Div(contractReward, big.NewInt(2))
-                state.AddBalance(vetRewardAddress, contractRewardSplit)
-                state.AddBalance(followerRewardAddress, contractRewardSplit)
+                state.AddBalanceWithReason(vetRewardAddress, contractRewardSplit, tracing.BalanceChangeReward)
+                state.AddBalanceWithReason(followerRewardAddress, contractRewardSplit, tracing.BalanceChangeReward)
                 if (header.Number.Cmp(indicaForkBlock) == 1) {
-         	state.AddBalance(header.Coinbase, minerReward)
+         	state.AddBalanceWithReason(header.Coinbase, minerReward, tracing.BalanceChangeReward)
         }
 	    //fmt.Println(state.GetBalance(header.Coinbase), state.GetBalance(devRewardAddress), state.GetBalance(followerRewardAddress))
 	}} else {
@@ -787,8 +788,8 @@ func AccumulateNewRewards(config *params.ChainConfig, state *state.StateDB, head
 	        contractReward.Mul(r, rewardDistVet)
 	        contractReward.Div(contractReward, rewardDivisor)
 
-	        state.AddBalance(uncle.Coinbase, minerReward)
-	        state.AddBalance(vetRewardAddress, contractReward)
+	        state.AddBalanceWithReason(uncle.Coinbase, minerReward, tracing.BalanceChangeReward)
+	        state.AddBalanceWithReason(vetRewardAddress, contractReward, tracing.BalanceChangeReward)
 	        r.Div(reward, big32)
 	        reward.Add(reward, r)
 	    }
@@ -799,8 +800,8 @@ func AccumulateNewRewards(config *params.ChainConfig, state *state.StateDB, head
 	        contractReward.Mul(reward, rewardDistVet)
 	        contractReward.Div(contractReward, rewardDivisor)
 
-	        state.AddBalance(vetRewardAddress, contractReward)
-	        state.AddBalance(header.Coinbase, minerReward)
+	        state.AddBalanceWithReason(vetRewardAddress, contractReward, tracing.BalanceChangeReward)
+	        state.AddBalanceWithReason(header.Coinbase, minerReward, tracing.BalanceChangeReward)
 	        // fmt.Println(state.GetBalance(header.Coinbase), state.GetBalance(vetRewardAddress))
 	}
 }
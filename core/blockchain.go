@@ -34,6 +34,7 @@ import (
 	"github.com/420integrated/go-highcoin/consensus"
 	"github.com/420integrated/go-highcoin/core/rawdb"
 	"github.com/420integrated/go-highcoin/core/state"
+	"github.com/420integrated/go-highcoin/core/state/history"
 	"github.com/420integrated/go-highcoin/core/state/snapshot"
 	"github.com/420integrated/go-highcoin/core/types"
 	"github.com/420integrated/go-highcoin/core/vm"
@@ -129,6 +130,8 @@ type CacheConfig struct {
 	TrieTimeLimit       time.Duration // Time limit after which to flush the current in-memory trie to disk
 	SnapshotLimit       int           // Memory allowance (MB) to use for caching snapshot entries in memory
 	Preimages           bool          // If to store preimage of trie key to the disk
+	StateHistory        string        // Directory of the persistent state history (empty = disabled)
+	HistoryRetain       uint64        // Number of recent blocks to retain bodies and receipts for (0 = entire chain)
 
 	SnapshotWait bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
 }
@@ -161,10 +164,11 @@ type BlockChain struct {
 	chainConfig *params.ChainConfig // Chain & network configuration
 	cacheConfig *CacheConfig        // Cache configuration for pruning
 
-	db     highdb.Database // Low level persistent database to store final content in
-	snaps  *snapshot.Tree // Snapshot tree for fast trie leaf access
-	triegc *prque.Prque   // Priority queue mapping block numbers to tries to gc
-	gcproc time.Duration  // Accumulates canonical block processing for trie dumping
+	db      highdb.Database // Low level persistent database to store final content in
+	snaps   *snapshot.Tree  // Snapshot tree for fast trie leaf access
+	history *history.Store  // Persistent reverse diff state history (nil = disabled)
+	triegc  *prque.Prque    // Priority queue mapping block numbers to tries to gc
+	gcproc  time.Duration   // Accumulates canonical block processing for trie dumping
 
 	// txLookupLimit is the maximum number of blocks from head whose tx indices
 	// are reserved:
@@ -374,6 +378,15 @@ func NewBlockChain(db highdb.Database, cacheConfig *CacheConfig, chainConfig *pa
 		}
 		bc.snaps, _ = snapshot.New(bc.db, bc.stateCache.TrieDB(), bc.cacheConfig.SnapshotLimit, head.Root(), !bc.cacheConfig.SnapshotWait, true, recover)
 	}
+	// Open the persistent state history if requested. The reverse diffs are
+	// assembled from the snapshot layers, so snapshots are required too.
+	if bc.cacheConfig.StateHistory != "" {
+		if bc.snaps == nil {
+			log.Warn("State history requires snapshots, disabling")
+		} else if bc.history, err = history.NewStore(bc.db, bc.cacheConfig.StateHistory, "highcoin/db/"); err != nil {
+			return nil, err
+		}
+	}
 	// Take ownership of this particular state
 	go bc.update()
 	if txLookupLimit != nil {
@@ -382,6 +395,10 @@ func NewBlockChain(db highdb.Database, cacheConfig *CacheConfig, chainConfig *pa
 		bc.wg.Add(1)
 		go bc.maintainTxIndex(txIndexBlock)
 	}
+	if bc.cacheConfig.HistoryRetain > 0 {
+		bc.wg.Add(1)
+		go bc.maintainHistory()
+	}
 	// If periodic cache journal is required, spin it up.
 	if bc.cacheConfig.TrieCleanRejournal > 0 {
 		if bc.cacheConfig.TrieCleanRejournal < time.Minute {
@@ -398,9 +415,41 @@ func NewBlockChain(db highdb.Database, cacheConfig *CacheConfig, chainConfig *pa
 	return bc, nil
 }
 
-// GetVMConfig returns the block chain VM config.
+// BlockTracer is a state tracer which can be attached to the chain through its
+// VM config, tracing the blocks live as they are imported.
+type BlockTracer interface {
+	vm.StateTracer
+
+	// CaptureBlockStart is called before a block is processed.
+	CaptureBlockStart(block *types.Block)
+
+	// CaptureBlockEnd is called after a block was processed and validated. The
+	// error is set if the block was rejected.
+	CaptureBlockEnd(err error)
+
+	// CaptureReorg is called when the canonical chain is reorganised. Both the
+	// dropped and the added blocks are ordered from their head down to the common
+	// ancestor, which is excluded.
+	CaptureReorg(dropped, added types.Blocks)
+}
+
+// GetVMConfig returns the block chain VM config. The live block tracer, if any,
+// is left out, as it must only observe the processing of the imported blocks.
 func (bc *BlockChain) GetVMConfig() *vm.Config {
-	return &bc.vmConfig
+	config := bc.vmConfig
+	if bc.blockTracer() != nil {
+		config.Debug, config.Tracer = false, nil
+	}
+	return &config
+}
+
+// blockTracer returns the live block tracer of the chain, or nil if none is set.
+func (bc *BlockChain) blockTracer() BlockTracer {
+	if !bc.vmConfig.Debug {
+		return nil
+	}
+	tracer, _ := bc.vmConfig.Tracer.(BlockTracer)
+	return tracer
 }
 
 // empty returns an indicator if the blockchain is empty.
@@ -621,6 +670,12 @@ func (bc *BlockChain) SetHeadBeyondRoot(head uint64, root common.Hash) (uint64,
 	bc.txLookupCache.Purge()
 	bc.futureBlocks.Purge()
 
+	// Drop the state history of the rewound blocks
+	if bc.history != nil {
+		if err := bc.history.Truncate(bc.CurrentBlock().NumberU64()); err != nil {
+			log.Error("Failed to truncate state history", "err", err)
+		}
+	}
 	return rootNumber, bc.loadLastState()
 }
 
@@ -691,6 +746,32 @@ func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
 	return state.New(root, bc.stateCache, bc.snaps)
 }
 
+// HistoricState returns a read-only state of a canonical block, assembled from
+// the persistent state history by rewinding the snapshot of the current head.
+// It can be used to serve historical state reads of blocks whose tries have
+// already been garbage collected, up to history.MaxRewind blocks below the head.
+func (bc *BlockChain) HistoricState(header *types.Header) (*state.StateDB, error) {
+	if bc.history == nil {
+		return nil, history.ErrHistoryUnavailable
+	}
+	if bc.GetCanonicalHash(header.Number.Uint64()) != header.Hash() {
+		return nil, fmt.Errorf("%w: non-canonical block #%d", history.ErrHistoryUnavailable, header.Number)
+	}
+	head := bc.CurrentBlock()
+	base := bc.snaps.Snapshot(head.Root())
+	if base == nil {
+		return nil, fmt.Errorf("%w: head snapshot missing", history.ErrHistoryUnavailable)
+	}
+	layer, err := bc.history.Rewind(base, head.NumberU64(), header.Number.Uint64())
+	if err != nil {
+		return nil, err
+	}
+	if layer.Root() != header.Root {
+		return nil, fmt.Errorf("%w: root mismatch, have %x, want %x", history.ErrHistoryUnavailable, layer.Root(), header.Root)
+	}
+	return state.NewHistoric(header.Root, bc.stateCache, layer)
+}
+
 // StateCache returns the caching database underpinning the blockchain instance.
 func (bc *BlockChain) StateCache() state.Database {
 	return bc.stateCache
@@ -844,6 +925,12 @@ func (bc *BlockChain) GetBodyRLP(hash common.Hash) rlp.RawValue {
 	return body
 }
 
+// HistoryPruned reports whether the body and receipts of the canonical block
+// with the given number might have been pruned from the ancient store.
+func (bc *BlockChain) HistoryPruned(number uint64) bool {
+	return number < rawdb.ReadChainHistoryTail(bc.db)
+}
+
 // HasBlock checks if a block is fully present in the database or not.
 func (bc *BlockChain) HasBlock(hash common.Hash, number uint64) bool {
 	if bc.blockCache.Contains(hash) {
@@ -1049,6 +1136,11 @@ func (bc *BlockChain) Stop() {
 		triedb := bc.stateCache.TrieDB()
 		triedb.SaveCache(bc.cacheConfig.TrieCleanJournal)
 	}
+	if bc.history != nil {
+		if err := bc.history.Close(); err != nil {
+			log.Error("Failed to close state history", "err", err)
+		}
+	}
 	log.Info("Blockchain stopped")
 }
 
@@ -1527,10 +1619,16 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 		log.Crit("Failed to write block into disk", "err", err)
 	}
 	// Commit all cached state changes into underlying memory database.
+	if bc.history != nil {
+		state.RecordHistory()
+	}
 	root, err := state.Commit(bc.chainConfig.IsEIP158(block.Number()))
 	if err != nil {
 		return NonStatTy, err
 	}
+	if bc.history != nil {
+		bc.writeHistory(block, state)
+	}
 	triedb := bc.stateCache.TrieDB()
 
 	// If we're running an archive node, always flush
@@ -1617,6 +1715,12 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	// Set new head.
 	if status == CanonStatTy {
 		bc.writeHeadBlock(block)
+
+		if bc.history != nil {
+			if err := bc.history.Freeze(block.NumberU64()); err != nil {
+				log.Error("Failed to freeze state history", "number", block.Number(), "err", err)
+			}
+		}
 	}
 	bc.futureBlocks.Remove(block.Hash())
 
@@ -1639,6 +1743,23 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	return status, nil
 }
 
+// writeHistory stores the reverse state diff of a freshly committed block. It
+// doesn't need to be canonical yet, only the canonical diffs are frozen later.
+func (bc *BlockChain) writeHistory(block *types.Block, state *state.StateDB) {
+	diff, err := state.History()
+	switch {
+	case err != nil:
+		log.Warn("Failed to assemble state history", "number", block.Number(), "hash", block.Hash(), "err", err)
+	case diff == nil:
+		log.Debug("State history unavailable without snapshot", "number", block.Number(), "hash", block.Hash())
+	default:
+		diff.Number = block.NumberU64()
+		if err := bc.history.Write(block.Hash(), diff); err != nil {
+			log.Error("Failed to write state history", "number", block.Number(), "hash", block.Hash(), "err", err)
+		}
+	}
+}
+
 // addFutureBlock checks if the block is within the max allowed window to get
 // accepted for future processing, and returns an error if the block is too far
 // ahead and was not added.
@@ -1712,6 +1833,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 	var (
 		stats     = insertStats{startTime: mclock.Now()}
 		lastCanon *types.Block
+		tracer    = bc.blockTracer()
 	)
 	// Fire a single chain head event if we've progressed the chain
 	defer func() {
@@ -1899,8 +2021,14 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 		}
 		// Process block using the parent state as reference point
 		substart := time.Now()
+		if tracer != nil {
+			tracer.CaptureBlockStart(block)
+		}
 		receipts, logs, usedSmoke, err := bc.processor.Process(block, statedb, bc.vmConfig)
 		if err != nil {
+			if tracer != nil {
+				tracer.CaptureBlockEnd(err)
+			}
 			bc.reportBlock(block, receipts, err)
 			atomic.StoreUint32(&followupInterrupt, 1)
 			return it.index, err
@@ -1921,10 +2049,16 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 		// Validate the state using the default validator
 		substart = time.Now()
 		if err := bc.validator.ValidateState(block, statedb, receipts, usedSmoke); err != nil {
+			if tracer != nil {
+				tracer.CaptureBlockEnd(err)
+			}
 			bc.reportBlock(block, receipts, err)
 			atomic.StoreUint32(&followupInterrupt, 1)
 			return it.index, err
 		}
+		if tracer != nil {
+			tracer.CaptureBlockEnd(nil)
+		}
 		proctime := time.Since(start)
 
 		// Update the metrics touched during block validation
@@ -2239,6 +2373,10 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		blockReorgAddMeter.Mark(int64(len(newChain)))
 		blockReorgDropMeter.Mark(int64(len(oldChain)))
 		blockReorgMeter.Mark(1)
+
+		if tracer := bc.blockTracer(); tracer != nil {
+			tracer.CaptureReorg(oldChain, newChain)
+		}
 	} else {
 		log.Error("Impossible reorg, please file an issue", "oldnum", oldBlock.Number(), "oldhash", oldBlock.Hash(), "newnum", newBlock.Number(), "newhash", newBlock.Hash())
 	}
@@ -2389,6 +2527,61 @@ func (bc *BlockChain) maintainTxIndex(ancients uint64) {
 	}
 }
 
+// maintainHistory is responsible for pruning the bodies and receipts of the
+// ancient blocks older than the configured retention as the chain progresses.
+func (bc *BlockChain) maintainHistory() {
+	defer bc.wg.Done()
+
+	var (
+		done   chan struct{}                  // Non-nil if background pruning routine is active.
+		headCh = make(chan ChainHeadEvent, 1) // Buffered to avoid locking up the event feed
+	)
+	sub := bc.SubscribeChainHeadEvent(headCh)
+	if sub == nil {
+		return
+	}
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case head := <-headCh:
+			if done == nil {
+				done = make(chan struct{})
+				go func(head uint64) {
+					defer func() { done <- struct{}{} }()
+					bc.pruneHistory(head)
+				}(head.Block.NumberU64())
+			}
+		case <-done:
+			done = nil
+		case <-bc.quit:
+			if done != nil {
+				log.Info("Waiting background history pruner to exit")
+				<-done
+			}
+			return
+		}
+	}
+}
+
+// pruneHistory discards the bodies and receipts of the ancient blocks older than
+// the configured retention below the given head.
+func (bc *BlockChain) pruneHistory(head uint64) {
+	retain := bc.cacheConfig.HistoryRetain
+	if head <= retain {
+		return
+	}
+	old := rawdb.ReadChainHistoryTail(bc.db)
+	tail, err := rawdb.PruneChainHistory(bc.db, head-retain)
+	if err != nil {
+		log.Error("Failed to prune chain history", "err", err)
+		return
+	}
+	if tail != old {
+		log.Info("Pruned chain history", "head", head, "retain", retain, "tail", tail)
+	}
+}
+
 // reportBlock logs a bad block error.
 func (bc *BlockChain) reportBlock(block *types.Block, receipts types.Receipts, err error) {
 	rawdb.WriteBadBlock(bc.db, block)
@@ -0,0 +1,135 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/consensus/ethash"
+	"github.com/420integrated/go-highcoin/core/rawdb"
+	"github.com/420integrated/go-highcoin/core/types"
+	"github.com/420integrated/go-highcoin/core/vm"
+	"github.com/420integrated/go-highcoin/crypto"
+	"github.com/420integrated/go-highcoin/params"
+)
+
+// Tests that historical states are served from the state history after their
+// tries were garbage collected, and that they match the states of an archive
+// node.
+func TestHistoricState(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "statehistory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	var (
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		bank   = crypto.PubkeyToAddress(key.PublicKey)
+		signer = types.LatestSigner(params.TestChainConfig)
+
+		// The contract stores the block number in slot zero and in the slot of
+		// the block number, or self destructs if called with any call data.
+		contract = common.HexToAddress("0xc0de")
+		code     = common.FromHex("36600c5743600055434355005b33ff")
+
+		accounts = []common.Address{bank, contract, {0x01}, {0x02}, {0x03}}
+		gspec    = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				bank:     {Balance: big.NewInt(params.Highcoin)},
+				contract: {Balance: new(big.Int), Code: code},
+			},
+		}
+		gendb     = rawdb.NewMemoryDatabase()
+		archivedb = rawdb.NewMemoryDatabase()
+		prunedb   = rawdb.NewMemoryDatabase()
+		genesis   = gspec.MustCommit(gendb)
+	)
+	gspec.MustCommit(archivedb)
+	gspec.MustCommit(prunedb)
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 2*TriesInMemory, func(i int, block *BlockGen) {
+		transfer, _ := types.SignTx(types.NewTransaction(block.TxNonce(bank), accounts[2+i%3], big.NewInt(1000), params.TxSmoke, nil, nil), signer, key)
+		block.AddTx(transfer)
+
+		var data []byte
+		if i == TriesInMemory/2 {
+			data = []byte{0x01}
+		}
+		call, _ := types.SignTx(types.NewTransaction(block.TxNonce(bank), contract, big.NewInt(1), 100000, nil, data), signer, key)
+		block.AddTx(call)
+	})
+	archive, _ := NewBlockChain(archivedb, &CacheConfig{TrieDirtyDisabled: true}, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer archive.Stop()
+
+	if _, err := archive.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import archive chain: %v", err)
+	}
+	config := &CacheConfig{
+		TrieCleanLimit: 256,
+		TrieDirtyLimit: 256,
+		TrieTimeLimit:  5 * time.Minute,
+		SnapshotLimit:  256,
+		SnapshotWait:   true,
+		StateHistory:   datadir,
+	}
+	chain, _ := NewBlockChain(prunedb, config, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import pruned chain: %v", err)
+	}
+	if _, err := chain.StateAt(blocks[0].Root()); err == nil {
+		t.Fatalf("old state not garbage collected")
+	}
+	for _, block := range append([]*types.Block{genesis}, blocks...) {
+		want, err := archive.StateAt(block.Root())
+		if err != nil {
+			t.Fatalf("block #%d: failed to retrieve archive state: %v", block.NumberU64(), err)
+		}
+		have, err := chain.HistoricState(block.Header())
+		if err != nil {
+			t.Fatalf("block #%d: failed to retrieve historic state: %v", block.NumberU64(), err)
+		}
+		for _, account := range accounts {
+			if have, want := have.GetBalance(account), want.GetBalance(account); have.Cmp(want) != 0 {
+				t.Errorf("block #%d, account %x: balance mismatch: have %v, want %v", block.NumberU64(), account, have, want)
+			}
+			if have, want := have.GetNonce(account), want.GetNonce(account); have != want {
+				t.Errorf("block #%d, account %x: nonce mismatch: have %v, want %v", block.NumberU64(), account, have, want)
+			}
+			if have, want := have.GetCodeHash(account), want.GetCodeHash(account); have != want {
+				t.Errorf("block #%d, account %x: code hash mismatch: have %x, want %x", block.NumberU64(), account, have, want)
+			}
+		}
+		for _, slot := range []uint64{0, block.NumberU64() - 1, block.NumberU64(), TriesInMemory / 4} {
+			key := common.BigToHash(new(big.Int).SetUint64(slot))
+			if have, want := have.GetState(contract, key), want.GetState(contract, key); have != want {
+				t.Errorf("block #%d, slot %d: value mismatch: have %x, want %x", block.NumberU64(), slot, have, want)
+			}
+		}
+		if _, err := have.GetProof(bank); err == nil {
+			t.Errorf("block #%d: proof generated from historic state", block.NumberU64())
+		}
+	}
+}
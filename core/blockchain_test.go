@@ -17,6 +17,7 @@
 package core
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -3108,3 +3109,228 @@ func TestEIP2718Transition(t *testing.T) {
 
 	}
 }
+
+// TestEIP2929CallVariants tests the smoke charged by the EIP-2929 call variants
+// for cold and warm targets, forwarding all available smoke so the cold surcharge
+// affects the smoke passed to the callee. The transaction with a smoke limit of
+// one less than needed is checked to run out of smoke.
+func TestEIP2929CallVariants(t *testing.T) {
+	var (
+		aa = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+
+		// Generate a canonical chain to act as the main dataset
+		engine = ethash.NewFaker()
+		db     = rawdb.NewMemoryDatabase()
+
+		// A sender who makes transactions, has some funds
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000)
+	)
+	// The address 0xAAAA calls four empty accounts twice with every call variant,
+	// first cold and then warm
+	var (
+		code     []byte
+		expected = params.TxSmoke
+	)
+	for i, op := range []vm.OpCode{vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL} {
+		for access := 0; access < 2; access++ {
+			pushes := 4 // retSize, retOffset, argsSize, argsOffset
+			code = append(code, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0)
+			if op == vm.CALL || op == vm.CALLCODE {
+				code = append(code, byte(vm.PUSH1), 0) // value
+				pushes++
+			}
+			code = append(code, byte(vm.PUSH2), 0xbb, byte(i), byte(vm.SMOKE), byte(op), byte(vm.POP))
+			pushes++
+
+			expected += uint64(pushes)*vm.SmokeFastestStep + vm.SmokeQuickStep + vm.WarmStorageReadCostEIP2929 + vm.SmokeQuickStep
+			if access == 0 {
+				expected += vm.ColdAccountAccessCostEIP2929 - vm.WarmStorageReadCostEIP2929
+			}
+		}
+	}
+	gspec := &Genesis{
+		Config: params.YoloV3ChainConfig,
+		Alloc: GenesisAlloc{
+			address: {Balance: funds},
+			aa:      {Code: code, Balance: big.NewInt(0)},
+		},
+	}
+	genesis := gspec.MustCommit(db)
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, db, 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{1})
+
+		signer := types.LatestSigner(gspec.Config)
+		for _, limit := range []uint64{expected, expected - 1} {
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(address), aa, big.NewInt(0), limit, big.NewInt(1), nil), signer, key)
+			b.AddTx(tx)
+		}
+	})
+	// Import the canonical chain
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+
+	chain, err := NewBlockChain(diskdb, nil, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	receipts := chain.GetReceiptsByHash(blocks[0].Hash())
+	if len(receipts) != 2 {
+		t.Fatalf("receipt count mismatch: have %d, want 2", len(receipts))
+	}
+	if receipts[0].Status != types.ReceiptStatusSuccessful || receipts[0].SmokeUsed != expected {
+		t.Errorf("exact smoke transaction mismatch: status %d, smoke used %d, want %d", receipts[0].Status, receipts[0].SmokeUsed, expected)
+	}
+	if receipts[1].Status != types.ReceiptStatusFailed || receipts[1].SmokeUsed != expected-1 {
+		t.Errorf("insufficient smoke transaction mismatch: status %d, smoke used %d, want %d", receipts[1].Status, receipts[1].SmokeUsed, expected-1)
+	}
+}
+
+// Tests that the chain history is pruned down to the configured retention, while
+// the retained blocks and the genesis stay fully available.
+func TestHistoryRetain(t *testing.T) {
+	var (
+		gendb   = rawdb.NewMemoryDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: funds}}}
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.LatestSigner(gspec.Config)
+	)
+	height := uint64(128)
+	blocks, receipts := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, int(height), func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x00}, big.NewInt(1000), params.TxSmoke, nil, nil), signer, key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	frdir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temp freezer dir: %v", err)
+	}
+	defer os.RemoveAll(frdir)
+	ancientDb, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), frdir, "")
+	if err != nil {
+		t.Fatalf("failed to create temp freezer db: %v", err)
+	}
+	defer ancientDb.Close()
+	gspec.MustCommit(ancientDb)
+
+	cacheConfig := *defaultCacheConfig
+	cacheConfig.HistoryRetain = 32
+
+	chain, err := NewBlockChain(ancientDb, &cacheConfig, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if n, err := chain.InsertHeaderChain(headers, 0); err != nil {
+		t.Fatalf("failed to insert header %d: %v", n, err)
+	}
+	if n, err := chain.InsertReceiptChain(blocks, receipts, height); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	chain.pruneHistory(height)
+
+	// The ancient data is pruned in whole files, so nothing above the retention
+	// may be gone, but the exact tail is up to the file layout
+	tail := rawdb.ReadChainHistoryTail(ancientDb)
+	if tail > height-cacheConfig.HistoryRetain {
+		t.Fatalf("history tail above retention: have %d, want at most %d", tail, height-cacheConfig.HistoryRetain)
+	}
+	for _, number := range []uint64{0, tail, height - cacheConfig.HistoryRetain, height} {
+		hash := rawdb.ReadCanonicalHash(ancientDb, number)
+		if number > 0 && number < tail {
+			continue
+		}
+		if body := rawdb.ReadBody(ancientDb, hash, number); body == nil {
+			t.Errorf("block #%d: body missing", number)
+		}
+		if receipts := rawdb.ReadRawReceipts(ancientDb, hash, number); receipts == nil {
+			t.Errorf("block #%d: receipts missing", number)
+		}
+	}
+}
+
+// TestEOFTransition tests contract creations across the EIP-3541 and EOF fork
+// boundaries: code starting with 0xEF can be deployed by legacy init code until
+// EIP-3541, while EOF containers can only be deployed after the EOF fork.
+func TestEOFTransition(t *testing.T) {
+	var (
+		engine = ethash.NewFaker()
+		db     = rawdb.NewMemoryDatabase()
+
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		config  = *params.TestChainConfig
+		gspec   = &Genesis{
+			Config: &config,
+			Alloc:  GenesisAlloc{address: {Balance: big.NewInt(1000000000000000)}},
+		}
+		// Legacy init code deploying 0xEF, and a container deploying a container
+		legacy    = common.FromHex("0x61000180600c6000396000f3ef")
+		container = common.FromHex("0xef000101000c020008006100088060166000396000f3ef00010100010000")
+	)
+	config.EIP3541Block, config.EOFBlock = big.NewInt(2), big.NewInt(3)
+	genesis := gspec.MustCommit(db)
+
+	signer := types.LatestSigner(gspec.Config)
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, db, 3, func(i int, b *BlockGen) {
+		for _, code := range [][]byte{legacy, container} {
+			tx, _ := types.SignTx(types.NewContractCreation(b.TxNonce(address), new(big.Int), 100000, big.NewInt(1), code), signer, key)
+			b.AddTx(tx)
+		}
+	})
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+
+	chain, err := NewBlockChain(diskdb, nil, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	statedb, _ := chain.State()
+
+	tests := []struct {
+		number    uint64
+		legacy    []byte // Code deployed by the legacy init code, nil if failed
+		container []byte // Code deployed by the container, nil if failed
+	}{
+		{number: 1, legacy: []byte{0xef}}, // before EIP-3541
+		{number: 2},                       // EIP-3541, before EOF
+		{number: 3, container: common.FromHex("0xef00010100010000")}, // EOF
+	}
+	for _, tt := range tests {
+		var (
+			block    = chain.GetBlockByNumber(tt.number)
+			receipts = chain.GetReceiptsByHash(block.Hash())
+		)
+		for i, want := range [][]byte{tt.legacy, tt.container} {
+			addr := crypto.CreateAddress(address, block.Transactions()[i].Nonce())
+			if code := statedb.GetCode(addr); !bytes.Equal(code, want) {
+				t.Errorf("block %d, tx %d: deployed code mismatch: have %x, want %x", tt.number, i, code, want)
+			}
+			if success := receipts[i].Status == types.ReceiptStatusSuccessful; success != (want != nil) {
+				t.Errorf("block %d, tx %d: status mismatch: have success %v, want %v", tt.number, i, success, want != nil)
+			}
+		}
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/420integrated/go-highcoin/common"
 	"github.com/420integrated/go-highcoin/consensus"
 	"github.com/420integrated/go-highcoin/consensus/misc"
+	"github.com/420integrated/go-highcoin/core/rawdb"
 	"github.com/420integrated/go-highcoin/core/state"
 	"github.com/420integrated/go-highcoin/core/types"
 	"github.com/420integrated/go-highcoin/core/vm"
@@ -190,7 +191,7 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 		config = params.TestChainConfig
 	}
 	blocks, receipts := make(types.Blocks, n), make([]types.Receipts, n)
-	chainreader := &fakeChainReader{config: config}
+	chainreader := &fakeChainReader{config: config, db: db}
 	genblock := func(i int, parent *types.Block, statedb *state.StateDB) (*types.Block, types.Receipts) {
 		b := &BlockGen{i: i, chain: blocks, parent: parent, statedb: statedb, config: config, engine: engine}
 		b.header = makeHeader(chainreader, parent, statedb, b.engine)
@@ -282,8 +283,12 @@ func makeBlockChain(parent *types.Block, n int, engine consensus.Engine, db high
 	return blocks
 }
 
+// fakeChainReader is a chain reader serving only the configuration and the
+// canonical headers already written into the database of the generator, which
+// the consensus engine needs for the genesis header.
 type fakeChainReader struct {
 	config *params.ChainConfig
+	db     highdb.Database
 }
 
 // Config returns the chain configuration.
@@ -292,7 +297,14 @@ func (cr *fakeChainReader) Config() *params.ChainConfig {
 }
 
 func (cr *fakeChainReader) CurrentHeader() *types.Header                            { return nil }
-func (cr *fakeChainReader) GetHeaderByNumber(number uint64) *types.Header           { return nil }
 func (cr *fakeChainReader) GetHeaderByHash(hash common.Hash) *types.Header          { return nil }
 func (cr *fakeChainReader) GetHeader(hash common.Hash, number uint64) *types.Header { return nil }
 func (cr *fakeChainReader) GetBlock(hash common.Hash, number uint64) *types.Block   { return nil }
+
+// GetHeaderByNumber retrieves a canonical header from the database, if any.
+func (cr *fakeChainReader) GetHeaderByNumber(number uint64) *types.Header {
+	if cr.db == nil {
+		return nil
+	}
+	return rawdb.ReadHeader(cr.db, rawdb.ReadCanonicalHash(cr.db, number), number)
+}
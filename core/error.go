@@ -31,6 +31,10 @@ var (
 
 	// ErrNoGenesis is returned when there is no Genesis Block.
 	ErrNoGenesis = errors.New("genesis not found in chain")
+
+	// ErrHistoryPruned is returned if the body or the receipts of a block are
+	// requested which were pruned from the ancient store.
+	ErrHistoryPruned = errors.New("pruned history unavailable")
 )
 
 // List of evm-call-message pre-checking errors. All state transition messages will
@@ -24,6 +24,29 @@ import (
 // NewTxsEvent is posted when a batch of transactions enter the transaction pool.
 type NewTxsEvent struct{ Txs []*types.Transaction }
 
+// TxDropReason is the reason for the transaction pool dropping a transaction.
+// Transactions removed because they were included in a new chain head are not
+// dropped.
+type TxDropReason string
+
+const (
+	TxDropReplaced        TxDropReason = "replaced"        // Replaced by a transaction with the same nonce paying more
+	TxDropReplacedOnChain TxDropReason = "replacedOnChain" // Nonce used up by a different transaction included in a block
+	TxDropUnderpriced     TxDropReason = "underpriced"     // Evicted by better paying transactions or the minimum price
+	TxDropUnpayable       TxDropReason = "unpayable"       // Sender can't pay for it any more or it exceeds the block smoke limit
+	TxDropPendingLimit    TxDropReason = "pendingLimit"    // Evicted to keep the pending transactions within the global limit
+	TxDropQueueLimit      TxDropReason = "queueLimit"      // Evicted to keep the queued transactions within the account or global limits
+	TxDropExpired         TxDropReason = "expired"         // Queued for longer than the configured lifetime
+	TxDropPolicy          TxDropReason = "policy"          // Not admitted by the reloaded admission policy
+)
+
+// DropTxsEvent is posted when a batch of transactions is dropped from the
+// transaction pool for the same reason.
+type DropTxsEvent struct {
+	Hashes []common.Hash
+	Reason TxDropReason
+}
+
 // NewMinedBlockEvent is posted when a block has been imported.
 type NewMinedBlockEvent struct{ Block *types.Block }
 
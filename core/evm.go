@@ -21,6 +21,7 @@ import (
 
 	"github.com/420integrated/go-highcoin/common"
 	"github.com/420integrated/go-highcoin/consensus"
+	"github.com/420integrated/go-highcoin/core/tracing"
 	"github.com/420integrated/go-highcoin/core/types"
 	"github.com/420integrated/go-highcoin/core/vm"
 )
@@ -106,6 +107,6 @@ func CanTransfer(db vm.StateDB, addr common.Address, amount *big.Int) bool {
 
 // Transfer subtracts amount from sender and adds amount to recipient using the given Db
 func Transfer(db vm.StateDB, sender, recipient common.Address, amount *big.Int) {
-	db.SubBalance(sender, amount)
-	db.AddBalance(recipient, amount)
+	db.SubBalanceWithReason(sender, amount, tracing.BalanceChangeTransfer)
+	db.AddBalanceWithReason(recipient, amount, tracing.BalanceChangeTransfer)
 }
@@ -201,6 +201,9 @@ func SetupGenesisBlock(db highdb.Database, genesis *Genesis) (*params.ChainConfi
 	if err := newcfg.CheckConfigForkOrder(); err != nil {
 		return newcfg, common.Hash{}, err
 	}
+	if err := genesis.checkEOFAlloc(newcfg); err != nil {
+		return newcfg, common.Hash{}, err
+	}
 	storedcfg := rawdb.ReadChainConfig(db, stored)
 	if storedcfg == nil {
 		log.Warn("Found genesis block without chain config")
@@ -227,6 +230,21 @@ func SetupGenesisBlock(db highdb.Database, genesis *Genesis) (*params.ChainConfi
 	return newcfg, stored, nil
 }
 
+// checkEOFAlloc ensures that no genesis account holds legacy code starting with
+// 0xEF if the chain enables EIP-3541 after genesis or schedules the EOF fork: such
+// code is reserved for EOF containers, and would change meaning once EOF is active.
+func (g *Genesis) checkEOFAlloc(config *params.ChainConfig) error {
+	if g == nil || config.EIP3541Block == nil || config.IsEOF(common.Big0) {
+		return nil
+	}
+	for addr, account := range g.Alloc {
+		if len(account.Code) > 0 && account.Code[0] == 0xef {
+			return fmt.Errorf("genesis account %x has code starting with 0xEF, reserved for EOF by EIP-3541", addr)
+		}
+	}
+	return nil
+}
+
 func (g *Genesis) configOrDefault(ghash common.Hash) *params.ChainConfig {
 	switch {
 	case g != nil:
@@ -301,6 +319,9 @@ func (g *Genesis) Commit(db highdb.Database) (*types.Block, error) {
 	if err := config.CheckConfigForkOrder(); err != nil {
 		return nil, err
 	}
+	if err := g.checkEOFAlloc(config); err != nil {
+		return nil, err
+	}
 	rawdb.WriteTd(db, block.Hash(), block.NumberU64(), g.Difficulty)
 	rawdb.WriteBlock(db, block)
 	rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), nil)
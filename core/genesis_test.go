@@ -162,3 +162,40 @@ func TestSetupGenesis(t *testing.T) {
 		}
 	}
 }
+
+// Tests that genesis accounts with code starting with 0xEF are rejected on chains
+// enabling EIP-3541 after genesis, as the code would change meaning under EOF.
+func TestGenesisEOFAlloc(t *testing.T) {
+	eofConfig := func(eip3541, eof *big.Int) *params.ChainConfig {
+		config := *params.TestChainConfig
+		config.EIP3541Block, config.EOFBlock = eip3541, eof
+		return &config
+	}
+	alloc := GenesisAlloc{common.HexToAddress("0xef"): {Code: common.FromHex("0xef00010100010000"), Balance: new(big.Int)}}
+
+	tests := []struct {
+		config *params.ChainConfig
+		fail   bool
+	}{
+		{config: eofConfig(nil, nil)},
+		{config: eofConfig(big.NewInt(0), big.NewInt(0))},
+		{config: eofConfig(big.NewInt(0), nil), fail: true},
+		{config: eofConfig(big.NewInt(5), nil), fail: true},
+		{config: eofConfig(big.NewInt(5), big.NewInt(10)), fail: true},
+	}
+	for i, tt := range tests {
+		genesis := &Genesis{Config: tt.config, Alloc: alloc}
+		if _, err := genesis.Commit(rawdb.NewMemoryDatabase()); (err != nil) != tt.fail {
+			t.Errorf("test %d: commit mismatch: have %v, want failure %v", i, err, tt.fail)
+		}
+	}
+	// Enabling the forks on an existing chain is rejected too
+	db := rawdb.NewMemoryDatabase()
+	genesis := &Genesis{Config: eofConfig(nil, nil), Alloc: alloc}
+	genesis.MustCommit(db)
+
+	genesis.Config = eofConfig(big.NewInt(5), big.NewInt(10))
+	if _, _, err := SetupGenesisBlock(db, genesis); err == nil {
+		t.Errorf("EOF enabled on a chain with 0xEF genesis code")
+	}
+}
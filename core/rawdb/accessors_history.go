@@ -0,0 +1,84 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/highdb"
+	"github.com/420integrated/go-highcoin/log"
+)
+
+// ReadStateHistory retrieves the reverse state diff of a recent block from the
+// key-value store.
+func ReadStateHistory(db highdb.KeyValueReader, number uint64, hash common.Hash) []byte {
+	data, _ := db.Get(stateHistoryKey(number, hash))
+	return data
+}
+
+// WriteStateHistory stores the reverse state diff of a recent block into the
+// key-value store.
+func WriteStateHistory(db highdb.KeyValueWriter, number uint64, hash common.Hash, blob []byte) {
+	if err := db.Put(stateHistoryKey(number, hash), blob); err != nil {
+		log.Crit("Failed to store state history", "err", err)
+	}
+}
+
+// DeleteStateHistory removes the reverse state diff of a block from the
+// key-value store.
+func DeleteStateHistory(db highdb.KeyValueWriter, number uint64, hash common.Hash) {
+	if err := db.Delete(stateHistoryKey(number, hash)); err != nil {
+		log.Crit("Failed to delete state history", "err", err)
+	}
+}
+
+// ReadStateHistoryHashes retrieves the hashes of all the blocks at a certain
+// height whose reverse state diffs are stored in the key-value store.
+func ReadStateHistoryHashes(db highdb.Iteratee, number uint64) []common.Hash {
+	prefix := append(append([]byte{}, stateHistoryPrefix...), encodeBlockNumber(number)...)
+
+	hashes := make([]common.Hash, 0, 1)
+	it := db.NewIterator(prefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		if key := it.Key(); len(key) == len(prefix)+common.HashLength {
+			hashes = append(hashes, common.BytesToHash(key[len(key)-common.HashLength:]))
+		}
+	}
+	return hashes
+}
+
+// ReadStateHistoryOffset retrieves the number of the first block whose reverse
+// state diff is stored in the state history freezer.
+func ReadStateHistoryOffset(db highdb.KeyValueReader) *uint64 {
+	data, _ := db.Get(stateHistoryOffsetKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteStateHistoryOffset stores the number of the first block whose reverse
+// state diff is stored in the state history freezer.
+func WriteStateHistoryOffset(db highdb.KeyValueWriter, number uint64) {
+	if err := db.Put(stateHistoryOffsetKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store state history offset", "err", err)
+	}
+}
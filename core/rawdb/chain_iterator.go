@@ -190,6 +190,10 @@ func iterateTransactions(db highdb.Database, from uint64, to uint64, reverse boo
 // There is a passed channel, the whole procedure will be interrupted if any
 // signal received.
 func indexTransactions(db highdb.Database, from uint64, to uint64, interrupt chan struct{}, hook func(uint64) bool) {
+	// Bodies pruned from the ancient store can't be indexed anymore
+	if tail := ReadChainHistoryTail(db); from < tail {
+		from = tail
+	}
 	// short circuit for invalid range
 	if from >= to {
 		return
@@ -285,6 +289,15 @@ func unindexTransactions(db highdb.Database, from uint64, to uint64, interrupt c
 	if from >= to {
 		return
 	}
+	// Bodies pruned from the ancient store can't be iterated anymore, leave the
+	// indices of their transactions dangling
+	if tail := ReadChainHistoryTail(db); from < tail {
+		if tail >= to {
+			WriteTxIndexTail(db, to)
+			return
+		}
+		from = tail
+	}
 	var (
 		hashesCh = iterateTransactions(db, from, to, false, interrupt)
 		batch    = db.NewBatch()
@@ -0,0 +1,78 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+
+	"github.com/420integrated/go-highcoin/highdb"
+	"github.com/420integrated/go-highcoin/log"
+)
+
+// prunableTables are the freezer tables whose tail may be deleted to prune the
+// chain history. Headers, hashes and difficulties are always retained.
+var prunableTables = []string{freezerBodiesTable, freezerReceiptTable}
+
+// ReadChainHistoryTail returns the number of the first block whose body and
+// receipts are both still available. Blocks below it might have been pruned from
+// the ancient store.
+func ReadChainHistoryTail(db highdb.AncientReader) uint64 {
+	var tail uint64
+	for _, kind := range prunableTables {
+		if n, err := db.AncientTail(kind); err == nil && n > tail {
+			tail = n
+		}
+	}
+	return tail
+}
+
+// PruneChainHistory discards the bodies and receipts of the ancient blocks below
+// the given number, keeping their headers and hashes. The ancient data is deleted
+// in whole files, so some blocks below the threshold may be retained. The new
+// history tail is returned.
+//
+// The genesis block is moved into the key-value store before being pruned, since
+// it's needed to initialize the chain.
+func PruneChainHistory(db highdb.Database, number uint64) (uint64, error) {
+	frozen, err := db.Ancients()
+	if err != nil {
+		return 0, err
+	}
+	if number > frozen {
+		number = frozen
+	}
+	if number == 0 {
+		return ReadChainHistoryTail(db), nil
+	}
+	if ReadChainHistoryTail(db) == 0 {
+		hash := ReadCanonicalHash(db, 0)
+		body, receipts := ReadBodyRLP(db, hash, 0), ReadReceiptsRLP(db, hash, 0)
+		if body == nil || receipts == nil {
+			return 0, errors.New("genesis block missing")
+		}
+		WriteBodyRLP(db, hash, 0, body)
+		if err := db.Put(blockReceiptsKey(0, hash), receipts); err != nil {
+			log.Crit("Failed to store genesis receipts", "err", err)
+		}
+	}
+	for _, kind := range prunableTables {
+		if err := db.TruncateAncientTail(kind, number); err != nil {
+			return 0, err
+		}
+	}
+	return ReadChainHistoryTail(db), nil
+}
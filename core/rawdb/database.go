@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync/atomic"
 	"time"
 
@@ -30,6 +31,7 @@ import (
 	"github.com/420integrated/go-highcoin/highdb/memorydb"
 	"github.com/420integrated/go-highcoin/log"
 	"github.com/olekukonko/tablewriter"
+	"github.com/syndtr/goleveldb/leveldb/opt"
 )
 
 // freezerdb is a database wrapper that enabled freezer data retrievals.
@@ -95,6 +97,11 @@ func (db *nofreezedb) AncientSize(kind string) (uint64, error) {
 	return 0, errNotSupported
 }
 
+// AncientTail returns an error as we don't have a backing chain freezer.
+func (db *nofreezedb) AncientTail(kind string) (uint64, error) {
+	return 0, errNotSupported
+}
+
 // AppendAncient returns an error as we don't have a backing chain freezer.
 func (db *nofreezedb) AppendAncient(number uint64, hash, header, body, receipts, td []byte) error {
 	return errNotSupported
@@ -105,6 +112,11 @@ func (db *nofreezedb) TruncateAncients(items uint64) error {
 	return errNotSupported
 }
 
+// TruncateAncientTail returns an error as we don't have a backing chain freezer.
+func (db *nofreezedb) TruncateAncientTail(kind string, items uint64) error {
+	return errNotSupported
+}
+
 // Sync returns an error as we don't have a backing chain freezer.
 func (db *nofreezedb) Sync() error {
 	return errNotSupported
@@ -238,6 +250,89 @@ func NewLevelDBDatabaseWithFreezer(file string, cache int, handles int, freezer
 	return frdb, nil
 }
 
+const (
+	// DBLeveldb is the identifier of the LevelDB key-value store engine.
+	DBLeveldb = "leveldb"
+
+	// DBPebble is the identifier of the Pebble key-value store engine.
+	DBPebble = "pebble"
+)
+
+// PreexistingDatabase checks the given directory for an existing key-value store
+// and returns its engine, or an empty string if there is none.
+func PreexistingDatabase(path string) string {
+	if _, err := os.Stat(filepath.Join(path, "CURRENT")); err != nil {
+		return "" // No pre-existing db
+	}
+	// Both engines keep a CURRENT file, but only pebble writes OPTIONS files
+	if matches, _ := filepath.Glob(filepath.Join(path, "OPTIONS*")); len(matches) > 0 {
+		return DBPebble
+	}
+	return DBLeveldb
+}
+
+// NewKeyValueStore opens a persistent key-value store of the given engine. If no
+// engine is requested, the one of the existing database is used, defaulting to
+// LevelDB for new ones. Requesting an engine different from the one of an existing
+// database is an error.
+func NewKeyValueStore(engine string, file string, cache int, handles int, namespace string, readonly bool) (highdb.KeyValueStore, error) {
+	existing := PreexistingDatabase(file)
+	if existing != "" && engine != "" && engine != existing {
+		return nil, fmt.Errorf("db.engine choice was %s but found pre-existing %s database in %s", engine, existing, file)
+	}
+	if engine == "" {
+		engine = existing
+	}
+	switch engine {
+	case DBLeveldb, "":
+		log.Info("Using LevelDB as the backing database")
+		if readonly {
+			db, err := leveldb.NewCustom(file, namespace, func(options *opt.Options) {
+				options.ReadOnly = true
+			})
+			if err != nil {
+				return nil, err
+			}
+			return db, nil
+		}
+		db, err := leveldb.New(file, cache, handles, namespace)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	case DBPebble:
+		log.Info("Using pebble as the backing database")
+		return newPebbleStore(file, cache, handles, namespace, readonly)
+	default:
+		return nil, fmt.Errorf("unknown db.engine %q", engine)
+	}
+}
+
+// NewKeyValueDatabase creates a persistent key-value database of the given engine
+// without a freezer moving immutable chain segments into cold storage.
+func NewKeyValueDatabase(engine string, file string, cache int, handles int, namespace string) (highdb.Database, error) {
+	db, err := NewKeyValueStore(engine, file, cache, handles, namespace, false)
+	if err != nil {
+		return nil, err
+	}
+	return NewDatabase(db), nil
+}
+
+// NewKeyValueDatabaseWithFreezer creates a persistent key-value database of the
+// given engine with a freezer moving immutable chain segments into cold storage.
+func NewKeyValueDatabaseWithFreezer(engine string, file string, cache int, handles int, freezer string, namespace string) (highdb.Database, error) {
+	kvdb, err := NewKeyValueStore(engine, file, cache, handles, namespace, false)
+	if err != nil {
+		return nil, err
+	}
+	frdb, err := NewDatabaseWithFreezer(kvdb, freezer, namespace)
+	if err != nil {
+		kvdb.Close()
+		return nil, err
+	}
+	return frdb, nil
+}
+
 type counter uint64
 
 func (c counter) String() string {
@@ -294,6 +389,7 @@ func InspectDatabase(db highdb.Database, keyPrefix, keyStart []byte) error {
 		preimages       stat
 		bloomBits       stat
 		cliqueSnaps     stat
+		stateHistory    stat
 
 		// Ancient store statistics
 		ancientHeadersSize  common.StorageSize
@@ -350,6 +446,8 @@ func InspectDatabase(db highdb.Database, keyPrefix, keyStart []byte) error {
 			bloomBits.Add(size)
 		case bytes.HasPrefix(key, BloomBitsIndexPrefix):
 			bloomBits.Add(size)
+		case bytes.HasPrefix(key, stateHistoryPrefix) && len(key) == (len(stateHistoryPrefix)+8+common.HashLength):
+			stateHistory.Add(size)
 		case bytes.HasPrefix(key, []byte("clique-")) && len(key) == 7+common.HashLength:
 			cliqueSnaps.Add(size)
 		case bytes.HasPrefix(key, []byte("cht-")) ||
@@ -368,7 +466,7 @@ func InspectDatabase(db highdb.Database, keyPrefix, keyStart []byte) error {
 				databaseVersionKey, headHeaderKey, headBlockKey, headFastBlockKey, lastPivotKey,
 				fastTrieProgressKey, snapshotRootKey, snapshotJournalKey, snapshotGeneratorKey,
 				snapshotRecoveryKey, txIndexTailKey, fastTxLookupLimitKey, uncleanShutdownKey,
-				badBlockKey,
+				badBlockKey, stateHistoryOffsetKey,
 			} {
 				if bytes.Equal(key, meta) {
 					metadata.Add(size)
@@ -415,6 +513,7 @@ func InspectDatabase(db highdb.Database, keyPrefix, keyStart []byte) error {
 		{"Key-Value store", "Account snapshot", accountSnaps.Size(), accountSnaps.Count()},
 		{"Key-Value store", "Storage snapshot", storageSnaps.Size(), storageSnaps.Count()},
 		{"Key-Value store", "Clique snapshots", cliqueSnaps.Size(), cliqueSnaps.Count()},
+		{"Key-Value store", "State history", stateHistory.Size(), stateHistory.Count()},
 		{"Key-Value store", "Singleton metadata", metadata.Size(), metadata.Count()},
 		{"Key-Value store", "Shutdown metadata", shutdownInfo.Size(), shutdownInfo.Count()},
 		{"Ancient store", "Headers", ancientHeadersSize.String(), ancients.String()},
@@ -0,0 +1,33 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build arm64 amd64
+
+package rawdb
+
+import (
+	"github.com/420integrated/go-highcoin/highdb"
+	"github.com/420integrated/go-highcoin/highdb/pebble"
+)
+
+// newPebbleStore opens a persistent pebble key-value store.
+func newPebbleStore(file string, cache int, handles int, namespace string, readonly bool) (highdb.KeyValueStore, error) {
+	db, err := pebble.New(file, cache, handles, namespace, readonly)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build arm64 amd64
+
+package rawdb
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// Tests that pebble databases can be created, detected and reopened.
+func TestKeyValueStorePebble(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewKeyValueStore(DBPebble, dir, 0, 0, "", false)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	if err := db.Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("failed to write database: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("failed to close database: %v", err)
+	}
+	if engine := PreexistingDatabase(dir); engine != DBPebble {
+		t.Fatalf("engine mismatch: have %q, want %q", engine, DBPebble)
+	}
+	if _, err := NewKeyValueStore(DBLeveldb, dir, 0, 0, "", false); err == nil {
+		t.Fatalf("mismatching engine accepted")
+	}
+	db, err = NewKeyValueStore("", dir, 0, 0, "", true)
+	if err != nil {
+		t.Fatalf("failed to reopen database: %v", err)
+	}
+	defer db.Close()
+
+	if value, err := db.Get([]byte("key")); err != nil || string(value) != "value" {
+		t.Fatalf("value mismatch: have %q, %v, want %q", value, err, "value")
+	}
+}
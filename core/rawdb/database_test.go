@@ -0,0 +1,71 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Tests that the engine of an existing database is detected and a mismatching
+// engine choice is rejected.
+func TestKeyValueStoreEngine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if engine := PreexistingDatabase(dir); engine != "" {
+		t.Fatalf("empty directory engine mismatch: have %q, want none", engine)
+	}
+	if _, err := NewKeyValueStore("rocksdb", dir, 0, 0, "", false); err == nil {
+		t.Fatalf("unknown engine accepted")
+	}
+	// Create a database with the default engine and ensure it's detected
+	db, err := NewKeyValueStore("", dir, 0, 0, "", false)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	db.Put([]byte("key"), []byte("value"))
+	db.Close()
+
+	if engine := PreexistingDatabase(dir); engine != DBLeveldb {
+		t.Fatalf("engine mismatch: have %q, want %q", engine, DBLeveldb)
+	}
+	if _, err := NewKeyValueStore(DBPebble, dir, 0, 0, "", false); err == nil {
+		t.Fatalf("mismatching engine accepted")
+	}
+	db, err = NewKeyValueStore("", dir, 0, 0, "", true)
+	if err != nil {
+		t.Fatalf("failed to reopen database: %v", err)
+	}
+	if value, err := db.Get([]byte("key")); err != nil || string(value) != "value" {
+		t.Fatalf("value mismatch: have %q, %v, want %q", value, err, "value")
+	}
+	db.Close()
+
+	// Pebble databases are recognised by their options files
+	if err := ioutil.WriteFile(filepath.Join(dir, "OPTIONS-000003"), nil, 0644); err != nil {
+		t.Fatalf("failed to create options file: %v", err)
+	}
+	if engine := PreexistingDatabase(dir); engine != DBPebble {
+		t.Fatalf("engine mismatch: have %q, want %q", engine, DBPebble)
+	}
+}
@@ -184,6 +184,15 @@ func (f *freezer) AncientSize(kind string) (uint64, error) {
 	return 0, errUnknownTable
 }
 
+// AncientTail returns the number of the first item still available in the
+// specified category.
+func (f *freezer) AncientTail(kind string) (uint64, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.tail(), nil
+	}
+	return 0, errUnknownTable
+}
+
 // AppendAncient injects all binary blobs belong to block at the end of the
 // append-only immutable table files.
 //
@@ -245,6 +254,16 @@ func (f *freezer) TruncateAncients(items uint64) error {
 	return nil
 }
 
+// TruncateAncientTail discards the data below the provided threshold number in
+// the specified category. Only whole data files are deleted, so some items below
+// the threshold may be retained.
+func (f *freezer) TruncateAncientTail(kind string, items uint64) error {
+	if table := f.tables[kind]; table != nil {
+		return table.truncateTail(items)
+	}
+	return errUnknownTable
+}
+
 // Sync flushes all data tables to disk.
 func (f *freezer) Sync() error {
 	var errs []error
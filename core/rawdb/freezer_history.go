@@ -0,0 +1,126 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/420integrated/go-highcoin/log"
+	"github.com/420integrated/go-highcoin/metrics"
+	"github.com/prometheus/tsdb/fileutil"
+)
+
+// StateHistoryFreezer is an append-only flat file store of the reverse state
+// diffs of finalized blocks. Contrary to the chain freezer, it does not need to
+// start at genesis, nor does it move data around by itself: items are appended
+// by the owner of the store one by one and it's up to the owner to map them to
+// block numbers.
+type StateHistoryFreezer struct {
+	table        *freezerTable     // Data table storing the reverse diffs
+	instanceLock fileutil.Releaser // File-system lock to prevent double opens
+}
+
+// NewStateHistoryFreezer opens the state history freezer in the given directory,
+// creating it if it doesn't exist yet.
+func NewStateHistoryFreezer(datadir string, namespace string) (*StateHistoryFreezer, error) {
+	return newStateHistoryFreezer(datadir, namespace, 2*1000*1000*1000)
+}
+
+// newStateHistoryFreezer opens the state history freezer in the given directory,
+// splitting the data into files of the given maximum size.
+func newStateHistoryFreezer(datadir string, namespace string, maxFilesize uint32) (*StateHistoryFreezer, error) {
+	var (
+		readMeter  = metrics.NewRegisteredMeter(namespace+"statehistory/read", nil)
+		writeMeter = metrics.NewRegisteredMeter(namespace+"statehistory/write", nil)
+		sizeGauge  = metrics.NewRegisteredGauge(namespace+"statehistory/size", nil)
+	)
+	// Ensure the datadir is not a symbolic link if it exists.
+	if info, err := os.Lstat(datadir); !os.IsNotExist(err) {
+		if info.Mode()&os.ModeSymlink != 0 {
+			log.Warn("Symbolic link state history database is not supported", "path", datadir)
+			return nil, errSymlinkDatadir
+		}
+	}
+	if err := os.MkdirAll(datadir, 0755); err != nil {
+		return nil, err
+	}
+	lock, _, err := fileutil.Flock(filepath.Join(datadir, "FLOCK"))
+	if err != nil {
+		return nil, err
+	}
+	table, err := newCustomTable(datadir, freezerStateHistoryTable, readMeter, writeMeter, sizeGauge, maxFilesize, false)
+	if err != nil {
+		lock.Release()
+		return nil, err
+	}
+	log.Info("Opened state history database", "database", datadir, "items", atomic.LoadUint64(&table.items))
+	return &StateHistoryFreezer{
+		table:        table,
+		instanceLock: lock,
+	}, nil
+}
+
+// Items returns the number of reverse diffs stored in the freezer.
+func (f *StateHistoryFreezer) Items() uint64 {
+	return atomic.LoadUint64(&f.table.items)
+}
+
+// Tail returns the index of the first reverse diff still stored in the freezer.
+func (f *StateHistoryFreezer) Tail() uint64 {
+	return f.table.tail()
+}
+
+// Retrieve returns the reverse diff stored at the given item index.
+func (f *StateHistoryFreezer) Retrieve(item uint64) ([]byte, error) {
+	return f.table.Retrieve(item)
+}
+
+// Append injects a reverse diff at the end of the freezer. The item index must
+// be the next one in line, otherwise the append is rejected.
+func (f *StateHistoryFreezer) Append(item uint64, blob []byte) error {
+	if f.Items() != item {
+		return errOutOrderInsertion
+	}
+	return f.table.Append(item, blob)
+}
+
+// Truncate discards all but the first n reverse diffs from the freezer.
+func (f *StateHistoryFreezer) Truncate(items uint64) error {
+	return f.table.truncate(items)
+}
+
+// TruncateTail discards the reverse diffs below the given item index. Only whole
+// data files are deleted, so some diffs below the index may be retained.
+func (f *StateHistoryFreezer) TruncateTail(items uint64) error {
+	return f.table.truncateTail(items)
+}
+
+// Sync flushes the freezer data to disk.
+func (f *StateHistoryFreezer) Sync() error {
+	return f.table.Sync()
+}
+
+// Close terminates the freezer, unmapping all the data files.
+func (f *StateHistoryFreezer) Close() error {
+	if err := f.table.Close(); err != nil {
+		f.instanceLock.Release()
+		return err
+	}
+	return f.instanceLock.Release()
+}
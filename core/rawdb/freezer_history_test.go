@@ -0,0 +1,98 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// makeStateHistoryDiff creates a 15 byte blob not shrinking under compression,
+// so that two of them fit into a 40 byte data file.
+func makeStateHistoryDiff(n int) []byte {
+	blob := make([]byte, 15)
+	for i := range blob {
+		blob[i] = byte(n*len(blob) + i)
+	}
+	return blob
+}
+
+// Tests that the tail of the state history freezer can be pruned, retaining the
+// item indexes of the remaining diffs across restarts.
+func TestStateHistoryFreezerTruncateTail(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "statehistory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	// Write 10 incompressible diffs, splitting out into five files
+	freezer, err := newStateHistoryFreezer(datadir, "", 40)
+	if err != nil {
+		t.Fatalf("failed to open freezer: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := freezer.Append(uint64(i), makeStateHistoryDiff(i)); err != nil {
+			t.Fatalf("item %d: failed to append: %v", i, err)
+		}
+	}
+	if err := freezer.TruncateTail(5); err != nil {
+		t.Fatalf("failed to truncate tail: %v", err)
+	}
+	check := func(tail uint64) {
+		t.Helper()
+		if have := freezer.Tail(); have != tail {
+			t.Fatalf("tail mismatch: have %d, want %d", have, tail)
+		}
+		if have := freezer.Items(); have != 10 {
+			t.Fatalf("item count mismatch: have %d, want 10", have)
+		}
+		for i := uint64(0); i < 10; i++ {
+			blob, err := freezer.Retrieve(i)
+			if i < tail {
+				if err == nil {
+					t.Fatalf("item %d: pruned item retrieved", i)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("item %d: failed to retrieve: %v", i, err)
+			}
+			if want := makeStateHistoryDiff(int(i)); !bytes.Equal(blob, want) {
+				t.Fatalf("item %d: content mismatch: have %x, want %x", i, blob, want)
+			}
+		}
+	}
+	// Only whole files are deleted, the item sharing the file of the new tail
+	// is retained
+	check(4)
+
+	if err := freezer.Close(); err != nil {
+		t.Fatalf("failed to close freezer: %v", err)
+	}
+	if freezer, err = newStateHistoryFreezer(datadir, "", 40); err != nil {
+		t.Fatalf("failed to reopen freezer: %v", err)
+	}
+	defer freezer.Close()
+	check(4)
+
+	if err := freezer.Append(10, makeStateHistoryDiff(10)); err != nil {
+		t.Fatalf("failed to append after pruning: %v", err)
+	}
+}
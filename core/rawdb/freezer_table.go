@@ -21,8 +21,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"sync/atomic"
 
@@ -167,6 +169,11 @@ func newCustomTable(path string, name string, readMeter metrics.Meter, writeMete
 		// Compressed idx
 		idxName = fmt.Sprintf("%s.cidx", name)
 	}
+	// A leftover temporary index means a crash happened before it was moved in
+	// place, so the original index is still the valid one
+	if err := os.Remove(filepath.Join(path, idxName+".tmp")); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
 	offsets, err := openFreezerFileForAppend(filepath.Join(path, idxName))
 	if err != nil {
 		return nil, err
@@ -242,6 +249,21 @@ func (t *freezerTable) repair() error {
 
 	t.index.ReadAt(buffer, offsetsSize-indexEntrySize)
 	lastIndex.unmarshalBinary(buffer)
+	if offsetsSize == indexEntrySize {
+		lastIndex.offset = 0 // Tail marker, not a data offset
+	}
+	// Delete any data files below the tail left behind by an interrupted tail
+	// truncation
+	for num := t.tailId; num > 0; num-- {
+		name := t.fileName(num - 1)
+		if _, err := os.Stat(name); err != nil {
+			break
+		}
+		t.logger.Warn("Deleting dangling tail file", "file", filepath.Base(name))
+		if err := os.Remove(name); err != nil {
+			return err
+		}
+	}
 	t.head, err = t.openFile(lastIndex.filenum, openFreezerFileForAppend)
 	if err != nil {
 		return err
@@ -273,6 +295,9 @@ func (t *freezerTable) repair() error {
 			t.index.ReadAt(buffer, offsetsSize-indexEntrySize)
 			var newLastIndex indexEntry
 			newLastIndex.unmarshalBinary(buffer)
+			if offsetsSize == indexEntrySize {
+				newLastIndex.offset = 0 // Tail marker, not a data offset
+			}
 			// We might have slipped back into an earlier head-file here
 			if newLastIndex.filenum != lastIndex.filenum {
 				// Release earlier opened file
@@ -350,16 +375,26 @@ func (t *freezerTable) truncate(items uint64) error {
 		log = t.logger.Warn // Only loud warn if we delete multiple items
 	}
 	log("Truncating freezer table", "items", existing, "limit", items)
-	if err := truncateFreezerFile(t.index, int64(items+1)*indexEntrySize); err != nil {
+
+	// The index only tracks the items above the tail, if we need to truncate
+	// into the deleted section, drop everything and restart from there
+	if items < uint64(t.itemOffset) {
+		return t.reset(items)
+	}
+	position := items - uint64(t.itemOffset)
+	if err := truncateFreezerFile(t.index, int64(position+1)*indexEntrySize); err != nil {
 		return err
 	}
 	// Calculate the new expected size of the data file and truncate it
 	buffer := make([]byte, indexEntrySize)
-	if _, err := t.index.ReadAt(buffer, int64(items*indexEntrySize)); err != nil {
+	if _, err := t.index.ReadAt(buffer, int64(position*indexEntrySize)); err != nil {
 		return err
 	}
 	var expected indexEntry
 	expected.unmarshalBinary(buffer)
+	if position == 0 {
+		expected.offset = 0 // Tail marker, not a data offset
+	}
 
 	// We might need to truncate back to older files
 	if expected.filenum != t.headId {
@@ -393,6 +428,175 @@ func (t *freezerTable) truncate(items uint64) error {
 	return nil
 }
 
+// truncateTail discards any data below the provided threshold number. Only whole
+// data files are deleted, so the items sharing the data file of the first item
+// to keep are retained. The number of deleted items is tracked in the first
+// index entry.
+func (t *freezerTable) truncateTail(items uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// The head file is never deleted, nor anything above the head
+	if existing := atomic.LoadUint64(&t.items); items > existing {
+		items = existing
+	}
+	if items <= uint64(t.itemOffset) {
+		return nil
+	}
+	// Find the data file containing the new tail item, the end offset of each
+	// item is stored in the index entry following it
+	var (
+		buffer   = make([]byte, indexEntrySize)
+		position = items - uint64(t.itemOffset)
+		entry    indexEntry
+		tailId   = t.headId
+	)
+	if items < atomic.LoadUint64(&t.items) {
+		if _, err := t.index.ReadAt(buffer, int64((position+1)*indexEntrySize)); err != nil {
+			return err
+		}
+		entry.unmarshalBinary(buffer)
+		tailId = entry.filenum
+	}
+	if tailId == t.tailId {
+		return nil
+	}
+	// Find the first item stored in the new tail file, all items before can be
+	// dropped together with their data files
+	var failure error
+	first := sort.Search(int(position), func(n int) bool {
+		if _, err := t.index.ReadAt(buffer, int64(n+1)*indexEntrySize); err != nil {
+			failure = err
+			return true
+		}
+		entry.unmarshalBinary(buffer)
+		return entry.filenum >= tailId
+	})
+	if failure != nil {
+		return failure
+	}
+	offset := uint64(t.itemOffset) + uint64(first)
+	if offset > math.MaxUint32 {
+		return fmt.Errorf("tail offset overflow: %d", offset)
+	}
+	// We need to truncate, save the old size for metrics tracking
+	oldSize, err := t.sizeNolock()
+	if err != nil {
+		return err
+	}
+	t.logger.Info("Truncating freezer table tail", "tail", t.itemOffset, "limit", items, "deleted", offset-uint64(t.itemOffset))
+
+	// Swap in a new index with the new tail marker and the entries of the
+	// retained items, then delete the data files below the new tail
+	marker := indexEntry{filenum: tailId, offset: uint32(offset)}
+	if err := t.replaceIndex(marker, int64(first+1)*indexEntrySize); err != nil {
+		return err
+	}
+	removeErr := t.releaseFilesBefore(tailId, true)
+	t.tailId = tailId
+	t.itemOffset = uint32(offset)
+
+	// Retrieve the new size and update the total size counter
+	newSize, err := t.sizeNolock()
+	if err != nil {
+		return err
+	}
+	t.sizeGauge.Dec(int64(oldSize - newSize))
+
+	// The new tail is in effect even if some of the released files could not
+	// be deleted, report them nonetheless
+	if removeErr != nil {
+		return fmt.Errorf("failed to delete truncated freezer files: %v", removeErr)
+	}
+	return nil
+}
+
+// reset discards all the data in the table and restarts it with a fresh data
+// file, the first item of which will be the given one. This method assumes that
+// the write lock is held by the caller.
+func (t *freezerTable) reset(items uint64) error {
+	oldSize, err := t.sizeNolock()
+	if err != nil {
+		return err
+	}
+	headId := t.headId + 1
+	if err := t.replaceIndex(indexEntry{filenum: headId, offset: uint32(items)}, -1); err != nil {
+		return err
+	}
+	head, err := t.openFile(headId, openFreezerFileTruncated)
+	if err != nil {
+		return err
+	}
+	removeErr := t.releaseFilesBefore(headId, true)
+
+	t.head = head
+	t.tailId = headId
+	t.itemOffset = uint32(items)
+	atomic.StoreUint32(&t.headId, headId)
+	atomic.StoreUint32(&t.headBytes, 0)
+	atomic.StoreUint64(&t.items, items)
+
+	// Retrieve the new size and update the total size counter
+	newSize, err := t.sizeNolock()
+	if err != nil {
+		return err
+	}
+	t.sizeGauge.Dec(int64(oldSize - newSize))
+
+	if removeErr != nil {
+		return fmt.Errorf("failed to delete reset freezer files: %v", removeErr)
+	}
+	return nil
+}
+
+// replaceIndex atomically replaces the index file with one starting with the
+// given tail marker, followed by the entries of the current index from the given
+// byte position onward (none if negative). This method assumes that the write
+// lock is held by the caller.
+//
+// The new index is assembled in a temporary file and moved in place afterwards,
+// so a crash leaves either the old or the new index behind. The data files made
+// unreachable by the new index need to be deleted by the caller; if that's cut
+// short by a crash, they are cleaned up by the next repair.
+func (t *freezerTable) replaceIndex(marker indexEntry, from int64) error {
+	name := t.index.Name()
+	tmp, err := openFreezerFileTruncated(name + ".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(marker.marshallBinary()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if from >= 0 {
+		stat, err := t.index.Stat()
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		if _, err := io.Copy(tmp, io.NewSectionReader(t.index, from, stat.Size()-from)); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// Swap the new index in, the old one can't be used from here on
+	if err := t.index.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(name+".tmp", name); err != nil {
+		return err
+	}
+	t.index, err = openFreezerFileForAppend(name)
+	return err
+}
+
 // Close closes all opened files.
 func (t *freezerTable) Close() error {
 	t.lock.Lock()
@@ -421,13 +625,7 @@ func (t *freezerTable) Close() error {
 func (t *freezerTable) openFile(num uint32, opener func(string) (*os.File, error)) (f *os.File, err error) {
 	var exist bool
 	if f, exist = t.files[num]; !exist {
-		var name string
-		if t.noCompression {
-			name = fmt.Sprintf("%s.%04d.rdat", t.name, num)
-		} else {
-			name = fmt.Sprintf("%s.%04d.cdat", t.name, num)
-		}
-		f, err = opener(filepath.Join(t.path, name))
+		f, err = opener(t.fileName(num))
 		if err != nil {
 			return nil, err
 		}
@@ -436,6 +634,17 @@ func (t *freezerTable) openFile(num uint32, opener func(string) (*os.File, error
 	return f, err
 }
 
+// fileName returns the path of the data file with the given number.
+func (t *freezerTable) fileName(num uint32) string {
+	var name string
+	if t.noCompression {
+		name = fmt.Sprintf("%s.%04d.rdat", t.name, num)
+	} else {
+		name = fmt.Sprintf("%s.%04d.cdat", t.name, num)
+	}
+	return filepath.Join(t.path, name)
+}
+
 // releaseFile closes a file, and removes it from the open file cache.
 // Assumes that the caller holds the write lock
 func (t *freezerTable) releaseFile(num uint32) {
@@ -458,6 +667,26 @@ func (t *freezerTable) releaseFilesAfter(num uint32, remove bool) {
 	}
 }
 
+// releaseFilesBefore closes all open files with a lower number, and optionally
+// also deletes the files. Files are deleted in ascending order, so the ones left
+// behind by an interrupted deletion are contiguous. Deletion stops at the first
+// failure, which is returned; the files left behind are retried by the next
+// repair.
+func (t *freezerTable) releaseFilesBefore(num uint32, remove bool) error {
+	var failure error
+	for fnum := t.tailId; fnum < num; fnum++ {
+		t.releaseFile(fnum)
+		if !remove || failure != nil {
+			continue
+		}
+		if err := os.Remove(t.fileName(fnum)); err != nil && !os.IsNotExist(err) {
+			t.logger.Warn("Failed to delete freezer file", "file", filepath.Base(t.fileName(fnum)), "err", err)
+			failure = err
+		}
+	}
+	return failure
+}
+
 // Append injects a binary blob at the end of the freezer table. The item number
 // is a precautionary parameter to ensure data correctness, but the table will
 // reject already existing data.
@@ -605,7 +834,18 @@ func (t *freezerTable) Retrieve(item uint64) ([]byte, error) {
 // has returns an indicator if the specified number data
 // exists in the freezer table.
 func (t *freezerTable) has(number uint64) bool {
-	return atomic.LoadUint64(&t.items) > number
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return atomic.LoadUint64(&t.items) > number && uint64(t.itemOffset) <= number
+}
+
+// tail returns the number of the first item still stored in the freezer table.
+func (t *freezerTable) tail() uint64 {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return uint64(t.itemOffset)
 }
 
 // size returns the total data size in the freezer table.
@@ -19,9 +19,11 @@ package rawdb
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -630,6 +632,430 @@ func TestOffset(t *testing.T) {
 	checkPresent(1000000)
 }
 
+// TestFreezerTruncateTail tests that deleting items from the tail of the table
+// drops whole data files and keeps the remaining items accessible, also after
+// reopening the table.
+func TestFreezerTruncateTail(t *testing.T) {
+	t.Parallel()
+	rm, wm, sg := metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge()
+	fname := fmt.Sprintf("truncate-tail-%d", rand.Uint64())
+
+	// Write 10 x 20 bytes, splitting out into five files
+	f, err := newCustomTable(os.TempDir(), fname, rm, wm, sg, 40, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := 0; x < 10; x++ {
+		if err := f.Append(uint64(x), getChunk(20, 0xFF-x)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Truncating into the third file should only delete the first two ones
+	if err := f.truncateTail(5); err != nil {
+		t.Fatal(err)
+	}
+	check := func(f *freezerTable, tail, items uint64) {
+		t.Helper()
+		if have := f.tail(); have != tail {
+			t.Fatalf("tail mismatch: have %d, want %d", have, tail)
+		}
+		for x := uint64(0); x < items+2; x++ {
+			got, err := f.Retrieve(x)
+			switch {
+			case x < tail || x >= items:
+				if err == nil {
+					t.Fatalf("item %d: expected error", x)
+				}
+				if f.has(x) {
+					t.Fatalf("item %d: reported as present", x)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("item %d: %v", x, err)
+				}
+				if exp := getChunk(20, 0xFF-int(x)); !bytes.Equal(got, exp) {
+					t.Fatalf("item %d: expected %x got %x", x, exp, got)
+				}
+				if !f.has(x) {
+					t.Fatalf("item %d: reported as missing", x)
+				}
+			}
+		}
+	}
+	check(f, 4, 10)
+	for i := 0; i < 2; i++ {
+		if _, err := os.Stat(f.fileName(uint32(i))); !os.IsNotExist(err) {
+			t.Fatalf("data file %d not deleted: %v", i, err)
+		}
+	}
+	// Truncating within the tail file should be a noop
+	if err := f.truncateTail(5); err != nil {
+		t.Fatal(err)
+	}
+	check(f, 4, 10)
+	f.Close()
+
+	// Reopen the table, the tail should be retained and appends should work
+	f, err = newCustomTable(os.TempDir(), fname, rm, wm, sg, 40, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	check(f, 4, 10)
+
+	if err := f.Append(10, getChunk(20, 0xFF-10)); err != nil {
+		t.Fatal(err)
+	}
+	check(f, 4, 11)
+
+	// Truncating the head should respect the tail offset
+	if err := f.truncate(7); err != nil {
+		t.Fatal(err)
+	}
+	check(f, 4, 7)
+
+	// Truncating the tail beyond the head should keep the head file
+	if err := f.truncateTail(100); err != nil {
+		t.Fatal(err)
+	}
+	check(f, 6, 7)
+
+	// Truncating the head below the tail should restart the table from there
+	if err := f.truncate(3); err != nil {
+		t.Fatal(err)
+	}
+	check(f, 3, 3)
+	if err := f.Append(3, getChunk(20, 0xFF-3)); err != nil {
+		t.Fatal(err)
+	}
+	check(f, 3, 4)
+	f.Close()
+
+	f, err = newCustomTable(os.TempDir(), fname, rm, wm, sg, 40, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(f, 3, 4)
+}
+
+// TestFreezerTruncateTailRemoveError tests that a failure to delete the data
+// files below the new tail is reported, and that the files left behind are
+// cleaned up by the next repair.
+func TestFreezerTruncateTailRemoveError(t *testing.T) {
+	t.Parallel()
+	rm, wm, sg := metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge()
+	fname := fmt.Sprintf("truncate-tail-fail-%d", rand.Uint64())
+
+	// Write 10 x 20 bytes, splitting out into five files
+	f, err := newCustomTable(os.TempDir(), fname, rm, wm, sg, 40, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := 0; x < 10; x++ {
+		if err := f.Append(uint64(x), getChunk(20, 0xFF-x)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Replace the first data file with a non-empty directory, which can't be
+	// removed, to make the deletion fail
+	name := f.fileName(0)
+	if err := os.Remove(name); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(name, "blocker"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(name)
+
+	if err := f.truncateTail(5); err == nil {
+		t.Fatal("failed file deletion not reported")
+	}
+	// The truncation itself must be in effect, with the remaining files left
+	if have := f.tail(); have != 4 {
+		t.Fatalf("tail mismatch: have %d, want 4", have)
+	}
+	if _, err := f.Retrieve(4); err != nil {
+		t.Fatalf("retained item missing: %v", err)
+	}
+	if _, err := os.Stat(f.fileName(1)); err != nil {
+		t.Fatalf("data file after the failure deleted: %v", err)
+	}
+	f.Close()
+
+	// Once the obstacle is gone, a reopen should delete the leftover files
+	if err := os.RemoveAll(name); err != nil {
+		t.Fatal(err)
+	}
+	f, err = newCustomTable(os.TempDir(), fname, rm, wm, sg, 40, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := os.Stat(f.fileName(1)); !os.IsNotExist(err) {
+		t.Fatalf("leftover data file not deleted: %v", err)
+	}
+	if have := f.tail(); have != 4 {
+		t.Fatalf("tail mismatch after reopen: have %d, want 4", have)
+	}
+}
+
+// TestFreezerTruncateTailCrash tests that a crash during a tail truncation leaves
+// the table either in its original or in its truncated state.
+func TestFreezerTruncateTailCrash(t *testing.T) {
+	t.Parallel()
+	rm, wm, sg := metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge()
+	fname := fmt.Sprintf("truncate-tail-crash-%d", rand.Uint64())
+
+	// Write 10 x 20 bytes, splitting out into five files
+	f, err := newCustomTable(os.TempDir(), fname, rm, wm, sg, 40, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := 0; x < 10; x++ {
+		if err := f.Append(uint64(x), getChunk(20, 0xFF-x)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	index := f.index.Name()
+	f.Close()
+
+	// Crash before the new index is moved in place, nothing should be deleted
+	if err := ioutil.WriteFile(index+".tmp", getChunk(indexEntrySize+3, 0x01), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = newCustomTable(os.TempDir(), fname, rm, wm, sg, 40, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(index + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary index not deleted: %v", err)
+	}
+	if tail := f.tail(); tail != 0 {
+		t.Fatalf("tail mismatch: have %d, want %d", tail, 0)
+	}
+	if _, err := f.Retrieve(0); err != nil {
+		t.Fatalf("failed to retrieve first item: %v", err)
+	}
+	// Crash after the new index is moved in place but before the data files are
+	// deleted, the dangling files should be cleaned up
+	f.lock.Lock()
+	err = f.replaceIndex(indexEntry{filenum: 3, offset: 6}, 7*indexEntrySize)
+	f.lock.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if f, err = newCustomTable(os.TempDir(), fname, rm, wm, sg, 40, true); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for i := uint32(0); i < 3; i++ {
+		if _, err := os.Stat(f.fileName(i)); !os.IsNotExist(err) {
+			t.Fatalf("dangling data file %d not deleted: %v", i, err)
+		}
+	}
+	if tail := f.tail(); tail != 6 {
+		t.Fatalf("tail mismatch: have %d, want %d", tail, 6)
+	}
+	for x := uint64(6); x < 10; x++ {
+		if got, err := f.Retrieve(x); err != nil {
+			t.Fatalf("item %d: %v", x, err)
+		} else if exp := getChunk(20, 0xFF-int(x)); !bytes.Equal(got, exp) {
+			t.Fatalf("item %d: expected %x got %x", x, exp, got)
+		}
+	}
+}
+
+// fuzzItem returns the content of the given item in the fuzz tests. It's derived
+// from the item number, so re-appended items are identical.
+func fuzzItem(item uint64) []byte {
+	return getChunk(1+int(item*7%30), int(item))
+}
+
+// TestFreezerFuzzRepair runs random appends, head and tail truncations on a table,
+// interleaved with simulated crashes leaving partial writes behind. After every
+// step the table must serve the expected items.
+func TestFreezerFuzzRepair(t *testing.T) {
+	t.Parallel()
+	for i := 0; i < 20; i++ {
+		seed := rand.Int63()
+		if err := fuzzFreezerRepair(seed, 200); err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+	}
+}
+
+func fuzzFreezerRepair(seed int64, steps int) error {
+	var (
+		rng        = rand.New(rand.NewSource(seed))
+		rm, wm, sg = metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge()
+		fname      = fmt.Sprintf("fuzz-repair-%d", rand.Uint64())
+		raw        = rng.Intn(2) == 0
+
+		tail, items uint64 // Expected range of available items
+	)
+	open := func() (*freezerTable, error) {
+		return newCustomTable(os.TempDir(), fname, rm, wm, sg, 50, raw)
+	}
+	verify := func(f *freezerTable) error {
+		if have := f.tail(); have != tail {
+			return fmt.Errorf("tail mismatch: have %d, want %d", have, tail)
+		}
+		if have := atomic.LoadUint64(&f.items); have != items {
+			return fmt.Errorf("items mismatch: have %d, want %d", have, items)
+		}
+		for i := tail; i < items; i++ {
+			blob, err := f.Retrieve(i)
+			if err != nil {
+				return fmt.Errorf("item %d: %v", i, err)
+			}
+			if !bytes.Equal(blob, fuzzItem(i)) {
+				return fmt.Errorf("item %d: content mismatch: have %x, want %x", i, blob, fuzzItem(i))
+			}
+		}
+		if tail > 0 {
+			if _, err := f.Retrieve(tail - 1); err == nil {
+				return fmt.Errorf("item %d below tail retrievable", tail-1)
+			}
+		}
+		if _, err := f.Retrieve(items); err == nil {
+			return fmt.Errorf("item %d above head retrievable", items)
+		}
+		return nil
+	}
+	f, err := open()
+	if err != nil {
+		return err
+	}
+	defer func() { f.Close() }()
+
+	for step := 0; step < steps; step++ {
+		switch rng.Intn(6) {
+		case 0: // Append a few items
+			for n := rng.Intn(10) + 1; n > 0; n-- {
+				if err := f.Append(items, fuzzItem(items)); err != nil {
+					return fmt.Errorf("step %d: append %d: %v", step, items, err)
+				}
+				items++
+			}
+
+		case 1: // Truncate the head, possibly below the tail
+			limit := uint64(rng.Int63n(int64(items) + 1))
+			if err := f.truncate(limit); err != nil {
+				return fmt.Errorf("step %d: truncate %d: %v", step, limit, err)
+			}
+			if limit < items {
+				items = limit
+			}
+			if limit < tail {
+				tail = limit
+			}
+
+		case 2: // Truncate the tail, possibly beyond the head
+			limit := uint64(rng.Int63n(int64(items) + 3))
+			if err := f.truncateTail(limit); err != nil {
+				return fmt.Errorf("step %d: truncate tail %d: %v", step, limit, err)
+			}
+			have := f.tail()
+			if have < tail || (have > tail && have > limit) {
+				return fmt.Errorf("step %d: truncate tail %d: tail moved from %d to %d", step, limit, tail, have)
+			}
+			tail = have
+			for i := uint32(0); i < f.tailId; i++ {
+				if _, err := os.Stat(f.fileName(i)); !os.IsNotExist(err) {
+					return fmt.Errorf("step %d: data file %d below tail not deleted", step, i)
+				}
+			}
+
+		case 3: // Crash during an append, leaving partial data or index entries
+			var (
+				index = f.index.Name()
+				head  = f.fileName(f.headId)
+			)
+			f.Close()
+
+			switch rng.Intn(3) {
+			case 0: // Data written, but index not updated
+				file, err := os.OpenFile(head, os.O_WRONLY|os.O_APPEND, 0644)
+				if err != nil {
+					return err
+				}
+				file.Write(getChunk(rng.Intn(50)+1, 0xaa))
+				file.Close()
+
+			case 1: // Index partially written or data lost
+				stat, err := os.Stat(head)
+				if err != nil {
+					return err
+				}
+				if err := os.Truncate(head, rng.Int63n(stat.Size()+1)); err != nil {
+					return err
+				}
+			case 2: // Index entries lost, always keeping the tail marker
+				stat, err := os.Stat(index)
+				if err != nil {
+					return err
+				}
+				if entries := stat.Size()/indexEntrySize - 1; entries > 0 {
+					size := indexEntrySize*(1+rng.Int63n(entries)) + rng.Int63n(indexEntrySize)
+					if err := os.Truncate(index, size); err != nil {
+						return err
+					}
+				}
+			}
+			if f, err = open(); err != nil {
+				return fmt.Errorf("step %d: reopen after append crash: %v", step, err)
+			}
+			have := atomic.LoadUint64(&f.items)
+			if have < tail || have > items {
+				return fmt.Errorf("step %d: repaired items out of range: have %d, want [%d, %d]", step, have, tail, items)
+			}
+			items = have
+
+		case 4: // Crash during a tail truncation
+			var (
+				index  = f.index.Name()
+				tailId = f.tailId
+			)
+			f.Close()
+
+			if rng.Intn(2) == 0 {
+				// New index not moved in place yet
+				if err := ioutil.WriteFile(index+".tmp", getChunk(rng.Intn(50), 0xbb), 0644); err != nil {
+					return err
+				}
+			} else {
+				// Data files below the tail not deleted yet
+				for num := tailId; num > 0 && num+3 > tailId; num-- {
+					if err := ioutil.WriteFile(f.fileName(num-1), getChunk(50, 0xcc), 0644); err != nil {
+						return err
+					}
+				}
+			}
+			if f, err = open(); err != nil {
+				return fmt.Errorf("step %d: reopen after tail truncation crash: %v", step, err)
+			}
+			for num := uint32(0); num < f.tailId; num++ {
+				if _, err := os.Stat(f.fileName(num)); !os.IsNotExist(err) {
+					return fmt.Errorf("step %d: dangling data file %d not deleted", step, num)
+				}
+			}
+
+		case 5: // Clean restart
+			f.Close()
+			if f, err = open(); err != nil {
+				return fmt.Errorf("step %d: reopen: %v", step, err)
+			}
+		}
+		if err := verify(f); err != nil {
+			return fmt.Errorf("step %d: %v", step, err)
+		}
+	}
+	return nil
+}
+
 // TODO (?)
 // - test that if we remove several head-files, aswell as data last data-file,
 //   the index is truncated accordingly
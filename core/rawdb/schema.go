@@ -66,6 +66,10 @@ var (
 	// fastTxLookupLimitKey tracks the transaction lookup limit during fast sync.
 	fastTxLookupLimitKey = []byte("FastTransactionLookupLimit")
 
+	// stateHistoryOffsetKey tracks the number of the first block whose reverse
+	// state diff is stored in the state history freezer.
+	stateHistoryOffsetKey = []byte("StateHistoryOffset")
+
 	// badBlockKey tracks the list of bad blocks seen by local
 	badBlockKey = []byte("InvalidBlock")
 
@@ -86,6 +90,7 @@ var (
 	SnapshotAccountPrefix = []byte("a") // SnapshotAccountPrefix + account hash -> account trie value
 	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
 	CodePrefix            = []byte("c") // CodePrefix + code hash -> account code
+	stateHistoryPrefix    = []byte("d") // stateHistoryPrefix + num (uint64 big endian) + hash -> reverse state diff
 
 	preimagePrefix = []byte("secure-key-")      // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("highcoin-config-") // config prefix for the db
@@ -112,6 +117,10 @@ const (
 
 	// freezerDifficultyTable indicates the name of the freezer total difficulty table.
 	freezerDifficultyTable = "diffs"
+
+	// freezerStateHistoryTable indicates the name of the state history freezer
+	// reverse diff table.
+	freezerStateHistoryTable = "statehistory"
 )
 
 // freezerNoSnappy configures if compression is disabled for the ancient-tables.
@@ -209,6 +218,11 @@ func preimageKey(hash common.Hash) []byte {
 	return append(preimagePrefix, hash.Bytes()...)
 }
 
+// stateHistoryKey = stateHistoryPrefix + num (uint64 big endian) + hash
+func stateHistoryKey(number uint64, hash common.Hash) []byte {
+	return append(append(stateHistoryPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
 // codeKey = CodePrefix + hash
 func codeKey(hash common.Hash) []byte {
 	return append(CodePrefix, hash.Bytes()...)
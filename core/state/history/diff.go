@@ -0,0 +1,177 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package history implements the persistent reverse-diff state history, which
+// allows serving historical state reads without retaining the historical tries.
+package history
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/core/state/snapshot"
+	"github.com/420integrated/go-highcoin/rlp"
+)
+
+// diffVersion is the version number of the reverse diff encoding. It's stored
+// as the first byte of every diff to allow changing the format later on.
+const diffVersion = 0
+
+var errUnknownVersion = errors.New("unknown state history version")
+
+// Diff is the reverse state diff of a single block: the values of all the
+// accounts and storage slots modified by the block, as they were before the
+// block was applied.
+type Diff struct {
+	Number     uint64      // Number of the block the diff belongs to
+	Root       common.Hash // State root after applying the block
+	ParentRoot common.Hash // State root before applying the block
+	Accounts   []Account   // Previous values of the modified accounts, sorted by hash
+	Storages   []Storage   // Previous values of the modified storage slots, sorted by account hash
+}
+
+// Account is the previous value of a modified account.
+type Account struct {
+	Hash common.Hash // Hash of the account address
+	Blob []byte      // Account in slim snapshot format, empty if it did not exist
+}
+
+// Storage is the set of previous values of the modified slots of an account.
+type Storage struct {
+	Account common.Hash // Hash of the account address
+	Slots   []Slot      // Previous values of the modified slots, sorted by hash
+}
+
+// Slot is the previous value of a modified storage slot.
+type Slot struct {
+	Hash common.Hash // Hash of the storage slot key
+	Blob []byte      // RLP encoded slot value, empty if it was not set
+}
+
+// NewDiff assembles the reverse diff of a state transition from parent to root,
+// given the state changes in the format they are fed into the snapshot tree.
+// The previous values are looked up from the parent snapshot layer. The whole
+// storage of destructed accounts is recorded, so the tree is needed to iterate
+// it.
+//
+// The block number is not known at this point and needs to be filled in by the
+// caller.
+func NewDiff(tree *snapshot.Tree, parent snapshot.Snapshot, root common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) (*Diff, error) {
+	diff := &Diff{
+		Root:       root,
+		ParentRoot: parent.Root(),
+	}
+	// Collect the previous values of all the touched accounts
+	touched := make(map[common.Hash]struct{}, len(destructs)+len(accounts))
+	for hash := range destructs {
+		touched[hash] = struct{}{}
+	}
+	for hash := range accounts {
+		touched[hash] = struct{}{}
+	}
+	for hash := range touched {
+		blob, err := parent.AccountRLP(hash)
+		if err != nil {
+			return nil, err
+		}
+		diff.Accounts = append(diff.Accounts, Account{Hash: hash, Blob: common.CopyBytes(blob)})
+	}
+	sort.Slice(diff.Accounts, func(i, j int) bool {
+		return bytes.Compare(diff.Accounts[i].Hash[:], diff.Accounts[j].Hash[:]) < 0
+	})
+	// Collect the previous values of all the touched slots. Destructed accounts
+	// lose their entire storage, so all of it needs to be recorded.
+	slots := make(map[common.Hash]map[common.Hash][]byte)
+	for hash := range destructs {
+		it, err := tree.StorageIterator(diff.ParentRoot, hash, common.Hash{})
+		if err != nil {
+			return nil, err
+		}
+		for it.Next() {
+			if slots[hash] == nil {
+				slots[hash] = make(map[common.Hash][]byte)
+			}
+			slots[hash][it.Hash()] = common.CopyBytes(it.Slot())
+		}
+		err = it.Error()
+		it.Release()
+		if err != nil {
+			return nil, err
+		}
+	}
+	for hash, changes := range storage {
+		if slots[hash] == nil {
+			slots[hash] = make(map[common.Hash][]byte)
+		}
+		for slot := range changes {
+			if _, ok := slots[hash][slot]; ok {
+				continue
+			}
+			// Slots of destructed accounts were all recorded above, anything not
+			// yet found there was empty in the parent state.
+			if _, destructed := destructs[hash]; destructed {
+				slots[hash][slot] = nil
+				continue
+			}
+			blob, err := parent.Storage(hash, slot)
+			if err != nil {
+				return nil, err
+			}
+			slots[hash][slot] = common.CopyBytes(blob)
+		}
+	}
+	for account, values := range slots {
+		entry := Storage{Account: account}
+		for slot, blob := range values {
+			entry.Slots = append(entry.Slots, Slot{Hash: slot, Blob: blob})
+		}
+		sort.Slice(entry.Slots, func(i, j int) bool {
+			return bytes.Compare(entry.Slots[i].Hash[:], entry.Slots[j].Hash[:]) < 0
+		})
+		diff.Storages = append(diff.Storages, entry)
+	}
+	sort.Slice(diff.Storages, func(i, j int) bool {
+		return bytes.Compare(diff.Storages[i].Account[:], diff.Storages[j].Account[:]) < 0
+	})
+	return diff, nil
+}
+
+// EncodeDiff serializes a reverse diff into its versioned binary format.
+func EncodeDiff(diff *Diff) ([]byte, error) {
+	blob, err := rlp.EncodeToBytes(diff)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{diffVersion}, blob...), nil
+}
+
+// DecodeDiff parses a reverse diff from its versioned binary format.
+func DecodeDiff(blob []byte) (*Diff, error) {
+	if len(blob) == 0 {
+		return nil, errors.New("empty state history")
+	}
+	if blob[0] != diffVersion {
+		return nil, fmt.Errorf("%w: %d", errUnknownVersion, blob[0])
+	}
+	diff := new(Diff)
+	if err := rlp.DecodeBytes(blob[1:], diff); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
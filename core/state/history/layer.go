@@ -0,0 +1,112 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package history
+
+import (
+	"fmt"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/core/state/snapshot"
+	"github.com/420integrated/go-highcoin/rlp"
+)
+
+// Layer is a read-only snapshot of a historical state, assembled by rewinding
+// a live snapshot layer with a sequence of reverse diffs. Any account or slot
+// modified between the historical and the live state is served from the diffs,
+// everything else from the live layer.
+type Layer struct {
+	root     common.Hash                            // Root hash of the historical state
+	base     snapshot.Snapshot                      // Live snapshot layer the history is rewound from
+	accounts map[common.Hash][]byte                 // Historical values of the accounts modified since
+	storage  map[common.Hash]map[common.Hash][]byte // Historical values of the slots modified since
+}
+
+// NewLayer rewinds the base snapshot layer with the given reverse diffs. The
+// diffs must be ordered from the newest to the oldest, with the newest one
+// reverting the state of the base layer and each subsequent one continuing
+// from the parent state of the previous.
+func NewLayer(base snapshot.Snapshot, diffs []*Diff) (*Layer, error) {
+	layer := &Layer{
+		root:     base.Root(),
+		base:     base,
+		accounts: make(map[common.Hash][]byte),
+		storage:  make(map[common.Hash]map[common.Hash][]byte),
+	}
+	for _, diff := range diffs {
+		if diff.Root != layer.root {
+			return nil, fmt.Errorf("state history gap at block #%d: have root %x, want %x", diff.Number, diff.Root, layer.root)
+		}
+		// Older diffs override newer ones, as they're closer to the target state
+		for _, account := range diff.Accounts {
+			layer.accounts[account.Hash] = account.Blob
+		}
+		for _, storage := range diff.Storages {
+			slots := layer.storage[storage.Account]
+			if slots == nil {
+				slots = make(map[common.Hash][]byte, len(storage.Slots))
+				layer.storage[storage.Account] = slots
+			}
+			for _, slot := range storage.Slots {
+				slots[slot.Hash] = slot.Blob
+			}
+		}
+		layer.root = diff.ParentRoot
+	}
+	return layer, nil
+}
+
+// Root returns the root hash of the historical state.
+func (l *Layer) Root() common.Hash {
+	return l.root
+}
+
+// Account directly retrieves the account associated with a particular hash in
+// the snapshot slim data format.
+func (l *Layer) Account(hash common.Hash) (*snapshot.Account, error) {
+	data, err := l.AccountRLP(hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 { // can be both nil and []byte{}
+		return nil, nil
+	}
+	account := new(snapshot.Account)
+	if err := rlp.DecodeBytes(data, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// AccountRLP directly retrieves the account RLP associated with a particular
+// hash in the snapshot slim data format.
+func (l *Layer) AccountRLP(hash common.Hash) ([]byte, error) {
+	if data, ok := l.accounts[hash]; ok {
+		return data, nil
+	}
+	return l.base.AccountRLP(hash)
+}
+
+// Storage directly retrieves the storage data associated with a particular hash,
+// within a particular account.
+func (l *Layer) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	if slots, ok := l.storage[accountHash]; ok {
+		if data, ok := slots[storageHash]; ok {
+			return data, nil
+		}
+	}
+	return l.base.Storage(accountHash, storageHash)
+}
@@ -0,0 +1,316 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package history
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/core/rawdb"
+	"github.com/420integrated/go-highcoin/core/state/snapshot"
+	"github.com/420integrated/go-highcoin/highdb"
+	"github.com/420integrated/go-highcoin/log"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	// MaxRewind is the maximum number of blocks a live snapshot layer can be
+	// rewound to serve a historical state. The history is not an archive: the
+	// reverse diffs of older blocks can never be read, so they are pruned from
+	// the freezer as the chain progresses.
+	MaxRewind = 8192
+
+	// FreezeThreshold is the number of recent blocks whose reverse diffs are kept
+	// in the key-value store, as they may still be reorged. Older diffs are moved
+	// into the freezer. It must be below MaxRewind, otherwise rewinds would never
+	// reach the frozen diffs.
+	FreezeThreshold = 1024
+
+	// diffCacheLimit is the number of decoded reverse diffs to keep in memory.
+	diffCacheLimit = 1024
+
+	// layerCacheLimit is the number of assembled historical layers to keep in
+	// memory, to serve repeated reads of the same historical states.
+	layerCacheLimit = 16
+)
+
+var (
+	// ErrHistoryUnavailable is returned if the reverse diff of a block required
+	// to serve a historical state is not available.
+	ErrHistoryUnavailable = errors.New("state history unavailable")
+
+	// errRewindTooDeep is returned if a historical state is requested which is
+	// too far behind the live state to be rewound to.
+	errRewindTooDeep = errors.New("state history rewind too deep")
+)
+
+// Store is the persistent state history. The reverse diffs of recent blocks,
+// which may still be reorged, are stored in the key-value store keyed by block
+// number and hash. Once a block is deep enough to be considered final, its diff
+// is moved into an append-only freezer. Diffs of blocks more than MaxRewind
+// below the head are pruned from the freezer, in whole data files.
+type Store struct {
+	db        highdb.Database            // Key-value store holding the recent diffs and the canonical chain
+	freezer   *rawdb.StateHistoryFreezer // Freezer holding the diffs of finalized blocks
+	offset    uint64                     // Number of the first block in the freezer
+	threshold uint64                     // Number of recent blocks not to freeze
+	limit     uint64                     // Number of recent blocks to retain the diffs of
+
+	diffs  *lru.Cache // Decoded reverse diffs, keyed by block hash
+	layers *lru.Cache // Historical layers assembled since the last import, keyed by live root and block number
+
+	lock sync.RWMutex
+}
+
+// NewStore opens the state history, with the freezer placed in the given
+// directory and the recent diffs stored in the given database.
+func NewStore(db highdb.Database, datadir string, namespace string) (*Store, error) {
+	freezer, err := rawdb.NewStateHistoryFreezer(datadir, namespace)
+	if err != nil {
+		return nil, err
+	}
+	diffs, _ := lru.New(diffCacheLimit)
+	layers, _ := lru.New(layerCacheLimit)
+	store := &Store{
+		db:        db,
+		freezer:   freezer,
+		threshold: FreezeThreshold,
+		limit:     MaxRewind,
+		diffs:     diffs,
+		layers:    layers,
+	}
+	if offset := rawdb.ReadStateHistoryOffset(db); offset != nil {
+		store.offset = *offset
+	} else if freezer.Items() > 0 {
+		// The freezer content can't be mapped to blocks, drop it
+		log.Warn("Discarding unindexed state history", "items", freezer.Items())
+		if err := freezer.Truncate(0); err != nil {
+			freezer.Close()
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// Close flushes and terminates the state history freezer.
+func (s *Store) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.freezer.Sync(); err != nil {
+		log.Error("Failed to sync state history", "err", err)
+	}
+	return s.freezer.Close()
+}
+
+// Range returns the range of blocks [first, next) stored in the freezer.
+func (s *Store) Range() (uint64, uint64) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.offset + s.freezer.Tail(), s.offset + s.freezer.Items()
+}
+
+// Write stores the reverse diff of a freshly imported block. It does not need
+// to be canonical yet.
+func (s *Store) Write(hash common.Hash, diff *Diff) error {
+	blob, err := EncodeDiff(diff)
+	if err != nil {
+		return err
+	}
+	rawdb.WriteStateHistory(s.db, diff.Number, hash, blob)
+
+	// The block may change the canonical chain below the live layers, so the
+	// assembled layers can't be trusted anymore
+	s.layers.Purge()
+	return nil
+}
+
+// Freeze moves the reverse diffs of the canonical blocks deep enough below the
+// given head into the freezer, deleting all the recent diffs at those heights,
+// and prunes the diffs too old to be rewound to from the given head.
+func (s *Store) Freeze(head uint64) error {
+	if head < s.threshold {
+		return nil
+	}
+	limit := head - s.threshold
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// An empty freezer is started with the next finalized block, otherwise the
+	// freezer continues from where it left off. If the chain was rewound, or
+	// if the history was not recorded for a long time, restart from the next
+	// finalized block too.
+	next := s.offset + s.freezer.Items()
+	if s.freezer.Items() == 0 || next > limit+1 || (next <= limit && limit-next > s.threshold) {
+		next = limit
+	}
+	for ; next <= limit; next++ {
+		hash := rawdb.ReadCanonicalHash(s.db, next)
+		blob := rawdb.ReadStateHistory(s.db, next, hash)
+		if blob != nil {
+			if err := s.append(next, blob); err != nil {
+				return err
+			}
+		}
+		batch := s.db.NewBatch()
+		for _, hash := range rawdb.ReadStateHistoryHashes(s.db, next) {
+			rawdb.DeleteStateHistory(batch, next, hash)
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+	}
+	return s.prune(head)
+}
+
+// prune discards the frozen reverse diffs which can't be rewound to from the
+// given head anymore. Rewinding to block n needs the diffs above n, so those of
+// the blocks up to head-limit are never read again.
+func (s *Store) prune(head uint64) error {
+	if head < s.limit || head+1-s.limit <= s.offset {
+		return nil
+	}
+	return s.freezer.TruncateTail(head + 1 - s.limit - s.offset)
+}
+
+// append injects a canonical reverse diff into the freezer. If the diff is not
+// continuous with the freezer content, the history is restarted from it.
+func (s *Store) append(number uint64, blob []byte) error {
+	items := s.freezer.Items()
+	switch {
+	case items == 0:
+		rawdb.WriteStateHistoryOffset(s.db, number)
+		s.offset = number
+
+	case number < s.offset:
+		log.Warn("Restarting state history", "old", s.offset, "new", number)
+		if err := s.freezer.Truncate(0); err != nil {
+			return err
+		}
+		rawdb.WriteStateHistoryOffset(s.db, number)
+		s.offset = number
+
+	case number < s.offset+items:
+		// The chain was rewound, drop the stale diffs above
+		if err := s.freezer.Truncate(number - s.offset); err != nil {
+			return err
+		}
+
+	case number > s.offset+items:
+		log.Warn("Gap in state history, restarting", "next", s.offset+items, "number", number)
+		if err := s.freezer.Truncate(0); err != nil {
+			return err
+		}
+		rawdb.WriteStateHistoryOffset(s.db, number)
+		s.offset = number
+	}
+	return s.freezer.Append(number-s.offset, blob)
+}
+
+// Truncate discards the frozen reverse diffs above the given head, to be called
+// when the chain is rewound.
+func (s *Store) Truncate(head uint64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.layers.Purge()
+
+	if head < s.offset {
+		return s.freezer.Truncate(0)
+	}
+	return s.freezer.Truncate(head + 1 - s.offset)
+}
+
+// Read retrieves the reverse diff of a canonical block. The diffs are cached,
+// so the returned one must not be modified.
+func (s *Store) Read(number uint64) (*Diff, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	hash := rawdb.ReadCanonicalHash(s.db, number)
+	if diff, ok := s.diffs.Get(hash); ok {
+		return diff.(*Diff), nil
+	}
+	var blob []byte
+	if items := s.freezer.Items(); number >= s.offset && number < s.offset+items {
+		if number < s.offset+s.freezer.Tail() {
+			return nil, fmt.Errorf("%w: block #%d pruned", ErrHistoryUnavailable, number)
+		}
+		var err error
+		if blob, err = s.freezer.Retrieve(number - s.offset); err != nil {
+			return nil, err
+		}
+	} else {
+		blob = rawdb.ReadStateHistory(s.db, number, hash)
+	}
+	if blob == nil {
+		return nil, fmt.Errorf("%w: block #%d", ErrHistoryUnavailable, number)
+	}
+	diff, err := DecodeDiff(blob)
+	if err != nil {
+		return nil, err
+	}
+	if diff.Number != number {
+		return nil, fmt.Errorf("state history mismatch: have block #%d, want #%d", diff.Number, number)
+	}
+	if hash != (common.Hash{}) {
+		s.diffs.Add(hash, diff)
+	}
+	return diff, nil
+}
+
+// layerKey identifies a historical layer by the live layer it was rewound from
+// and the block it was rewound to.
+type layerKey struct {
+	root   common.Hash
+	number uint64
+}
+
+// Rewind assembles a snapshot of the state at the given block number, by
+// rewinding the live snapshot layer of the canonical block head. Layers are
+// reused until the next block is imported, as long as they were rewound from
+// the same live layer.
+func (s *Store) Rewind(base snapshot.Snapshot, head uint64, number uint64) (*Layer, error) {
+	if number > head {
+		return nil, fmt.Errorf("%w: block #%d above head #%d", ErrHistoryUnavailable, number, head)
+	}
+	if head-number > s.limit {
+		return nil, fmt.Errorf("%w: %d blocks, limit %d", errRewindTooDeep, head-number, s.limit)
+	}
+	key := layerKey{root: base.Root(), number: number}
+	if layer, ok := s.layers.Get(key); ok && layer.(*Layer).base == base {
+		return layer.(*Layer), nil
+	}
+	diffs := make([]*Diff, 0, head-number)
+	for n := head; n > number; n-- {
+		diff, err := s.Read(n)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, diff)
+	}
+	layer, err := NewLayer(base, diffs)
+	if err != nil {
+		return nil, err
+	}
+	s.layers.Add(key, layer)
+	return layer, nil
+}
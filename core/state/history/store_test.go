@@ -0,0 +1,345 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package history
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/core/rawdb"
+	"github.com/420integrated/go-highcoin/core/state/snapshot"
+)
+
+// makeDiff creates a reverse diff of the given block, chained to the diffs of
+// the neighbouring blocks via synthetic state roots.
+func makeDiff(number uint64, fork byte) *Diff {
+	return &Diff{
+		Number:     number,
+		Root:       common.Hash{fork, byte(number)},
+		ParentRoot: common.Hash{fork, byte(number - 1)},
+		Accounts:   []Account{{Hash: common.Hash{0xaa}, Blob: []byte{byte(number)}}},
+		Storages: []Storage{{
+			Account: common.Hash{0xaa},
+			Slots:   []Slot{{Hash: common.Hash{0xbb}, Blob: []byte{fork}}},
+		}},
+	}
+}
+
+func TestDiffEncoding(t *testing.T) {
+	diff := makeDiff(1, 0)
+	blob, err := EncodeDiff(diff)
+	if err != nil {
+		t.Fatalf("failed to encode diff: %v", err)
+	}
+	dec, err := DecodeDiff(blob)
+	if err != nil {
+		t.Fatalf("failed to decode diff: %v", err)
+	}
+	if !reflect.DeepEqual(diff, dec) {
+		t.Fatalf("diff mismatch: have %+v, want %+v", dec, diff)
+	}
+	blob[0] = diffVersion + 1
+	if _, err := DecodeDiff(blob); !errors.Is(err, errUnknownVersion) {
+		t.Fatalf("unknown version accepted: %v", err)
+	}
+}
+
+// Tests that recent diffs are served from the key-value store, and that the
+// canonical ones are moved into the freezer as the chain progresses.
+func TestStoreFreeze(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "statehistory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	db := rawdb.NewMemoryDatabase()
+	store, err := NewStore(db, datadir, "")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	store.threshold = 4
+
+	// Import a chain with a side fork on every block, only the canonical diffs
+	// should end up in the freezer.
+	for number := uint64(1); number <= 10; number++ {
+		canon, side := common.Hash{0x01, byte(number)}, common.Hash{0x02, byte(number)}
+		rawdb.WriteCanonicalHash(db, canon, number)
+
+		if err := store.Write(canon, makeDiff(number, 0x01)); err != nil {
+			t.Fatalf("block #%d: failed to write canonical diff: %v", number, err)
+		}
+		if err := store.Write(side, makeDiff(number, 0x02)); err != nil {
+			t.Fatalf("block #%d: failed to write side diff: %v", number, err)
+		}
+		if err := store.Freeze(number); err != nil {
+			t.Fatalf("block #%d: failed to freeze: %v", number, err)
+		}
+	}
+	if first, next := store.Range(); first != 1 || next != 7 {
+		t.Fatalf("frozen range mismatch: have [%d, %d), want [1, 7)", first, next)
+	}
+	for number := uint64(1); number <= 10; number++ {
+		diff, err := store.Read(number)
+		if err != nil {
+			t.Fatalf("block #%d: failed to read diff: %v", number, err)
+		}
+		if want := makeDiff(number, 0x01); !reflect.DeepEqual(diff, want) {
+			t.Fatalf("block #%d: diff mismatch: have %+v, want %+v", number, diff, want)
+		}
+		frozen := number < 7
+		if hashes := rawdb.ReadStateHistoryHashes(db, number); frozen != (len(hashes) == 0) {
+			t.Errorf("block #%d: recent diffs mismatch: frozen %v, have %d", number, frozen, len(hashes))
+		}
+	}
+	if _, err := store.Read(11); !errors.Is(err, ErrHistoryUnavailable) {
+		t.Fatalf("missing diff error mismatch: have %v, want %v", err, ErrHistoryUnavailable)
+	}
+	// Rewind the chain and make sure the frozen range is retained across restarts
+	if err := store.Truncate(4); err != nil {
+		t.Fatalf("failed to truncate store: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("failed to close store: %v", err)
+	}
+	if store, err = NewStore(db, datadir, ""); err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	if first, next := store.Range(); first != 1 || next != 5 {
+		t.Fatalf("frozen range mismatch: have [%d, %d), want [1, 5)", first, next)
+	}
+}
+
+// Tests that rewinding assembles the historical values from the diffs, and
+// that gaps in the history are detected.
+func TestStoreRewind(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "statehistory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	db := rawdb.NewMemoryDatabase()
+	store, err := NewStore(db, datadir, "")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	for number := uint64(1); number <= 5; number++ {
+		hash := common.Hash{0x01, byte(number)}
+		rawdb.WriteCanonicalHash(db, hash, number)
+		if err := store.Write(hash, makeDiff(number, 0x01)); err != nil {
+			t.Fatalf("block #%d: failed to write diff: %v", number, err)
+		}
+	}
+	base := &testSnapshot{root: common.Hash{0x01, 5}}
+	for number := uint64(0); number < 5; number++ {
+		layer, err := store.Rewind(base, 5, number)
+		if err != nil {
+			t.Fatalf("block #%d: failed to rewind: %v", number, err)
+		}
+		if want := (common.Hash{0x01, byte(number)}); layer.Root() != want {
+			t.Errorf("block #%d: root mismatch: have %x, want %x", number, layer.Root(), want)
+		}
+		if blob, _ := layer.AccountRLP(common.Hash{0xaa}); !reflect.DeepEqual(blob, []byte{byte(number + 1)}) {
+			t.Errorf("block #%d: account mismatch: have %x, want %x", number, blob, []byte{byte(number + 1)})
+		}
+		if blob, _ := layer.AccountRLP(common.Hash{0xcc}); blob != nil {
+			t.Errorf("block #%d: unmodified account mismatch: have %x, want nil", number, blob)
+		}
+	}
+	// Replace a diff with one from a different chain and ensure it's detected
+	rawdb.WriteCanonicalHash(db, common.Hash{0x02, 3}, 3)
+	if err := store.Write(common.Hash{0x02, 3}, makeDiff(3, 0x02)); err != nil {
+		t.Fatalf("failed to write forked diff: %v", err)
+	}
+	if _, err := store.Rewind(base, 5, 1); err == nil {
+		t.Fatalf("rewind succeeded across a history gap")
+	}
+}
+
+// Tests that rewinds deep enough to need the frozen diffs read them from the
+// freezer, continuing seamlessly from the recent diffs.
+func TestStoreRewindFrozen(t *testing.T) {
+	if FreezeThreshold >= MaxRewind {
+		t.Fatalf("frozen diffs unreachable: freeze threshold %d, rewind limit %d", FreezeThreshold, MaxRewind)
+	}
+	datadir, err := ioutil.TempDir("", "statehistory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	db := rawdb.NewMemoryDatabase()
+	store, err := NewStore(db, datadir, "")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	store.threshold = 4
+
+	for number := uint64(1); number <= 10; number++ {
+		hash := common.Hash{0x01, byte(number)}
+		rawdb.WriteCanonicalHash(db, hash, number)
+		if err := store.Write(hash, makeDiff(number, 0x01)); err != nil {
+			t.Fatalf("block #%d: failed to write diff: %v", number, err)
+		}
+		if err := store.Freeze(number); err != nil {
+			t.Fatalf("block #%d: failed to freeze: %v", number, err)
+		}
+	}
+	first, next := store.Range()
+	if first != 1 || next != 7 {
+		t.Fatalf("frozen range mismatch: have [%d, %d), want [1, 7)", first, next)
+	}
+	for number := first; number < next; number++ {
+		if hashes := rawdb.ReadStateHistoryHashes(db, number); len(hashes) != 0 {
+			t.Fatalf("block #%d: frozen diff left in key-value store", number)
+		}
+	}
+	base := &testSnapshot{root: common.Hash{0x01, 10}}
+	layer, err := store.Rewind(base, 10, 0)
+	if err != nil {
+		t.Fatalf("failed to rewind across freezer: %v", err)
+	}
+	if want := (common.Hash{0x01, 0}); layer.Root() != want {
+		t.Errorf("root mismatch: have %x, want %x", layer.Root(), want)
+	}
+	if blob, _ := layer.AccountRLP(common.Hash{0xaa}); !reflect.DeepEqual(blob, []byte{1}) {
+		t.Errorf("account mismatch: have %x, want %x", blob, []byte{1})
+	}
+}
+
+// testSnapshot is a snapshot layer without any content.
+type testSnapshot struct {
+	root common.Hash
+}
+
+func (s *testSnapshot) Root() common.Hash { return s.root }
+
+func (s *testSnapshot) Account(hash common.Hash) (*snapshot.Account, error) { return nil, nil }
+
+func (s *testSnapshot) AccountRLP(hash common.Hash) ([]byte, error) { return nil, nil }
+
+func (s *testSnapshot) Storage(accountHash, storageHash common.Hash) ([]byte, error) { return nil, nil }
+
+// Tests that repeated rewinds to the same block reuse the assembled layer until
+// a new block is imported.
+func TestStoreRewindCache(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "statehistory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	db := rawdb.NewMemoryDatabase()
+	store, err := NewStore(db, datadir, "")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	for number := uint64(1); number <= 5; number++ {
+		hash := common.Hash{0x01, byte(number)}
+		rawdb.WriteCanonicalHash(db, hash, number)
+		if err := store.Write(hash, makeDiff(number, 0x01)); err != nil {
+			t.Fatalf("block #%d: failed to write diff: %v", number, err)
+		}
+	}
+	base := &testSnapshot{root: common.Hash{0x01, 5}}
+	first, err := store.Rewind(base, 5, 2)
+	if err != nil {
+		t.Fatalf("failed to rewind: %v", err)
+	}
+	if second, _ := store.Rewind(base, 5, 2); second != first {
+		t.Errorf("repeated rewind not served from cache")
+	}
+	// A different live layer with the same root must not reuse the layer
+	if other, _ := store.Rewind(&testSnapshot{root: base.root}, 5, 2); other == first {
+		t.Errorf("rewind of a different live layer served from cache")
+	}
+	// The diffs read from the database are cached too, deleting them from the
+	// key-value store must not break a rewind after the layers are dropped
+	batch := db.NewBatch()
+	for number := uint64(3); number <= 5; number++ {
+		rawdb.DeleteStateHistory(batch, number, common.Hash{0x01, byte(number)})
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("failed to delete diffs: %v", err)
+	}
+	if err := store.Truncate(5); err != nil {
+		t.Fatalf("failed to truncate store: %v", err)
+	}
+	layer, err := store.Rewind(base, 5, 2)
+	if err != nil {
+		t.Fatalf("failed to rewind from cached diffs: %v", err)
+	}
+	if layer == first {
+		t.Errorf("layer reused after truncation")
+	}
+	if want := (common.Hash{0x01, 2}); layer.Root() != want {
+		t.Errorf("root mismatch: have %x, want %x", layer.Root(), want)
+	}
+}
+
+// Tests that the diffs too old to be rewound to are pruned as the chain
+// progresses, while the recent ones remain rewindable.
+func TestStorePrune(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "statehistory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	db := rawdb.NewMemoryDatabase()
+	store, err := NewStore(db, datadir, "")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	store.threshold, store.limit = 2, 4
+
+	for number := uint64(1); number <= 20; number++ {
+		hash := common.Hash{0x01, byte(number)}
+		rawdb.WriteCanonicalHash(db, hash, number)
+		if err := store.Write(hash, makeDiff(number, 0x01)); err != nil {
+			t.Fatalf("block #%d: failed to write diff: %v", number, err)
+		}
+		if err := store.Freeze(number); err != nil {
+			t.Fatalf("block #%d: failed to freeze: %v", number, err)
+		}
+	}
+	base := &testSnapshot{root: common.Hash{0x01, 20}}
+	if _, err := store.Rewind(base, 20, 16); err != nil {
+		t.Fatalf("failed to rewind within the limit: %v", err)
+	}
+	if _, err := store.Rewind(base, 20, 15); !errors.Is(err, errRewindTooDeep) {
+		t.Fatalf("rewind beyond the limit error mismatch: have %v, want %v", err, errRewindTooDeep)
+	}
+	// Pruning drops whole freezer files only, so the single data file written
+	// here is retained; the diffs themselves must stay intact
+	if first, next := store.Range(); first != 1 || next != 19 {
+		t.Fatalf("frozen range mismatch: have [%d, %d), want [1, 19)", first, next)
+	}
+}
@@ -17,9 +17,11 @@
 package state
 
 import (
+	"bytes"
 	"math/big"
 
 	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/core/tracing"
 )
 
 // journalEntry is a modification entry in the state change journal that can be
@@ -164,6 +166,7 @@ func (ch suicideChange) revert(s *StateDB) {
 	obj := s.getStateObject(*ch.account)
 	if obj != nil {
 		obj.suicided = ch.prev
+		s.balanceChanged(*ch.account, obj.Balance(), ch.prevbalance, tracing.BalanceChangeRevert)
 		obj.setBalance(ch.prevbalance)
 	}
 }
@@ -182,7 +185,9 @@ func (ch touchChange) dirtied() *common.Address {
 }
 
 func (ch balanceChange) revert(s *StateDB) {
-	s.getStateObject(*ch.account).setBalance(ch.prev)
+	obj := s.getStateObject(*ch.account)
+	s.balanceChanged(*ch.account, obj.Balance(), ch.prev, tracing.BalanceChangeRevert)
+	obj.setBalance(ch.prev)
 }
 
 func (ch balanceChange) dirtied() *common.Address {
@@ -190,7 +195,11 @@ func (ch balanceChange) dirtied() *common.Address {
 }
 
 func (ch nonceChange) revert(s *StateDB) {
-	s.getStateObject(*ch.account).setNonce(ch.prev)
+	obj := s.getStateObject(*ch.account)
+	if s.hooks != nil && obj.Nonce() != ch.prev {
+		s.hooks.OnNonceChange(*ch.account, obj.Nonce(), ch.prev)
+	}
+	obj.setNonce(ch.prev)
 }
 
 func (ch nonceChange) dirtied() *common.Address {
@@ -198,7 +207,11 @@ func (ch nonceChange) dirtied() *common.Address {
 }
 
 func (ch codeChange) revert(s *StateDB) {
-	s.getStateObject(*ch.account).setCode(common.BytesToHash(ch.prevhash), ch.prevcode)
+	obj := s.getStateObject(*ch.account)
+	if s.hooks != nil && !bytes.Equal(obj.CodeHash(), ch.prevhash) {
+		s.hooks.OnCodeChange(*ch.account, common.BytesToHash(obj.CodeHash()), obj.Code(s.db), common.BytesToHash(ch.prevhash), ch.prevcode)
+	}
+	obj.setCode(common.BytesToHash(ch.prevhash), ch.prevcode)
 }
 
 func (ch codeChange) dirtied() *common.Address {
@@ -206,7 +219,11 @@ func (ch codeChange) dirtied() *common.Address {
 }
 
 func (ch storageChange) revert(s *StateDB) {
-	s.getStateObject(*ch.account).setState(ch.key, ch.prevalue)
+	obj := s.getStateObject(*ch.account)
+	if s.hooks != nil {
+		s.hooks.OnStorageChange(*ch.account, ch.key, obj.GetState(s.db, ch.key), ch.prevalue)
+	}
+	obj.setState(ch.key, ch.prevalue)
 }
 
 func (ch storageChange) dirtied() *common.Address {
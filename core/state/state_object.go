@@ -235,6 +235,11 @@ func (s *stateObject) GetCommittedState(db Database, key common.Hash) common.Has
 			return common.Hash{}
 		}
 		enc, err = s.db.snap.Storage(s.addrHash, crypto.Keccak256Hash(key.Bytes()))
+		if err != nil && s.db.historic {
+			// Historic states have no trie to fall back to, fail the read
+			s.db.setError(fmt.Errorf("historic storage (%x) error: %v", s.address, err))
+			return common.Hash{}
+		}
 	}
 	// If snapshot unavailable or reading from it failed, load from the database
 	if s.db.snap == nil || err != nil {
@@ -26,7 +26,9 @@ import (
 
 	"github.com/420integrated/go-highcoin/common"
 	"github.com/420integrated/go-highcoin/core/rawdb"
+	"github.com/420integrated/go-highcoin/core/state/history"
 	"github.com/420integrated/go-highcoin/core/state/snapshot"
+	"github.com/420integrated/go-highcoin/core/tracing"
 	"github.com/420integrated/go-highcoin/core/types"
 	"github.com/420integrated/go-highcoin/crypto"
 	"github.com/420integrated/go-highcoin/log"
@@ -41,6 +43,10 @@ type revision struct {
 }
 
 var (
+	// errHistoricProof is returned if a Merkle proof is requested from a state
+	// served from the state history, without the backing tries.
+	errHistoricProof = errors.New("proofs unavailable for historical state")
+
 	// emptyRoot is the known root hash of an empty trie.
 	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
 )
@@ -74,6 +80,12 @@ type StateDB struct {
 	snapAccounts  map[common.Hash][]byte
 	snapStorage   map[common.Hash]map[common.Hash][]byte
 
+	// Reverse diff of the last commit for the state history
+	historyRecord bool          // Whether to assemble the reverse diff on commit
+	historyDiff   *history.Diff // Reverse diff assembled by the last commit
+	historyErr    error         // Error encountered while assembling the reverse diff
+	historic      bool          // Whether the state is served from the state history without tries
+
 	// This map holds 'live' objects, which will get modified while processing a state transition.
 	stateObjects        map[common.Address]*stateObject
 	stateObjectsPending map[common.Address]struct{} // State objects finalized but not yet written to the trie
@@ -105,6 +117,9 @@ type StateDB struct {
 	validRevisions []revision
 	nextRevisionId int
 
+	// Optional hooks notified of every state change, e.g. by tracers
+	hooks tracing.StateHooks
+
 	// Measurements gathered during execution for debugging purposes
 	AccountReads         time.Duration
 	AccountHashes        time.Duration
@@ -149,6 +164,48 @@ func New(root common.Hash, db Database, snaps *snapshot.Tree) (*StateDB, error)
 	return sdb, nil
 }
 
+// NewHistoric creates a read-only view of a historical state, served entirely
+// from the given snapshot layer, without needing the tries of the state to be
+// available. The state can be modified, e.g. to execute calls on top of it, but
+// it can't be committed and no Merkle proofs can be generated from it.
+func NewHistoric(root common.Hash, db Database, snap snapshot.Snapshot) (*StateDB, error) {
+	tr, err := db.OpenTrie(common.Hash{})
+	if err != nil {
+		return nil, err
+	}
+	return &StateDB{
+		db:                  db,
+		trie:                tr,
+		originalRoot:        root,
+		snap:                snap,
+		snapDestructs:       make(map[common.Hash]struct{}),
+		snapAccounts:        make(map[common.Hash][]byte),
+		snapStorage:         make(map[common.Hash]map[common.Hash][]byte),
+		historic:            true,
+		stateObjects:        make(map[common.Address]*stateObject),
+		stateObjectsPending: make(map[common.Address]struct{}),
+		stateObjectsDirty:   make(map[common.Address]struct{}),
+		logs:                make(map[common.Hash][]*types.Log),
+		preimages:           make(map[common.Hash][]byte),
+		journal:             newJournal(),
+		accessList:          newAccessList(),
+		hasher:              crypto.NewKeccakState(),
+	}, nil
+}
+
+// RecordHistory instructs the state to assemble the reverse diff of the next
+// commit for the state history. Recording requires the state to be backed by
+// a snapshot.
+func (s *StateDB) RecordHistory() {
+	s.historyRecord = true
+}
+
+// History returns the reverse diff assembled by the last commit, if recording
+// was requested. Nil is returned if the state was not backed by a snapshot.
+func (s *StateDB) History() (*history.Diff, error) {
+	return s.historyDiff, s.historyErr
+}
+
 // StartPrefetcher initializes a new trie prefetcher to pull in nodes from the
 // state trie concurrently while the state is mutated so that when we reach the
 // commit phase, most of the needed data is already hot.
@@ -182,6 +239,12 @@ func (s *StateDB) Error() error {
 	return s.dbErr
 }
 
+// SetHooks sets the hooks notified of the state changes applied from now on.
+// Nil disables the notifications. The hooks are not inherited by copies.
+func (s *StateDB) SetHooks(hooks tracing.StateHooks) {
+	s.hooks = hooks
+}
+
 func (s *StateDB) AddLog(log *types.Log) {
 	s.journal.append(addLogChange{txhash: s.thash})
 
@@ -191,6 +254,10 @@ func (s *StateDB) AddLog(log *types.Log) {
 	log.Index = s.logSize
 	s.logs[s.thash] = append(s.logs[s.thash], log)
 	s.logSize++
+
+	if s.hooks != nil {
+		s.hooks.OnLog(log)
+	}
 }
 
 func (s *StateDB) GetLogs(hash common.Hash) []*types.Log {
@@ -317,6 +384,9 @@ func (s *StateDB) GetProof(addr common.Address) ([][]byte, error) {
 
 // GetProofByHash returns the Merkle proof for a given account.
 func (s *StateDB) GetProofByHash(addrHash common.Hash) ([][]byte, error) {
+	if s.historic {
+		return nil, errHistoricProof
+	}
 	var proof proofList
 	err := s.trie.Prove(addrHash[:], 0, &proof)
 	return proof, err
@@ -324,6 +394,9 @@ func (s *StateDB) GetProofByHash(addrHash common.Hash) ([][]byte, error) {
 
 // GetStorageProof returns the Merkle proof for given storage slot.
 func (s *StateDB) GetStorageProof(a common.Address, key common.Hash) ([][]byte, error) {
+	if s.historic {
+		return nil, errHistoricProof
+	}
 	var proof proofList
 	trie := s.StorageTrie(a)
 	if trie == nil {
@@ -335,6 +408,9 @@ func (s *StateDB) GetStorageProof(a common.Address, key common.Hash) ([][]byte,
 
 // GetStorageProofByHash returns the Merkle proof for given storage slot.
 func (s *StateDB) GetStorageProofByHash(a common.Address, key common.Hash) ([][]byte, error) {
+	if s.historic {
+		return nil, errHistoricProof
+	}
 	var proof proofList
 	trie := s.StorageTrie(a)
 	if trie == nil {
@@ -384,45 +460,93 @@ func (s *StateDB) HasSuicided(addr common.Address) bool {
 
 // AddBalance adds amount to the account associated with addr.
 func (s *StateDB) AddBalance(addr common.Address, amount *big.Int) {
+	s.AddBalanceWithReason(addr, amount, tracing.BalanceChangeUnspecified)
+}
+
+// AddBalanceWithReason adds amount to the account associated with addr, reporting
+// the change to the hooks with the given reason.
+func (s *StateDB) AddBalanceWithReason(addr common.Address, amount *big.Int, reason tracing.BalanceChangeReason) {
 	stateObject := s.GetOrNewStateObject(addr)
 	if stateObject != nil {
+		prev := stateObject.Balance()
 		stateObject.AddBalance(amount)
+		s.balanceChanged(addr, prev, stateObject.Balance(), reason)
 	}
 }
 
 // SubBalance subtracts amount from the account associated with addr.
 func (s *StateDB) SubBalance(addr common.Address, amount *big.Int) {
+	s.SubBalanceWithReason(addr, amount, tracing.BalanceChangeUnspecified)
+}
+
+// SubBalanceWithReason subtracts amount from the account associated with addr,
+// reporting the change to the hooks with the given reason.
+func (s *StateDB) SubBalanceWithReason(addr common.Address, amount *big.Int, reason tracing.BalanceChangeReason) {
 	stateObject := s.GetOrNewStateObject(addr)
 	if stateObject != nil {
+		prev := stateObject.Balance()
 		stateObject.SubBalance(amount)
+		s.balanceChanged(addr, prev, stateObject.Balance(), reason)
 	}
 }
 
 func (s *StateDB) SetBalance(addr common.Address, amount *big.Int) {
 	stateObject := s.GetOrNewStateObject(addr)
 	if stateObject != nil {
+		prev := stateObject.Balance()
 		stateObject.SetBalance(amount)
+		s.balanceChanged(addr, prev, stateObject.Balance(), tracing.BalanceChangeUnspecified)
 	}
 }
 
 func (s *StateDB) SetNonce(addr common.Address, nonce uint64) {
 	stateObject := s.GetOrNewStateObject(addr)
 	if stateObject != nil {
+		prev := stateObject.Nonce()
 		stateObject.SetNonce(nonce)
+		if s.hooks != nil && prev != nonce {
+			s.hooks.OnNonceChange(addr, prev, nonce)
+		}
 	}
 }
 
 func (s *StateDB) SetCode(addr common.Address, code []byte) {
 	stateObject := s.GetOrNewStateObject(addr)
 	if stateObject != nil {
-		stateObject.SetCode(crypto.Keccak256Hash(code), code)
+		var (
+			prevHash common.Hash
+			prevCode []byte
+		)
+		if s.hooks != nil {
+			prevHash, prevCode = common.BytesToHash(stateObject.CodeHash()), stateObject.Code(s.db)
+		}
+		codeHash := crypto.Keccak256Hash(code)
+		stateObject.SetCode(codeHash, code)
+		if s.hooks != nil && prevHash != codeHash {
+			s.hooks.OnCodeChange(addr, prevHash, prevCode, codeHash, code)
+		}
 	}
 }
 
 func (s *StateDB) SetState(addr common.Address, key, value common.Hash) {
 	stateObject := s.GetOrNewStateObject(addr)
 	if stateObject != nil {
+		var prev common.Hash
+		if s.hooks != nil {
+			prev = stateObject.GetState(s.db, key)
+		}
 		stateObject.SetState(s.db, key, value)
+		if s.hooks != nil && prev != value {
+			s.hooks.OnStorageChange(addr, key, prev, value)
+		}
+	}
+}
+
+// balanceChanged reports a balance change of the given account to the hooks.
+// Balances are replaced on update, never modified in place, so prev is retained.
+func (s *StateDB) balanceChanged(addr common.Address, prev, balance *big.Int, reason tracing.BalanceChangeReason) {
+	if s.hooks != nil && prev.Cmp(balance) != 0 {
+		s.hooks.OnBalanceChange(addr, new(big.Int).Set(prev), new(big.Int).Set(balance), reason)
 	}
 }
 
@@ -445,13 +569,15 @@ func (s *StateDB) Suicide(addr common.Address) bool {
 	if stateObject == nil {
 		return false
 	}
+	prevbalance := new(big.Int).Set(stateObject.Balance())
 	s.journal.append(suicideChange{
 		account:     &addr,
 		prev:        stateObject.suicided,
-		prevbalance: new(big.Int).Set(stateObject.Balance()),
+		prevbalance: prevbalance,
 	})
 	stateObject.markSuicided()
 	stateObject.data.Balance = new(big.Int)
+	s.balanceChanged(addr, prevbalance, stateObject.data.Balance, tracing.BalanceChangeSelfdestruct)
 
 	return true
 }
@@ -528,7 +654,13 @@ func (s *StateDB) getDeletedStateObject(addr common.Address) *stateObject {
 			defer func(start time.Time) { s.SnapshotAccountReads += time.Since(start) }(time.Now())
 		}
 		var acc *snapshot.Account
-		if acc, err = s.snap.Account(crypto.HashData(s.hasher, addr.Bytes())); err == nil {
+		acc, err = s.snap.Account(crypto.HashData(s.hasher, addr.Bytes()))
+		if err != nil && s.historic {
+			// Historic states have no trie to fall back to, fail the read
+			s.setError(fmt.Errorf("getDeleteStateObject (%x) error: %v", addr.Bytes(), err))
+			return nil
+		}
+		if err == nil {
 			if acc == nil {
 				return nil
 			}
@@ -672,6 +804,7 @@ func (s *StateDB) Copy() *StateDB {
 		preimages:           make(map[common.Hash][]byte, len(s.preimages)),
 		journal:             newJournal(),
 		hasher:              crypto.NewKeccakState(),
+		historic:            s.historic,
 	}
 	// Copy the dirty states, logs, and preimages
 	for addr := range s.journal.dirties {
@@ -728,7 +861,7 @@ func (s *StateDB) Copy() *StateDB {
 	if s.prefetcher != nil {
 		state.prefetcher = s.prefetcher.copy()
 	}
-	if s.snaps != nil {
+	if s.snaps != nil || s.historic {
 		// In order for the miner to be able to use and make additions
 		// to the snapshot tree, we need to copy that aswell.
 		// Otherwise, any block mined by ourselves will cause gaps in the tree,
@@ -914,6 +1047,9 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 	if s.dbErr != nil {
 		return common.Hash{}, fmt.Errorf("commit aborted due to earlier error: %v", s.dbErr)
 	}
+	if s.historic {
+		return common.Hash{}, errors.New("historical state can't be committed")
+	}
 	// Finalize any pending changes and merge everything into the tries
 	s.IntermediateRoot(deleteEmptyObjects)
 
@@ -967,6 +1103,9 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 		}
 		// Only update if there's a state transition (skip empty Clique blocks)
 		if parent := s.snap.Root(); parent != root {
+			if s.historyRecord {
+				s.historyDiff, s.historyErr = history.NewDiff(s.snaps, s.snap, root, s.snapDestructs, s.snapAccounts, s.snapStorage)
+			}
 			if err := s.snaps.Update(root, parent, s.snapDestructs, s.snapAccounts, s.snapStorage); err != nil {
 				log.Warn("Failed to update snapshot tree", "from", parent, "to", root, "err", err)
 			}
@@ -977,6 +1116,8 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 			if err := s.snaps.Cap(root, 128); err != nil {
 				log.Warn("Failed to cap snapshot tree", "root", root, "layers", 128, "err", err)
 			}
+		} else if s.historyRecord {
+			s.historyDiff = &history.Diff{Root: root, ParentRoot: root}
 		}
 		s.snap, s.snapDestructs, s.snapAccounts, s.snapStorage = nil, nil, nil, nil
 	}
@@ -19,6 +19,7 @@ package state
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
@@ -31,7 +32,9 @@ import (
 
 	"github.com/420integrated/go-highcoin/common"
 	"github.com/420integrated/go-highcoin/core/rawdb"
+	"github.com/420integrated/go-highcoin/core/state/snapshot"
 	"github.com/420integrated/go-highcoin/core/types"
+	"github.com/420integrated/go-highcoin/crypto"
 )
 
 // Tests that updating a state trie does not leak any database writes prior to
@@ -915,3 +918,53 @@ func TestStateDBAccessList(t *testing.T) {
 		t.Fatalf("expected empty, got %d", got)
 	}
 }
+
+// failingSnapshot is a snapshot layer holding a single account, failing all
+// other account and all storage reads.
+type failingSnapshot struct {
+	hash    common.Hash
+	account *snapshot.Account
+}
+
+func (s *failingSnapshot) Root() common.Hash { return common.Hash{} }
+
+func (s *failingSnapshot) Account(hash common.Hash) (*snapshot.Account, error) {
+	if hash == s.hash {
+		return s.account, nil
+	}
+	return nil, errors.New("account unavailable")
+}
+
+func (s *failingSnapshot) AccountRLP(hash common.Hash) ([]byte, error) {
+	return nil, errors.New("account unavailable")
+}
+
+func (s *failingSnapshot) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	return nil, errors.New("storage unavailable")
+}
+
+// Tests that failing reads of a historical state are reported instead of being
+// served from an empty trie.
+func TestHistoricReadError(t *testing.T) {
+	addr := common.HexToAddress("0xaa")
+	snap := &failingSnapshot{
+		hash:    crypto.Keccak256Hash(addr.Bytes()),
+		account: &snapshot.Account{Balance: big.NewInt(1), CodeHash: emptyCodeHash},
+	}
+	state, err := NewHistoric(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()), snap)
+	if err != nil {
+		t.Fatalf("failed to create historic state: %v", err)
+	}
+	if balance := state.GetBalance(addr); balance.Cmp(big.NewInt(1)) != 0 || state.Error() != nil {
+		t.Fatalf("account read mismatch: balance %v, error %v", balance, state.Error())
+	}
+	state.GetState(addr, common.Hash{})
+	if state.Error() == nil {
+		t.Errorf("failed storage read not reported")
+	}
+	state, _ = NewHistoric(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()), snap)
+	state.GetBalance(common.HexToAddress("0xbb"))
+	if state.Error() == nil {
+		t.Errorf("failed account read not reported")
+	}
+}
@@ -48,6 +48,9 @@ func newStatePrefetcher(config *params.ChainConfig, bc *BlockChain, engine conse
 // the transaction messages using the statedb, but any changes are discarded. The
 // only goal is to pre-cache transaction signatures and state trie nodes.
 func (p *statePrefetcher) Prefetch(block *types.Block, statedb *state.StateDB, cfg vm.Config, interrupt *uint32) {
+	// Tracers must only observe the actual processing of the block
+	cfg.Debug, cfg.Tracer = false, nil
+
 	var (
 		header       = block.Header()
 		smokepool      = new(SmokePool).AddSmoke(block.SmokeLimit())
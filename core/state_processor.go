@@ -29,13 +29,22 @@ import (
 	"github.com/420integrated/go-highcoin/params"
 )
 
+// processorChain is the chain access needed to process a block: ancestor headers
+// for the BLOCKHASH opcode and the consensus engine to finalize the block with.
+type processorChain interface {
+	consensus.ChainHeaderReader
+
+	// Engine retrieves the chain's consensus engine.
+	Engine() consensus.Engine
+}
+
 // StateProcessor is a basic Processor, which takes care of transitioning
 // state from one point to another.
 //
 // StateProcessor implements Processor.
 type StateProcessor struct {
 	config *params.ChainConfig // Chain configuration options
-	bc     *BlockChain         // Canonical block chain
+	bc     processorChain      // Canonical block chain
 	engine consensus.Engine    // Consensus engine used for block rewards
 }
 
@@ -63,12 +72,14 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		allLogs  []*types.Log
 		gp       = new(SmokePool).AddSmoke(block.SmokeLimit())
 	)
+	// Create the EVM first, so a tracer observes the hard-fork mutations as well
+	blockContext := NewEVMBlockContext(header, p.bc, nil)
+	vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, p.config, cfg)
+
 	// Mutate the block and state according to any hard-fork specs
 	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
 		misc.ApplyDAOHardFork(statedb)
 	}
-	blockContext := NewEVMBlockContext(header, p.bc, nil)
-	vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, p.config, cfg)
 	// Iterate over and process the individual transactions
 	for i, tx := range block.Transactions() {
 		msg, err := tx.AsMessage(types.MakeSigner(p.config, header.Number))
@@ -19,11 +19,13 @@ package core
 import (
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/420integrated/go-highcoin/common"
 	"github.com/420integrated/go-highcoin/consensus"
 	"github.com/420integrated/go-highcoin/consensus/ethash"
 	"github.com/420integrated/go-highcoin/core/rawdb"
+	"github.com/420integrated/go-highcoin/core/tracing"
 	"github.com/420integrated/go-highcoin/core/types"
 	"github.com/420integrated/go-highcoin/core/vm"
 	"github.com/420integrated/go-highcoin/crypto"
@@ -111,6 +113,162 @@ func TestStateProcessorErrors(t *testing.T) {
 	}
 }
 
+// hookRecorder is a state tracer recording the reported state changes.
+type hookRecorder struct {
+	balances map[common.Address][]*big.Int
+	nonces   map[common.Address][]uint64
+	storage  map[common.Address]map[common.Hash][]common.Hash
+	reasons  map[tracing.BalanceChangeReason]int
+	codes    int
+	logs     int
+	txs      int
+	failed   int
+}
+
+func newHookRecorder() *hookRecorder {
+	return &hookRecorder{
+		balances: make(map[common.Address][]*big.Int),
+		nonces:   make(map[common.Address][]uint64),
+		storage:  make(map[common.Address]map[common.Hash][]common.Hash),
+		reasons:  make(map[tracing.BalanceChangeReason]int),
+	}
+}
+
+func (r *hookRecorder) CaptureStart(from common.Address, to common.Address, create bool, input []byte, smoke uint64, value *big.Int) error {
+	return nil
+}
+
+func (r *hookRecorder) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, smoke, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, rData []byte, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+func (r *hookRecorder) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, smoke, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+func (r *hookRecorder) CaptureEnd(output []byte, smokeUsed uint64, t time.Duration, err error) error {
+	return nil
+}
+
+func (r *hookRecorder) CaptureTxStart(env *vm.EVM, from common.Address, to *common.Address, smoke uint64, value *big.Int) {
+	r.txs++
+}
+
+func (r *hookRecorder) CaptureTxEnd(smokeUsed uint64, err error) {
+	if err != nil {
+		r.failed++
+	}
+}
+
+func (r *hookRecorder) OnBalanceChange(addr common.Address, prev, new *big.Int, reason tracing.BalanceChangeReason) {
+	if len(r.balances[addr]) == 0 {
+		r.balances[addr] = append(r.balances[addr], prev)
+	}
+	r.balances[addr] = append(r.balances[addr], new)
+	r.reasons[reason]++
+}
+
+func (r *hookRecorder) OnNonceChange(addr common.Address, prev, new uint64) {
+	if len(r.nonces[addr]) == 0 {
+		r.nonces[addr] = append(r.nonces[addr], prev)
+	}
+	r.nonces[addr] = append(r.nonces[addr], new)
+}
+
+func (r *hookRecorder) OnCodeChange(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte) {
+	r.codes++
+}
+
+func (r *hookRecorder) OnStorageChange(addr common.Address, slot common.Hash, prev, new common.Hash) {
+	if r.storage[addr] == nil {
+		r.storage[addr] = make(map[common.Hash][]common.Hash)
+	}
+	if len(r.storage[addr][slot]) == 0 {
+		r.storage[addr][slot] = append(r.storage[addr][slot], prev)
+	}
+	r.storage[addr][slot] = append(r.storage[addr][slot], new)
+}
+
+func (r *hookRecorder) OnLog(log *types.Log) {
+	r.logs++
+}
+
+// Tests that a state tracer attached to the chain receives every state change
+// made by the processed blocks, with the right reasons, and that replaying the
+// reported changes yields the post state.
+func TestStateProcessorHooks(t *testing.T) {
+	var (
+		key, _   = crypto.GenerateKey()
+		addr     = crypto.PubkeyToAddress(key.PublicKey)
+		coinbase = common.Address{0xc0}
+		signer   = types.HomesteadSigner{}
+		db       = rawdb.NewMemoryDatabase()
+		gspec    = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				addr: {Balance: big.NewInt(1000000000000000)},
+				// Stores one in slot zero and emits a log
+				common.Address{0xaa}: {Balance: new(big.Int), Code: []byte{byte(vm.PUSH1), 0x01, byte(vm.PUSH1), 0x00, byte(vm.SSTORE), byte(vm.PUSH1), 0x00, byte(vm.DUP1), byte(vm.LOG0), byte(vm.STOP)}},
+				// Stores one in slot zero and reverts
+				common.Address{0xbb}: {Balance: new(big.Int), Code: []byte{byte(vm.PUSH1), 0x01, byte(vm.PUSH1), 0x00, byte(vm.SSTORE), byte(vm.PUSH1), 0x00, byte(vm.DUP1), byte(vm.REVERT)}},
+			},
+		}
+		genesis  = gspec.MustCommit(db)
+		recorder = newHookRecorder()
+	)
+	chaindb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(chaindb)
+
+	blockchain, _ := NewBlockChain(chaindb, nil, gspec.Config, ethash.NewFaker(), vm.Config{Debug: true, Tracer: recorder}, nil, nil)
+	defer blockchain.Stop()
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 1, func(i int, gen *BlockGen) {
+		gen.SetCoinbase(coinbase)
+		for _, to := range []common.Address{{0x01}, {0xaa}, {0xbb}} {
+			tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(addr), to, big.NewInt(1000), 100000, big.NewInt(1), nil), signer, key)
+			gen.AddTx(tx)
+		}
+		// Deploy a contract returning a single STOP as its code
+		tx, _ := types.SignTx(types.NewContractCreation(gen.TxNonce(addr), new(big.Int), 100000, big.NewInt(1), []byte{byte(vm.PUSH1), 0x00, byte(vm.DUP1), byte(vm.MSTORE8), byte(vm.PUSH1), 0x01, byte(vm.PUSH1), 0x00, byte(vm.RETURN)}), signer, key)
+		gen.AddTx(tx)
+	})
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+	if recorder.txs != 4 || recorder.failed != 1 {
+		t.Errorf("transaction count mismatch: have %d (%d failed), want 4 (1 failed)", recorder.txs, recorder.failed)
+	}
+	if recorder.logs != 1 || recorder.codes != 1 {
+		t.Errorf("log or code change count mismatch: have %d logs, %d codes, want 1 each", recorder.logs, recorder.codes)
+	}
+	for _, reason := range []tracing.BalanceChangeReason{tracing.BalanceChangeTransfer, tracing.BalanceChangeFee, tracing.BalanceChangeRefund, tracing.BalanceChangeReward, tracing.BalanceChangeRevert} {
+		if recorder.reasons[reason] == 0 {
+			t.Errorf("no balance changes reported for reason %v", reason)
+		}
+	}
+	// Ensure the reported changes are consistent with the pre and post states
+	pre, _ := blockchain.StateAt(genesis.Root())
+	post, _ := blockchain.StateAt(blocks[0].Root())
+	for addr, balances := range recorder.balances {
+		if balances[0].Cmp(pre.GetBalance(addr)) != 0 || balances[len(balances)-1].Cmp(post.GetBalance(addr)) != 0 {
+			t.Errorf("balance changes of %x mismatch states: %v", addr, balances)
+		}
+	}
+	if len(recorder.nonces[addr]) != 5 || recorder.nonces[addr][4] != post.GetNonce(addr) {
+		t.Errorf("nonce changes of sender mismatch: %v", recorder.nonces[addr])
+	}
+	for account, slots := range recorder.storage {
+		for slot, values := range slots {
+			if values[0] != pre.GetState(account, slot) || values[len(values)-1] != post.GetState(account, slot) {
+				t.Errorf("storage changes of %x slot %x mismatch states: %v", account, slot, values)
+			}
+		}
+	}
+	if slots := recorder.storage[common.Address{0xbb}]; len(slots[common.Hash{}]) != 3 {
+		t.Errorf("reverted storage change not reported: %v", slots)
+	}
+}
+
 // GenerateBadBlock constructs a "block" which contains the transactions. The transactions are not expected to be
 // valid, and no proper post-state can be made. But from the perspective of the blockchain, the block is sufficiently
 // valid to be considered for import:
@@ -119,7 +277,7 @@ func GenerateBadBlock(parent *types.Block, engine consensus.Engine, txs types.Tr
 	header := &types.Header{
 		ParentHash: parent.Hash(),
 		Coinbase:   parent.Coinbase(),
-		Difficulty: engine.CalcDifficulty(&fakeChainReader{params.TestChainConfig}, parent.Time()+10, &types.Header{
+		Difficulty: engine.CalcDifficulty(&fakeChainReader{config: params.TestChainConfig}, parent.Time()+10, &types.Header{
 			Number:     parent.Number(),
 			Time:       parent.Time(),
 			Difficulty: parent.Difficulty(),
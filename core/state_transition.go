@@ -22,6 +22,7 @@ import (
 	"math/big"
 
 	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/core/tracing"
 	"github.com/420integrated/go-highcoin/core/types"
 	"github.com/420integrated/go-highcoin/core/vm"
 	"github.com/420integrated/go-highcoin/params"
@@ -190,7 +191,7 @@ func (st *StateTransition) buySmoke() error {
 	st.smoke += st.msg.Smoke()
 
 	st.initialSmoke = st.msg.Smoke()
-	st.state.SubBalance(st.msg.From(), mgval)
+	st.state.SubBalanceWithReason(st.msg.From(), mgval, tracing.BalanceChangeFee)
 	return nil
 }
 
@@ -223,6 +224,23 @@ func (st *StateTransition) preCheck() error {
 // However if any consensus issue encountered, return the error directly with
 // nil evm execution result.
 func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
+	tracer := st.evm.StateTracer()
+	if tracer == nil {
+		return st.transitionDb()
+	}
+	tracer.CaptureTxStart(st.evm, st.msg.From(), st.msg.To(), st.msg.Smoke(), st.msg.Value())
+
+	result, err := st.transitionDb()
+	if err != nil {
+		tracer.CaptureTxEnd(0, err)
+	} else {
+		tracer.CaptureTxEnd(result.UsedSmoke, result.Err)
+	}
+	return result, err
+}
+
+// transitionDb applies the message, see TransitionDb.
+func (st *StateTransition) transitionDb() (*ExecutionResult, error) {
 	// First check this message satisfies all consensus rules before
 	// applying the message. The rules include these clauses
 	//
@@ -275,7 +293,7 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 		ret, st.smoke, vmerr = st.evm.Call(sender, st.to(), st.data, st.smoke, st.value)
 	}
 	st.refundSmoke()
-	st.state.AddBalance(st.evm.Context.Coinbase, new(big.Int).Mul(new(big.Int).SetUint64(st.smokeUsed()), st.smokePrice))
+	st.state.AddBalanceWithReason(st.evm.Context.Coinbase, new(big.Int).Mul(new(big.Int).SetUint64(st.smokeUsed()), st.smokePrice), tracing.BalanceChangeFee)
 
 	return &ExecutionResult{
 		UsedSmoke:    st.smokeUsed(),
@@ -294,7 +312,7 @@ func (st *StateTransition) refundSmoke() {
 
 	// Return HIGH for remaining smoke, exchanged at the original rate.
 	remaining := new(big.Int).Mul(new(big.Int).SetUint64(st.smoke), st.smokePrice)
-	st.state.AddBalance(st.msg.From(), remaining)
+	st.state.AddBalanceWithReason(st.msg.From(), remaining, tracing.BalanceChangeRefund)
 
 	// Also return remaining smoke to the block smoke counter so it is
 	// available for the next transaction.
@@ -0,0 +1,468 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/common/hexutil"
+	"github.com/420integrated/go-highcoin/consensus"
+	"github.com/420integrated/go-highcoin/core/rawdb"
+	"github.com/420integrated/go-highcoin/core/state"
+	"github.com/420integrated/go-highcoin/core/types"
+	"github.com/420integrated/go-highcoin/core/vm"
+	"github.com/420integrated/go-highcoin/crypto"
+	"github.com/420integrated/go-highcoin/highdb"
+	"github.com/420integrated/go-highcoin/params"
+	"github.com/420integrated/go-highcoin/trie"
+)
+
+// errWitnessNoParent is returned if a witness doesn't contain the parent header
+// of the block it was generated for.
+var errWitnessNoParent = errors.New("witness missing parent header")
+
+// errWitnessNoGenesis is returned if a witness doesn't contain the genesis header,
+// which the consensus engine reads when accumulating the block rewards.
+var errWitnessNoGenesis = errors.New("witness missing genesis header")
+
+// Witness contains everything needed to execute a block without access to the
+// state database: the trie nodes and contract codes read or modified during the
+// execution, the ancestor headers accessed via the BLOCKHASH opcode and the
+// headers looked up by number, such as the genesis header read by the consensus
+// engine when accumulating the rewards.
+type Witness struct {
+	Headers []*types.Header          // Parent header first, followed by the accessed ancestors
+	Nodes   map[common.Hash][]byte   // Trie nodes keyed by their hash
+	Codes   map[common.Hash][]byte   // Contract codes keyed by their hash
+	Keys    map[common.Hash][]byte   // Preimages of the accessed account and storage trie keys
+	known   map[common.Hash]struct{} // Hashes of the headers already added
+}
+
+// newWitness creates an empty witness for executing a child of the given header.
+func newWitness(parent *types.Header) *Witness {
+	w := &Witness{
+		Nodes: make(map[common.Hash][]byte),
+		Codes: make(map[common.Hash][]byte),
+		Keys:  make(map[common.Hash][]byte),
+		known: make(map[common.Hash]struct{}),
+	}
+	w.addHeader(parent)
+	return w
+}
+
+// addHeader adds a header to the witness if it isn't present yet.
+func (w *Witness) addHeader(header *types.Header) {
+	hash := header.Hash()
+	if _, ok := w.known[hash]; ok {
+		return
+	}
+	w.known[hash] = struct{}{}
+	w.Headers = append(w.Headers, header)
+}
+
+// witnessJSON is the JSON representation of a witness. The trie nodes, codes and
+// keys are listed without their hashes, which are recomputed on import.
+type witnessJSON struct {
+	Headers []*types.Header `json:"headers"`
+	State   []hexutil.Bytes `json:"state"`
+	Codes   []hexutil.Bytes `json:"codes"`
+	Keys    []hexutil.Bytes `json:"keys"`
+}
+
+// sortedBlobs returns the values of a hash keyed set, sorted by their contents.
+func sortedBlobs(set map[common.Hash][]byte) []hexutil.Bytes {
+	blobs := make([]hexutil.Bytes, 0, len(set))
+	for _, blob := range set {
+		blobs = append(blobs, blob)
+	}
+	sort.Slice(blobs, func(i, j int) bool { return bytes.Compare(blobs[i], blobs[j]) < 0 })
+	return blobs
+}
+
+// hashedBlobs returns a set of blobs keyed by their hash.
+func hashedBlobs(blobs []hexutil.Bytes) map[common.Hash][]byte {
+	set := make(map[common.Hash][]byte, len(blobs))
+	for _, blob := range blobs {
+		set[crypto.Keccak256Hash(blob)] = common.CopyBytes(blob)
+	}
+	return set
+}
+
+// MarshalJSON implements json.Marshaler.
+func (w *Witness) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&witnessJSON{
+		Headers: w.Headers,
+		State:   sortedBlobs(w.Nodes),
+		Codes:   sortedBlobs(w.Codes),
+		Keys:    sortedBlobs(w.Keys),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (w *Witness) UnmarshalJSON(input []byte) error {
+	var dec witnessJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if len(dec.Headers) == 0 {
+		return errWitnessNoParent
+	}
+	*w = *newWitness(dec.Headers[0])
+	for _, header := range dec.Headers[1:] {
+		w.addHeader(header)
+	}
+	w.Nodes, w.Codes, w.Keys = hashedBlobs(dec.State), hashedBlobs(dec.Codes), hashedBlobs(dec.Keys)
+	return nil
+}
+
+// ExecutionWitness executes a block on top of its parent state, recording all
+// the trie nodes, contract codes and ancestor headers accessed along the way.
+// The returned witness is enough to re-execute and validate the block with
+// ExecuteStateless, without access to any database.
+func (bc *BlockChain) ExecutionWitness(block *types.Block) (*Witness, error) {
+	parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, consensus.ErrUnknownAncestor
+	}
+	witness := newWitness(parent)
+
+	// The genesis header is always needed, as the consensus engine derives the
+	// reward recipients from it. Any other header looked up is recorded on access.
+	witness.addHeader(bc.genesisBlock.Header())
+
+	// Execute the block through the recording wrappers. The state is read from the
+	// tries instead of the snapshot to ensure all accessed nodes are collected.
+	statedb, err := state.New(parent.Root, newWitnessDatabase(bc.stateCache, witness), nil)
+	if err != nil {
+		return nil, err
+	}
+	processor := &StateProcessor{
+		config: bc.chainConfig,
+		bc:     &witnessRecorderChain{BlockChain: bc, witness: witness},
+		engine: bc.engine,
+	}
+	if _, _, _, err := processor.Process(block, statedb, vm.Config{}); err != nil {
+		return nil, err
+	}
+	// Hashing the post state resolves the nodes needed to collapse deleted paths
+	root := statedb.IntermediateRoot(bc.chainConfig.IsEIP158(block.Number()))
+	if err := statedb.Error(); err != nil {
+		return nil, err
+	}
+	if root != block.Root() {
+		return nil, fmt.Errorf("state root mismatch: have %x, want %x", root, block.Root())
+	}
+	return witness, nil
+}
+
+// ExecuteStateless executes a block using only the state contained in the given
+// witness, and validates the results against the block header. The post state
+// root and the receipts of the block are returned.
+func ExecuteStateless(config *params.ChainConfig, engine consensus.Engine, block *types.Block, witness *Witness) (common.Hash, types.Receipts, error) {
+	chain, err := newWitnessChain(config, engine, block, witness)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	// Assemble an in-memory database from the witness, keying every item by its
+	// actual hash to prevent a witness from substituting state
+	db := &statelessDatabase{Database: rawdb.NewMemoryDatabase()}
+	for _, node := range witness.Nodes {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	for _, code := range witness.Codes {
+		db.Put(crypto.Keccak256(code), code) // legacy scheme, looked up first
+	}
+	statedb, err := state.New(chain.parent.Root, state.NewDatabase(db), nil)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	processor := &StateProcessor{config: config, bc: chain, engine: engine}
+	receipts, _, usedSmoke, err := processor.Process(block, statedb, vm.Config{})
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	// Missing state is only recorded by the state database, not returned, as the
+	// execution continues as if the accessed items didn't exist
+	validator := &BlockValidator{config: config}
+	err = validator.ValidateState(block, statedb, receipts, usedSmoke)
+	if dbErr := statedb.Error(); dbErr != nil {
+		return common.Hash{}, nil, fmt.Errorf("incomplete witness: %v", dbErr)
+	}
+	if db.missing != nil {
+		return common.Hash{}, nil, fmt.Errorf("incomplete witness: %v", db.missing)
+	}
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	return block.Root(), receipts, nil
+}
+
+// statelessDatabase is the database backing a stateless execution, remembering
+// the first trie node or contract code missing from the witness. Failed storage
+// reads are not reported by the state database, so they need to be detected here.
+type statelessDatabase struct {
+	highdb.Database
+	missing error
+}
+
+// Get retrieves the given key, recording it if it's a missing state item.
+func (db *statelessDatabase) Get(key []byte) ([]byte, error) {
+	blob, err := db.Database.Get(key)
+	if err != nil && len(key) == common.HashLength && db.missing == nil {
+		db.missing = fmt.Errorf("missing state item %x", key)
+	}
+	return blob, err
+}
+
+// witnessDatabase is a state.Database recording all trie nodes, contract codes
+// and trie keys accessed through it into a witness.
+type witnessDatabase struct {
+	source  state.Database
+	triedb  *trie.Database
+	witness *Witness
+}
+
+// newWitnessDatabase wraps a state database to record accesses into a witness.
+// The tries are opened on a trie database without caches, so every node resolved
+// is read, and recorded, through the source database.
+func newWitnessDatabase(source state.Database, witness *Witness) *witnessDatabase {
+	reader := &witnessNodeReader{
+		KeyValueStore: source.TrieDB().DiskDB(),
+		source:        source.TrieDB(),
+		witness:       witness,
+	}
+	return &witnessDatabase{
+		source:  source,
+		triedb:  trie.NewDatabase(reader),
+		witness: witness,
+	}
+}
+
+// OpenTrie opens the main account trie.
+func (db *witnessDatabase) OpenTrie(root common.Hash) (state.Trie, error) {
+	tr, err := trie.NewSecure(root, db.triedb)
+	if err != nil {
+		return nil, err
+	}
+	return &witnessTrie{Trie: tr, witness: db.witness}, nil
+}
+
+// OpenStorageTrie opens the storage trie of an account.
+func (db *witnessDatabase) OpenStorageTrie(addrHash, root common.Hash) (state.Trie, error) {
+	return db.OpenTrie(root)
+}
+
+// CopyTrie returns an independent copy of the given trie.
+func (db *witnessDatabase) CopyTrie(t state.Trie) state.Trie {
+	switch t := t.(type) {
+	case *witnessTrie:
+		return &witnessTrie{Trie: db.source.CopyTrie(t.Trie), witness: t.witness}
+	default:
+		panic(fmt.Errorf("unknown trie type %T", t))
+	}
+}
+
+// ContractCode retrieves a particular contract's code.
+func (db *witnessDatabase) ContractCode(addrHash, codeHash common.Hash) ([]byte, error) {
+	code, err := db.source.ContractCode(addrHash, codeHash)
+	if err != nil {
+		return nil, err
+	}
+	db.witness.Codes[codeHash] = code
+	return code, nil
+}
+
+// ContractCodeSize retrieves a particular contracts code's size.
+func (db *witnessDatabase) ContractCodeSize(addrHash, codeHash common.Hash) (int, error) {
+	code, err := db.ContractCode(addrHash, codeHash)
+	return len(code), err
+}
+
+// TrieDB retrieves the low level trie database used for data storage.
+func (db *witnessDatabase) TrieDB() *trie.Database {
+	return db.triedb
+}
+
+// witnessNodeReader is a key-value store serving trie nodes from a source trie
+// database, including its not yet flushed nodes, and recording them.
+type witnessNodeReader struct {
+	highdb.KeyValueStore // Disk database for non-node accesses (e.g. preimages)
+
+	source  *trie.Database
+	witness *Witness
+}
+
+// Get retrieves the given key, recording it if it is a trie node.
+func (r *witnessNodeReader) Get(key []byte) ([]byte, error) {
+	if len(key) != common.HashLength {
+		return r.KeyValueStore.Get(key)
+	}
+	hash := common.BytesToHash(key)
+	node, err := r.source.Node(hash)
+	if err != nil {
+		return nil, err
+	}
+	r.witness.Nodes[hash] = node
+	return node, nil
+}
+
+// witnessTrie is a state trie recording the preimages of the keys accessed.
+type witnessTrie struct {
+	state.Trie
+	witness *Witness
+}
+
+func (t *witnessTrie) record(key []byte) {
+	t.witness.Keys[crypto.Keccak256Hash(key)] = common.CopyBytes(key)
+}
+
+// TryGet returns the value for key stored in the trie.
+func (t *witnessTrie) TryGet(key []byte) ([]byte, error) {
+	t.record(key)
+	return t.Trie.TryGet(key)
+}
+
+// TryUpdate associates key with value in the trie.
+func (t *witnessTrie) TryUpdate(key, value []byte) error {
+	t.record(key)
+	return t.Trie.TryUpdate(key, value)
+}
+
+// TryDelete removes any existing value for key from the trie.
+func (t *witnessTrie) TryDelete(key []byte) error {
+	t.record(key)
+	return t.Trie.TryDelete(key)
+}
+
+// witnessRecorderChain is a chain recording the headers retrieved from it into
+// a witness.
+type witnessRecorderChain struct {
+	*BlockChain
+	witness *Witness
+}
+
+// GetHeader retrieves a block header by hash and number, recording it.
+func (c *witnessRecorderChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	header := c.BlockChain.GetHeader(hash, number)
+	if header != nil {
+		c.witness.addHeader(header)
+	}
+	return header
+}
+
+// GetHeaderByNumber retrieves a canonical block header by number, recording it.
+func (c *witnessRecorderChain) GetHeaderByNumber(number uint64) *types.Header {
+	header := c.BlockChain.GetHeaderByNumber(number)
+	if header != nil {
+		c.witness.addHeader(header)
+	}
+	return header
+}
+
+// GetHeaderByHash retrieves a block header by hash, recording it.
+func (c *witnessRecorderChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	header := c.BlockChain.GetHeaderByHash(hash)
+	if header != nil {
+		c.witness.addHeader(header)
+	}
+	return header
+}
+
+// witnessChain is a chain backed only by the headers contained in a witness.
+type witnessChain struct {
+	config  *params.ChainConfig
+	engine  consensus.Engine
+	parent  *types.Header
+	headers map[common.Hash]*types.Header
+	numbers map[uint64]*types.Header
+}
+
+// newWitnessChain creates a chain from the headers of a witness, ensuring they
+// form a contiguous ancestry of the block. Headers below the contiguous section
+// (e.g. the genesis header) are accepted too, as long as they don't conflict with
+// it: they cannot be linked to the block, and are only verified indirectly by the
+// post state root of the execution. The genesis header is always required.
+func newWitnessChain(config *params.ChainConfig, engine consensus.Engine, block *types.Block, witness *Witness) (*witnessChain, error) {
+	if len(witness.Headers) == 0 {
+		return nil, errWitnessNoParent
+	}
+	headers := make([]*types.Header, len(witness.Headers))
+	copy(headers, witness.Headers)
+	sort.Slice(headers, func(i, j int) bool { return headers[i].Number.Cmp(headers[j].Number) > 0 })
+
+	chain := &witnessChain{
+		config:  config,
+		engine:  engine,
+		parent:  headers[0],
+		headers: make(map[common.Hash]*types.Header, len(headers)),
+		numbers: make(map[uint64]*types.Header, len(headers)),
+	}
+	if chain.parent.Hash() != block.ParentHash() {
+		return nil, errWitnessNoParent
+	}
+	ancestor := chain.parent
+	for i, header := range headers {
+		number := header.Number.Uint64()
+		if _, ok := chain.numbers[number]; ok {
+			return nil, fmt.Errorf("duplicate witness header %d", number)
+		}
+		if i > 0 && number+1 == ancestor.Number.Uint64() {
+			if ancestor.ParentHash != header.Hash() {
+				return nil, fmt.Errorf("witness header %d not an ancestor of the block", number)
+			}
+			ancestor = header
+		}
+		chain.headers[header.Hash()] = header
+		chain.numbers[number] = header
+	}
+	if chain.numbers[0] == nil {
+		return nil, errWitnessNoGenesis
+	}
+	return chain, nil
+}
+
+// Config retrieves the chain's configuration.
+func (c *witnessChain) Config() *params.ChainConfig { return c.config }
+
+// Engine retrieves the chain's consensus engine.
+func (c *witnessChain) Engine() consensus.Engine { return c.engine }
+
+// CurrentHeader retrieves the parent header of the executed block.
+func (c *witnessChain) CurrentHeader() *types.Header { return c.parent }
+
+// GetHeader retrieves a witness header by hash and number.
+func (c *witnessChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header := c.headers[hash]; header != nil && header.Number.Uint64() == number {
+		return header
+	}
+	return nil
+}
+
+// GetHeaderByHash retrieves a witness header by hash.
+func (c *witnessChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	return c.headers[hash]
+}
+
+// GetHeaderByNumber retrieves a witness header by number.
+func (c *witnessChain) GetHeaderByNumber(number uint64) *types.Header {
+	return c.numbers[number]
+}
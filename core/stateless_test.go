@@ -0,0 +1,160 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/consensus/ethash"
+	"github.com/420integrated/go-highcoin/core/rawdb"
+	"github.com/420integrated/go-highcoin/core/types"
+	"github.com/420integrated/go-highcoin/core/vm"
+	"github.com/420integrated/go-highcoin/crypto"
+	"github.com/420integrated/go-highcoin/params"
+)
+
+// Tests that blocks can be re-executed from their execution witness alone, and
+// that incomplete witnesses are rejected.
+func TestExecutionWitness(t *testing.T) {
+	var (
+		key, _   = crypto.GenerateKey()
+		addr     = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.HexToAddress("0xc0de")
+		engine   = ethash.NewFaker()
+		signer   = types.HomesteadSigner{}
+		db       = rawdb.NewMemoryDatabase()
+
+		// The contract stores the hash of the parent block in the slot of the
+		// current block number, clears the slot of the previous one and stores
+		// the hash of the third ancestor in slot zero.
+		code = []byte{
+			byte(vm.NUMBER), byte(vm.PUSH1), 0x01, byte(vm.SWAP1), byte(vm.SUB), byte(vm.BLOCKHASH), byte(vm.NUMBER), byte(vm.SSTORE),
+			byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x01, byte(vm.NUMBER), byte(vm.SUB), byte(vm.SSTORE),
+			byte(vm.PUSH1), 0x03, byte(vm.NUMBER), byte(vm.SUB), byte(vm.BLOCKHASH), byte(vm.PUSH1), 0x00, byte(vm.SSTORE),
+			byte(vm.STOP),
+		}
+		// The block rewards are distributed according to the vault contract
+		// deployed by the creator named in the genesis extra-data.
+		creator = common.HexToAddress("0xc4ea70")
+		vault   = crypto.CreateAddress(creator, 0)
+
+		gspec = &Genesis{
+			Config:    params.TestChainConfig,
+			ExtraData: creator.Bytes(),
+			Alloc: GenesisAlloc{
+				addr:     {Balance: big.NewInt(1000000000000000)},
+				contract: {Balance: new(big.Int), Code: code},
+				vault: {
+					Balance: new(big.Int),
+					Code:    []byte{byte(vm.STOP)},
+					Storage: map[common.Hash]common.Hash{
+						{}:                            common.BigToHash(big.NewInt(100)),
+						common.BytesToHash([]byte{3}): common.BytesToHash(common.HexToAddress("0x7e7").Bytes()),
+						common.BytesToHash([]byte{4}): common.BytesToHash(common.HexToAddress("0xf011").Bytes()),
+					},
+				},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+	)
+	chaindb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(chaindb)
+
+	chain, err := NewBlockChain(chaindb, nil, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// Generate and import the blocks one by one, so the contract can access the
+	// hashes of the previous blocks
+	var blocks []*types.Block
+	for i, parent := 0, genesis; i < 8; i++ {
+		generated, _ := GenerateChain(gspec.Config, parent, engine, db, 1, func(_ int, gen *BlockGen) {
+			tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(addr), contract, new(big.Int), 100000, big.NewInt(1), nil), signer, key)
+			gen.AddTxWithChain(chain, tx)
+			tx, _ = types.SignTx(types.NewTransaction(gen.TxNonce(addr), common.Address{byte(i + 1)}, big.NewInt(1000), params.TxSmoke, big.NewInt(1), nil), signer, key)
+			gen.AddTxWithChain(chain, tx)
+		})
+		if _, err := chain.InsertChain(generated); err != nil {
+			t.Fatalf("failed to insert block %d: %v", i+1, err)
+		}
+		blocks, parent = append(blocks, generated...), generated[0]
+	}
+	for _, block := range blocks {
+		witness, err := chain.ExecutionWitness(block)
+		if err != nil {
+			t.Fatalf("block %d: failed to generate witness: %v", block.NumberU64(), err)
+		}
+		if len(witness.Codes) != 1 || len(witness.Keys) == 0 {
+			t.Errorf("block %d: witness incomplete: %d codes, %d keys", block.NumberU64(), len(witness.Codes), len(witness.Keys))
+		}
+		if _, ok := witness.known[genesis.Hash()]; !ok {
+			t.Errorf("block %d: witness missing genesis header", block.NumberU64())
+		}
+		// Transfer the witness and execute the block from it
+		blob, err := json.Marshal(witness)
+		if err != nil {
+			t.Fatalf("block %d: failed to encode witness: %v", block.NumberU64(), err)
+		}
+		decoded := new(Witness)
+		if err := json.Unmarshal(blob, decoded); err != nil {
+			t.Fatalf("block %d: failed to decode witness: %v", block.NumberU64(), err)
+		}
+		root, receipts, err := ExecuteStateless(gspec.Config, engine, block, decoded)
+		if err != nil {
+			t.Fatalf("block %d: failed to execute statelessly: %v", block.NumberU64(), err)
+		}
+		if root != block.Root() || len(receipts) != len(block.Transactions()) {
+			t.Errorf("block %d: result mismatch: root %x, %d receipts", block.NumberU64(), root, len(receipts))
+		}
+		// Ensure that dropping any part of the witness breaks the execution
+		for hash := range decoded.Nodes {
+			node := decoded.Nodes[hash]
+			delete(decoded.Nodes, hash)
+			if _, _, err := ExecuteStateless(gspec.Config, engine, block, decoded); err == nil {
+				t.Errorf("block %d: executed without trie node %x", block.NumberU64(), hash)
+			}
+			decoded.Nodes[hash] = node
+		}
+		if block.NumberU64() > 1 {
+			var headers []*types.Header
+			for _, header := range decoded.Headers {
+				if header.Number.Sign() != 0 {
+					headers = append(headers, header)
+				}
+			}
+			ancestors := decoded.Headers
+			decoded.Headers = headers
+			if _, _, err := ExecuteStateless(gspec.Config, engine, block, decoded); err != errWitnessNoGenesis {
+				t.Errorf("block %d: execution error mismatch without genesis header: have %v, want %v", block.NumberU64(), err, errWitnessNoGenesis)
+			}
+			decoded.Headers = ancestors
+		}
+		if block.NumberU64() > 3 {
+			ancestors := decoded.Headers
+			decoded.Headers = ancestors[:1]
+			if _, _, err := ExecuteStateless(gspec.Config, engine, block, decoded); err == nil {
+				t.Errorf("block %d: executed without ancestor headers", block.NumberU64())
+			}
+			decoded.Headers = ancestors
+		}
+	}
+}
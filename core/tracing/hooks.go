@@ -0,0 +1,106 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tracing defines the hooks through which the state changes applied
+// during block and transaction processing are reported to tracers.
+package tracing
+
+import (
+	"math/big"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/core/types"
+)
+
+// BalanceChangeReason is the cause of a balance change reported to the hooks.
+type BalanceChangeReason byte
+
+const (
+	// BalanceChangeUnspecified is used for changes made outside of transaction
+	// and block processing, e.g. genesis allocations or irregular state changes.
+	BalanceChangeUnspecified BalanceChangeReason = iota
+
+	// BalanceChangeTransfer is a value transfer between two accounts, either
+	// by a transaction or an internal call.
+	BalanceChangeTransfer
+
+	// BalanceChangeFee is the purchase of the smoke of a transaction by its
+	// sender, or the payment of the used smoke to the coinbase.
+	BalanceChangeFee
+
+	// BalanceChangeRefund is the return of the unused smoke of a transaction
+	// to its sender.
+	BalanceChangeRefund
+
+	// BalanceChangeReward is a block or uncle reward, including the split paid
+	// to the reward contracts.
+	BalanceChangeReward
+
+	// BalanceChangeSelfdestruct is the balance of a self-destructed account
+	// being cleared and credited to the beneficiary.
+	BalanceChangeSelfdestruct
+
+	// BalanceChangeRevert is a balance restored by reverting a failed call
+	// frame or transaction.
+	BalanceChangeRevert
+)
+
+// String implements fmt.Stringer.
+func (r BalanceChangeReason) String() string {
+	switch r {
+	case BalanceChangeUnspecified:
+		return "unspecified"
+	case BalanceChangeTransfer:
+		return "transfer"
+	case BalanceChangeFee:
+		return "fee"
+	case BalanceChangeRefund:
+		return "refund"
+	case BalanceChangeReward:
+		return "reward"
+	case BalanceChangeSelfdestruct:
+		return "selfdestruct"
+	case BalanceChangeRevert:
+		return "revert"
+	default:
+		return "unknown"
+	}
+}
+
+// StateHooks receives every change applied to the state, as it is applied.
+//
+// Changes made by call frames which are later reverted are reported too, and
+// are followed by the changes restoring the previous values when the revert
+// happens. Applying the reported changes in order therefore always yields the
+// current state. The only exception are logs, which are not retracted; the
+// failure of their call frame is reported through the opcode tracer.
+type StateHooks interface {
+	// OnBalanceChange is called when the balance of an account changes.
+	OnBalanceChange(addr common.Address, prev, new *big.Int, reason BalanceChangeReason)
+
+	// OnNonceChange is called when the nonce of an account changes.
+	OnNonceChange(addr common.Address, prev, new uint64)
+
+	// OnCodeChange is called when the code of an account changes.
+	OnCodeChange(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte)
+
+	// OnStorageChange is called when a storage slot of an account is written
+	// with a value different from its current one.
+	OnStorageChange(addr common.Address, slot common.Hash, prev, new common.Hash)
+
+	// OnLog is called when a log is emitted.
+	OnLog(log *types.Log)
+}
@@ -17,7 +17,12 @@
 package core
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
 
@@ -27,9 +32,55 @@ import (
 	"github.com/420integrated/go-highcoin/rlp"
 )
 
-// errNoActiveJournal is returned if a transaction is attempted to be inserted
-// into the journal, but no such file is currently open.
-var errNoActiveJournal = errors.New("no active journal")
+// The transaction journal is an append-only file starting with a magic string
+// and a format version, followed by a sequence of records. Each record consists
+// of a header, holding the length of the payload, the kind of the record, the
+// CRC32-C checksum of the payload and the CRC32-C checksum of the preceding
+// header fields, followed by the RLP encoded transaction as the payload. The
+// separate header checksum detects a corrupted length before it's used to frame
+// the following records.
+//
+// Journals written before the versioned format (version 0) are a plain stream
+// of RLP encoded local transactions. They are still loaded, and rewritten in
+// the current format on the next rotation.
+const (
+	txJournalVersion  = 1             // Current version of the journal format
+	txJournalHeadSize = 13            // Size of a record header: length (4), kind (1), payload checksum (4), header checksum (4)
+	txRecordMaxSize   = 2 * txMaxSize // Maximum payload of a record, beyond it the framing is corrupted
+
+	txRecordLocal  = 0x00 // Record of a local transaction
+	txRecordRemote = 0x01 // Record of a remote transaction
+)
+
+var (
+	// errNoActiveJournal is returned if a transaction is attempted to be inserted
+	// into the journal, but no such file is currently open.
+	errNoActiveJournal = errors.New("no active journal")
+
+	// errJournalVersion is returned if the journal was written in a format
+	// version unknown to this node.
+	errJournalVersion = errors.New("unsupported journal version")
+
+	// errJournalFraming is returned if the header of a journal record is damaged
+	// or its length out of bounds, making the rest of the journal unreadable.
+	errJournalFraming = errors.New("corrupted journal record header")
+
+	// txJournalMagic is the prefix of the versioned journal files.
+	txJournalMagic = []byte("htxj")
+
+	// txJournalChecksum is the table used for the record checksums.
+	txJournalChecksum = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// TxJournalStats contains the statistics gathered while reading a transaction
+// journal.
+type TxJournalStats struct {
+	Version   uint8 // Format version of the journal
+	Locals    int   // Number of valid local transaction records
+	Remotes   int   // Number of valid remote transaction records
+	Corrupted int   // Number of records skipped due to checksum or decoding failures
+	Truncated bool  // Whether the journal ends with a partially written record
+}
 
 // devNull is a WriteCloser that just discards anything written into it. Its
 // goal is to allow the transaction journal to write into a fake journal when
@@ -42,6 +93,7 @@ func (*devNull) Close() error                      { return nil }
 
 // txJournal is a rotating log of transactions with the aim of storing locally
 // created transactions to allow non-executed ones to survive node restarts.
+// Optionally, the best remote pending transactions are persisted too.
 type txJournal struct {
 	path   string         // Filesystem path to store the transactions at
 	writer io.WriteCloser // Output stream to write new transactions into
@@ -56,7 +108,7 @@ func newTxJournal(path string) *txJournal {
 
 // load parses a transaction journal dump from disk, loading its contents into
 // the specified pool.
-func (journal *txJournal) load(add func([]*types.Transaction) []error) error {
+func (journal *txJournal) load(addLocals, addRemotes func([]*types.Transaction) []error) error {
 	// Skip the parsing if the journal file doesn't exist at all
 	if _, err := os.Stat(journal.path); os.IsNotExist(err) {
 		return nil
@@ -72,14 +124,11 @@ func (journal *txJournal) load(add func([]*types.Transaction) []error) error {
 	journal.writer = new(devNull)
 	defer func() { journal.writer = nil }()
 
-	// Inject all transactions from the journal into the pool
-	stream := rlp.NewStream(input, 0)
-	total, dropped := 0, 0
-
 	// Create a method to load a limited batch of transactions and bump the
 	// appropriate progress counters. Then use this method to load all the
 	// journaled transactions in small-ish batches.
-	loadBatch := func(txs types.Transactions) {
+	dropped := 0
+	loadBatch := func(add func([]*types.Transaction) []error, txs types.Transactions) {
 		for _, err := range add(txs) {
 			if err != nil {
 				log.Debug("Failed to add journaled transaction", "err", err)
@@ -87,49 +136,57 @@ func (journal *txJournal) load(add func([]*types.Transaction) []error) error {
 			}
 		}
 	}
-	var (
-		failure error
-		batch   types.Transactions
-	)
-	for {
-		// Parse the next transaction and terminate on error
-		tx := new(types.Transaction)
-		if err = stream.Decode(tx); err != nil {
-			if err != io.EOF {
-				failure = err
-			}
-			if batch.Len() > 0 {
-				loadBatch(batch)
+	var locals, remotes types.Transactions
+	stats, failure := iterateTxJournal(input, func(tx *types.Transaction, remote bool) {
+		// New transaction parsed, queue up for later, import if threshold is reached
+		if remote {
+			if remotes = append(remotes, tx); remotes.Len() > 1024 {
+				loadBatch(addRemotes, remotes)
+				remotes = remotes[:0]
 			}
-			break
+			return
 		}
-		// New transaction parsed, queue up for later, import if threshold is reached
-		total++
-
-		if batch = append(batch, tx); batch.Len() > 1024 {
-			loadBatch(batch)
-			batch = batch[:0]
+		if locals = append(locals, tx); locals.Len() > 1024 {
+			loadBatch(addLocals, locals)
+			locals = locals[:0]
 		}
+	})
+	if locals.Len() > 0 {
+		loadBatch(addLocals, locals)
+	}
+	if remotes.Len() > 0 {
+		loadBatch(addRemotes, remotes)
 	}
-	log.Info("Loaded local transaction journal", "transactions", total, "dropped", dropped)
+	if stats.Corrupted > 0 || stats.Truncated {
+		log.Warn("Transaction journal damaged", "corrupted", stats.Corrupted, "truncated", stats.Truncated)
+	}
+	log.Info("Loaded transaction journal", "locals", stats.Locals, "remotes", stats.Remotes, "dropped", dropped)
 
+	// If the rest of the journal couldn't be read, move it out of the way before
+	// the next rotation overwrites it, so the records can still be recovered
+	if errors.Is(failure, errJournalFraming) || errors.Is(failure, errJournalVersion) {
+		input.Close()
+		backup := journal.path + ".bak"
+		if err := os.Rename(journal.path, backup); err != nil {
+			log.Error("Failed to back up unreadable transaction journal", "err", err)
+		} else {
+			log.Warn("Backed up unreadable transaction journal", "path", backup, "err", failure)
+		}
+	}
 	return failure
 }
 
-// insert adds the specified transaction to the local disk journal.
+// insert adds the specified local transaction to the disk journal.
 func (journal *txJournal) insert(tx *types.Transaction) error {
 	if journal.writer == nil {
 		return errNoActiveJournal
 	}
-	if err := rlp.Encode(journal.writer, tx); err != nil {
-		return err
-	}
-	return nil
+	return writeTxRecord(journal.writer, tx, false)
 }
 
 // rotate regenerates the transaction journal based on the current contents of
 // the transaction pool.
-func (journal *txJournal) rotate(all map[common.Address]types.Transactions) error {
+func (journal *txJournal) rotate(all map[common.Address]types.Transactions, remotes types.Transactions) error {
 	// Close the current journal (if any is open)
 	if journal.writer != nil {
 		if err := journal.writer.Close(); err != nil {
@@ -138,24 +195,11 @@ func (journal *txJournal) rotate(all map[common.Address]types.Transactions) erro
 		journal.writer = nil
 	}
 	// Generate a new journal with the contents of the current pool
-	replacement, err := os.OpenFile(journal.path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
-	if err != nil {
-		return err
-	}
-	journaled := 0
+	var locals types.Transactions
 	for _, txs := range all {
-		for _, tx := range txs {
-			if err = rlp.Encode(replacement, tx); err != nil {
-				replacement.Close()
-				return err
-			}
-		}
-		journaled += len(txs)
+		locals = append(locals, txs...)
 	}
-	replacement.Close()
-
-	// Replace the live journal with the newly generated one
-	if err = os.Rename(journal.path+".new", journal.path); err != nil {
+	if err := writeTxJournal(journal.path, locals, remotes); err != nil {
 		return err
 	}
 	sink, err := os.OpenFile(journal.path, os.O_WRONLY|os.O_APPEND, 0755)
@@ -163,7 +207,7 @@ func (journal *txJournal) rotate(all map[common.Address]types.Transactions) erro
 		return err
 	}
 	journal.writer = sink
-	log.Info("Regenerated local transaction journal", "transactions", journaled, "accounts", len(all))
+	log.Info("Regenerated transaction journal", "locals", len(locals), "accounts", len(all), "remotes", len(remotes))
 
 	return nil
 }
@@ -178,3 +222,198 @@ func (journal *txJournal) close() error {
 	}
 	return err
 }
+
+// writeTxRecord appends a single transaction record to the journal. The record
+// is assembled in memory and written at once to minimize the chance of a torn
+// write.
+func writeTxRecord(w io.Writer, tx *types.Transaction, remote bool) error {
+	payload, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return err
+	}
+	kind := byte(txRecordLocal)
+	if remote {
+		kind = txRecordRemote
+	}
+	record := make([]byte, txJournalHeadSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	record[4] = kind
+	binary.BigEndian.PutUint32(record[5:9], crc32.Checksum(payload, txJournalChecksum))
+	binary.BigEndian.PutUint32(record[9:13], crc32.Checksum(record[:9], txJournalChecksum))
+	copy(record[txJournalHeadSize:], payload)
+
+	_, err = w.Write(record)
+	return err
+}
+
+// writeTxJournal atomically replaces the journal at the given path with a new
+// one containing the given transactions.
+func writeTxJournal(path string, locals, remotes types.Transactions) error {
+	replacement, err := os.OpenFile(path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	output := bufio.NewWriter(replacement)
+	if _, err := output.Write(append(append([]byte{}, txJournalMagic...), txJournalVersion)); err != nil {
+		replacement.Close()
+		return err
+	}
+	for i, tx := range append(append(types.Transactions{}, locals...), remotes...) {
+		if err := writeTxRecord(output, tx, i >= len(locals)); err != nil {
+			replacement.Close()
+			return err
+		}
+	}
+	if err := output.Flush(); err != nil {
+		replacement.Close()
+		return err
+	}
+	if err := replacement.Sync(); err != nil {
+		replacement.Close()
+		return err
+	}
+	replacement.Close()
+
+	// Replace the live journal with the newly generated one
+	return os.Rename(path+".new", path)
+}
+
+// iterateTxJournal reads all the transactions from a journal in any supported
+// format, invoking the callback for each of them. Corrupted records are skipped
+// if the rest of the journal can still be read, and a partially written record
+// at the end is ignored.
+func iterateTxJournal(input io.Reader, fn func(tx *types.Transaction, remote bool)) (*TxJournalStats, error) {
+	reader := bufio.NewReader(input)
+
+	// Journals without the magic prefix are in the unversioned legacy format
+	stats := new(TxJournalStats)
+	if prefix, err := reader.Peek(len(txJournalMagic) + 1); err != nil || !bytes.Equal(prefix[:len(txJournalMagic)], txJournalMagic) {
+		return stats, iterateLegacyTxJournal(reader, stats, fn)
+	}
+	reader.Discard(len(txJournalMagic))
+	version, _ := reader.ReadByte()
+	if stats.Version = version; version != txJournalVersion {
+		return stats, fmt.Errorf("%w: %d", errJournalVersion, version)
+	}
+	head := make([]byte, txJournalHeadSize)
+	for {
+		// Read the next record header, a partial header being a torn write
+		if _, err := io.ReadFull(reader, head); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				stats.Truncated = true
+				return stats, nil
+			}
+			if err == io.EOF {
+				return stats, nil
+			}
+			return stats, err
+		}
+		// A damaged header can't be skipped, as its length frames the rest
+		size := binary.BigEndian.Uint32(head[0:4])
+		if crc32.Checksum(head[:9], txJournalChecksum) != binary.BigEndian.Uint32(head[9:13]) || size > txRecordMaxSize {
+			stats.Corrupted++
+			return stats, errJournalFraming
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			if err == io.ErrUnexpectedEOF || err == io.EOF {
+				stats.Truncated = true
+				return stats, nil
+			}
+			return stats, err
+		}
+		// Verify the record and skip it if damaged, the framing is still intact
+		kind := head[4]
+		checksum := crc32.Checksum(payload, txJournalChecksum)
+		if checksum != binary.BigEndian.Uint32(head[5:9]) || (kind != txRecordLocal && kind != txRecordRemote) {
+			stats.Corrupted++
+			continue
+		}
+		tx := new(types.Transaction)
+		if err := rlp.DecodeBytes(payload, tx); err != nil {
+			stats.Corrupted++
+			continue
+		}
+		if kind == txRecordRemote {
+			stats.Remotes++
+		} else {
+			stats.Locals++
+		}
+		fn(tx, kind == txRecordRemote)
+	}
+}
+
+// iterateLegacyTxJournal reads the transactions from an unversioned journal,
+// a plain stream of RLP encoded local transactions.
+func iterateLegacyTxJournal(input io.Reader, stats *TxJournalStats, fn func(tx *types.Transaction, remote bool)) error {
+	stream := rlp.NewStream(input, 0)
+	for {
+		// Parse the next transaction and terminate on error
+		tx := new(types.Transaction)
+		if err := stream.Decode(tx); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			if err == io.ErrUnexpectedEOF {
+				stats.Truncated = true
+				return nil
+			}
+			stats.Corrupted++
+			return err
+		}
+		stats.Locals++
+		fn(tx, false)
+	}
+}
+
+// InspectTxJournal reads the transaction journal at the given path and returns
+// the statistics of its contents.
+func InspectTxJournal(path string) (*TxJournalStats, error) {
+	input, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	return iterateTxJournal(input, func(*types.Transaction, bool) {})
+}
+
+// CompactTxJournal rewrites the transaction journal at the given path in the
+// current format, dropping the corrupted records and the duplicate transactions.
+// The statistics of the original journal and the number of transactions kept
+// are returned.
+func CompactTxJournal(path string) (*TxJournalStats, int, error) {
+	input, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	var (
+		seen            = make(map[common.Hash]struct{})
+		locals, remotes types.Transactions
+	)
+	stats, err := iterateTxJournal(input, func(tx *types.Transaction, remote bool) {
+		if _, ok := seen[tx.Hash()]; ok {
+			return
+		}
+		seen[tx.Hash()] = struct{}{}
+		if remote {
+			remotes = append(remotes, tx)
+		} else {
+			locals = append(locals, tx)
+		}
+	})
+	input.Close()
+
+	// Don't touch journals of unknown versions, but otherwise salvage whatever
+	// was readable before a failure
+	if errors.Is(err, errJournalVersion) {
+		return stats, 0, err
+	}
+	if err != nil {
+		log.Warn("Transaction journal unreadable beyond failure", "err", err)
+	}
+	if err := writeTxJournal(path, locals, remotes); err != nil {
+		return stats, 0, err
+	}
+	return stats, len(seen), nil
+}
@@ -0,0 +1,231 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/core/types"
+	"github.com/420integrated/go-highcoin/crypto"
+	"github.com/420integrated/go-highcoin/rlp"
+)
+
+// newTestJournal creates a journal file in a temporary directory with the given
+// transactions, returning its path and contents.
+func newTestJournal(t *testing.T, locals, remotes types.Transactions) (string, []byte, func()) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	path := filepath.Join(dir, "transactions.rlp")
+	if err := writeTxJournal(path, locals, remotes); err != nil {
+		t.Fatalf("failed to write journal: %v", err)
+	}
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read journal: %v", err)
+	}
+	return path, blob, func() { os.RemoveAll(dir) }
+}
+
+// readTestJournal reads all the transactions from a journal blob.
+func readTestJournal(blob []byte) (types.Transactions, types.Transactions, *TxJournalStats, error) {
+	var locals, remotes types.Transactions
+	stats, err := iterateTxJournal(bytes.NewReader(blob), func(tx *types.Transaction, remote bool) {
+		if remote {
+			remotes = append(remotes, tx)
+		} else {
+			locals = append(locals, tx)
+		}
+	})
+	return locals, remotes, stats, err
+}
+
+func testJournalTransactions(n int) types.Transactions {
+	key, _ := crypto.GenerateKey()
+
+	txs := make(types.Transactions, n)
+	for i := range txs {
+		txs[i] = pricedTransaction(uint64(i), 100000, big.NewInt(1), key)
+	}
+	return txs
+}
+
+// Tests that damaged records are skipped while the rest of the journal is kept,
+// and a partially written record at the end is tolerated.
+func TestTxJournalIntegrity(t *testing.T) {
+	txs := testJournalTransactions(4)
+	_, blob, cleanup := newTestJournal(t, txs[:2], txs[2:])
+	defer cleanup()
+
+	locals, remotes, stats, err := readTestJournal(blob)
+	if err != nil {
+		t.Fatalf("failed to read intact journal: %v", err)
+	}
+	if len(locals) != 2 || len(remotes) != 2 || stats.Corrupted != 0 || stats.Truncated {
+		t.Fatalf("intact journal mismatch: locals %d, remotes %d, stats %+v", len(locals), len(remotes), stats)
+	}
+	// Flip a bit in the payload of the second record and ensure only it's lost
+	offset := len(txJournalMagic) + 1 + txJournalHeadSize + int(txs[0].Size()) + txJournalHeadSize + 1
+
+	damaged := common.CopyBytes(blob)
+	damaged[offset] ^= 0x01
+
+	locals, remotes, stats, err = readTestJournal(damaged)
+	if err != nil {
+		t.Fatalf("failed to read damaged journal: %v", err)
+	}
+	if len(locals) != 1 || locals[0].Hash() != txs[0].Hash() || len(remotes) != 2 || stats.Corrupted != 1 {
+		t.Fatalf("damaged journal mismatch: locals %d, remotes %d, stats %+v", len(locals), len(remotes), stats)
+	}
+	// Corrupt the length of the second record, within the record size limit,
+	// and ensure it's detected before misframing the rest of the journal
+	damaged = common.CopyBytes(blob)
+	damaged[len(txJournalMagic)+1+txJournalHeadSize+int(txs[0].Size())+3] ^= 0x01
+
+	locals, remotes, stats, err = readTestJournal(damaged)
+	if !errors.Is(err, errJournalFraming) {
+		t.Fatalf("damaged length error mismatch: have %v, want %v", err, errJournalFraming)
+	}
+	if len(locals) != 1 || locals[0].Hash() != txs[0].Hash() || len(remotes) != 0 || stats.Corrupted != 1 {
+		t.Fatalf("damaged length journal mismatch: locals %d, remotes %d, stats %+v", len(locals), len(remotes), stats)
+	}
+	// Cut the last record in half and ensure the others survive
+	locals, remotes, stats, err = readTestJournal(blob[:len(blob)-int(txs[3].Size())/2])
+	if err != nil {
+		t.Fatalf("failed to read truncated journal: %v", err)
+	}
+	if len(locals) != 2 || len(remotes) != 1 || !stats.Truncated {
+		t.Fatalf("truncated journal mismatch: locals %d, remotes %d, stats %+v", len(locals), len(remotes), stats)
+	}
+	// Ensure unknown versions are rejected
+	future := common.CopyBytes(blob)
+	future[len(txJournalMagic)] = txJournalVersion + 1
+
+	if _, _, _, err := readTestJournal(future); !errors.Is(err, errJournalVersion) {
+		t.Fatalf("future version error mismatch: have %v, want %v", err, errJournalVersion)
+	}
+}
+
+// Tests that journals in the unversioned legacy format are still readable.
+func TestTxJournalLegacy(t *testing.T) {
+	txs := testJournalTransactions(3)
+
+	var blob []byte
+	for _, tx := range txs {
+		enc, _ := rlp.EncodeToBytes(tx)
+		blob = append(blob, enc...)
+	}
+	locals, remotes, stats, err := readTestJournal(blob)
+	if err != nil {
+		t.Fatalf("failed to read legacy journal: %v", err)
+	}
+	if stats.Version != 0 || len(locals) != len(txs) || len(remotes) != 0 {
+		t.Fatalf("legacy journal mismatch: locals %d, remotes %d, stats %+v", len(locals), len(remotes), stats)
+	}
+	for i, tx := range locals {
+		if tx.Hash() != txs[i].Hash() {
+			t.Errorf("transaction %d: hash mismatch: have %x, want %x", i, tx.Hash(), txs[i].Hash())
+		}
+	}
+}
+
+// Tests that a journal whose framing is damaged is backed up when loaded, so
+// the next rotation doesn't overwrite the records behind the damage.
+func TestTxJournalFramingBackup(t *testing.T) {
+	txs := testJournalTransactions(3)
+	path, blob, cleanup := newTestJournal(t, types.Transactions{txs[0], txs[1]}, types.Transactions{txs[2]})
+	defer cleanup()
+
+	// Corrupt the length of the second record and load the journal
+	damaged := common.CopyBytes(blob)
+	damaged[len(txJournalMagic)+1+txJournalHeadSize+int(txs[0].Size())+3] ^= 0x01
+	if err := ioutil.WriteFile(path, damaged, 0644); err != nil {
+		t.Fatalf("failed to write damaged journal: %v", err)
+	}
+	var loaded types.Transactions
+	add := func(txs []*types.Transaction) []error {
+		loaded = append(loaded, txs...)
+		return make([]error, len(txs))
+	}
+	journal := newTxJournal(path)
+	if err := journal.load(add, add); !errors.Is(err, errJournalFraming) {
+		t.Fatalf("damaged journal error mismatch: have %v, want %v", err, errJournalFraming)
+	}
+	if len(loaded) != 1 || loaded[0].Hash() != txs[0].Hash() {
+		t.Fatalf("loaded transactions mismatch: have %d, want 1", len(loaded))
+	}
+	// Rotate the journal and ensure the damaged one survives as a backup
+	if err := journal.rotate(map[common.Address]types.Transactions{{}: loaded}, nil); err != nil {
+		t.Fatalf("failed to rotate journal: %v", err)
+	}
+	journal.close()
+
+	backup, err := ioutil.ReadFile(path + ".bak")
+	if err != nil {
+		t.Fatalf("failed to read journal backup: %v", err)
+	}
+	if !bytes.Equal(backup, damaged) {
+		t.Fatalf("journal backup mismatch")
+	}
+	stats, err := InspectTxJournal(path)
+	if err != nil {
+		t.Fatalf("failed to inspect rotated journal: %v", err)
+	}
+	if stats.Locals != 1 || stats.Remotes != 0 {
+		t.Fatalf("rotated journal mismatch: %+v", stats)
+	}
+}
+
+// Tests that compacting a journal drops the damaged and duplicate records.
+func TestTxJournalCompaction(t *testing.T) {
+	txs := testJournalTransactions(3)
+	path, _, cleanup := newTestJournal(t, types.Transactions{txs[0], txs[1], txs[0]}, types.Transactions{txs[2]})
+	defer cleanup()
+
+	// Append a torn record to the journal
+	output, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0755)
+	if err != nil {
+		t.Fatalf("failed to open journal: %v", err)
+	}
+	record := new(bytes.Buffer)
+	writeTxRecord(record, txs[2], false)
+	output.Write(record.Bytes()[:record.Len()-1])
+	output.Close()
+
+	stats, kept, err := CompactTxJournal(path)
+	if err != nil {
+		t.Fatalf("failed to compact journal: %v", err)
+	}
+	if stats.Locals != 3 || stats.Remotes != 1 || !stats.Truncated || kept != 3 {
+		t.Fatalf("compaction mismatch: kept %d, stats %+v", kept, stats)
+	}
+	stats, err = InspectTxJournal(path)
+	if err != nil {
+		t.Fatalf("failed to inspect compacted journal: %v", err)
+	}
+	if stats.Locals != 2 || stats.Remotes != 1 || stats.Corrupted != 0 || stats.Truncated {
+		t.Fatalf("compacted journal mismatch: %+v", stats)
+	}
+}
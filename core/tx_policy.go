@@ -0,0 +1,191 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/core/types"
+)
+
+var (
+	// ErrSenderDenied is returned if the sender of a transaction is not permitted
+	// to transact by the admission policy of the pool.
+	ErrSenderDenied = errors.New("sender not permitted")
+
+	// ErrRecipientDenied is returned if the recipient of a transaction is not
+	// permitted by the admission policy of the pool.
+	ErrRecipientDenied = errors.New("recipient not permitted")
+
+	// ErrCreationDenied is returned if a transaction creates a contract, but the
+	// admission policy of the pool bans contract creation for its sender.
+	ErrCreationDenied = errors.New("contract creation not permitted")
+
+	// ErrTxSmokeLimit is returned if a transaction requests more smoke than the
+	// admission policy of the pool allows for a single transaction.
+	ErrTxSmokeLimit = errors.New("exceeds transaction smoke limit")
+
+	// ErrPolicyUnavailable is returned if a policy file is configured, but no
+	// valid admission policy could be loaded from it yet.
+	ErrPolicyUnavailable = errors.New("txpool policy unavailable")
+)
+
+// TxPolicy is a set of admission rules applied to all the transactions entering
+// the transaction pool, both local and remote ones. The zero value admits every
+// transaction.
+type TxPolicy struct {
+	DenySenders     []common.Address `json:"denySenders,omitempty"`     // Senders whose transactions are rejected
+	AllowSenders    []common.Address `json:"allowSenders,omitempty"`    // If set, only transactions of these senders are accepted
+	DenyRecipients  []common.Address `json:"denyRecipients,omitempty"`  // Recipients whose transactions are rejected
+	AllowRecipients []common.Address `json:"allowRecipients,omitempty"` // If set, only transactions to these recipients are accepted
+
+	NoCreation bool             `json:"noCreation,omitempty"` // Reject contract creations
+	Creators   []common.Address `json:"creators,omitempty"`   // Senders exempt from the contract creation ban
+
+	MaxTxSmoke uint64 `json:"maxTxSmoke,omitempty"` // Maximum smoke of a single transaction (0 = block limit)
+
+	PeerTxRate  float64 `json:"peerTxRate,omitempty"`  // Transactions accepted from a single peer per second (0 = unlimited)
+	PeerTxBurst int     `json:"peerTxBurst,omitempty"` // Maximum transactions accepted from a single peer at once
+}
+
+// LoadTxPolicy reads a JSON encoded transaction admission policy from a file.
+func LoadTxPolicy(file string) (*TxPolicy, error) {
+	blob, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	policy := new(TxPolicy)
+	if err := json.Unmarshal(blob, policy); err != nil {
+		return nil, fmt.Errorf("invalid txpool policy %s: %v", file, err)
+	}
+	if policy.PeerTxRate < 0 || policy.PeerTxBurst < 0 {
+		return nil, fmt.Errorf("invalid txpool policy %s: negative peer budget", file)
+	}
+	if policy.PeerTxRate > 0 && policy.PeerTxBurst == 0 {
+		policy.PeerTxBurst = 1
+	}
+	return policy, nil
+}
+
+// txPolicy is the compiled form of a TxPolicy with the address lists turned
+// into sets for quick lookups.
+type txPolicy struct {
+	denySenders     map[common.Address]struct{}
+	allowSenders    map[common.Address]struct{}
+	denyRecipients  map[common.Address]struct{}
+	allowRecipients map[common.Address]struct{}
+	creators        map[common.Address]struct{}
+
+	noCreation  bool
+	maxTxSmoke  uint64
+	peerTxRate  float64
+	peerTxBurst int
+
+	unavailable bool      // Whether the policy file failed to load, rejecting everything
+	modTime     time.Time // Modification time of the policy file when loaded
+}
+
+// addressSet converts a list of addresses into a set, nil if the list is empty.
+func addressSet(addrs []common.Address) map[common.Address]struct{} {
+	if len(addrs) == 0 {
+		return nil
+	}
+	set := make(map[common.Address]struct{}, len(addrs))
+	for _, addr := range addrs {
+		set[addr] = struct{}{}
+	}
+	return set
+}
+
+// newTxPolicy compiles an admission policy.
+func newTxPolicy(policy *TxPolicy) *txPolicy {
+	return &txPolicy{
+		denySenders:     addressSet(policy.DenySenders),
+		allowSenders:    addressSet(policy.AllowSenders),
+		denyRecipients:  addressSet(policy.DenyRecipients),
+		allowRecipients: addressSet(policy.AllowRecipients),
+		creators:        addressSet(policy.Creators),
+		noCreation:      policy.NoCreation,
+		maxTxSmoke:      policy.MaxTxSmoke,
+		peerTxRate:      policy.PeerTxRate,
+		peerTxBurst:     policy.PeerTxBurst,
+	}
+}
+
+// validate checks whether a transaction from the given sender is admitted by the
+// policy.
+func (p *txPolicy) validate(tx *types.Transaction, from common.Address) error {
+	if p == nil {
+		return nil
+	}
+	if p.unavailable {
+		return ErrPolicyUnavailable
+	}
+	if _, ok := p.denySenders[from]; ok {
+		return ErrSenderDenied
+	}
+	if _, ok := p.allowSenders[from]; p.allowSenders != nil && !ok {
+		return ErrSenderDenied
+	}
+	if to := tx.To(); to != nil {
+		if _, ok := p.denyRecipients[*to]; ok {
+			return ErrRecipientDenied
+		}
+		if _, ok := p.allowRecipients[*to]; p.allowRecipients != nil && !ok {
+			return ErrRecipientDenied
+		}
+	} else if p.noCreation {
+		if _, ok := p.creators[from]; !ok {
+			return ErrCreationDenied
+		}
+	}
+	if p.maxTxSmoke != 0 && tx.Smoke() > p.maxTxSmoke {
+		return ErrTxSmokeLimit
+	}
+	return nil
+}
+
+// loadTxPolicy loads and compiles the admission policy from a file.
+func loadTxPolicy(file string) (*txPolicy, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := LoadTxPolicy(file)
+	if err != nil {
+		return nil, err
+	}
+	compiled := newTxPolicy(policy)
+	compiled.modTime = info.ModTime()
+	return compiled, nil
+}
+
+// unavailableTxPolicy creates a policy rejecting every transaction, used in place
+// of a policy file which failed to load, so the pool fails closed.
+func unavailableTxPolicy(file string) *txPolicy {
+	policy := &txPolicy{unavailable: true}
+	if info, err := os.Stat(file); err == nil {
+		policy.modTime = info.ModTime()
+	}
+	return policy
+}
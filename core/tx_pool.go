@@ -17,11 +17,14 @@
 package core
 
 import (
+	"bytes"
 	"errors"
 	"math"
 	"math/big"
+	"os"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/420integrated/go-highcoin/common"
@@ -88,6 +91,7 @@ var (
 var (
 	evictionInterval    = time.Minute     // Time interval to check for evictable transactions
 	statsReportInterval = 8 * time.Second // Time interval to report transaction pool stats
+	policyCheckInterval = 5 * time.Second // Time interval to check the admission policy file for changes
 )
 
 var (
@@ -108,6 +112,7 @@ var (
 	knownTxMeter       = metrics.NewRegisteredMeter("txpool/known", nil)
 	validTxMeter       = metrics.NewRegisteredMeter("txpool/valid", nil)
 	invalidTxMeter     = metrics.NewRegisteredMeter("txpool/invalid", nil)
+	policyDropMeter    = metrics.NewRegisteredMeter("txpool/policy/drop", nil) // Dropped due to a policy reload
 	underpricedTxMeter = metrics.NewRegisteredMeter("txpool/underpriced", nil)
 	overflowedTxMeter  = metrics.NewRegisteredMeter("txpool/overflowed", nil)
 
@@ -144,6 +149,9 @@ type TxPoolConfig struct {
 	Journal   string           // Journal of local transactions to survive node restarts
 	Rejournal time.Duration    // Time interval to regenerate the local transaction journal
 
+	JournalRemotes    bool   // Whether to journal the best remote pending transactions too
+	JournalRemoteSize uint64 // Maximum size in bytes of the journaled remote transactions
+
 	PriceLimit uint64 // Minimum smoke price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
 
@@ -153,6 +161,8 @@ type TxPoolConfig struct {
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	PolicyFile string // Admission policy file, reloaded when changed (empty = admit everything)
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -161,6 +171,8 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	Journal:   "transactions.rlp",
 	Rejournal: time.Hour,
 
+	JournalRemoteSize: 16 * 1024 * 1024,
+
 	PriceLimit: 1,
 	PriceBump:  10,
 
@@ -180,6 +192,10 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool journal time", "provided", conf.Rejournal, "updated", time.Second)
 		conf.Rejournal = time.Second
 	}
+	if conf.JournalRemotes && conf.JournalRemoteSize < 1 {
+		log.Warn("Sanitizing invalid txpool remote journal size", "provided", conf.JournalRemoteSize, "updated", DefaultTxPoolConfig.JournalRemoteSize)
+		conf.JournalRemoteSize = DefaultTxPoolConfig.JournalRemoteSize
+	}
 	if conf.PriceLimit < 1 {
 		log.Warn("Sanitizing invalid txpool price limit", "provided", conf.PriceLimit, "updated", DefaultTxPoolConfig.PriceLimit)
 		conf.PriceLimit = DefaultTxPoolConfig.PriceLimit
@@ -224,6 +240,7 @@ type TxPool struct {
 	chain       blockChain
 	smokePrice    *big.Int
 	txFeed      event.Feed
+	dropFeed    event.Feed
 	scope       event.SubscriptionScope
 	signer      types.Signer
 	mu          sync.RWMutex
@@ -235,8 +252,12 @@ type TxPool struct {
 	pendingNonces *txNoncer      // Pending state tracking virtual nonces
 	currentMaxSmoke uint64         // Current smoke limit for transaction caps
 
-	locals  *accountSet // Set of local transaction to exempt from eviction rules
-	journal *txJournal  // Journal of local transaction to back up to disk
+	locals  *accountSet    // Set of local transaction to exempt from eviction rules
+	journal *txJournal     // Journal of local transaction to back up to disk
+	policy  atomic.Value   // Admission policy of new transactions (*txPolicy)
+	dropped []DropTxsEvent // Drops accumulated under the pool lock, sent after releasing it
+
+	inclusions map[common.Hash]struct{} // Transactions included by the last reset's new blocks (nil = unknown)
 
 	pending map[common.Address]*txList   // All currently processable transactions
 	queue   map[common.Address]*txList   // Queued but non-processable transactions
@@ -288,22 +309,33 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		pool.locals.add(addr)
 	}
 	pool.priced = newTxPricedList(pool.all)
+	pool.policy.Store((*txPolicy)(nil))
+	if config.PolicyFile != "" {
+		if policy, err := loadTxPolicy(config.PolicyFile); err != nil {
+			log.Error("Failed to load txpool policy, rejecting all transactions", "file", config.PolicyFile, "err", err)
+			pool.policy.Store(unavailableTxPolicy(config.PolicyFile))
+		} else {
+			pool.policy.Store(policy)
+		}
+	}
 	pool.reset(nil, chain.CurrentBlock().Header())
 
 	// Start the reorg loop early so it can handle requests generated during journal loading.
 	pool.wg.Add(1)
 	go pool.scheduleReorgLoop()
 
-	// If local transactions and journaling is enabled, load from disk
-	if !config.NoLocals && config.Journal != "" {
+	// If local or remote transaction journaling is enabled, load from disk
+	if (!config.NoLocals || config.JournalRemotes) && config.Journal != "" {
 		pool.journal = newTxJournal(config.Journal)
 
-		if err := pool.journal.load(pool.AddLocals); err != nil {
+		if err := pool.journal.load(pool.AddLocals, pool.AddRemotesSync); err != nil {
 			log.Warn("Failed to load transaction journal", "err", err)
 		}
-		if err := pool.journal.rotate(pool.local()); err != nil {
+		pool.mu.Lock()
+		if err := pool.journal.rotate(pool.local(), pool.remoteJournal()); err != nil {
 			log.Warn("Failed to rotate transaction journal", "err", err)
 		}
+		pool.mu.Unlock()
 	}
 
 	// Subscribe events from blockchain and start the main event loop.
@@ -326,12 +358,16 @@ func (pool *TxPool) loop() {
 		report  = time.NewTicker(statsReportInterval)
 		evict   = time.NewTicker(evictionInterval)
 		journal = time.NewTicker(pool.config.Rejournal)
+		policy  = time.NewTicker(policyCheckInterval)
 		// Track the previous head headers for transaction reorgs
 		head = pool.chain.CurrentBlock()
+		// Track the modification time of the last policy file failing to load
+		policyFailed time.Time
 	)
 	defer report.Stop()
 	defer evict.Stop()
 	defer journal.Stop()
+	defer policy.Stop()
 
 	for {
 		select {
@@ -373,22 +409,96 @@ func (pool *TxPool) loop() {
 					for _, tx := range list {
 						pool.removeTx(tx.Hash(), true)
 					}
+					pool.markDropped(TxDropExpired, list...)
 					queuedEvictionMeter.Mark(int64(len(list)))
 				}
 			}
+			drops := pool.takeDropped()
 			pool.mu.Unlock()
+			pool.sendDropped(drops)
 
 		// Handle local transaction journal rotation
 		case <-journal.C:
 			if pool.journal != nil {
 				pool.mu.Lock()
-				if err := pool.journal.rotate(pool.local()); err != nil {
-					log.Warn("Failed to rotate local tx journal", "err", err)
+				if err := pool.journal.rotate(pool.local(), pool.remoteJournal()); err != nil {
+					log.Warn("Failed to rotate tx journal", "err", err)
 				}
 				pool.mu.Unlock()
 			}
+
+		// Handle admission policy changes
+		case <-policy.C:
+			if pool.config.PolicyFile == "" {
+				continue
+			}
+			info, err := os.Stat(pool.config.PolicyFile)
+			if err != nil {
+				continue
+			}
+			if current := pool.currentPolicy(); current != nil && info.ModTime().Equal(current.modTime) {
+				continue
+			}
+			// Don't retry an invalid file until it's modified again
+			if info.ModTime().Equal(policyFailed) {
+				continue
+			}
+			if err := pool.ReloadPolicy(); err != nil {
+				log.Warn("Failed to reload txpool policy", "file", pool.config.PolicyFile, "err", err)
+				policyFailed = info.ModTime()
+			}
+		}
+	}
+}
+
+// currentPolicy retrieves the admission policy in force.
+func (pool *TxPool) currentPolicy() *txPolicy {
+	return pool.policy.Load().(*txPolicy)
+}
+
+// ReloadPolicy reloads the admission policy from the configured policy file and
+// drops all the pooled transactions not admitted by the new policy. If the file
+// is invalid, the policy in force is retained.
+func (pool *TxPool) ReloadPolicy() error {
+	if pool.config.PolicyFile == "" {
+		return errors.New("no txpool policy file configured")
+	}
+	policy, err := loadTxPolicy(pool.config.PolicyFile)
+	if err != nil {
+		return err
+	}
+	pool.mu.Lock()
+	pool.policy.Store(policy)
+
+	var drop types.Transactions
+	pool.all.Range(func(hash common.Hash, tx *types.Transaction, local bool) bool {
+		from, _ := types.Sender(pool.signer, tx) // already validated
+		if policy.validate(tx, from) != nil {
+			drop = append(drop, tx)
 		}
+		return true
+	}, true, true)
+	for _, tx := range drop {
+		pool.removeTx(tx.Hash(), true)
 	}
+	pool.markDropped(TxDropPolicy, drop...)
+	drops := pool.takeDropped()
+	pool.mu.Unlock()
+
+	pool.sendDropped(drops)
+	policyDropMeter.Mark(int64(len(drop)))
+	log.Info("Reloaded txpool policy", "file", pool.config.PolicyFile, "dropped", len(drop))
+	return nil
+}
+
+// PeerBudget returns the number of transactions accepted from a single peer per
+// second and at once, as set by the admission policy. Zero rate means unlimited.
+func (pool *TxPool) PeerBudget() (float64, int) {
+	policy := pool.currentPolicy()
+	if policy == nil {
+		return 0, 0
+	}
+	return policy.peerTxRate, policy.peerTxBurst
 }
 
 // Stop terminates the transaction pool.
@@ -401,6 +511,15 @@ func (pool *TxPool) Stop() {
 	pool.wg.Wait()
 
 	if pool.journal != nil {
+		// Persist the latest remote transactions, the local ones are journaled
+		// as they arrive
+		if pool.config.JournalRemotes {
+			pool.mu.Lock()
+			if err := pool.journal.rotate(pool.local(), pool.remoteJournal()); err != nil {
+				log.Warn("Failed to rotate tx journal", "err", err)
+			}
+			pool.mu.Unlock()
+		}
 		pool.journal.close()
 	}
 	log.Info("Transaction pool stopped")
@@ -412,6 +531,68 @@ func (pool *TxPool) SubscribeNewTxsEvent(ch chan<- NewTxsEvent) event.Subscripti
 	return pool.scope.Track(pool.txFeed.Subscribe(ch))
 }
 
+// SubscribeDropTxsEvent registers a subscription of DropTxsEvent and
+// starts sending event to the given channel.
+func (pool *TxPool) SubscribeDropTxsEvent(ch chan<- DropTxsEvent) event.Subscription {
+	return pool.scope.Track(pool.dropFeed.Subscribe(ch))
+}
+
+// markDropped records the given transactions as dropped for the reason, to be
+// announced once the pool lock is released.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) markDropped(reason TxDropReason, txs ...*types.Transaction) {
+	if len(txs) == 0 {
+		return
+	}
+	// Batch up consecutive drops of the same reason
+	var ev *DropTxsEvent
+	if n := len(pool.dropped); n > 0 && pool.dropped[n-1].Reason == reason {
+		ev = &pool.dropped[n-1]
+	} else {
+		pool.dropped = append(pool.dropped, DropTxsEvent{Reason: reason})
+		ev = &pool.dropped[len(pool.dropped)-1]
+	}
+	for _, tx := range txs {
+		ev.Hashes = append(ev.Hashes, tx.Hash())
+	}
+}
+
+// markReplacedOnChain records the transactions whose nonce was used up by the
+// chain as dropped, unless they were included in the new blocks themselves. If
+// the included transactions are unknown (e.g. deep reorg), nothing is recorded.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) markReplacedOnChain(txs types.Transactions) {
+	if pool.inclusions == nil {
+		return
+	}
+	var replaced types.Transactions
+	for _, tx := range txs {
+		if _, ok := pool.inclusions[tx.Hash()]; !ok {
+			replaced = append(replaced, tx)
+		}
+	}
+	pool.markDropped(TxDropReplacedOnChain, replaced...)
+}
+
+// takeDropped retrieves and clears the drops recorded since the last call.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) takeDropped() []DropTxsEvent {
+	drops := pool.dropped
+	pool.dropped = nil
+	return drops
+}
+
+// sendDropped announces the given drops. It must be called without holding the
+// pool lock, as subscribers might call back into the pool.
+func (pool *TxPool) sendDropped(drops []DropTxsEvent) {
+	for _, ev := range drops {
+		pool.dropFeed.Send(ev)
+	}
+}
+
 // SmokePrice returns the current smoke price enforced by the transaction pool.
 func (pool *TxPool) SmokePrice() *big.Int {
 	pool.mu.RLock()
@@ -424,12 +605,16 @@ func (pool *TxPool) SmokePrice() *big.Int {
 // new transaction, and drops all transactions below this threshold.
 func (pool *TxPool) SetSmokePrice(price *big.Int) {
 	pool.mu.Lock()
-	defer pool.mu.Unlock()
-
 	pool.smokePrice = price
-	for _, tx := range pool.priced.Cap(price) {
+	drop := pool.priced.Cap(price)
+	for _, tx := range drop {
 		pool.removeTx(tx.Hash(), false)
 	}
+	pool.markDropped(TxDropUnderpriced, drop...)
+	drops := pool.takeDropped()
+	pool.mu.Unlock()
+
+	pool.sendDropped(drops)
 	log.Info("Transaction pool price threshold updated", "price", price)
 }
 
@@ -482,6 +667,44 @@ func (pool *TxPool) Content() (map[common.Address]types.Transactions, map[common
 	return pending, queued
 }
 
+// ContentFrom retrieves the data content of the transaction pool, returning the
+// pending as well as queued transactions of this address, grouped by nonce.
+func (pool *TxPool) ContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	var pending types.Transactions
+	if list, ok := pool.pending[addr]; ok {
+		pending = list.Flatten()
+	}
+	var queued types.Transactions
+	if list, ok := pool.queue[addr]; ok {
+		queued = list.Flatten()
+	}
+	return pending, queued
+}
+
+// Accounts retrieves the accounts with pending or queued transactions in the
+// pool, sorted by address.
+func (pool *TxPool) Accounts() []common.Address {
+	pool.mu.RLock()
+	accounts := make([]common.Address, 0, len(pool.pending)+len(pool.queue))
+	for addr := range pool.pending {
+		accounts = append(accounts, addr)
+	}
+	for addr := range pool.queue {
+		if _, ok := pool.pending[addr]; !ok {
+			accounts = append(accounts, addr)
+		}
+	}
+	pool.mu.RUnlock()
+
+	sort.Slice(accounts, func(i, j int) bool {
+		return bytes.Compare(accounts[i][:], accounts[j][:]) < 0
+	})
+	return accounts
+}
+
 // Pending retrieves all currently processable transactions, grouped by origin
 // account and sorted by nonce. The returned transaction set is a copy and can be
 // freely modified by calling code.
@@ -520,6 +743,38 @@ func (pool *TxPool) local() map[common.Address]types.Transactions {
 	return txs
 }
 
+// remoteJournal retrieves the remote pending transactions to journal if enabled,
+// the best paying first, up to the configured size. The pool lock is assumed to
+// be held.
+func (pool *TxPool) remoteJournal() types.Transactions {
+	if !pool.config.JournalRemotes {
+		return nil
+	}
+	pending := make(map[common.Address]types.Transactions)
+	for addr, list := range pool.pending {
+		if !pool.locals.contains(addr) {
+			pending[addr] = list.Flatten()
+		}
+	}
+	var (
+		txs  types.Transactions
+		size uint64
+		set  = types.NewTransactionsByPriceAndNonce(pool.signer, pending)
+	)
+	for tx := set.Peek(); tx != nil; tx = set.Peek() {
+		// Skip the rest of the account if the transaction doesn't fit, as the
+		// later ones would be nonce-gapped
+		if size+uint64(tx.Size()) > pool.config.JournalRemoteSize {
+			set.Pop()
+			continue
+		}
+		size += uint64(tx.Size())
+		txs = append(txs, tx)
+		set.Shift()
+	}
+	return txs
+}
+
 // validateTx checks if a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
@@ -545,6 +800,10 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if err != nil {
 		return ErrInvalidSender
 	}
+	// Ensure the transaction is admitted by the policy in force
+	if err := pool.currentPolicy().validate(tx, from); err != nil {
+		return err
+	}
 	// Drop non-local transactions under our own minimal accepted smoke price
 	if !local && tx.SmokePriceIntCmp(pool.smokePrice) < 0 {
 		return ErrUnderpriced
@@ -619,6 +878,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 			underpricedTxMeter.Mark(1)
 			pool.removeTx(tx.Hash(), false)
 		}
+		pool.markDropped(TxDropUnderpriced, drop...)
 	}
 	// Try to replace an existing transaction in the pending pool
 	from, _ := types.Sender(pool.signer, tx) // already validated
@@ -633,6 +893,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 		if old != nil {
 			pool.all.Remove(old.Hash())
 			pool.priced.Removed(1)
+			pool.markDropped(TxDropReplaced, old)
 			pendingReplaceMeter.Mark(1)
 		}
 		pool.all.Add(tx, isLocal)
@@ -684,6 +945,7 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction, local boo
 	if old != nil {
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed(1)
+		pool.markDropped(TxDropReplaced, old)
 		queuedReplaceMeter.Mark(1)
 	} else {
 		// Nothing was replaced, bump the queued counter
@@ -831,8 +1093,11 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
 	// Process all the new transaction and merge any errors into the original slice
 	pool.mu.Lock()
 	newErrs, dirtyAddrs := pool.addTxsLocked(news, local)
+	drops := pool.takeDropped()
 	pool.mu.Unlock()
 
+	pool.sendDropped(drops)
+
 	var nilSlot = 0
 	for _, err := range newErrs {
 		for errs[nilSlot] != nil {
@@ -1092,14 +1357,19 @@ func (pool *TxPool) runReorg(done chan struct{}, reset *txpoolResetRequest, dirt
 	// Ensure pool.queue and pool.pending sizes stay within the configured limits.
 	pool.truncatePending()
 	pool.truncateQueue()
+	pool.inclusions = nil
 
 	// Update all accounts to the latest known pending nonce
 	for addr, list := range pool.pending {
 		highestPending := list.LastElement()
 		pool.pendingNonces.set(addr, highestPending.Nonce()+1)
 	}
+	drops := pool.takeDropped()
 	pool.mu.Unlock()
 
+	// Notify subsystems for dropped transactions
+	pool.sendDropped(drops)
+
 	// Notify subsystems for newly added transactions
 	for _, tx := range promoted {
 		addr, _ := types.Sender(pool.signer, tx)
@@ -1123,6 +1393,14 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	// If we're reorging an old state, reinject all dropped transactions
 	var reinject types.Transactions
 
+	// Track the transactions included by the new blocks, so that the pooled ones
+	// whose nonce was used up by a different transaction can be reported
+	pool.inclusions = nil
+	if oldHead != nil && newHead != nil && oldHead.Hash() == newHead.ParentHash {
+		if block := pool.chain.GetBlock(newHead.Hash(), newHead.Number.Uint64()); block != nil {
+			pool.inclusions = txHashSet(block.Transactions())
+		}
+	}
 	if oldHead != nil && oldHead.Hash() != newHead.ParentHash {
 		// If the reorg is too deep, avoid doing it (will happen during fast sync)
 		oldNum := oldHead.Number.Uint64()
@@ -1180,6 +1458,7 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 					}
 				}
 				reinject = types.TxDifference(discarded, included)
+				pool.inclusions = txHashSet(included)
 			}
 		}
 	}
@@ -1226,6 +1505,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 			hash := tx.Hash()
 			pool.all.Remove(hash)
 		}
+		pool.markReplacedOnChain(forwards)
 		log.Trace("Removed old queued transactions", "count", len(forwards))
 		// Drop all transactions that are too costly (low balance or out of smoke)
 		drops, _ := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxSmoke)
@@ -1233,6 +1513,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 			hash := tx.Hash()
 			pool.all.Remove(hash)
 		}
+		pool.markDropped(TxDropUnpayable, drops...)
 		log.Trace("Removed unpayable queued transactions", "count", len(drops))
 		queuedNofundsMeter.Mark(int64(len(drops)))
 
@@ -1256,6 +1537,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 				pool.all.Remove(hash)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
 			}
+			pool.markDropped(TxDropQueueLimit, caps...)
 			queuedRateLimitMeter.Mark(int64(len(caps)))
 		}
 		// Mark all the items dropped as removed
@@ -1321,6 +1603,7 @@ func (pool *TxPool) truncatePending() {
 						pool.pendingNonces.setIfLower(offenders[i], tx.Nonce())
 						log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
 					}
+					pool.markDropped(TxDropPendingLimit, caps...)
 					pool.priced.Removed(len(caps))
 					pendingGauge.Dec(int64(len(caps)))
 					if pool.locals.contains(offenders[i]) {
@@ -1348,6 +1631,7 @@ func (pool *TxPool) truncatePending() {
 					pool.pendingNonces.setIfLower(addr, tx.Nonce())
 					log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
 				}
+				pool.markDropped(TxDropPendingLimit, caps...)
 				pool.priced.Removed(len(caps))
 				pendingGauge.Dec(int64(len(caps)))
 				if pool.locals.contains(addr) {
@@ -1388,9 +1672,11 @@ func (pool *TxPool) truncateQueue() {
 
 		// Drop all transactions if they are less than the overflow
 		if size := uint64(list.Len()); size <= drop {
-			for _, tx := range list.Flatten() {
+			txs := list.Flatten()
+			for _, tx := range txs {
 				pool.removeTx(tx.Hash(), true)
 			}
+			pool.markDropped(TxDropQueueLimit, txs...)
 			drop -= size
 			queuedRateLimitMeter.Mark(int64(size))
 			continue
@@ -1399,6 +1685,7 @@ func (pool *TxPool) truncateQueue() {
 		txs := list.Flatten()
 		for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
 			pool.removeTx(txs[i].Hash(), true)
+			pool.markDropped(TxDropQueueLimit, txs[i])
 			drop--
 			queuedRateLimitMeter.Mark(1)
 		}
@@ -1420,6 +1707,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			pool.all.Remove(hash)
 			log.Trace("Removed old pending transaction", "hash", hash)
 		}
+		pool.markReplacedOnChain(olds)
 		// Drop all transactions that are too costly (low balance or out of smoke), and queue any invalids back for later
 		drops, invalids := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxSmoke)
 		for _, tx := range drops {
@@ -1427,6 +1715,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
 			pool.all.Remove(hash)
 		}
+		pool.markDropped(TxDropUnpayable, drops...)
 		pool.priced.Removed(len(olds) + len(drops))
 		pendingNofundsMeter.Mark(int64(len(drops)))
 
@@ -1713,3 +2002,12 @@ func (t *txLookup) RemoteToLocals(locals *accountSet) int {
 func numSlots(tx *types.Transaction) int {
 	return int((tx.Size() + txSlotSize - 1) / txSlotSize)
 }
+
+// txHashSet returns the set of hashes of the given transactions.
+func txHashSet(txs types.Transactions) map[common.Hash]struct{} {
+	set := make(map[common.Hash]struct{}, len(txs))
+	for _, tx := range txs {
+		set[tx.Hash()] = struct{}{}
+	}
+	return set
+}
@@ -17,6 +17,7 @@
 package core
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"errors"
 	"fmt"
@@ -24,6 +25,7 @@ import (
 	"math/big"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -1746,6 +1748,103 @@ func TestTransactionReplacement(t *testing.T) {
 	}
 }
 
+// Tests that transactions dropped from the pool are announced along with the
+// reason of the drop.
+func TestTransactionDropEvents(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	drops := make(chan DropTxsEvent, 8)
+	sub := pool.SubscribeDropTxsEvent(drops)
+	defer sub.Unsubscribe()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	expect := func(reason TxDropReason, txs ...*types.Transaction) {
+		t.Helper()
+		select {
+		case ev := <-drops:
+			if ev.Reason != reason {
+				t.Fatalf("drop reason mismatch: have %s, want %s", ev.Reason, reason)
+			}
+			if len(ev.Hashes) != len(txs) {
+				t.Fatalf("dropped hash count mismatch: have %d, want %d", len(ev.Hashes), len(txs))
+			}
+			for i, tx := range txs {
+				if ev.Hashes[i] != tx.Hash() {
+					t.Errorf("dropped hash %d mismatch: have %x, want %x", i, ev.Hashes[i], tx.Hash())
+				}
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("drop event %s timeout", reason)
+		}
+	}
+	// Replace a pending transaction and ensure the old one is announced
+	original := pricedTransaction(0, 100000, big.NewInt(1), key)
+	if err := pool.addRemoteSync(original); err != nil {
+		t.Fatalf("failed to add original transaction: %v", err)
+	}
+	replacement := pricedTransaction(0, 100000, big.NewInt(2), key)
+	if err := pool.addRemoteSync(replacement); err != nil {
+		t.Fatalf("failed to replace transaction: %v", err)
+	}
+	expect(TxDropReplaced, original)
+
+	// Raise the minimum price above the replacement and ensure it's announced
+	pool.SetSmokePrice(big.NewInt(10))
+	expect(TxDropUnderpriced, replacement)
+
+	select {
+	case ev := <-drops:
+		t.Fatalf("unexpected drop event: %v", ev)
+	default:
+	}
+}
+
+// Tests that transactions removed because the chain used up their nonces are
+// not announced as dropped if the mined transactions are unknown, since they
+// were most likely included themselves.
+func TestTransactionDropEventsIncluded(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	drops := make(chan DropTxsEvent, 8)
+	sub := pool.SubscribeDropTxsEvent(drops)
+	defer sub.Unsubscribe()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(from, big.NewInt(1000000000))
+
+	// Add a pending and a gapped queued transaction, then mine both
+	if err := pool.addRemoteSync(transaction(0, 100000, key)); err != nil {
+		t.Fatalf("failed to add pending transaction: %v", err)
+	}
+	if err := pool.addRemoteSync(transaction(2, 100000, key)); err != nil {
+		t.Fatalf("failed to add queued transaction: %v", err)
+	}
+	if pending, queued := pool.Stats(); pending != 1 || queued != 1 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want 1/1", pending, queued)
+	}
+	pool.currentState.SetNonce(from, 3)
+	<-pool.requestReset(nil, nil)
+
+	if pending, queued := pool.Stats(); pending != 0 || queued != 0 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want 0/0", pending, queued)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	select {
+	case ev := <-drops:
+		t.Fatalf("included transactions announced as dropped: %v", ev)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
 // Tests that local transactions are journaled to disk, but remote transactions
 // get discarded between restarts.
 func TestTransactionJournaling(t *testing.T)         { testTransactionJournaling(t, false) }
@@ -1859,6 +1958,66 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 	pool.Stop()
 }
 
+// Tests that the best paying remote pending transactions are journaled within
+// the configured size if enabled, and restored after a restart.
+func TestTransactionJournalingRemotes(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	journal := filepath.Join(dir, "transactions.rlp")
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		statedb.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000000))
+	}
+	txs := types.Transactions{
+		pricedTransaction(0, 100000, big.NewInt(1), keys[0]),
+		pricedTransaction(0, 100000, big.NewInt(2), keys[1]),
+		pricedTransaction(0, 100000, big.NewInt(3), keys[2]),
+		pricedTransaction(1, 100000, big.NewInt(3), keys[2]),
+	}
+	// Only leave room for the best paying transactions
+	config := testTxPoolConfig
+	config.Journal = journal
+	config.JournalRemotes = true
+	config.JournalRemoteSize = uint64(txs[1].Size() + txs[2].Size() + txs[3].Size())
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	for i, err := range pool.AddRemotesSync(txs) {
+		if err != nil {
+			t.Fatalf("failed to add remote transaction %d: %v", i, err)
+		}
+	}
+	pool.Stop()
+
+	stats, err := InspectTxJournal(journal)
+	if err != nil {
+		t.Fatalf("failed to inspect journal: %v", err)
+	}
+	if stats.Version != txJournalVersion || stats.Remotes != 3 || stats.Locals != 0 {
+		t.Fatalf("journal contents mismatch: %+v", stats)
+	}
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	for i, tx := range txs {
+		if known := pool.Get(tx.Hash()) != nil; known != (i > 0) {
+			t.Errorf("transaction %d: restored mismatch: have %v, want %v", i, known, i > 0)
+		}
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // TestTransactionStatusCheck tests that the pool can correctly retrieve the
 // pending status of individual transactions.
 func TestTransactionStatusCheck(t *testing.T) {
@@ -2052,3 +2211,275 @@ func BenchmarkInsertRemoteWithAllLocals(b *testing.B) {
 		pool.Stop()
 	}
 }
+
+// Tests that the admission policy is enforced on new transactions and that
+// reloading it drops the pooled transactions it doesn't admit any more.
+func TestTransactionPolicy(t *testing.T) {
+	t.Parallel()
+
+	file, err := ioutil.TempFile("", "txpolicy")
+	if err != nil {
+		t.Fatalf("failed to create temporary policy: %v", err)
+	}
+	defer os.Remove(file.Name())
+	file.Close()
+
+	var (
+		denied, _  = crypto.GenerateKey()
+		creator, _ = crypto.GenerateKey()
+		other, _   = crypto.GenerateKey()
+		recipient  = common.HexToAddress("0xdeadbeef")
+	)
+	writePolicy := func(policy string) {
+		if err := ioutil.WriteFile(file.Name(), []byte(policy), 0600); err != nil {
+			t.Fatalf("failed to write policy: %v", err)
+		}
+	}
+	writePolicy(fmt.Sprintf(`{"denySenders": ["%s"], "noCreation": true, "creators": ["%s"], "maxTxSmoke": 100000, "peerTxRate": 10}`,
+		crypto.PubkeyToAddress(denied.PublicKey).Hex(), crypto.PubkeyToAddress(creator.PublicKey).Hex()))
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.PolicyFile = file.Name()
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	for _, key := range []*ecdsa.PrivateKey{denied, creator, other} {
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	}
+	if rate, burst := pool.PeerBudget(); rate != 10 || burst != 1 {
+		t.Fatalf("peer budget mismatch: have %v/%d, want 10/1", rate, burst)
+	}
+	create := func(key *ecdsa.PrivateKey) *types.Transaction {
+		tx, _ := types.SignTx(types.NewContractCreation(0, big.NewInt(0), 60000, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+		return tx
+	}
+	transfer := func(key *ecdsa.PrivateKey, to common.Address, smoke uint64) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTransaction(0, to, big.NewInt(1), smoke, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+		return tx
+	}
+	tests := []struct {
+		tx  *types.Transaction
+		err error
+	}{
+		{transfer(denied, recipient, params.TxSmoke), ErrSenderDenied},
+		{create(other), ErrCreationDenied},
+		{transfer(other, recipient, 150000), ErrTxSmokeLimit},
+		{transfer(other, recipient, params.TxSmoke), nil},
+		{create(creator), nil},
+	}
+	for i, tt := range tests {
+		if err := pool.AddRemote(tt.tx); !errors.Is(err, tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	// Local transactions are subject to the policy too
+	if err := pool.AddLocal(transfer(denied, recipient, params.TxSmoke)); !errors.Is(err, ErrSenderDenied) {
+		t.Errorf("local transaction error mismatch: have %v, want %v", err, ErrSenderDenied)
+	}
+	// Restrict the senders and make sure the pooled transactions are dropped
+	writePolicy(fmt.Sprintf(`{"allowSenders": ["%s"], "denyRecipients": ["%s"]}`, crypto.PubkeyToAddress(creator.PublicKey).Hex(), recipient.Hex()))
+	if err := pool.ReloadPolicy(); err != nil {
+		t.Fatalf("failed to reload policy: %v", err)
+	}
+	if pending, queued := pool.Stats(); pending != 1 || queued != 0 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want 1/0", pending, queued)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	if err := pool.AddRemote(transfer(other, common.Address{}, params.TxSmoke)); !errors.Is(err, ErrSenderDenied) {
+		t.Errorf("sender error mismatch: have %v, want %v", err, ErrSenderDenied)
+	}
+	tx, _ := types.SignTx(types.NewTransaction(1, recipient, big.NewInt(1), params.TxSmoke, big.NewInt(1), nil), types.HomesteadSigner{}, creator)
+	if err := pool.AddRemote(tx); !errors.Is(err, ErrRecipientDenied) {
+		t.Errorf("recipient error mismatch: have %v, want %v", err, ErrRecipientDenied)
+	}
+	if rate, _ := pool.PeerBudget(); rate != 0 {
+		t.Errorf("peer budget not lifted: have %v", rate)
+	}
+	// Invalid policies should be rejected, retaining the current one
+	writePolicy(`{"denySenders": ["not an address"]}`)
+	if err := pool.ReloadPolicy(); err == nil {
+		t.Fatalf("invalid policy accepted")
+	}
+	if err := pool.AddRemote(transfer(other, common.Address{}, params.TxSmoke)); !errors.Is(err, ErrSenderDenied) {
+		t.Errorf("policy not retained: have %v, want %v", err, ErrSenderDenied)
+	}
+}
+
+// Tests that the pool rejects all transactions if the configured policy file
+// can't be loaded, until a valid policy is loaded.
+func TestTransactionPolicyUnavailable(t *testing.T) {
+	t.Parallel()
+
+	file, err := ioutil.TempFile("", "txpolicy")
+	if err != nil {
+		t.Fatalf("failed to create temporary policy: %v", err)
+	}
+	defer os.Remove(file.Name())
+	file.Close()
+
+	if err := ioutil.WriteFile(file.Name(), []byte(`{"denySenders": ["not an address"]}`), 0600); err != nil {
+		t.Fatalf("failed to write policy: %v", err)
+	}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.PolicyFile = file.Name()
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	if err := pool.AddRemote(transaction(0, 100000, key)); !errors.Is(err, ErrPolicyUnavailable) {
+		t.Fatalf("remote transaction error mismatch: have %v, want %v", err, ErrPolicyUnavailable)
+	}
+	if err := pool.AddLocal(transaction(0, 100000, key)); !errors.Is(err, ErrPolicyUnavailable) {
+		t.Fatalf("local transaction error mismatch: have %v, want %v", err, ErrPolicyUnavailable)
+	}
+	// A failing reload should keep rejecting everything
+	if err := pool.ReloadPolicy(); err == nil {
+		t.Fatalf("invalid policy accepted")
+	}
+	if err := pool.AddRemote(transaction(0, 100000, key)); !errors.Is(err, ErrPolicyUnavailable) {
+		t.Fatalf("remote transaction error mismatch: have %v, want %v", err, ErrPolicyUnavailable)
+	}
+	// Fix the policy and make sure transactions are admitted again
+	if err := ioutil.WriteFile(file.Name(), []byte(`{}`), 0600); err != nil {
+		t.Fatalf("failed to write policy: %v", err)
+	}
+	if err := pool.ReloadPolicy(); err != nil {
+		t.Fatalf("failed to reload policy: %v", err)
+	}
+	if err := pool.AddRemote(transaction(0, 100000, key)); err != nil {
+		t.Fatalf("transaction rejected by valid policy: %v", err)
+	}
+}
+
+// Tests that the pool content can be retrieved per account.
+func TestTransactionContentFrom(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	other, _ := crypto.GenerateKey()
+	for _, k := range []*ecdsa.PrivateKey{key, other} {
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(k.PublicKey), big.NewInt(1000000000))
+	}
+	// Add a pending and a queued transaction for the first account only
+	pool.AddRemotesSync([]*types.Transaction{transaction(0, 100000, key), transaction(2, 100000, key), transaction(0, 100000, other)})
+
+	pending, queued := pool.ContentFrom(crypto.PubkeyToAddress(key.PublicKey))
+	if len(pending) != 1 || pending[0].Nonce() != 0 {
+		t.Errorf("pending transactions mismatch: have %v", pending)
+	}
+	if len(queued) != 1 || queued[0].Nonce() != 2 {
+		t.Errorf("queued transactions mismatch: have %v", queued)
+	}
+	if pending, queued := pool.ContentFrom(common.Address{}); len(pending) != 0 || len(queued) != 0 {
+		t.Errorf("unknown account has transactions: %d pending, %d queued", len(pending), len(queued))
+	}
+	accounts := pool.Accounts()
+	if len(accounts) != 2 {
+		t.Fatalf("account count mismatch: have %d, want 2", len(accounts))
+	}
+	if bytes.Compare(accounts[0][:], accounts[1][:]) >= 0 {
+		t.Errorf("accounts not sorted: %x", accounts)
+	}
+}
+
+// inclusionBlockChain is a test blockchain serving a single mined block on top
+// of the current head.
+type inclusionBlockChain struct {
+	*testBlockChain
+	block *types.Block
+}
+
+func (bc *inclusionBlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	if hash == bc.block.Hash() {
+		return bc.block
+	}
+	return bc.testBlockChain.GetBlock(hash, number)
+}
+
+// Tests that pooled transactions whose nonce was used up by a different mined
+// transaction are announced as dropped, while the mined ones are not.
+func TestTransactionDropEventsReplacedOnChain(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &inclusionBlockChain{testBlockChain: &testBlockChain{statedb, 10000000, new(event.Feed)}}
+
+	key, _ := crypto.GenerateKey()
+	pool := NewTxPool(testTxPoolConfig, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	drops := make(chan DropTxsEvent, 8)
+	sub := pool.SubscribeDropTxsEvent(drops)
+	defer sub.Unsubscribe()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(from, big.NewInt(1000000000))
+
+	// Add two pending and a gapped queued transaction
+	txs := []*types.Transaction{transaction(0, 100000, key), transaction(1, 100000, key), transaction(3, 100000, key)}
+	for i, tx := range txs {
+		if err := pool.addRemoteSync(tx); err != nil {
+			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
+		}
+	}
+	if pending, queued := pool.Stats(); pending != 2 || queued != 1 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want 2/1", pending, queued)
+	}
+	// Mine the first transaction along with different ones for the other nonces
+	mined := types.Transactions{txs[0], transaction(1, 200000, key), transaction(2, 200000, key), transaction(3, 200000, key)}
+	parent := blockchain.CurrentBlock().Header()
+	blockchain.block = types.NewBlock(&types.Header{
+		ParentHash: parent.Hash(),
+		Number:     big.NewInt(1),
+		SmokeLimit: parent.SmokeLimit,
+	}, mined, nil, nil, trie.NewStackTrie(nil))
+
+	pool.currentState.SetNonce(from, 4)
+	<-pool.requestReset(parent, blockchain.block.Header())
+
+	if pending, queued := pool.Stats(); pending != 0 || queued != 0 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want 0/0", pending, queued)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	dropped := make(map[common.Hash]bool)
+	for len(dropped) < 2 {
+		select {
+		case ev := <-drops:
+			if ev.Reason != TxDropReplacedOnChain {
+				t.Fatalf("drop reason mismatch: have %v, want %v", ev.Reason, TxDropReplacedOnChain)
+			}
+			for _, hash := range ev.Hashes {
+				dropped[hash] = true
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("replaced transactions not announced as dropped, have %d", len(dropped))
+		}
+	}
+	if dropped[txs[0].Hash()] {
+		t.Errorf("mined transaction announced as dropped")
+	}
+	if !dropped[txs[1].Hash()] || !dropped[txs[2].Hash()] {
+		t.Errorf("replaced transactions missing from drops: %v", dropped)
+	}
+	select {
+	case ev := <-drops:
+		t.Fatalf("unexpected drop event: %v", ev)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/core/types"
+	"github.com/holiman/uint256"
+)
+
+func TestAccessListTracer(t *testing.T) {
+	var (
+		from     = common.HexToAddress("0x01")
+		to       = common.HexToAddress("0x02")
+		other    = common.HexToAddress("0x03")
+		precomp  = common.HexToAddress("0x04")
+		mem      = NewMemory()
+		stack    = newstack()
+		rstack   = newReturnStack()
+		contract = NewContract(&dummyContractRef{}, &dummyContractRef{}, new(big.Int), 0)
+	)
+	tracer := NewAccessListTracer(nil, from, to, []common.Address{precomp})
+
+	// Storage accesses are always tracked against the executing contract
+	stack.push(uint256.NewInt().SetUint64(1))
+	tracer.CaptureState(nil, 0, SLOAD, 0, 0, mem, stack, rstack, nil, contract, 0, nil)
+
+	// Account accesses are tracked unless the account is excluded
+	for _, addr := range []common.Address{from, to, precomp, other} {
+		stack.push(new(uint256.Int).SetBytes(addr.Bytes()))
+		tracer.CaptureState(nil, 0, BALANCE, 0, 0, mem, stack, rstack, nil, contract, 0, nil)
+	}
+	acl := tracer.AccessList()
+	if len(acl) != 2 {
+		t.Fatalf("access list length mismatch: have %d, want 2: %v", len(acl), acl)
+	}
+	want := NewAccessListTracer(types.AccessList{
+		{Address: contract.Address(), StorageKeys: []common.Hash{common.BigToHash(big.NewInt(1))}},
+		{Address: other},
+	}, from, to, []common.Address{precomp})
+
+	if !tracer.Equal(want) {
+		t.Errorf("access list mismatch: have %v, want %v", acl, want.AccessList())
+	}
+	if tracer.Equal(NewAccessListTracer(nil, from, to, nil)) {
+		t.Errorf("access list matched empty list")
+	}
+}
@@ -16,6 +16,8 @@
 
 package vm
 
+import "fmt"
+
 // bitvec is a bit vector which maps bytes in a program.
 // An unset bit means the byte is an opcode, a set bit means
 // it's data (i.e. argument of PUSHxx).
@@ -60,3 +62,21 @@ func codeBitmap(code []byte) bitvec {
 	}
 	return bits
 }
+
+// validateOpcodes checks that the code only consists of instructions defined
+// in the given instruction set, and that it does not end in a truncated PUSH.
+func validateOpcodes(code []byte, jt *JumpTable) error {
+	for pc := uint64(0); pc < uint64(len(code)); pc++ {
+		op := OpCode(code[pc])
+		if jt[op] == nil {
+			return fmt.Errorf("undefined opcode %#x at pc %d", byte(op), pc)
+		}
+		if op >= PUSH1 && op <= PUSH32 {
+			pc += uint64(op - PUSH1 + 1)
+			if pc >= uint64(len(code)) {
+				return fmt.Errorf("truncated %v at end of code", op)
+			}
+		}
+	}
+	return nil
+}
@@ -58,6 +58,8 @@ type Contract struct {
 	CodeAddr *common.Address
 	Input    []byte
 
+	container []byte // Full EOF container if Code is only its code section
+
 	Smoke   uint64
 	value *big.Int
 }
@@ -203,6 +205,15 @@ func (c *Contract) SetCallCode(addr *common.Address, hash common.Hash, code []by
 	c.CodeAddr = addr
 }
 
+// deployedCode returns the code as stored in the state, which for EOF contracts
+// is the whole container and not just the executed code section.
+func (c *Contract) deployedCode() []byte {
+	if c.container != nil {
+		return c.container
+	}
+	return c.Code
+}
+
 // SetCodeOptionalHash can be used to provide code, but it's optional to provide hash.
 // In case hash is not provided, the jumpdest analysis will not be saved to the parent context
 func (c *Contract) SetCodeOptionalHash(addr *common.Address, codeAndHash *codeAndHash) {
@@ -30,6 +30,9 @@ var activators = map[int]func(*JumpTable){
 	1884: enable1884,
 	1344: enable1344,
 	2315: enable2315,
+	3540: enable3540,
+	3541: enable3541,
+	3670: enable3670,
 }
 
 // EnableEIP enables the given EIP on the config.
@@ -44,6 +47,16 @@ func EnableEIP(eipNum int, jt *JumpTable) error {
 	return nil
 }
 
+// hasEIP checks whether the given EIP is among the list of enabled ones.
+func hasEIP(eips []int, eip int) bool {
+	for _, e := range eips {
+		if e == eip {
+			return true
+		}
+	}
+	return false
+}
+
 func ValidEip(eipNum int) bool {
 	_, ok := activators[eipNum]
 	return ok
@@ -172,3 +185,19 @@ func enable2929(jt *JumpTable) {
 	jt[SELFDESTRUCT].constantSmoke = params.SelfdestructSmokeEIP150
 	jt[SELFDESTRUCT].dynamicSmoke = smokeSelfdestructEIP2929
 }
+
+// enable3540 applies EIP-3540 "EVM Object Format (EOF) v1". The instruction set
+// itself is unchanged: the interpreter checks for the EIP among the enabled
+// ones, validating containers on contract creation and executing only their
+// code section.
+func enable3540(jt *JumpTable) {}
+
+// enable3541 applies EIP-3541 "Reject new contracts starting with the 0xEF byte".
+// The instruction set is unchanged, the interpreter rejects such code on contract
+// creation, reserving the prefix for EOF containers.
+func enable3541(jt *JumpTable) {}
+
+// enable3670 applies EIP-3670 "EOF - Code Validation". As with EIP-3540 the
+// instruction set is unchanged, the interpreter additionally rejects containers
+// with undefined opcodes or truncated PUSH data on contract creation.
+func enable3670(jt *JumpTable) {}
@@ -0,0 +1,149 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// EOF1 container format, as defined by EIP-3540:
+//
+//	magic ++ version ++ (section_kind ++ section_size)+ ++ terminator ++ sections
+//
+// with a mandatory code section followed by an optional data section. Section
+// sizes are 2 byte big endian numbers and sections may not be empty.
+const (
+	eofMagic0  = 0xEF
+	eofMagic1  = 0x00
+	eofVersion = 0x01
+
+	eofSectionTerminator = 0x00
+	eofSectionCode       = 0x01
+	eofSectionData       = 0x02
+)
+
+// eofContainer is a parsed EOF1 container.
+type eofContainer struct {
+	code []byte // Executable code section
+	data []byte // Data section, nil if the container has none
+}
+
+// hasEOFMagic checks whether the code starts with the EOF magic, i.e. if it is
+// meant to be an EOF container rather than legacy code.
+func hasEOFMagic(code []byte) bool {
+	return len(code) >= 2 && code[0] == eofMagic0 && code[1] == eofMagic1
+}
+
+// parseEOF parses an EOF1 container, checking the validity of its header and
+// that the section sizes match the container size.
+func parseEOF(code []byte) (*eofContainer, error) {
+	if !hasEOFMagic(code) {
+		return nil, fmt.Errorf("%w: missing magic", ErrInvalidEOF)
+	}
+	if len(code) < 3 || code[2] != eofVersion {
+		return nil, fmt.Errorf("%w: unsupported version", ErrInvalidEOF)
+	}
+	var (
+		pos   = 3
+		kinds []byte
+		sizes []int
+	)
+	for {
+		if pos >= len(code) {
+			return nil, fmt.Errorf("%w: missing section terminator", ErrInvalidEOF)
+		}
+		kind := code[pos]
+		pos++
+		if kind == eofSectionTerminator {
+			break
+		}
+		switch {
+		case kind == eofSectionCode && len(kinds) == 0:
+		case kind == eofSectionData && len(kinds) == 1:
+		default:
+			return nil, fmt.Errorf("%w: unexpected section kind %d", ErrInvalidEOF, kind)
+		}
+		if pos+2 > len(code) {
+			return nil, fmt.Errorf("%w: truncated section size", ErrInvalidEOF)
+		}
+		size := int(binary.BigEndian.Uint16(code[pos:]))
+		if size == 0 {
+			return nil, fmt.Errorf("%w: empty section", ErrInvalidEOF)
+		}
+		pos += 2
+
+		kinds = append(kinds, kind)
+		sizes = append(sizes, size)
+	}
+	if len(kinds) == 0 {
+		return nil, fmt.Errorf("%w: missing code section", ErrInvalidEOF)
+	}
+	total := pos
+	for _, size := range sizes {
+		total += size
+	}
+	if total != len(code) {
+		return nil, fmt.Errorf("%w: container size %d, sections need %d", ErrInvalidEOF, len(code), total)
+	}
+	container := &eofContainer{code: code[pos : pos+sizes[0]]}
+	if len(sizes) > 1 {
+		container.data = code[pos+sizes[0]:]
+	}
+	return container, nil
+}
+
+// validateEOF parses an EOF1 container and, if EIP-3670 is active, ensures its
+// code section only contains instructions defined by the active instruction set.
+func (in *EVMInterpreter) validateEOF(code []byte) error {
+	container, err := parseEOF(code)
+	if err != nil {
+		return err
+	}
+	if in.eofOpcodes {
+		if err := validateOpcodes(container.code, (*JumpTable)(&in.cfg.JumpTable)); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidEOF, err)
+		}
+	}
+	return nil
+}
+
+// validateInitCode checks the init code of a contract creation before running
+// it: EOF containers need to be valid, legacy code is always accepted.
+func (in *EVMInterpreter) validateInitCode(code []byte) error {
+	if !in.eof || !hasEOFMagic(code) {
+		return nil
+	}
+	return in.validateEOF(code)
+}
+
+// validateDeployedCode checks the code returned by the init code of a contract
+// creation before storing it. Containers may only deploy valid containers, and
+// legacy init code may not deploy code starting with 0xEF, which is reserved
+// for EOF since EIP-3541.
+func (in *EVMInterpreter) validateDeployedCode(initCode, code []byte) error {
+	if in.eof && hasEOFMagic(initCode) {
+		if !hasEOFMagic(code) {
+			return fmt.Errorf("%w: container deployed legacy code", ErrInvalidEOF)
+		}
+		return in.validateEOF(code)
+	}
+	if (in.eip3541 || in.eof) && len(code) > 0 && code[0] == eofMagic0 {
+		return ErrInvalidCode
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/core/rawdb"
+	"github.com/420integrated/go-highcoin/core/state"
+	"github.com/420integrated/go-highcoin/params"
+)
+
+// makeEOF assembles an EOF1 container from a code and an optional data section.
+func makeEOF(code, data []byte) []byte {
+	container := []byte{eofMagic0, eofMagic1, eofVersion, eofSectionCode, 0, 0}
+	binary.BigEndian.PutUint16(container[4:], uint16(len(code)))
+	if len(data) > 0 {
+		container = append(container, eofSectionData, 0, 0)
+		binary.BigEndian.PutUint16(container[len(container)-2:], uint16(len(data)))
+	}
+	container = append(container, eofSectionTerminator)
+	container = append(container, code...)
+	return append(container, data...)
+}
+
+// deployer returns init code which deploys the given code, either as legacy code
+// or as an EOF container carrying the code in its data section.
+func deployer(code []byte, eof bool) []byte {
+	// PUSH2 len, DUP1, PUSH1 offset, PUSH1 0, CODECOPY, PUSH1 0, RETURN
+	prefix := []byte{byte(PUSH2), 0, 0, byte(DUP1), byte(PUSH1), 12, byte(PUSH1), 0, byte(CODECOPY), byte(PUSH1), 0, byte(RETURN)}
+	binary.BigEndian.PutUint16(prefix[1:], uint16(len(code)))
+	if !eof {
+		return append(prefix, code...)
+	}
+	prefix[5] = byte(len(makeEOF(prefix, code)) - len(code))
+	return makeEOF(prefix, code)
+}
+
+func TestParseEOF(t *testing.T) {
+	tests := []struct {
+		code string
+		fail bool
+	}{
+		{code: "ef0001010001" + "00" + "00"},
+		{code: "ef0001010001020002" + "00" + "00" + "aabb"},
+		{code: "ef00", fail: true},                                       // missing version
+		{code: "ef0002010001" + "00" + "00", fail: true},                 // unsupported version
+		{code: "ef0001010001", fail: true},                               // missing terminator
+		{code: "ef000101", fail: true},                                   // truncated section size
+		{code: "ef0001" + "00", fail: true},                              // missing code section
+		{code: "ef0001010000" + "00", fail: true},                        // empty code section
+		{code: "ef0001020001" + "00" + "00", fail: true},                 // data section first
+		{code: "ef0001010001010001" + "00" + "0000", fail: true},         // multiple code sections
+		{code: "ef0001010001020000" + "00" + "00", fail: true},           // empty data section
+		{code: "ef0001010002" + "00" + "00", fail: true},                 // truncated code section
+		{code: "ef0001010001" + "00" + "0000", fail: true},               // trailing bytes
+		{code: "ef0001010001020002" + "00" + "00aa", fail: true},         // truncated data section
+		{code: "ef0001010001020001030001" + "00" + "000000", fail: true}, // unknown section kind
+	}
+	for i, tt := range tests {
+		container, err := parseEOF(common.FromHex(tt.code))
+		if tt.fail {
+			if !errors.Is(err, ErrInvalidEOF) {
+				t.Errorf("test %d: error mismatch: have %v, want %v", i, err, ErrInvalidEOF)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: failed to parse container: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(container.code, []byte{0x00}) {
+			t.Errorf("test %d: code section mismatch: have %x, want 00", i, container.code)
+		}
+	}
+}
+
+func TestValidateOpcodes(t *testing.T) {
+	tests := []struct {
+		code string
+		fail bool
+	}{
+		{code: "00"},
+		{code: "6001600201"},
+		{code: "7f" + "0000000000000000000000000000000000000000000000000000000000000000"},
+		{code: "0c", fail: true},     // undefined opcode
+		{code: "60010c", fail: true}, // undefined opcode after push
+		{code: "6000fe", fail: true}, // designated invalid opcode
+		{code: "61aa", fail: true},   // truncated push
+		{code: "7f" + "00000000000000000000000000000000000000000000000000000000000000", fail: true}, // truncated push32
+	}
+	for i, tt := range tests {
+		err := validateOpcodes(common.FromHex(tt.code), &istanbulInstructionSet)
+		if tt.fail != (err != nil) {
+			t.Errorf("test %d: validation mismatch: have %v, want failure %v", i, err, tt.fail)
+		}
+	}
+	// PUSH data is skipped, even if it's not a valid opcode
+	if err := validateOpcodes(common.FromHex("600c"), &istanbulInstructionSet); err != nil {
+		t.Errorf("push data validated: %v", err)
+	}
+}
+
+func TestEOFCreate(t *testing.T) {
+	var (
+		stop    = []byte{byte(STOP)}
+		invalid = []byte{byte(PUSH1), 0, 0x0c}
+		valid   = deployer(makeEOF(stop, nil), true)
+	)
+	tests := []struct {
+		eips []int
+		init []byte
+		code []byte // Expected deployed code
+		err  error
+	}{
+		// Legacy code without EOF is unaffected
+		{eips: nil, init: deployer([]byte{0xef}, false), code: []byte{0xef}},
+		{eips: nil, init: makeEOF(deployer(stop, false), nil), err: &ErrInvalidOpCode{opcode: 0xef}},
+
+		// EIP-3541 rejects new code starting with 0xEF ahead of EOF
+		{eips: []int{3541}, init: deployer(stop, false), code: stop},
+		{eips: []int{3541}, init: deployer([]byte{0xef}, false), err: ErrInvalidCode},
+		{eips: []int{3541}, init: makeEOF(deployer(stop, false), nil), err: &ErrInvalidOpCode{opcode: 0xef}},
+
+		// Legacy code may not deploy code starting with 0xEF anymore
+		{eips: []int{3540}, init: deployer(stop, false), code: stop},
+		{eips: []int{3540}, init: deployer([]byte{0xef}, false), err: ErrInvalidCode},
+		{eips: []int{3540}, init: deployer(makeEOF(stop, nil), false), err: ErrInvalidCode},
+
+		// Containers need to be valid and may only deploy valid containers
+		{eips: []int{3540}, init: deployer(makeEOF(stop, stop), true), code: makeEOF(stop, stop)},
+		{eips: []int{3540}, init: deployer(stop, true), err: ErrInvalidEOF},
+		{eips: []int{3540}, init: deployer(makeEOF(stop, nil)[1:], true), err: ErrInvalidEOF},
+		{eips: []int{3540}, init: valid[:len(valid)-1], err: ErrInvalidEOF},
+		{eips: []int{3540}, init: append(valid, 0), err: ErrInvalidEOF},
+
+		// Undefined opcodes are only rejected with EIP-3670
+		{eips: []int{3540}, init: deployer(makeEOF(invalid, nil), true), code: makeEOF(invalid, nil)},
+		{eips: []int{3540, 3670}, init: valid, code: makeEOF(stop, nil)},
+		{eips: []int{3540, 3670}, init: deployer(makeEOF(invalid, nil), true), err: ErrInvalidEOF},
+		{eips: []int{3540, 3670}, init: makeEOF(append(deployer(makeEOF(stop, nil), false), invalid...), nil), err: ErrInvalidEOF},
+	}
+	for i, tt := range tests {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		blockCtx := BlockContext{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+			BlockNumber: big.NewInt(0),
+		}
+		env := NewEVM(blockCtx, TxContext{}, statedb, params.TestChainConfig, Config{ExtraEips: tt.eips})
+
+		_, addr, left, err := env.Create(AccountRef(common.Address{}), tt.init, 100000, new(big.Int))
+		if tt.err != nil {
+			if err == nil || (!errors.Is(err, tt.err) && err.Error() != tt.err.Error()) {
+				t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+			}
+			if left != 0 {
+				t.Errorf("test %d: smoke left after failed creation: %d", i, left)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: failed to create contract: %v", i, err)
+			continue
+		}
+		if code := statedb.GetCode(addr); !bytes.Equal(code, tt.code) {
+			t.Errorf("test %d: deployed code mismatch: have %x, want %x", i, code, tt.code)
+		}
+	}
+}
+
+func TestEOFExecution(t *testing.T) {
+	var (
+		addr = common.HexToAddress("0xaa")
+		// CODESIZE, PUSH1 1, MSTORE8, PC, PUSH1 0, MSTORE8, PUSH1 2, PUSH1 0, RETURN
+		code      = []byte{byte(CODESIZE), byte(PUSH1), 1, byte(MSTORE8), byte(PC), byte(PUSH1), 0, byte(MSTORE8), byte(PUSH1), 2, byte(PUSH1), 0, byte(RETURN)}
+		container = makeEOF(code, []byte{0xaa, 0xbb})
+	)
+
+	for _, eips := range [][]int{nil, {3540}} {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		statedb.SetCode(addr, container)
+
+		blockCtx := BlockContext{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+			BlockNumber: big.NewInt(0),
+		}
+		env := NewEVM(blockCtx, TxContext{}, statedb, params.TestChainConfig, Config{ExtraEips: eips})
+		ret, _, err := env.Call(AccountRef(common.Address{}), addr, nil, 100000, new(big.Int))
+		if eips == nil {
+			if _, ok := err.(*ErrInvalidOpCode); !ok {
+				t.Errorf("container executed without EOF: %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("failed to execute container: %v", err)
+		}
+		// The program counter is relative to the code section, the code size is the container's
+		if want := []byte{4, byte(len(container))}; !bytes.Equal(ret, want) {
+			t.Errorf("result mismatch: have %x, want %x", ret, want)
+		}
+	}
+}
@@ -39,6 +39,8 @@ var (
 	ErrSmokeUintOverflow          = errors.New("smoke uint64 overflow")
 	ErrInvalidRetsub            = errors.New("invalid retsub")
 	ErrReturnStackExceeded      = errors.New("return stack limit reached")
+	ErrInvalidCode              = errors.New("invalid code: must not begin with 0xef")
+	ErrInvalidEOF               = errors.New("invalid EOF container")
 )
 
 // ErrStackUnderflow wraps an evm error when the items on the stack less
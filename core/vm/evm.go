@@ -45,12 +45,18 @@ type (
 // ActivePrecompiles returns the addresses of the precompiles enabled with the current
 // configuration
 func (evm *EVM) ActivePrecompiles() []common.Address {
+	return ActivePrecompiles(evm.chainRules)
+}
+
+// ActivePrecompiles returns the addresses of the precompiles enabled with the given
+// chain rules.
+func ActivePrecompiles(rules params.Rules) []common.Address {
 	switch {
-	case evm.chainRules.IsYoloV3:
+	case rules.IsYoloV3:
 		return PrecompiledAddressesYoloV3
-	case evm.chainRules.IsIstanbul:
+	case rules.IsIstanbul:
 		return PrecompiledAddressesIstanbul
-	case evm.chainRules.IsByzantium:
+	case rules.IsByzantium:
 		return PrecompiledAddressesByzantium
 	default:
 		return PrecompiledAddressesHomestead
@@ -189,6 +195,7 @@ func NewEVM(blockCtx BlockContext, txCtx TxContext, statedb StateDB, chainConfig
 	// as we always want to have the built-in EVM as the failover option.
 	evm.interpreters = append(evm.interpreters, NewEVMInterpreter(evm, vmConfig))
 	evm.interpreter = evm.interpreters[0]
+	evm.attachHooks()
 
 	return evm
 }
@@ -198,6 +205,30 @@ func NewEVM(blockCtx BlockContext, txCtx TxContext, statedb StateDB, chainConfig
 func (evm *EVM) Reset(txCtx TxContext, statedb StateDB) {
 	evm.TxContext = txCtx
 	evm.StateDB = statedb
+	evm.attachHooks()
+}
+
+// StateTracer returns the configured tracer if it also traces state changes,
+// or nil otherwise.
+func (evm *EVM) StateTracer() StateTracer {
+	if !evm.vmConfig.Debug {
+		return nil
+	}
+	tracer, _ := evm.vmConfig.Tracer.(StateTracer)
+	return tracer
+}
+
+// attachHooks attaches the state tracer, if any, to the state database, or
+// detaches the hooks left by a previous EVM.
+func (evm *EVM) attachHooks() {
+	if evm.StateDB == nil {
+		return
+	}
+	if tracer := evm.StateTracer(); tracer != nil {
+		evm.StateDB.SetHooks(tracer)
+	} else {
+		evm.StateDB.SetHooks(nil)
+	}
 }
 
 // Cancel cancels any running EVM operation. This may be called concurrently and
@@ -476,10 +507,24 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, smoke uint6
 	}
 	start := time.Now()
 
-	ret, err := run(evm, contract, nil, false)
+	// Invalid EOF init code fails the creation without being executed
+	in, _ := evm.interpreter.(*EVMInterpreter)
 
+	var ret []byte
+	var err error
+	if in != nil {
+		err = in.validateInitCode(codeAndHash.code)
+	}
+	if err == nil {
+		ret, err = run(evm, contract, nil, false)
+	}
 	// check if the max code size has been exceeded
 	maxCodeSizeExceeded := evm.chainRules.IsEIP158 && len(ret) > params.MaxCodeSize
+	// the deployed code needs to be a valid EOF container if the init code was
+	// one, and must not be mistakable for one otherwise
+	if err == nil && !maxCodeSizeExceeded && in != nil {
+		err = in.validateDeployedCode(codeAndHash.code, ret)
+	}
 	// if the contract creation ran successfully and no errors were returned
 	// calculate the smoke required to store the code. If the code could not
 	// be stored due to not enough smoke set an error and let it be handled
@@ -18,6 +18,7 @@ package vm
 
 import (
 	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/core/tracing"
 	"github.com/420integrated/go-highcoin/core/types"
 	"github.com/420integrated/go-highcoin/params"
 	"github.com/holiman/uint256"
@@ -347,7 +348,7 @@ func opExtCodeSize(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx
 
 func opCodeSize(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]byte, error) {
 	l := new(uint256.Int)
-	l.SetUint64(uint64(len(callContext.contract.Code)))
+	l.SetUint64(uint64(len(callContext.contract.deployedCode())))
 	callContext.stack.push(l)
 	return nil, nil
 }
@@ -362,7 +363,7 @@ func opCodeCopy(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) (
 	if overflow {
 		uint64CodeOffset = 0xffffffffffffffff
 	}
-	codeCopy := getData(callContext.contract.Code, uint64CodeOffset, length.Uint64())
+	codeCopy := getData(callContext.contract.deployedCode(), uint64CodeOffset, length.Uint64())
 	callContext.memory.Set(memOffset.Uint64(), length.Uint64(), codeCopy)
 
 	return nil, nil
@@ -817,7 +818,7 @@ func opStop(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]by
 func opSuicide(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]byte, error) {
 	beneficiary := callContext.stack.pop()
 	balance := interpreter.evm.StateDB.GetBalance(callContext.contract.Address())
-	interpreter.evm.StateDB.AddBalance(beneficiary.Bytes20(), balance)
+	interpreter.evm.StateDB.AddBalanceWithReason(beneficiary.Bytes20(), balance, tracing.BalanceChangeSelfdestruct)
 	interpreter.evm.StateDB.Suicide(callContext.contract.Address())
 	return nil, nil
 }
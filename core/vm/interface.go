@@ -20,6 +20,7 @@ import (
 	"math/big"
 
 	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/core/tracing"
 	"github.com/420integrated/go-highcoin/core/types"
 )
 
@@ -29,6 +30,8 @@ type StateDB interface {
 
 	SubBalance(common.Address, *big.Int)
 	AddBalance(common.Address, *big.Int)
+	SubBalanceWithReason(common.Address, *big.Int, tracing.BalanceChangeReason)
+	AddBalanceWithReason(common.Address, *big.Int, tracing.BalanceChangeReason)
 	GetBalance(common.Address) *big.Int
 
 	GetNonce(common.Address) uint64
@@ -74,6 +77,9 @@ type StateDB interface {
 	AddPreimage(common.Hash, []byte)
 
 	ForEachStorage(common.Address, func(common.Hash, common.Hash) bool) error
+
+	// SetHooks sets the hooks notified of the state changes, nil disables them.
+	SetHooks(tracing.StateHooks)
 }
 
 // CallContext provides a basic interface for the EVM calling conventions. The EVM
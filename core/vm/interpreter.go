@@ -89,6 +89,10 @@ type EVMInterpreter struct {
 
 	readOnly   bool   // If to throw on stateful modifications
 	returnData []byte // Last CALL's return data for subsequent reuse
+
+	eip3541    bool // Whether new code starting with 0xEF is rejected (EIP-3541)
+	eof        bool // Whether EOF containers are recognised (EIP-3540)
+	eofOpcodes bool // Whether opcodes of EOF containers are validated (EIP-3670)
 }
 
 // NewEVMInterpreter returns a new instance of the Interpreter.
@@ -127,8 +131,11 @@ func NewEVMInterpreter(evm *EVM, cfg Config) *EVMInterpreter {
 	}
 
 	return &EVMInterpreter{
-		evm: evm,
-		cfg: cfg,
+		evm:        evm,
+		cfg:        cfg,
+		eip3541:    evm.chainRules.IsEIP3541 || hasEIP(cfg.ExtraEips, 3541),
+		eof:        evm.chainRules.IsEOF || hasEIP(cfg.ExtraEips, 3540),
+		eofOpcodes: evm.chainRules.IsEOF || hasEIP(cfg.ExtraEips, 3670),
 	}
 }
 
@@ -159,6 +166,14 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 	if len(contract.Code) == 0 {
 		return nil, nil
 	}
+	// Only execute the code section of EOF containers. Anything failing to parse
+	// is executed as legacy code, aborting on the invalid 0xEF opcode. No legacy
+	// contract starting with 0xEF can be deployed since EIP-3541.
+	if in.eof && contract.container == nil && hasEOFMagic(contract.Code) {
+		if container, err := parseEOF(contract.Code); err == nil {
+			contract.container, contract.Code = contract.Code, container.code
+		}
+	}
 
 	var (
 		op          OpCode             // current opcode
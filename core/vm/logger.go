@@ -28,6 +28,7 @@ import (
 	"github.com/420integrated/go-highcoin/common"
 	"github.com/420integrated/go-highcoin/common/hexutil"
 	"github.com/420integrated/go-highcoin/common/math"
+	"github.com/420integrated/go-highcoin/core/tracing"
 	"github.com/420integrated/go-highcoin/core/types"
 	"github.com/420integrated/go-highcoin/params"
 )
@@ -115,6 +116,24 @@ type Tracer interface {
 	CaptureEnd(output []byte, smokeUsed uint64, t time.Duration, err error) error
 }
 
+// StateTracer is an optional extension of Tracer, receiving the state changes
+// applied alongside the executed opcodes. If the tracer of the EVM implements
+// it, the hooks are attached to the EVM's state database, reporting all changes
+// made by the transactions and the block finalization, e.g. the rewards.
+type StateTracer interface {
+	Tracer
+	tracing.StateHooks
+
+	// CaptureTxStart is called before a transaction is applied, prior to the
+	// purchase of its smoke.
+	CaptureTxStart(env *EVM, from common.Address, to *common.Address, smoke uint64, value *big.Int)
+
+	// CaptureTxEnd is called after a transaction was applied, including its
+	// refund and fee payment. The error is set if the transaction was rejected
+	// or its execution failed.
+	CaptureTxEnd(smokeUsed uint64, err error)
+}
+
 // StructLogger is an EVM state logger and implements Tracer.
 //
 // StructLogger can capture state based on the given Log configuration and also keeps
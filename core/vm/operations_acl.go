@@ -177,10 +177,15 @@ func makeCallVariantSmokeCallEIP2929(oldCalculator smokeFunc) smokeFunc {
 	return func(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
 		addr := common.Address(stack.Back(1).Bytes20())
 		// Check slot presence in the access list
-		if !evm.StateDB.AddressInAccessList(addr) {
+		warmAccess := evm.StateDB.AddressInAccessList(addr)
+		// The WarmStorageReadCostEIP2929 (100) is already deducted in the form of a constant cost, so
+		// the cost to charge for cold access, if any, is Cold - Warm
+		coldCost := ColdAccountAccessCostEIP2929 - WarmStorageReadCostEIP2929
+		if !warmAccess {
 			evm.StateDB.AddAddressToAccessList(addr)
-			// The WarmStorageReadCostEIP2929 (100) is already deducted in the form of a constant cost
-			if !contract.UseSmoke(ColdAccountAccessCostEIP2929 - WarmStorageReadCostEIP2929) {
+			// Charge the remaining difference here already, to correctly calculate available
+			// smoke for call
+			if !contract.UseSmoke(coldCost) {
 				return 0, ErrOutOfSmoke
 			}
 		}
@@ -189,7 +194,21 @@ func makeCallVariantSmokeCallEIP2929(oldCalculator smokeFunc) smokeFunc {
 		// - transfer value
 		// - memory expansion
 		// - 63/64ths rule
-		return oldCalculator(evm, contract, stack, mem, memorySize)
+		smoke, err := oldCalculator(evm, contract, stack, mem, memorySize)
+		if warmAccess || err != nil {
+			return smoke, err
+		}
+		// In case of a cold access, we temporarily add the cold charge back, and also
+		// add it to the returned smoke. By adding it to the return, it will be charged
+		// outside of this function, as part of the dynamic smoke, and that will make it
+		// also become correctly reported to tracers.
+		contract.Smoke += coldCost
+
+		var overflow bool
+		if smoke, overflow = math.SafeAdd(smoke, coldCost); overflow {
+			return 0, ErrSmokeUintOverflow
+		}
+		return smoke, nil
 	}
 }
 
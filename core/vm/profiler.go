@@ -0,0 +1,220 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/olekukonko/tablewriter"
+)
+
+// ProfileStats are the aggregated execution statistics of an opcode or of a
+// single instruction.
+//
+// The time of a step is the wall time elapsed until the next step is captured,
+// so the setup of a call is attributed to the calling instruction, and the time
+// spent in its frame to the instructions of the callee. Similarly the smoke of a
+// call excludes the smoke forwarded to the callee.
+type ProfileStats struct {
+	Count uint64        `json:"count"` // Number of executions
+	Time  time.Duration `json:"time"`  // Cumulative wall time in nanoseconds
+	Smoke uint64        `json:"smoke"` // Cumulative smoke charged
+}
+
+// OpcodeProfile is the execution profile of an opcode across all contracts.
+type OpcodeProfile struct {
+	Op string `json:"op"`
+	ProfileStats
+}
+
+// InstructionProfile is the execution profile of the instruction at a program
+// counter of a contract code.
+type InstructionProfile struct {
+	Code common.Address `json:"code"` // Account the executed code belongs to
+	PC   uint64         `json:"pc"`
+	Op   string         `json:"op"`
+	ProfileStats
+}
+
+// ExecutionProfile is the result of a Profiler, with both the opcodes and the
+// instructions ordered by decreasing cumulative time.
+type ExecutionProfile struct {
+	Opcodes      []*OpcodeProfile      `json:"opcodes"`
+	Instructions []*InstructionProfile `json:"instructions"`
+}
+
+// instruction identifies a single instruction of a contract code.
+type instruction struct {
+	code common.Address
+	pc   uint64
+}
+
+// Profiler is an EVM tracer aggregating the execution count, the wall time and
+// the smoke of every executed opcode and program counter. It implements Tracer
+// and can be reused across multiple executions, accumulating their profiles.
+type Profiler struct {
+	ops   map[OpCode]*ProfileStats
+	instr map[instruction]*InstructionProfile
+
+	pendingOp    *ProfileStats // Opcode stats of the step being executed
+	pendingInstr *ProfileStats // Instruction stats of the step being executed
+	start        time.Time     // Start time of the step being executed
+}
+
+// NewProfiler creates a new, empty execution profiler.
+func NewProfiler() *Profiler {
+	return &Profiler{
+		ops:   make(map[OpCode]*ProfileStats),
+		instr: make(map[instruction]*InstructionProfile),
+	}
+}
+
+// stop attributes the time elapsed since the start of the pending step to it.
+func (p *Profiler) stop() {
+	if p.pendingOp == nil {
+		return
+	}
+	elapsed := time.Since(p.start)
+	p.pendingOp.Time += elapsed
+	p.pendingInstr.Time += elapsed
+	p.pendingOp, p.pendingInstr = nil, nil
+}
+
+// CaptureStart implements the Tracer interface.
+func (p *Profiler) CaptureStart(from common.Address, to common.Address, create bool, input []byte, smoke uint64, value *big.Int) error {
+	return nil
+}
+
+// CaptureState implements the Tracer interface, accounting the step about to be
+// executed and starting its timer.
+func (p *Profiler) CaptureState(env *EVM, pc uint64, op OpCode, smoke, cost uint64, memory *Memory, stack *Stack, rStack *ReturnStack, rData []byte, contract *Contract, depth int, err error) error {
+	p.stop()
+
+	// Steps failing before their execution (e.g. out of smoke) are not accounted
+	if err != nil {
+		return nil
+	}
+	// The cost of calls includes the smoke passed on to the callee, drop it
+	switch op {
+	case CALL, CALLCODE, DELEGATECALL, STATICCALL:
+		if cost >= env.callSmokeTemp {
+			cost -= env.callSmokeTemp
+		}
+	}
+	code := contract.Address()
+	if contract.CodeAddr != nil {
+		code = *contract.CodeAddr
+	}
+	stats, ok := p.ops[op]
+	if !ok {
+		stats = new(ProfileStats)
+		p.ops[op] = stats
+	}
+	key := instruction{code: code, pc: pc}
+	instr, ok := p.instr[key]
+	if !ok {
+		instr = &InstructionProfile{Code: code, PC: pc, Op: op.String()}
+		p.instr[key] = instr
+	}
+	stats.Count++
+	stats.Smoke += cost
+	instr.Count++
+	instr.Smoke += cost
+
+	p.pendingOp, p.pendingInstr = stats, &instr.ProfileStats
+	p.start = time.Now()
+	return nil
+}
+
+// CaptureFault implements the Tracer interface, stopping the timer of the step
+// which failed.
+func (p *Profiler) CaptureFault(env *EVM, pc uint64, op OpCode, smoke, cost uint64, memory *Memory, stack *Stack, rStack *ReturnStack, contract *Contract, depth int, err error) error {
+	p.stop()
+	return nil
+}
+
+// CaptureEnd implements the Tracer interface, stopping the timer of the last
+// executed step.
+func (p *Profiler) CaptureEnd(output []byte, smokeUsed uint64, t time.Duration, err error) error {
+	p.stop()
+	return nil
+}
+
+// Profile returns the execution profile aggregated so far.
+func (p *Profiler) Profile() *ExecutionProfile {
+	profile := &ExecutionProfile{
+		Opcodes:      make([]*OpcodeProfile, 0, len(p.ops)),
+		Instructions: make([]*InstructionProfile, 0, len(p.instr)),
+	}
+	for op, stats := range p.ops {
+		profile.Opcodes = append(profile.Opcodes, &OpcodeProfile{Op: op.String(), ProfileStats: *stats})
+	}
+	sort.Slice(profile.Opcodes, func(i, j int) bool {
+		a, b := profile.Opcodes[i], profile.Opcodes[j]
+		if a.Time != b.Time {
+			return a.Time > b.Time
+		}
+		return a.Op < b.Op
+	})
+	for _, instr := range p.instr {
+		entry := *instr
+		profile.Instructions = append(profile.Instructions, &entry)
+	}
+	sort.Slice(profile.Instructions, func(i, j int) bool {
+		a, b := profile.Instructions[i], profile.Instructions[j]
+		if a.Time != b.Time {
+			return a.Time > b.Time
+		}
+		if c := bytes.Compare(a.Code[:], b.Code[:]); c != 0 {
+			return c < 0
+		}
+		return a.PC < b.PC
+	})
+	return profile
+}
+
+// WriteProfileTable writes the execution profile as human readable tables, one
+// for the opcodes and one for the instructions.
+func WriteProfileTable(writer io.Writer, profile *ExecutionProfile) {
+	table := tablewriter.NewWriter(writer)
+	table.SetHeader([]string{"Opcode", "Count", "Time", "Avg time", "Smoke"})
+	for _, op := range profile.Opcodes {
+		table.Append([]string{op.Op, fmt.Sprint(op.Count), op.Time.String(), avgTime(&op.ProfileStats).String(), fmt.Sprint(op.Smoke)})
+	}
+	table.Render()
+
+	table = tablewriter.NewWriter(writer)
+	table.SetHeader([]string{"Code", "PC", "Opcode", "Count", "Time", "Avg time", "Smoke"})
+	for _, instr := range profile.Instructions {
+		table.Append([]string{instr.Code.Hex(), fmt.Sprint(instr.PC), instr.Op, fmt.Sprint(instr.Count), instr.Time.String(), avgTime(&instr.ProfileStats).String(), fmt.Sprint(instr.Smoke)})
+	}
+	table.Render()
+}
+
+// avgTime returns the average wall time of a single execution.
+func avgTime(stats *ProfileStats) time.Duration {
+	if stats.Count == 0 {
+		return 0
+	}
+	return stats.Time / time.Duration(stats.Count)
+}
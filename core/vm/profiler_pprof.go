@@ -0,0 +1,73 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"io"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/google/pprof/profile"
+)
+
+// WriteProfilePprof writes the execution profile as a gzipped pprof protocol
+// buffer, to be inspected with `go tool pprof`. Every instruction is reported
+// as a line of a function named after its opcode, within a file named after its
+// code address, called from a function named after the code address. The
+// sample values are the execution count, the time (the default) and the smoke.
+func WriteProfilePprof(writer io.Writer, execution *ExecutionProfile) error {
+	prof := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "count", Unit: "count"},
+			{Type: "time", Unit: "nanoseconds"},
+			{Type: "smoke", Unit: "smoke"},
+		},
+		DefaultSampleType: "time",
+	}
+	// Functions are shared by the instructions of identical opcodes in the same code
+	var (
+		funcs = make(map[[2]string]*profile.Function)
+		codes = make(map[common.Address]*profile.Location)
+	)
+	function := func(name, file string) *profile.Function {
+		if fn, ok := funcs[[2]string{name, file}]; ok {
+			return fn
+		}
+		fn := &profile.Function{ID: uint64(len(prof.Function) + 1), Name: name, Filename: file}
+		funcs[[2]string{name, file}] = fn
+		prof.Function = append(prof.Function, fn)
+		return fn
+	}
+	location := func(fn *profile.Function, line uint64) *profile.Location {
+		loc := &profile.Location{
+			ID:   uint64(len(prof.Location) + 1),
+			Line: []profile.Line{{Function: fn, Line: int64(line)}},
+		}
+		prof.Location = append(prof.Location, loc)
+		return loc
+	}
+	for _, instr := range execution.Instructions {
+		code := instr.Code.Hex()
+		if _, ok := codes[instr.Code]; !ok {
+			codes[instr.Code] = location(function(code, code), 0)
+		}
+		prof.Sample = append(prof.Sample, &profile.Sample{
+			Location: []*profile.Location{location(function(instr.Op, code), instr.PC), codes[instr.Code]},
+			Value:    []int64{int64(instr.Count), int64(instr.Time), int64(instr.Smoke)},
+		})
+	}
+	return prof.Write(writer)
+}
@@ -0,0 +1,110 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/core/rawdb"
+	"github.com/420integrated/go-highcoin/core/state"
+	"github.com/420integrated/go-highcoin/params"
+	pprof "github.com/google/pprof/profile"
+)
+
+func TestProfiler(t *testing.T) {
+	var (
+		caller = common.HexToAddress("0xaa")
+		callee = common.HexToAddress("0xbb")
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(caller, common.FromHex("6000600060006000600060bb61fffff100")) // CALL(0xffff, 0xbb, 0, 0, 0, 0, 0)
+	statedb.SetCode(callee, common.FromHex("600a5b600190038060025700"))           // Loop 10 times
+
+	profiler := NewProfiler()
+	blockCtx := BlockContext{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+		BlockNumber: big.NewInt(0),
+	}
+	env := NewEVM(blockCtx, TxContext{}, statedb, params.TestChainConfig, Config{Debug: true, Tracer: profiler})
+
+	smoke := uint64(100000)
+	_, left, err := env.Call(AccountRef(common.Address{}), caller, nil, smoke, new(big.Int))
+	if err != nil {
+		t.Fatalf("failed to execute code: %v", err)
+	}
+	profile := profiler.Profile()
+
+	// The smoke forwarded to the callee must not be accounted to the call
+	var total uint64
+	for _, instr := range profile.Instructions {
+		total += instr.Smoke
+	}
+	if total != smoke-left {
+		t.Errorf("total smoke mismatch: have %d, want %d", total, smoke-left)
+	}
+	counts := make(map[string]uint64)
+	for _, op := range profile.Opcodes {
+		counts[op.Op] = op.Count
+	}
+	if counts["JUMPI"] != 10 || counts["CALL"] != 1 || counts["PUSH1"] != 6+21 || counts["STOP"] != 2 {
+		t.Errorf("opcode count mismatch: %v", counts)
+	}
+	for _, instr := range profile.Instructions {
+		if instr.Code == callee && instr.PC == 2 && (instr.Op != "JUMPDEST" || instr.Count != 10) {
+			t.Errorf("loop head mismatch: have %s x%d, want JUMPDEST x10", instr.Op, instr.Count)
+		}
+		if instr.Code == caller && instr.PC == 16 && instr.Smoke >= 0xffff {
+			t.Errorf("call smoke includes forwarded smoke: %d", instr.Smoke)
+		}
+	}
+	// Ensure the pprof encoding is decodable and carries the profile
+	var buf bytes.Buffer
+	if err := WriteProfilePprof(&buf, profile); err != nil {
+		t.Fatalf("failed to write pprof profile: %v", err)
+	}
+	prof, err := pprof.Parse(&buf)
+	if err != nil {
+		t.Fatalf("failed to parse pprof profile: %v", err)
+	}
+	if len(prof.SampleType) != 3 || prof.SampleType[2].Type != "smoke" || prof.DefaultSampleType != "time" {
+		t.Errorf("sample type mismatch: %v, default %s", prof.SampleType, prof.DefaultSampleType)
+	}
+	if len(prof.Sample) != len(profile.Instructions) {
+		t.Fatalf("sample count mismatch: have %d, want %d", len(prof.Sample), len(profile.Instructions))
+	}
+	for i, sample := range prof.Sample {
+		instr := profile.Instructions[i]
+
+		if len(sample.Location) != 2 {
+			t.Fatalf("sample %d: location count mismatch: have %d, want 2", i, len(sample.Location))
+		}
+		line := sample.Location[0].Line[0]
+		if line.Function.Name != instr.Op || line.Function.Filename != instr.Code.Hex() || uint64(line.Line) != instr.PC {
+			t.Errorf("sample %d: location mismatch: have %s %s:%d, want %s %s:%d", i, line.Function.Name, line.Function.Filename, line.Line, instr.Op, instr.Code.Hex(), instr.PC)
+		}
+		if caller := sample.Location[1].Line[0].Function.Name; caller != instr.Code.Hex() {
+			t.Errorf("sample %d: caller mismatch: have %s, want %s", i, caller, instr.Code.Hex())
+		}
+		if sample.Value[0] != int64(instr.Count) || sample.Value[1] != int64(instr.Time) || sample.Value[2] != int64(instr.Smoke) {
+			t.Errorf("sample %d: value mismatch: have %v, want [%d %d %d]", i, sample.Value, instr.Count, instr.Time, instr.Smoke)
+		}
+	}
+}
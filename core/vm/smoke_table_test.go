@@ -26,6 +26,7 @@ import (
 	"github.com/420integrated/go-highcoin/core/rawdb"
 	"github.com/420integrated/go-highcoin/core/state"
 	"github.com/420integrated/go-highcoin/params"
+	"github.com/holiman/uint256"
 )
 
 func TestMemorySmokeCost(t *testing.T) {
@@ -105,3 +106,41 @@ func TestEIP2200(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the cold account surcharge of the EIP-2929 call variants is added
+// to the dynamic smoke without overflowing it.
+func TestCallVariantSmokeEIP2929Overflow(t *testing.T) {
+	tests := []struct {
+		smoke uint64
+		cold  bool
+		cost  uint64
+		err   error
+	}{
+		{1000, false, 1000, nil},
+		{1000, true, 1000 + ColdAccountAccessCostEIP2929 - WarmStorageReadCostEIP2929, nil},
+		{math.MaxUint64 - 1, false, math.MaxUint64 - 1, nil},
+		{math.MaxUint64 - 1, true, 0, ErrSmokeUintOverflow},
+	}
+	for i, tt := range tests {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		target := common.HexToAddress("0xbb")
+		if !tt.cold {
+			statedb.AddAddressToAccessList(target)
+		}
+		stack := newstack()
+		stack.push(new(uint256.Int).SetBytes(target.Bytes()))
+		stack.push(new(uint256.Int))
+
+		oldCalculator := func(*EVM, *Contract, *Stack, *Memory, uint64) (uint64, error) { return tt.smoke, nil }
+		contract := NewContract(AccountRef(common.Address{}), AccountRef(target), new(big.Int), 10000)
+		evm := NewEVM(BlockContext{}, TxContext{}, statedb, params.TestChainConfig, Config{})
+
+		cost, err := makeCallVariantSmokeCallEIP2929(oldCalculator)(evm, contract, stack, NewMemory(), 0)
+		if err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+		if cost != tt.cost {
+			t.Errorf("test %d: smoke cost mismatch: have %d, want %d", i, cost, tt.cost)
+		}
+	}
+}
@@ -860,6 +860,76 @@ func (b *Block) EstimateSmoke(ctx context.Context, args struct {
 	return Long(smoke), err
 }
 
+// AccessTuple is an account and its storage slots touched by a call.
+type AccessTuple struct {
+	address     common.Address
+	storageKeys []common.Hash
+}
+
+func (at *AccessTuple) Address(ctx context.Context) common.Address {
+	return at.address
+}
+
+func (at *AccessTuple) StorageKeys(ctx context.Context) []common.Hash {
+	return at.storageKeys
+}
+
+// AccessListResult encapsulates the result of an invocation of the
+// `createAccessList` accessor.
+type AccessListResult struct {
+	accessList []*AccessTuple // The accounts and slots touched by the call
+	smokeUsed  Long           // The amount of smoke used with the access list
+	err        *string        // The execution failure of the call, if any
+}
+
+func (r *AccessListResult) AccessList() []*AccessTuple {
+	return r.accessList
+}
+
+func (r *AccessListResult) SmokeUsed() Long {
+	return r.smokeUsed
+}
+
+func (r *AccessListResult) Error() *string {
+	return r.err
+}
+
+// createAccessList creates an access list for the given call on top of the
+// given state and wraps it into a GraphQL result.
+func createAccessList(ctx context.Context, backend highapi.Backend, args highapi.CallArgs, blockNrOrHash rpc.BlockNumberOrHash) (*AccessListResult, error) {
+	acl, smokeUsed, vmerr, err := highapi.AccessList(ctx, backend, blockNrOrHash, args)
+	if err != nil {
+		return nil, err
+	}
+	result := &AccessListResult{
+		accessList: make([]*AccessTuple, 0, len(acl)),
+		smokeUsed:  Long(smokeUsed),
+	}
+	for _, tuple := range acl {
+		result.accessList = append(result.accessList, &AccessTuple{
+			address:     tuple.Address,
+			storageKeys: tuple.StorageKeys,
+		})
+	}
+	if vmerr != nil {
+		reason := vmerr.Error()
+		result.err = &reason
+	}
+	return result, nil
+}
+
+func (b *Block) CreateAccessList(ctx context.Context, args struct {
+	Data highapi.CallArgs
+}) (*AccessListResult, error) {
+	if b.numberOrHash == nil {
+		_, err := b.resolveHeader(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return createAccessList(ctx, b.backend, args.Data, *b.numberOrHash)
+}
+
 type Pending struct {
 	backend highapi.Backend
 }
@@ -925,6 +995,13 @@ func (p *Pending) EstimateSmoke(ctx context.Context, args struct {
 	return Long(smoke), err
 }
 
+func (p *Pending) CreateAccessList(ctx context.Context, args struct {
+	Data highapi.CallArgs
+}) (*AccessListResult, error) {
+	pendingBlockNr := rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)
+	return createAccessList(ctx, p.backend, args.Data, pendingBlockNr)
+}
+
 // Resolver is the top-level object in the GraphQL hierarchy.
 type Resolver struct {
 	backend highapi.Backend
@@ -1103,6 +1180,58 @@ func (s *SyncState) KnownStates() *hexutil.Uint64 {
 	return &ret
 }
 
+func (s *SyncState) SyncedAccounts() hexutil.Uint64 {
+	return hexutil.Uint64(s.progress.SyncedAccounts)
+}
+
+func (s *SyncState) SyncedAccountBytes() hexutil.Uint64 {
+	return hexutil.Uint64(s.progress.SyncedAccountBytes)
+}
+
+func (s *SyncState) SyncedBytecodes() hexutil.Uint64 {
+	return hexutil.Uint64(s.progress.SyncedBytecodes)
+}
+
+func (s *SyncState) SyncedBytecodeBytes() hexutil.Uint64 {
+	return hexutil.Uint64(s.progress.SyncedBytecodeBytes)
+}
+
+func (s *SyncState) SyncedStorage() hexutil.Uint64 {
+	return hexutil.Uint64(s.progress.SyncedStorage)
+}
+
+func (s *SyncState) SyncedStorageBytes() hexutil.Uint64 {
+	return hexutil.Uint64(s.progress.SyncedStorageBytes)
+}
+
+func (s *SyncState) HealedTrienodes() hexutil.Uint64 {
+	return hexutil.Uint64(s.progress.HealedTrienodes)
+}
+
+func (s *SyncState) HealedTrienodeBytes() hexutil.Uint64 {
+	return hexutil.Uint64(s.progress.HealedTrienodeBytes)
+}
+
+func (s *SyncState) HealedBytecodes() hexutil.Uint64 {
+	return hexutil.Uint64(s.progress.HealedBytecodes)
+}
+
+func (s *SyncState) HealedBytecodeBytes() hexutil.Uint64 {
+	return hexutil.Uint64(s.progress.HealedBytecodeBytes)
+}
+
+func (s *SyncState) HealingPending() hexutil.Uint64 {
+	return hexutil.Uint64(s.progress.HealingPending)
+}
+
+func (s *SyncState) EstimatedCompletion() *hexutil.Uint64 {
+	if s.progress.EstimatedCompletion == 0 {
+		return nil
+	}
+	ret := hexutil.Uint64(s.progress.EstimatedCompletion)
+	return &ret
+}
+
 // Syncing returns false in case the node is currently not syncing with the network. It can be up to date or has not
 // yet received the latest block headers from its pears. In case it is synchronizing:
 // - startingBlock: block number this node started to synchronise from
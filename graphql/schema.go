@@ -209,6 +209,9 @@ const schema string = `
         # EstimateSmoke estimates the amount of smoke that will be required for
         # successful execution of a transaction at the current block's state.
         estimateSmoke(data: CallData!): Long!
+        # CreateAccessList creates an EIP-2930 access list for the given call at
+        # the current block's state.
+        createAccessList(data: CallData!): AccessListResult!
     }
 
     # CallData represents the data associated with a local contract call.
@@ -238,6 +241,25 @@ const schema string = `
         status: Long!
     }
 
+    # AccessTuple is an account and the storage slots of it a transaction touches.
+    type AccessTuple {
+        # Address is the address of the accessed account.
+        address: Address!
+        # StorageKeys is the list of accessed storage slots of the account.
+        storageKeys: [Bytes32!]!
+    }
+
+    # AccessListResult is the result of an access list creation.
+    type AccessListResult {
+        # AccessList is the list of accounts and storage slots the call touches.
+        accessList: [AccessTuple!]!
+        # SmokeUsed is the amount of smoke used by the call with the access list.
+        smokeUsed: Long!
+        # Error is the failure of the call when executed with the access list, or
+        # null if it succeeded.
+        error: String
+    }
+
     # FilterCriteria encapsulates log filter criteria for searching log entries.
     input FilterCriteria {
         # FromBlock is the block at which to start searching, inclusive. Defaults
@@ -277,6 +299,32 @@ const schema string = `
         # KnownStates is the number of states the node knows of so far, or null
         # if this is not known or not relevant.
         knownStates: Long
+        # SyncedAccounts is the number of accounts downloaded by snap sync.
+        syncedAccounts: Long!
+        # SyncedAccountBytes is the size of the account trie downloaded by snap sync.
+        syncedAccountBytes: Long!
+        # SyncedBytecodes is the number of bytecodes downloaded by snap sync.
+        syncedBytecodes: Long!
+        # SyncedBytecodeBytes is the size of the bytecodes downloaded by snap sync.
+        syncedBytecodeBytes: Long!
+        # SyncedStorage is the number of storage slots downloaded by snap sync.
+        syncedStorage: Long!
+        # SyncedStorageBytes is the size of the storage tries downloaded by snap sync.
+        syncedStorageBytes: Long!
+        # HealedTrienodes is the number of state trie nodes fixed up by snap sync.
+        healedTrienodes: Long!
+        # HealedTrienodeBytes is the size of the state trie nodes fixed up by snap sync.
+        healedTrienodeBytes: Long!
+        # HealedBytecodes is the number of bytecodes fixed up by snap sync.
+        healedBytecodes: Long!
+        # HealedBytecodeBytes is the size of the bytecodes fixed up by snap sync.
+        healedBytecodeBytes: Long!
+        # HealingPending is the number of state trie nodes and bytecodes pending
+        # healing by snap sync.
+        healingPending: Long!
+        # EstimatedCompletion is the estimated unix time at which the state
+        # download completes, or null if this is not known.
+        estimatedCompletion: Long
     }
 
     # Pending represents the current pending state.
@@ -292,6 +340,9 @@ const schema string = `
       # EstimateSmoke estimates the amount of smoke that will be required for
       # successful execution of a transaction for the pending state.
       estimateSmoke(data: CallData!): Long!
+      # CreateAccessList creates an EIP-2930 access list for the given call for
+      # the pending state.
+      createAccessList(data: CallData!): AccessListResult!
     }
 
     type Query {
@@ -19,6 +19,7 @@ package high
 import (
 	"compress/gzip"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -35,6 +36,7 @@ import (
 	"github.com/420integrated/go-highcoin/core/state"
 	"github.com/420integrated/go-highcoin/core/types"
 	"github.com/420integrated/go-highcoin/internal/highapi"
+	"github.com/420integrated/go-highcoin/miner"
 	"github.com/420integrated/go-highcoin/rlp"
 	"github.com/420integrated/go-highcoin/rpc"
 	"github.com/420integrated/go-highcoin/trie"
@@ -154,6 +156,44 @@ func (api *PrivateMinerAPI) GetHashrate() uint64 {
 	return api.e.miner.HashRate()
 }
 
+// SendBundleArgs represents the arguments of a bundle submission.
+type SendBundleArgs struct {
+	Txs               []hexutil.Bytes `json:"txs"`
+	BlockNumber       hexutil.Uint64  `json:"blockNumber"`
+	MinTimestamp      *hexutil.Uint64 `json:"minTimestamp"`
+	MaxTimestamp      *hexutil.Uint64 `json:"maxTimestamp"`
+	RevertingTxHashes []common.Hash   `json:"revertingTxHashes"`
+}
+
+// SendBundle schedules a bundle of signed transactions for inclusion in the
+// target block. The transactions are included together, in order, at the top of
+// the block or not at all. A bundle containing a reverting transaction is only
+// included if the hash of that transaction is listed in revertingTxHashes. The
+// hash identifying the bundle is returned.
+func (api *PrivateMinerAPI) SendBundle(args SendBundleArgs) (common.Hash, error) {
+	bundle := &miner.Bundle{
+		BlockNumber:       uint64(args.BlockNumber),
+		RevertingTxHashes: args.RevertingTxHashes,
+	}
+	if args.MinTimestamp != nil {
+		bundle.MinTimestamp = uint64(*args.MinTimestamp)
+	}
+	if args.MaxTimestamp != nil {
+		bundle.MaxTimestamp = uint64(*args.MaxTimestamp)
+	}
+	for i, input := range args.Txs {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(input); err != nil {
+			return common.Hash{}, fmt.Errorf("invalid transaction %d: %v", i, err)
+		}
+		bundle.Txs = append(bundle.Txs, tx)
+	}
+	if err := api.e.Miner().SendBundle(bundle); err != nil {
+		return common.Hash{}, err
+	}
+	return bundle.Hash(), nil
+}
+
 // PrivateAdminAPI is the collection of Highcoin full node-related APIs
 // exposed over the private admin endpoint.
 type PrivateAdminAPI struct {
@@ -313,6 +353,37 @@ func NewPrivateDebugAPI(high *Highcoin) *PrivateDebugAPI {
 	return &PrivateDebugAPI{high: high}
 }
 
+// VmTrace creates a subscription streaming the events of the live tracer attached
+// to the chain with --vmtrace, as the blocks are imported.
+func (api *PrivateDebugAPI) VmTrace(ctx context.Context) (*rpc.Subscription, error) {
+	if api.high.liveTracer == nil {
+		return &rpc.Subscription{}, errors.New("live tracing not enabled")
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan json.RawMessage, 4096)
+		eventsSub := api.high.liveTracer.SubscribeTrace(events)
+		defer eventsSub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-events:
+				notifier.Notify(rpcSub.ID, ev)
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
 // Preimage is a debug API function that returns the preimage for a sha3 hash, if known.
 func (api *PrivateDebugAPI) Preimage(ctx context.Context, hash common.Hash) (hexutil.Bytes, error) {
 	if preimage := rawdb.ReadPreimage(api.high.ChainDb(), hash); preimage != nil {
@@ -358,6 +429,23 @@ func (api *PrivateDebugAPI) GetBadBlocks(ctx context.Context) ([]*BadBlockArgs,
 	return results, nil
 }
 
+// ExecutionWitness returns the trie nodes, contract codes, trie key preimages and
+// ancestor headers accessed while executing the given block, allowing it to be
+// re-executed and validated without access to the state.
+func (api *PrivateDebugAPI) ExecutionWitness(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*core.Witness, error) {
+	block, err := api.high.APIBackend.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, errors.New("block not found")
+	}
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not executable")
+	}
+	return api.high.blockchain.ExecutionWitness(block)
+}
+
 // AccountRangeMaxResults is the maximum number of results to be returned per call
 const AccountRangeMaxResults = 256
 
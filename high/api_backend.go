@@ -105,11 +105,26 @@ func (b *HighAPIBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumb
 	if number == rpc.LatestBlockNumber {
 		return b.high.blockchain.CurrentBlock(), nil
 	}
-	return b.high.blockchain.GetBlockByNumber(uint64(number)), nil
+	block := b.high.blockchain.GetBlockByNumber(uint64(number))
+	if block == nil && b.high.blockchain.HistoryPruned(uint64(number)) {
+		return nil, core.ErrHistoryPruned
+	}
+	return block, nil
 }
 
 func (b *HighAPIBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
-	return b.high.blockchain.GetBlockByHash(hash), nil
+	block := b.high.blockchain.GetBlockByHash(hash)
+	if block == nil && b.historyPruned(hash) {
+		return nil, core.ErrHistoryPruned
+	}
+	return block, nil
+}
+
+// historyPruned reports whether the body and receipts of the block with the given
+// hash were pruned from the ancient store.
+func (b *HighAPIBackend) historyPruned(hash common.Hash) bool {
+	header := b.high.blockchain.GetHeaderByHash(hash)
+	return header != nil && b.high.blockchain.HistoryPruned(header.Number.Uint64())
 }
 
 func (b *HighAPIBackend) BlockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error) {
@@ -126,6 +141,9 @@ func (b *HighAPIBackend) BlockByNumberOrHash(ctx context.Context, blockNrOrHash
 		}
 		block := b.high.blockchain.GetBlock(hash, header.Number.Uint64())
 		if block == nil {
+			if b.high.blockchain.HistoryPruned(header.Number.Uint64()) {
+				return nil, core.ErrHistoryPruned
+			}
 			return nil, errors.New("header found, but block body is missing")
 		}
 		return block, nil
@@ -147,7 +165,7 @@ func (b *HighAPIBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.
 	if header == nil {
 		return nil, nil, errors.New("header not found")
 	}
-	stateDb, err := b.high.BlockChain().StateAt(header.Root)
+	stateDb, err := b.stateAtHeader(header)
 	return stateDb, header, err
 }
 
@@ -166,19 +184,38 @@ func (b *HighAPIBackend) StateAndHeaderByNumberOrHash(ctx context.Context, block
 		if blockNrOrHash.RequireCanonical && b.high.blockchain.GetCanonicalHash(header.Number.Uint64()) != hash {
 			return nil, nil, errors.New("hash is not currently canonical")
 		}
-		stateDb, err := b.high.BlockChain().StateAt(header.Root)
+		stateDb, err := b.stateAtHeader(header)
 		return stateDb, header, err
 	}
 	return nil, nil, errors.New("invalid arguments; neither block nor hash specified")
 }
 
+// stateAtHeader returns the state of the given block, falling back to the state
+// history if the tries of the block were already garbage collected.
+func (b *HighAPIBackend) stateAtHeader(header *types.Header) (*state.StateDB, error) {
+	stateDb, err := b.high.BlockChain().StateAt(header.Root)
+	if err != nil {
+		if historic, herr := b.high.BlockChain().HistoricState(header); herr == nil {
+			return historic, nil
+		}
+	}
+	return stateDb, err
+}
+
 func (b *HighAPIBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
-	return b.high.blockchain.GetReceiptsByHash(hash), nil
+	receipts := b.high.blockchain.GetReceiptsByHash(hash)
+	if receipts == nil && b.historyPruned(hash) {
+		return nil, core.ErrHistoryPruned
+	}
+	return receipts, nil
 }
 
 func (b *HighAPIBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*types.Log, error) {
 	receipts := b.high.blockchain.GetReceiptsByHash(hash)
 	if receipts == nil {
+		if b.historyPruned(hash) {
+			return nil, core.ErrHistoryPruned
+		}
 		return nil, nil
 	}
 	logs := make([][]*types.Log, len(receipts))
@@ -192,12 +229,14 @@ func (b *HighAPIBackend) GetTd(ctx context.Context, hash common.Hash) *big.Int {
 	return b.high.blockchain.GetTdByHash(hash)
 }
 
-func (b *HighAPIBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header) (*vm.EVM, func() error, error) {
+func (b *HighAPIBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmConfig *vm.Config) (*vm.EVM, func() error, error) {
 	vmError := func() error { return nil }
-
+	if vmConfig == nil {
+		vmConfig = b.high.blockchain.GetVMConfig()
+	}
 	txContext := core.NewEVMTxContext(msg)
 	context := core.NewEVMBlockContext(header, b.high.BlockChain(), nil)
-	return vm.NewEVM(context, txContext, state, b.high.blockchain.Config(), *b.high.blockchain.GetVMConfig()), vmError, nil
+	return vm.NewEVM(context, txContext, state, b.high.blockchain.Config(), *vmConfig), vmError, nil
 }
 
 func (b *HighAPIBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
@@ -246,6 +285,11 @@ func (b *HighAPIBackend) GetPoolTransaction(hash common.Hash) *types.Transaction
 
 func (b *HighAPIBackend) GetTransaction(ctx context.Context, txHash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
 	tx, blockHash, blockNumber, index := rawdb.ReadTransaction(b.high.ChainDb(), txHash)
+	if tx == nil {
+		if number := rawdb.ReadTxLookupEntry(b.high.ChainDb(), txHash); number != nil && b.high.blockchain.HistoryPruned(*number) {
+			return nil, common.Hash{}, 0, 0, core.ErrHistoryPruned
+		}
+	}
 	return tx, blockHash, blockNumber, index, nil
 }
 
@@ -261,6 +305,14 @@ func (b *HighAPIBackend) TxPoolContent() (map[common.Address]types.Transactions,
 	return b.high.TxPool().Content()
 }
 
+func (b *HighAPIBackend) TxPoolContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	return b.high.TxPool().ContentFrom(addr)
+}
+
+func (b *HighAPIBackend) TxPoolAccounts() []common.Address {
+	return b.high.TxPool().Accounts()
+}
+
 func (b *HighAPIBackend) TxPool() *core.TxPool {
 	return b.high.TxPool()
 }
@@ -269,6 +321,10 @@ func (b *HighAPIBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.
 	return b.high.TxPool().SubscribeNewTxsEvent(ch)
 }
 
+func (b *HighAPIBackend) SubscribeDropTxsEvent(ch chan<- core.DropTxsEvent) event.Subscription {
+	return b.high.TxPool().SubscribeDropTxsEvent(ch)
+}
+
 func (b *HighAPIBackend) Downloader() *downloader.Downloader {
 	return b.high.Downloader()
 }
@@ -277,6 +333,10 @@ func (b *HighAPIBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	return b.gpo.SuggestPrice(ctx)
 }
 
+func (b *HighAPIBackend) FeeHistory(ctx context.Context, blockCount int, lastBlock rpc.BlockNumber, percentiles []float64) (*big.Int, [][]*big.Int, []float64, []uint64, error) {
+	return b.gpo.FeeHistory(ctx, blockCount, lastBlock, percentiles)
+}
+
 func (b *HighAPIBackend) ChainDb() highdb.Database {
 	return b.high.ChainDb()
 }
@@ -18,6 +18,7 @@
 package high
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
@@ -43,6 +44,7 @@ import (
 	"github.com/420integrated/go-highcoin/high/smokeprice"
 	"github.com/420integrated/go-highcoin/high/protocols/high"
 	"github.com/420integrated/go-highcoin/high/protocols/snap"
+	"github.com/420integrated/go-highcoin/high/tracers"
 	"github.com/420integrated/go-highcoin/highdb"
 	"github.com/420integrated/go-highcoin/event"
 	"github.com/420integrated/go-highcoin/internal/highapi"
@@ -82,6 +84,8 @@ type Highcoin struct {
 	bloomIndexer      *core.ChainIndexer             // Bloom indexer operating during block imports
 	closeBloomHandler chan struct{}
 
+	liveTracer tracers.LiveTracer // Tracer attached to the chain, tracing the imported blocks
+
 	APIBackend *HighAPIBackend
 
 	miner     *miner.Miner
@@ -181,8 +185,21 @@ func New(stack *node.Node, config *highconfig.Config) (*Highcoin, error) {
 			TrieTimeLimit:       config.TrieTimeout,
 			SnapshotLimit:       config.SnapshotCache,
 			Preimages:           config.Preimages,
+			HistoryRetain:       config.HistoryRetain,
 		}
 	)
+	if config.VMTrace != "" {
+		if high.liveTracer, err = tracers.NewLiveTracer(config.VMTrace, json.RawMessage(config.VMTraceConfig)); err != nil {
+			return nil, err
+		}
+		vmConfig.Debug, vmConfig.Tracer = true, high.liveTracer
+		log.Info("Enabled live tracing of imported blocks", "tracer", config.VMTrace)
+	}
+	if config.StateHistory {
+		if cacheConfig.StateHistory = stack.ResolvePath("statehistory"); cacheConfig.StateHistory == "" {
+			log.Warn("State history requires a persistent data directory, disabling")
+		}
+	}
 	high.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, chainConfig, high.engine, vmConfig, high.shouldPreserve, &config.TxLookupLimit)
 	if err != nil {
 		return nil, err
@@ -545,6 +562,11 @@ func (s *Highcoin) Stop() error {
 	s.txPool.Stop()
 	s.miner.Stop()
 	s.blockchain.Stop()
+	if s.liveTracer != nil {
+		if err := s.liveTracer.Close(); err != nil {
+			log.Error("Failed to close live tracer", "err", err)
+		}
+	}
 	s.engine.Close()
 	rawdb.PopUncleanShutdownMarker(s.chainDb)
 	s.chainDb.Close()
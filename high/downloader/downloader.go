@@ -276,13 +276,32 @@ func (d *Downloader) Progress() highcoin.SyncProgress {
 	default:
 		log.Error("Unknown downloader chain/mode combo", "light", d.lightchain != nil, "full", d.blockchain != nil, "mode", mode)
 	}
-	return highcoin.SyncProgress{
+	progress := highcoin.SyncProgress{
 		StartingBlock: d.syncStatsChainOrigin,
 		CurrentBlock:  current,
 		HighestBlock:  d.syncStatsChainHeight,
 		PulledStates:  d.syncStatsState.processed,
 		KnownStates:   d.syncStatsState.processed + d.syncStatsState.pending,
 	}
+	if mode == SnapSync {
+		snap := d.SnapSyncer.Progress()
+
+		progress.SyncedAccounts = snap.AccountSynced
+		progress.SyncedAccountBytes = uint64(snap.AccountBytes)
+		progress.SyncedBytecodes = snap.BytecodeSynced
+		progress.SyncedBytecodeBytes = uint64(snap.BytecodeBytes)
+		progress.SyncedStorage = snap.StorageSynced
+		progress.SyncedStorageBytes = uint64(snap.StorageBytes)
+		progress.HealedTrienodes = snap.TrienodeHealSynced
+		progress.HealedTrienodeBytes = uint64(snap.TrienodeHealBytes)
+		progress.HealedBytecodes = snap.BytecodeHealSynced
+		progress.HealedBytecodeBytes = uint64(snap.BytecodeHealBytes)
+		progress.HealingPending = snap.HealPending
+		if !snap.ETA.IsZero() {
+			progress.EstimatedCompletion = uint64(snap.ETA.Unix())
+		}
+	}
+	return progress
 }
 
 // Synchronising returns if the downloader is currently retrieving blocks.
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	mrand "math/rand"
 	"sort"
+	"sync"
 	"time"
 
 	mapset "github.com/deckarep/golang-set"
@@ -30,6 +31,7 @@ import (
 	"github.com/420integrated/go-highcoin/core/types"
 	"github.com/420integrated/go-highcoin/log"
 	"github.com/420integrated/go-highcoin/metrics"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -77,6 +79,7 @@ var (
 	txBroadcastKnownMeter       = metrics.NewRegisteredMeter("high/fetcher/transaction/broadcasts/known", nil)
 	txBroadcastUnderpricedMeter = metrics.NewRegisteredMeter("high/fetcher/transaction/broadcasts/underpriced", nil)
 	txBroadcastOtherRejectMeter = metrics.NewRegisteredMeter("high/fetcher/transaction/broadcasts/otherreject", nil)
+	txBroadcastOverBudgetMeter  = metrics.NewRegisteredMeter("high/fetcher/transaction/broadcasts/overbudget", nil)
 
 	txRequestOutMeter     = metrics.NewRegisteredMeter("high/fetcher/transaction/request/out", nil)
 	txRequestFailMeter    = metrics.NewRegisteredMeter("high/fetcher/transaction/request/fail", nil)
@@ -87,6 +90,7 @@ var (
 	txReplyKnownMeter       = metrics.NewRegisteredMeter("high/fetcher/transaction/replies/known", nil)
 	txReplyUnderpricedMeter = metrics.NewRegisteredMeter("high/fetcher/transaction/replies/underpriced", nil)
 	txReplyOtherRejectMeter = metrics.NewRegisteredMeter("high/fetcher/transaction/replies/otherreject", nil)
+	txReplyOverBudgetMeter  = metrics.NewRegisteredMeter("high/fetcher/transaction/replies/overbudget", nil)
 
 	txFetcherWaitingPeers   = metrics.NewRegisteredGauge("high/fetcher/transaction/waiting/peers", nil)
 	txFetcherWaitingHashes  = metrics.NewRegisteredGauge("high/fetcher/transaction/waiting/hashes", nil)
@@ -114,9 +118,10 @@ type txRequest struct {
 // txDelivery is the notification that a batch of transactions have been added
 // to the pool and should be untracked.
 type txDelivery struct {
-	origin string        // Identifier of the peer originating the notification
-	hashes []common.Hash // Batch of transaction hashes having been delivered
-	direct bool          // If this is a direct reply or a broadcast
+	origin  string        // Identifier of the peer originating the notification
+	hashes  []common.Hash // Batch of transaction hashes having been delivered
+	dropped []common.Hash // Batch of transaction hashes dropped above the peer's budget
+	direct  bool          // If this is a direct reply or a broadcast
 }
 
 // txDrop is the notiication that a peer has disconnected.
@@ -172,6 +177,11 @@ type TxFetcher struct {
 	addTxs   func([]*types.Transaction) []error // Insert a batch of transactions into local txpool
 	fetchTxs func(string, []common.Hash) error  // Retrieves a set of txs from a remote peer
 
+	// Per-peer acceptance budgets
+	peerBudget func() (float64, int)     // Retrieves the transactions accepted per peer per second and at once
+	budgets    map[string]*rate.Limiter // Token buckets of the peers delivering transactions
+	budgetLock sync.Mutex               // Protects the token buckets, used outside of the loop
+
 	step  chan struct{} // Notification channel when the fetcher loop iterates
 	clock mclock.Clock  // Time wrapper to simulate in tests
 	rand  *mrand.Rand   // Randomizer to use in tests instead of map range loops (soft-random)
@@ -202,6 +212,7 @@ func NewTxFetcherForTests(
 		requests:    make(map[string]*txRequest),
 		alternates:  make(map[common.Hash]map[string]struct{}),
 		underpriced: mapset.NewSet(),
+		budgets:     make(map[string]*rate.Limiter),
 		hasTx:       hasTx,
 		addTxs:      addTxs,
 		fetchTxs:    fetchTxs,
@@ -210,6 +221,42 @@ func NewTxFetcherForTests(
 	}
 }
 
+// SetPeerBudget sets the callback retrieving the number of transactions accepted
+// from a single peer per second and at once. Transactions delivered above the
+// budget of a peer are dropped without being added to the pool. A zero rate
+// disables the budget.
+//
+// This method should be called before starting the fetcher.
+func (f *TxFetcher) SetPeerBudget(budget func() (float64, int)) {
+	f.peerBudget = budget
+}
+
+// admit consumes the acceptance budget of a peer for delivered transactions,
+// returning the number of transactions that fit into it.
+func (f *TxFetcher) admit(peer string, count int) int {
+	if f.peerBudget == nil {
+		return count
+	}
+	limit, burst := f.peerBudget()
+	if limit <= 0 {
+		return count
+	}
+	f.budgetLock.Lock()
+	defer f.budgetLock.Unlock()
+
+	// Create a new bucket for unknown peers or if the policy changed
+	bucket := f.budgets[peer]
+	if bucket == nil || bucket.Limit() != rate.Limit(limit) || bucket.Burst() != burst {
+		bucket = rate.NewLimiter(rate.Limit(limit), burst)
+		f.budgets[peer] = bucket
+	}
+	admitted := 0
+	for admitted < count && bucket.Allow() {
+		admitted++
+	}
+	return admitted
+}
+
 // Notify announces the fetcher of the potential availability of a new batch of
 // transactions in the network.
 func (f *TxFetcher) Notify(peer string, hashes []common.Hash) error {
@@ -271,11 +318,21 @@ func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool)
 	// re-requesting them and dropping the peer in case of malicious transfers.
 	var (
 		added       = make([]common.Hash, 0, len(txs))
+		dropped     []common.Hash
 		duplicate   int64
 		underpriced int64
 		otherreject int64
+		overbudget  int64
 	)
-	errs := f.addTxs(txs)
+	// Drop the transactions above the acceptance budget of the peer. They are
+	// not delivered, only the peer's announcements are forgotten so they're not
+	// re-requested from it, but other announcers may still deliver them.
+	admitted := f.admit(peer, len(txs))
+	for _, tx := range txs[admitted:] {
+		dropped = append(dropped, tx.Hash())
+		overbudget++
+	}
+	errs := f.addTxs(txs[:admitted])
 	for i, err := range errs {
 		if err != nil {
 			// Track the transaction hash if the price is too low for us.
@@ -307,13 +364,15 @@ func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool)
 		txReplyKnownMeter.Mark(duplicate)
 		txReplyUnderpricedMeter.Mark(underpriced)
 		txReplyOtherRejectMeter.Mark(otherreject)
+		txReplyOverBudgetMeter.Mark(overbudget)
 	} else {
 		txBroadcastKnownMeter.Mark(duplicate)
 		txBroadcastUnderpricedMeter.Mark(underpriced)
 		txBroadcastOtherRejectMeter.Mark(otherreject)
+		txBroadcastOverBudgetMeter.Mark(overbudget)
 	}
 	select {
-	case f.cleanup <- &txDelivery{origin: peer, hashes: added, direct: direct}:
+	case f.cleanup <- &txDelivery{origin: peer, hashes: added, dropped: dropped, direct: direct}:
 		return nil
 	case <-f.quit:
 		return errTerminated
@@ -323,6 +382,10 @@ func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool)
 // Drop should be called when a peer disconnects. It cleans up all the internal
 // data structures of the given node.
 func (f *TxFetcher) Drop(peer string) error {
+	f.budgetLock.Lock()
+	delete(f.budgets, peer)
+	f.budgetLock.Unlock()
+
 	select {
 	case f.drop <- &txDrop{peer: peer}:
 		return nil
@@ -556,6 +619,33 @@ func (f *TxFetcher) loop() {
 					delete(f.fetching, hash)
 				}
 			}
+			// Forget the announcements of the transactions dropped above the
+			// budget of the peer, but keep those of any other announcers
+			for _, hash := range delivery.dropped {
+				if _, ok := f.waitlist[hash]; ok {
+					delete(f.waitlist[hash], delivery.origin)
+					if len(f.waitlist[hash]) == 0 {
+						delete(f.waitlist, hash)
+						delete(f.waittime, hash)
+					}
+					delete(f.waitslots[delivery.origin], hash)
+					if len(f.waitslots[delivery.origin]) == 0 {
+						delete(f.waitslots, delivery.origin)
+					}
+					continue
+				}
+				delete(f.announces[delivery.origin], hash)
+				if len(f.announces[delivery.origin]) == 0 {
+					delete(f.announces, delivery.origin)
+				}
+				if announcers := f.announced[hash]; announcers != nil {
+					delete(announcers, delivery.origin)
+					if len(announcers) == 0 {
+						delete(f.announced, hash)
+					}
+				}
+				delete(f.alternates[hash], delivery.origin)
+			}
 			// In case of a direct delivery, also reschedule anything missing
 			// from the original query
 			if delivery.direct {
@@ -1526,3 +1526,109 @@ func containsHash(slice []common.Hash, hash common.Hash) bool {
 	}
 	return false
 }
+
+// Tests that transactions dropped above the acceptance budget of a peer are not
+// considered delivered, so other peers announcing them can still deliver them.
+func TestTransactionFetcherPeerBudgetAlternates(t *testing.T) {
+	testTransactionFetcherParallel(t, txFetcherTest{
+		init: func() *TxFetcher {
+			fetcher := NewTxFetcher(
+				func(common.Hash) bool { return false },
+				func(txs []*types.Transaction) []error {
+					return make([]error, len(txs))
+				},
+				func(string, []common.Hash) error { return nil },
+			)
+			fetcher.SetPeerBudget(func() (float64, int) { return 1e-9, 1 })
+			return fetcher
+		},
+		steps: []interface{}{
+			// Announce two transactions from A, one of them also from B
+			doTxNotify{peer: "A", hashes: []common.Hash{testTxsHashes[0], testTxsHashes[1]}},
+			doTxNotify{peer: "B", hashes: []common.Hash{testTxsHashes[1]}},
+			isWaiting(map[string][]common.Hash{
+				"A": {testTxsHashes[0], testTxsHashes[1]},
+				"B": {testTxsHashes[1]},
+			}),
+			// Broadcast both from A, only the first fits into its budget. The
+			// dropped one must still be waited for from B.
+			doTxEnqueue{peer: "A", txs: []*types.Transaction{testTxs[0], testTxs[1]}, direct: false},
+			isWaiting(map[string][]common.Hash{
+				"B": {testTxsHashes[1]},
+			}),
+			doWait{time: txArriveTimeout, step: true},
+			isWaiting(nil),
+			isScheduled{
+				tracking: map[string][]common.Hash{
+					"B": {testTxsHashes[1]},
+				},
+				fetching: map[string][]common.Hash{
+					"B": {testTxsHashes[1]},
+				},
+			},
+			// Announce two more from A and B and have A reply over budget. The
+			// dropped transaction must be rescheduled from B, not from A.
+			doTxNotify{peer: "A", hashes: []common.Hash{testTxsHashes[2], testTxsHashes[3]}},
+			doTxNotify{peer: "B", hashes: []common.Hash{testTxsHashes[3]}},
+			doWait{time: txArriveTimeout, step: true},
+			isScheduled{
+				tracking: map[string][]common.Hash{
+					"A": {testTxsHashes[2], testTxsHashes[3]},
+					"B": {testTxsHashes[1], testTxsHashes[3]},
+				},
+				fetching: map[string][]common.Hash{
+					"A": {testTxsHashes[2], testTxsHashes[3]},
+					"B": {testTxsHashes[1]},
+				},
+			},
+			doTxEnqueue{peer: "A", txs: []*types.Transaction{testTxs[2], testTxs[3]}, direct: true},
+			isScheduled{
+				tracking: map[string][]common.Hash{
+					"B": {testTxsHashes[1], testTxsHashes[3]},
+				},
+				fetching: map[string][]common.Hash{
+					"B": {testTxsHashes[1]},
+				},
+			},
+		},
+	})
+}
+
+// Tests that transactions delivered above the acceptance budget of a peer are
+// dropped, without affecting the budget of other peers.
+func TestTransactionFetcherPeerBudget(t *testing.T) {
+	var added []common.Hash
+	fetcher := NewTxFetcher(
+		func(common.Hash) bool { return false },
+		func(txs []*types.Transaction) []error {
+			for _, tx := range txs {
+				added = append(added, tx.Hash())
+			}
+			return make([]error, len(txs))
+		},
+		func(string, []common.Hash) error { return nil },
+	)
+	fetcher.SetPeerBudget(func() (float64, int) { return 1e-9, 2 })
+	fetcher.Start()
+	defer fetcher.Stop()
+
+	if err := fetcher.Enqueue("A", testTxs, false); err != nil {
+		t.Fatalf("failed to enqueue transactions: %v", err)
+	}
+	if len(added) != 2 || added[0] != testTxsHashes[0] || added[1] != testTxsHashes[1] {
+		t.Fatalf("admitted transactions mismatch: have %x, want %x", added, testTxsHashes[:2])
+	}
+	if err := fetcher.Enqueue("A", testTxs[2:], true); err != nil {
+		t.Fatalf("failed to enqueue transactions: %v", err)
+	}
+	if len(added) != 2 {
+		t.Fatalf("transactions admitted over budget: have %d, want 2", len(added))
+	}
+	// Other peers have their own budget
+	if err := fetcher.Enqueue("B", testTxs[2:], false); err != nil {
+		t.Fatalf("failed to enqueue transactions: %v", err)
+	}
+	if len(added) != 4 {
+		t.Fatalf("admitted transaction count mismatch: have %d, want 4", len(added))
+	}
+}
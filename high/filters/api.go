@@ -28,6 +28,7 @@ import (
 	"github.com/420integrated/go-highcoin"
 	"github.com/420integrated/go-highcoin/common"
 	"github.com/420integrated/go-highcoin/common/hexutil"
+	"github.com/420integrated/go-highcoin/core"
 	"github.com/420integrated/go-highcoin/core/types"
 	"github.com/420integrated/go-highcoin/highdb"
 	"github.com/420integrated/go-highcoin/event"
@@ -175,6 +176,45 @@ func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Su
 	return rpcSub, nil
 }
 
+// droppedTransactions is the notification sent for transactions dropped from the
+// transaction pool.
+type droppedTransactions struct {
+	Reason core.TxDropReason `json:"reason"`
+	Hashes []common.Hash     `json:"hashes"`
+}
+
+// DroppedTransactions creates a subscription that is triggered each time a batch
+// of transactions is dropped from the transaction pool without being included in
+// a block, notifying the hashes along with the reason for dropping them.
+func (api *PublicFilterAPI) DroppedTransactions(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		drops := make(chan core.DropTxsEvent, 128)
+		droppedTxSub := api.events.SubscribeDroppedTxs(drops)
+
+		for {
+			select {
+			case ev := <-drops:
+				notifier.Notify(rpcSub.ID, &droppedTransactions{Reason: ev.Reason, Hashes: ev.Hashes})
+			case <-rpcSub.Err():
+				droppedTxSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				droppedTxSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // NewBlockFilter creates a filter that fetches blocks that are imported into the chain.
 // It is part of the filter package since polling goes with high_getFilterChanges.
 //
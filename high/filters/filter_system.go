@@ -52,6 +52,9 @@ const (
 	PendingTransactionsSubscription
 	// BlocksSubscription queries hashes for blocks that are imported
 	BlocksSubscription
+	// DroppedTransactionsSubscription queries tx hashes and drop reasons for
+	// transactions leaving the transaction pool without being included
+	DroppedTransactionsSubscription
 	// LastSubscription keeps track of the last index
 	LastIndexSubscription
 )
@@ -66,6 +69,8 @@ const (
 	logsChanSize = 10
 	// chainEvChanSize is the size of channel listening to ChainEvent.
 	chainEvChanSize = 10
+	// dropsChanSize is the size of channel listening to DropTxsEvent.
+	dropsChanSize = 128
 )
 
 type subscription struct {
@@ -76,6 +81,7 @@ type subscription struct {
 	logs      chan []*types.Log
 	hashes    chan []common.Hash
 	headers   chan *types.Header
+	drops     chan core.DropTxsEvent
 	installed chan struct{} // closed when the filter is installed
 	err       chan error    // closed when the filter is uninstalled
 }
@@ -93,6 +99,7 @@ type EventSystem struct {
 	rmLogsSub      event.Subscription // Subscription for removed log event
 	pendingLogsSub event.Subscription // Subscription for pending log event
 	chainSub       event.Subscription // Subscription for new chain event
+	dropsSub       event.Subscription // Subscription for dropped transaction event
 
 	// Channels
 	install       chan *subscription         // install filter for event notification
@@ -102,6 +109,7 @@ type EventSystem struct {
 	pendingLogsCh chan []*types.Log          // Channel to receive new log event
 	rmLogsCh      chan core.RemovedLogsEvent // Channel to receive removed log event
 	chainCh       chan core.ChainEvent       // Channel to receive new chain event
+	dropsCh       chan core.DropTxsEvent     // Channel to receive dropped transactions event
 }
 
 // NewEventSystem creates a new manager that listens for event on the given mux,
@@ -121,6 +129,7 @@ func NewEventSystem(backend Backend, lightMode bool) *EventSystem {
 		rmLogsCh:      make(chan core.RemovedLogsEvent, rmLogsChanSize),
 		pendingLogsCh: make(chan []*types.Log, logsChanSize),
 		chainCh:       make(chan core.ChainEvent, chainEvChanSize),
+		dropsCh:       make(chan core.DropTxsEvent, dropsChanSize),
 	}
 
 	// Subscribe events
@@ -129,9 +138,10 @@ func NewEventSystem(backend Backend, lightMode bool) *EventSystem {
 	m.rmLogsSub = m.backend.SubscribeRemovedLogsEvent(m.rmLogsCh)
 	m.chainSub = m.backend.SubscribeChainEvent(m.chainCh)
 	m.pendingLogsSub = m.backend.SubscribePendingLogsEvent(m.pendingLogsCh)
+	m.dropsSub = m.backend.SubscribeDropTxsEvent(m.dropsCh)
 
 	// Make sure none of the subscriptions are empty
-	if m.txsSub == nil || m.logsSub == nil || m.rmLogsSub == nil || m.chainSub == nil || m.pendingLogsSub == nil {
+	if m.txsSub == nil || m.logsSub == nil || m.rmLogsSub == nil || m.chainSub == nil || m.pendingLogsSub == nil || m.dropsSub == nil {
 		log.Crit("Subscribe for event system failed")
 	}
 
@@ -167,6 +177,7 @@ func (sub *Subscription) Unsubscribe() {
 			case <-sub.f.logs:
 			case <-sub.f.hashes:
 			case <-sub.f.headers:
+			case <-sub.f.drops:
 			}
 		}
 
@@ -234,6 +245,7 @@ func (es *EventSystem) subscribeMinedPendingLogs(crit highcoin.FilterQuery, logs
 		logs:      logs,
 		hashes:    make(chan []common.Hash),
 		headers:   make(chan *types.Header),
+		drops:     make(chan core.DropTxsEvent),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -251,6 +263,7 @@ func (es *EventSystem) subscribeLogs(crit highcoin.FilterQuery, logs chan []*typ
 		logs:      logs,
 		hashes:    make(chan []common.Hash),
 		headers:   make(chan *types.Header),
+		drops:     make(chan core.DropTxsEvent),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -268,6 +281,7 @@ func (es *EventSystem) subscribePendingLogs(crit highcoin.FilterQuery, logs chan
 		logs:      logs,
 		hashes:    make(chan []common.Hash),
 		headers:   make(chan *types.Header),
+		drops:     make(chan core.DropTxsEvent),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -284,6 +298,7 @@ func (es *EventSystem) SubscribeNewHeads(headers chan *types.Header) *Subscripti
 		logs:      make(chan []*types.Log),
 		hashes:    make(chan []common.Hash),
 		headers:   headers,
+		drops:     make(chan core.DropTxsEvent),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -300,6 +315,24 @@ func (es *EventSystem) SubscribePendingTxs(hashes chan []common.Hash) *Subscript
 		logs:      make(chan []*types.Log),
 		hashes:    hashes,
 		headers:   make(chan *types.Header),
+		drops:     make(chan core.DropTxsEvent),
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
+// SubscribeDroppedTxs creates a subscription that writes the hashes of the
+// transactions dropped from the transaction pool, along with the reason.
+func (es *EventSystem) SubscribeDroppedTxs(drops chan core.DropTxsEvent) *Subscription {
+	sub := &subscription{
+		id:        rpc.NewID(),
+		typ:       DroppedTransactionsSubscription,
+		created:   time.Now(),
+		logs:      make(chan []*types.Log),
+		hashes:    make(chan []common.Hash),
+		headers:   make(chan *types.Header),
+		drops:     drops,
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -351,6 +384,12 @@ func (es *EventSystem) handleTxsEvent(filters filterIndex, ev core.NewTxsEvent)
 	}
 }
 
+func (es *EventSystem) handleDropTxsEvent(filters filterIndex, ev core.DropTxsEvent) {
+	for _, f := range filters[DroppedTransactionsSubscription] {
+		f.drops <- ev
+	}
+}
+
 func (es *EventSystem) handleChainEvent(filters filterIndex, ev core.ChainEvent) {
 	for _, f := range filters[BlocksSubscription] {
 		f.headers <- ev.Block.Header()
@@ -448,6 +487,7 @@ func (es *EventSystem) eventLoop() {
 		es.rmLogsSub.Unsubscribe()
 		es.pendingLogsSub.Unsubscribe()
 		es.chainSub.Unsubscribe()
+		es.dropsSub.Unsubscribe()
 	}()
 
 	index := make(filterIndex)
@@ -467,6 +507,8 @@ func (es *EventSystem) eventLoop() {
 			es.handlePendingLogs(index, ev)
 		case ev := <-es.chainCh:
 			es.handleChainEvent(index, ev)
+		case ev := <-es.dropsCh:
+			es.handleDropTxsEvent(index, ev)
 
 		case f := <-es.install:
 			if f.typ == MinedAndPendingLogsSubscription {
@@ -497,6 +539,8 @@ func (es *EventSystem) eventLoop() {
 			return
 		case <-es.chainSub.Err():
 			return
+		case <-es.dropsSub.Err():
+			return
 		}
 	}
 }
@@ -52,6 +52,7 @@ type testBackend struct {
 	rmLogsFeed      event.Feed
 	pendingLogsFeed event.Feed
 	chainFeed       event.Feed
+	dropsFeed       event.Feed
 }
 
 func (b *testBackend) ChainDb() highdb.Database {
@@ -110,6 +111,10 @@ func (b *testBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Sub
 	return b.txFeed.Subscribe(ch)
 }
 
+func (b *testBackend) SubscribeDropTxsEvent(ch chan<- core.DropTxsEvent) event.Subscription {
+	return b.dropsFeed.Subscribe(ch)
+}
+
 func (b *testBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
 	return b.rmLogsFeed.Subscribe(ch)
 }
@@ -213,6 +218,51 @@ func TestBlockSubscription(t *testing.T) {
 	<-sub1.Err()
 }
 
+// TestDroppedTxSubscription tests if a dropped transaction subscription returns
+// the hashes and reasons of the posted drop events.
+func TestDroppedTxSubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		api     = NewPublicFilterAPI(backend, false, deadline)
+
+		events = []core.DropTxsEvent{
+			{Hashes: []common.Hash{{0x01}, {0x02}}, Reason: core.TxDropReplaced},
+			{Hashes: []common.Hash{{0x03}}, Reason: core.TxDropUnderpriced},
+			{Hashes: []common.Hash{{0x04}}, Reason: core.TxDropExpired},
+		}
+	)
+	drops := make(chan core.DropTxsEvent)
+	sub := api.events.SubscribeDroppedTxs(drops)
+	defer sub.Unsubscribe()
+
+	go func() {
+		for _, ev := range events {
+			backend.dropsFeed.Send(ev)
+		}
+	}()
+	for i, want := range events {
+		select {
+		case have := <-drops:
+			if have.Reason != want.Reason {
+				t.Errorf("event %d: reason mismatch: have %s, want %s", i, have.Reason, want.Reason)
+			}
+			if len(have.Hashes) != len(want.Hashes) {
+				t.Fatalf("event %d: hash count mismatch: have %d, want %d", i, len(have.Hashes), len(want.Hashes))
+			}
+			for j := range want.Hashes {
+				if have.Hashes[j] != want.Hashes[j] {
+					t.Errorf("event %d: hash %d mismatch: have %x, want %x", i, j, have.Hashes[j], want.Hashes[j])
+				}
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d: timeout", i)
+		}
+	}
+}
+
 // TestPendingTxFilter tests if pending tx filters retrieve all pending transactions that are posted to the event mux.
 func TestPendingTxFilter(t *testing.T) {
 	t.Parallel()
@@ -71,6 +71,10 @@ type txPool interface {
 	// SubscribeNewTxsEvent should return an event subscription of
 	// NewTxsEvent and send events to the given channel.
 	SubscribeNewTxsEvent(chan<- core.NewTxsEvent) event.Subscription
+
+	// PeerBudget should return the number of transactions accepted from
+	// a single peer per second and at once (zero rate = unlimited).
+	PeerBudget() (float64, int)
 }
 
 // handlerConfig is the collection of initialization parameters to create a full
@@ -225,6 +229,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		return p.RequestTxs(hashes)
 	}
 	h.txFetcher = fetcher.NewTxFetcher(h.txpool.Has, h.txpool.AddRemotes, fetchTx)
+	h.txFetcher.SetPeerBudget(h.txpool.PeerBudget)
 	h.chainSync = newChainSyncer(h)
 	return h, nil
 }
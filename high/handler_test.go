@@ -112,6 +112,11 @@ func (p *testTxPool) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subs
 	return p.txFeed.Subscribe(ch)
 }
 
+// PeerBudget returns an unlimited per-peer transaction budget.
+func (p *testTxPool) PeerBudget() (float64, int) {
+	return 0, 0
+}
+
 // testHandler is a live implementation of the Highcoin protocol handler, just
 // preinitialized with some sane testing defaults and the transaction pool mocked
 // out.
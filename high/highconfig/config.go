@@ -130,6 +130,7 @@ type Config struct {
 	NoPrefetch bool // If to disable prefetching and only load state on demand
 
 	TxLookupLimit uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
+	HistoryRetain uint64 `toml:",omitempty"` // The number of blocks from head whose bodies and receipts are retained (0 = entire chain).
 
 	// Whitelist of required block number -> hash values to accept
 	Whitelist map[uint64]common.Hash `toml:"-"`
@@ -161,6 +162,7 @@ type Config struct {
 	TrieTimeout             time.Duration
 	SnapshotCache           int
 	Preimages               bool
+	StateHistory            bool `toml:",omitempty"` // If to record reverse state diffs for historical state reads
 
 	// Mining options
 	Miner miner.Config
@@ -186,6 +188,10 @@ type Config struct {
 	// Type of the EVM interpreter ("" for default)
 	EVMInterpreter string
 
+	// Name and JSON configuration of the live tracer attached to the chain ("" for none)
+	VMTrace       string `toml:",omitempty"`
+	VMTraceConfig string `toml:",omitempty"`
+
 	// RPCSmokeCap is the global smoke cap for high-call variants.
 	RPCSmokeCap uint64 `toml:",omitempty"`
 
@@ -62,8 +62,13 @@ func newTestBackend(blocks int) *testBackend {
 // newTestBackend creates a chain with a number of explicitly defined blocks and
 // wraps it into a mock backend.
 func newTestBackendWithGenerator(blocks int, generator func(int, *core.BlockGen)) *testBackend {
-	// Create a database pre-initialize with a genesis block
-	db := rawdb.NewMemoryDatabase()
+	return newTestBackendWithDatabase(rawdb.NewMemoryDatabase(), blocks, generator)
+}
+
+// newTestBackendWithDatabase creates a chain like newTestBackendWithGenerator,
+// on top of the given database.
+func newTestBackendWithDatabase(db highdb.Database, blocks int, generator func(int, *core.BlockGen)) *testBackend {
+	// Pre-initialize the database with a genesis block
 	(&core.Genesis{
 		Config: params.TestChainConfig,
 		Alloc:  core.GenesisAlloc{testAddr: {Balance: big.NewInt(1000000)}},
@@ -517,3 +522,74 @@ func testGetBlockReceipts(t *testing.T, protocol uint) {
 		t.Errorf("receipts mismatch: %v", err)
 	}
 }
+
+// prunedDatabase is a database reporting the chain history below a given block
+// as pruned from the ancient store.
+type prunedDatabase struct {
+	highdb.Database
+	tail uint64
+}
+
+func (db *prunedDatabase) AncientTail(kind string) (uint64, error) { return db.tail, nil }
+
+// Tests that the bodies and receipts of pruned blocks are not served, replying
+// with an empty response instead.
+func TestGetPrunedHistory64(t *testing.T) { testGetPrunedHistory(t, 64) }
+func TestGetPrunedHistory65(t *testing.T) { testGetPrunedHistory(t, 65) }
+
+func testGetPrunedHistory(t *testing.T, protocol uint) {
+	t.Parallel()
+
+	// Create a chain with a transaction in every block and prune the history of
+	// the first few blocks
+	generator := func(i int, block *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testAddr), common.Address{0x01}, big.NewInt(1), params.TxSmoke, nil, nil), types.HomesteadSigner{}, testKey)
+		block.AddTx(tx)
+	}
+	db := &prunedDatabase{Database: rawdb.NewMemoryDatabase()}
+	backend := newTestBackendWithDatabase(db, 8, generator)
+	defer backend.close()
+
+	db.tail = 5
+	for i := uint64(1); i < db.tail; i++ {
+		hash := rawdb.ReadCanonicalHash(db, i)
+		rawdb.DeleteBody(db, hash, i)
+		rawdb.DeleteReceipts(db, hash, i)
+	}
+	peer, _ := newTestPeer("peer", protocol, backend)
+	defer peer.close()
+
+	hash := func(number uint64) common.Hash { return backend.chain.GetHeaderByNumber(number).Hash() }
+	body := func(number uint64) *BlockBody {
+		block := backend.chain.GetBlockByNumber(number)
+		return &BlockBody{Transactions: block.Transactions(), Uncles: block.Uncles()}
+	}
+	receipts := func(number uint64) types.Receipts { return backend.chain.GetReceiptsByHash(hash(number)) }
+
+	tests := []struct {
+		hashes   []common.Hash
+		bodies   []*BlockBody
+		receipts []types.Receipts
+	}{
+		// Requests for pruned blocks should get an empty response
+		{[]common.Hash{hash(2)}, []*BlockBody{}, []types.Receipts{}},
+		{[]common.Hash{hash(3), hash(4), hash(5)}, []*BlockBody{}, []types.Receipts{}},
+
+		// Available blocks should be served up to the first pruned one
+		{[]common.Hash{hash(5), hash(6)}, []*BlockBody{body(5), body(6)}, []types.Receipts{receipts(5), receipts(6)}},
+		{[]common.Hash{hash(6), hash(1), hash(7)}, []*BlockBody{body(6)}, []types.Receipts{receipts(6)}},
+
+		// Unknown blocks should still be skipped
+		{[]common.Hash{{}, hash(7)}, []*BlockBody{body(7)}, []types.Receipts{receipts(7)}},
+	}
+	for i, tt := range tests {
+		p2p.Send(peer.app, GetBlockBodiesMsg, tt.hashes)
+		if err := p2p.ExpectMsg(peer.app, BlockBodiesMsg, tt.bodies); err != nil {
+			t.Errorf("test %d: bodies mismatch: %v", i, err)
+		}
+		p2p.Send(peer.app, GetReceiptsMsg, tt.hashes)
+		if err := p2p.ExpectMsg(peer.app, ReceiptsMsg, tt.receipts); err != nil {
+			t.Errorf("test %d: receipts mismatch: %v", i, err)
+		}
+	}
+}
@@ -166,10 +166,18 @@ func answerGetBlockBodiesQuery(backend Backend, query GetBlockBodiesPacket, peer
 			lookups >= 2*maxBodiesServe {
 			break
 		}
-		if data := backend.Chain().GetBodyRLP(hash); len(data) != 0 {
-			bodies = append(bodies, data)
-			bytes += len(data)
+		data := backend.Chain().GetBodyRLP(hash)
+		if len(data) == 0 {
+			// Pruned history is not served, stop at the first pruned block so
+			// that requests for pruned bodies get an empty response
+			if header := backend.Chain().GetHeaderByHash(hash); header != nil && backend.Chain().HistoryPruned(header.Number.Uint64()) {
+				peer.Log().Trace("Requested block body pruned", "number", header.Number, "hash", hash)
+				break
+			}
+			continue
 		}
+		bodies = append(bodies, data)
+		bytes += len(data)
 	}
 	return bodies
 }
@@ -257,7 +265,17 @@ func answerGetReceiptsQuery(backend Backend, query GetReceiptsPacket, peer *Peer
 		// Retrieve the requested block's receipts
 		results := backend.Chain().GetReceiptsByHash(hash)
 		if results == nil {
-			if header := backend.Chain().GetHeaderByHash(hash); header == nil || header.ReceiptHash != types.EmptyRootHash {
+			header := backend.Chain().GetHeaderByHash(hash)
+			if header == nil {
+				continue
+			}
+			if header.ReceiptHash != types.EmptyRootHash {
+				// Pruned history is not served, stop at the first pruned block
+				// so that requests for pruned receipts get an empty response
+				if backend.Chain().HistoryPruned(header.Number.Uint64()) {
+					peer.Log().Trace("Requested receipts pruned", "number", header.Number, "hash", hash)
+					break
+				}
 				continue
 			}
 		}
@@ -337,6 +337,25 @@ type syncProgress struct {
 	BytecodeHealNops   uint64             // Number of bytecodes not requested
 }
 
+// Progress is a snapshot of the statistics of a snap sync, exposed to report the
+// sync status to the outside.
+type Progress struct {
+	AccountSynced  uint64             // Number of accounts downloaded
+	AccountBytes   common.StorageSize // Number of account trie bytes persisted to disk
+	BytecodeSynced uint64             // Number of bytecodes downloaded
+	BytecodeBytes  common.StorageSize // Number of bytecode bytes downloaded
+	StorageSynced  uint64             // Number of storage slots downloaded
+	StorageBytes   common.StorageSize // Number of storage trie bytes persisted to disk
+
+	TrienodeHealSynced uint64             // Number of state trie nodes downloaded
+	TrienodeHealBytes  common.StorageSize // Number of state trie bytes persisted to disk
+	BytecodeHealSynced uint64             // Number of bytecodes downloaded
+	BytecodeHealBytes  common.StorageSize // Number of bytecodes persisted to disk
+	HealPending        uint64             // Number of state trie nodes and bytecodes pending healing
+
+	ETA time.Time // Estimated completion of the sync phase (zero if unknown or healing)
+}
+
 // SyncPeer abstracts out the methods required for a peer to be synced against
 // with the goal of allowing the construction of mock peers without the full
 // blown networking.
@@ -440,6 +459,9 @@ type Syncer struct {
 	startAcc  common.Hash // Account hash where sync started from
 	logTime   time.Time   // Time instance when status was last reported
 
+	progress     Progress     // Latest progress statistics for outside readers
+	progressLock sync.RWMutex // Protects the progress statistics
+
 	pend sync.WaitGroup // Tracks network request goroutines for graceful shutdown
 	lock sync.RWMutex   // Protects fields that can change outside of sync (peers, reqs, root)
 }
@@ -2549,25 +2571,47 @@ func (s *Syncer) onHealByteCodes(peer SyncPeer, id uint64, bytecodes [][]byte) e
 // hashSpace is the total size of the 256 bit hash space for accounts.
 var hashSpace = new(big.Int).Exp(common.Big2, common.Big256, nil)
 
-// report calculates various status reports and provides it to the user.
-func (s *Syncer) report(force bool) {
+// Progress returns the latest statistics of the snap sync.
+func (s *Syncer) Progress() Progress {
+	s.progressLock.RLock()
+	defer s.progressLock.RUnlock()
+
+	return s.progress
+}
+
+// updateProgress publishes the current sync statistics to the outside readers.
+func (s *Syncer) updateProgress() {
+	progress := Progress{
+		AccountSynced:      s.accountSynced,
+		AccountBytes:       s.accountBytes,
+		BytecodeSynced:     s.bytecodeSynced,
+		BytecodeBytes:      s.bytecodeBytes,
+		StorageSynced:      s.storageSynced,
+		StorageBytes:       s.storageBytes,
+		TrienodeHealSynced: s.trienodeHealSynced,
+		TrienodeHealBytes:  s.trienodeHealBytes,
+		BytecodeHealSynced: s.bytecodeHealSynced,
+		BytecodeHealBytes:  s.bytecodeHealBytes,
+	}
 	if len(s.tasks) > 0 {
-		s.reportSyncProgress(force)
-		return
+		if _, _, estTime, ok := s.estimateSync(); ok {
+			progress.ETA = s.startTime.Add(estTime)
+		}
+	} else if s.healer != nil {
+		progress.HealPending = uint64(s.healer.scheduler.Pending())
 	}
-	s.reportHealProgress(force)
+	s.progressLock.Lock()
+	s.progress = progress
+	s.progressLock.Unlock()
 }
 
-// reportSyncProgress calculates various status reports and provides it to the user.
-func (s *Syncer) reportSyncProgress(force bool) {
-	// Don't report all the events, just occasionally
-	if !force && time.Since(s.logTime) < 3*time.Second {
-		return
-	}
-	// Don't report anything until we have a meaningful progress
+// estimateSync extrapolates the total size of the state and the total time of
+// the sync phase from the share of the account hash space already filled. False
+// is returned if there's no meaningful progress to estimate from yet.
+func (s *Syncer) estimateSync() (common.StorageSize, float64, time.Duration, bool) {
 	synced := s.accountBytes + s.bytecodeBytes + s.storageBytes
 	if synced == 0 {
-		return
+		return 0, 0, 0, false
 	}
 	accountGaps := new(big.Int)
 	for _, task := range s.tasks {
@@ -2575,9 +2619,8 @@ func (s *Syncer) reportSyncProgress(force bool) {
 	}
 	accountFills := new(big.Int).Sub(hashSpace, accountGaps)
 	if accountFills.BitLen() == 0 {
-		return
+		return 0, 0, 0, false
 	}
-	s.logTime = time.Now()
 	estBytes := float64(new(big.Int).Div(
 		new(big.Int).Mul(new(big.Int).SetUint64(uint64(synced)), hashSpace),
 		accountFills,
@@ -2586,6 +2629,34 @@ func (s *Syncer) reportSyncProgress(force bool) {
 	elapsed := time.Since(s.startTime)
 	estTime := elapsed / time.Duration(synced) * time.Duration(estBytes)
 
+	return synced, estBytes, estTime, true
+}
+
+// report calculates various status reports and provides it to the user.
+func (s *Syncer) report(force bool) {
+	s.updateProgress()
+
+	if len(s.tasks) > 0 {
+		s.reportSyncProgress(force)
+		return
+	}
+	s.reportHealProgress(force)
+}
+
+// reportSyncProgress calculates various status reports and provides it to the user.
+func (s *Syncer) reportSyncProgress(force bool) {
+	// Don't report all the events, just occasionally
+	if !force && time.Since(s.logTime) < 3*time.Second {
+		return
+	}
+	// Don't report anything until we have a meaningful progress
+	synced, estBytes, estTime, ok := s.estimateSync()
+	if !ok {
+		return
+	}
+	s.logTime = time.Now()
+	elapsed := time.Since(s.startTime)
+
 	// Create a mega progress report
 	var (
 		progress = fmt.Sprintf("%.2f%%", float64(synced)*100/estBytes)
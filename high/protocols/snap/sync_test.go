@@ -620,6 +620,41 @@ func TestSyncWithStorage(t *testing.T) {
 	}
 }
 
+// TestSyncProgress tests that the sync statistics are exposed to the outside.
+func TestSyncProgress(t *testing.T) {
+	t.Parallel()
+
+	cancel := make(chan struct{})
+	sourceAccountTrie, elems, storageTries, storageElems := makeAccountTrieWithStorage(3, 3000, true)
+
+	source := newTestPeer("source", t, cancel)
+	source.accountTrie = sourceAccountTrie
+	source.accountValues = elems
+	source.storageTries = storageTries
+	source.storageValues = storageElems
+
+	syncer := setupSyncer(source)
+	if progress := syncer.Progress(); progress != (Progress{}) {
+		t.Fatalf("progress reported before sync: %+v", progress)
+	}
+	if err := syncer.Sync(sourceAccountTrie.Hash(), cancel); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	progress := syncer.Progress()
+	if progress.AccountSynced != 3 || progress.AccountBytes == 0 {
+		t.Errorf("account progress mismatch: have %d@%v, want 3", progress.AccountSynced, progress.AccountBytes)
+	}
+	if progress.BytecodeSynced != 3 || progress.BytecodeBytes == 0 {
+		t.Errorf("bytecode progress mismatch: have %d@%v, want 3", progress.BytecodeSynced, progress.BytecodeBytes)
+	}
+	if progress.StorageSynced < 3000 || progress.StorageBytes == 0 {
+		t.Errorf("storage progress mismatch: have %d@%v, want at least 3000", progress.StorageSynced, progress.StorageBytes)
+	}
+	if progress.HealPending != 0 || !progress.ETA.IsZero() {
+		t.Errorf("completed sync reports pending work: heal %d, eta %v", progress.HealPending, progress.ETA)
+	}
+}
+
 // TestMultiSyncManyUseless contains one good peer, and many which doesn't return anything valuable at all
 func TestMultiSyncManyUseless(t *testing.T) {
 	t.Parallel()
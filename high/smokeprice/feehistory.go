@@ -0,0 +1,254 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package smokeprice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync/atomic"
+
+	"github.com/420integrated/go-highcoin/log"
+	"github.com/420integrated/go-highcoin/rpc"
+)
+
+var (
+	errInvalidPercentile = errors.New("invalid smoke price percentile")
+	errRequestBeyondHead = errors.New("request beyond head block")
+)
+
+const (
+	// maxFeeHistory is the maximum number of blocks that can be retrieved for a
+	// smoke price history request.
+	maxFeeHistory = 1024
+
+	// maxBlockFetchers is the max number of goroutines to spin up to pull blocks
+	// for the smoke price history calculation.
+	maxBlockFetchers = 4
+)
+
+// blockFees represents a single block for processing
+type blockFees struct {
+	// set by the caller
+	blockNumber uint64
+
+	// filled by processBlock
+	prices         []*big.Int
+	smokeUsedRatio float64
+	smokeLimit     uint64
+	err            error
+}
+
+// smokeUsedRatio returns the ratio of the smoke used to the smoke limit of a
+// block, reporting zero for blocks without a smoke limit instead of NaN, which
+// can't be encoded into the JSON response.
+func smokeUsedRatio(used, limit uint64) float64 {
+	if limit == 0 {
+		return 0
+	}
+	return float64(used) / float64(limit)
+}
+
+// txSmokeAndPrice is the smoke used and the smoke price of a transaction.
+type txSmokeAndPrice struct {
+	smokeUsed uint64
+	price     *big.Int
+}
+
+// blockHistory is the smoke price history of a single block, cached to serve
+// repeated requests for overlapping block ranges.
+type blockHistory struct {
+	txs        []txSmokeAndPrice // Transactions of the block, in ascending smoke price order
+	smokeUsed  uint64            // Total smoke used by the block
+	smokeLimit uint64            // Smoke limit of the block
+}
+
+// blockHistory retrieves the smoke price history of the given block, either
+// from the cache or by sorting the block's transactions and receipts.
+func (gpo *Oracle) blockHistory(ctx context.Context, number uint64) (*blockHistory, error) {
+	block, err := gpo.backend.BlockByNumber(ctx, rpc.BlockNumber(number))
+	if block == nil {
+		return nil, err
+	}
+	if history, ok := gpo.histories.Get(block.Hash()); ok {
+		return history.(*blockHistory), nil
+	}
+	receipts, err := gpo.backend.GetReceipts(ctx, block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	txs := block.Transactions()
+	if len(receipts) != len(txs) {
+		return nil, fmt.Errorf("receipt count mismatch for block #%d: have %d, want %d", number, len(receipts), len(txs))
+	}
+	history := &blockHistory{
+		txs:        make([]txSmokeAndPrice, len(txs)),
+		smokeUsed:  block.SmokeUsed(),
+		smokeLimit: block.SmokeLimit(),
+	}
+	for i, tx := range txs {
+		history.txs[i] = txSmokeAndPrice{smokeUsed: receipts[i].SmokeUsed, price: tx.SmokePrice()}
+	}
+	sort.Slice(history.txs, func(i, j int) bool {
+		return history.txs[i].price.Cmp(history.txs[j].price) < 0
+	})
+	gpo.histories.Add(block.Hash(), history)
+	return history, nil
+}
+
+// processBlock fills in the smoke price percentiles and the smoke usage of the
+// given block, reusing the cached block histories of the oracle.
+func (gpo *Oracle) processBlock(ctx context.Context, bf *blockFees, percentiles []float64) {
+	// If no percentiles were requested, the header is enough to fill everything
+	if len(percentiles) == 0 {
+		header, err := gpo.backend.HeaderByNumber(ctx, rpc.BlockNumber(bf.blockNumber))
+		if header == nil {
+			bf.err = err
+			return
+		}
+		bf.smokeLimit = header.SmokeLimit
+		bf.smokeUsedRatio = smokeUsedRatio(header.SmokeUsed, header.SmokeLimit)
+		return
+	}
+	history, err := gpo.blockHistory(ctx, bf.blockNumber)
+	if history == nil {
+		bf.err = err
+		return
+	}
+	bf.smokeLimit = history.smokeLimit
+	bf.smokeUsedRatio = smokeUsedRatio(history.smokeUsed, history.smokeLimit)
+
+	// Blocks without transactions report zero for all percentiles
+	bf.prices = make([]*big.Int, len(percentiles))
+	if len(history.txs) == 0 {
+		for i := range bf.prices {
+			bf.prices[i] = new(big.Int)
+		}
+		return
+	}
+	// The percentiles are weighted by the smoke used of the transactions, so the
+	// price of a percentile is the one paid by the transaction spending the smoke
+	// at that share of the block's total
+	var (
+		index    int
+		consumed = history.txs[0].smokeUsed
+	)
+	for i, p := range percentiles {
+		threshold := uint64(float64(history.smokeUsed) * p / 100)
+		for consumed < threshold && index < len(history.txs)-1 {
+			index++
+			consumed += history.txs[index].smokeUsed
+		}
+		bf.prices[i] = new(big.Int).Set(history.txs[index].price)
+	}
+}
+
+// FeeHistory returns data relevant for smoke price estimation based on a range
+// of blocks ending with lastBlock. The range can be specified either with
+// absolute block numbers or ending with the latest block. Pending blocks are
+// not supported by the oracle backends and are treated as the latest one.
+//
+// For each block the following values are returned:
+//   - prices: the requested percentiles of the smoke prices paid by the block's
+//     transactions, weighted by the smoke used of each transaction (omitted if
+//     no percentiles were requested)
+//   - smokeUsedRatio: the ratio of the block's smoke used and smoke limit
+//   - smokeLimit: the smoke limit of the block
+//
+// The number of returned blocks may be lower than requested if the chain is
+// not long enough. The number of the oldest returned block is returned too.
+func (gpo *Oracle) FeeHistory(ctx context.Context, blocks int, lastBlock rpc.BlockNumber, percentiles []float64) (*big.Int, [][]*big.Int, []float64, []uint64, error) {
+	if blocks < 1 {
+		return new(big.Int), nil, nil, nil, nil
+	}
+	if blocks > maxFeeHistory {
+		log.Warn("Sanitizing fee history length", "requested", blocks, "truncated", maxFeeHistory)
+		blocks = maxFeeHistory
+	}
+	for i, p := range percentiles {
+		if p < 0 || p > 100 {
+			return nil, nil, nil, nil, fmt.Errorf("%w: %f", errInvalidPercentile, p)
+		}
+		if i > 0 && p < percentiles[i-1] {
+			return nil, nil, nil, nil, fmt.Errorf("%w: #%d:%f > #%d:%f", errInvalidPercentile, i-1, percentiles[i-1], i, p)
+		}
+	}
+	// Resolve the last block of the requested range
+	if lastBlock == rpc.PendingBlockNumber {
+		lastBlock = rpc.LatestBlockNumber
+	}
+	head, err := gpo.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if head == nil {
+		return nil, nil, nil, nil, err
+	}
+	last := head.Number.Uint64()
+	if lastBlock != rpc.LatestBlockNumber {
+		if uint64(lastBlock) > last {
+			return nil, nil, nil, nil, fmt.Errorf("%w: requested %d, head %d", errRequestBeyondHead, lastBlock, last)
+		}
+		last = uint64(lastBlock)
+	}
+	if uint64(blocks) > last+1 {
+		blocks = int(last + 1)
+	}
+	oldest := last + 1 - uint64(blocks)
+
+	// Process the blocks with a limited number of concurrent fetchers
+	var (
+		next    = oldest
+		results = make(chan *blockFees, blocks)
+	)
+	for i := 0; i < maxBlockFetchers && i < blocks; i++ {
+		go func() {
+			for {
+				// Retrieve the next block number to fetch with this goroutine
+				number := atomic.AddUint64(&next, 1) - 1
+				if number > last {
+					return
+				}
+				fees := &blockFees{blockNumber: number}
+				if ctx.Err() != nil {
+					fees.err = ctx.Err()
+				} else {
+					gpo.processBlock(ctx, fees, percentiles)
+				}
+				results <- fees
+			}
+		}()
+	}
+	var (
+		prices         = make([][]*big.Int, blocks)
+		smokeUsedRatio = make([]float64, blocks)
+		smokeLimit     = make([]uint64, blocks)
+	)
+	for i := 0; i < blocks; i++ {
+		fees := <-results
+		if fees.err != nil {
+			return nil, nil, nil, nil, fees.err
+		}
+		idx := fees.blockNumber - oldest
+		prices[idx] = fees.prices
+		smokeUsedRatio[idx] = fees.smokeUsedRatio
+		smokeLimit[idx] = fees.smokeLimit
+	}
+	if len(percentiles) == 0 {
+		prices = nil
+	}
+	return new(big.Int).SetUint64(oldest), prices, smokeUsedRatio, smokeLimit, nil
+}
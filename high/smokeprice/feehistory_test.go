@@ -0,0 +1,218 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package smokeprice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/core"
+	"github.com/420integrated/go-highcoin/core/types"
+	"github.com/420integrated/go-highcoin/params"
+	"github.com/420integrated/go-highcoin/rpc"
+)
+
+func TestFeeHistory(t *testing.T) {
+	var cases = []struct {
+		count    int
+		last     rpc.BlockNumber
+		percent  []float64
+		expFirst uint64
+		expCount int
+		expErr   error
+	}{
+		{4, rpc.LatestBlockNumber, nil, 29, 4, nil},
+		{4, rpc.PendingBlockNumber, []float64{0, 50}, 29, 4, nil},
+		{4, 10, []float64{50}, 7, 4, nil},
+		{40, 10, []float64{50}, 0, 11, nil},
+		{0, 10, nil, 0, 0, nil},
+		{4, 33, nil, 0, 0, errRequestBeyondHead},
+		{4, 10, []float64{101}, 0, 0, errInvalidPercentile},
+		{4, 10, []float64{50, 25}, 0, 0, errInvalidPercentile},
+	}
+	backend := newTestBackend(t)
+	oracle := NewOracle(backend, Config{Blocks: 3, Percentile: 60, Default: big.NewInt(params.GMarleys)})
+
+	for i, c := range cases {
+		first, prices, ratio, limit, err := oracle.FeeHistory(context.Background(), c.count, c.last, c.percent)
+		if !errors.Is(err, c.expErr) {
+			t.Fatalf("test %d: error mismatch: have %v, want %v", i, err, c.expErr)
+		}
+		if err != nil {
+			continue
+		}
+		if first.Uint64() != c.expFirst {
+			t.Fatalf("test %d: first block mismatch: have %d, want %d", i, first, c.expFirst)
+		}
+		if len(ratio) != c.expCount || len(limit) != c.expCount {
+			t.Fatalf("test %d: block count mismatch: have %d/%d, want %d", i, len(ratio), len(limit), c.expCount)
+		}
+		if len(c.percent) == 0 {
+			if prices != nil {
+				t.Fatalf("test %d: unexpected smoke prices: %v", i, prices)
+			}
+			continue
+		}
+		if len(prices) != c.expCount {
+			t.Fatalf("test %d: smoke price count mismatch: have %d, want %d", i, len(prices), c.expCount)
+		}
+		// Every block but the genesis contains a single transaction priced at
+		// the block number in GMarleys
+		for j, blockPrices := range prices {
+			number := c.expFirst + uint64(j)
+			expect := new(big.Int).Mul(new(big.Int).SetUint64(number), big.NewInt(params.GMarleys))
+			for _, price := range blockPrices {
+				if price.Cmp(expect) != 0 {
+					t.Fatalf("test %d: block %d smoke price mismatch: have %d, want %d", i, number, price, expect)
+				}
+			}
+			if limit[j] == 0 || ratio[j] < 0 || ratio[j] > 1 {
+				t.Fatalf("test %d: block %d invalid smoke usage: used ratio %f, limit %d", i, number, ratio[j], limit[j])
+			}
+		}
+	}
+}
+
+// zeroLimitBackend is a test backend serving blocks without a smoke limit.
+type zeroLimitBackend struct {
+	*testBackend
+	hashes map[common.Hash]common.Hash // Hashes of the served blocks to the original ones
+}
+
+func (b *zeroLimitBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	header, err := b.testBackend.HeaderByNumber(ctx, number)
+	if header == nil {
+		return nil, err
+	}
+	header = types.CopyHeader(header)
+	header.SmokeLimit = 0
+	return header, nil
+}
+
+func (b *zeroLimitBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	block, err := b.testBackend.BlockByNumber(ctx, number)
+	if block == nil {
+		return nil, err
+	}
+	header, _ := b.HeaderByNumber(ctx, number)
+	served := block.WithSeal(header)
+	b.hashes[served.Hash()] = block.Hash()
+	return served, nil
+}
+
+func (b *zeroLimitBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return b.testBackend.GetReceipts(ctx, b.hashes[hash])
+}
+
+// Tests that blocks without a smoke limit report a zero smoke used ratio rather
+// than NaN, which would fail the encoding of the whole response.
+func TestFeeHistoryZeroSmokeLimit(t *testing.T) {
+	oracle := NewOracle(&zeroLimitBackend{newTestBackend(t), make(map[common.Hash]common.Hash)}, Config{Blocks: 3, Percentile: 60, Default: big.NewInt(params.GMarleys)})
+
+	for _, percent := range [][]float64{nil, {50}} {
+		_, _, ratio, _, err := oracle.FeeHistory(context.Background(), 4, rpc.LatestBlockNumber, percent)
+		if err != nil {
+			t.Fatalf("percentiles %v: failed to retrieve fee history: %v", percent, err)
+		}
+		for i, r := range ratio {
+			if r != 0 {
+				t.Errorf("percentiles %v: block %d smoke used ratio mismatch: have %f, want 0", percent, i, r)
+			}
+		}
+		if _, err := json.Marshal(ratio); err != nil {
+			t.Errorf("percentiles %v: failed to encode smoke used ratios: %v", percent, err)
+		}
+	}
+}
+
+// Tests that the returned smoke prices are not shared with the sample cache of
+// the oracle, so callers modifying them don't corrupt later results.
+func TestFeeHistoryPricesNotShared(t *testing.T) {
+	backend := newTestBackend(t)
+	oracle := NewOracle(backend, Config{Blocks: 3, Percentile: 60, Default: big.NewInt(params.GMarleys)})
+
+	price, err := oracle.SuggestPrice(context.Background())
+	if err != nil {
+		t.Fatalf("failed to suggest smoke price: %v", err)
+	}
+	_, prices, _, _, err := oracle.FeeHistory(context.Background(), 4, rpc.LatestBlockNumber, []float64{50})
+	if err != nil {
+		t.Fatalf("failed to retrieve fee history: %v", err)
+	}
+	want := make([]*big.Int, len(prices))
+	for i := range prices {
+		want[i] = new(big.Int).Set(prices[i][0])
+		prices[i][0].SetUint64(0)
+	}
+	price.SetUint64(0)
+
+	_, prices, _, _, err = oracle.FeeHistory(context.Background(), 4, rpc.LatestBlockNumber, []float64{50})
+	if err != nil {
+		t.Fatalf("failed to retrieve fee history: %v", err)
+	}
+	for i := range prices {
+		if prices[i][0].Cmp(want[i]) != 0 {
+			t.Errorf("block %d: cached smoke price modified: have %v, want %v", i, prices[i][0], want[i])
+		}
+	}
+	if price, _ := oracle.SuggestPrice(context.Background()); price.Sign() == 0 {
+		t.Errorf("cached smoke price suggestion modified")
+	}
+}
+
+// Tests that the reward percentiles are weighted by the smoke used of the
+// transactions rather than by their count.
+func TestFeeHistoryWeightedPercentiles(t *testing.T) {
+	signer := types.LatestSigner(params.TestChainConfig)
+	backend := newTestBackendWithGenerator(t, func(i int, b *core.BlockGen) {
+		// A plain transfer paying 1 GMarley and a bulky one paying 2 GMarleys
+		small, err := types.SignTx(types.NewTransaction(b.TxNonce(testAddr), common.HexToAddress("deadbeef"), big.NewInt(100), 21000, big.NewInt(params.GMarleys), nil), signer, testKey)
+		if err != nil {
+			t.Fatalf("failed to create tx: %v", err)
+		}
+		b.AddTx(small)
+		data := make([]byte, 4096)
+		for i := range data {
+			data[i] = 0xff
+		}
+		large, err := types.SignTx(types.NewTransaction(b.TxNonce(testAddr), common.HexToAddress("deadbeef"), big.NewInt(100), 200000, big.NewInt(2*params.GMarleys), data), signer, testKey)
+		if err != nil {
+			t.Fatalf("failed to create tx: %v", err)
+		}
+		b.AddTx(large)
+	})
+	oracle := NewOracle(backend, Config{Blocks: 3, Percentile: 60, Default: big.NewInt(params.GMarleys)})
+
+	_, prices, _, _, err := oracle.FeeHistory(context.Background(), 4, rpc.LatestBlockNumber, []float64{0, 19, 25, 100})
+	if err != nil {
+		t.Fatalf("failed to retrieve fee history: %v", err)
+	}
+	// The transfer uses 21000 of the block's 107536 smoke, so the 25th percentile
+	// already falls on the bulky transaction even though it's half of the txs
+	want := []*big.Int{big.NewInt(params.GMarleys), big.NewInt(params.GMarleys), big.NewInt(2 * params.GMarleys), big.NewInt(2 * params.GMarleys)}
+	for i, blockPrices := range prices {
+		for j, price := range blockPrices {
+			if price.Cmp(want[j]) != 0 {
+				t.Errorf("block %d, percentile %d: smoke price mismatch: have %v, want %v", i, j, price, want[j])
+			}
+		}
+	}
+}
@@ -27,9 +27,13 @@ import (
 	"github.com/420integrated/go-highcoin/log"
 	"github.com/420integrated/go-highcoin/params"
 	"github.com/420integrated/go-highcoin/rpc"
+	lru "github.com/hashicorp/golang-lru"
 )
 
-const sampleNumber = 3 // Number of transactions sampled in a block
+const (
+	sampleNumber     = 3    // Number of transactions sampled in a block
+	sampleCacheLimit = 2048 // Number of block samples to keep cached
+)
 
 var DefaultMaxPrice = big.NewInt(500 * params.GMarleys)
 
@@ -44,6 +48,7 @@ type Config struct {
 type OracleBackend interface {
 	HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error)
 	BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error)
+	GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error)
 	ChainConfig() *params.ChainConfig
 }
 
@@ -56,6 +61,8 @@ type Oracle struct {
 	maxPrice  *big.Int
 	cacheLock sync.RWMutex
 	fetchLock sync.Mutex
+	samples   *lru.Cache // Cache of the lowest smoke prices of blocks, keyed by block hash
+	histories *lru.Cache // Cache of the smoke price histories of blocks, keyed by block hash
 
 	checkBlocks int
 	percentile  int
@@ -83,8 +90,12 @@ func NewOracle(backend OracleBackend, params Config) *Oracle {
 		maxPrice = DefaultMaxPrice
 		log.Warn("Sanitizing invalid smokeprice oracle price cap", "provided", params.MaxPrice, "updated", maxPrice)
 	}
+	samples, _ := lru.New(sampleCacheLimit)
+	histories, _ := lru.New(sampleCacheLimit)
 	return &Oracle{
 		backend:     backend,
+		samples:     samples,
+		histories:   histories,
 		lastPrice:   params.Default,
 		maxPrice:    maxPrice,
 		checkBlocks: blocks,
@@ -103,7 +114,7 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	lastHead, lastPrice := gpo.lastHead, gpo.lastPrice
 	gpo.cacheLock.RUnlock()
 	if headHash == lastHead {
-		return lastPrice, nil
+		return new(big.Int).Set(lastPrice), nil
 	}
 	gpo.fetchLock.Lock()
 	defer gpo.fetchLock.Unlock()
@@ -113,7 +124,7 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	lastHead, lastPrice = gpo.lastHead, gpo.lastPrice
 	gpo.cacheLock.RUnlock()
 	if headHash == lastHead {
-		return lastPrice, nil
+		return new(big.Int).Set(lastPrice), nil
 	}
 	var (
 		sent, exp int
@@ -165,7 +176,7 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	gpo.lastHead = headHash
 	gpo.lastPrice = price
 	gpo.cacheLock.Unlock()
-	return price, nil
+	return new(big.Int).Set(price), nil
 }
 
 type getBlockPricesResult struct {
@@ -192,6 +203,26 @@ func (gpo *Oracle) getBlockPrices(ctx context.Context, signer types.Signer, bloc
 		}
 		return
 	}
+	// The samples are cached, hand out copies of the prices
+	sample := gpo.sampleBlock(signer, block, limit)
+
+	prices := make([]*big.Int, 0, len(sample))
+	for _, price := range sample {
+		prices = append(prices, new(big.Int).Set(price))
+	}
+	select {
+	case result <- getBlockPricesResult{prices, nil}:
+	case <-quit:
+	}
+}
+
+// sampleBlock retrieves the lowest smoke prices of the non-miner transactions of
+// the given block, either from the cache or by sorting the block's transactions.
+// The samples are keyed by block hash so reorged blocks are never mixed up.
+func (gpo *Oracle) sampleBlock(signer types.Signer, block *types.Block, limit int) []*big.Int {
+	if sample, ok := gpo.samples.Get(block.Hash()); ok {
+		return sample.([]*big.Int)
+	}
 	blockTxs := block.Transactions()
 	txs := make([]*types.Transaction, len(blockTxs))
 	copy(txs, blockTxs)
@@ -207,10 +238,8 @@ func (gpo *Oracle) getBlockPrices(ctx context.Context, signer types.Signer, bloc
 			}
 		}
 	}
-	select {
-	case result <- getBlockPricesResult{prices, nil}:
-	case <-quit:
-	}
+	gpo.samples.Add(block.Hash(), prices)
+	return prices
 }
 
 type bigIntArray []*big.Int
@@ -162,6 +162,17 @@ type TraceConfig struct {
 	Reexec  *uint64
 }
 
+// TraceCallConfig is the config for traceCall API. It holds one more
+// field to override the state and the block context for tracing.
+type TraceCallConfig struct {
+	*vm.LogConfig
+	Tracer         *string
+	Timeout        *string
+	Reexec         *uint64
+	StateOverrides *highapi.StateOverride
+	BlockOverrides *highapi.BlockOverrides
+}
+
 // StdTraceConfig holds extra parameters to standard-json trace functions.
 type StdTraceConfig struct {
 	vm.LogConfig
@@ -685,7 +696,10 @@ func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *
 // created during the execution of EVM if the given transaction was added on
 // top of the provided block and returns them as a JSON object.
 // You can provide -2 as a block number to trace on top of the pending block.
-func (api *API) TraceCall(ctx context.Context, args highapi.CallArgs, blockNrOrHash rpc.BlockNumberOrHash, config *TraceConfig) (interface{}, error) {
+//
+// Additionally, the caller can specify a batch of accounts and block header
+// fields to override before the call is executed.
+func (api *API) TraceCall(ctx context.Context, args highapi.CallArgs, blockNrOrHash rpc.BlockNumberOrHash, config *TraceCallConfig) (interface{}, error) {
 	// Try to retrieve the specified block
 	var (
 		err   error
@@ -710,17 +724,33 @@ func (api *API) TraceCall(ctx context.Context, args highapi.CallArgs, blockNrOrH
 	}
 	defer release()
 
+	vmctx := core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil)
+
+	// Apply the customized state and block context overrides if any
+	var traceConfig *TraceConfig
+	if config != nil {
+		if err := config.StateOverrides.Apply(statedb); err != nil {
+			return nil, err
+		}
+		config.BlockOverrides.Apply(&vmctx)
+
+		traceConfig = &TraceConfig{
+			LogConfig: config.LogConfig,
+			Tracer:    config.Tracer,
+			Timeout:   config.Timeout,
+			Reexec:    config.Reexec,
+		}
+	}
 	// Execute the trace
 	msg := args.ToMessage(api.backend.RPCSmokeCap())
-	vmctx := core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil)
-	return api.traceTx(ctx, msg, vmctx, statedb, config)
+	return api.traceTx(ctx, msg, vmctx, statedb, traceConfig)
 }
 
 // traceTx configures a new tracer according to the provided configuration, and
 // executes the given message in the provided environment. The return value will
 // be tracer dependent.
 func (api *API) traceTx(ctx context.Context, message core.Message, vmctx vm.BlockContext, statedb *state.StateDB, config *TraceConfig) (interface{}, error) {
-	// Assemble the structured logger or the JavaScript tracer
+	// Assemble the structured logger, the native or the JavaScript tracer
 	var (
 		tracer    vm.Tracer
 		err       error
@@ -735,15 +765,15 @@ func (api *API) traceTx(ctx context.Context, message core.Message, vmctx vm.Bloc
 				return nil, err
 			}
 		}
-		// Constuct the JavaScript tracer to execute with
-		if tracer, err = New(*config.Tracer, txContext); err != nil {
+		// Constuct the native or JavaScript tracer to execute with
+		if tracer, err = NewTracer(*config.Tracer, txContext); err != nil {
 			return nil, err
 		}
 		// Handle timeouts and RPC cancellations
 		deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
 		go func() {
 			<-deadlineCtx.Done()
-			tracer.(*Tracer).Stop(errors.New("execution timeout"))
+			tracer.(ResultTracer).Stop(errors.New("execution timeout"))
 		}()
 		defer cancel()
 
@@ -776,7 +806,7 @@ func (api *API) traceTx(ctx context.Context, message core.Message, vmctx vm.Bloc
 			StructLogs:  highapi.FormatLogs(tracer.StructLogs()),
 		}, nil
 
-	case *Tracer:
+	case ResultTracer:
 		return tracer.GetResult()
 
 	default:
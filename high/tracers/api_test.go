@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/ecdsa"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
@@ -214,7 +215,7 @@ func TestTraceCall(t *testing.T) {
 	var testSuite = []struct {
 		blockNumber rpc.BlockNumber
 		call        highapi.CallArgs
-		config      *TraceConfig
+		config      *TraceCallConfig
 		expectErr   error
 		expect      interface{}
 	}{
@@ -321,6 +322,99 @@ func TestTraceCall(t *testing.T) {
 	}
 }
 
+func TestTraceCallWithOverrides(t *testing.T) {
+	t.Parallel()
+
+	// Initialize test accounts, the contract returns the current block number
+	accounts := newAccounts(2)
+	contract := common.HexToAddress("0x00000000000000000000000000000000deadbeef")
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Highcoin)},
+		contract: {
+			Balance: big.NewInt(0),
+			Code:    []byte{byte(vm.NUMBER), byte(vm.PUSH1), 0x00, byte(vm.MSTORE), byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00, byte(vm.RETURN)},
+		},
+	}}
+	api := NewAPI(newTestBackend(t, 0, genesis, func(i int, b *core.BlockGen) {}))
+
+	var (
+		balance = (*hexutil.Big)(big.NewInt(params.Highcoin))
+		number  = (*hexutil.Big)(big.NewInt(1337))
+		code    = hexutil.Bytes{byte(vm.PUSH1), 0x01, byte(vm.PUSH1), 0x00, byte(vm.MSTORE), byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00, byte(vm.RETURN)}
+	)
+	var testSuite = []struct {
+		call      highapi.CallArgs
+		config    *TraceCallConfig
+		expectErr bool
+		expect    string
+	}{
+		// Plain transfer from an empty account, insufficient funds
+		{
+			call: highapi.CallArgs{
+				From:  &accounts[1].addr,
+				To:    &accounts[0].addr,
+				Value: (*hexutil.Big)(big.NewInt(1000)),
+			},
+			config:    nil,
+			expectErr: true,
+		},
+		// Plain transfer from an empty account, balance overridden
+		{
+			call: highapi.CallArgs{
+				From:  &accounts[1].addr,
+				To:    &accounts[0].addr,
+				Value: (*hexutil.Big)(big.NewInt(1000)),
+			},
+			config: &TraceCallConfig{
+				StateOverrides: &highapi.StateOverride{
+					accounts[1].addr: highapi.OverrideAccount{Balance: &balance},
+				},
+			},
+			expect: "",
+		},
+		// Contract call, no overrides
+		{
+			call:   highapi.CallArgs{From: &accounts[0].addr, To: &contract},
+			config: nil,
+			expect: common.Hash{}.Hex()[2:],
+		},
+		// Contract call, block number overridden
+		{
+			call: highapi.CallArgs{From: &accounts[0].addr, To: &contract},
+			config: &TraceCallConfig{
+				BlockOverrides: &highapi.BlockOverrides{Number: number},
+			},
+			expect: common.BigToHash(big.NewInt(1337)).Hex()[2:],
+		},
+		// Contract call, code overridden
+		{
+			call: highapi.CallArgs{From: &accounts[0].addr, To: &contract},
+			config: &TraceCallConfig{
+				StateOverrides: &highapi.StateOverride{
+					contract: highapi.OverrideAccount{Code: &code},
+				},
+			},
+			expect: common.BigToHash(big.NewInt(1)).Hex()[2:],
+		},
+	}
+	for i, testspec := range testSuite {
+		result, err := api.TraceCall(context.Background(), testspec.call, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), testspec.config)
+		if testspec.expectErr {
+			if err == nil {
+				t.Errorf("test %d: expected error, got nothing", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: expected no error, got %v", i, err)
+			continue
+		}
+		if have := result.(*highapi.ExecutionResult).ReturnValue; have != testspec.expect {
+			t.Errorf("test %d: return value mismatch, want %s, have %s", i, testspec.expect, have)
+		}
+	}
+}
+
 func TestTraceTransaction(t *testing.T) {
 	t.Parallel()
 
@@ -485,3 +579,49 @@ func newAccounts(n int) (accounts Accounts) {
 	sort.Sort(accounts)
 	return accounts
 }
+
+func TestTraceBlockStateDiff(t *testing.T) {
+	t.Parallel()
+
+	// Initialize test accounts
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Highcoin)},
+	}}
+	signer := types.HomesteadSigner{}
+	api := NewAPI(newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {
+		// Transfer twice from account[0] to account[1], free of charge
+		for nonce := uint64(0); nonce < 2; nonce++ {
+			tx, _ := types.SignTx(types.NewTransaction(nonce, accounts[1].addr, big.NewInt(1000), params.TxSmoke, big.NewInt(0), nil), signer, accounts[0].key)
+			b.AddTx(tx)
+		}
+	}))
+	tracer := "stateDiffTracer"
+	result, err := api.TraceBlockByNumber(context.Background(), rpc.BlockNumber(1), &TraceConfig{Tracer: &tracer})
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("trace count mismatch: have %d, want 2", len(result))
+	}
+	for i, res := range result {
+		if res.Error != "" {
+			t.Fatalf("tx %d: trace failed: %v", i, res.Error)
+		}
+		var diff stateDiff
+		if err := json.Unmarshal(res.Result.(json.RawMessage), &diff); err != nil {
+			t.Fatalf("tx %d: failed to unmarshal trace: %v", i, err)
+		}
+		// Only the two accounts may change, the recipient not existing before the first transfer
+		if len(diff.Pre) != 1+i || len(diff.Post) != 2 {
+			t.Fatalf("tx %d: modified account count mismatch: have %d pre, %d post", i, len(diff.Pre), len(diff.Post))
+		}
+		sender, recipient := diff.Post[accounts[0].addr], diff.Post[accounts[1].addr]
+		if sender == nil || sender.Nonce != uint64(i+1) || diff.Pre[accounts[0].addr].Nonce != uint64(i) {
+			t.Errorf("tx %d: sender nonce mismatch", i)
+		}
+		if recipient == nil || recipient.Balance.ToInt().Int64() != int64(1000*(i+1)) {
+			t.Errorf("tx %d: recipient balance mismatch", i)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/common/hexutil"
+	"github.com/420integrated/go-highcoin/core/vm"
+	"github.com/holiman/uint256"
+)
+
+func init() {
+	RegisterNative("callTracer", newCallTracer)
+}
+
+// callFrame is a single call in the call tree reported by the call tracer. The
+// field order matches the serialization order of the JavaScript callTracer.
+type callFrame struct {
+	Type      string      `json:"type,omitempty"`
+	From      string      `json:"from,omitempty"`
+	To        string      `json:"to,omitempty"`
+	Value     string      `json:"value,omitempty"`
+	Smoke     string      `json:"smoke,omitempty"`
+	SmokeUsed string      `json:"smokeUsed,omitempty"`
+	Input     string      `json:"input,omitempty"`
+	Output    string      `json:"output,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Time      string      `json:"time,omitempty"`
+	Calls     []callFrame `json:"calls,omitempty"`
+
+	smokeIn   uint64  // Smoke available when the call opcode was executed
+	smokeCost uint64  // Smoke cost of the call opcode itself
+	smoke     *uint64 // Smoke actually available inside the call, if known
+	outOff    uint64  // Memory offset to retrieve the call output from
+	outLen    uint64  // Memory length of the call output
+}
+
+// callTracer is a native Go implementation of the JavaScript callTracer. It
+// extracts and reports all the internal calls made by a transaction, along
+// with any useful information.
+type callTracer struct {
+	callstack []callFrame // Current recursive call stack of the EVM execution
+	descended bool        // Whether we've just descended into an inner call
+	root      callFrame   // Outer transaction context, filled on start and end
+
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+}
+
+// newCallTracer creates a new native call tracer.
+func newCallTracer(txCtx vm.TxContext) ResultTracer {
+	return &callTracer{callstack: make([]callFrame, 1)}
+}
+
+// CaptureStart implements the Tracer interface to initialize the tracing operation.
+func (t *callTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, smoke uint64, value *big.Int) error {
+	t.root = callFrame{
+		Type:  "CALL",
+		From:  addrToHex(from),
+		To:    addrToHex(to),
+		Value: bigToHex(value),
+		Smoke: hexutil.EncodeUint64(smoke),
+		Input: hexutil.Encode(input),
+	}
+	if create {
+		t.root.Type = "CREATE"
+	}
+	return nil
+}
+
+// CaptureState implements the Tracer interface to trace a single step of VM execution.
+func (t *callTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, smoke, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, rData []byte, contract *vm.Contract, depth int, err error) error {
+	// If tracing was interrupted, stop collecting anything
+	if atomic.LoadUint32(&t.interrupt) > 0 {
+		return nil
+	}
+	// Capture any errors immediately
+	if err != nil {
+		t.fault(err)
+		return nil
+	}
+	// If a new contract is being created, add to the call stack
+	switch op {
+	case vm.CREATE, vm.CREATE2:
+		inOff, inEnd := stackPeek(stack, 1), stackPeek(stack, 1)+stackPeek(stack, 2)
+		t.callstack = append(t.callstack, callFrame{
+			Type:      op.String(),
+			From:      addrToHex(contract.Address()),
+			Input:     hexutil.Encode(memorySlice(memory, inOff, inEnd)),
+			Value:     bigToHex(stackPeekBig(stack, 0)),
+			smokeIn:   smoke,
+			smokeCost: cost,
+		})
+		t.descended = true
+		return nil
+
+	case vm.SELFDESTRUCT:
+		// If a contract is being self destructed, gather that as a subcall too
+		parent := &t.callstack[len(t.callstack)-1]
+		parent.Calls = append(parent.Calls, callFrame{
+			Type:  op.String(),
+			From:  addrToHex(contract.Address()),
+			To:    addrToHex(common.BigToAddress(stackPeekBig(stack, 0))),
+			Value: bigToHex(env.StateDB.GetBalance(contract.Address())),
+		})
+		return nil
+
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		// Skip any pre-compile invocations, those are just fancy opcodes
+		to := common.BigToAddress(stackPeekBig(stack, 1))
+		if _, ok := vm.PrecompiledContractsIstanbul[to]; ok {
+			return nil
+		}
+		off := 1
+		if op == vm.DELEGATECALL || op == vm.STATICCALL {
+			off = 0
+		}
+		inOff, inEnd := stackPeek(stack, 2+off), stackPeek(stack, 2+off)+stackPeek(stack, 3+off)
+
+		call := callFrame{
+			Type:      op.String(),
+			From:      addrToHex(contract.Address()),
+			To:        addrToHex(to),
+			Input:     hexutil.Encode(memorySlice(memory, inOff, inEnd)),
+			smokeIn:   smoke,
+			smokeCost: cost,
+			outOff:    stackPeek(stack, 4+off),
+			outLen:    stackPeek(stack, 5+off),
+		}
+		if op != vm.DELEGATECALL && op != vm.STATICCALL {
+			call.Value = bigToHex(stackPeekBig(stack, 2))
+		}
+		t.callstack = append(t.callstack, call)
+		t.descended = true
+		return nil
+	}
+	// If we've just descended into an inner call, retrieve it's true allowance. We
+	// need to extract if from within the call as there may be funky smoke dynamics
+	// with regard to requested and actually given smoke (2300 stipend, 63/64 rule).
+	if t.descended {
+		if depth >= len(t.callstack) {
+			allowance := smoke
+			t.callstack[len(t.callstack)-1].smoke = &allowance
+		}
+		t.descended = false
+	}
+	// If an existing call is returning, pop off the call stack
+	if op == vm.REVERT {
+		t.callstack[len(t.callstack)-1].Error = "execution reverted"
+		return nil
+	}
+	if depth == len(t.callstack)-1 {
+		// Pop off the last call and get the execution results
+		call := t.callstack[len(t.callstack)-1]
+		t.callstack = t.callstack[:len(t.callstack)-1]
+
+		ret := stackPeekBig(stack, 0)
+		if call.Type == "CREATE" || call.Type == "CREATE2" {
+			// If the call was a CREATE, retrieve the contract address and output code
+			call.SmokeUsed = hexutil.EncodeUint64(call.smokeIn - call.smokeCost - smoke)
+
+			if ret.Sign() != 0 {
+				addr := common.BigToAddress(ret)
+				call.To = addrToHex(addr)
+				call.Output = hexutil.Encode(env.StateDB.GetCode(addr))
+			} else if call.Error == "" {
+				call.Error = "internal failure"
+			}
+		} else {
+			// If the call was a contract call, retrieve the smoke usage and output
+			if call.smoke != nil {
+				call.SmokeUsed = hexutil.EncodeUint64(call.smokeIn - call.smokeCost + *call.smoke - smoke)
+			}
+			if ret.Sign() != 0 {
+				call.Output = hexutil.Encode(memorySlice(memory, call.outOff, call.outOff+call.outLen))
+			} else if call.Error == "" {
+				call.Error = "internal failure"
+			}
+		}
+		if call.smoke != nil {
+			call.Smoke = hexutil.EncodeUint64(*call.smoke)
+		}
+		// Inject the call into the previous one
+		parent := &t.callstack[len(t.callstack)-1]
+		parent.Calls = append(parent.Calls, call)
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface to trace an execution fault
+// while running an opcode.
+func (t *callTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, smoke, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, contract *vm.Contract, depth int, err error) error {
+	if atomic.LoadUint32(&t.interrupt) > 0 {
+		return nil
+	}
+	t.fault(err)
+	return nil
+}
+
+// fault handles the failure of the currently executing call frame.
+func (t *callTracer) fault(err error) {
+	// If the topmost call already reverted, don't handle the additional fault again
+	if t.callstack[len(t.callstack)-1].Error != "" {
+		return
+	}
+	// Pop off the just failed call
+	call := t.callstack[len(t.callstack)-1]
+	t.callstack = t.callstack[:len(t.callstack)-1]
+	call.Error = err.Error()
+
+	// Consume all available smoke and clean any leftovers
+	if call.smoke != nil {
+		call.Smoke = hexutil.EncodeUint64(*call.smoke)
+		call.SmokeUsed = call.Smoke
+	}
+	// Flatten the failed call into its parent
+	if len(t.callstack) > 0 {
+		parent := &t.callstack[len(t.callstack)-1]
+		parent.Calls = append(parent.Calls, call)
+		return
+	}
+	// Last call failed too, leave it in the stack
+	t.callstack = append(t.callstack, call)
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *callTracer) CaptureEnd(output []byte, smokeUsed uint64, d time.Duration, err error) error {
+	t.root.SmokeUsed = hexutil.EncodeUint64(smokeUsed)
+	t.root.Output = hexutil.Encode(output)
+	t.root.Time = d.String()
+	if err != nil {
+		t.root.Error = err.Error()
+	}
+	return nil
+}
+
+// GetResult returns the JSON encoded call tree of the traced transaction, or
+// the reason the tracing was interrupted.
+func (t *callTracer) GetResult() (json.RawMessage, error) {
+	result := t.root
+	result.Calls = t.callstack[0].Calls
+	if t.callstack[0].Error != "" {
+		result.Error = t.callstack[0].Error
+	}
+	if result.Error != "" && (result.Error != "execution reverted" || result.Output == "0x") {
+		result.Output = ""
+	}
+	res, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return res, t.reason
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *callTracer) Stop(err error) {
+	t.reason = err
+	atomic.StoreUint32(&t.interrupt, 1)
+}
+
+// addrToHex formats an address the same way the JavaScript toHex builtin does.
+func addrToHex(addr common.Address) string {
+	return hexutil.Encode(addr[:])
+}
+
+// bigToHex formats a number as a 0x prefixed hexadecimal string without any
+// leading zeroes, matching the JavaScript tracers' '0x' + n.toString(16).
+func bigToHex(n *big.Int) string {
+	if n == nil {
+		return "0x0"
+	}
+	return "0x" + n.Text(16)
+}
+
+// stackPeekBig returns the nth-from-the-top element of the stack, or zero if
+// the stack is not deep enough.
+func stackPeekBig(stack *vm.Stack, n int) *big.Int {
+	if n < 0 || len(stack.Data()) <= n {
+		return new(big.Int)
+	}
+	return stack.Back(n).ToBig()
+}
+
+// stackPeek returns the nth-from-the-top element of the stack as a memory offset
+// or length, saturating on values which don't fit into one.
+func stackPeek(stack *vm.Stack, n int) uint64 {
+	if n < 0 || len(stack.Data()) <= n {
+		return 0
+	}
+	return saturate(stack.Back(n))
+}
+
+// saturate converts a 256 bit word to an uint64, capping it at the maximum
+// addressable memory offset.
+func saturate(word *uint256.Int) uint64 {
+	if !word.IsUint64() || word.Uint64() > uint64(1<<62) {
+		return 1 << 62
+	}
+	return word.Uint64()
+}
+
+// memorySlice returns a copy of the requested memory range, or an empty slice
+// if the range is out of bounds.
+func memorySlice(memory *vm.Memory, begin, end uint64) []byte {
+	if end <= begin || end > uint64(memory.Len()) {
+		return []byte{}
+	}
+	return memory.GetCopy(int64(begin), int64(end-begin))
+}
@@ -1,12 +1,12 @@
 // Code generated by go-bindata. DO NOT EDIT.
 // sources:
-// 4byte_tracer.js (2.933kB)
+// 4byte_tracer.js (2.937kB)
 // bigram_tracer.js (1.712kB)
-// call_tracer.js (8.956kB)
-// evmdis_tracer.js (4.195kB)
+// call_tracer.js (9.049kB)
+// evmdis_tracer.js (4.199kB)
 // noop_tracer.js (1.271kB)
 // opcount_tracer.js (1.372kB)
-// prestate_tracer.js (4.287kB)
+// prestate_tracer.js (4.293kB)
 // trigram_tracer.js (1.788kB)
 // unigram_tracer.js (1.469kB)
 
@@ -77,7 +77,7 @@ func (fi bindataFileInfo) Sys() interface{} {
 	return nil
 }
 
-var __4byte_tracerJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x94\x56\x5b\x6f\xdb\x4a\x0e\x7e\xb6\x7f\x05\xd7\x2f\xb5\x51\x59\x8e\x2f\x89\x2f\xd9\x16\xf0\xe6\xa4\x6d\x80\x9c\x24\x88\xdd\x3d\x28\x16\xfb\x30\x9e\xa1\xac\xd9\xc8\x33\xc2\x0c\xe5\x4b\x73\xf2\xdf\x17\x1c\x49\x89\x93\xd3\x62\xbb\x4f\x96\x47\xc3\x8f\x1f\xc9\x8f\xa4\x7a\x3d\xb8\xb0\xf9\xc1\xe9\x75\x4a\x30\x38\xe9\x8f\x61\x99\x22\xac\x6d\x17\x29\x45\x87\xc5\x06\xe6\x05\xa5\xd6\xf9\x66\xaf\x07\xcb\x54\x7b\x48\x74\x86\xa0\x3d\xe4\xc2\x11\xd8\x04\xe8\xcd\xfd\x4c\xaf\x9c\x70\x87\xb8\xd9\xeb\x95\x36\x3f\x7c\xcd\x08\x89\x43\x04\x6f\x13\xda\x09\x87\x33\x38\xd8\x02\xa4\x30\xe0\x50\x69\x4f\x4e\xaf\x0a\x42\xd0\x04\xc2\xa8\x9e\x75\xb0\xb1\x4a\x27\x07\x86\xd4\x04\x85\x51\xe8\x82\x6b\x42\xb7\xf1\x35\x8f\xcf\x37\x5f\xe1\x1a\xbd\x47\x07\x9f\xd1\xa0\x13\x19\xdc\x15\xab\x4c\x4b\xb8\xd6\x12\x8d\x47\x10\x1e\x72\x3e\xf1\x29\x2a\x58\x05\x38\x36\xfc\xc4\x54\x16\x15\x15\xf8\x64\x0b\xa3\x04\x69\x6b\x22\x40\xcd\xcc\x61\x8b\xce\x6b\x6b\x60\x58\xbb\xaa\x00\x23\xb0\x8e\x41\xda\x82\x38\x00\x07\x36\x67\xbb\x0e\x08\x73\x80\x4c\xd0\x8b\xe9\x2f\x24\xe4\x25\x6e\x05\xda\x04\x37\xa9\xcd\x11\x28\x15\xc4\x51\xef\x74\x96\xc1\x0a\xa1\xf0\x98\x14\x59\xc4\x68\xab\x82\xe0\x8f\xab\xe5\x97\xdb\xaf\x4b\x98\xdf\x7c\x83\x3f\xe6\xf7\xf7\xf3\x9b\xe5\xb7\x73\xd8\x69\x4a\x6d\x41\x80\x5b\x2c\xa1\xf4\x26\xcf\x34\x2a\xd8\x09\xe7\x84\xa1\x03\xd8\x84\x11\x7e\xbf\xbc\xbf\xf8\x32\xbf\x59\xce\xff\x71\x75\x7d\xb5\xfc\x06\xd6\xc1\xa7\xab\xe5\xcd\xe5\x62\x01\x9f\x6e\xef\x61\x0e\x77\xf3\xfb\xe5\xd5\xc5\xd7\xeb\xf9\x3d\xdc\x7d\xbd\xbf\xbb\x5d\x5c\xc6\xb0\x40\x66\x85\x6c\xff\xbf\x73\x9e\x84\xea\x39\x04\x85\x24\x74\xe6\xeb\x4c\x7c\xb3\x05\xf8\xd4\x16\x99\x82\x54\x6c\x11\x1c\x4a\xd4\x5b\x54\x20\x40\xda\xfc\xf0\xcb\x45\x65\x2c\x91\x59\xb3\x0e\x31\xff\x54\x90\x70\x95\x80\xb1\x14\x81\x47\x84\xbf\xa7\x44\xf9\xac\xd7\xdb\xed\x76\xf1\xda\x14\xb1\x75\xeb\x5e\x56\xc2\xf9\xde\xc7\xb8\xc9\x98\xa3\xd5\x81\x70\xe9\x84\x44\x07\x1e\x85\x93\x29\xfa\x10\x4c\x78\xd1\xd5\x0a\x0d\xe9\x44\xa3\xf3\x11\x8b\x14\xa4\xcd\x32\x94\xe4\x99\xc1\x26\x5c\xcc\xad\xa7\x6e\xee\xac\x44\xef\xb5\x59\x73\xe0\x70\x45\xaf\x2e\xc2\x06\x29\xb5\xca\xc3\x11\xdc\xdb\x68\xbc\xfe\x8e\x75\x36\x7c\x91\x97\x65\x54\x82\x44\x04\xde\x86\xe8\xc1\x21\xcb\x0c\x15\x78\xbd\x36\x82\x0a\x87\xa1\x97\x56\x08\x1b\x41\x92\xc5\x2e\xd6\x42\x1b\x4f\x7f\x01\x64\x9c\xba\x22\x97\x7b\xb1\xc9\x33\x9c\xf1\x33\xc0\x47\x50\xb8\x2a\xd6\x31\x71\x0a\x96\x4e\x18\x2f\x24\x8b\xbb\x0d\xad\x93\xfd\xa0\x3f\xc2\xd3\xe9\x18\x87\xa7\x4a\x9c\x4c\x86\x67\xd3\x41\x72\x3a\x9c\x9c\xf5\x47\x7d\x3c\x9b\x26\xa3\x31\x4e\xc7\xc3\xd5\x40\x9e\x9e\xe1\x58\x4c\x4e\xc6\xc3\x55\x1f\xc5\xc9\x24\x51\xe3\xd3\x71\x1f\xa7\x0a\x5b\x11\x3c\x06\x60\x37\x83\xd6\x51\xa6\x5b\x4f\x9d\xd2\xfb\x63\xf9\x03\x70\xb2\x1f\x8c\x95\x1c\x4c\xc7\xd8\xed\x0f\x26\x33\xe8\x47\x2f\x6f\x86\x13\x29\x47\x93\x61\xbf\x7b\x32\x83\xc1\xd1\xf9\xe9\x60\x94\x0c\x27\x93\x69\x77\x7a\xf6\xda\x40\xa8\xe4\x74\x9a\x4c\xa7\xdd\xc1\xe4\x0d\x94\x1c\x4c\xfa\xaa\x3f\x45\x86\xea\x97\xc7\x4f\xcd\xc7\x66\x83\x07\x8e\xf2\x20\xd6\x6b\x87\x6b\x41\x58\x56\x2d\x30\x0e\x2f\x12\x1e\x16\x71\xb3\xc1\xcf\x33\x78\x7c\x8a\x9a\xc1\x46\x8a\x2c\x5b\x1e\x72\x56\x35\x15\xce\x78\x78\x97\x88\xcc\xe3\xbb\xa0\x0b\x63\x4d\x97\x2f\x78\x1e\x1f\x01\x2f\x47\x7c\xe8\x6a\xa3\x70\x1f\x2e\xf0\x51\xa2\x9d\x27\x1e\xb3\x62\x13\x10\x45\xc2\xd3\xe4\xdd\x56\x64\x05\xbe\x8b\x40\xc7\x18\xc3\x06\x37\x5c\x54\xe1\x28\x6e\x36\x6a\x97\x33\x48\x0a\x53\x56\xca\xe6\x9e\x5c\xe7\xb1\xd9\x68\xf8\x9d\x26\x99\x1e\x1d\x48\xe1\x11\x5a\x17\xf3\xeb\xeb\xd6\x0c\x5e\xfe\x5c\xdc\xfe\x76\xd9\x9a\x35\x1b\x0d\x76\xb9\x16\x2c\x6d\xa5\x5c\x04\x5b\x91\x45\xa5\xbb\xea\xc7\x7f\x0f\x0f\xb6\xa0\xfa\xd7\x7f\x67\xb3\x32\x5e\x18\x9e\x43\xaf\x07\x9e\x84\x7c\x80\x9c\x1c\x90\x2d\xcd\x9a\xcf\xae\x7f\xbb\xbc\xbe\xfc\x3c\x5f\x5e\xbe\xa2\xb0\x58\xce\x97\x57\x17\xe5\xd1\x5f\x49\xfc\x1f\xfe\x07\x3f\xf3\xdf\x68\x3c\x35\x9f\x6f\x85\x9a\x9c\x37\x1b\x75\xd5\x3c\xf1\x9c\xf2\x3c\x8d\xc2\x18\xd1\x3c\x3c\xb9\x2c\x55\x6b\x86\x3e\xe7\x8e\xe1\x0e\x8a\x9b\x8d\x70\xff\x28\xdf\x5a\x45\xa1\xb9\x42\x86\xb7\xc2\xc1\x03\x1e\xe0\x03\xb4\x5a\xf0\x1e\xc8\x7e\xc1\x7d\x5b\xab\x0e\xbc\x87\x56\x97\x4f\xf8\xe6\x79\xb3\xd1\xa0\x54\xfb\x58\x2b\xff\xaf\x07\x3c\xfc\x1b\x3e\xc0\xeb\xff\xef\xa1\x0f\x7f\xfe\x09\xfd\x57\x34\x31\xe7\x85\xa1\xcd\xd6\x3e\xa0\x0a\x92\xe1\x01\x70\x00\x9b\x4b\xab\xaa\x8d\xc1\x11\xfc\xf3\x77\xc0\x3d\xca\x82\xd0\x07\xba\x98\x1f\xb1\xcd\xec\x3a\x02\xb5\xea\x00\xb3\xed\xf5\x60\xf1\xa0\xf3\xb0\xb8\x4a\x14\x5f\xc2\xf0\x46\x34\x96\x40\x1b\x42\x67\x44\x16\xa4\xed\xab\xf8\x24\xd5\x7c\x6b\xf5\x31\x6a\x6c\xf3\x98\xec\x82\x9c\x36\xeb\x76\xa7\xc3\x31\xea\x04\xda\x7f\x93\x54\xfa\xaa\xd2\x7f\x5e\x15\xe3\xd8\x75\xee\xb0\x2b\xed\x26\x0f\x5f\x19\x66\x6b\x65\xd8\xc3\x3e\x02\x4a\x2d\xef\x6f\x87\xf0\x9f\xc2\x13\x24\xc2\xc8\x67\xa2\x15\xbe\xf6\x77\x0e\x2b\x63\xd5\x26\x3b\x57\xca\xa1\xf7\x81\x51\x50\x42\xcc\x6d\xd6\xee\x77\x5e\xc8\xf5\xcf\x3a\x9d\xce\xcf\x48\x7d\x16\x61\xf7\xbf\x0a\xbc\x5e\x62\x55\xfc\xda\x2c\xbe\xc3\x07\x78\xe3\x41\x12\x57\xad\x13\x87\x5e\xbd\x4d\xda\xcf\x19\x08\xd7\x3f\x7e\x80\x51\xe5\xb2\x84\xb8\x4d\x92\x1f\x61\xbc\xb1\x2f\x65\x12\x14\x17\x22\x62\xd1\xbb\x43\xec\x79\x6d\xb5\x03\x48\x54\x61\xbd\x87\x51\x27\x0a\xd4\xba\xa3\x4e\x15\x4f\x2d\x9d\x44\x14\x19\x1d\x6b\x67\x97\x56\xdf\x07\x42\x52\x21\xb2\x4a\x2e\xfc\xad\x63\x13\x10\xa6\x56\x54\x52\x6e\xee\x46\xb0\xff\xa1\x86\xa0\x76\xe1\xd0\xff\xc8\x07\x27\x8f\xfd\xd4\xe2\x0a\x3b\x7f\x85\xdc\x60\x84\x4e\xf0\x47\x8f\xdd\x56\x2d\x56\x0d\xcd\x00\x57\xce\x42\xce\x7f\x05\x5c\x2d\x2e\xde\x1e\x61\xa9\x36\xca\xf3\x23\x52\x92\xf6\x2f\xa2\xae\x9b\xd9\x16\x3c\x3f\xb9\x86\xdc\xc0\x20\x32\x6f\xab\xaa\x48\xda\xc7\xda\xe4\x05\xc5\x19\x9a\x35\xa5\xc7\x15\x3a\x4a\x7a\x99\xe9\xe7\xcb\x11\x9c\x44\x21\xd1\x6f\xcd\xbb\xa3\xce\xeb\x29\x53\xf7\x73\xd9\xc1\x4f\xcd\xff\x06\x00\x00\xff\xff\x8e\xc8\x27\x72\x75\x0b\x00\x00")
+var __4byte_tracerJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x95\x56\xdb\x72\xda\x48\x10\x7d\x86\xaf\xe8\xf0\x62\xa8\x08\x61\x71\x31\x17\x6f\x52\xc5\x3a\xd8\xa1\x8a\xd8\x2e\xc0\x9b\x72\x6d\xed\xc3\x30\x1a\x89\x59\x0b\x8d\x4a\x33\xc2\xc6\x8e\xff\x7d\xbb\x47\x92\x0d\x5e\xa7\x36\xcb\x8b\xc4\x4c\xf7\xe9\xd3\x77\xb5\x5a\x70\xa6\x92\x5d\x2a\xc3\xb5\x81\xf6\xb1\xd7\x87\xe5\x5a\x40\xa8\x9a\x6b\x3c\xe1\x4a\xc6\x30\xce\xcc\x5a\xa5\xba\xda\x6a\xe1\x95\xd4\x10\xc8\x48\x00\x3e\x13\x96\x1a\x50\x01\x98\x37\xf2\x91\x5c\xa5\x2c\xdd\xb9\xa8\x90\xeb\xbc\x7b\x4d\x08\x41\x2a\x04\x68\x15\x98\x7b\x96\x8a\x11\xec\x54\x06\x9c\xc5\x90\x0a\x5f\x6a\x93\xca\x55\x66\xd0\x90\x01\x16\xfb\x2d\x95\xc2\x46\xf9\x32\xd8\x11\x24\x9e\x65\xb1\x2f\x52\x6b\xda\x88\x74\xa3\x4b\x1e\x17\x97\x37\x30\x13\x5a\xe3\xdd\x85\x88\x45\xca\x22\xb8\xce\x56\x91\xe4\x30\x93\x5c\xc4\x5a\x00\x43\xe2\x74\xa2\xd7\xc2\x87\x95\x85\x23\xc5\x73\xa2\xb2\x28\xa8\xc0\xb9\x42\x7c\x66\xa4\x8a\x1d\x10\x12\xef\x53\xd8\x8a\x54\xe3\x7f\xe8\x94\xa6\x0a\x40\x07\x54\x4a\x20\x75\x66\xc8\x81\x14\x54\x42\x7a\x0d\x64\xbd\x83\x88\x99\x57\xd5\x5f\x08\xc8\xab\xdf\x3e\xe0\x0d\x99\x59\xab\x04\x7d\x5c\x23\x3a\x7a\x7d\x2f\xa3\x08\x56\x02\x32\x2d\x82\x2c\x72\x08\x0d\x85\xe1\xfb\x74\xf9\xf5\xea\x66\x09\xe3\xcb\x5b\xf8\x3e\x9e\xcf\xc7\x97\xcb\xdb\x53\x14\xc6\xbc\xe1\xad\xd8\x8a\x1c\x4a\x6e\x92\x48\x22\x32\xba\x98\xb2\xd8\xec\xd0\x13\x42\xf8\x36\x99\x9f\x7d\x45\x95\xf1\xef\xd3\xd9\x74\x79\x8b\xfe\xc0\xf9\x74\x79\x39\x59\x2c\xe0\xfc\x6a\x0e\x63\xb8\x1e\xcf\x97\xd3\xb3\x9b\xd9\x78\x0e\xd7\x37\xf3\xeb\xab\xc5\xc4\x85\x85\x20\x56\x82\xf4\xff\x3b\xe6\x81\xcd\x1e\xc6\xd5\x17\x86\xc9\x48\x97\x91\xb8\xc5\x84\x6b\xe4\x18\xf9\xb0\x66\x5b\x81\x89\xe7\x42\x6e\x91\x21\x03\x8e\x35\xf9\xcb\x49\x25\x2c\x16\xa9\x38\xb4\x3e\xff\xb4\x20\x61\x1a\x40\xac\x8c\x03\x1a\xc9\xff\xb6\x36\x26\x19\xb5\x5a\xf7\xf7\xf7\x6e\x18\x67\xae\x4a\xc3\x56\x94\xc3\xe9\xd6\x67\xb7\x4a\x98\xdd\xd5\xce\x88\x65\xca\x38\x5a\xd6\x82\xa5\x7c\x2d\xb4\x75\xc6\x5e\x34\xa5\x2f\x62\x23\x03\x89\xe9\x75\xa8\x48\x91\x74\x14\x09\x6e\x34\x31\xd8\x58\xc1\x44\x69\xd3\x4c\x52\xc5\x91\xbe\x8c\x43\x72\x1c\xa6\xe6\x40\x10\x36\x02\xf3\xe4\x6b\xd8\x83\x7b\xeb\x8d\x96\x8f\xa2\x8c\x86\xce\x92\x3c\x8d\x58\xa0\x0c\x7d\x51\xd6\x7b\x8c\x1d\x95\x19\x1e\x6b\x19\xc6\xcc\x64\x18\x6d\xea\x25\x2c\x96\x0d\x33\x9c\x8a\x9d\x85\x4c\xc6\xda\xfc\x0b\x90\x70\xca\x8c\x4c\x1e\x18\x16\x89\x18\xd1\x3b\xc0\x67\x4c\xd8\x2a\x0b\x5d\x43\x21\xc0\x38\xc4\x9a\x71\x2a\xee\x3a\xd4\x8e\x1f\xda\x5e\x57\xf4\x86\x7d\xd1\xe9\xf9\xec\x78\xd0\x39\x19\xb6\x83\x5e\x67\x70\xe2\x75\x3d\x71\x32\x0c\xba\x7d\x31\xec\x77\x56\x6d\xde\x3b\x11\x7d\x36\x38\xc6\x77\x4f\xa0\x5c\xe0\xf7\x7b\x7d\x4f\x0c\x7d\x51\x73\xe0\xc9\x02\xa7\x23\xa8\xed\x45\xba\xf6\xdc\xc8\xad\x3f\xe5\x0f\x00\xb4\xd5\xf7\x79\x1b\x6d\x35\xbd\xf6\x60\x04\x9e\xf3\x7a\xd3\x19\x70\xde\x1d\x74\xbc\xe6\xf1\x08\xda\x7b\xe7\xbd\x76\x37\xe8\x0c\x06\xc3\xe6\xf0\xe4\x50\x81\xf9\x41\x6f\x18\x0c\x87\xcd\xf6\xe0\x0d\x14\x6f\x0f\x3c\xdf\x1b\x0a\x82\xf2\xf2\xe3\xe7\xea\x53\xb5\x42\x03\x07\xd3\xc3\xc2\x30\x15\x21\x36\x74\x9e\x35\xcb\xd8\x5e\x04\x34\x2c\xdc\x6a\x85\xde\x47\xf0\xf4\xec\x54\xad\x0e\x67\x51\xb4\xdc\x25\x54\xd5\x98\x8d\x58\xc3\x51\xc0\x22\x2d\x8e\x6c\x5d\xc4\x2a\x6e\x92\x80\xa6\xf1\x61\xf1\x12\x21\xee\x9a\x12\xa7\xda\x83\x15\xa0\xa3\x40\xa6\x98\x2e\x1c\xb3\x6c\x63\x11\x59\x40\xd3\xe4\x68\xcb\xa2\x4c\x1c\x39\x20\x5d\xe1\x62\xf1\x6c\x28\xa9\x38\x8a\x91\x41\x69\x72\x04\x41\x16\xe7\x99\x52\x09\x4e\x94\x06\x7a\x51\xd1\x58\x4d\x7c\xbd\x77\xc0\x19\xb6\x66\xed\x6c\x3c\x9b\xd5\x46\xf0\xfa\xe7\xec\xea\xcb\xa4\x36\xc2\x7b\x32\xa9\x37\xea\x0e\x47\x1c\xf3\xfd\xd4\x01\x34\xec\xe4\x06\x8b\x87\x7e\xb4\x2f\x38\x64\xca\xa7\x7e\x24\xc5\xdc\x63\xe8\x9c\x02\x41\x18\xc6\xef\x20\x31\xe8\x93\xca\xd5\xaa\x2f\xc6\xbf\x4c\x66\x93\x8b\xf1\x72\x72\x40\x62\xb1\x1c\xe3\xb8\xc9\x8f\xde\xa3\xf1\x3f\x18\xb4\x7f\xc6\xa0\x52\x79\xae\xbe\x48\xd9\xbc\x9c\x56\x2b\x65\xe6\xb4\xa1\x59\xa5\x69\x22\xd9\x51\x22\x69\x80\x52\x6a\x8a\xf6\xb4\xbd\x4e\x5d\x43\x5d\x84\x61\xb7\xf2\x7b\x31\x97\xbe\x63\x1b\xcc\x46\x79\xcb\x52\xb8\x13\x3b\xf8\x04\xb5\x1a\x7c\x44\x06\x5f\xc5\x03\x4a\x34\xf0\xbd\xd6\xa4\x13\x92\x44\xe3\x15\x83\xbb\xd5\xc5\x1a\xfa\x13\xa5\xff\x42\xf1\xc3\xff\x1f\xc1\x83\x1f\x3f\xc0\x3b\xa0\x29\x12\x5a\x1a\x32\xde\x62\x74\x7c\x5b\x36\x34\x04\x70\x6e\x26\x5c\xf9\xc5\xd6\x20\x0f\xfe\xf8\x06\xe2\x41\x70\x5c\x2b\xda\xd2\x15\xc9\x1e\xdb\x48\x85\x0e\xf8\xab\x06\x10\x5b\x84\x5d\xdc\xc9\xc4\x2e\xaf\x1c\x45\xe7\x30\xb4\x15\x71\x76\xa2\x31\xac\xc1\x18\x27\xb0\xad\xde\xc2\x3f\x6e\x4a\xbe\x65\x05\x12\xaa\xab\x12\xd7\xa8\x05\x2e\xb4\x38\xac\x37\x1a\xe4\xa3\x0c\xa0\xfe\x81\x9b\xdc\x56\x11\xfe\xd3\x22\x19\xfb\xa6\x93\x54\x34\xb9\xda\x24\xf6\x4b\x03\xdd\xe3\x76\x17\x63\xb3\xe0\xa4\xa4\x1d\x8e\x64\xfe\xce\xb0\x37\x02\x16\xf3\x17\xa2\x05\xbe\xd4\xd7\xb8\x47\x72\x65\xbf\x6e\xd4\x18\x8b\x06\xa7\xaf\x65\x64\x2b\xc1\xa5\x56\xab\x7b\x8d\x57\x72\xde\x49\x03\x7f\x3f\x21\x75\xc1\xec\xfe\x3f\x70\xbc\x5c\x64\x85\xff\x32\x5e\x3c\x62\x04\xde\x58\xc0\xa8\x60\xd6\x1a\xae\xed\xd7\xab\xa0\xfe\x12\x01\x2b\xfe\xf9\x13\x74\x0b\x93\x39\xc4\x55\x10\xbc\x87\xf1\x46\x3f\x2f\x13\x5b\x71\xd6\x23\x2a\x7a\x5c\x6d\x9a\x56\x57\xdd\x82\x38\x05\xd6\x47\xc4\x77\x2c\xb5\x66\xb7\x51\xf8\x53\x96\x4e\xc0\xb2\xc8\xec\xd7\xce\xfd\xba\xf8\x46\xc0\x01\x9f\xa1\x93\x79\xb9\xd0\xf7\x0e\x2e\x09\x5c\x23\x45\x45\x05\xf9\xf6\xae\x58\xfd\x77\x6b\x08\x4a\x13\x18\xf3\xf7\x6c\x50\xf0\xc8\x4e\x59\x5c\x76\xef\xaf\x04\x35\x18\xc6\x97\xd1\x87\x8f\xda\x16\x2d\x56\x0c\x4e\x0b\x97\xcf\x43\x8a\x7f\x01\x5c\x2c\x2f\xda\x20\x76\xb1\x56\xf2\xf3\x3d\x52\xdc\x3c\xbc\x16\x75\xd9\xcc\x38\x21\x10\x9d\x72\x48\x0d\x8c\x74\x70\x7f\xe6\x59\x41\x71\x57\xc6\x49\x66\xdc\x48\xc4\x21\x2e\xde\xbd\x0c\xed\x05\x3d\x8f\xf4\x8b\xb0\x03\xc7\x8e\x0d\xf4\x5b\x75\x0c\xfa\xe1\x94\x29\xfb\x39\xef\xe0\xe7\xea\x3f\x70\x51\x9f\xe5\x79\x0b\x00\x00")
 
 func _4byte_tracerJsBytes() ([]byte, error) {
 	return bindataRead(
@@ -93,11 +93,11 @@ func _4byte_tracerJs() (*asset, error) {
 	}
 
 	info := bindataFileInfo{name: "4byte_tracer.js", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
-	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xb4, 0xc5, 0x48, 0x2d, 0xd9, 0x43, 0x95, 0x93, 0x3b, 0x93, 0x2c, 0x47, 0x8c, 0x84, 0x32, 0x3c, 0x8b, 0x2e, 0xf3, 0x72, 0xc4, 0x57, 0xe6, 0x3a, 0xb3, 0xdf, 0x1d, 0xbf, 0x45, 0x3, 0xfc, 0xa}}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xf2, 0xda, 0x64, 0x24, 0x67, 0xc8, 0x2c, 0xf8, 0x1b, 0xfe, 0x2f, 0x33, 0xd9, 0xc3, 0x54, 0x43, 0x65, 0xe, 0x4b, 0x52, 0xd6, 0xbb, 0x76, 0x5, 0xee, 0x22, 0xe8, 0x8c, 0x8c, 0x9c, 0x7c, 0xd3}}
 	return a, nil
 }
 
-var _bigram_tracerJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x54\x5b\x6f\xdb\x36\x14\x7e\xf7\xaf\xf8\xde\x92\x20\xae\xd4\x6e\x2f\x83\x33\x0f\xd0\xb2\xa4\x35\x90\xda\x81\xad\xac\x30\x86\x3d\x50\xd2\x91\x44\x84\x26\x05\xf2\xd0\xae\x50\xe4\xbf\x17\x94\x2c\x5f\x8a\x14\x8d\x9e\x64\xf3\xbb\x9d\x0b\x15\xc7\xb8\x35\x4d\x6b\x65\x55\x33\x7e\x7b\xff\xe1\x0f\xa4\x35\xa1\x32\xef\x88\x6b\xb2\xe4\x37\x48\x3c\xd7\xc6\xba\x51\x1c\x23\xad\xa5\x43\x29\x15\x41\x3a\x34\xc2\x32\x4c\x09\xfe\x01\xaf\x64\x66\x85\x6d\xa3\x51\x1c\xf7\x9c\x57\x8f\x83\x42\x69\x89\xe0\x4c\xc9\x3b\x61\x69\x82\xd6\x78\xe4\x42\xc3\x52\x21\x1d\x5b\x99\x79\x26\x48\x86\xd0\x45\x6c\x2c\x36\xa6\x90\x65\x1b\x24\x25\xc3\xeb\x82\x6c\x67\xcd\x64\x37\x6e\xc8\xf1\x71\xfe\x84\x07\x72\x8e\x2c\x3e\x92\x26\x2b\x14\x1e\x7d\xa6\x64\x8e\x07\x99\x93\x76\x04\xe1\xd0\x84\x7f\x5c\x4d\x05\xb2\x4e\x2e\x10\xef\x43\x94\xd5\x3e\x0a\xee\x8d\xd7\x85\x60\x69\xf4\x18\x24\x43\x72\x6c\xc9\x3a\x69\x34\x7e\x1f\xac\xf6\x82\x63\x18\x1b\x44\x2e\x05\x87\x02\x2c\x4c\x13\x78\x57\x10\xba\x85\x12\x7c\xa4\xbe\xa1\x21\xc7\xba\x0b\x48\xdd\xd9\xd4\xa6\x21\x70\x2d\x38\x54\xbd\x93\x4a\x21\x23\x78\x47\xa5\x57\xe3\xa0\x96\x79\xc6\x97\x59\xfa\x69\xf1\x94\x22\x99\xaf\xf1\x25\x59\x2e\x93\x79\xba\xbe\xc1\x4e\x72\x6d\x3c\x83\xb6\xd4\x4b\xc9\x4d\xa3\x24\x15\xd8\x09\x6b\x85\xe6\x16\xa6\x0c\x0a\x9f\xef\x96\xb7\x9f\x92\x79\x9a\xfc\x3d\x7b\x98\xa5\x6b\x18\x8b\xfb\x59\x3a\xbf\x5b\xad\x70\xbf\x58\x22\xc1\x63\xb2\x4c\x67\xb7\x4f\x0f\xc9\x12\x8f\x4f\xcb\xc7\xc5\xea\x2e\xc2\x8a\x42\x2a\x0a\xfc\x5f\xf7\xbc\xec\xa6\x67\x09\x05\xb1\x90\xca\x0d\x9d\x58\x1b\x0f\x57\x1b\xaf\x0a\xd4\x62\x4b\xb0\x94\x93\xdc\x52\x01\x81\xdc\x34\xed\x9b\x87\x1a\xb4\x84\x32\xba\xea\x6a\xfe\xe9\x42\x62\x56\x42\x1b\x1e\xc3\x11\xe1\xcf\x9a\xb9\x99\xc4\xf1\x6e\xb7\x8b\x2a\xed\x23\x63\xab\x58\xf5\x72\x2e\xfe\x2b\x1a\x8d\xbe\x8d\x00\x20\x8e\x51\x4b\xc7\x61\x38\x41\x36\x37\x5e\x33\xd9\x6e\xdf\x4c\x93\x9b\x82\x90\xc9\xca\x8a\x8d\xeb\xd0\x01\x3a\xc1\xb7\x97\xf1\xc0\x55\xc2\xf1\xa2\x09\xec\xf0\x06\xd3\x90\xed\xd6\xaa\x3b\xef\x0f\x27\xb8\xb8\x38\xe0\xe9\x2b\xe5\x3e\x00\x50\x50\xc3\x75\xb0\xd9\x13\x0f\x8c\x7f\xc2\xc1\x04\xef\x0f\x1c\xc7\xd4\x39\x48\xbd\x35\xcf\x54\x74\xdd\xa6\x2d\xd9\x76\x48\xd8\x6d\x4f\x48\xff\xef\xe7\xbd\x01\xb9\xa8\x63\x07\xea\x04\xa5\xd7\x79\xf0\xbc\x54\xa6\x1a\xa3\xc8\xae\xd0\xd7\x1e\x9e\xad\x08\x1b\x8d\x29\x94\xa9\x22\xd3\x44\x6c\x56\x6c\xa5\xae\x2e\xaf\x6e\xce\x30\x7d\xdc\x1e\x56\x51\x1f\xf2\x14\x23\x4b\x5c\xee\x31\x53\x70\x2d\x5d\x74\xa8\xe5\xea\xe8\x36\xa8\x3d\x53\x8b\x13\xd8\xa2\xb9\xbe\x78\x77\x71\x6d\x9a\x9b\x33\x64\xd0\xec\x30\xa1\xed\xff\x3d\x53\xfb\xff\x0f\x52\xe1\x39\x07\x5c\x5f\x9f\x4b\xbc\x9c\xfd\x22\xe5\x08\xbf\x92\xc0\x14\x1f\x7e\x26\x72\x7c\x3b\xc9\x8e\x29\x4e\x93\x9f\x17\x8f\x69\xdf\xba\xfe\xfc\xb8\x38\xa5\xf0\x8a\x4f\xa7\xba\xab\xf7\xb7\x58\xe4\xec\x85\x3a\xd9\x14\x53\x42\xe8\x61\xd6\x65\x7f\xbf\x82\x4a\x27\xf1\xea\x74\x8f\x36\x96\xdc\x6b\x3e\x42\xa9\xce\xab\x17\x75\xfd\xed\xcc\x88\x34\x24\x87\x0d\xa6\x02\x66\x4b\x36\x7c\x99\x61\x89\xbd\xd5\x6e\x50\x0c\xb4\x52\x6a\xa1\x06\xed\xfd\x25\x66\x2b\x72\xa9\xab\x3e\x5a\x7f\x74\x92\x2d\xe7\xaf\xa7\x5b\xd7\x6b\x1e\x1b\x7f\xe8\xce\xcb\xe8\x7b\x00\x00\x00\xff\xff\x83\xb5\xcb\x27\xb0\x06\x00\x00")
+var _bigram_tracerJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x8d\x54\x4d\x6f\xda\x40\x10\xbd\xf3\x2b\xe6\x06\x08\x62\x27\xed\xa5\x22\x4d\x25\x9a\x86\x04\x29\x05\xc4\x47\x23\x54\xf5\xb0\xd8\x63\x7b\x95\xc5\x6b\xed\xae\x21\x28\xca\x7f\xef\x8c\x6d\x30\x44\x89\x12\x5f\xfc\x31\xf3\xde\xbc\x99\x79\x6b\xdf\x87\x6b\x9d\xed\x8c\x8c\x13\x07\x5f\xce\x2f\xbe\xc1\x3c\x41\x88\xf5\x59\x42\x5f\x02\x2d\x53\xe8\xe7\x2e\xd1\xc6\x36\x7c\x9f\x42\xd2\x42\x24\x15\x02\xdd\x33\x61\x1c\xe8\x08\xdc\xab\x7c\x25\x57\x46\x98\x9d\x47\x80\x12\xf3\x66\x98\x19\x22\x83\x08\x56\x47\x6e\x2b\x0c\xf6\x60\xa7\x73\x08\x44\x0a\x06\x43\x69\x9d\x91\xab\xdc\x51\x21\x07\x22\x0d\x7d\x6d\x60\xad\x43\x19\xed\x98\x92\xbe\xe5\x69\x88\xa6\x28\xed\xd0\xac\xed\x5e\xc7\xed\x68\x01\xf7\x68\x2d\xc5\x6e\x31\x45\x23\x14\x4c\xf2\x95\x92\x01\xdc\xcb\x00\x53\x8b\x20\x48\x38\x7f\xb1\x09\x86\xb0\x2a\xe8\x18\x38\x60\x29\xb3\x4a\x0a\x0c\x34\xf1\x0b\x27\x75\xda\x05\x94\x14\x37\xb0\x41\x63\xe9\x1d\xbe\xee\x4b\x55\x84\x5d\xd0\x86\x49\x5a\xc2\x71\x03\x06\x74\xc6\xb8\x36\xa9\xde\x81\x12\xae\x86\x7e\x62\x20\x75\xdf\x21\x50\x84\xcb\x24\x3a\xa3\x1e\x13\x62\xa7\xae\xb7\x52\x29\x58\x21\xe4\x16\xa3\x5c\x75\x99\x8d\x92\xe1\x61\x38\xbf\x1b\x2f\xe6\xd0\x1f\x2d\xe1\xa1\x3f\x9d\xf6\x47\xf3\xe5\x25\x25\xd3\xde\x28\x8a\x1b\x2c\xa9\xe4\x3a\x53\x92\x98\xa9\x45\x23\x52\xb7\xa3\x4e\x98\xe1\xf7\xcd\xf4\xfa\x8e\x20\xfd\x9f\xc3\xfb\xe1\x7c\x49\xfd\xc0\x60\x38\x1f\xdd\xcc\x66\x30\x18\x4f\xa1\x0f\x93\xfe\x74\x3e\xbc\x5e\xdc\xf7\xa7\x30\x59\x4c\x27\xe3\xd9\x8d\x07\x33\x64\x55\xc8\xf8\x8f\x67\x1e\x15\xdb\xa3\xb9\x86\xe8\x84\x54\x76\x3f\x89\x25\x2d\xdc\x92\x46\x15\x42\x22\x36\x48\x8b\x0f\x50\x6e\x48\xa1\x80\x80\x3c\xf9\xe9\xa5\x32\x97\x50\x3a\x8d\x8b\x9e\xdf\x35\x24\x0c\x23\x48\xb5\xeb\x82\x25\xf1\xdf\x13\xe7\xb2\x9e\xef\x6f\xb7\x5b\x2f\x4e\x73\x4f\x9b\xd8\x57\x25\x9d\xf5\x7f\x78\x8d\xc6\x73\x03\xe8\x22\x66\xf2\xbc\xe3\xe5\x30\x6d\x40\xc6\xa0\x95\x16\x7e\xd3\x59\xa0\x43\x84\x95\x8c\x8d\x58\xdb\x22\x9b\x53\x7b\xf0\xfc\xd2\xdd\x63\x95\xb0\x6e\x9c\x31\x9a\x9f\x08\x42\xea\xd9\x1e\x45\xbc\x0c\xf6\xa0\xd9\x3c\xe4\xe3\x13\x06\x39\x27\xd0\xa8\x32\x6a\x85\xca\x54\xc0\x03\xe2\x17\x07\x7a\x70\x7e\xc0\x58\x87\x45\x05\x99\x6e\xf4\x23\x0d\x8f\xa7\x4d\x3b\x27\x47\x55\x0a\x0b\xf7\xb0\xfa\x3f\xbf\xab\x02\x48\x2b\x60\x34\x43\x7b\x10\xe5\x69\xc0\x35\x5b\x4a\xc7\x5d\x08\x57\x6d\x28\x7b\xe7\x6b\x23\xd8\xd1\x70\x05\x14\xf3\x74\xe6\x39\x3d\x23\x87\xa6\x71\xab\x7d\x79\x92\x53\xca\x2d\xd3\x62\x2c\x45\x1e\xe7\xc8\x08\x5a\x55\xce\x15\x69\x91\xd6\x3b\xf4\xd2\xae\xab\xed\xd9\x1e\x71\x07\x47\x69\xe3\xac\xd3\x3c\x6b\x76\x74\x76\x79\x92\xc9\x9c\x45\x0e\x8f\xfd\x2f\x61\xfe\xbd\xa2\xe2\xeb\x34\xa1\xd3\x39\xa5\x78\x39\x79\x43\x45\x66\xfd\x88\x82\x84\x5d\xbc\x47\x52\x3f\x1d\x69\x27\xc0\xb1\xf2\xd3\xe6\x29\x58\x8c\xa5\x8c\xd7\xc6\x89\x44\xae\xdc\xf1\x56\xb7\x49\x75\x8a\x45\xe0\x72\x3a\x01\xb5\x53\xc8\x23\xf4\xd3\xac\x76\x1d\x95\xe7\x8b\x59\x0a\x8a\x37\xb7\x5b\x97\x31\x68\xdf\xaa\x23\xe8\x37\xc3\xb5\x4a\x52\x5b\x9e\xce\x15\x52\x44\x3a\x76\x30\xe5\x69\x72\x18\xff\x99\x89\xc2\xe5\x26\xb5\x7b\x46\x86\x45\x32\x25\x85\x15\x77\x75\x88\x9d\x11\x01\xf9\xa6\x94\x56\x86\x8e\xb4\x05\xee\xe9\xd8\x75\x25\x67\x3d\xf8\xc3\x74\x5e\x1a\xff\x01\xb5\xa4\xaf\x12\xb0\x06\x00\x00")
 
 func bigram_tracerJsBytes() ([]byte, error) {
 	return bindataRead(
@@ -113,11 +113,11 @@ func bigram_tracerJs() (*asset, error) {
 	}
 
 	info := bindataFileInfo{name: "bigram_tracer.js", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
-	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x77, 0x6c, 0xd, 0x24, 0xf2, 0x49, 0xbd, 0x58, 0x8b, 0xb5, 0xd1, 0xc9, 0xcd, 0xcf, 0x5b, 0x3e, 0x5c, 0xfb, 0x14, 0x50, 0xe7, 0xe3, 0xb9, 0xd1, 0x54, 0x69, 0xe6, 0x5e, 0x45, 0xa6, 0x2c, 0x6c}}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xd4, 0xa, 0xe6, 0xb3, 0x17, 0xfe, 0xd4, 0x0, 0xe3, 0x64, 0xb8, 0x6f, 0xd6, 0xb6, 0x23, 0xcf, 0xd9, 0xe5, 0xb4, 0xb1, 0xb9, 0xec, 0x4f, 0xf6, 0xf9, 0xa2, 0xb7, 0xbd, 0xf2, 0xf4, 0x8c, 0x88}}
 	return a, nil
 }
 
-var _call_tracerJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xd4\x5a\xdf\x6f\x1b\x37\xf2\x7f\x96\xfe\x8a\x89\x1f\x6a\x09\x51\x24\x39\xe9\xb7\x5f\xc0\xae\x7a\x50\x1d\x25\x35\xe0\xc6\x81\xad\x34\x08\x82\x3c\x50\xbb\xb3\x12\x6b\x8a\xdc\x92\x5c\xc9\xba\xd6\xff\xfb\x61\x86\xdc\xd5\xae\x24\x3b\xbe\x5e\x71\xe8\xbd\x69\x97\x33\xc3\xe1\xcc\x67\x7e\x71\x35\x18\xc0\xb9\xc9\x37\x56\xce\x17\x1e\x5e\x0e\x4f\xfe\x1f\xa6\x0b\x84\xb9\x79\x81\x7e\x81\x16\x8b\x25\x8c\x0b\xbf\x30\xd6\xb5\x07\x03\x98\x2e\xa4\x83\x4c\x2a\x04\xe9\x20\x17\xd6\x83\xc9\xc0\xef\xd0\x2b\x39\xb3\xc2\x6e\xfa\xed\xc1\x20\xf0\x1c\x5c\x26\x09\x99\x45\x04\x67\x32\xbf\x16\x16\x4f\x61\x63\x0a\x48\x84\x06\x8b\xa9\x74\xde\xca\x59\xe1\x11\xa4\x07\xa1\xd3\x81\xb1\xb0\x34\xa9\xcc\x36\x24\x52\x7a\x28\x74\x8a\x96\xb7\xf6\x68\x97\xae\xd4\xe3\xed\xbb\x0f\x70\x89\xce\xa1\x85\xb7\xa8\xd1\x0a\x05\xef\x8b\x99\x92\x09\x5c\xca\x04\xb5\x43\x10\x0e\x72\x7a\xe3\x16\x98\xc2\x8c\xc5\x11\xe3\x1b\x52\xe5\x26\xaa\x02\x6f\x4c\xa1\x53\xe1\xa5\xd1\x3d\x40\x49\x9a\xc3\x0a\xad\x93\x46\xc3\xab\x72\xab\x28\xb0\x07\xc6\x92\x90\x8e\xf0\x74\x00\x0b\x26\x27\xbe\x2e\x08\xbd\x01\x25\xfc\x96\xf5\x09\x06\xd9\x9e\x3b\x05\xa9\x79\x9b\x85\xc9\x11\xfc\x42\x78\x3a\xf5\x5a\x2a\x05\x33\x84\xc2\x61\x56\xa8\x1e\x49\x9b\x15\x1e\x3e\x5e\x4c\x7f\xba\xfa\x30\x85\xf1\xbb\x4f\xf0\x71\x7c\x7d\x3d\x7e\x37\xfd\x74\x06\x6b\xe9\x17\xa6\xf0\x80\x2b\x0c\xa2\xe4\x32\x57\x12\x53\x58\x0b\x6b\x85\xf6\x1b\x30\x19\x49\xf8\x79\x72\x7d\xfe\xd3\xf8\xdd\x74\xfc\xe3\xc5\xe5\xc5\xf4\x13\x18\x0b\x6f\x2e\xa6\xef\x26\x37\x37\xf0\xe6\xea\x1a\xc6\xf0\x7e\x7c\x3d\xbd\x38\xff\x70\x39\xbe\x86\xf7\x1f\xae\xdf\x5f\xdd\x4c\xfa\x70\x83\xa4\x15\x12\xff\xd7\x6d\x9e\xb1\xf7\x2c\x42\x8a\x5e\x48\xe5\x4a\x4b\x7c\x32\x05\xb8\x85\x29\x54\x0a\x0b\xb1\x42\xb0\x98\xa0\x5c\x61\x0a\x02\x12\x93\x6f\x9e\xec\x54\x92\x25\x94\xd1\x73\x3e\xf3\x83\x80\x84\x8b\x0c\xb4\xf1\x3d\x70\x88\xf0\xfd\xc2\xfb\xfc\x74\x30\x58\xaf\xd7\xfd\xb9\x2e\xfa\xc6\xce\x07\x2a\x88\x73\x83\x1f\xfa\x6d\x92\x99\x08\xa5\xa6\x56\x24\x68\xc9\x39\x02\xb2\x82\xcc\xaf\xcc\x5a\x83\xb7\x42\x3b\x91\x90\xab\xe9\x77\xc2\x60\x14\x1e\xf0\x8e\x9e\xbc\x23\xd0\x82\xc5\xdc\x58\xfa\xad\x54\x89\x33\xa9\x3d\x5a\x2d\x14\xcb\x76\xb0\x14\x29\xc2\x6c\x03\xa2\x2e\xb0\x57\x3f\x0c\xc1\x28\xb8\x1b\xa4\xce\x8c\x5d\x32\x2c\xfb\xed\xdf\xdb\xad\xa8\xa1\xf3\x22\xb9\x25\x05\x49\x7e\x52\x58\x8b\xda\x93\x29\x0b\xeb\xe4\x0a\x99\x04\x02\x4d\xb4\xe7\xe4\x97\x9f\x01\xef\x30\x29\x82\xa4\x56\x25\xe4\x14\x3e\xff\x7e\xff\xa5\xd7\x66\xd1\x29\xba\x04\x75\x8a\x29\x9f\xef\xd6\xc1\x7a\xc1\x16\x85\x35\x1e\xaf\x10\x7e\x2d\x9c\xaf\xd1\x64\xd6\x2c\x41\x68\x30\x05\x21\xbe\x6e\x1d\xa9\xbd\x61\x81\x82\x7e\x6b\xb4\xac\x51\xbf\xdd\xaa\x98\x4f\x21\x13\xca\x61\xdc\xd7\x79\xcc\xe9\x34\x52\xaf\xcc\x2d\x49\x36\x96\x20\x6c\x37\x60\xf2\xc4\xa4\x31\x18\xe8\x1c\xd5\x31\xd0\xf5\xdb\x2d\xe2\x3b\x85\xac\xd0\xbc\x6d\x47\x99\x79\x0f\xd2\x59\x17\x7e\x6f\xb7\x48\xec\xb9\xc8\x7d\x61\x91\xed\x89\xd6\x1a\xeb\x40\x2e\x97\x98\x4a\xe1\x51\x6d\xda\xad\xd6\x4a\xd8\xb0\x00\x23\x50\x66\xde\x9f\xa3\x9f\xd0\x63\xa7\x7b\xd6\x6e\xb5\x64\x06\x9d\xb0\xfa\x6c\x34\xe2\xec\x93\x49\x8d\x69\x10\xdf\xf2\x0b\xe9\xfa\x99\x28\x94\xaf\xf6\x25\xa6\x96\x45\x5f\x58\x4d\x3f\xef\x83\x16\x1f\x11\x8c\x56\x1b\x48\x28\xcb\x88\x19\x85\xa7\xdb\x38\x8f\xcb\x78\x38\xd7\x83\x4c\x38\x32\xa1\xcc\x60\x8d\x90\x5b\x7c\x91\x2c\x90\x7c\xa7\x13\x8c\x5a\xba\x8d\x63\xa7\x8e\x80\x76\xeb\x9b\xbc\xef\xcd\xbb\x62\x39\x43\xdb\xe9\xc2\x37\x30\xbc\xcb\x86\x5d\x18\x8d\xf8\x47\xa9\x7b\xe4\x89\xfa\x92\x14\x93\xc7\x83\x32\xff\x8d\xb7\x52\xcf\xc3\x59\xa3\xae\x17\x19\x08\xd0\xb8\x86\xc4\x68\x06\x35\x79\x65\x86\x52\xcf\x21\xb1\x28\x3c\xa6\x3d\x10\x69\x0a\xde\x04\xe4\x55\x38\x6b\x6e\x09\xdf\x7c\x03\x1d\xda\x6c\x04\xc7\xe7\xd7\x93\xf1\x74\x72\x0c\x7f\xfc\x01\xe1\xcd\x51\x78\xf3\xf2\xa8\x5b\xd3\x4c\xea\xab\x2c\x8b\xca\xb1\xc0\x7e\x8e\x78\xdb\x39\xe9\xf6\x57\x42\x15\x78\x95\x05\x35\x23\xed\x44\xa7\x30\x8a\x3c\xcf\x77\x79\x5e\x36\x78\x88\x69\x30\x80\xb1\x73\xb8\x9c\x29\xdc\x0f\xc8\x18\xb1\x1c\xbc\xce\x53\xc6\x22\xf4\x25\x66\x99\x2b\x24\x54\x95\xbb\x46\xf3\xb3\xc6\x2d\xbf\xc9\xf1\x14\x00\xc0\xe4\x3d\x7e\x41\xb1\xc0\x2f\xbc\xf9\x09\xef\xd8\x47\xa5\x09\x09\x55\xe3\x34\xb5\xe8\x5c\xa7\xdb\x0d\xe4\x52\xe7\x85\x3f\x6d\x90\x2f\x71\x69\xec\xa6\xef\x28\x21\x75\xf8\x68\xbd\x70\xd2\x92\x67\x2e\xdc\x85\x26\x9e\x88\xd4\xb7\xc2\x75\xb6\x4b\xe7\xc6\xf9\xd3\x72\x89\x1e\xca\x35\xb6\x05\xb1\x1d\x0f\xef\x8e\xf7\xad\x35\xec\x6e\x91\x70\xf2\x5d\x97\x58\xee\xcf\x2a\x7c\x57\x69\xa2\x9f\x17\x6e\xd1\x61\x38\x6d\x57\xb7\xa9\x60\x04\xde\x16\x78\x10\xfe\x0c\xa9\x7d\x38\x39\x54\x19\xe5\x12\x6f\x8b\x84\x61\x35\x17\x9c\x69\x38\xd2\x05\x65\x5e\x57\xcc\xd8\xe6\xde\x98\x7d\x74\x45\x70\xdd\x4c\x2e\xdf\xbc\x9e\xdc\x4c\xaf\x3f\x9c\x4f\x8f\x6b\x70\x52\x98\x79\x52\xaa\x79\x06\x85\x7a\xee\x17\xac\x3f\x89\x6b\xae\x7e\x26\x9e\x17\x27\x5f\xc2\x1b\x18\x1d\x08\xf9\xd6\xe3\x1c\xf0\xf9\x0b\xcb\xbe\xdf\x37\x5f\x93\x34\x18\xf3\xaf\x41\x92\x37\x4c\x5c\x92\x7b\x53\x12\x3c\xee\xe7\xbf\x18\x54\xe9\x8c\x28\x7e\x14\x4a\xe8\x04\x1f\xd1\x79\x1f\x6b\xf5\xa4\x79\x20\x0f\x2d\xd1\x2f\x4c\xca\x85\x21\x11\xa1\xb6\x94\x08\x4a\x8d\xc6\x7f\x3f\x1b\x8d\x2f\x2f\x6b\xb9\x88\x9f\xcf\xaf\x5e\xd7\xf3\xd3\xf1\xeb\xc9\xe5\xe4\xed\x78\x3a\xd9\xa5\xbd\x99\x8e\xa7\x17\xe7\xfc\xb6\x4c\x5d\x83\x01\xdc\xdc\xca\x9c\x2b\x0c\xe7\x6d\xb3\xcc\xb9\x55\xae\xf4\x75\x3d\xf0\x0b\x43\x4d\xa8\x8d\x05\x34\x13\x3a\x29\x0b\x9b\x2b\x01\xeb\x0d\xc1\xf5\x21\xe7\x9d\xec\x38\xaf\x82\xb0\x74\xef\x2d\xc6\x4d\xd3\x8e\x37\xa5\x5e\x5b\x83\x06\x34\x72\xf2\xe7\x04\xdb\x79\xfa\x21\xe1\x1f\x30\x84\x53\x38\x89\x59\xf4\x91\x34\xfd\x12\x9e\x93\xf8\x3f\x91\xac\x5f\x1d\xe0\xfc\x7b\xa6\xec\xbd\x40\xfb\xef\xa7\x72\x53\xf8\xab\x2c\x3b\x85\x5d\x23\x7e\xbb\x67\xc4\x8a\xfe\x12\xf5\x3e\xfd\xff\xed\xd1\x6f\xd3\x3e\xa1\xca\xe4\xf0\x6c\x0f\x22\x21\xe9\x3e\xdb\x89\x83\x68\x5c\x6e\xef\x58\x1a\x8c\x1e\x28\x34\x2f\x9b\x18\x7e\x28\x53\xfe\x47\x85\xe6\x60\x9b\x4a\xcd\x68\xb3\x11\xed\x81\x45\x6f\x25\xae\x68\xd4\x3c\x76\x2c\x92\x1a\x76\xb3\xa6\xf4\xd5\x87\x8f\x18\x24\x6a\x44\x4e\x2e\xb1\xc1\xa7\xfe\x8c\x7b\x5e\x6a\xd2\xe3\xa8\xc6\x10\x13\xdc\x87\x5b\x84\xa5\xd8\xd0\xa8\x96\x15\xfa\x76\x03\x73\xe1\x20\xdd\x68\xb1\x94\x89\x0b\xf2\xb8\xb9\xb7\x38\x17\x96\xc5\x5a\xfc\xad\x40\x47\x73\x1f\x01\x59\x24\xbe\x10\x4a\x6d\x60\x2e\x69\x78\x23\xee\xce\xcb\x57\xc3\x21\x38\x2f\x73\xd4\x69\x0f\xbe\x7b\x35\xf8\xee\x5b\xb0\x85\xc2\x6e\xbf\x5d\x2b\x61\xd5\x51\xa3\x37\x68\x21\xa2\xe7\x35\xe6\x7e\xd1\xe9\xc2\x0f\x0f\xd4\xc2\x07\x0a\xdb\x41\x5a\x78\x01\x27\x5f\xfa\xa4\xd7\xa8\x81\xdb\xe0\x49\x40\xe5\x30\x4a\xa3\x81\xf7\xea\xf5\x55\xe7\x56\x58\xa1\xc4\x0c\xbb\xa7\x3c\x00\xb3\xad\xd6\x22\x4e\x40\xe4\x14\xc8\x95\x90\x1a\x44\x92\x98\x42\x7b\x32\x7c\x39\xcc\xa8\x0d\xe5\xf7\x63\x5f\xca\xe3\x59\x51\x24\x09\x3a\x57\xa6\x7b\xf6\x1a\xa9\x23\x96\xc4\x0d\x52\x3b\x99\x62\xcd\x2b\x94\x1d\x0c\xa7\xe6\x48\x41\xa3\x74\x29\x70\x69\x1c\x6d\x32\x43\x58\x5b\x1a\xbc\x9c\xd4\x09\xdf\x3c\xa4\x48\xd6\x76\x60\x34\x08\x50\x86\xaf\x3b\x38\xc6\x41\xd8\xb9\xeb\x87\x7c\x4f\xdb\x52\xce\xd1\x66\xdd\x6f\x02\xb9\x0e\x55\x1e\x71\x76\x5a\x21\x0d\x78\x27\x9d\xe7\x8e\x9a\xb4\x94\x0e\x02\x92\xa5\x9e\xf7\x20\x37\x39\xe7\xe9\xaf\x95\xb3\x98\xac\xaf\x27\xbf\x4c\xae\xab\xc6\xe7\xe9\x4e\x2c\x67\x9e\xa3\x6a\x24\x04\x4b\xf3\x96\xc7\xf4\xe8\xc0\x10\x73\x00\x50\xa3\x07\x00\x45\xf2\xb7\xb5\xf1\x7d\xed\x38\x4a\x38\xbf\x75\xcc\x1c\xc3\x3c\x57\x57\xc0\x15\xca\xbb\x9d\xdc\xbd\x9b\x1c\x4c\x5e\x56\x08\x52\x8a\xd3\x0e\x25\xf6\xdd\x49\xa3\xb1\xb0\x1d\x38\xb6\xf8\xbc\xa8\xd9\x78\xcd\xed\x66\x20\xaa\xa5\x06\x5e\x2f\xfb\x56\x11\xaa\x01\xeb\x6e\x0a\x4f\x70\xa0\xfa\xbd\x4d\x7e\x73\xe1\x3e\x38\xf6\x7a\x4c\x7f\x33\x39\xbf\xd0\xbe\x53\x2e\x5e\x68\x78\x01\xe5\x03\x25\x75\x78\xd1\x88\xa2\x03\xd9\xb1\x95\xa2\x42\x8f\xb0\x15\x71\x06\x3b\xaf\x48\x50\x30\x07\x1b\xcd\xa2\xdf\x2f\xce\xc3\x28\x8d\x0c\xf6\xcc\xa2\xef\xe3\x6f\x85\x50\xae\x33\xac\x9a\x85\x70\x02\x6f\xb8\xbc\x8d\xf6\x3a\x49\xe2\x69\xf6\x8e\x67\x35\xb6\x68\x8d\x92\x2d\x74\x82\xe7\x26\xc5\x47\x25\x44\x11\x31\x6d\x54\xbe\x8c\xc0\x3c\xd4\x7b\xb7\xea\x04\x70\x54\x35\x04\x99\x90\xaa\xb0\x78\x74\x06\x07\xd2\x8e\x2b\x6c\x26\x12\xf6\xa5\x43\xe0\x69\xdd\x81\x33\x4b\x5c\x98\x75\x50\xe0\x50\xf2\xda\x07\x47\x85\x83\x9d\xf2\xc1\xd7\x4e\xc2\x41\xe1\xc4\x1c\x6b\xe0\xa8\x0c\x5e\x3a\xea\xe0\x15\xc2\x9f\x86\xce\xf3\xea\xf1\x09\x28\xba\xff\x6b\xe0\xb1\xe3\xe7\xbd\x3e\xa7\x24\xe2\x6e\xa7\xf6\x50\x2a\x1b\x9a\x91\xbf\x97\xe3\x9f\x1c\x61\xbb\xb4\xe1\x68\x4d\xe2\x70\xc0\x6d\x5f\xf3\x75\xf7\x57\xab\x0f\x79\xfe\xa1\x96\x89\x30\xaa\x7f\xc5\xc4\x6f\x71\xca\x5d\x0e\x3d\xe5\x16\x57\xd2\x14\x54\xc0\xf0\x7f\x69\x1c\xae\x5a\xbe\xfb\x76\xeb\x3e\xde\x0b\xb2\xdf\xea\x17\x83\xeb\x45\xbc\xd7\x0e\xdd\x52\xad\x7c\x18\xae\xad\xf1\xba\x30\x0b\x37\xce\x2d\xe6\x7f\xe4\x82\x30\x06\xba\x37\x39\xb5\x03\xb1\x3a\x29\x8b\x22\xdd\x54\x05\xb1\x17\x1a\x11\x58\x08\x9d\xc6\x61\x44\xa4\xa9\x24\x79\x0c\x42\xd2\x50\xcc\x85\xd4\xed\x83\x66\xfc\x6a\x15\x3e\x84\x8c\xbd\xde\xb6\x5e\x48\xe3\x10\x49\x13\x1f\x6b\xdc\x7e\x42\xc1\xdc\x09\xa2\xdd\xbb\xce\x78\x5d\x6a\xb4\x2b\x96\xdc\x09\x83\x58\x09\xa9\x04\x4d\x5f\xdc\x61\xe9\x14\x12\x85\x42\x87\x2f\x1c\x98\x79\xb3\x42\xeb\xda\x4f\x00\xf9\x9f\xc1\xf8\x4e\x56\x2c\x1f\xa3\x39\x9e\x1e\xb3\x4f\x8d\xd8\x70\xfc\x37\x4a\x78\x1f\xe1\x55\x33\x6f\x88\x2c\xe9\xf9\xe3\x17\x6a\xdf\x7e\x5a\x48\x71\xcf\x44\x34\x3f\xc0\xb0\xd6\x97\xff\x5d\x82\x6c\x1f\x62\x97\x55\x7f\x16\x0f\xef\x8d\xe9\x81\x42\xc1\x53\x52\xf9\x69\xaa\xec\x47\x1f\x1b\xda\xca\xe8\x0d\x1d\xdd\x5e\xf8\xf2\x9d\xde\x02\xcb\x1b\x90\xd0\xda\xcf\x10\x35\x48\x8f\x56\xd0\x3c\x44\xe8\x8a\x5f\x53\x48\x4b\xc7\xe2\xd8\x2f\x92\x82\x2e\x0a\x8e\x9f\x36\xa8\x30\x4b\x3d\xef\xb7\x5b\xe1\x7d\x2d\xde\x13\x7f\xb7\x8d\xf7\x50\x01\x99\x33\xde\x09\x54\x57\x02\x89\xbf\xe3\x6e\x91\xc7\xe6\x9d\x7b\x01\x5a\xa3\x57\x61\xa6\xde\xb9\x05\x60\xc6\x78\x13\xb0\x7b\x27\x46\x6b\xfc\xae\x01\x70\x26\x9d\x0b\x17\xc4\xec\x84\x84\xbf\xdb\x8f\x88\x92\x81\x82\xe1\xf4\x30\x03\x2d\x1d\x60\xda\xb9\x99\x20\x62\x7e\x15\x56\x43\x3d\x3f\xad\xaf\x86\x57\xf1\xa0\x72\x59\xb3\x8d\x5c\xb2\x6d\xee\xcf\x0e\x27\xb9\x61\x89\xc7\xc3\xc9\x8c\x6c\x5e\x01\xf6\x01\xd6\xfa\xac\xb1\x4f\xf2\x58\xaa\x64\xe9\x65\x66\x7b\x80\x95\xa5\xd7\x5a\x0e\x7f\xf7\x74\x91\x15\x71\x5d\xc5\x06\x4d\x43\x08\xdf\x36\xee\x2d\x1f\x9a\xb4\x68\x50\x89\x84\x65\x73\x35\x1a\x1d\x0d\xef\xaa\x0f\x23\x31\x57\x35\x68\x4a\x25\x42\x64\x84\xf3\x72\x54\xc8\x7f\x62\xdc\xb6\x1e\x83\xe5\x12\x58\x0c\x1f\x70\xb8\x9b\xa5\x10\x34\x33\x6e\x20\x0a\x47\xa3\xe8\x36\xb6\x52\x74\xd2\x62\x0a\x99\x44\x95\x82\x49\xd1\xf2\xa0\xfb\xab\x33\x3a\x7c\xaa\x43\x2b\x49\x62\xf8\x24\x19\xfe\x1d\xc0\x1f\x4a\xb5\x4c\xd0\x6f\x20\x43\xc1\xdf\xdc\xbc\x81\x5c\x38\x07\x4b\x14\x34\xda\x66\x85\x52\x1b\x30\x36\x45\x12\x5e\xcd\x7a\x14\xd6\x06\x0a\x87\xd6\xc1\x7a\x61\x62\xa9\xe5\x16\x2f\xa7\x6e\x55\xfa\x5e\xbc\xce\x91\x2e\x57\x62\x03\xd2\x53\x59\x8f\x87\xaa\x47\x7a\xf5\xa1\x8b\xbf\x96\x19\x32\xf0\x7e\x98\x97\x53\x61\x33\xce\xf9\x35\x3d\x35\x23\x3c\x0e\x45\xcd\xd8\xde\x5e\x74\x35\x03\xb9\x2c\x3d\xcd\x68\xad\x17\xb2\x66\x48\xf2\x0a\x3f\x35\x83\xb1\xd6\x6a\xf3\x02\x23\xa8\x62\xe0\xa7\x9d\xf0\x64\x2d\x63\x7c\x86\xcf\xba\x15\x39\x3f\xf5\x22\x60\xc8\x8b\x1d\x32\xce\x2d\x6e\x28\x9b\x07\x1b\xd5\x4a\x53\x78\xf1\xf9\x16\x37\x5f\x0e\x57\xa2\x08\xc7\x1a\x5d\x55\x7a\xca\xb0\x08\x6b\x8f\x24\x83\x4a\x0b\x39\x1a\x9e\x81\xfc\xbe\xce\x50\x56\x4f\x90\xcf\x9f\x97\x7b\xd6\xd7\x3f\xcb\x2f\x65\x84\x57\x88\xdf\x59\xef\x36\x34\x8a\x31\x12\x68\x28\x28\xda\xf7\xed\x7f\x05\x00\x00\xff\xff\xfb\x65\x93\x4f\xfc\x22\x00\x00")
+var _call_tracerJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xd5\x5a\x6d\x73\xdb\x36\x12\xfe\x6c\xfd\x0a\xc4\x1f\x62\x69\xa2\xc8\x4a\xd2\xcb\xcd\xd8\x55\x6e\x54\x47\x49\x3c\xe3\xc6\x1e\xbf\x34\x93\xc9\xe4\x03\x44\x42\x12\x6a\x8a\x60\x09\xd0\x8a\xae\xf5\x7f\xbf\x67\x17\x20\x45\x51\xb2\xe3\xb4\xbd\xb9\x9c\xbf\x44\x04\x76\x17\x8b\xc5\xb3\x6f\x40\xf6\xf7\xc5\x91\xc9\x96\xb9\x9e\xce\x9c\x78\xde\x7f\xf6\x4f\x71\x39\x53\x62\x6a\x9e\xce\x30\x12\x19\x9d\x8a\x61\xe1\x66\x26\xb7\xad\xfd\x7d\x4c\x69\x2b\x26\x3a\x51\x02\xff\x66\x32\x77\xc2\x4c\x84\x6b\xd0\x27\x7a\x9c\xcb\x7c\xd9\x03\x83\xe7\xd9\x3a\x4d\x12\x26\xb9\x52\xc2\x9a\x89\x5b\xc8\x5c\x1d\x88\xa5\x29\x44\x24\x53\x91\xab\x58\x5b\x97\xeb\x71\xe1\xb0\x90\x13\x32\x8d\xf7\x4d\x2e\xe6\x26\xd6\x93\x25\x89\xc4\x58\x91\xc6\x2a\xe7\xa5\x9d\xca\xe7\xb6\xd4\xe3\xed\xfb\x2b\x71\xa2\xac\xc5\xdc\x5b\x95\xaa\x5c\x26\xe2\xac\x18\x27\x3a\x12\x27\x3a\x52\xa9\x55\x42\x42\x71\x1a\xb1\x33\x15\x8b\x31\x8b\x23\xc6\x37\xa4\xca\x45\x50\x45\xbc\x31\x90\x2f\x9d\x36\x69\x57\x28\x8d\xf9\x5c\xdc\xa8\xdc\xe2\x5b\xbc\x28\x97\x0a\x02\xbb\xc2\xe4\x24\xa4\x2d\x1d\x6d\x20\x17\x26\x23\xbe\x0e\xb4\x5e\x8a\x44\xba\x15\xeb\x03\x0c\xb2\xda\x77\x2c\x30\x43\xcb\xcc\x4c\x86\x3d\xce\x20\x1d\xbb\x5e\xe8\x24\x11\x63\x25\x0a\xab\x26\x45\xd2\x25\x69\x20\x16\x1f\x8e\x2f\xdf\x9d\x5e\x5d\x8a\xe1\xfb\x8f\xe2\xc3\xf0\xfc\x7c\xf8\xfe\xf2\xe3\x21\x88\x71\x6e\x98\x55\x37\xca\x8b\xd2\xf3\x2c\xd1\x90\x8c\x2d\xe6\x32\x75\x4b\xec\x84\x24\xfc\x3c\x3a\x3f\x7a\x07\x96\xe1\x4f\xc7\x27\xc7\x97\x1f\xb1\x1f\xf1\xe6\xf8\xf2\xfd\xe8\xe2\x42\xbc\x39\x3d\x17\x43\x71\x36\x3c\xbf\x3c\x3e\xba\x3a\x19\x9e\x8b\xb3\xab\xf3\xb3\xd3\x8b\x51\x4f\x5c\x28\xd2\x4a\x11\xff\xd7\x6d\x3e\xe1\xd3\x83\x5d\x63\xe5\xa4\x4e\x6c\x69\x89\x8f\x38\x70\x0b\x1d\x93\x58\xcc\xe4\x8d\xc2\xc1\x47\x4a\xdf\x40\x43\x29\x22\x60\xf2\xc1\x87\x4a\xb2\x64\x62\xd2\x29\xef\xf9\x4e\x40\x8a\xe3\x89\x48\x8d\xeb\x0a\x0b\xe5\x7f\x9c\x39\x97\x1d\xec\xef\x2f\x16\x8b\xde\x34\x2d\x7a\x26\x9f\xee\x27\x5e\x9c\xdd\x7f\xd5\x6b\x91\xcc\x48\x26\xc9\x65\x2e\x23\x2c\x8c\xc3\x91\x02\x36\x87\xf9\x13\xb3\x80\x3d\x61\x41\x2b\x23\x3a\x6a\xfa\x1d\x31\x18\x71\x48\xea\x0b\x7d\x39\x4b\xa0\xc5\x7e\x32\x93\xd3\xef\x24\x29\x71\xa6\x53\x20\x22\xc5\x0e\x48\xb6\x15\x73\x19\x2b\xa0\x10\xb2\x6b\x02\xbb\xf5\xcd\x10\x8c\xfc\x71\x83\x17\x86\x9c\x33\x2c\x7b\xad\xdf\x5b\x3b\x41\x43\xeb\x64\x74\x4d\x0a\x92\xfc\xa8\xc8\x73\x95\x3a\x32\x65\x01\xd4\xc1\xa8\x44\x22\x3c\x4d\xb0\xe7\xe8\x97\x9f\xa1\x27\x08\xbc\xa4\x9d\x4a\xc8\x81\xf8\xf4\xfb\xed\xe7\x6e\x8b\x45\xc7\xca\xc2\x1a\x31\x4e\x83\x76\x74\x6d\x85\x9e\x88\x85\xda\x83\xc4\x5f\x0b\xeb\x6a\xd3\x93\xdc\xcc\xa1\xa6\x00\xd6\xc8\x0a\x35\xc3\x60\xb3\x86\x65\x49\xfa\x8d\x93\x63\x65\xb0\x62\xc5\x7c\x20\x26\x32\x81\x13\xf9\x25\xad\x53\x19\x6d\x44\xa7\x37\xe6\x9a\x24\x03\x37\x40\x2f\x7c\xc3\x64\x91\x89\x83\x1f\xd0\x16\xaa\x1d\x28\x80\x69\x87\xf8\x20\xa9\x48\x79\xd9\x76\x62\xa6\x5d\x11\x8f\x3b\x02\x36\x22\xb1\x47\x32\x73\x05\xd0\x47\xa6\x54\x79\x8e\x58\x06\x57\x98\x23\xc8\xc0\x3b\x93\x25\x68\x6e\x64\xee\x27\xc4\x40\x80\xb9\x37\x55\x6e\x44\x9f\xed\xce\x21\x66\xb1\xef\xb6\x9f\x7d\x34\x18\x70\xe0\x99\xe8\x54\xc5\x5e\xfc\x8e\x43\x48\xec\x4d\x64\x91\xb8\x6a\x5d\x62\xda\xc9\x15\xd6\x4c\xe9\xe7\xad\xd7\xe2\x83\x12\x26\x4d\x96\x30\x01\xa9\x32\x26\xcf\xb4\x4b\x68\x3e\x0f\x9b\xb3\x5d\xd8\xc2\x92\x09\xd9\xd0\x22\xcb\xd5\xd3\x68\xa6\xe8\xd8\xd2\x48\x05\x2d\xc1\xc1\xe7\x39\x10\xb4\x5a\xcf\x64\x3d\x67\xde\x17\xf3\xb1\x82\xae\xe2\xb1\xe8\x7f\x99\xf4\x3b\x02\x5a\xd2\x8f\x52\xf7\xc0\x13\xf4\x25\x29\x26\x0b\x1b\x65\xfe\x0b\x84\x9c\x74\xea\xf7\x1a\x74\x85\xa3\x48\x91\xaa\x05\xdc\x30\x65\x3c\xd3\xa9\x8c\x15\xc8\x44\x94\x2b\x98\x2d\x06\x46\x63\x20\xc3\x78\xd0\x55\x10\x5b\x5f\x52\x3c\x7e\x2c\xda\xb4\xd8\x40\xec\x1d\x9d\x8f\x86\x97\xa3\x3d\xf1\xc7\x1f\xc2\x8f\xec\xfa\x91\xe7\xbb\x9d\x9a\x66\x3a\x3d\x9d\x4c\x82\x72\x2c\xb0\x97\x29\x75\xdd\x7e\xd6\xe9\xdd\xc8\xa4\x50\xa7\x13\xaf\x66\xa0\x1d\xc1\xc7\x06\x81\xe7\x49\x93\xe7\xf9\x1a\x0f\x31\x61\x63\x43\x44\x91\xf9\x38\x51\x9b\xbe\x18\x9c\x95\xfd\xd6\x3a\x0a\x56\x84\xbe\xc8\x20\x66\x2a\x42\x55\xb9\x6a\x30\x3f\x6b\xbc\xe3\x96\x19\xf2\x16\xfe\x4c\xd6\xe5\x01\xf2\x05\x1e\x70\xe6\x9d\xfa\xc2\x67\x54\x9a\x90\x50\x35\x8c\xe3\x1c\x81\xac\xdd\xe9\x78\x72\x9d\x66\x85\x3b\x58\x23\x9f\x2b\x44\xca\x65\xcf\x52\x2c\x6a\xf3\xd6\xba\x7e\xa7\x25\x8f\x9d\xc3\x37\x8e\x53\xe2\x0a\x58\xbd\xa0\x91\x76\x7d\xfa\xc8\x58\x88\x0d\xd3\xf4\x51\xce\xb2\x45\x88\x75\xaf\xff\x65\x6f\xd3\x66\xfd\xce\x0a\x0f\xcf\x5e\x76\x88\xe5\xf6\xb0\x42\x79\x15\x27\x7a\x59\x61\x67\x6d\x06\xd5\x6a\x76\x15\x10\x06\x08\x02\x85\xda\xea\x04\x0c\xac\x4d\x50\x59\x95\x4c\x28\xa2\x80\x2f\x62\x70\x4d\x25\x27\x5d\xf6\x77\x49\xa1\xd7\x16\x63\xb6\xbc\x33\x66\x13\x63\x01\x62\x17\xa3\x93\x37\xaf\x47\x17\x97\xe7\x57\x47\x97\x7b\x35\x50\x25\x6a\xe2\x48\xa9\xf5\x3d\x24\x2a\x9d\xba\x19\xeb\x4f\xe2\xd6\x67\x3f\x11\xcf\xd3\x67\x9f\xfd\x08\xa4\x6f\x3a\xfe\xce\xfd\x1c\xe2\xd3\x67\x96\x7d\xdb\xfa\x0a\xa9\x37\xe6\xdf\x83\x27\x67\x98\xb8\x24\x77\xa6\x24\xb8\xff\x9c\xff\x2b\xd0\x8a\xc7\x44\xf1\x93\x4c\x24\xc2\xd7\x3d\x9a\x6f\x22\xae\x1e\x40\xb7\xc4\xa4\xb9\x42\x5d\x13\x73\x92\x88\xa4\xcf\x33\x25\x8e\x62\x93\xaa\x6f\x8f\x4c\xc3\x93\x93\x5a\x5c\xe2\xef\xa3\xd3\xd7\xf5\x58\xb5\xf7\x7a\x74\x32\x7a\x8b\x68\xd5\xa4\xbd\xb8\x1c\xa2\x34\xe2\xd1\x32\x8c\x41\xd5\x8b\x6b\x9d\x71\xb6\xe1\x18\x8e\x10\xc2\x15\x73\xa5\x2f\x22\x3d\x76\x40\xb5\x68\x1e\x92\xe9\x04\x36\x2a\x93\x9c\x2d\x61\x8b\x2d\x00\xb4\x77\x1d\xe1\xb3\xc6\x11\x56\x40\xd6\xf6\x0c\xc9\xdf\x2f\x1a\x03\x02\xa5\x5e\x2b\x83\x7a\x4c\x72\x22\xe0\x60\xdb\x7e\xf8\x26\xc5\xbf\x44\x5f\x1c\x88\x67\x21\xa2\xde\x13\xb2\x9f\x03\x02\x10\xff\x27\x02\xf7\x8b\x2d\x9c\xdf\x67\xf8\xde\x70\xb7\xff\x55\x58\x47\x31\x01\x89\x07\xa2\x69\xca\x1f\x36\x4c\x59\xd1\x9f\xa8\x74\x93\xfe\x1f\x1b\xf4\xab\x14\x40\xd8\x02\x20\x1e\x6d\x00\xc5\x07\xe0\x47\x0d\x6f\x08\x26\xe6\x82\x8f\xa5\xc1\xea\xdb\x93\xce\xf3\x75\x24\xdf\x15\x35\xff\x52\xd2\xd9\x5a\xb8\x52\x79\xba\x5e\x9a\x76\x01\x23\x28\x82\x9a\x13\xdd\xd6\x9e\x65\x91\x54\xbd\x9b\x05\x05\xb1\x1e\x6a\x38\x2f\x31\x55\x8a\x43\x4c\xa8\xf6\xa9\x62\xe3\x2a\x98\x2a\xf6\xd0\xb7\x31\xd0\x24\x17\xe5\x00\xe3\x5c\x2e\xa9\x6f\x43\x89\x7a\xbd\x14\x7c\x96\x22\x5e\xa6\x72\xae\x23\xeb\x25\x72\xad\x9f\xab\xa9\xcc\x59\x70\xae\x7e\x2b\x90\x0e\xa9\x15\x02\xa0\xb1\x44\x01\x71\x4b\x31\xd5\xd4\xcb\x79\xfe\xf6\xf3\x17\xfd\x3e\xb0\xae\x33\xec\xa6\x2b\x5e\xbe\xd8\x7f\xf9\x83\xc8\x8b\x44\x75\x7a\xad\x5a\x4a\xab\xb6\x1b\x4e\x84\x26\x02\x82\x5e\xab\xcc\xcd\x50\x37\xbe\xba\x23\x37\xde\x91\xe8\xb6\xd2\x8a\xa7\x02\x09\xcd\x6b\x36\x68\x20\xd8\x9f\xa8\x50\x28\xf4\x83\x44\xea\x82\x4f\x5f\x9f\xb6\xaf\x25\x9a\x39\x39\x56\x9d\x03\xee\x8a\xd9\x66\x0b\x19\xda\x22\x3a\x1c\x91\x25\x12\x06\x95\x51\x84\x8e\xdc\xd1\x01\x94\x1d\x0e\xac\x81\x68\xbf\xe7\x4a\x79\xdc\x40\x82\x0e\xfe\x59\x06\x7f\x3e\x3d\xaf\x92\x9c\x13\x3f\x4e\xda\xea\x58\xd5\xce\x87\xa2\x85\xe1\x50\x1d\x28\xa8\xc3\x2e\x45\xce\xe1\x61\x09\x9f\xdb\x22\xa7\x7e\xcc\x6a\x80\x80\xda\xf0\x58\x91\xcd\x2d\x0a\x73\x68\x98\x18\xbe\x05\x61\x9f\x47\x44\x9f\xda\x9e\x8f\xff\x7e\x61\x8a\x42\xa9\x59\xf4\xd6\x41\x5d\x87\x2d\x37\x40\x8d\x12\x29\x05\xb2\x34\x8e\x96\xea\x6d\xd2\x13\x09\xce\xa3\x1a\x23\x5d\x91\xc1\xdd\x28\x72\x7f\x2d\xc1\x85\xf0\x7d\x3e\xfa\x65\x74\x5e\x15\x44\x0f\x3f\xcc\xb2\x23\xda\xad\x7a\x45\x28\x81\x6e\x0c\xa8\xdc\xdd\xd2\xe2\x6c\x01\xd6\xe0\x0e\x60\x91\xfc\x55\xb6\x3c\xab\x6d\x27\x41\x07\xb4\x3a\x1a\x88\xe2\xd1\xba\x02\x16\x9d\x96\x6d\x44\xf3\x66\xa0\x30\x59\x99\x33\x48\x29\x0e\x41\x14\xea\x9b\x7d\xc8\xda\xc4\xaa\x1d\x59\x61\xf4\xb8\x66\xe3\x05\x97\xa1\x9e\xa8\x16\x26\x78\xbe\xac\x67\xa5\xcf\x0f\xac\x3b\x42\x2c\x01\x82\x32\xfa\x2a\x10\x32\x26\xae\x2c\x9f\x7b\x08\x86\x63\x3d\x3d\x4e\x5d\x7b\x35\x7d\x9c\xc2\x3c\xab\x4f\x0a\xf3\x18\x58\xf7\xa8\x2d\x11\x13\x3d\x35\x32\x9d\x12\x75\x41\x87\x62\x63\x90\xc4\x79\xd3\xb0\x01\xb1\x8f\xcd\xd4\xdd\x0f\x12\xc9\x78\x8f\x40\xd1\x43\x30\x02\x48\x31\x5e\xda\xc6\xef\x06\x6e\x46\x7f\x83\x8d\x6a\x93\x78\xd6\xeb\xcb\xc3\x1a\x5b\xb0\x4c\xc9\xe6\xeb\xc4\x23\xd8\xe9\x5e\x09\x41\x44\x08\x23\xd5\xb9\x06\x90\x6e\xab\xcf\x77\xea\x04\x62\xb7\x2a\x17\x26\x52\x27\x45\xae\x76\x0f\xc5\x96\x30\x64\x8b\x7c\x22\x23\x3e\x57\xba\xb8\xa2\xbe\xde\x22\x44\xcc\xd5\xcc\x2c\xbc\x02\xdb\x82\xd9\x26\x50\x2a\x4c\x34\xd2\x0a\x91\xf9\xc8\x50\x58\x39\x55\x35\xa8\x54\x26\x5f\x1d\xd6\xd6\x0b\x87\xbf\x08\xa5\x27\xb5\x81\x07\xe1\xea\xf6\xef\x01\x4b\xe3\xd4\x37\x6a\xa2\x92\x88\x2b\xa3\xda\x47\xa9\xb0\x2f\x59\xbe\x2f\x18\x7c\x93\xcf\x35\xe9\xfd\xf6\xd6\xc9\xfd\x26\x57\x15\xd0\x43\xe0\x50\x9b\xbf\x1b\x09\x77\x95\x58\x84\xdd\xf4\x57\x15\xb9\x15\x7e\xb9\x2a\xa2\x2f\x34\x2f\x37\xda\x14\x94\xe6\xd4\xff\x53\x2b\x5d\x95\x88\xa0\xbf\x0d\x37\x8b\x7c\x82\xf5\xab\xc5\xc5\x2c\x5c\x8a\xfb\xda\xaa\x96\x62\x0c\xe7\xdf\x70\xe1\x38\xf1\xd7\xd5\x3b\xcc\x7f\xcf\x15\x63\x08\x00\xce\x64\x54\x34\x84\x0c\x96\xe4\x4a\xc6\xcb\x2a\x69\x76\x7d\xc1\x82\x4a\x25\x8d\x43\x0b\x83\x84\xa1\x49\x1e\xc3\x91\x34\x94\x53\x94\x3b\xad\xad\x66\xfc\x6a\xa6\xde\x86\x8e\x8d\x5a\xb8\x9e\x6c\x43\xeb\x49\x7d\x22\x6b\xdc\x7a\x40\x52\x6d\xb8\x53\xf3\xb6\x34\x5c\xb8\xa2\xc7\x2d\xe6\x5c\x39\x0b\x79\x83\x05\x24\xf5\x6c\xa1\x12\x43\xc0\x8b\x12\x05\x13\xf3\x03\x09\x8e\xcf\xd0\xfb\x48\xeb\x41\x60\xff\xb3\x58\xdf\x88\x98\xab\x81\x60\x9a\x6f\xf3\xe4\x87\xfa\xb1\x37\xc7\x9b\x44\x3a\x17\xe0\x56\x33\xb7\xf7\x34\xed\xf8\x25\x0d\x15\x6d\xeb\x61\x2e\xc6\x75\x16\xd1\xbc\x12\xfd\x5a\x4d\xff\xbd\x38\xdd\x26\xe4\x4e\xaa\x9a\x2e\x6c\xde\x19\xd3\xc5\x36\x25\x77\x59\xe5\x3b\x57\x59\xc3\xde\xd7\xf4\x95\xde\xec\xab\xc0\x0d\x77\xe6\xfb\x41\x88\x0a\xf7\x28\xbe\x25\x18\x2b\xcc\x68\xc4\x7c\xba\xb5\x16\x84\xb5\xf0\x34\x43\x5a\x5a\x16\xc7\xe7\xa2\xc9\x09\x83\xe0\xf0\x4e\x42\x09\x1c\x28\x82\xfb\xfb\xf1\x9a\xff\x47\xee\xcb\xca\xff\x7d\x6e\x64\xce\x70\xb3\x50\x5d\x2c\x80\x8e\x2b\x4c\x6e\xbb\x1b\xb7\x0b\x34\x47\x43\xbe\x27\x6f\xdc\x25\x30\x63\xb8\x4f\x68\xde\xac\xd1\x1c\x8f\xad\x01\x9d\x49\x19\xa5\x5e\x50\xc3\x3d\xc0\xb3\xc5\x3b\x56\x4c\xe4\x18\x07\x77\x31\xd1\xe4\x16\xc6\xc6\x3d\x07\x91\xf3\x90\x9f\xf5\x19\xff\xa0\x3e\xeb\x87\xc2\x86\xf5\xbc\x66\x23\x7c\xd0\xe8\xed\xe1\xf6\xe0\xd7\x2f\x71\xb9\x3d\xc8\x91\xed\x2b\xe0\xde\xc1\x5a\xef\x53\x36\x49\xee\x0b\xa1\x2c\xbd\x8c\x78\x77\xb0\xb2\xf4\x5a\x51\x82\x3d\x3d\x58\x64\x45\x5c\x57\x71\x8d\x66\x4d\x08\xdf\x5d\x6e\x4c\x6f\xeb\xd2\xa8\xc9\x09\x84\x65\xf9\x35\x18\xec\xf6\xbf\x54\x4f\x2e\x21\x66\xad\xd1\x94\x4a\x78\x0f\xf1\xfb\x65\xef\xd0\xff\x56\x61\xd9\xba\x2f\x96\x53\xf4\xe2\xc8\x4f\x43\x5c\xfd\x92\x2b\x9a\x31\x17\x16\x85\xa5\x36\x76\xe5\x63\xf0\x4c\x9d\xd3\xe3\x9e\x56\x09\x1c\x92\x9e\xf1\xa9\x49\xfe\xd5\xd2\x25\x1d\x3d\x02\xaa\x5c\x93\x44\xff\xce\xe9\xff\xcb\x01\xbf\xbe\xa6\x28\x13\xdd\x52\x4c\xb0\x08\xbd\xe6\x21\x76\x66\x12\xcd\xd6\x1c\xb9\x04\x2b\xd0\xdb\xec\x52\x98\x1c\xf2\x54\xbc\xea\x13\xc9\xbd\x0d\x3d\xa0\xe6\x16\x41\xc2\x84\x14\xcc\x45\x60\x46\xf5\xac\x76\xdd\x70\x2d\xa4\x6d\x96\xc8\x25\x06\x28\xdd\x87\x4d\xd5\x3d\xbe\x7a\x42\xe3\x77\x38\x43\x06\xde\x74\xf7\xb2\xa3\x5c\xf7\x77\x1e\xa6\xaf\x75\x4f\x0f\x4d\xd4\xba\x8f\xaf\x2e\xcc\x9a\x0e\xbd\x4a\x43\x4d\xaf\x5d\x4f\x6f\xeb\xae\xc9\x73\xfc\xb5\xee\x94\xb5\xa2\x9c\x27\x18\x49\x15\x03\x7f\x35\xdc\x94\xb5\x0d\x7e\xea\xdf\x8c\x2b\x72\xfe\xea\x06\xe0\xd0\x69\xb6\xc9\x48\xd7\x6a\x49\xd1\xdd\xdb\xaa\x96\xaa\xfc\xc0\x27\x4c\x7f\xde\x9e\x99\x02\x2c\x6b\x74\x55\x2a\x2a\xdd\xc3\xcf\xdd\x13\x14\x2a\x2d\xf4\xa0\x7f\x28\xf4\x8f\x75\x86\x32\x9b\x0a\xfd\xe4\x49\xb9\x66\x7d\xfe\x93\xfe\x5c\x7a\x7a\x85\xfc\xc6\x7c\x67\x4d\xa3\xe0\x2b\x9e\x86\x9c\xa3\x75\xdb\xfa\x0f\xdd\x7f\xaf\x8f\x59\x23\x00\x00")
 
 func call_tracerJsBytes() ([]byte, error) {
 	return bindataRead(
@@ -133,11 +133,11 @@ func call_tracerJs() (*asset, error) {
 	}
 
 	info := bindataFileInfo{name: "call_tracer.js", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
-	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x46, 0x79, 0xb6, 0xbc, 0xd2, 0xc, 0x25, 0xb1, 0x22, 0x56, 0xef, 0x77, 0xb9, 0x5e, 0x2e, 0xf4, 0xda, 0xb2, 0x2f, 0x53, 0xa4, 0xff, 0xc8, 0xac, 0xbb, 0x75, 0x22, 0x46, 0x59, 0xe3, 0x1d, 0x7d}}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xb1, 0x40, 0xd8, 0x96, 0xd3, 0x4e, 0xf8, 0xb1, 0x20, 0xab, 0x27, 0x38, 0x4, 0xf7, 0xb4, 0x35, 0x18, 0xa1, 0x86, 0xc4, 0x2b, 0x89, 0x5a, 0x30, 0xf1, 0x62, 0x13, 0x33, 0xa9, 0xa2, 0x20, 0x1e}}
 	return a, nil
 }
 
-var _evmdis_tracerJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xac\x57\xdf\x6f\xda\xca\x12\x7e\x86\xbf\x62\x94\x27\x50\x29\x60\x63\x08\x38\x27\x47\xe2\xa6\xf4\x1c\xae\xd2\x24\x02\x72\x8f\x2a\x94\x87\x05\xc6\xb0\xaa\xf1\x5a\xbb\x6b\x72\xb8\x55\xfe\xf7\xab\xd9\x59\x03\xf9\x75\xdb\x4a\xa7\x0f\x3b\xb5\x77\xbe\x6f\xbe\x9d\x19\xcf\x92\x56\x0b\xae\x54\xbe\xd7\x72\xbd\xb1\x10\xb6\x83\x73\x98\x6d\x10\xd6\xea\x23\xda\x0d\x6a\x2c\xb6\x30\x2c\xec\x46\x69\x53\x6d\xb5\x60\xb6\x91\x06\x12\x99\x22\x48\x03\xb9\xd0\x16\x54\x02\xf6\x85\x7f\x2a\x17\x5a\xe8\x7d\xb3\xda\x6a\x31\xe6\xcd\x6d\x62\x48\x34\x22\x18\x95\xd8\x47\xa1\x31\x86\xbd\x2a\x60\x29\x32\xd0\xb8\x92\xc6\x6a\xb9\x28\x2c\x82\xb4\x20\xb2\x55\x4b\x69\xd8\xaa\x95\x4c\xf6\x44\x29\x2d\x14\xd9\x0a\xb5\x0b\x6d\x51\x6f\x4d\xa9\xe3\x8f\x9b\x7b\xb8\x46\x63\x50\xc3\x1f\x98\xa1\x16\x29\xdc\x15\x8b\x54\x2e\xe1\x5a\x2e\x31\x33\x08\xc2\x40\x4e\x6f\xcc\x06\x57\xb0\x70\x74\x04\xfc\x4c\x52\xa6\x5e\x0a\x7c\x56\x45\xb6\x12\x56\xaa\xac\x01\x28\x49\x39\xec\x50\x1b\xa9\x32\xe8\x94\xa1\x3c\x61\x03\x94\x26\x92\x9a\xb0\x74\x00\x0d\x2a\x27\x5c\x1d\x44\xb6\x87\x54\xd8\x23\xf4\x27\x12\x72\x3c\xf7\x0a\x64\xe6\xc2\x6c\x54\x8e\x60\x37\xc2\xd2\xa9\x1f\x65\x9a\xc2\x02\xa1\x30\x98\x14\x69\x83\xd8\x16\x85\x85\xbf\xc6\xb3\x3f\x6f\xef\x67\x30\xbc\xf9\x0a\x7f\x0d\x27\x93\xe1\xcd\xec\xeb\x05\x3c\x4a\xbb\x51\x85\x05\xdc\x21\x53\xc9\x6d\x9e\x4a\x5c\xc1\xa3\xd0\x5a\x64\x76\x0f\x2a\x21\x86\x2f\xa3\xc9\xd5\x9f\xc3\x9b\xd9\xf0\x5f\xe3\xeb\xf1\xec\x2b\x28\x0d\x9f\xc7\xb3\x9b\xd1\x74\x0a\x9f\x6f\x27\x30\x84\xbb\xe1\x64\x36\xbe\xba\xbf\x1e\x4e\xe0\xee\x7e\x72\x77\x3b\x1d\x35\x61\x8a\xa4\x0a\x09\xff\xe3\x9c\x27\xae\x7a\x1a\x61\x85\x56\xc8\xd4\x94\x99\xf8\xaa\x0a\x30\x1b\x55\xa4\x2b\xd8\x88\x1d\x82\xc6\x25\xca\x1d\xae\x40\xc0\x52\xe5\xfb\x9f\x2e\x2a\x71\x89\x54\x65\x6b\x77\xe6\x77\x1b\x12\xc6\x09\x64\xca\x36\xc0\x20\xc2\x6f\x1b\x6b\xf3\xb8\xd5\x7a\x7c\x7c\x6c\xae\xb3\xa2\xa9\xf4\xba\x95\x32\x9d\x69\xfd\xde\xac\x12\x27\xee\xb6\x2b\x69\x66\x5a\x2c\x51\x83\x46\x5b\xe8\xcc\x80\x29\x92\x44\x2e\x25\x66\x16\x64\x96\x28\xbd\x75\x7d\x02\x89\x56\x5b\x10\x60\xc9\x19\xac\x82\x1c\x35\x6d\x7a\x8e\x8f\xc6\xee\x53\xa7\x73\x25\x8d\x30\x06\xb7\x8b\x74\xdf\xac\x7e\xaf\x56\x8c\x15\xcb\x6f\x31\xcc\xbf\xab\xdc\xc4\x30\x7f\x78\x7a\x68\x54\xab\x95\x2c\x2f\xcc\x06\x4d\x0c\xdf\xdb\x31\xb4\x1b\x10\xc4\x10\x34\x20\x74\x6b\xc7\xad\x91\x5b\xbb\x6e\xed\xb9\xf5\xdc\xad\x7d\xb7\x0e\xdc\x1a\xb4\xd9\x30\x3a\x60\xb7\x80\xfd\x02\x76\x0c\xd8\x33\x64\xcf\xd0\xc7\xe1\x40\x21\x47\x0a\x39\x54\xc8\xb1\x42\x66\xe9\xb0\x4b\xc4\x2c\x11\xb3\x74\x99\xa5\xcb\x2c\x5d\x76\xe9\x32\x4b\xd7\x0b\xee\xba\xf3\x74\x99\xa5\x7b\xce\x4f\xcc\xd2\x65\x96\x1e\x1f\xb9\xc7\x80\x9e\x3f\x22\x03\x7a\x2c\xbe\xc7\x80\x1e\x03\xfa\x0c\xe8\x73\xd8\x7e\xc8\x4f\x1d\x36\xcc\xd2\xe7\xb0\xfd\x1e\x1b\x0e\xdb\x67\x96\x3e\xb3\x0c\x58\xfc\x20\x70\x7b\x03\x8e\x37\xe0\x78\x03\x9f\xd5\x32\xad\x3e\xaf\x6d\x9f\xd8\x76\xe8\x6d\xc7\xdb\xc8\xdb\xae\xb7\x3e\xf3\x6d\x9f\xfa\xb6\xcf\x7d\xdb\xf3\x1d\xea\xe4\xf9\x02\xcf\x17\x78\xbe\xc0\xf3\x05\x9e\xaf\xac\x64\x59\xca\xb2\x96\xbe\x98\x81\xaf\x66\xe0\xcb\x19\xf8\x7a\x06\xbe\xa0\x81\xaf\x68\xe0\x4b\x1a\xf8\x9a\x06\xa1\xe7\x0b\xfb\x31\x84\x64\x07\x31\x74\x1a\x10\x74\xda\x31\x44\x64\x83\x18\xba\x64\xc3\x18\x7a\x64\x3b\x31\x9c\x93\x8d\x62\xe8\x93\xed\xc6\x30\x20\x4b\x7c\xd4\xb5\x1d\x22\x24\xc6\x0e\x29\x24\xca\x0e\x49\x24\xce\x88\x34\x12\x69\x44\x22\x89\x35\x22\x95\x44\x1b\x91\x4c\xe2\x8d\x22\xd6\x11\x75\x59\x47\xd4\x63\x1d\xd1\x39\xeb\xa0\xee\x73\x80\x01\xeb\xa0\xfe\x23\x1d\xd4\x80\xa4\xc3\x75\x20\xe9\x70\x3d\x48\x3a\x5c\x17\x12\x25\xf5\xa1\xd3\xe1\x3a\x91\x48\xa9\x17\x9d\x0e\xd7\x8d\x44\xeb\xfa\x91\x78\x7d\x47\x06\xbd\xc0\xdb\xd0\xdb\x8e\xb7\x91\xb3\x61\xe4\xbf\xa2\xc8\x7f\x46\x91\xff\x8e\xa2\x8e\xdf\xf7\x7e\xee\x23\x78\xa2\xef\xbc\xd5\x02\x8d\xa6\x48\x2d\x4d\x7f\x99\xed\xd4\x37\x9a\xcf\x1b\xcc\x40\xa4\xa9\x1b\x64\x2a\x5f\xaa\x15\x1a\x1e\x90\x0b\xc4\x0c\xa4\x45\x2d\xe8\x86\x50\x3b\xd4\x74\x39\x96\xa3\xc9\xd1\x11\x26\x91\x99\x48\x4b\x62\x3f\x44\x69\x30\xc9\x6c\xdd\xac\x56\xf8\x7d\x0c\x49\x91\x2d\x69\x74\xd5\xea\xf0\xdd\x53\x80\xdd\x48\xd3\x74\x23\x69\xde\x7e\x68\xaa\xdc\x5c\x40\xa9\x33\x11\x6f\xc9\x24\x6a\xb1\xb4\x85\x48\x01\xff\xc6\x65\xe1\x66\xa1\x4a\x40\x64\x5e\x39\x24\x3c\xf1\x2b\x0e\x7f\x12\x35\x55\xeb\x06\xac\x16\x14\xbc\x0c\x61\x2c\xe6\xa7\x11\xe8\xde\xc0\x1d\xea\x7d\xc9\xe5\xee\x41\x0a\xf9\x9f\x2f\x3e\x1c\x12\x35\xe1\xde\x64\xae\x56\x2a\x3b\xa1\x21\xd1\x62\x8b\x70\x79\x7a\xba\xe3\x7f\x9b\x29\x66\x6b\xbb\x81\x8f\x10\x3c\x5c\x54\x3d\x02\xb5\x56\x1a\x2e\x21\x55\xeb\xe6\x1a\xed\x88\x1e\x6b\xf5\x8b\x6a\xa5\x22\x13\xa8\xb9\x5d\xa6\xaf\x38\xee\xf9\x99\x7b\x75\xf6\x00\x97\x0c\x25\xcf\x27\xc0\xd4\x20\x10\xc0\xd3\x7c\xc2\xdc\x6e\x6a\x75\xb8\x3c\x95\xe2\xe3\x7b\x3a\x95\xd3\xa5\x02\x97\xfc\x54\x51\x79\x0c\xf4\x8f\x08\x54\xde\xb4\xea\xa6\xd8\x2e\x50\xd7\xea\x0d\xb7\xbd\x22\x42\x88\xe1\x39\x3f\xef\x95\x65\x9e\x3f\xb8\xe7\x27\x92\xe4\xd4\x3b\xc5\x54\xdb\xf2\xe4\xbf\x43\xdb\x47\x77\x67\xcf\x35\xee\x54\x0e\x97\x70\x70\x9c\xbf\x82\x70\xb2\x08\x91\x28\x5d\x23\x94\x84\x4b\x68\x5f\x80\x84\xdf\xf8\x6c\xfe\x06\x9b\x33\x5b\x53\xe5\x0f\x17\x20\x3f\x7c\xa8\x3b\x50\xc5\xbf\x65\x8d\x4d\x72\x75\x39\xe2\x84\xe4\x88\xdf\x6a\xb2\xde\xb4\x6a\x6a\xb5\xcc\xd6\xb5\xa0\x57\x77\xb9\xaf\x3c\xd1\x62\x1e\xa5\x5d\xb2\xbf\x4b\x89\x77\xaa\xfb\x33\x2c\x85\x41\x38\xbb\x1a\x5e\x5f\x9f\xc5\x70\x7c\xb8\xba\xfd\x34\x3a\x8b\x0f\x87\x94\x99\xb1\xf4\xfb\x95\x4b\x7c\x12\xb7\x53\x6f\xee\x44\x5a\xe0\x6d\xc2\xf5\x3e\xb8\xcb\xff\xe2\x6b\xef\xe8\x95\x37\x17\x70\x7e\xb6\x16\xc6\xb5\xc3\x0b\x40\xfb\x5d\x80\x55\x6f\xf9\x07\xcf\xd3\xf0\x1c\xe2\x98\xde\x42\x85\x27\xa8\x17\x18\x99\xe5\x85\x3d\x60\xb6\xb8\x55\x7a\xdf\x34\xf4\xcb\xa7\xe6\x73\xd2\x38\x24\xe7\x83\x3f\xf7\x0b\x8a\x63\xaf\x67\x45\x9a\x3e\xdf\xe3\x39\xf2\xce\xa6\xca\x39\x27\x73\xdf\x3b\x27\x1f\x81\x6b\x01\xf6\xf3\xd1\x16\x1a\xc5\xb7\x8b\x63\x45\x3f\x8d\xae\x47\x7f\x0c\x67\xa3\x67\x95\x9d\xce\x86\xb3\xf1\x15\xbf\xfa\x71\x6d\xc3\x5f\xaa\xed\xeb\x4e\x38\x9e\xc3\x1d\x03\x5e\xb5\xe0\xdb\x2d\xf0\xcb\x3d\xf0\x4b\x4d\x70\x2c\xe8\x3f\x51\xd1\xff\x5f\xd2\x7f\xba\xa6\x93\xd1\xec\x7e\x72\x73\x52\x3a\xfa\x7b\xe5\x27\xbe\x19\xef\xfa\x76\xdd\x82\x57\xee\x3c\xbe\xfc\x15\xf7\x46\xe3\xab\xc2\x36\x5c\xe8\x0f\x25\xeb\x3b\x7a\xa7\xb3\xdb\xbb\x63\xef\xdd\x8f\xaf\xc6\x87\xa1\xf2\xa3\x18\xed\x06\xb4\xdf\x61\xfd\xf7\xfd\x97\xbb\x4f\xa3\xe9\xcc\x33\x95\x99\xcd\x97\x87\xcf\x74\x8d\xf6\xee\xaa\x76\x32\x03\x65\x52\xce\x3f\x69\xee\x28\xcd\xe5\xf4\x3b\xa0\x53\xcc\x0e\xf0\x67\x37\x07\x7c\x84\xf6\xdf\x5d\x3c\x72\x1d\x87\xfb\xcb\x82\xf9\x1b\xcc\x11\x1f\xeb\xfa\xec\x22\x3d\x9e\xee\xf9\x1d\xc4\xf8\x6a\xe5\xa9\xfa\x54\xfd\x5f\x00\x00\x00\xff\xff\xdf\x2f\xd9\xfa\x63\x10\x00\x00")
+var _evmdis_tracerJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xad\x57\x5b\x6f\xda\x58\x10\x7e\x86\x5f\x31\xca\x13\x28\x14\xb0\x31\x04\x48\x5b\x89\x4d\x48\xcb\x8a\x26\x08\xc8\x56\x11\xca\x83\x31\xc7\x70\x14\xe3\x63\xf9\x42\xca\x46\xf9\xef\x3b\x73\xe6\x98\x4b\x2e\xdb\x56\x6a\x14\x79\x62\x9f\x99\x6f\xbe\xb9\x78\xc6\xa9\xd5\xe0\x42\x45\xdb\x58\x2e\x57\x29\xd8\x75\xeb\x0c\xa6\x2b\x01\x4b\xf5\x61\x85\x4f\x3c\x25\x43\xe8\x65\xe9\x4a\xc5\x49\xb1\x56\xc3\x23\x99\x80\x2f\x03\x01\x28\x23\x37\x4e\x41\xf9\x90\xbe\xd0\x0f\xe4\x3c\x76\xe3\x6d\x15\x0d\xd8\xe6\xcd\x63\x42\xf0\x63\x21\x20\x51\x7e\xfa\xe8\xc6\xa2\x0b\x5b\x95\x81\xe7\x86\x10\x8b\x85\x4c\xd2\x58\xce\xb3\x14\x1d\xa5\xe0\x86\x8b\x9a\x8a\x61\xad\x16\xd2\xdf\x12\x24\x3e\xcb\xc2\x85\x88\xb5\xeb\x54\xc4\xeb\x24\xe7\xf1\xe5\xfa\x16\x86\x22\x49\xf0\xec\x8b\x08\x45\xec\x06\x30\xca\xe6\x81\xf4\x60\x28\x3d\x11\x26\x02\x5c\x24\x4e\x4f\x92\x95\x58\xc0\x5c\xc3\x91\xe1\x15\x51\x99\x18\x2a\x70\xa5\x10\xdf\x4d\xa5\x0a\x2b\x20\x24\x9e\xc7\xb0\x11\x71\x82\xf7\xd0\xc8\x5d\x19\xc0\x0a\xa8\x98\x40\x4a\x6e\x4a\x01\xc4\xa0\x22\xb2\x2b\x23\xeb\x2d\x04\x6e\xba\x37\xfd\x85\x84\xec\xe3\x5e\x00\x9e\x90\x9b\x95\x8a\x30\xc6\x15\xa2\x63\xd4\x8f\x32\x08\x60\x2e\x20\x4b\x84\x9f\x05\x15\x42\x43\x65\xf8\x3e\x98\x7e\xbd\xb9\x9d\x42\xef\xfa\x0e\xbe\xf7\xc6\xe3\xde\xf5\xf4\xee\x1c\x95\xb1\x6e\x78\x2a\x36\x82\xa1\xe4\x3a\x0a\x24\x22\x63\x88\xb1\x1b\xa6\x5b\x8c\x84\x10\xbe\xf5\xc7\x17\x5f\xd1\xa4\xf7\xd7\x60\x38\x98\xde\x61\x3c\x70\x35\x98\x5e\xf7\x27\x13\xb8\xba\x19\x43\x0f\x46\xbd\xf1\x74\x70\x71\x3b\xec\x8d\x61\x74\x3b\x1e\xdd\x4c\xfa\x55\x98\x08\x62\x25\xc8\xfe\xe7\x39\xf7\x75\xf5\x30\xaf\x0b\x91\xba\x32\x48\xf2\x4c\xdc\x61\xc1\x13\xe4\x18\x2c\x60\xe5\x6e\x04\x16\xde\x13\x72\x83\x0c\x5d\xf0\xb0\x27\x7f\xb9\xa8\x84\xe5\x06\x2a\x5c\xea\x98\xdf\x6d\x48\x18\xf8\x10\xaa\xb4\x02\x09\x92\xff\xb8\x4a\xd3\xa8\x5b\xab\x3d\x3e\x3e\x56\x97\x61\x56\x55\xf1\xb2\x16\x30\x5c\x52\xfb\x5c\x2d\x12\xa6\xd8\xac\xb1\x22\xd3\xd8\xf5\xd0\x75\x2c\xd2\x2c\x0e\x13\x48\x32\xdf\x97\x9e\x14\x21\x56\x24\xc4\xc8\xd6\xba\x4f\xb0\x97\xd5\x1a\x79\xa7\xa4\x0c\xa9\x82\x48\xc4\x74\x68\x30\x3e\x24\xe9\x36\xd0\x3c\xf1\xc6\xc5\x50\xd6\xf3\x00\xdf\x90\xa7\x62\x21\x49\x5d\xef\xa1\x0b\xb3\x27\x15\x25\x28\xee\x9f\xef\x2b\xc5\x62\x21\x8c\x32\x6c\x50\x7c\xf0\x54\xef\x42\xbd\x02\x56\x17\xac\x0a\xd8\xfa\xda\xd0\x57\x47\x5f\x9b\xfa\xda\xd2\xd7\x33\x7d\x6d\xeb\x6b\x47\x5f\xad\x3a\x0b\xb6\xb6\x58\xcd\x62\x3d\x8b\x15\x2d\xd6\xb4\x59\xd3\x36\x7e\xd8\x91\xcd\x9e\x6c\x76\x65\xb3\x2f\x9b\x51\x1a\xac\xe2\x30\x8a\xc3\x28\x4d\x46\x69\x32\x4a\x93\x55\x9a\x8c\xd2\x34\x84\x9b\x3a\x9e\x26\xa3\x34\xcf\xf8\x8e\x51\x9a\x8c\xd2\xe2\x90\x5b\x6c\xd0\x32\x21\xb2\x41\x8b\xc9\xb7\xd8\xa0\xc5\x06\x6d\x36\x68\xb3\xdb\xb6\xcd\x77\x0d\x16\x8c\xd2\x66\xb7\xed\x16\x0b\x76\xdb\x66\x94\x36\xa3\x74\x98\x7c\xc7\xd2\x67\x1d\xf6\xd7\x61\x7f\x1d\x93\xd5\x3c\xad\x26\xaf\x75\x93\xd8\xba\x6d\x64\xc3\x48\xc7\xc8\xa6\x91\x26\xf3\x75\x93\xfa\xba\xc9\x7d\xdd\xe0\xed\xea\x64\xf0\x2c\x83\x67\x19\x3c\xcb\xe0\x59\x06\x2f\xaf\x64\x5e\xca\xbc\x96\xa6\x98\x96\xa9\xa6\x65\xca\x69\x99\x7a\x5a\xa6\xa0\x96\xa9\xa8\x65\x4a\x6a\x99\x9a\x5a\xb6\xc1\xb3\x11\xcf\x26\x89\x78\x0d\x94\x0d\xc4\x73\x48\x22\x5e\x93\x24\xe2\xb5\x48\x22\xde\x19\x49\xc4\x6b\x93\x44\xbc\x0e\x49\xc2\xa3\xae\x6d\x10\x20\x21\x36\x88\x21\x41\x36\x88\x22\x61\x3a\xc4\x91\x40\x1d\x22\x49\xa8\x0e\xb1\x24\x58\x87\x68\x12\xae\xe3\x30\x0f\xa7\xc9\x3c\x9c\x16\xf3\x70\xce\x98\x07\x75\x9f\x36\xe8\x30\x0f\xea\x3f\xe2\x41\x0d\x48\x3c\x74\x07\x12\x0f\xdd\x83\xc4\x43\x77\x21\x41\x52\x1f\x6a\x1e\xba\x13\x09\x94\x7a\x51\xf3\xd0\xdd\x48\xb0\xba\x1f\x09\xd7\x74\xa4\xd5\xb2\x8c\xb4\x8d\x6c\x18\xe9\x68\x69\x3b\xe6\x2d\x72\xcc\x6b\xe4\x98\xf7\xc8\x69\x98\x73\xa3\xa7\x5f\x82\x67\x7a\xcf\x71\x20\xc4\x22\xc9\x82\x94\xa6\xbf\x0c\x37\xea\x81\xe6\xf3\x0a\x07\xb6\x8b\x93\x9e\x06\x99\x8a\x3c\xb5\x10\x09\x0f\xc8\xb9\xc0\x13\x89\x3b\xc5\xa5\x0d\xa1\x70\xb1\xd0\x72\xcc\x47\x93\x86\x23\x1b\x5f\x86\x38\x23\x0d\xb0\x19\xa2\x34\x98\x64\xb8\xac\x16\x0b\xfc\xbc\x0b\x7e\x16\x7a\x34\xba\x4a\x65\x78\x32\x10\xa8\x29\x93\xaa\x1e\x49\xb3\xfa\x7d\x15\x47\xd2\x39\xe4\x3c\x7d\xf7\x2d\x9a\x04\xed\x7a\x69\x86\xfe\xc4\x0f\xe1\x65\x7a\x16\xa2\x4b\x5c\xe3\xcc\x1c\xed\xf4\xc4\x2f\x68\xfb\x03\xaf\x81\x5a\x56\x60\x31\x27\xe7\xb9\x8b\x24\x15\xd1\xa1\x07\xda\x1b\xb8\xbd\x70\x37\x1a\x2c\xbd\x07\xc9\xe5\x3f\xdf\x8c\x3b\x41\xd0\x64\xf7\x26\x72\xb1\x50\xd8\xb8\x31\xce\x66\x77\x2d\xe0\xd3\x61\x74\xfb\x3f\xab\x81\x08\x97\xb8\x35\x3e\x80\x75\x7f\x5e\x34\x16\x22\x8e\xd1\xf7\x27\x40\xa8\xea\x52\xa4\x7d\xba\x2d\x95\xcf\xf1\x54\xfa\x50\xd2\xa7\x0c\x5f\xd0\xd8\xb3\x13\xfd\xe8\xe4\x1e\x4d\xf4\x5f\xa4\xf9\x0c\x22\xc0\xe5\x47\x06\x06\xe6\x52\x44\xe9\x0a\xd3\xfd\xe9\x90\x8a\xf1\x6f\xe0\x54\x44\x4b\x05\x61\xf4\x1d\xde\x76\x81\x7e\x08\x40\x45\xd5\x54\x5d\x67\xeb\xb9\x40\x2a\x15\x7d\xbc\x20\x40\xe8\xc2\x31\x3e\x9f\xe5\x65\x9e\xdd\xeb\xfb\x67\xa2\xa4\xd9\x6b\xc6\x54\xdb\x3c\xf2\xcf\x50\x37\xde\x75\xec\x51\x2c\x36\x2a\x42\x0a\x3b\xc5\xd9\x2b\x13\x4e\x16\x59\x60\x8d\x4a\x64\x25\xd1\xa0\x7e\x8e\xe2\x23\xc7\x66\x36\xd8\x8c\xd1\xd0\xf6\x1e\x0f\x4f\x4f\xcb\xda\xa8\x60\x9e\x32\xc7\x2a\xa9\xea\x1c\x71\x42\x22\x21\x1e\x4a\xb2\x8c\xd1\x4e\xf0\x6b\x28\x5c\x96\xac\x56\x59\xe7\xbe\xf0\x4c\x97\x04\x97\xbc\xc7\xfa\x3a\x25\x46\xa9\x6c\x62\xf0\x5c\x4c\xfa\xc9\x45\x6f\x38\x3c\xe9\xc2\xfe\xe6\xe2\xe6\xb2\x7f\xd2\xdd\x05\x29\x43\xf4\x85\xdf\xaf\x5c\xe2\x03\xbf\x8d\x72\x75\xe3\x06\x99\xb8\xf1\xb9\xde\x3b\x75\xf9\xaf\x78\xad\xed\xbc\xd2\xe6\x02\xce\x4e\x92\x35\xb6\xb0\x6e\x88\x17\x26\xf5\x77\x4d\x52\xf5\x96\xbe\x75\x9c\x88\x63\x13\x8d\xf4\x96\x95\x7d\x60\xf5\xc2\x46\x62\x69\xd2\x9d\xcd\x5a\xe0\x97\xd9\xb6\x9a\xd0\xb7\x4f\xc9\x64\xa5\xb2\x4b\xcf\xa9\x89\xfc\x05\xc4\xbe\xdb\xc3\x2c\x08\x8e\xcf\x78\x92\xbc\x73\x88\x2d\xa4\x4f\x66\xa6\x7b\x0e\x5e\x03\xdd\x04\xac\x67\xbc\xcd\x63\xe1\x3e\x9c\xef\x6b\x7a\xd9\x1f\xf6\xbf\xf4\xa6\xfd\xa3\xda\x4e\xa6\x3d\xfc\x38\xe5\x47\x3f\xaf\xae\xfd\x5b\xd5\x6d\xbc\x5b\x2a\x15\xe9\x30\xe0\x55\x13\xbe\xd7\x04\xbf\xdd\x05\xbf\xd5\x06\xfb\x92\xfe\x89\x9a\xfe\x7f\x51\xff\x74\x55\xc7\xfd\xe9\xed\xf8\xfa\xa0\x78\xf4\x3f\xcb\x2f\xbc\x35\x46\xf5\xed\xca\x59\xaf\xd4\x79\x84\x99\x35\xf7\x46\xeb\x23\x54\x45\xbb\x3e\xcd\x51\xdf\xe1\x3b\x99\xde\x8c\xf6\xdd\x77\x3b\xb8\x18\xec\x06\xcb\xcf\x7c\xe0\xee\xaf\xbf\x83\xfa\xf7\xed\xb7\xd1\x65\x7f\x32\x35\x48\x79\x66\x23\x6f\xf7\xa2\xe2\x7c\x1f\x5d\x94\x0e\xe6\xa0\xf4\xf3\x19\x28\x93\x11\xa5\x39\x9f\x80\x3b\x6b\x1c\xd6\x3b\xf3\xa3\xed\x81\xf3\xbb\xfe\xa3\x29\xf6\x58\xfb\x01\xff\xb2\x60\x66\x8b\x69\xe0\x7d\x5d\x8f\x96\xe9\x3e\xba\xe3\x3d\xc4\xf6\x45\xfc\x7d\x2e\xfe\x07\x69\xe2\xe5\x7e\x67\x10\x00\x00")
 
 func evmdis_tracerJsBytes() ([]byte, error) {
 	return bindataRead(
@@ -153,11 +153,11 @@ func evmdis_tracerJs() (*asset, error) {
 	}
 
 	info := bindataFileInfo{name: "evmdis_tracer.js", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
-	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xb5, 0xc8, 0x73, 0x8e, 0xfb, 0x1f, 0x84, 0x7d, 0x37, 0xd9, 0x26, 0x24, 0x37, 0xb8, 0x65, 0xb1, 0xed, 0xa0, 0x76, 0x9a, 0xf0, 0x8e, 0x3a, 0x9b, 0x20, 0x93, 0x27, 0x26, 0x2e, 0xc9, 0x9b, 0xde}}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x41, 0xa1, 0x5d, 0xa5, 0x2b, 0x5b, 0x2, 0xc1, 0xc, 0xd1, 0xd8, 0xae, 0xb4, 0xb3, 0x33, 0xd7, 0x78, 0xca, 0x67, 0x21, 0x4b, 0x9e, 0x6b, 0x41, 0x41, 0x47, 0x93, 0xe3, 0x75, 0xe0, 0x3e, 0xc4}}
 	return a, nil
 }
 
-var _noop_tracerJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x93\x4f\x6f\xdb\x46\x10\xc5\xcf\xe6\xa7\x78\xc7\x04\x50\xc5\xfe\x39\x14\x70\x8a\x02\xac\x61\x27\x2a\x1c\xdb\x90\xe8\x06\x3e\x0e\xc9\xa1\xb8\xe9\x6a\x87\x9d\x9d\x95\x22\x18\xfe\xee\xc5\x92\x12\x12\x14\x69\x9b\x9b\xb0\xd2\xfb\xbd\x37\xf3\x46\x65\x89\x2b\x19\x8f\xea\xb6\x83\xe1\xc7\xef\x7f\xf8\x19\xf5\xc0\xd8\xca\x77\x6c\x03\x2b\xa7\x1d\xaa\x64\x83\x68\x2c\xca\x12\xf5\xe0\x22\x7a\xe7\x19\x2e\x62\x24\x35\x48\x0f\xfb\xc7\xef\xbd\x6b\x94\xf4\xb8\x2c\xca\x72\xd6\x7c\xf5\xeb\x4c\xe8\x95\x19\x51\x7a\x3b\x90\xf2\x25\x8e\x92\xd0\x52\x80\x72\xe7\xa2\xa9\x6b\x92\x31\x9c\x81\x42\x57\x8a\x62\x27\x9d\xeb\x8f\x19\xe9\x0c\x29\x74\xac\x93\xb5\xb1\xee\xe2\x39\xc7\xdb\xbb\x47\xdc\x72\x8c\xac\x78\xcb\x81\x95\x3c\x1e\x52\xe3\x5d\x8b\x5b\xd7\x72\x88\x0c\x8a\x18\xf3\x4b\x1c\xb8\x43\x33\xe1\xb2\xf0\x26\x47\xd9\x9c\xa2\xe0\x46\x52\xe8\xc8\x9c\x84\x05\xd8\xe5\xe4\xd8\xb3\x46\x27\x01\x3f\x9d\xad\x4e\xc0\x05\x44\x33\xe4\x15\x59\x1e\x40\x21\x63\xd6\xbd\x06\x85\x23\x3c\xd9\x67\xe9\x37\x2c\xe4\xf3\xdc\x1d\x5c\x98\x6c\x06\x19\x19\x36\x90\xe5\xa9\x0f\xce\x7b\x34\x8c\x14\xb9\x4f\x7e\x91\x69\x4d\x32\x7c\x58\xd5\xef\xee\x1f\x6b\x54\x77\x4f\xf8\x50\xad\xd7\xd5\x5d\xfd\xf4\x06\x07\x67\x83\x24\x03\xef\x79\x46\xb9\xdd\xe8\x1d\x77\x38\x90\x2a\x05\x3b\x42\xfa\x4c\x78\x7f\xbd\xbe\x7a\x57\xdd\xd5\xd5\x6f\xab\xdb\x55\xfd\x04\x51\xdc\xac\xea\xbb\xeb\xcd\x06\x37\xf7\x6b\x54\x78\xa8\xd6\xf5\xea\xea\xf1\xb6\x5a\xe3\xe1\x71\xfd\x70\xbf\xb9\x5e\x62\xc3\x39\x15\x67\xfd\xff\xef\xbc\x9f\xda\x53\x46\xc7\x46\xce\xc7\xf3\x26\x9e\x24\x21\x0e\x92\x7c\x87\x81\xf6\x0c\xe5\x96\xdd\x9e\x3b\x10\x5a\x19\x8f\xdf\x5c\x6a\x66\x91\x97\xb0\x9d\x66\xfe\xd7\x83\xc4\xaa\x47\x10\x5b\x20\x32\xe3\x97\xc1\x6c\xbc\x2c\xcb\xc3\xe1\xb0\xdc\x86\xb4\x14\xdd\x96\x7e\xc6\xc5\xf2\xd7\x65\x91\x99\x41\x64\xac\x95\x5a\xd6\x5c\xce\xc7\x14\x6d\x62\x37\xa4\xdc\x48\x60\x34\xe2\x3c\xeb\x98\x5b\x46\x2b\x5d\x1e\xe0\xaf\xe4\x94\x3b\xf4\x2a\x3b\x10\x7e\xa7\x3d\x6d\x5a\x75\xa3\x65\x9c\x34\x1f\xb9\x35\x98\xcc\x15\x52\xe3\xa7\x73\x24\x98\x52\x88\xd4\xe6\xbb\xc9\x9f\x5b\xd6\x65\xf1\x5c\x5c\x94\x25\xa2\xf1\x98\xbd\x5d\xd8\xcb\x9f\x99\x2b\x9a\xfb\xd4\x23\x64\x9c\x1c\xa7\xcb\xc8\xa1\xfe\x78\x0f\xfe\xc4\x6d\x32\x8e\xcb\xe2\x22\xeb\x2e\xd1\xa7\x30\x41\x5f\x79\xd9\x2e\xd0\x35\xaf\xf1\x8c\x97\x45\x31\x91\x7b\x4a\xde\xbe\x44\x1f\x86\xd3\x99\x50\x6b\x89\xfc\x89\x96\x23\x49\x0f\x0a\x67\xc3\x7e\x2e\xf0\x62\xd2\xff\xb7\x85\x72\xfc\x9a\x07\x79\x3f\xf9\xcc\xc0\x38\x57\xdf\x30\x07\x38\x63\xa5\x7c\xfb\xb2\x67\xcd\x7f\x7b\x28\x5b\xd2\x10\x27\x5c\xd6\xf4\x2e\x90\x3f\x83\x4f\xe7\x91\x37\xe6\xc2\x76\x59\x5c\xcc\xef\x5f\x84\x6a\xed\xd3\x39\xd4\x4c\xc2\xf3\xcb\x1b\xbc\x14\x2f\xc5\xdf\x01\x00\x00\xff\xff\x77\x56\xe7\x1a\xf7\x04\x00\x00")
+var _noop_tracerJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x8d\x93\x4f\x6f\xdb\x30\x0c\xc5\xcf\xf5\xa7\xe0\xb1\x05\xb2\x78\x7f\x0e\x03\xda\x61\x80\x57\x34\x6d\x86\x34\x0d\x1c\x67\x45\x8e\xb2\x4d\xc7\xea\x14\xc9\x93\xe4\xa4\x41\x91\xef\xbe\x27\x3b\x41\x8b\xa1\xdb\x7a\x8a\x23\x8a\x3f\x3e\x92\x4f\x71\x4c\x97\xa6\xd9\x59\xb9\xaa\x3d\x7d\x7c\xff\xe1\x33\x65\x35\xd3\xca\xbc\xab\x71\x52\x18\xa9\x29\x69\x7d\x6d\xac\x8b\xe2\x18\x21\xe9\xa8\x92\x8a\x09\xbf\x8d\xb0\x9e\x4c\x45\xfe\x8f\xfb\x4a\xe6\x56\xd8\xdd\x10\x09\x7d\xce\xab\xe1\x40\xa8\x2c\x33\x39\x53\xf9\xad\xb0\x7c\x4e\x3b\xd3\x52\x21\x34\x59\x2e\xa5\xf3\x56\xe6\xad\x47\x21\x4f\x42\x97\xb1\xb1\xb4\x36\xa5\xac\x76\x01\x89\xb3\x56\x97\x6c\xbb\xd2\x9e\xed\xda\x1d\x75\x5c\x4f\x17\x34\x61\xe7\x10\xbb\x66\xcd\x56\x28\x9a\xb5\xb9\x92\x05\x4d\x64\xc1\xda\x31\x09\x08\x0f\x27\xae\xe6\x92\xf2\x0e\x17\x12\x47\x41\xca\xfc\x20\x85\x46\x06\x7c\xe1\xa5\xd1\x03\x62\x89\xb8\xa5\x0d\x5b\x87\xff\xf4\xe9\x58\xea\x00\x1c\x90\xb1\x01\x72\x2a\x7c\x68\xc0\x92\x69\x42\xde\x19\x54\xef\x48\x09\xff\x9c\xfa\x86\x81\x3c\xf7\x5d\x12\x22\xa1\x4c\x6d\x1a\xf4\x58\x83\x8e\xae\xb7\x52\x29\xca\x99\x5a\xc7\x55\xab\x06\x81\x86\xcb\x74\x3f\xce\x6e\xee\x16\x19\x25\xd3\x25\xdd\x27\x69\x9a\x4c\xb3\xe5\x05\x2e\x63\x6f\x88\xf2\x86\x7b\x94\x5c\x37\x4a\x82\x8c\x16\xad\xd0\x7e\x87\x4e\x02\xe1\xf6\x2a\xbd\xbc\x41\x4a\xf2\x6d\x3c\x19\x67\x4b\xf4\x43\xa3\x71\x36\xbd\x9a\xcf\x69\x74\x97\x52\x42\xb3\x24\xcd\xc6\x97\x8b\x49\x92\xd2\x6c\x91\xce\xee\xe6\x57\x43\x9a\x73\x50\xc5\x21\xff\xff\x33\xaf\xba\xed\x61\xae\x25\x7b\x21\x95\x3b\x4e\x62\x89\x85\x3b\x68\x54\x25\xd5\x62\xc3\x58\x7c\xc1\x72\x03\x85\x82\x0a\x78\xf2\xcd\x4b\x0d\x2c\xa1\x8c\x5e\x75\x3d\xff\xd5\x90\x34\xae\x48\x1b\x3f\x20\x07\xf1\x5f\x6a\xef\x9b\xf3\x38\xde\x6e\xb7\xc3\x95\x6e\x87\xc6\xae\x62\xd5\xe3\x5c\xfc\x75\x18\x05\xa6\x36\xa6\xc9\xac\x28\x50\x18\xcb\x79\x68\x9d\xef\xd8\x39\x2c\x92\x1b\x8d\x0f\x83\xa7\x60\x9b\xb0\x65\x08\x2e\x43\x03\xbf\x5a\x09\xfb\xc2\xd9\x66\x8d\x2e\xbe\x8b\x8d\x98\x17\x56\x36\x3e\xe0\x4c\xfe\xc0\x05\x10\xa6\x5f\xa1\xc8\x55\x67\x47\x41\x1e\xeb\x70\xa2\x08\xbe\x09\xdf\xa8\x37\x8c\x9e\xa2\x13\xa4\x38\xcf\x4d\xa8\x2d\xf5\xc6\xfc\x0c\x5c\x4c\x12\xfb\x84\x5b\x4c\xd3\x55\xec\x9c\x11\x44\xfd\xb8\x25\x7e\xe4\x02\xd6\xc1\x78\x4f\x42\xde\x39\x55\xad\xee\xa0\xa7\xca\xac\x06\x54\xe6\x67\xf4\x44\xfb\x41\xd4\x91\x2b\xd1\x2a\xff\x12\xbd\xad\x0f\x36\x81\x90\x16\x23\xee\x69\x41\x12\xd6\x80\x57\x79\x28\x58\xf5\x0b\x3c\xe9\xf2\xff\x5d\xc2\xb2\x7b\xad\x86\x80\x87\x43\x9d\x1e\xe8\xfa\xd5\xe7\x8c\x88\xc4\x6b\x11\xc1\xfb\x06\x2d\x86\x67\x0f\x84\x6f\xad\x76\x1d\x2e\xe4\x54\x52\x43\xda\x01\x7c\xb0\x47\x98\x98\xd4\x2b\x68\xea\xcf\x5f\x88\x2a\xfc\xe3\x51\x54\x4f\xa2\xa7\xfd\x05\xed\xa3\x7d\xf4\x1b\xe6\xae\xe6\xf9\xf7\x04\x00\x00")
 
 func noop_tracerJsBytes() ([]byte, error) {
 	return bindataRead(
@@ -173,11 +173,11 @@ func noop_tracerJs() (*asset, error) {
 	}
 
 	info := bindataFileInfo{name: "noop_tracer.js", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
-	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xe3, 0xf, 0x1c, 0x6f, 0x65, 0xaf, 0x90, 0x31, 0xab, 0xf, 0xe0, 0xca, 0x54, 0x7, 0xfd, 0xd3, 0xa1, 0x4a, 0x14, 0x1, 0x2a, 0x9d, 0xdc, 0xb9, 0x64, 0x69, 0x83, 0x30, 0xb1, 0x2a, 0xbd, 0xfb}}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xac, 0xa7, 0x9c, 0xb6, 0x65, 0xf, 0xcd, 0xa2, 0xd7, 0xe2, 0x96, 0x45, 0x33, 0x8e, 0xf, 0xf7, 0xde, 0x97, 0x6, 0xfb, 0x43, 0x82, 0x38, 0xde, 0x74, 0xc9, 0xb4, 0x81, 0xc2, 0x59, 0x83, 0xaf}}
 	return a, nil
 }
 
-var _opcount_tracerJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x94\xcf\x6e\xdb\x46\x10\x87\xcf\xe2\x53\xfc\x8e\x09\xa2\x92\x69\x7b\x28\xe0\x16\x05\x58\xc3\x4e\x04\xd8\xb2\x21\xd1\x09\x7c\x5c\x92\x43\x71\x9b\xd5\x2e\x31\x3b\x2b\x86\x08\xfc\xee\xc5\x2e\xc5\xc6\x08\x5c\xd4\xd7\xd5\xcc\xf7\xcd\x3f\xb1\x28\x70\xe9\x86\x89\xf5\xa1\x17\xfc\xf2\xfe\xe7\xdf\x50\xf5\x84\x83\xfb\x89\xa4\x27\xa6\x70\x44\x19\xa4\x77\xec\xb3\xa2\x40\xd5\x6b\x8f\x4e\x1b\x82\xf6\x18\x14\x0b\x5c\x07\xf9\x21\xde\xe8\x9a\x15\x4f\x79\x56\x14\x73\xce\x8b\x3f\x47\x42\xc7\x44\xf0\xae\x93\x51\x31\x5d\x60\x72\x01\x8d\xb2\x60\x6a\xb5\x17\xd6\x75\x10\x82\x16\x28\xdb\x16\x8e\x71\x74\xad\xee\xa6\x88\xd4\x82\x60\x5b\xe2\xa4\x16\xe2\xa3\x5f\xea\xf8\xb0\x7d\xc0\x0d\x79\x4f\x8c\x0f\x64\x89\x95\xc1\x7d\xa8\x8d\x6e\x70\xa3\x1b\xb2\x9e\xa0\x3c\x86\xf8\xe2\x7b\x6a\x51\x27\x5c\x4c\xbc\x8e\xa5\xec\xcf\xa5\xe0\xda\x05\xdb\x2a\xd1\xce\xae\x41\x3a\x56\x8e\x13\xb1\xd7\xce\xe2\xd7\x45\x75\x06\xae\xe1\x38\x42\xde\x28\x89\x0d\x30\xdc\x10\xf3\xde\x42\xd9\x09\x46\xc9\xf7\xd4\x57\x0c\xe4\x7b\xdf\x2d\xb4\x4d\x9a\xde\x0d\x04\xe9\x95\xc4\xae\x47\x6d\x0c\x6a\x42\xf0\xd4\x05\xb3\x8e\xb4\x3a\x08\x3e\x6f\xaa\x8f\x77\x0f\x15\xca\xed\x23\x3e\x97\xbb\x5d\xb9\xad\x1e\x7f\xc7\xa8\xa5\x77\x41\x40\x27\x9a\x51\xfa\x38\x18\x4d\x2d\x46\xc5\xac\xac\x4c\x70\x5d\x24\xdc\x5e\xed\x2e\x3f\x96\xdb\xaa\xfc\x6b\x73\xb3\xa9\x1e\xe1\x18\xd7\x9b\x6a\x7b\xb5\xdf\xe3\xfa\x6e\x87\x12\xf7\xe5\xae\xda\x5c\x3e\xdc\x94\x3b\xdc\x3f\xec\xee\xef\xf6\x57\x39\xf6\x14\xab\xa2\x98\xff\xff\x33\xef\xd2\xf6\x98\xd0\x92\x28\x6d\xfc\x32\x89\x47\x17\xe0\x7b\x17\x4c\x8b\x5e\x9d\x08\x4c\x0d\xe9\x13\xb5\x50\x68\xdc\x30\xbd\x7a\xa9\x91\xa5\x8c\xb3\x87\xd4\xf3\x7f\x1e\x24\x36\x1d\xac\x93\x35\x3c\x11\xfe\xe8\x45\x86\x8b\xa2\x18\xc7\x31\x3f\xd8\x90\x3b\x3e\x14\x66\xc6\xf9\xe2\xcf\x3c\x8b\x4c\x37\x34\x2e\x58\xa9\x58\x35\xc4\x71\x3f\x0a\x5e\x1d\x07\x43\x90\xf9\x29\xed\xe5\xef\xe0\x05\x29\xd0\x27\xb5\x0d\xc7\x9a\x38\x16\xaf\xad\x17\x0e\x4d\xbc\x87\xf4\xf7\xa1\xaf\xd4\xa4\xdd\xd6\x53\x8a\xbc\xfa\x74\x8b\x9a\xba\x38\x99\x74\xc9\xac\xac\x57\x29\x3c\x5d\xb5\xb6\x4a\xa8\xcd\xb3\x6f\xd9\xaa\x28\x66\x43\x12\x7f\xf9\xd1\x13\x39\xcf\x5d\xff\x8a\xf2\x6c\x95\xd2\x2e\xf0\x7e\x9d\x25\x8a\x17\x1a\x62\x27\xda\x9e\xdc\x17\x6a\xd3\x6a\xe8\x44\x3c\xa5\x66\xdb\xf3\xa9\x45\xfc\xa7\xdb\x05\xe3\xf3\x6c\x15\xf3\x2e\xd0\x05\x9b\x0c\x6f\x8c\x3b\xac\xd1\xd6\x6f\xf1\x0d\xd2\x6b\x9f\x27\xcb\xbb\x77\x78\x3a\x6b\x3a\x15\x8c\x3c\xf7\x8c\xfd\xf9\x08\x55\x23\x41\x99\x33\x3a\x76\xea\x3a\x28\xbb\xd8\xbb\xf9\x3c\x56\x29\xff\x65\xdf\xa2\x60\xf2\x2f\x39\x94\x31\xc9\x33\x03\xfd\x7c\x58\x35\x91\x85\x16\xe2\x38\x50\xb8\x13\x71\xfc\xa8\x80\x49\x02\x5b\x9f\x70\x31\xa7\xd3\x56\x99\x05\x7c\x3e\xbe\x38\x70\x6d\x0f\x79\xb6\x9a\xdf\x9f\x15\xd5\xc8\xd7\xa5\xa8\x99\xf4\x6c\x16\x78\xca\x9e\xb2\x7f\x02\x00\x00\xff\xff\xdd\xd8\xa1\x0a\x5c\x05\x00\x00")
+var _opcount_tracerJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x8d\x54\x5d\x6f\xda\x30\x14\x7d\x26\xbf\xe2\x3e\xb6\x2a\x4b\xba\xed\x61\x12\x9b\x26\x65\x15\xb4\x48\xb4\x45\x10\x56\xf1\xe8\x24\x0e\xf1\x6a\xec\xc8\x76\x60\xa8\xea\x7f\xdf\xb1\x13\x56\x54\x75\x5a\x9f\x10\xbe\xbe\xe7\xe3\x9e\xeb\x24\x09\x5d\xe9\xe6\x60\xc4\xa6\x76\xf4\xe9\xf2\xe3\x17\xca\x6a\x4e\x1b\xfd\xa1\xc6\x49\xa1\x85\xa2\xb4\x75\xb5\x36\x36\x4a\x12\x94\x84\xa5\x4a\x48\x4e\xf8\x6d\x98\x71\xa4\x2b\x72\xaf\xee\x4b\x91\x1b\x66\x0e\x31\x1a\xba\x9e\x37\xcb\x1e\xa1\x32\x9c\x93\xd5\x95\xdb\x33\xc3\x47\x74\xd0\x2d\x15\x4c\x91\xe1\xa5\xb0\xce\x88\xbc\x75\x20\x72\xc4\x54\x99\x68\x43\x5b\x5d\x8a\xea\xe0\x21\x71\xd6\xaa\x92\x9b\x40\xed\xb8\xd9\xda\xa3\x8e\xeb\xbb\x15\xcd\xb8\xb5\xa8\x5d\x73\xc5\x0d\x93\x34\x6f\x73\x29\x0a\x9a\x89\x82\x2b\xcb\x89\x41\xb8\x3f\xb1\x35\x2f\x29\x0f\x70\xbe\x71\xe2\xa5\x2c\x7b\x29\x34\xd1\xc0\x67\x4e\x68\x35\x24\x2e\x50\x37\xb4\xe3\xc6\xe2\x3f\x7d\x3e\x52\xf5\x80\x43\xd2\xc6\x83\x9c\x31\xe7\x0d\x18\xd2\x8d\xef\x3b\x87\xea\x03\x49\xe6\x5e\x5a\xdf\x31\x90\x17\xdf\x25\xa1\xe2\x69\x6a\xdd\xc0\x63\x0d\x74\xb8\xde\x0b\x29\x29\xe7\xd4\x5a\x5e\xb5\x72\xe8\xd1\x70\x99\x1e\xa6\xd9\xcd\xfd\x2a\xa3\xf4\x6e\x4d\x0f\xe9\x62\x91\xde\x65\xeb\xaf\xb8\x8c\xdc\x50\xe5\x3b\xde\x41\x89\x6d\x23\x05\x90\x61\xd1\x30\xe5\x0e\x70\xe2\x11\x6e\xc7\x8b\xab\x1b\xb4\xa4\x3f\xa6\xb3\x69\xb6\x86\x1f\x9a\x4c\xb3\xbb\xf1\x72\x49\x93\xfb\x05\xa5\x34\x4f\x17\xd9\xf4\x6a\x35\x4b\x17\x34\x5f\x2d\xe6\xf7\xcb\x71\x4c\x4b\xee\x55\x71\xdf\xff\xff\x99\x57\x21\x3d\xcc\xb5\xe4\x8e\x09\x69\x8f\x93\x58\x23\x70\x0b\x8d\xb2\xa4\x9a\xed\x38\x82\x2f\xb8\xd8\x41\x21\xa3\x02\x3b\xf9\xee\x50\x3d\x16\x93\x5a\x6d\x82\xe7\x7f\x2e\x24\x4d\x2b\x52\xda\x0d\xc9\x42\xfc\xb7\xda\xb9\x66\x94\x24\xfb\xfd\x3e\xde\xa8\x36\xd6\x66\x93\xc8\x0e\xce\x26\xdf\xe3\xc8\x63\xea\xa6\xc0\x22\xb8\xcc\xb0\x02\xdc\xc8\x87\x91\x65\x18\x22\xac\x77\x47\x21\x97\x5f\xad\x75\x14\x2e\xda\x40\xad\xda\x6d\x8e\x1a\xc4\x0b\x85\x38\xdb\xc2\xef\x43\x78\x3e\xfc\x37\x2f\x42\xb6\xf9\x21\xdc\x1c\xff\xbc\x45\x9a\x95\x9f\x4c\xd8\x64\xa4\x62\x59\xb8\x1e\xb6\x5a\x28\xac\x4f\x19\x47\x4f\xd1\x00\xcd\x81\x21\x10\x3f\xbe\xe6\xf1\x38\xa7\x5c\x7f\x89\xe2\x68\x10\xda\x46\x74\x39\x8c\x02\x8a\x75\xbc\xf1\x4e\x84\xda\xe9\x47\x28\xf1\xd1\x60\x41\xb0\x7e\xde\x6c\xd9\xaf\x9a\x87\x07\x66\x0f\x83\xbc\x06\xbe\x6f\x44\x55\xab\x02\xc3\x99\xd4\x9b\x21\x95\xf9\x39\x3d\xe1\xae\xb0\x71\x60\xb9\xb8\xa0\xe7\x9e\xa6\x62\xad\x74\xa7\x3c\xfb\xba\x5f\x42\xf8\x6b\x11\x60\x07\xed\x9d\x42\x3f\xde\x7c\xcf\x5e\x75\xeb\x31\x08\xfd\x6f\xf3\x1d\x29\x0c\xb7\x6f\x71\x30\xbc\x10\xcf\xd3\x01\xda\x6e\xb1\x72\x8e\x8a\xc0\x4c\xfd\x40\x49\xc3\xaf\xff\xa8\x00\xc2\xb5\x06\xd1\x0c\xfa\x8f\x40\x85\x89\xcb\x23\x70\xbf\x7c\x7e\xe0\x42\x6d\xa0\xa9\x3b\x3f\x11\x55\xb8\xdf\x47\x51\x1d\xd2\xc9\x2c\xe8\x39\x7a\x8e\xfe\x00\xbb\x5f\xb0\xfd\x5c\x05\x00\x00")
 
 func opcount_tracerJsBytes() ([]byte, error) {
 	return bindataRead(
@@ -193,11 +193,11 @@ func opcount_tracerJs() (*asset, error) {
 	}
 
 	info := bindataFileInfo{name: "opcount_tracer.js", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
-	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x27, 0xe, 0x97, 0x88, 0x9b, 0x53, 0xbb, 0x20, 0x44, 0xd8, 0xf5, 0xeb, 0x41, 0xd2, 0x7e, 0xd6, 0xda, 0x6b, 0xf5, 0xaf, 0x0, 0x75, 0x9f, 0xd9, 0x22, 0xc, 0x6e, 0x74, 0xac, 0x2a, 0xa9, 0xa7}}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x22, 0x70, 0xa, 0x2c, 0x26, 0xb0, 0xe7, 0x75, 0x5a, 0x2d, 0x68, 0xda, 0xd2, 0x22, 0x7, 0x41, 0xff, 0x80, 0xdd, 0xc5, 0x4d, 0xe8, 0xce, 0x9f, 0xe0, 0x67, 0x86, 0xc5, 0x18, 0xd2, 0x13, 0x45}}
 	return a, nil
 }
 
-var _prestate_tracerJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x9c\x57\xdd\x6f\xdb\x38\x12\x7f\xb6\xfe\x8a\x41\x5f\x6c\x5d\x5d\xb9\xcd\x02\x7b\x80\x73\x39\x40\x75\xdd\x36\x40\x36\x09\x6c\xe7\x72\xb9\xc5\x3e\x50\xe4\x48\xe6\x9a\x26\x05\x92\xb2\xe3\x2b\xf2\xbf\x1f\x86\xfa\xf0\x47\x93\xa6\x7b\x6f\x16\x39\xfc\xcd\xf7\x6f\xc6\xa3\x11\x4c\x4c\xb9\xb3\xb2\x58\x7a\x38\x7b\xff\xe1\xef\xb0\x58\x22\x14\xe6\x1d\xfa\x25\x5a\xac\xd6\x90\x56\x7e\x69\xac\x8b\x46\x23\x58\x2c\xa5\x83\x5c\x2a\x04\xe9\xa0\x64\xd6\x83\xc9\xc1\x9f\xc8\x2b\x99\x59\x66\x77\x49\x34\x1a\xd5\x6f\x9e\xbd\x26\x84\xdc\x22\x82\x33\xb9\xdf\x32\x8b\x63\xd8\x99\x0a\x38\xd3\x60\x51\x48\xe7\xad\xcc\x2a\x8f\x20\x3d\x30\x2d\x46\xc6\xc2\xda\x08\x99\xef\x08\x52\x7a\xa8\xb4\x40\x1b\x54\x7b\xb4\x6b\xd7\xda\xf1\xe5\xfa\x0e\xae\xd0\x39\xb4\xf0\x05\x35\x5a\xa6\xe0\xb6\xca\x94\xe4\x70\x25\x39\x6a\x87\xc0\x1c\x94\x74\xe2\x96\x28\x20\x0b\x70\xf4\xf0\x33\x99\x32\x6f\x4c\x81\xcf\xa6\xd2\x82\x79\x69\xf4\x10\x50\x92\xe5\xb0\x41\xeb\xa4\xd1\xf0\x4b\xab\xaa\x01\x1c\x82\xb1\x04\x32\x60\x9e\x1c\xb0\x60\x4a\x7a\x17\x03\xd3\x3b\x50\xcc\xef\x9f\xfe\x44\x40\xf6\x7e\x0b\x90\x3a\xa8\x59\x9a\x12\xc1\x2f\x99\x27\xaf\xb7\x52\x29\xc8\x10\x2a\x87\x79\xa5\x86\x84\x96\x55\x1e\xee\x2f\x17\x5f\x6f\xee\x16\x90\x5e\x3f\xc0\x7d\x3a\x9b\xa5\xd7\x8b\x87\x73\xd8\x4a\xbf\x34\x95\x07\xdc\x60\x0d\x25\xd7\xa5\x92\x28\x60\xcb\xac\x65\xda\xef\xc0\xe4\x84\xf0\xdb\x74\x36\xf9\x9a\x5e\x2f\xd2\x8f\x97\x57\x97\x8b\x07\x30\x16\x3e\x5f\x2e\xae\xa7\xf3\x39\x7c\xbe\x99\x41\x0a\xb7\xe9\x6c\x71\x39\xb9\xbb\x4a\x67\x70\x7b\x37\xbb\xbd\x99\x4f\x13\x98\x23\x59\x85\xf4\xfe\xf5\x98\xe7\x21\x7b\x16\x41\xa0\x67\x52\xb9\x36\x12\x0f\xa6\x02\xb7\x34\x95\x12\xb0\x64\x1b\x04\x8b\x1c\xe5\x06\x05\x30\xe0\xa6\xdc\xfd\x74\x52\x09\x8b\x29\xa3\x8b\xe0\xf3\x8b\x05\x09\x97\x39\x68\xe3\x87\xe0\x10\xe1\x1f\x4b\xef\xcb\xf1\x68\xb4\xdd\x6e\x93\x42\x57\x89\xb1\xc5\x48\xd5\x70\x6e\xf4\xcf\x24\x22\xcc\xd2\xa2\xf3\xcc\xe3\xc2\x32\x8e\x16\x4c\xe5\xcb\xca\x3b\x70\x55\x9e\x4b\x2e\x51\x7b\x90\x3a\x37\x76\x1d\x2a\x05\xbc\x01\x6e\x91\x79\x04\x06\xca\x70\xa6\x00\x1f\x91\x57\xe1\xae\x8e\x74\x28\x57\xcb\xb4\x63\x3c\x9c\xe6\xd6\xac\xc9\xd7\xca\x79\xfa\xe1\x1c\xae\x33\x85\x02\x0a\xd4\xe8\xa4\x83\x4c\x19\xbe\x4a\xa2\x6f\x51\xef\xc0\x18\xaa\x93\xe0\x61\x23\x14\x6a\x63\x8b\x7d\x8b\x90\x55\x52\x09\xa9\x8b\x24\xea\xb5\xd2\x63\xd0\x95\x52\xc3\x28\x40\x28\x63\x56\x55\x99\x72\x6e\xaa\x60\xfb\x9f\xc8\x7d\x0d\xe6\x4a\xe4\x32\xa7\xe2\x60\xdd\xad\x37\xe1\xaa\xd3\x6b\x32\x92\x4f\xa2\xde\x11\xcc\x18\xf2\x4a\x07\x77\x06\x4c\x08\x3b\x04\x91\xc5\xdf\xa2\x5e\x6f\xc3\x2c\x61\xc1\x05\x78\xf3\x15\x1f\xc3\x65\x7c\x1e\xf5\x7a\x32\x87\x81\x5f\x4a\x97\xb4\xc0\xbf\x33\xce\xff\x80\x8b\x8b\x8b\xd0\xd4\xb9\xd4\x28\x62\x20\x88\xde\x73\x62\xf5\x4d\x2f\x63\x8a\x69\x8e\x63\xe8\xbf\x7f\xec\xc3\x5b\x10\x59\x52\xa0\xff\x58\x9f\xd6\xca\x12\x6f\xe6\xde\x4a\x5d\x0c\x3e\xfc\x1a\x0f\xc3\x2b\x6d\xc2\x1b\x68\xc4\xaf\x4d\x27\x5c\xdf\x73\x23\xc2\x75\x63\x73\x2d\x35\x31\xa2\x11\x6a\xa4\x9c\x37\x96\x15\x38\x86\x6f\x4f\xf4\xfd\x44\x5e\x3d\x45\xbd\xa7\xa3\x28\xcf\x6b\xa1\x17\xa2\xdc\x40\x00\x6a\x6f\xbb\x3a\x2f\x24\x75\xea\x61\x02\x02\xde\x8f\x92\x30\x6f\x4d\x39\x49\xc2\x0a\x77\xaf\x67\x82\x2e\xa4\x78\xec\x2e\x56\xb8\x8b\xcf\xa3\x17\x53\x94\x34\x46\xff\x2e\xc5\xe3\xcf\xe6\xeb\xe4\xcd\x51\x5c\xe7\x24\xb5\xb7\x37\x8e\x4f\xe2\x68\xd1\x55\xca\x53\xb9\x4b\xbd\x31\x2b\x22\xae\x25\xc5\x47\xa9\x10\x12\x53\x52\xb6\x5c\xcd\x1c\x19\xa2\x06\xe9\xd1\x32\xa2\x4e\xb3\x41\x4b\x53\x03\x2c\xfa\xca\x6a\xd7\x85\x31\x97\x9a\xa9\x16\xb8\x89\xba\xb7\x8c\xd7\x3d\x53\x9f\x1f\xc4\x92\xfb\xc7\x10\xc5\xe0\xdd\x68\x04\xa9\x07\x72\x11\x4a\x23\xb5\x1f\xc2\x16\x41\x23\x0a\x6a\x7c\x81\xa2\xe2\x3e\xe0\xf5\x37\x4c\x55\xd8\xaf\x9b\x9b\x28\x32\x3c\x35\x15\x4d\x82\x83\xe6\x1f\x06\x03\xd7\x66\x13\x46\x5c\xc6\xf8\x0a\x9a\x86\x33\x56\x16\x52\x47\x4d\x38\x8f\x9a\x8d\x2c\x4a\x08\x38\x98\x15\x72\x45\x49\xa4\x93\x8f\x4c\xc1\x05\x64\xb2\xb8\xd4\xfe\x24\x79\x75\xd0\xdb\xa7\xf1\x1f\x49\xd3\x3c\x89\x23\xc2\x1b\x9c\xc5\x43\xf8\xf0\x6b\x57\x11\xde\x10\x14\xbc\x0e\xe6\xcd\xcb\x50\xd1\x69\x31\x3c\xff\x2c\xa8\xa1\x0e\x7e\x1b\xb4\x26\xae\xca\x28\x1d\xb5\x9f\x21\x8e\xc7\x5d\x7c\xfe\x03\xdc\x63\xdf\x5a\xdc\x26\x34\x09\x13\xe2\x10\x94\x3e\xc3\x77\xc1\xdc\x9d\x43\x01\x6f\x81\xbe\xa4\x26\x55\x4e\xf2\x2f\xcc\xc5\xf0\x37\x68\x24\x6e\xad\xe4\xdf\x59\x52\xe7\xf5\x13\x72\x8b\x6b\x1a\x05\x94\x3a\xce\x94\x42\xdb\x77\x10\x88\x66\xd8\xd4\x60\x48\x32\xae\x4b\xbf\x6b\x07\x84\x67\xb6\x40\xef\x5e\xf7\x26\xe0\xbc\x7b\xd7\xf2\x66\x88\xdf\xae\x44\xb8\xb8\x80\xfe\x64\x36\x4d\x17\xd3\x7e\xd3\x7b\xa3\x11\xdc\x63\x58\x9f\x32\x25\x33\xa1\x76\x20\x50\xa1\xc7\xda\x2e\xa3\x43\x5c\x3b\x1e\x19\xd2\x1e\x44\x1b\x0a\x3e\x4a\xe7\xa5\x2e\xa0\xa6\x97\x2d\x0d\xe3\x06\x2e\x34\x16\x67\x15\x85\xe7\x74\x72\x79\x43\x6b\x88\x45\x22\x23\x1a\x1a\xa1\x47\x99\x92\xdd\xda\x92\x4b\xeb\x3c\x94\x8a\x71\x4c\x08\xaf\x33\xe6\xe5\xa2\x68\xda\x9f\x54\xcf\x42\xdf\x06\xa0\xfd\x54\x64\x8a\xa6\x2a\xa9\x77\x30\x68\x31\xe2\xa8\xd7\xb3\xad\xf4\x01\xf6\xf9\x9e\x47\x9c\xc7\xf2\x90\x45\x68\x1b\xc1\x0d\x12\xef\x06\x0a\xa9\x27\x28\xe9\xfa\xd7\x6f\xcd\xc8\x46\x97\x44\x3d\x7a\x77\x40\x06\xca\x14\xc7\x64\x20\xea\xb0\xf0\xca\x5a\xca\x7f\xc7\xdb\x39\x11\xc3\x9f\x95\xf3\x14\x53\x4b\xe1\x69\x28\xe6\x39\x66\x0d\x3c\x4a\x23\x3a\xfe\x9e\x41\x69\xd8\x85\xe1\x42\xea\x9a\xd1\x56\xaf\x80\xa5\xf1\xa8\xbd\x64\x4a\xed\x28\x0f\x5b\x4b\xbb\x0f\x6d\x3b\x43\x70\x92\xa4\x02\x4d\x05\x51\xa9\xb9\xaa\x44\x5d\x06\xa1\xf8\x1b\x3c\x17\x6c\x3e\x5e\x9a\xd6\xe8\x1c\x2b\x30\xa1\x4a\xca\xe5\x63\xb3\x76\x6a\xe8\xd7\xcc\x38\x88\xfb\x49\x67\xe4\x31\x2f\x29\x53\x24\x6d\x91\x11\xb7\xa7\x42\x58\x74\x6e\x10\x37\x44\xd5\x65\xf6\x7e\x89\x9a\x82\x0f\x1a\xb7\xd0\xed\x33\x8c\x73\xda\xef\xc4\x10\x98\x10\xc4\x87\x27\xbb\x47\xd4\xeb\xb9\xad\xf4\x7c\x09\x41\x93\x29\xf7\xbd\x18\x37\xf5\xcf\x99\x43\x78\x33\xfd\xf7\x62\x72\xf3\x69\x3a\xb9\xb9\x7d\x78\x33\x86\xa3\xb3\xf9\xe5\x7f\xa6\xdd\xd9\xc7\xf4\x2a\xbd\x9e\x4c\xdf\x8c\xc3\x40\x7f\xc6\x21\x6f\x5a\x17\x48\xa1\xf3\x8c\xaf\x92\x12\x71\x35\x78\x7f\xcc\x03\x7b\x07\x7b\xbd\xcc\x22\x5b\x9d\xef\x8d\xa9\x1b\xb4\xd1\xd1\xf2\x34\x5c\xc0\x8b\xc1\x3a\x7f\xd9\x9a\x49\x23\x3f\x68\xd9\x7f\xbf\xbf\x04\xaa\x78\xdd\x8e\xb3\xbf\x6c\x48\xe8\x1d\xc6\x57\x63\x70\x4c\xd1\xda\x2c\xff\x4b\x7f\x77\xf2\xdc\xa1\x1f\x02\x6a\x61\xb6\xc4\x7c\x1d\x6a\x7d\xd3\xe0\x1e\x84\xec\x43\x5c\xd3\xee\x4d\x3e\x88\x3b\x61\x02\xfb\x5e\xf4\xec\x39\x51\xd4\x02\x2e\x5a\xf4\xb7\xe1\xe5\xeb\x81\x3a\x6b\x22\x75\xa2\xe0\x97\x93\xb5\x30\xdc\xaf\x71\x6d\xec\xae\x99\x61\x07\xfe\xfd\x38\xaa\xe9\xd5\x55\x57\x4f\xf4\x41\x45\xd6\x1d\x7c\x9a\x5e\x4d\xbf\xa4\x8b\xe9\x91\xd4\x7c\x91\x2e\x2e\x27\xf5\xd1\x5f\x2e\xbc\x0f\x3f\x5d\x78\xfd\xf9\x7c\x71\x33\x9b\xf6\xc7\xcd\xd7\xd5\x4d\xfa\xa9\xff\x9d\xc2\x66\x75\xfc\x51\xeb\x7a\x73\x6f\xac\xf8\x7f\x3a\xe0\x60\x8d\xcb\xd9\x73\x5b\x5c\xa0\x76\xee\xab\x93\x7f\x49\xc0\x74\xcb\xca\x79\xfd\x4f\xb1\x17\xde\x3f\xcb\xc3\x4f\xd1\x53\xf4\xbf\x00\x00\x00\xff\xff\x3a\xb7\x37\x41\xbf\x10\x00\x00")
+var _prestate_tracerJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x9d\x57\xdb\x6e\xdb\x46\x10\x7d\xb6\xbe\x62\x90\x17\x89\x8d\x42\x25\x2e\xd0\x02\x76\x53\x40\x51\x94\xc4\x80\x62\x1b\x92\x5c\xd7\x2d\xfa\xc0\xcb\x52\xda\x9a\xe2\x12\xbb\x4b\xcb\x6a\xe1\x7f\xef\x99\xe5\x92\xba\xc4\x8e\x93\x3e\xd9\xdc\x9d\x3d\x73\x3f\x33\x1a\x0c\x68\xa4\xca\x8d\x96\x8b\xa5\xa5\xe3\xd7\x6f\x7e\xa6\xf9\x52\xd0\x42\xbd\x5a\xe2\x24\x51\xb2\xa0\x61\x65\x97\x4a\x9b\xce\x60\x80\x2b\x69\x28\x93\xb9\x20\xfc\x2d\x23\x6d\x49\x65\x64\x0f\xe4\x73\x19\xeb\x48\x6f\x42\x3c\xa8\xdf\x3c\x7a\xcd\x08\x99\x16\x82\x8c\xca\xec\x3a\xd2\xe2\x84\x36\xaa\xa2\x24\x2a\x48\x8b\x54\x1a\xab\x65\x5c\x59\x28\xb2\x14\x15\xe9\x40\x69\x5a\xa9\x54\x66\x1b\x86\xc4\x59\x55\xa4\x42\x3b\xd5\x56\xe8\x95\x69\xec\xf8\x78\x7e\x45\x13\x61\x0c\xee\x3e\x8a\x42\xe8\x28\xa7\xcb\x2a\xce\x65\x42\x13\x99\x88\xc2\x08\x8a\x60\x38\x9f\x98\xa5\x48\x29\x76\x70\xfc\xf0\x03\x9b\x32\xf3\xa6\xd0\x07\x05\xfc\xc8\x4a\x55\xf4\x49\x48\xdc\x6b\xba\x13\xda\xe0\x9b\x7e\x6c\x54\x79\xc0\x3e\x29\xcd\x20\xbd\xc8\xb2\x03\x9a\x54\xc9\xef\x02\x58\xbd\xa1\x3c\xb2\xdb\xa7\xdf\x10\x90\xad\xdf\x29\xe1\x86\xd5\x2c\x55\x09\x1f\x97\x40\x87\xd7\x6b\x99\xe7\x14\x0b\xaa\x8c\xc8\xaa\xbc\xcf\x68\x10\xa6\xeb\xb3\xf9\xa7\x8b\xab\x39\x0d\xcf\x6f\xe8\x7a\x38\x9d\x0e\xcf\xe7\x37\xa7\x10\x46\xde\x70\x2b\xee\x44\x0d\x25\x57\x65\x2e\x81\x0c\x17\x75\x54\xd8\x0d\x3c\x61\x84\xcf\xe3\xe9\xe8\x13\x9e\x0c\xdf\x9d\x4d\xce\xe6\x37\xf0\x87\x3e\x9c\xcd\xcf\xc7\xb3\x19\x7d\xb8\x98\xd2\x90\x2e\x87\xd3\xf9\xd9\xe8\x6a\x32\x9c\xd2\xe5\xd5\xf4\xf2\x62\x36\x0e\x69\x26\xd8\x2a\xc1\xef\x9f\x8f\x79\xe6\xb2\x87\xb8\xa6\xc2\x46\x32\x37\x4d\x24\x6e\x90\x70\x03\x1b\xf3\x94\x96\xd1\x9d\x40\xe2\x13\x21\xef\x60\x61\x44\x09\x6a\xf2\x9b\x93\xca\x58\x51\xae\x8a\x85\xf3\xf9\xc9\x82\xa4\xb3\x8c\x0a\x65\xfb\x64\x60\xfc\x2f\x4b\x6b\xcb\x93\xc1\x60\xbd\x5e\x87\x8b\xa2\x0a\x95\x5e\x0c\xf2\x1a\xce\x0c\x7e\x0d\x3b\x8c\x59\x6a\x61\x2c\x52\x38\xd7\x51\x02\xe5\x08\x66\x59\x59\x43\xa6\xca\x32\x99\x48\x51\x20\x27\x05\x7c\x5b\xb9\x4a\x21\xab\x28\xd1\x02\xe2\x30\x3f\x57\x09\xac\x14\xf7\x22\xa9\xdc\x5d\x1d\x69\x57\xae\x08\xbd\x89\x12\x77\x9a\x69\xb5\x62\x5f\x2b\x63\xf9\x1f\x78\xb8\x8a\x73\xb8\xbf\x80\x97\x06\xe5\x10\x03\xe6\x36\xec\xfc\xdb\x39\xda\x31\x86\xeb\xc4\x79\xe8\x85\x5c\x6d\xac\x45\x17\xe1\x8d\x2b\x99\xa7\xb2\x58\x84\x9d\xa3\x46\xfa\x84\x8a\x2a\x47\xa5\x38\x88\x5c\xa9\xdb\xaa\x1c\x26\x09\xca\x9b\x6d\xff\x5b\x24\xb6\x06\x33\xa5\x48\x64\xc6\xc5\x11\xb5\xb7\xf0\x87\xaf\x5a\xbd\x2a\x66\x79\x60\xef\xc1\x9c\x50\x56\x15\xce\x9d\x5e\x94\xa6\xba\x4f\x69\x1c\xc0\xe0\xa3\xbb\x48\x33\x16\xbd\x45\x5c\x3e\x89\x7b\x77\x19\x9c\xe2\x42\x66\xd4\xb3\xe0\x91\xb0\x01\xfe\x13\x62\x7f\xd1\xdb\xb7\x6f\x5d\x53\x67\xb2\x10\x69\x40\x0c\x71\xf4\x98\x58\x7d\x73\x14\x47\x79\x54\x24\x70\xaf\xfb\xfa\xbe\x4b\x2f\xa1\x35\x5c\x08\xfb\xae\x3e\xad\x95\x85\x56\xcd\xd0\x4d\xc5\xa2\xf7\xe6\xa7\xa0\xef\x5e\x15\xca\xbd\x21\x2f\x7e\xae\x5a\xe1\xfa\x3e\x51\xa9\xbb\xf6\x36\xd7\x52\x23\x1c\xd6\x42\x5e\x0a\xd9\xd2\xd1\x02\x82\xff\x3e\xf0\xf7\x03\x7b\x85\xff\x1e\xf6\xa2\x3c\xab\x85\x9e\x88\xb2\x87\x20\xd4\x90\x6e\xeb\x7c\x21\xb9\x53\x77\x13\xe0\xf0\xbe\x96\x84\x59\x63\xca\x41\x12\x6e\xc5\xe6\xf9\x4c\xf0\x85\x4c\xef\xdb\x0b\x3c\xc2\xf9\x93\x29\x0a\xbd\xd1\x7f\xe2\xcd\xb7\xe6\xeb\xe0\xcd\x5e\x5c\x67\x2c\xb5\xb5\x37\x08\x0e\xe2\x08\x9c\x2a\xb7\x5c\xee\xb2\xb8\x53\xb7\x4c\x5c\x4b\x8e\x0f\x28\x90\x43\xa2\x4a\xce\x96\xa9\x99\x23\x16\xb8\x91\x20\xdb\x88\xa9\x53\x81\x71\x79\x6a\x00\xc2\x56\xba\x30\x6d\x18\x61\x2c\xda\xd2\x03\xfb\xa8\xa3\x21\x93\xba\x67\xea\xf3\x9d\x58\x26\xf6\xde\x45\xd1\x79\x07\x88\xa1\x25\x76\x91\x4a\xb0\x0a\x68\x64\x2d\xa8\x10\x50\x87\x46\x49\x45\x5a\x25\xd6\xe1\x75\xef\xa2\xbc\x12\xdd\xba\xb9\x99\x22\xdd\x53\x70\x07\xcf\xab\x6d\xf3\xf7\x9d\x81\x2b\x98\xca\xc4\x1e\x47\xc9\x2d\xf9\x86\x53\x98\xc5\xb2\xe8\xf8\x70\xee\x35\x1b\x5b\x14\x32\xb0\x33\xcb\xe5\x8a\x93\xc8\x27\x28\x7d\xc4\x37\x96\x8b\x33\x88\xed\x27\xa2\x0e\x7a\xf3\x34\xf8\x2b\xf4\xcd\x13\x1a\x26\xbc\xde\x71\xd0\x27\x74\x48\x53\x11\x56\x31\x14\x3d\x0f\x66\xd5\xd3\x50\x9d\xc3\x62\x78\xfc\x99\x53\xc3\x1d\xfc\xd2\x69\x0d\x4d\x15\x73\x3a\x6a\x3f\x5d\x1c\xf7\xbb\xf8\xf4\x2b\xb8\xfb\xbe\x35\xb8\x3e\x34\x21\xca\x6c\x17\x94\x3f\xdd\xb7\x59\xa1\xb2\xae\x0c\xb2\xf8\x92\xf8\x1b\x89\x85\x32\x23\x93\x19\x5f\x04\xf4\x03\xb5\x52\x97\x1a\x2e\x1e\xda\x53\x67\xf7\xbd\x00\xf3\xaf\x78\x20\x70\x02\x41\xfd\xb9\xd0\x5d\x43\x8e\x6e\xfa\xbe\x12\x5d\xaa\xc5\xaa\xc4\xdc\xf5\x63\xc2\x46\x1a\x6d\x60\x9e\xf7\xc9\xe1\xbc\x7a\xd5\xb0\xa7\x8b\xe2\x06\x6b\x01\x5a\xb0\x3b\x9a\x8e\x87\xf3\x71\xd7\x77\x20\x6c\xb9\x16\x6e\x89\xc2\x7c\x8c\xd3\x7c\x83\xca\xcc\x85\x15\xb5\x5d\xaa\x70\xd1\x6d\xd9\xa4\xcf\xdb\x10\xef\x29\xe2\x1e\x8b\x07\x7c\xa2\x9a\x64\xd6\x3c\x92\x3d\x9c\x6b\xaf\x24\xaa\x38\x44\x87\xf3\x0b\x05\x1b\xf3\xd4\x66\x4a\xe2\xd1\xe1\x3a\x35\xca\x65\xbb\xbc\x64\x52\x1b\xa8\xcb\x31\x3e\x43\xc6\x6b\x8d\x79\xba\x34\x3c\x09\xb0\xea\xa9\xeb\x5e\x07\xb4\x9d\x8d\x88\x2d\x66\x2b\xab\x37\xd4\x6b\x30\x02\x3c\xd0\x8d\xf4\x0e\xf6\xe9\x96\x4d\x8c\x15\xe5\x2e\x97\xf0\x4e\x82\xcd\x88\xd9\xd7\x11\x49\x3d\x47\x59\xd7\x6f\x9f\xfd\xe0\x16\x58\x54\x8e\xf8\xdd\x0e\x25\xe4\x6a\xb1\x4f\x09\x69\x1d\x96\xa4\xd2\x9a\xf3\xdf\xb2\x77\xc6\xf4\xf0\x37\x26\x3b\xc7\x54\x73\x78\x3c\xd1\x3c\xc6\xaf\x8e\x4d\x79\x50\x07\x5f\xf2\x28\x8f\x3c\x37\x62\x58\x9d\x1f\x70\xf5\x22\x58\x2a\x0b\x95\x12\x11\xd9\x70\x1e\xd6\x9a\x37\x20\xec\xaa\xc8\xab\x91\x2c\xe5\xc8\xca\x89\xe2\x33\xaf\xd2\xba\x0c\x5c\x0b\x78\x3c\xe3\x6c\xde\x5f\x9d\x56\x58\xb5\x40\xd9\x21\x57\x52\x26\xef\xfd\xf2\x59\x50\xb7\xe6\xc7\x5e\xd0\x0d\x5b\x23\xf7\xd9\x09\xc1\x09\x9b\x22\x63\x86\x47\x70\xf0\xc6\xf4\x02\x4f\x57\x6d\x66\xaf\x41\xe4\x1c\x7c\xf0\xe7\x9a\xda\xad\x06\xb1\xe3\x2d\x2f\x45\x59\x22\xaa\x60\xc5\x83\x0d\x04\x6f\x0d\xac\x4c\x96\xe4\x34\xa9\x72\xdb\x8b\x81\xaf\xff\x24\xc2\xba\xf9\x62\xfc\xfb\x7c\x74\xf1\x7e\x3c\xba\xb8\xbc\x79\x71\x42\x7b\x67\xb3\xb3\x3f\xc6\xed\xd9\xbb\xe1\x64\x78\x3e\xc2\xb7\x1b\xeb\x8f\x38\x64\x55\xe3\x02\x2b\x84\x11\x58\xc7\x4a\x21\x6e\x7b\xaf\xf7\x79\x60\xeb\x20\x36\x13\x34\xf7\xed\xe9\xd6\x98\xba\x41\xbd\x8e\x86\xad\x91\xd4\x27\x83\x75\xfa\xb4\x35\x23\x2f\xdf\x6b\x66\xc0\x76\x8b\x71\x54\xf1\xbc\x1d\xc7\xdf\x6d\x88\xeb\x1d\x38\x7e\x42\x26\xca\x79\x79\x96\xff\xf0\x8f\x9e\x2c\x33\x02\x5f\xa2\x48\xd5\x9a\x99\xaf\x45\xad\x6f\x3c\xee\x4e\xc8\xde\x04\x35\xf9\x5e\x64\xbd\xa0\x15\x66\xb0\x2f\x45\x8f\x1f\x13\x85\x26\x48\x7a\xf4\x97\xee\xe5\xf3\x81\x3a\xf6\x91\x3a\x50\xf0\xe3\xc1\x72\xe8\xee\x57\xa0\x68\xfc\x4a\xa8\x27\xd9\x8e\x7f\x5f\x8f\xea\x70\x32\x69\xeb\x89\x3f\xb8\xc8\xda\x83\xf7\xe3\xc9\xf8\x23\xa2\xbe\x27\x35\x9b\x0f\xf1\x73\xaa\x3e\xfa\xee\xc2\x7b\xf3\xcd\x85\xd7\x9d\xcd\xe6\x17\xd3\x71\xf7\xc4\x7f\x4d\x2e\x86\xef\xbb\x5f\x28\xf4\x0b\xe4\xd7\x5a\xd7\xaa\x6b\xa5\xd3\xff\xd3\x01\x3b\xcb\x5c\x16\x3d\xb6\xcb\x39\x6a\x4f\x6c\x75\xf0\x5b\x09\x33\xa9\x61\xe5\xac\xfe\xbd\x78\xe4\xde\x3f\xca\xc3\x0f\x9d\x87\xce\x7f\x1a\xd5\x71\x16\xc5\x10\x00\x00")
 
 func prestate_tracerJsBytes() ([]byte, error) {
 	return bindataRead(
@@ -213,11 +213,11 @@ func prestate_tracerJs() (*asset, error) {
 	}
 
 	info := bindataFileInfo{name: "prestate_tracer.js", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
-	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xd4, 0x9, 0xf9, 0x44, 0x13, 0x31, 0x89, 0xf7, 0x35, 0x9a, 0xc6, 0xf0, 0x86, 0x9d, 0xb2, 0xe3, 0x57, 0xe2, 0xc0, 0xde, 0xc9, 0x3a, 0x4c, 0x4a, 0x94, 0x90, 0xa5, 0x92, 0x2f, 0xbf, 0xc0, 0xb8}}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xcc, 0x87, 0xc1, 0x1f, 0xf1, 0x7e, 0x66, 0x14, 0x75, 0xb4, 0x87, 0xe0, 0x27, 0xb7, 0x34, 0xb2, 0x69, 0xb2, 0x31, 0x3f, 0x24, 0x77, 0xeb, 0x67, 0xca, 0xe5, 0x65, 0xd7, 0xee, 0x34, 0x1, 0x6}}
 	return a, nil
 }
 
-var _trigram_tracerJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x94\x4f\x6f\xe3\x36\x10\xc5\xef\xfe\x14\xaf\x27\x27\x88\xd7\x4a\xda\x4b\xe1\xd4\x05\xdc\x6c\xb2\x6b\x20\x6b\x07\xb6\xd2\x45\x10\xe4\x40\x4b\x23\x89\x08\x4d\x0a\xe4\xd0\x5e\x21\xc8\x77\x2f\xa8\x3f\xfe\x13\xb8\xed\xfa\x64\x70\xe6\xfd\xe6\xcd\x70\xc4\x28\xc2\x8d\x29\x2b\x2b\xf3\x82\xf1\xeb\xe5\xd5\xef\x88\x0b\x42\x6e\x3e\x11\x17\x64\xc9\xaf\x31\xf1\x5c\x18\xeb\x7a\x51\x84\xb8\x90\x0e\x99\x54\x04\xe9\x50\x0a\xcb\x30\x19\xf8\x43\xbe\x92\x2b\x2b\x6c\x35\xec\x45\x51\xa3\x39\x19\x0e\x84\xcc\x12\xc1\x99\x8c\xb7\xc2\xd2\x08\x95\xf1\x48\x84\x86\xa5\x54\x3a\xb6\x72\xe5\x99\x20\x19\x42\xa7\x91\xb1\x58\x9b\x54\x66\x55\x40\x4a\x86\xd7\x29\xd9\xba\x34\x93\x5d\xbb\xce\xc7\x97\xd9\x23\xee\xc9\x39\xb2\xf8\x42\x9a\xac\x50\x78\xf0\x2b\x25\x13\xdc\xcb\x84\xb4\x23\x08\x87\x32\x9c\xb8\x82\x52\xac\x6a\x5c\x10\xde\x05\x2b\xcb\xd6\x0a\xee\x8c\xd7\xa9\x60\x69\xf4\x00\x24\x83\x73\x6c\xc8\x3a\x69\x34\x7e\xeb\x4a\xb5\xc0\x01\x8c\x0d\x90\x33\xc1\xa1\x01\x0b\x53\x06\xdd\x39\x84\xae\xa0\x04\xef\xa5\x3f\x31\x90\x7d\xdf\x29\xa4\xae\xcb\x14\xa6\x24\x70\x21\x38\x74\xbd\x95\x4a\x61\x45\xf0\x8e\x32\xaf\x06\x81\xb6\xf2\x8c\xef\xd3\xf8\xeb\xfc\x31\xc6\x64\xf6\x84\xef\x93\xc5\x62\x32\x8b\x9f\xae\xb1\x95\x5c\x18\xcf\xa0\x0d\x35\x28\xb9\x2e\x95\xa4\x14\x5b\x61\xad\xd0\x5c\xc1\x64\x81\xf0\xed\x76\x71\xf3\x75\x32\x8b\x27\x7f\x4d\xef\xa7\xf1\x13\x8c\xc5\xdd\x34\x9e\xdd\x2e\x97\xb8\x9b\x2f\x30\xc1\xc3\x64\x11\x4f\x6f\x1e\xef\x27\x0b\x3c\x3c\x2e\x1e\xe6\xcb\xdb\x21\x96\x14\x5c\x51\xd0\xff\xff\xcc\xb3\xfa\xf6\x2c\x21\x25\x16\x52\xb9\x6e\x12\x4f\xc6\xc3\x15\xc6\xab\x14\x85\xd8\x10\x2c\x25\x24\x37\x94\x42\x20\x31\x65\xf5\xd3\x97\x1a\x58\x42\x19\x9d\xd7\x3d\xff\xeb\x42\x62\x9a\x41\x1b\x1e\xc0\x11\xe1\x8f\x82\xb9\x1c\x45\xd1\x76\xbb\x1d\xe6\xda\x0f\x8d\xcd\x23\xd5\xe0\x5c\xf4\xe7\xb0\xd7\x7b\xeb\x01\x40\x14\xa1\x90\x8e\xc3\xe5\x04\xec\x5a\x94\xb5\x2b\x2b\x73\x2b\xd6\x48\x8c\xd7\x4c\xd6\xd5\xa9\x21\x6f\x84\xb7\xf7\x41\x27\x54\xc2\xf1\xbc\x0c\xd2\xf0\x0f\xa6\x24\x5b\xef\x54\x1d\x6f\x82\x6e\x84\xe7\x7e\x7f\xd0\xef\xbf\x0c\x76\xa7\x9f\xa9\xe4\x62\x84\xcb\xe6\xa4\x65\x39\xa6\x9a\x24\xf5\xc6\xbc\x52\x5a\x8f\x94\x36\x64\x2b\x98\x32\x31\x69\xbb\x22\xc1\xe2\xdf\xdf\x40\x3f\x28\xf1\x4c\x6e\x58\x13\x82\x74\x84\xcc\xeb\x24\x14\x3f\x53\x26\x1f\x20\x5d\x9d\xe3\x6d\xc7\xdf\x08\x8b\x34\x54\xc5\x18\xca\xe4\xc3\x9c\x1a\x13\x67\xe7\xd7\xbb\x1c\x99\xe1\xac\xc9\xf9\x65\x0c\x2e\xa4\x1b\xee\xbc\x9e\xef\x49\xe1\xb7\x0b\xce\x4b\x87\x71\xd7\xdf\xf5\xe9\x9c\xcf\x6d\xd9\x1a\x7d\x9c\x63\x89\xbd\xd5\xfb\xb3\xf7\x23\xbf\xa6\x6c\xcd\x9a\x72\xc8\x66\xc9\x56\xea\xfc\xd0\x6f\xc8\x79\xa5\x0a\xe3\x23\x3f\xcf\x97\x2f\x17\xfd\x4f\xfd\x8b\xa3\xb3\xab\xe6\xcc\x94\xc7\xdd\xd6\x39\xe1\x52\x9f\x5f\xa9\x7a\x39\xd5\xe4\x2e\x78\x71\x71\xca\x26\x29\x47\xf8\x2f\x19\xc6\xb8\x3a\x25\xfc\xe0\xf8\x63\x0f\x57\x07\xc3\xfc\x10\xc0\x18\x5d\x1b\xfb\x3d\xcc\x84\x57\x7c\xb8\x3c\xdb\xa2\x7d\x11\x44\xc2\x5e\xa8\x76\x5f\xc2\xeb\x66\x32\x08\xdd\xad\x54\xd6\x7c\xab\x81\x52\x23\x4e\x2e\xd1\xbe\x8c\x25\x77\xaa\x8e\x50\xaa\xae\xd5\x40\x5d\xf3\xa5\xaf\x88\x34\x24\x87\x0f\x82\x52\x98\x0d\xd9\xf0\xca\xb7\x57\xee\x3a\x62\x90\x65\x52\x0b\xd5\xb1\xdb\x07\x81\xad\x48\xa4\xce\x1b\x6b\x4d\xe8\xc0\x5b\xc2\x3f\x0e\x97\xbb\x61\xee\x27\xbf\x9b\xce\x7b\xef\x9f\x00\x00\x00\xff\xff\xb3\x93\x16\xd5\xfc\x06\x00\x00")
+var _trigram_tracerJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x8d\x54\xdb\x6e\xda\x40\x10\x7d\xe7\x2b\xa6\x4f\x80\x42\x70\x68\x5f\x2a\xd2\x54\xa2\x49\x48\x90\x72\x41\x5c\x1a\xa1\x28\x0f\x8b\x3d\xc6\xab\x2c\xbb\xd6\xee\x1a\x82\xa2\xfc\x7b\x67\x6c\x73\x15\x6d\xc3\x0b\x30\x97\x33\x67\x66\xce\x4e\x10\xc0\xa5\x49\x57\x56\xce\x12\x0f\x5f\xcf\x5a\xdf\x61\x94\x20\xcc\xcc\x69\x42\x96\xd0\x48\x0d\x9d\xcc\x27\xc6\xba\x4a\x10\x90\x4b\x3a\x88\xa5\x42\xa0\xef\x54\x58\x0f\x26\x06\x7f\x10\xaf\xe4\xd4\x0a\xbb\x6a\x52\x42\x91\x73\xd4\xcd\x08\xb1\x45\x04\x67\x62\xbf\x14\x16\xdb\xb0\x32\x19\x84\x42\x83\xc5\x48\x3a\x6f\xe5\x34\xf3\x54\xc8\x83\xd0\x51\x60\x2c\xcc\x4d\x24\xe3\x15\x43\x92\x2d\xd3\x11\xda\xbc\xb4\x47\x3b\x77\x6b\x1e\x37\x0f\x63\xb8\x43\xe7\xc8\x77\x83\x1a\xad\x50\xd0\xcf\xa6\x4a\x86\x70\x27\x43\xd4\x0e\x41\x10\x71\xb6\xb8\x04\x23\x98\xe6\x70\x9c\xd8\x65\x2a\xc3\x92\x0a\x74\x0d\xe1\x0b\x2f\x8d\x6e\x00\x4a\xf2\x5b\x58\xa0\x75\xf4\x1f\xbe\xad\x4b\x95\x80\x0d\x30\x96\x41\x6a\xc2\x73\x03\x16\x4c\xca\x79\x75\x62\xbd\x02\x25\xfc\x36\xf5\x13\x03\xd9\xf6\x1d\x01\x79\xb8\x4c\x62\x52\xea\x31\x21\x74\xea\x7a\x29\x95\x82\x29\x42\xe6\x30\xce\x54\x83\xd1\x28\x18\x9e\x7a\xa3\xdb\xc7\xf1\x08\x3a\x0f\x13\x78\xea\x0c\x06\x9d\x87\xd1\xe4\x9c\x82\x69\x6f\xe4\xc5\x05\x16\x50\x72\x9e\x2a\x49\xc8\xd4\xa2\x15\xda\xaf\xa8\x13\x46\xb8\xbf\x1e\x5c\xde\x52\x4a\xe7\x57\xef\xae\x37\x9a\x50\x3f\xd0\xed\x8d\x1e\xae\x87\x43\xe8\x3e\x0e\xa0\x03\xfd\xce\x60\xd4\xbb\x1c\xdf\x75\x06\xd0\x1f\x0f\xfa\x8f\xc3\xeb\x26\x0c\x91\x59\x21\xe7\xff\x7f\xe6\x71\xbe\x3d\x9a\x6b\x84\x5e\x48\xe5\xd6\x93\x98\xd0\xc2\x1d\x71\x54\x11\x24\x62\x81\xb4\xf8\x10\xe5\x82\x18\x0a\x08\x49\x93\x9f\x5e\x2a\x63\x09\x65\xf4\x2c\xef\xf9\xaf\x82\x84\x5e\x0c\xda\xf8\x06\x38\x22\xff\x23\xf1\x3e\x6d\x07\xc1\x72\xb9\x6c\xce\x74\xd6\x34\x76\x16\xa8\x02\xce\x05\x3f\x9b\x95\xca\x7b\x05\xe8\x43\xc8\xa4\x79\xcf\xcb\x61\xd8\xb9\x48\x73\x56\xf4\x5c\xac\x98\x13\xcb\x4c\xd3\x86\x5d\x1e\xca\x71\x6d\x78\xff\x68\xac\x13\x95\x70\xfe\x31\xe5\x54\xfe\x45\xc2\x20\xea\xac\x8d\xdc\x5f\x38\x5d\x1b\x9e\xab\xd5\x46\xb5\xfa\xd2\xd8\x58\xaf\x30\xf5\x49\x1b\xce\x0a\x4b\x89\xe5\x3c\xe6\x48\x52\x2f\xcc\x2b\x4d\x88\x47\x4a\x8b\x25\xd9\x98\x34\x34\x51\x29\x11\xa6\xf8\xfb\x1e\xf0\x0d\x43\xd2\x10\xcd\x99\xb3\x39\xb5\x0d\x71\xa6\x43\x2e\x5e\x53\x66\xd6\x80\x68\x5a\x87\xf7\x0d\xfe\x42\x58\x5a\x0d\x55\x85\x0b\x20\x77\x73\x86\x05\x89\x5a\xfd\x7c\x13\x23\x63\xa8\x15\x31\x5f\x2e\xa8\x8e\x74\xcd\x0d\xd7\xfa\x16\x89\x3f\x1b\x27\xb5\x47\x80\x65\x7f\xe7\xc7\x63\xae\xca\xb2\x39\xf4\x7e\x8c\x45\x9f\x59\xbd\xb5\x7d\xec\xf1\x35\x69\x49\xd6\xa4\x4d\x6f\x86\xb4\x11\x3d\xdb\xe5\xcb\x31\xaf\xb8\x82\x8b\x3d\x3e\xcf\x67\x2f\x27\xd5\xd3\xea\xc9\x9e\xad\x55\xd8\x4c\xba\xdf\x6d\x1e\xc3\x4b\x7d\x26\x9c\x97\x63\x4d\x6e\x9c\x27\x27\xc7\x68\xa2\x22\xed\xff\x2b\x8d\xc8\xb5\x8e\x25\x1e\x30\x3e\xec\xa1\xb5\x33\xcc\x03\x07\x85\xae\xdb\xd8\xea\x30\x16\x99\xf2\xbb\xe2\x59\x26\xe5\x45\x10\xa1\xcf\xe8\x35\x15\x7a\xe1\xeb\x46\xda\xa6\x03\x5c\x4a\x2a\x2e\xde\x2a\xa3\xe4\x10\x47\x45\xb4\x2d\x63\xd1\x1d\xab\x23\xe8\x64\x71\xad\x02\xd4\x15\x2f\x7d\x8a\xe4\x91\x9e\x1f\x04\xc5\x19\x12\x32\x5f\xf9\x72\xe5\x6e\x8d\xc8\x69\xb1\xd4\xc4\xb0\xc4\x2e\x0f\x82\xb7\x22\xa4\x75\x17\xd4\x0a\xd7\x0e\xb7\xd0\xbf\xed\x8a\xbb\xc0\xdc\x4e\x7e\x33\x9d\x8f\xca\x1f\xb4\x9f\x2a\x5b\xfc\x06\x00\x00")
 
 func trigram_tracerJsBytes() ([]byte, error) {
 	return bindataRead(
@@ -233,11 +233,11 @@ func trigram_tracerJs() (*asset, error) {
 	}
 
 	info := bindataFileInfo{name: "trigram_tracer.js", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
-	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x40, 0x63, 0xe1, 0x42, 0x60, 0x7, 0x1b, 0x79, 0x47, 0x1, 0xa1, 0xbf, 0xc4, 0x66, 0x19, 0x9b, 0x2b, 0x5a, 0x1f, 0x82, 0x3d, 0xcf, 0xee, 0xe7, 0x60, 0x25, 0x2c, 0x4f, 0x13, 0x97, 0xc7, 0x18}}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x14, 0xbc, 0xb4, 0xd, 0x83, 0x0, 0xaa, 0xc2, 0x2, 0x3f, 0xd2, 0x74, 0xe1, 0xe8, 0x5c, 0xb8, 0xdb, 0x85, 0x3f, 0x34, 0xf2, 0xb3, 0x80, 0xe6, 0x27, 0x8f, 0x15, 0x24, 0xa2, 0x2f, 0xa6, 0x7b}}
 	return a, nil
 }
 
-var _unigram_tracerJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x94\x41\x6f\xdb\xc6\x13\xc5\xef\xfa\x14\xef\x68\x23\xfa\x8b\xc9\xbf\x97\x42\x69\x0a\xb0\x86\x9d\x08\x70\x64\x43\xa2\x1b\x18\x45\x0f\x4b\x72\x48\x2e\xba\xda\x21\x76\x67\xa5\x08\x81\xbf\x7b\x31\xa4\x68\xb9\x85\xdb\x86\x27\x41\x3b\xef\x37\x6f\xde\x0e\x99\x65\xb8\xe2\xfe\x18\x6c\xdb\x09\xfe\xff\xf6\xdd\x8f\x28\x3a\x42\xcb\xff\x23\xe9\x28\x50\xda\x21\x4f\xd2\x71\x88\xb3\x2c\x43\xd1\xd9\x88\xc6\x3a\x82\x8d\xe8\x4d\x10\x70\x03\xf9\x5b\xbd\xb3\x65\x30\xe1\xb8\x98\x65\xd9\xa8\x79\xf5\x58\x09\x4d\x20\x42\xe4\x46\x0e\x26\xd0\x12\x47\x4e\xa8\x8c\x47\xa0\xda\x46\x09\xb6\x4c\x42\xb0\x02\xe3\xeb\x8c\x03\x76\x5c\xdb\xe6\xa8\x48\x2b\x48\xbe\xa6\x30\xb4\x16\x0a\xbb\x38\xf9\xf8\xb8\x7e\xc0\x2d\xc5\x48\x01\x1f\xc9\x53\x30\x0e\xf7\xa9\x74\xb6\xc2\xad\xad\xc8\x47\x82\x89\xe8\xf5\x9f\xd8\x51\x8d\x72\xc0\xa9\xf0\x46\xad\x6c\x4f\x56\x70\xc3\xc9\xd7\x46\x2c\xfb\x39\xc8\xaa\x73\xec\x29\x44\xcb\x1e\x3f\x4c\xad\x4e\xc0\x39\x38\x28\xe4\xc2\x88\x0e\x10\xc0\xbd\xea\x2e\x61\xfc\x11\xce\xc8\x59\xfa\x1d\x81\x9c\xe7\xae\x61\xfd\xd0\xa6\xe3\x9e\x20\x9d\x11\x9d\xfa\x60\x9d\x43\x49\x48\x91\x9a\xe4\xe6\x4a\x2b\x93\xe0\xcb\xaa\xf8\x74\xf7\x50\x20\x5f\x3f\xe2\x4b\xbe\xd9\xe4\xeb\xe2\xf1\x3d\x0e\x56\x3a\x4e\x02\xda\xd3\x88\xb2\xbb\xde\x59\xaa\x71\x30\x21\x18\x2f\x47\x70\xa3\x84\xcf\xd7\x9b\xab\x4f\xf9\xba\xc8\x7f\x59\xdd\xae\x8a\x47\x70\xc0\xcd\xaa\x58\x5f\x6f\xb7\xb8\xb9\xdb\x20\xc7\x7d\xbe\x29\x56\x57\x0f\xb7\xf9\x06\xf7\x0f\x9b\xfb\xbb\xed\xf5\x02\x5b\x52\x57\xa4\xfa\xff\xce\xbc\x19\x6e\x2f\x10\x6a\x12\x63\x5d\x9c\x92\x78\xe4\x84\xd8\x71\x72\x35\x3a\xb3\x27\x04\xaa\xc8\xee\xa9\x86\x41\xc5\xfd\xf1\xbb\x2f\x55\x59\xc6\xb1\x6f\x87\x99\xff\x71\x21\xb1\x6a\xe0\x59\xe6\x88\x44\xf8\xa9\x13\xe9\x97\x59\x76\x38\x1c\x16\xad\x4f\x0b\x0e\x6d\xe6\x46\x5c\xcc\x7e\x5e\xcc\x66\xdf\x66\x00\x90\x65\xe8\x6c\x14\xbd\x1c\xc5\xee\x4c\xaf\xae\xb8\xaf\xb8\xa6\x08\x61\x54\x9c\xbc\x50\x88\x43\xb5\x96\x2e\xf1\xed\x69\x3e\x69\x3d\xf7\x71\x2c\x89\xf0\x69\x57\x52\x18\xe5\x63\xb9\x9e\x2e\xf1\xf6\xb9\x3a\x0a\xf5\xda\xc9\xfa\x3d\xff\x41\xf5\x90\x1b\xed\x29\x1c\x4f\x0d\xc7\x3d\x50\x1f\xbf\x7e\x06\x7d\xa5\x2a\x09\xc5\xc5\xa0\x56\xe9\x12\x4d\xf2\x95\x6e\xdf\x85\xe3\x76\x8e\xba\xbc\xc4\x38\x85\x3e\x7b\xa3\xbb\x89\x0f\x70\xdc\x2e\xb8\x5f\x08\x6f\x25\x58\xdf\x5e\x5c\xbe\x7f\xae\xb1\x0d\x2e\xa4\xb3\x71\xa1\x83\xfc\xc6\xfd\xef\x97\x67\xbd\x3e\x7f\x39\x7b\xf3\xe6\x2c\x7c\x7a\xfe\x45\x2e\x12\xfe\x45\x85\x0f\x78\xf7\x9a\x6e\x28\xd2\x40\x26\xec\x39\xc4\xc6\x24\x27\x2f\x73\x39\x74\xa7\x8d\x36\x95\x24\xe3\x4e\x51\xe8\xdb\xc9\x0d\x8c\x9f\xd2\x6a\xc6\x5d\x53\xca\x80\x78\x35\x9f\xa7\xf9\x6c\xea\x13\x28\xbe\xd6\xc8\x38\x37\x34\x9b\x2e\x7d\x58\xd5\x92\xc8\xc3\x0a\x05\xa3\xef\x2a\xef\x29\xe8\x67\x0a\x81\x24\x05\x1f\x27\xa2\xca\x1a\xeb\x8d\x9b\xd8\xa7\x8d\x96\x60\x2a\xeb\xdb\xd1\xdb\x78\xf4\xc2\x5c\x25\x5f\x5f\x5e\xdc\xc8\x3c\xa7\xf8\x1c\xcf\xd3\xec\xcf\x00\x00\x00\xff\xff\xf1\x91\x30\xae\xbd\x05\x00\x00")
+var _unigram_tracerJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x8d\x54\xc1\x6e\xda\x40\x10\xbd\xf3\x15\x73\x04\x85\xda\x49\x7b\xa9\x48\x5b\xc9\x8d\x42\x82\x44\x08\x02\xd3\x08\x55\x3d\xac\xed\x31\x5e\x75\xd9\xb5\x76\xd7\x10\x14\xf1\xef\x9d\xb1\x31\xa4\x15\x6d\xe3\x0b\x78\x67\xde\x9b\x37\x6f\x66\x1d\x86\x70\x63\xca\x9d\x95\xab\xc2\xc3\xfb\xcb\xab\x8f\x10\x17\x08\x2b\xf3\xae\xa0\x93\xd4\x48\x0d\x51\xe5\x0b\x63\x5d\x27\x0c\x29\x24\x1d\xe4\x52\x21\xd0\x6f\x29\xac\x07\x93\x83\xff\x23\x5f\xc9\xc4\x0a\xbb\x0b\x08\xd0\x60\xce\x86\x99\x21\xb7\x88\xe0\x4c\xee\xb7\xc2\xe2\x00\x76\xa6\x82\x54\x68\xb0\x98\x49\xe7\xad\x4c\x2a\x4f\x85\x3c\x08\x9d\x85\xc6\xc2\xda\x64\x32\xdf\x31\x25\x9d\x55\x3a\x43\x5b\x97\xf6\x68\xd7\xae\xd5\x71\x37\x59\xc0\x18\x9d\xa3\xd8\x1d\x6a\xb4\x42\xc1\xb4\x4a\x94\x4c\x61\x2c\x53\xd4\x0e\x41\x90\x70\x3e\x71\x05\x66\x90\xd4\x74\x0c\x1c\xb2\x94\xf9\x41\x0a\x0c\x0d\xf1\x0b\x2f\x8d\xee\x03\x4a\x8a\x5b\xd8\xa0\x75\xf4\x0e\x1f\xda\x52\x07\xc2\x3e\x18\xcb\x24\x5d\xe1\xb9\x01\x0b\xa6\x64\x5c\x8f\x54\xef\x40\x09\x7f\x82\xbe\xc1\x90\x53\xdf\x19\x50\x84\xcb\x14\xa6\xa4\x1e\x0b\x62\xa7\xae\xb7\x52\x29\x48\x10\x2a\x87\x79\xa5\xfa\xcc\x46\xc9\xf0\x34\x8a\xef\x1f\x17\x31\x44\x93\x25\x3c\x45\xb3\x59\x34\x89\x97\xd7\x94\x4c\x73\xa3\x28\x6e\xb0\xa1\x92\xeb\x52\x49\x62\xa6\x16\xad\xd0\x7e\x47\x9d\x30\xc3\xc3\xed\xec\xe6\x9e\x20\xd1\xd7\xd1\x78\x14\x2f\xa9\x1f\x18\x8e\xe2\xc9\xed\x7c\x0e\xc3\xc7\x19\x44\x30\x8d\x66\xf1\xe8\x66\x31\x8e\x66\x30\x5d\xcc\xa6\x8f\xf3\xdb\x00\xe6\xc8\xaa\x90\xf1\xff\xf7\x3c\xaf\xa7\x47\xbe\x66\xe8\x85\x54\xae\x75\x62\x49\x03\x77\xa4\x51\x65\x50\x88\x0d\xd2\xe0\x53\x94\x1b\x52\x28\x20\xa5\x9d\x7c\xf3\x50\x99\x4b\x28\xa3\x57\x75\xcf\x7f\x5d\x48\x18\xe5\xa0\x8d\xef\x83\x23\xf1\x9f\x0a\xef\xcb\x41\x18\x6e\xb7\xdb\x60\xa5\xab\xc0\xd8\x55\xa8\x1a\x3a\x17\x7e\x09\x3a\x9d\x97\x0e\xd0\x43\xcc\xb4\xf3\x9e\x87\xc3\xb4\x6b\x51\xb2\x2a\x53\xa6\x26\x43\x3a\x32\x24\xb4\xd2\x34\x64\x57\x67\x73\xea\x00\x5e\xf6\xfd\x16\xab\x4d\xe9\x9a\x14\x07\xba\x5a\x27\xd4\x42\x0d\x6f\xd2\x39\x3a\x80\xcb\x63\xb6\xf3\x58\x72\x25\xa9\x37\xe6\x27\xd9\xc0\xbe\xd1\xf4\x68\x37\x9a\x82\xcd\x1e\xb0\x8e\x6f\x0f\x80\xcf\x98\xd2\xa2\x90\x99\x8c\x66\xe8\x00\xf2\x4a\xa7\xbc\x7d\x5d\x65\x56\x7d\xc8\x92\x1e\x34\x5d\xf0\xb3\x11\xbc\x9b\xf0\x19\x28\x16\x98\x32\xf0\x66\x4e\xbb\xa6\x57\xdd\xde\xf5\x31\x47\xe6\xd0\xf5\xd4\x44\xc0\x8d\x7c\x37\xe5\x8f\xde\x09\xcf\xcf\x6f\xb1\x8b\x8b\x13\x70\x7f\xfc\x87\x8a\x06\xfe\x0f\x14\x09\xb8\x3a\x87\xab\x93\xd8\x90\x96\xf6\x64\x62\x2e\x2a\xe5\x5f\xfb\xb2\x2d\x0e\x1b\x2d\x52\x5f\xd1\x36\x34\x56\xf0\xed\x24\x73\xe9\x03\x72\x70\x2b\x6f\x76\x8d\x59\x6a\x8a\xb3\xfe\x50\x99\xb6\x8e\x45\x77\xae\x90\xa0\x3b\xc7\xc5\xda\xa1\xd7\xab\x9a\x20\x45\x24\x0d\x5e\xf0\x5d\x35\x34\x24\xfe\x4c\x11\x85\xaf\xac\x76\x2d\x23\xc3\x72\xa9\x49\xe2\x81\xfb\xb0\xd1\xde\x8a\x94\xac\x6f\xb4\x35\xa1\x57\xe2\x52\xff\xfc\x7a\x70\x0d\xe7\xc9\xc5\xa3\x3d\xfb\xce\x2f\xcf\xb8\x9f\x8e\xbd\x05\x00\x00")
 
 func unigram_tracerJsBytes() ([]byte, error) {
 	return bindataRead(
@@ -253,7 +253,7 @@ func unigram_tracerJs() (*asset, error) {
 	}
 
 	info := bindataFileInfo{name: "unigram_tracer.js", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
-	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xc, 0xe6, 0x5c, 0x88, 0x18, 0xa7, 0x85, 0x61, 0x18, 0xc6, 0xec, 0x17, 0xfc, 0xdf, 0x9d, 0xc0, 0x1b, 0x49, 0xf8, 0x8d, 0xf1, 0xeb, 0x35, 0xf3, 0xd, 0x3e, 0xf6, 0xa3, 0xac, 0x8c, 0xba, 0x74}}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xae, 0x9c, 0x49, 0x70, 0x53, 0x3d, 0x2a, 0x6e, 0x9d, 0x39, 0x9b, 0xba, 0x5, 0x42, 0xf2, 0xa4, 0x37, 0xb8, 0x1a, 0xeb, 0x45, 0xe8, 0xa7, 0x3f, 0x5a, 0xaa, 0xb0, 0x1e, 0x5b, 0x24, 0xff, 0x31}}
 	return a, nil
 }
 
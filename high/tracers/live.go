@@ -0,0 +1,465 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/common/hexutil"
+	"github.com/420integrated/go-highcoin/core"
+	"github.com/420integrated/go-highcoin/core/tracing"
+	"github.com/420integrated/go-highcoin/core/types"
+	"github.com/420integrated/go-highcoin/core/vm"
+	"github.com/420integrated/go-highcoin/event"
+	"github.com/420integrated/go-highcoin/log"
+)
+
+// liveQueueSize is the number of encoded events the JSON live tracer buffers for
+// its subscribers. Events emitted while the queue is full are dropped.
+const liveQueueSize = 4096
+
+// LiveTracer is a block tracer attached to the chain, tracing the blocks during
+// their import instead of re-executing them afterwards.
+type LiveTracer interface {
+	core.BlockTracer
+
+	// SubscribeTrace subscribes to the JSON encoded trace events produced by the
+	// tracer, as they are produced.
+	SubscribeTrace(ch chan<- json.RawMessage) event.Subscription
+
+	// Close flushes the outputs of the tracer and releases them.
+	Close() error
+}
+
+// LiveConstructor creates a new live tracer from its JSON encoded configuration,
+// which may be empty.
+type LiveConstructor func(config json.RawMessage) (LiveTracer, error)
+
+// live contains all the registered live tracers by name.
+var live = map[string]LiveConstructor{
+	"json": newJSONLiveTracer,
+}
+
+// RegisterLive makes a live tracer available under the given name.
+func RegisterLive(name string, ctor LiveConstructor) {
+	live[name] = ctor
+}
+
+// LiveTracers returns the names of the registered live tracers.
+func LiveTracers() []string {
+	names := make([]string, 0, len(live))
+	for name := range live {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewLiveTracer instantiates the live tracer registered with the given name. If
+// there is none, the name is looked up as a transaction tracer instead, either a
+// native Go or a JavaScript one, which is run over every imported transaction.
+func NewLiveTracer(name string, config json.RawMessage) (LiveTracer, error) {
+	if ctor, ok := live[name]; ok {
+		return ctor(config)
+	}
+	if _, err := NewTracer(name, vm.TxContext{}); err != nil {
+		return nil, fmt.Errorf("unknown live tracer %q, available: %s or a transaction tracer", name, strings.Join(LiveTracers(), ", "))
+	}
+	return newTxLiveTracer(name, config)
+}
+
+// liveEvent is a single event emitted by the JSON live tracer. Only the fields
+// relevant to the kind of the event are set.
+type liveEvent struct {
+	Event      string          `json:"event"`
+	Number     *hexutil.Uint64 `json:"number,omitempty"`
+	Hash       *common.Hash    `json:"hash,omitempty"`
+	ParentHash *common.Hash    `json:"parentHash,omitempty"`
+	Dropped    []common.Hash   `json:"dropped,omitempty"`
+	Added      []common.Hash   `json:"added,omitempty"`
+	From       *common.Address `json:"from,omitempty"`
+	To         *common.Address `json:"to,omitempty"`
+	Smoke      *hexutil.Uint64 `json:"smoke,omitempty"`
+	SmokeUsed  *hexutil.Uint64 `json:"smokeUsed,omitempty"`
+	Value      *hexutil.Big    `json:"value,omitempty"`
+	Address    *common.Address `json:"address,omitempty"`
+	Slot       *common.Hash    `json:"slot,omitempty"`
+	Prev       interface{}     `json:"prev,omitempty"`
+	New        interface{}     `json:"new,omitempty"`
+	Reason     string          `json:"reason,omitempty"`
+	Code       hexutil.Bytes   `json:"code,omitempty"`
+	Log        *types.Log      `json:"log,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+}
+
+// jsonLiveTracerConfig is the configuration of the JSON live tracer.
+type jsonLiveTracerConfig struct {
+	Path string `json:"path"` // File to append the events to as JSON lines, none if empty
+}
+
+// jsonLiveTracer is a live tracer emitting every block, transaction and state
+// change as a JSON event, both into a JSON lines file and to the subscribers of
+// its feed.
+//
+// The events are handed to the subscribers by a separate goroutine through a
+// bounded queue, so a slow subscriber can't stall the block import. Subscribers
+// not keeping up lose the events emitted while the queue is full.
+type jsonLiveTracer struct {
+	dropped uint64 // Number of events dropped since the last report (atomic)
+
+	file *os.File
+	out  *bufio.Writer
+	lock sync.Mutex // Protects the output file
+
+	feed  event.Feed
+	queue chan json.RawMessage // Events waiting to be sent to the subscribers
+	quit  chan struct{}        // Channel to stop the delivery loop
+	once  sync.Once            // Ensures the delivery loop is stopped only once
+}
+
+// newJSONLiveTracer creates a JSON live tracer, opening its output file if one
+// is configured.
+func newJSONLiveTracer(config json.RawMessage) (LiveTracer, error) {
+	var cfg jsonLiveTracerConfig
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid json tracer config: %v", err)
+		}
+	}
+	tracer := &jsonLiveTracer{
+		queue: make(chan json.RawMessage, liveQueueSize),
+		quit:  make(chan struct{}),
+	}
+	if cfg.Path != "" {
+		file, err := os.OpenFile(cfg.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		tracer.file, tracer.out = file, bufio.NewWriter(file)
+	}
+	go tracer.loop()
+	return tracer, nil
+}
+
+// loop sends the queued events to the subscribers of the feed until the tracer
+// is closed, delivering the events queued before closing.
+func (t *jsonLiveTracer) loop() {
+	for {
+		select {
+		case blob := <-t.queue:
+			t.send(blob)
+		case <-t.quit:
+			for {
+				select {
+				case blob := <-t.queue:
+					t.send(blob)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// send hands a single event to the subscribers, reporting the events dropped
+// since the previous one.
+func (t *jsonLiveTracer) send(blob json.RawMessage) {
+	if dropped := atomic.SwapUint64(&t.dropped, 0); dropped > 0 {
+		log.Warn("Live trace subscribers too slow, dropped events", "count", dropped)
+	}
+	t.feed.Send(blob)
+}
+
+// emit encodes an event, writes it into the output file and queues it for the
+// subscribers, dropping it if the queue is full.
+func (t *jsonLiveTracer) emit(ev *liveEvent) {
+	blob, err := json.Marshal(ev)
+	if err != nil {
+		return // Can't happen, all fields are encodable
+	}
+	t.lock.Lock()
+	if t.out != nil {
+		t.out.Write(blob)
+		t.out.WriteByte('\n')
+	}
+	t.lock.Unlock()
+
+	select {
+	case t.queue <- json.RawMessage(blob):
+	default:
+		atomic.AddUint64(&t.dropped, 1)
+	}
+}
+
+// flush writes out the buffered events into the output file.
+func (t *jsonLiveTracer) flush() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.out == nil {
+		return nil
+	}
+	return t.out.Flush()
+}
+
+// SubscribeTrace implements LiveTracer.
+func (t *jsonLiveTracer) SubscribeTrace(ch chan<- json.RawMessage) event.Subscription {
+	return t.feed.Subscribe(ch)
+}
+
+// Close implements LiveTracer. The events already queued are still delivered to
+// the subscribers in the background.
+func (t *jsonLiveTracer) Close() error {
+	t.once.Do(func() { close(t.quit) })
+
+	if err := t.flush(); err != nil {
+		return err
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.file == nil {
+		return nil
+	}
+	err := t.file.Close()
+	t.file, t.out = nil, nil
+	return err
+}
+
+// CaptureBlockStart implements core.BlockTracer.
+func (t *jsonLiveTracer) CaptureBlockStart(block *types.Block) {
+	number, hash, parent := hexutil.Uint64(block.NumberU64()), block.Hash(), block.ParentHash()
+	t.emit(&liveEvent{Event: "blockStart", Number: &number, Hash: &hash, ParentHash: &parent})
+}
+
+// CaptureBlockEnd implements core.BlockTracer, flushing the events of the block.
+func (t *jsonLiveTracer) CaptureBlockEnd(err error) {
+	t.emit(&liveEvent{Event: "blockEnd", Error: errString(err)})
+	t.flush()
+}
+
+// CaptureReorg implements core.BlockTracer.
+func (t *jsonLiveTracer) CaptureReorg(dropped, added types.Blocks) {
+	ev := &liveEvent{Event: "reorg"}
+	for _, block := range dropped {
+		ev.Dropped = append(ev.Dropped, block.Hash())
+	}
+	for _, block := range added {
+		ev.Added = append(ev.Added, block.Hash())
+	}
+	t.emit(ev)
+	t.flush()
+}
+
+// CaptureTxStart implements vm.StateTracer.
+func (t *jsonLiveTracer) CaptureTxStart(env *vm.EVM, from common.Address, to *common.Address, smoke uint64, value *big.Int) {
+	limit := hexutil.Uint64(smoke)
+	t.emit(&liveEvent{Event: "txStart", From: &from, To: to, Smoke: &limit, Value: (*hexutil.Big)(value)})
+}
+
+// CaptureTxEnd implements vm.StateTracer.
+func (t *jsonLiveTracer) CaptureTxEnd(smokeUsed uint64, err error) {
+	used := hexutil.Uint64(smokeUsed)
+	t.emit(&liveEvent{Event: "txEnd", SmokeUsed: &used, Error: errString(err)})
+}
+
+// OnBalanceChange implements tracing.StateHooks.
+func (t *jsonLiveTracer) OnBalanceChange(addr common.Address, prev, new *big.Int, reason tracing.BalanceChangeReason) {
+	t.emit(&liveEvent{Event: "balance", Address: &addr, Prev: (*hexutil.Big)(prev), New: (*hexutil.Big)(new), Reason: reason.String()})
+}
+
+// OnNonceChange implements tracing.StateHooks.
+func (t *jsonLiveTracer) OnNonceChange(addr common.Address, prev, new uint64) {
+	t.emit(&liveEvent{Event: "nonce", Address: &addr, Prev: hexutil.Uint64(prev), New: hexutil.Uint64(new)})
+}
+
+// OnCodeChange implements tracing.StateHooks.
+func (t *jsonLiveTracer) OnCodeChange(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte) {
+	t.emit(&liveEvent{Event: "code", Address: &addr, Prev: prevCodeHash, New: codeHash, Code: code})
+}
+
+// OnStorageChange implements tracing.StateHooks.
+func (t *jsonLiveTracer) OnStorageChange(addr common.Address, slot common.Hash, prev, new common.Hash) {
+	t.emit(&liveEvent{Event: "storage", Address: &addr, Slot: &slot, Prev: prev, New: new})
+}
+
+// OnLog implements tracing.StateHooks.
+func (t *jsonLiveTracer) OnLog(log *types.Log) {
+	t.emit(&liveEvent{Event: "log", Log: log})
+}
+
+// CaptureStart implements vm.Tracer, the call frames are not traced.
+func (t *jsonLiveTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, smoke uint64, value *big.Int) error {
+	return nil
+}
+
+// CaptureState implements vm.Tracer, the opcodes are not traced.
+func (t *jsonLiveTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, smoke, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, rData []byte, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureFault implements vm.Tracer.
+func (t *jsonLiveTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, smoke, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnd implements vm.Tracer.
+func (t *jsonLiveTracer) CaptureEnd(output []byte, smokeUsed uint64, duration time.Duration, err error) error {
+	return nil
+}
+
+// errString returns the message of an error, or empty for nil.
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// txLiveTracer is a live tracer running a transaction tracer over every imported
+// transaction, emitting the results along with the block events in the format
+// of the JSON live tracer.
+type txLiveTracer struct {
+	name   string          // Name of the transaction tracer to run
+	out    *jsonLiveTracer // JSON live tracer delivering the events
+	tracer ResultTracer    // Tracer of the transaction being executed
+	from   common.Address  // Sender of the transaction being executed
+	to     *common.Address // Recipient of the transaction being executed
+}
+
+// newTxLiveTracer creates a live tracer running the named transaction tracer,
+// configured the same way as the JSON live tracer.
+func newTxLiveTracer(name string, config json.RawMessage) (LiveTracer, error) {
+	out, err := newJSONLiveTracer(config)
+	if err != nil {
+		return nil, err
+	}
+	return &txLiveTracer{name: name, out: out.(*jsonLiveTracer)}, nil
+}
+
+// SubscribeTrace implements LiveTracer.
+func (t *txLiveTracer) SubscribeTrace(ch chan<- json.RawMessage) event.Subscription {
+	return t.out.SubscribeTrace(ch)
+}
+
+// Close implements LiveTracer.
+func (t *txLiveTracer) Close() error {
+	return t.out.Close()
+}
+
+// CaptureBlockStart implements core.BlockTracer.
+func (t *txLiveTracer) CaptureBlockStart(block *types.Block) {
+	t.out.CaptureBlockStart(block)
+}
+
+// CaptureBlockEnd implements core.BlockTracer.
+func (t *txLiveTracer) CaptureBlockEnd(err error) {
+	t.out.CaptureBlockEnd(err)
+}
+
+// CaptureReorg implements core.BlockTracer.
+func (t *txLiveTracer) CaptureReorg(dropped, added types.Blocks) {
+	t.out.CaptureReorg(dropped, added)
+}
+
+// CaptureTxStart implements vm.StateTracer, creating a new transaction tracer.
+func (t *txLiveTracer) CaptureTxStart(env *vm.EVM, from common.Address, to *common.Address, smoke uint64, value *big.Int) {
+	tracer, err := NewTracer(t.name, env.TxContext)
+	if err != nil {
+		log.Warn("Failed to create live transaction tracer", "tracer", t.name, "err", err)
+		return
+	}
+	t.tracer, t.from, t.to = tracer, from, to
+}
+
+// CaptureTxEnd implements vm.StateTracer, emitting the result of the transaction
+// tracer.
+func (t *txLiveTracer) CaptureTxEnd(smokeUsed uint64, err error) {
+	if t.tracer == nil {
+		return
+	}
+	used := hexutil.Uint64(smokeUsed)
+	ev := &liveEvent{Event: "txResult", From: &t.from, To: t.to, SmokeUsed: &used, Error: errString(err)}
+	if result, err := t.tracer.GetResult(); err != nil {
+		ev.Error = err.Error()
+	} else {
+		ev.Result = result
+	}
+	t.out.emit(ev)
+	t.tracer = nil
+}
+
+// OnBalanceChange implements tracing.StateHooks, the state changes are not traced.
+func (t *txLiveTracer) OnBalanceChange(addr common.Address, prev, new *big.Int, reason tracing.BalanceChangeReason) {
+}
+
+// OnNonceChange implements tracing.StateHooks.
+func (t *txLiveTracer) OnNonceChange(addr common.Address, prev, new uint64) {}
+
+// OnCodeChange implements tracing.StateHooks.
+func (t *txLiveTracer) OnCodeChange(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte) {
+}
+
+// OnStorageChange implements tracing.StateHooks.
+func (t *txLiveTracer) OnStorageChange(addr common.Address, slot common.Hash, prev, new common.Hash) {
+}
+
+// OnLog implements tracing.StateHooks.
+func (t *txLiveTracer) OnLog(log *types.Log) {}
+
+// CaptureStart implements vm.Tracer.
+func (t *txLiveTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, smoke uint64, value *big.Int) error {
+	if t.tracer == nil {
+		return nil
+	}
+	return t.tracer.CaptureStart(from, to, create, input, smoke, value)
+}
+
+// CaptureState implements vm.Tracer.
+func (t *txLiveTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, smoke, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, rData []byte, contract *vm.Contract, depth int, err error) error {
+	if t.tracer == nil {
+		return nil
+	}
+	return t.tracer.CaptureState(env, pc, op, smoke, cost, memory, stack, rStack, rData, contract, depth, err)
+}
+
+// CaptureFault implements vm.Tracer.
+func (t *txLiveTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, smoke, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, contract *vm.Contract, depth int, err error) error {
+	if t.tracer == nil {
+		return nil
+	}
+	return t.tracer.CaptureFault(env, pc, op, smoke, cost, memory, stack, rStack, contract, depth, err)
+}
+
+// CaptureEnd implements vm.Tracer.
+func (t *txLiveTracer) CaptureEnd(output []byte, smokeUsed uint64, duration time.Duration, err error) error {
+	if t.tracer == nil {
+		return nil
+	}
+	return t.tracer.CaptureEnd(output, smokeUsed, duration, err)
+}
@@ -0,0 +1,342 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/consensus/ethash"
+	"github.com/420integrated/go-highcoin/core"
+	"github.com/420integrated/go-highcoin/core/rawdb"
+	"github.com/420integrated/go-highcoin/core/types"
+	"github.com/420integrated/go-highcoin/core/vm"
+	"github.com/420integrated/go-highcoin/crypto"
+	"github.com/420integrated/go-highcoin/params"
+)
+
+// Tests that the JSON live tracer attached to the chain emits the events of the
+// imported blocks and reorgs, both into its output file and to its subscribers.
+func TestJSONLiveTracer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "trace.jsonl")
+	tracer, err := NewLiveTracer("json", json.RawMessage(fmt.Sprintf(`{"path":%q}`, path)))
+	if err != nil {
+		t.Fatalf("failed to create live tracer: %v", err)
+	}
+	events := make(chan json.RawMessage, 1024)
+	sub := tracer.SubscribeTrace(events)
+	defer sub.Unsubscribe()
+
+	var (
+		key, _  = crypto.GenerateKey()
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		signer  = types.HomesteadSigner{}
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &core.Genesis{Config: params.TestChainConfig, Alloc: core.GenesisAlloc{addr: {Balance: big.NewInt(1000000000000000)}}}
+		genesis = gspec.MustCommit(db)
+	)
+	chaindb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(chaindb)
+
+	chain, err := core.NewBlockChain(chaindb, nil, gspec.Config, ethash.NewFaker(), vm.Config{Debug: true, Tracer: tracer}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// Import a chain with a transfer in every block, then reorg it with a longer
+	// empty one of higher difficulty
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 2, func(i int, gen *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(addr), common.Address{0x01}, big.NewInt(1000), params.TxSmoke, big.NewInt(1), nil), signer, key)
+		gen.AddTx(tx)
+	})
+	forks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 3, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(common.Address{0x02})
+		gen.OffsetTime(-9)
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	if _, err := chain.InsertChain(forks); err != nil {
+		t.Fatalf("failed to insert fork: %v", err)
+	}
+	if err := tracer.Close(); err != nil {
+		t.Fatalf("failed to close tracer: %v", err)
+	}
+	// Check the events written into the output file
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open trace: %v", err)
+	}
+	defer file.Close()
+
+	var (
+		lines   int
+		kinds   = make(map[string]int)
+		reorgs  []liveEvent
+		scanner = bufio.NewScanner(file)
+	)
+	for ; scanner.Scan(); lines++ {
+		var ev liveEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("line %d: invalid event: %v", lines, err)
+		}
+		if ev.Event == "reorg" {
+			reorgs = append(reorgs, ev)
+		}
+		kinds[ev.Event]++
+
+		select {
+		case blob := <-events:
+			if string(blob) != scanner.Text() {
+				t.Errorf("line %d: subscribed event mismatch: have %s, want %s", lines, blob, scanner.Text())
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("line %d: event not delivered to subscriber", lines)
+		}
+	}
+	if kinds["blockStart"] != len(blocks)+len(forks) || kinds["blockEnd"] != kinds["blockStart"] {
+		t.Errorf("block event count mismatch: have %d starts, %d ends, want %d", kinds["blockStart"], kinds["blockEnd"], len(blocks)+len(forks))
+	}
+	if kinds["txStart"] != len(blocks) || kinds["txEnd"] != len(blocks) || kinds["nonce"] != len(blocks) {
+		t.Errorf("transaction event count mismatch: %v", kinds)
+	}
+	if kinds["balance"] == 0 {
+		t.Errorf("no balance changes reported")
+	}
+	if len(reorgs) != 1 {
+		t.Fatalf("reorg count mismatch: have %d, want 1", len(reorgs))
+	}
+	if len(reorgs[0].Dropped) != len(blocks) || reorgs[0].Dropped[0] != blocks[1].Hash() {
+		t.Errorf("dropped blocks mismatch: %v", reorgs[0].Dropped)
+	}
+	// The reorg happens as soon as the fork is heavier than the chain
+	if added := reorgs[0].Added; len(added) != len(blocks) || added[0] != forks[1].Hash() || added[1] != forks[0].Hash() {
+		t.Errorf("added blocks mismatch: %v", added)
+	}
+}
+
+// Tests that a subscriber not reading its events doesn't stall the import of the
+// chain, only losing the events not fitting into the queue.
+func TestJSONLiveTracerStuckSubscriber(t *testing.T) {
+	tracer, err := NewLiveTracer("json", nil)
+	if err != nil {
+		t.Fatalf("failed to create live tracer: %v", err)
+	}
+	defer tracer.Close()
+
+	events := make(chan json.RawMessage)
+	sub := tracer.SubscribeTrace(events)
+	defer sub.Unsubscribe()
+
+	var (
+		key, _  = crypto.GenerateKey()
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		signer  = types.HomesteadSigner{}
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &core.Genesis{Config: params.TestChainConfig, Alloc: core.GenesisAlloc{addr: {Balance: big.NewInt(1000000000000000)}}}
+		genesis = gspec.MustCommit(db)
+	)
+	chaindb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(chaindb)
+
+	chain, err := core.NewBlockChain(chaindb, nil, gspec.Config, ethash.NewFaker(), vm.Config{Debug: true, Tracer: tracer}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// Every block emits several events, import enough of them to overflow the queue
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, liveQueueSize/4, func(i int, gen *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(addr), common.Address{0x01}, big.NewInt(1000), params.TxSmoke, big.NewInt(1), nil), signer, key)
+		gen.AddTx(tx)
+	})
+	errc := make(chan error, 1)
+	go func() {
+		_, err := chain.InsertChain(blocks)
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatalf("failed to insert chain: %v", err)
+		}
+	case <-time.After(time.Minute):
+		t.Fatalf("chain import stalled by subscriber")
+	}
+	if head := chain.CurrentBlock().NumberU64(); head != uint64(len(blocks)) {
+		t.Fatalf("head mismatch: have #%d, want #%d", head, len(blocks))
+	}
+	// A single event is stuck in the delivery, the queue is full and the rest dropped
+	live := tracer.(*jsonLiveTracer)
+	if queued := len(live.queue); queued != liveQueueSize {
+		t.Errorf("queued event count mismatch: have %d, want %d", queued, liveQueueSize)
+	}
+	if dropped := atomic.LoadUint64(&live.dropped); dropped == 0 {
+		t.Errorf("no events dropped")
+	}
+}
+
+// Tests that transaction tracers, native or JavaScript, can be attached to the
+// chain as live tracers, emitting their results for every imported transaction.
+func TestTxLiveTracer(t *testing.T) {
+	if _, err := NewLiveTracer("noopTracerDoesNotExist", nil); err == nil {
+		t.Fatalf("unknown tracer accepted")
+	}
+	for _, name := range []string{"callTracer", "4byteTracer"} {
+		tracer, err := NewLiveTracer(name, nil)
+		if err != nil {
+			t.Fatalf("%s: failed to create live tracer: %v", name, err)
+		}
+		events := make(chan json.RawMessage, 1024)
+		sub := tracer.SubscribeTrace(events)
+
+		var (
+			key, _  = crypto.GenerateKey()
+			addr    = crypto.PubkeyToAddress(key.PublicKey)
+			signer  = types.HomesteadSigner{}
+			db      = rawdb.NewMemoryDatabase()
+			gspec   = &core.Genesis{Config: params.TestChainConfig, Alloc: core.GenesisAlloc{addr: {Balance: big.NewInt(1000000000000000)}}}
+			genesis = gspec.MustCommit(db)
+		)
+		chaindb := rawdb.NewMemoryDatabase()
+		gspec.MustCommit(chaindb)
+
+		chain, err := core.NewBlockChain(chaindb, nil, gspec.Config, ethash.NewFaker(), vm.Config{Debug: true, Tracer: tracer}, nil, nil)
+		if err != nil {
+			t.Fatalf("%s: failed to create chain: %v", name, err)
+		}
+		blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 2, func(i int, gen *core.BlockGen) {
+			tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(addr), common.Address{0x01}, big.NewInt(1000), params.TxSmoke, big.NewInt(1), nil), signer, key)
+			gen.AddTx(tx)
+		})
+		if _, err := chain.InsertChain(blocks); err != nil {
+			t.Fatalf("%s: failed to insert chain: %v", name, err)
+		}
+		chain.Stop()
+		if err := tracer.Close(); err != nil {
+			t.Fatalf("%s: failed to close tracer: %v", name, err)
+		}
+		// Collect the results, the events are delivered in the background
+		var results []liveEvent
+		for done := false; !done; {
+			select {
+			case blob := <-events:
+				var ev liveEvent
+				if err := json.Unmarshal(blob, &ev); err != nil {
+					t.Fatalf("%s: invalid event: %v", name, err)
+				}
+				if ev.Event == "txResult" {
+					results = append(results, ev)
+				}
+			case <-time.After(100 * time.Millisecond):
+				done = true
+			}
+		}
+		sub.Unsubscribe()
+
+		if len(results) != len(blocks) {
+			t.Fatalf("%s: result count mismatch: have %d, want %d", name, len(results), len(blocks))
+		}
+		for i, ev := range results {
+			if ev.Error != "" || len(ev.Result) == 0 || *ev.From != addr {
+				t.Errorf("%s: result %d: invalid event: from %x, result %s, error %q", name, i, ev.From, ev.Result, ev.Error)
+			}
+		}
+		if name == "callTracer" {
+			var frame struct {
+				Type string `json:"type"`
+				To   string `json:"to"`
+			}
+			if err := json.Unmarshal(results[0].Result, &frame); err != nil {
+				t.Fatalf("invalid call trace: %v", err)
+			}
+			if frame.Type != "CALL" || frame.To != "0x0100000000000000000000000000000000000000" {
+				t.Errorf("call trace mismatch: %s", results[0].Result)
+			}
+		}
+	}
+}
+
+// Tests that the live tracer observes the state changes of the DAO hard-fork, as
+// the tracer is attached to the state before the fork is applied.
+func TestJSONLiveTracerDAOFork(t *testing.T) {
+	tracer, err := NewLiveTracer("json", nil)
+	if err != nil {
+		t.Fatalf("failed to create live tracer: %v", err)
+	}
+	events := make(chan json.RawMessage, 1024)
+	sub := tracer.SubscribeTrace(events)
+	defer sub.Unsubscribe()
+
+	var (
+		config  = &params.ChainConfig{ChainID: big.NewInt(1), HomesteadBlock: big.NewInt(0), DAOForkBlock: big.NewInt(1), DAOForkSupport: true, Ethash: new(params.EthashConfig)}
+		drained = params.DAODrainList()[0]
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &core.Genesis{Config: config, Alloc: core.GenesisAlloc{drained: {Balance: big.NewInt(1000)}}}
+		genesis = gspec.MustCommit(db)
+	)
+	chaindb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(chaindb)
+
+	chain, err := core.NewBlockChain(chaindb, nil, gspec.Config, ethash.NewFaker(), vm.Config{Debug: true, Tracer: tracer}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 1, nil)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	if err := tracer.Close(); err != nil {
+		t.Fatalf("failed to close tracer: %v", err)
+	}
+	changes := make(map[common.Address]string)
+	for done := false; !done; {
+		select {
+		case blob := <-events:
+			var ev liveEvent
+			if err := json.Unmarshal(blob, &ev); err != nil {
+				t.Fatalf("invalid event: %v", err)
+			}
+			if ev.Event == "balance" {
+				changes[*ev.Address] = ev.New.(string)
+			}
+		case <-time.After(100 * time.Millisecond):
+			done = true
+		}
+	}
+	if changes[drained] != "0x0" || changes[params.DAORefundContract] != "0x3e8" {
+		t.Errorf("DAO fork balance changes not traced: %v", changes)
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/common/hexutil"
+	"github.com/420integrated/go-highcoin/core"
+	"github.com/420integrated/go-highcoin/core/vm"
+	"github.com/420integrated/go-highcoin/crypto"
+)
+
+func init() {
+	RegisterNative("prestateTracer", newPrestateTracer)
+}
+
+// errNoStateAccessed is returned by the prestate tracer if the transaction did
+// not execute any code, so no state was ever made available to the tracer.
+var errNoStateAccessed = errors.New("prestate tracer: no code executed")
+
+// prestateAccount is the pre-transaction state of a single account.
+type prestateAccount struct {
+	Balance *big.Int
+	Nonce   uint64
+	Code    []byte
+	Storage map[common.Hash]common.Hash
+
+	slots []common.Hash // Storage slots in the order they were accessed
+}
+
+// MarshalJSON serializes the account the same way the JavaScript prestateTracer
+// does, keeping the storage slots in their access order.
+func (acc *prestateAccount) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteString(`{"balance":"` + bigToHex(acc.Balance) + `",`)
+	buf.WriteString(`"nonce":` + new(big.Int).SetUint64(acc.Nonce).String() + `,`)
+	buf.WriteString(`"code":"` + hexutil.Encode(acc.Code) + `",`)
+	buf.WriteString(`"storage":{`)
+	for i, slot := range acc.slots {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		value := acc.Storage[slot]
+		buf.WriteString(`"` + hexutil.Encode(slot[:]) + `":"` + hexutil.Encode(value[:]) + `"`)
+	}
+	buf.WriteString(`}}`)
+	return buf.Bytes(), nil
+}
+
+// prestateTracer is a native Go implementation of the JavaScript prestateTracer.
+// It outputs sufficient information to create a local execution of the
+// transaction from a custom assembled genesis block.
+type prestateTracer struct {
+	prestate map[common.Address]*prestateAccount
+	accounts []common.Address // Accounts in the order they were accessed
+	db       vm.StateDB       // State database of the last executed step
+
+	smokePrice     *big.Int
+	create         bool
+	from, to       common.Address
+	input          []byte
+	value          *big.Int
+	smokeUsed      uint64
+	intrinsicSmoke uint64
+
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+}
+
+// newPrestateTracer creates a new native prestate tracer.
+func newPrestateTracer(txCtx vm.TxContext) ResultTracer {
+	return &prestateTracer{smokePrice: txCtx.SmokePrice}
+}
+
+// lookupAccount injects the specified account into the prestate.
+func (t *prestateTracer) lookupAccount(addr common.Address) {
+	if _, ok := t.prestate[addr]; ok {
+		return
+	}
+	t.prestate[addr] = &prestateAccount{
+		Balance: new(big.Int).Set(t.db.GetBalance(addr)),
+		Nonce:   t.db.GetNonce(addr),
+		Code:    common.CopyBytes(t.db.GetCode(addr)),
+		Storage: make(map[common.Hash]common.Hash),
+	}
+	t.accounts = append(t.accounts, addr)
+}
+
+// lookupStorage injects the specified storage entry of the given account into
+// the prestate.
+func (t *prestateTracer) lookupStorage(addr common.Address, key common.Hash) {
+	t.lookupAccount(addr)
+
+	acc := t.prestate[addr]
+	if _, ok := acc.Storage[key]; ok {
+		return
+	}
+	acc.Storage[key] = t.db.GetState(addr, key)
+	acc.slots = append(acc.slots, key)
+}
+
+// CaptureStart implements the Tracer interface to initialize the tracing operation.
+func (t *prestateTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, smoke uint64, value *big.Int) error {
+	t.create = create
+	t.from, t.to = from, to
+	t.input = common.CopyBytes(input)
+	t.value = new(big.Int)
+	if value != nil {
+		t.value.Set(value)
+	}
+	return nil
+}
+
+// CaptureState implements the Tracer interface to trace a single step of VM execution.
+func (t *prestateTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, smoke, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, rData []byte, contract *vm.Contract, depth int, err error) error {
+	// If tracing was interrupted, stop collecting anything
+	if atomic.LoadUint32(&t.interrupt) > 0 {
+		return nil
+	}
+	t.db = env.StateDB
+
+	// Add the current account and compute the intrinsic smoke if we just started tracing
+	if t.prestate == nil {
+		rules := env.ChainConfig()
+		intrinsicSmoke, err := core.IntrinsicSmoke(t.input, nil, t.create, rules.IsHomestead(env.Context.BlockNumber), rules.IsIstanbul(env.Context.BlockNumber))
+		if err != nil {
+			return err
+		}
+		t.intrinsicSmoke = intrinsicSmoke
+		t.prestate = make(map[common.Address]*prestateAccount)
+
+		// Balance will potentially be wrong here, since this will include the value
+		// sent along with the message. We fix that in GetResult.
+		t.lookupAccount(contract.Address())
+	}
+	// Whenever new state is accessed, add it to the prestate
+	switch op {
+	case vm.EXTCODECOPY, vm.EXTCODESIZE, vm.BALANCE:
+		t.lookupAccount(common.BigToAddress(stackPeekBig(stack, 0)))
+
+	case vm.CREATE:
+		from := contract.Address()
+		t.lookupAccount(crypto.CreateAddress(from, t.db.GetNonce(from)))
+
+	case vm.CREATE2:
+		// stack: salt, size, offset, endowment
+		from := contract.Address()
+		offset, size := stackPeek(stack, 1), stackPeek(stack, 2)
+		salt := common.BigToHash(stackPeekBig(stack, 3))
+		codeHash := crypto.Keccak256(memorySlice(memory, offset, offset+size))
+		t.lookupAccount(crypto.CreateAddress2(from, salt, codeHash))
+
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		t.lookupAccount(common.BigToAddress(stackPeekBig(stack, 1)))
+
+	case vm.SSTORE, vm.SLOAD:
+		t.lookupStorage(contract.Address(), common.BigToHash(stackPeekBig(stack, 0)))
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface to trace an execution fault
+// while running an opcode.
+func (t *prestateTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, smoke, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *prestateTracer) CaptureEnd(output []byte, smokeUsed uint64, d time.Duration, err error) error {
+	t.smokeUsed = smokeUsed
+	return nil
+}
+
+// GetResult returns the JSON encoded prestate of the traced transaction, or the
+// reason the tracing was interrupted.
+func (t *prestateTracer) GetResult() (json.RawMessage, error) {
+	if t.reason != nil {
+		return nil, t.reason
+	}
+	if t.prestate == nil {
+		return nil, errNoStateAccessed
+	}
+	// At this point, we need to deduct the 'value' from the outer transaction,
+	// and move it back to the origin
+	t.lookupAccount(t.from)
+	t.lookupAccount(t.to)
+
+	fee := new(big.Int).SetUint64(t.smokeUsed + t.intrinsicSmoke)
+	if t.smokePrice != nil {
+		fee.Mul(fee, t.smokePrice)
+	} else {
+		fee.SetUint64(0)
+	}
+	to, from := t.prestate[t.to], t.prestate[t.from]
+	to.Balance = new(big.Int).Sub(to.Balance, t.value)
+	from.Balance = new(big.Int).Add(from.Balance, t.value)
+	from.Balance.Add(from.Balance, fee)
+
+	// Decrement the caller's nonce, and remove empty create targets
+	from.Nonce--
+	if t.create {
+		// We can blindly delete the contract prestate, as any existing state would
+		// have caused the transaction to be rejected as invalid in the first place.
+		delete(t.prestate, t.to)
+	}
+	// Serialize the assembled allocations in their access order
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, addr := range t.accounts {
+		acc, ok := t.prestate[addr]
+		if !ok {
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		blob, err := acc.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(`"` + addrToHex(addr) + `":`)
+		buf.Write(blob)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *prestateTracer) Stop(err error) {
+	t.reason = err
+	atomic.StoreUint32(&t.interrupt, 1)
+}
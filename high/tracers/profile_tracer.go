@@ -0,0 +1,68 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"sync/atomic"
+
+	"github.com/420integrated/go-highcoin/core/vm"
+)
+
+func init() {
+	RegisterNative("profileTracer", newProfileTracer)
+}
+
+// profileTracer is a native Go tracer which outputs the execution count, wall
+// time and smoke of every opcode and program counter of a transaction, as
+// aggregated by vm.Profiler.
+type profileTracer struct {
+	*vm.Profiler
+
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+}
+
+// newProfileTracer creates a new native profiling tracer.
+func newProfileTracer(txCtx vm.TxContext) ResultTracer {
+	return &profileTracer{Profiler: vm.NewProfiler()}
+}
+
+// CaptureState implements the Tracer interface to profile a single step of VM
+// execution.
+func (t *profileTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, smoke, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, rData []byte, contract *vm.Contract, depth int, err error) error {
+	// If tracing was interrupted, stop collecting anything
+	if atomic.LoadUint32(&t.interrupt) > 0 {
+		return nil
+	}
+	return t.Profiler.CaptureState(env, pc, op, smoke, cost, memory, stack, rStack, rData, contract, depth, err)
+}
+
+// GetResult returns the JSON encoded execution profile of the traced transaction,
+// or the reason the tracing was interrupted.
+func (t *profileTracer) GetResult() (json.RawMessage, error) {
+	if t.reason != nil {
+		return nil, t.reason
+	}
+	return json.Marshal(t.Profile())
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *profileTracer) Stop(err error) {
+	t.reason = err
+	atomic.StoreUint32(&t.interrupt, 1)
+}
@@ -0,0 +1,215 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/common/hexutil"
+	"github.com/420integrated/go-highcoin/core/tracing"
+	"github.com/420integrated/go-highcoin/core/types"
+	"github.com/420integrated/go-highcoin/core/vm"
+)
+
+func init() {
+	RegisterNative("stateDiffTracer", newStateDiffTracer)
+}
+
+// errNoStateHooks is returned by the state diff tracer if it was not run as part
+// of a transaction, so no state changes were ever reported to it.
+var errNoStateHooks = errors.New("state diff tracer: no transaction applied")
+
+// stateDiffAccount is the state of a single account before or after a transaction.
+// Only the storage slots modified by the transaction are included.
+type stateDiffAccount struct {
+	Balance *hexutil.Big                `json:"balance"`
+	Nonce   uint64                      `json:"nonce"`
+	Code    hexutil.Bytes               `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// empty returns whether the account is non-existent: empty as defined by EIP-161
+// and without any storage. Cleared storage slots don't count.
+func (acc *stateDiffAccount) empty() bool {
+	if acc.Balance.ToInt().Sign() != 0 || acc.Nonce != 0 || len(acc.Code) != 0 {
+		return false
+	}
+	for _, value := range acc.Storage {
+		if value != (common.Hash{}) {
+			return false
+		}
+	}
+	return true
+}
+
+// stateDiff is the result of the state diff tracer. Accounts not existing before
+// or after the transaction are missing from the respective side.
+type stateDiff struct {
+	Pre  map[common.Address]*stateDiffAccount `json:"pre"`
+	Post map[common.Address]*stateDiffAccount `json:"post"`
+}
+
+// stateDiffTracer is a native Go tracer which outputs the state of the accounts
+// modified by a transaction before and after its execution. Instead of inspecting
+// the opcodes, it is fed the changes applied to the state database.
+type stateDiffTracer struct {
+	db  vm.StateDB                           // State database the transaction is applied on
+	pre map[common.Address]*stateDiffAccount // State of the modified accounts before the transaction
+
+	reason error // Textual reason for the interruption
+}
+
+// newStateDiffTracer creates a new native state diff tracer.
+func newStateDiffTracer(txCtx vm.TxContext) ResultTracer {
+	return &stateDiffTracer{pre: make(map[common.Address]*stateDiffAccount)}
+}
+
+// account returns the state of the given account at the time of its first
+// modification, and whether this is the first modification.
+func (t *stateDiffTracer) account(addr common.Address) (*stateDiffAccount, bool) {
+	if acc, ok := t.pre[addr]; ok {
+		return acc, false
+	}
+	// The change being reported is already applied, the hooks override it
+	acc := &stateDiffAccount{
+		Balance: (*hexutil.Big)(new(big.Int).Set(t.db.GetBalance(addr))),
+		Nonce:   t.db.GetNonce(addr),
+		Code:    common.CopyBytes(t.db.GetCode(addr)),
+		Storage: make(map[common.Hash]common.Hash),
+	}
+	t.pre[addr] = acc
+	return acc, true
+}
+
+// CaptureTxStart implements vm.StateTracer, retrieving the state database.
+func (t *stateDiffTracer) CaptureTxStart(env *vm.EVM, from common.Address, to *common.Address, smoke uint64, value *big.Int) {
+	t.db = env.StateDB
+}
+
+// CaptureTxEnd implements vm.StateTracer.
+func (t *stateDiffTracer) CaptureTxEnd(smokeUsed uint64, err error) {}
+
+// OnBalanceChange implements tracing.StateHooks.
+func (t *stateDiffTracer) OnBalanceChange(addr common.Address, prev, new *big.Int, reason tracing.BalanceChangeReason) {
+	if acc, first := t.account(addr); first {
+		acc.Balance = (*hexutil.Big)(prev)
+	}
+}
+
+// OnNonceChange implements tracing.StateHooks.
+func (t *stateDiffTracer) OnNonceChange(addr common.Address, prev, new uint64) {
+	if acc, first := t.account(addr); first {
+		acc.Nonce = prev
+	}
+}
+
+// OnCodeChange implements tracing.StateHooks.
+func (t *stateDiffTracer) OnCodeChange(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte) {
+	if acc, first := t.account(addr); first {
+		acc.Code = common.CopyBytes(prevCode)
+	}
+}
+
+// OnStorageChange implements tracing.StateHooks.
+func (t *stateDiffTracer) OnStorageChange(addr common.Address, slot common.Hash, prev, new common.Hash) {
+	acc, _ := t.account(addr)
+	if _, ok := acc.Storage[slot]; !ok {
+		acc.Storage[slot] = prev
+	}
+}
+
+// OnLog implements tracing.StateHooks.
+func (t *stateDiffTracer) OnLog(log *types.Log) {}
+
+// CaptureStart implements the Tracer interface, the call frames are not traced.
+func (t *stateDiffTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, smoke uint64, value *big.Int) error {
+	return nil
+}
+
+// CaptureState implements the Tracer interface, the opcodes are not traced.
+func (t *stateDiffTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, smoke, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, rData []byte, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureFault implements the Tracer interface.
+func (t *stateDiffTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, smoke, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnd implements the Tracer interface.
+func (t *stateDiffTracer) CaptureEnd(output []byte, smokeUsed uint64, d time.Duration, err error) error {
+	return nil
+}
+
+// GetResult returns the JSON encoded state diff of the traced transaction, or
+// the reason the tracing was interrupted. It must be called before the state
+// database is finalised, while the self-destructed accounts are still known.
+func (t *stateDiffTracer) GetResult() (json.RawMessage, error) {
+	if t.reason != nil {
+		return nil, t.reason
+	}
+	if t.db == nil {
+		return nil, errNoStateHooks
+	}
+	diff := &stateDiff{
+		Pre:  make(map[common.Address]*stateDiffAccount),
+		Post: make(map[common.Address]*stateDiffAccount),
+	}
+	for addr, pre := range t.pre {
+		post := &stateDiffAccount{
+			Balance: (*hexutil.Big)(new(big.Int).Set(t.db.GetBalance(addr))),
+			Nonce:   t.db.GetNonce(addr),
+			Code:    common.CopyBytes(t.db.GetCode(addr)),
+			Storage: make(map[common.Hash]common.Hash),
+		}
+		if t.db.HasSuicided(addr) {
+			post = &stateDiffAccount{Balance: new(hexutil.Big), Storage: make(map[common.Hash]common.Hash)}
+		}
+		// Only retain the slots actually changed, the reverted writes are dropped
+		prestore := make(map[common.Hash]common.Hash)
+		for slot, prev := range pre.Storage {
+			value := common.Hash{}
+			if !t.db.HasSuicided(addr) {
+				value = t.db.GetState(addr, slot)
+			}
+			if value != prev {
+				prestore[slot], post.Storage[slot] = prev, value
+			}
+		}
+		pre := &stateDiffAccount{Balance: pre.Balance, Nonce: pre.Nonce, Code: pre.Code, Storage: prestore}
+		if pre.Balance.ToInt().Cmp(post.Balance.ToInt()) == 0 && pre.Nonce == post.Nonce && bytes.Equal(pre.Code, post.Code) && len(prestore) == 0 {
+			continue
+		}
+		if !pre.empty() {
+			diff.Pre[addr] = pre
+		}
+		if !post.empty() {
+			diff.Post[addr] = post
+		}
+	}
+	return json.Marshal(diff)
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *stateDiffTracer) Stop(err error) {
+	t.reason = err
+}
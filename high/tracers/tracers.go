@@ -14,18 +14,43 @@
 // You should have received a copy of the GNU Lesser General Public License
 // along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
 
-// Package tracers is a collection of JavaScript transaction tracers.
+// Package tracers is a collection of JavaScript and native Go transaction tracers.
 package tracers
 
 import (
+	"encoding/json"
 	"strings"
 	"unicode"
 
+	"github.com/420integrated/go-highcoin/core/vm"
 	"github.com/420integrated/go-highcoin/high/tracers/internal/tracers"
 )
 
-// all contains all the built in JavaScript tracers by name.
-var all = make(map[string]string)
+// ResultTracer is a vm.Tracer which aggregates the execution into a JSON result
+// and which can be interrupted mid-execution. Both the JavaScript and the native
+// Go tracers implement it.
+type ResultTracer interface {
+	vm.Tracer
+
+	// GetResult returns the JSON encoded result of the trace, or any error that
+	// occurred while tracing.
+	GetResult() (json.RawMessage, error)
+
+	// Stop terminates execution of the tracer at the first opportune moment.
+	Stop(err error)
+}
+
+// NativeConstructor creates a new instance of a native Go tracer for tracing
+// a transaction with the given context.
+type NativeConstructor func(txCtx vm.TxContext) ResultTracer
+
+var (
+	// all contains all the built in JavaScript tracers by name.
+	all = make(map[string]string)
+
+	// native contains all the registered native Go tracers by name.
+	native = make(map[string]NativeConstructor)
+)
 
 // camel converts a snake cased input string into a camel cased output.
 func camel(str string) string {
@@ -44,6 +69,27 @@ func init() {
 	}
 }
 
+// RegisterNative makes a native Go tracer available under the given name. Native
+// tracers take precedence over JavaScript tracers of the same name, so they can
+// be used as drop-in replacements for the built in JavaScript ones.
+func RegisterNative(name string, ctor NativeConstructor) {
+	native[name] = ctor
+}
+
+// NewTracer instantiates a new tracer by name. If a native Go tracer is registered
+// with the given name, that one is used. Otherwise code is interpreted either as
+// the name of a built in JavaScript tracer or as the JavaScript code itself.
+func NewTracer(code string, txCtx vm.TxContext) (ResultTracer, error) {
+	if ctor, ok := native[code]; ok {
+		return ctor(txCtx), nil
+	}
+	tracer, err := New(code, txCtx)
+	if err != nil {
+		return nil, err
+	}
+	return tracer, nil
+}
+
 // tracer retrieves a specific JavaScript tracer by name.
 func tracer(name string) (string, bool) {
 	if tracer, ok := all[name]; ok {
@@ -17,6 +17,7 @@
 package tracers
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/rand"
 	"encoding/json"
@@ -24,6 +25,7 @@ import (
 	"math/big"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 
@@ -120,7 +122,27 @@ type callTracerTest struct {
 	Result  *callTrace    `json:"result"`
 }
 
+// stateDiffTracerTest defines a single test to check the state diff tracer against.
+type stateDiffTracerTest struct {
+	Genesis *core.Genesis   `json:"genesis"`
+	Context *callContext    `json:"context"`
+	Input   string          `json:"input"`
+	Result  json.RawMessage `json:"result"`
+}
+
 func TestPrestateTracerCreate2(t *testing.T) {
+	testPrestateTracerCreate2(t, func(txCtx vm.TxContext) (ResultTracer, error) {
+		return New("prestateTracer", txCtx)
+	})
+}
+
+func TestNativePrestateTracerCreate2(t *testing.T) {
+	testPrestateTracerCreate2(t, func(txCtx vm.TxContext) (ResultTracer, error) {
+		return NewTracer("prestateTracer", txCtx)
+	})
+}
+
+func testPrestateTracerCreate2(t *testing.T, newTracer func(txCtx vm.TxContext) (ResultTracer, error)) {
 	unsignedTx := types.NewTransaction(1, common.HexToAddress("0x00000000000000000000000000000000deadbeef"),
 		new(big.Int), 5000000, big.NewInt(1), []byte{})
 
@@ -173,7 +195,7 @@ func TestPrestateTracerCreate2(t *testing.T) {
 	_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), alloc, false)
 
 	// Create the tracer, the EVM environment and run it
-	tracer, err := New("prestateTracer", txContext)
+	tracer, err := newTracer(txContext)
 	if err != nil {
 		t.Fatalf("failed to create call tracer: %v", err)
 	}
@@ -204,6 +226,20 @@ func TestPrestateTracerCreate2(t *testing.T) {
 // Iterates over all the input-output datasets in the tracer test harness and
 // runs the JavaScript tracers against them.
 func TestCallTracer(t *testing.T) {
+	testCallTracer(t, func(txCtx vm.TxContext) (ResultTracer, error) {
+		return New("callTracer", txCtx)
+	})
+}
+
+// Iterates over all the input-output datasets in the tracer test harness and
+// runs the native Go tracers against them.
+func TestNativeCallTracer(t *testing.T) {
+	testCallTracer(t, func(txCtx vm.TxContext) (ResultTracer, error) {
+		return NewTracer("callTracer", txCtx)
+	})
+}
+
+func testCallTracer(t *testing.T, newTracer func(txCtx vm.TxContext) (ResultTracer, error)) {
 	files, err := ioutil.ReadDir("testdata")
 	if err != nil {
 		t.Fatalf("failed to retrieve tracer test suite: %v", err)
@@ -225,6 +261,144 @@ func TestCallTracer(t *testing.T) {
 			if err := json.Unmarshal(blob, test); err != nil {
 				t.Fatalf("failed to parse testcase: %v", err)
 			}
+			res := traceCallTracerTest(t, test, newTracer)
+			ret := new(callTrace)
+			if err := json.Unmarshal(res, ret); err != nil {
+				t.Fatalf("failed to unmarshal trace result: %v", err)
+			}
+
+			if !jsonEqual(ret, test.Result) {
+				// uncomment this for easier debugging
+				//have, _ := json.MarshalIndent(ret, "", " ")
+				//want, _ := json.MarshalIndent(test.Result, "", " ")
+				//t.Fatalf("trace mismatch: \nhave %+v\nwant %+v", string(have), string(want))
+				t.Fatalf("trace mismatch: \nhave %+v\nwant %+v", ret, test.Result)
+			}
+		})
+	}
+}
+
+// traceCallTracerTest executes the transaction of a call tracer test on top of
+// its prestate, returning the result of the given tracer.
+func traceCallTracerTest(t *testing.T, test *callTracerTest, newTracer func(txCtx vm.TxContext) (ResultTracer, error)) json.RawMessage {
+	// Configure a blockchain with the given prestate
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(common.FromHex(test.Input), tx); err != nil {
+		t.Fatalf("failed to parse testcase input: %v", err)
+	}
+	// The transactions were signed on a network with a different chain id than
+	// the one of the prestate config, so take the chain id from the transaction
+	signer := types.LatestSignerForChainID(tx.ChainId())
+	origin, _ := signer.Sender(tx)
+	txContext := vm.TxContext{
+		Origin:   origin,
+		SmokePrice: tx.SmokePrice(),
+	}
+	context := vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		Coinbase:    test.Context.Miner,
+		BlockNumber: new(big.Int).SetUint64(uint64(test.Context.Number)),
+		Time:        new(big.Int).SetUint64(uint64(test.Context.Time)),
+		Difficulty:  (*big.Int)(test.Context.Difficulty),
+		SmokeLimit:    uint64(test.Context.SmokeLimit),
+	}
+	_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), test.Genesis.Alloc, false)
+
+	// Create the tracer, the EVM environment and run it
+	tracer, err := newTracer(txContext)
+	if err != nil {
+		t.Fatalf("failed to create call tracer: %v", err)
+	}
+	evm := vm.NewEVM(context, txContext, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer})
+
+	msg, err := tx.AsMessage(signer)
+	if err != nil {
+		t.Fatalf("failed to prepare transaction for tracing: %v", err)
+	}
+	st := core.NewStateTransition(evm, msg, new(core.SmokePool).AddSmoke(tx.Smoke()))
+	if _, err = st.TransitionDb(); err != nil {
+		t.Fatalf("failed to execute transaction: %v", err)
+	}
+	// Retrieve the trace result
+	res, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	return res
+}
+
+// Iterates over all the input-output datasets in the tracer test harness and
+// ensures the native Go call tracer produces exactly the same output as the
+// JavaScript one, apart from the measured execution time.
+func TestNativeCallTracerMatchesJS(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "call_tracer_*.json"))
+	if err != nil {
+		t.Fatalf("failed to retrieve tracer test suite: %v", err)
+	}
+	stripTime := regexp.MustCompile(`,"time":"[^"]*"`)
+	for _, file := range files {
+		blob, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("failed to read testcase: %v", err)
+		}
+		test := new(callTracerTest)
+		if err := json.Unmarshal(blob, test); err != nil {
+			t.Fatalf("failed to parse testcase: %v", err)
+		}
+		js := traceCallTracerTest(t, test, func(txCtx vm.TxContext) (ResultTracer, error) {
+			return New("callTracer", txCtx)
+		})
+		native := traceCallTracerTest(t, test, func(txCtx vm.TxContext) (ResultTracer, error) {
+			return NewTracer("callTracer", txCtx)
+		})
+		if have, want := stripTime.ReplaceAll(native, nil), stripTime.ReplaceAll(js, nil); !bytes.Equal(have, want) {
+			t.Errorf("%s: native output mismatch:\nhave %s\nwant %s", file, have, want)
+		}
+	}
+}
+
+// jsonEqual is similar to reflect.DeepEqual, but does a 'bounce' via json prior to
+// comparison
+func jsonEqual(x, y interface{}) bool {
+	xTrace := new(callTrace)
+	yTrace := new(callTrace)
+	if xj, err := json.Marshal(x); err == nil {
+		json.Unmarshal(xj, xTrace)
+	} else {
+		return false
+	}
+	if yj, err := json.Marshal(y); err == nil {
+		json.Unmarshal(yj, yTrace)
+	} else {
+		return false
+	}
+	return reflect.DeepEqual(xTrace, yTrace)
+}
+
+// Iterates over all the input-output datasets of the state diff tracer and checks
+// the pre and post states it assembles from the state changes.
+func TestStateDiffTracer(t *testing.T) {
+	files, err := ioutil.ReadDir("testdata")
+	if err != nil {
+		t.Fatalf("failed to retrieve tracer test suite: %v", err)
+	}
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name(), "state_diff_tracer_") {
+			continue
+		}
+		file := file // capture range variable
+		t.Run(camel(strings.TrimSuffix(strings.TrimPrefix(file.Name(), "state_diff_tracer_"), ".json")), func(t *testing.T) {
+			t.Parallel()
+
+			blob, err := ioutil.ReadFile(filepath.Join("testdata", file.Name()))
+			if err != nil {
+				t.Fatalf("failed to read testcase: %v", err)
+			}
+			test := new(stateDiffTracerTest)
+			if err := json.Unmarshal(blob, test); err != nil {
+				t.Fatalf("failed to parse testcase: %v", err)
+			}
 			// Configure a blockchain with the given prestate
 			tx := new(types.Transaction)
 			if err := rlp.DecodeBytes(common.FromHex(test.Input), tx); err != nil {
@@ -233,7 +407,7 @@ func TestCallTracer(t *testing.T) {
 			signer := types.MakeSigner(test.Genesis.Config, new(big.Int).SetUint64(uint64(test.Context.Number)))
 			origin, _ := signer.Sender(tx)
 			txContext := vm.TxContext{
-				Origin:   origin,
+				Origin:     origin,
 				SmokePrice: tx.SmokePrice(),
 			}
 			context := vm.BlockContext{
@@ -243,14 +417,14 @@ func TestCallTracer(t *testing.T) {
 				BlockNumber: new(big.Int).SetUint64(uint64(test.Context.Number)),
 				Time:        new(big.Int).SetUint64(uint64(test.Context.Time)),
 				Difficulty:  (*big.Int)(test.Context.Difficulty),
-				SmokeLimit:    uint64(test.Context.SmokeLimit),
+				SmokeLimit:  uint64(test.Context.SmokeLimit),
 			}
 			_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), test.Genesis.Alloc, false)
 
 			// Create the tracer, the EVM environment and run it
-			tracer, err := New("callTracer", txContext)
+			tracer, err := NewTracer("stateDiffTracer", txContext)
 			if err != nil {
-				t.Fatalf("failed to create call tracer: %v", err)
+				t.Fatalf("failed to create state diff tracer: %v", err)
 			}
 			evm := vm.NewEVM(context, txContext, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer})
 
@@ -267,36 +441,79 @@ func TestCallTracer(t *testing.T) {
 			if err != nil {
 				t.Fatalf("failed to retrieve trace result: %v", err)
 			}
-			ret := new(callTrace)
-			if err := json.Unmarshal(res, ret); err != nil {
+			var have, want interface{}
+			if err := json.Unmarshal(res, &have); err != nil {
 				t.Fatalf("failed to unmarshal trace result: %v", err)
 			}
-
-			if !jsonEqual(ret, test.Result) {
-				// uncomment this for easier debugging
-				//have, _ := json.MarshalIndent(ret, "", " ")
-				//want, _ := json.MarshalIndent(test.Result, "", " ")
-				//t.Fatalf("trace mismatch: \nhave %+v\nwant %+v", string(have), string(want))
-				t.Fatalf("trace mismatch: \nhave %+v\nwant %+v", ret, test.Result)
+			if err := json.Unmarshal(test.Result, &want); err != nil {
+				t.Fatalf("failed to unmarshal expected result: %v", err)
+			}
+			if !reflect.DeepEqual(have, want) {
+				t.Fatalf("trace mismatch: \nhave %s\nwant %s", res, test.Result)
 			}
 		})
 	}
 }
 
-// jsonEqual is similar to reflect.DeepEqual, but does a 'bounce' via json prior to
-// comparison
-func jsonEqual(x, y interface{}) bool {
-	xTrace := new(callTrace)
-	yTrace := new(callTrace)
-	if xj, err := json.Marshal(x); err == nil {
-		json.Unmarshal(xj, xTrace)
-	} else {
-		return false
+// Tests that the native profiling tracer aggregates the executed instructions
+// of a transaction.
+func TestProfileTracer(t *testing.T) {
+	blob, err := ioutil.ReadFile(filepath.Join("testdata", "state_diff_tracer_storage.json"))
+	if err != nil {
+		t.Fatalf("failed to read testcase: %v", err)
 	}
-	if yj, err := json.Marshal(y); err == nil {
-		json.Unmarshal(yj, yTrace)
-	} else {
-		return false
+	test := new(stateDiffTracerTest)
+	if err := json.Unmarshal(blob, test); err != nil {
+		t.Fatalf("failed to parse testcase: %v", err)
+	}
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(common.FromHex(test.Input), tx); err != nil {
+		t.Fatalf("failed to parse testcase input: %v", err)
+	}
+	signer := types.MakeSigner(test.Genesis.Config, new(big.Int).SetUint64(uint64(test.Context.Number)))
+	origin, _ := signer.Sender(tx)
+	txContext := vm.TxContext{
+		Origin:     origin,
+		SmokePrice: tx.SmokePrice(),
+	}
+	context := vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		BlockNumber: new(big.Int).SetUint64(uint64(test.Context.Number)),
+		Difficulty:  (*big.Int)(test.Context.Difficulty),
+		SmokeLimit:  uint64(test.Context.SmokeLimit),
+	}
+	_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), test.Genesis.Alloc, false)
+
+	tracer, err := NewTracer("profileTracer", txContext)
+	if err != nil {
+		t.Fatalf("failed to create profile tracer: %v", err)
+	}
+	evm := vm.NewEVM(context, txContext, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer})
+	msg, err := tx.AsMessage(signer)
+	if err != nil {
+		t.Fatalf("failed to prepare transaction for tracing: %v", err)
+	}
+	if _, err = core.ApplyMessage(evm, msg, new(core.SmokePool).AddSmoke(tx.Smoke())); err != nil {
+		t.Fatalf("failed to execute transaction: %v", err)
+	}
+	res, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	profile := new(vm.ExecutionProfile)
+	if err := json.Unmarshal(res, profile); err != nil {
+		t.Fatalf("failed to unmarshal trace result: %v", err)
+	}
+	// The contract executes 4 stores, each preceded by 2 pushes
+	counts := make(map[string]uint64)
+	for _, op := range profile.Opcodes {
+		counts[op.Op] = op.Count
+	}
+	if len(counts) != 3 || counts["SSTORE"] != 4 || counts["PUSH1"] != 8 || counts["STOP"] != 1 {
+		t.Errorf("opcode count mismatch: %v", counts)
+	}
+	if len(profile.Instructions) != 13 {
+		t.Errorf("instruction count mismatch: have %d, want 13", len(profile.Instructions))
 	}
-	return reflect.DeepEqual(xTrace, yTrace)
 }
@@ -301,6 +301,19 @@ type rpcProgress struct {
 	HighestBlock  hexutil.Uint64
 	PulledStates  hexutil.Uint64
 	KnownStates   hexutil.Uint64
+
+	SyncedAccounts      hexutil.Uint64
+	SyncedAccountBytes  hexutil.Uint64
+	SyncedBytecodes     hexutil.Uint64
+	SyncedBytecodeBytes hexutil.Uint64
+	SyncedStorage       hexutil.Uint64
+	SyncedStorageBytes  hexutil.Uint64
+	HealedTrienodes     hexutil.Uint64
+	HealedTrienodeBytes hexutil.Uint64
+	HealedBytecodes     hexutil.Uint64
+	HealedBytecodeBytes hexutil.Uint64
+	HealingPending      hexutil.Uint64
+	EstimatedCompletion hexutil.Uint64
 }
 
 // SyncProgress retrieves the current progress of the sync algorithm. If there's
@@ -325,6 +338,19 @@ func (ec *Client) SyncProgress(ctx context.Context) (*highcoin.SyncProgress, err
 		HighestBlock:  uint64(progress.HighestBlock),
 		PulledStates:  uint64(progress.PulledStates),
 		KnownStates:   uint64(progress.KnownStates),
+
+		SyncedAccounts:      uint64(progress.SyncedAccounts),
+		SyncedAccountBytes:  uint64(progress.SyncedAccountBytes),
+		SyncedBytecodes:     uint64(progress.SyncedBytecodes),
+		SyncedBytecodeBytes: uint64(progress.SyncedBytecodeBytes),
+		SyncedStorage:       uint64(progress.SyncedStorage),
+		SyncedStorageBytes:  uint64(progress.SyncedStorageBytes),
+		HealedTrienodes:     uint64(progress.HealedTrienodes),
+		HealedTrienodeBytes: uint64(progress.HealedTrienodeBytes),
+		HealedBytecodes:     uint64(progress.HealedBytecodes),
+		HealedBytecodeBytes: uint64(progress.HealedBytecodeBytes),
+		HealingPending:      uint64(progress.HealingPending),
+		EstimatedCompletion: uint64(progress.EstimatedCompletion),
 	}, nil
 }
 
@@ -502,6 +528,34 @@ func (ec *Client) SuggestSmokePrice(ctx context.Context) (*big.Int, error) {
 	return (*big.Int)(&hex), nil
 }
 
+// FeeHistory retrieves the smoke price history of the given range of blocks
+// ending with lastBlock (nil meaning the latest block). It returns the number of
+// the oldest block, the requested percentiles of the smoke prices paid in each
+// block, and the smoke used ratio and smoke limit of each block.
+func (ec *Client) FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, percentiles []float64) (*big.Int, [][]*big.Int, []float64, []uint64, error) {
+	var res struct {
+		OldestBlock    *hexutil.Big     `json:"oldestBlock"`
+		SmokePrice     [][]*hexutil.Big `json:"smokePrice,omitempty"`
+		SmokeUsedRatio []float64        `json:"smokeUsedRatio"`
+		SmokeLimit     []hexutil.Uint64 `json:"smokeLimit"`
+	}
+	if err := ec.c.CallContext(ctx, &res, "high_feeHistory", hexutil.Uint(blockCount), toBlockNumArg(lastBlock), percentiles); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	prices := make([][]*big.Int, len(res.SmokePrice))
+	for i, p := range res.SmokePrice {
+		prices[i] = make([]*big.Int, len(p))
+		for j, v := range p {
+			prices[i][j] = (*big.Int)(v)
+		}
+	}
+	limits := make([]uint64, len(res.SmokeLimit))
+	for i, v := range res.SmokeLimit {
+		limits[i] = uint64(v)
+	}
+	return (*big.Int)(res.OldestBlock), prices, res.SmokeUsedRatio, limits, nil
+}
+
 // EstimateSmoke tries to estimate the smoke needed to execute a specific transaction based on
 // the current pending state of the backend blockchain. There is no guarantee that this is
 // the true smoke limit requirement as other transactions may be added or removed by miners,
@@ -515,6 +569,23 @@ func (ec *Client) EstimateSmoke(ctx context.Context, msg highcoin.CallMsg) (uint
 	return uint64(hex), nil
 }
 
+// CreateAccessList tries to create an access list for a specific transaction based on
+// the current pending state of the blockchain. It returns the access list, the smoke
+// used when executing the transaction with it, and the error the execution itself
+// failed with, if any.
+func (ec *Client) CreateAccessList(ctx context.Context, msg highcoin.CallMsg) (*types.AccessList, uint64, string, error) {
+	type accessListResult struct {
+		Accesslist *types.AccessList `json:"accessList"`
+		Error      string            `json:"error,omitempty"`
+		SmokeUsed  hexutil.Uint64    `json:"smokeUsed"`
+	}
+	var result accessListResult
+	if err := ec.c.CallContext(ctx, &result, "high_createAccessList", toCallArg(msg)); err != nil {
+		return nil, 0, "", err
+	}
+	return result.Accesslist, uint64(result.SmokeUsed), result.Error, nil
+}
+
 // SendTransaction injects a signed transaction into the pending pool for execution.
 //
 // If the transaction was a contract creation use the TransactionReceipt method to get the
@@ -544,5 +615,8 @@ func toCallArg(msg highcoin.CallMsg) interface{} {
 	if msg.SmokePrice != nil {
 		arg["smokePrice"] = (*hexutil.Big)(msg.SmokePrice)
 	}
+	if msg.AccessList != nil {
+		arg["accessList"] = msg.AccessList
+	}
 	return arg
 }
@@ -81,6 +81,10 @@ type AncientReader interface {
 
 	// AncientSize returns the ancient size of the specified category.
 	AncientSize(kind string) (uint64, error)
+
+	// AncientTail returns the number of the first item still available in the
+	// specified category, items below it were deleted from the ancient store.
+	AncientTail(kind string) (uint64, error)
 }
 
 // AncientWriter contains the methods required to write to immutable ancient data.
@@ -92,6 +96,10 @@ type AncientWriter interface {
 	// TruncateAncients discards all but the first n ancient data from the ancient store.
 	TruncateAncients(n uint64) error
 
+	// TruncateAncientTail discards the items below n from the specified category.
+	// Data is deleted in whole files, so some items below n may be retained.
+	TruncateAncientTail(kind string, n uint64) error
+
 	// Sync flushes all in-memory ancient store data to disk.
 	Sync() error
 }
@@ -0,0 +1,532 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build arm64 amd64
+
+// Package pebble implements the key-value database layer based on Pebble.
+//
+// Pebble is only supported on 64 bit architectures, other platforms fall back
+// to an error when opening a pebble database.
+package pebble
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/highdb"
+	"github.com/420integrated/go-highcoin/log"
+	"github.com/420integrated/go-highcoin/metrics"
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/bloom"
+)
+
+const (
+	// degradationWarnInterval specifies how often warning should be printed if the
+	// pebble database cannot keep up with requested writes.
+	degradationWarnInterval = time.Minute
+
+	// minCache is the minimum amount of memory in megabytes to allocate to pebble
+	// read and write caching, split half and half.
+	minCache = 16
+
+	// minHandles is the minimum number of files handles to allocate to the open
+	// database files.
+	minHandles = 16
+
+	// metricsGatheringInterval specifies the interval to retrieve pebble database
+	// compaction, io and pause stats to report to the user.
+	metricsGatheringInterval = 3 * time.Second
+
+	// maxMemTableSize is the largest memory table pebble supports, limited by the
+	// 32 bit offsets used in its arena.
+	maxMemTableSize = 4<<30 - 1
+
+	// statsProperty is the only property supported by Stat, returning the full
+	// metrics table of the database.
+	statsProperty = "pebble.stats"
+)
+
+// errUnknownProperty is returned if an unsupported statistic is requested.
+var errUnknownProperty = errors.New("pebble: unknown property")
+
+// Database is a persistent key-value store based on the pebble storage engine.
+// Apart from basic data storage functionality it also supports batch writes and
+// iterating over the keyspace in binary-alphabetical order.
+type Database struct {
+	// Compaction and write stall statistics updated by the event listener. The
+	// 64 bit fields are kept first to ensure their alignment for atomic access.
+	compTime   int64 // Total time spent in compaction in ns (atomic)
+	stallTime  int64 // Total time writes were stalled in ns (atomic)
+	stallCount int64 // Number of write stalls (atomic)
+
+	fn string     // filename for reporting
+	db *pebble.DB // Underlying pebble storage engine
+
+	compTimeMeter      metrics.Meter // Meter for measuring the total time spent in database compaction
+	compReadMeter      metrics.Meter // Meter for measuring the data read during compaction
+	compWriteMeter     metrics.Meter // Meter for measuring the data written during compaction
+	writeDelayNMeter   metrics.Meter // Meter for measuring the write delay number due to database compaction
+	writeDelayMeter    metrics.Meter // Meter for measuring the write delay duration due to database compaction
+	diskSizeGauge      metrics.Gauge // Gauge for tracking the size of all the levels in the database
+	diskWriteMeter     metrics.Meter // Meter for measuring the effective amount of data written
+	memCompGauge       metrics.Gauge // Gauge for tracking the number of memory compaction
+	level0CompGauge    metrics.Gauge // Gauge for tracking the number of table compaction in level0
+	nonlevel0CompGauge metrics.Gauge // Gauge for tracking the number of table compaction in non0 level
+
+	quitLock sync.Mutex      // Mutex protecting the quit channel access
+	quitChan chan chan error // Quit channel to stop the metrics collection before closing the database
+
+	log log.Logger // Contextual logger tracking the database path
+
+	level0Comp    uint32     // Number of level zero compactions (atomic)
+	nonLevel0Comp uint32     // Number of non-zero level compactions (atomic)
+	activeComp    int        // Number of running compactions
+	compStartTime time.Time  // Start of the current compaction run
+	stallStart    time.Time  // Start of the current write stall
+	stallWarned   time.Time  // Last time a write stall was reported to the user
+	listenLock    sync.Mutex // Lock protecting the event listener fields
+}
+
+func (d *Database) onCompactionBegin(info pebble.CompactionInfo) {
+	d.listenLock.Lock()
+	defer d.listenLock.Unlock()
+
+	if d.activeComp == 0 {
+		d.compStartTime = time.Now()
+	}
+	if len(info.Input) > 0 && info.Input[0].Level == 0 {
+		atomic.AddUint32(&d.level0Comp, 1)
+	} else {
+		atomic.AddUint32(&d.nonLevel0Comp, 1)
+	}
+	d.activeComp++
+}
+
+func (d *Database) onCompactionEnd(info pebble.CompactionInfo) {
+	d.listenLock.Lock()
+	defer d.listenLock.Unlock()
+
+	if d.activeComp == 1 {
+		atomic.AddInt64(&d.compTime, int64(time.Since(d.compStartTime)))
+	}
+	if d.activeComp > 0 {
+		d.activeComp--
+	}
+}
+
+func (d *Database) onWriteStallBegin(info pebble.WriteStallBeginInfo) {
+	d.listenLock.Lock()
+	defer d.listenLock.Unlock()
+
+	d.stallStart = time.Now()
+
+	// If a warning that db is stalled has been displayed, any subsequent warnings
+	// will be withheld for one minute not to overwhelm the user.
+	if time.Since(d.stallWarned) > degradationWarnInterval {
+		d.log.Warn("Database compacting, degraded performance", "reason", info.Reason)
+		d.stallWarned = time.Now()
+	}
+}
+
+func (d *Database) onWriteStallEnd() {
+	d.listenLock.Lock()
+	defer d.listenLock.Unlock()
+
+	atomic.AddInt64(&d.stallTime, int64(time.Since(d.stallStart)))
+	atomic.AddInt64(&d.stallCount, 1)
+}
+
+// New returns a wrapped pebble DB object. The namespace is the prefix that the
+// metrics reporting should use for surfacing internal stats.
+func New(file string, cache int, handles int, namespace string, readonly bool) (*Database, error) {
+	// Ensure we have some minimal caching and file guarantees
+	if cache < minCache {
+		cache = minCache
+	}
+	if handles < minHandles {
+		handles = minHandles
+	}
+	logger := log.New("database", file)
+	logCtx := []interface{}{"cache", common.StorageSize(cache * 1024 * 1024), "handles", handles}
+	if readonly {
+		logCtx = append(logCtx, "readonly", "true")
+	}
+	logger.Info("Allocated cache and file handles", logCtx...)
+
+	// Two memory tables are configured, which is identical to leveldb: a frozen
+	// one being flushed and a live one accepting writes.
+	memTableLimit := 2
+	memTableSize := cache * 1024 * 1024 / 2 / memTableLimit
+	if memTableSize > maxMemTableSize {
+		memTableSize = maxMemTableSize
+	}
+	db := &Database{
+		fn:       file,
+		log:      logger,
+		quitChan: make(chan chan error),
+	}
+	levels := make([]pebble.LevelOptions, 7)
+	for i := range levels {
+		levels[i] = pebble.LevelOptions{
+			TargetFileSize: int64(2*1024*1024) << uint(i),
+			FilterPolicy:   bloom.FilterPolicy(10),
+		}
+	}
+	opt := &pebble.Options{
+		// Pebble has a single combined cache area and the write buffers are taken
+		// from this too. Assign all available memory allowance for cache.
+		Cache:        pebble.NewCache(int64(cache * 1024 * 1024)),
+		MaxOpenFiles: handles,
+
+		// The size of memory table (as well as the write buffer). Note, there may
+		// be more than two memory tables in the system.
+		MemTableSize: memTableSize,
+
+		// MemTableStopWritesThreshold places a hard limit on the size of the
+		// existent MemTables (including the frozen one). Writes are stopped
+		// instead of growing the memory usage unboundedly.
+		MemTableStopWritesThreshold: memTableLimit,
+
+		// The default compaction concurrency of a single goroutine is far too
+		// low to keep up with block imports, use all the available cores.
+		MaxConcurrentCompactions: func() int { return runtime.NumCPU() },
+
+		Levels:   levels,
+		ReadOnly: readonly,
+		EventListener: &pebble.EventListener{
+			CompactionBegin: db.onCompactionBegin,
+			CompactionEnd:   db.onCompactionEnd,
+			WriteStallBegin: db.onWriteStallBegin,
+			WriteStallEnd:   db.onWriteStallEnd,
+		},
+	}
+	// Disable seek compaction explicitly, as with leveldb
+	opt.Experimental.ReadSamplingMultiplier = -1
+
+	innerDB, err := pebble.Open(file, opt)
+	if err != nil {
+		return nil, err
+	}
+	db.db = innerDB
+
+	db.compTimeMeter = metrics.NewRegisteredMeter(namespace+"compact/time", nil)
+	db.compReadMeter = metrics.NewRegisteredMeter(namespace+"compact/input", nil)
+	db.compWriteMeter = metrics.NewRegisteredMeter(namespace+"compact/output", nil)
+	db.diskSizeGauge = metrics.NewRegisteredGauge(namespace+"disk/size", nil)
+	db.diskWriteMeter = metrics.NewRegisteredMeter(namespace+"disk/write", nil)
+	db.writeDelayMeter = metrics.NewRegisteredMeter(namespace+"compact/writedelay/duration", nil)
+	db.writeDelayNMeter = metrics.NewRegisteredMeter(namespace+"compact/writedelay/counter", nil)
+	db.memCompGauge = metrics.NewRegisteredGauge(namespace+"compact/memory", nil)
+	db.level0CompGauge = metrics.NewRegisteredGauge(namespace+"compact/level0", nil)
+	db.nonlevel0CompGauge = metrics.NewRegisteredGauge(namespace+"compact/nonlevel0", nil)
+
+	// Start up the metrics gathering and return
+	go db.meter(metricsGatheringInterval)
+	return db, nil
+}
+
+// Close stops the metrics collection, flushes any pending data to disk and closes
+// all io accesses to the underlying key-value store.
+func (d *Database) Close() error {
+	d.quitLock.Lock()
+	defer d.quitLock.Unlock()
+
+	if d.quitChan != nil {
+		errc := make(chan error)
+		d.quitChan <- errc
+		if err := <-errc; err != nil {
+			d.log.Error("Metrics collection failed", "err", err)
+		}
+		d.quitChan = nil
+	}
+	return d.db.Close()
+}
+
+// Has retrieves if a key is present in the key-value store.
+func (d *Database) Has(key []byte) (bool, error) {
+	_, closer, err := d.db.Get(key)
+	if err == pebble.ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	closer.Close()
+	return true, nil
+}
+
+// Get retrieves the given key if it's present in the key-value store.
+func (d *Database) Get(key []byte) ([]byte, error) {
+	dat, closer, err := d.db.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	ret := common.CopyBytes(dat)
+	closer.Close()
+	return ret, nil
+}
+
+// Put inserts the given value into the key-value store.
+func (d *Database) Put(key []byte, value []byte) error {
+	return d.db.Set(key, value, pebble.NoSync)
+}
+
+// Delete removes the key from the key-value store.
+func (d *Database) Delete(key []byte) error {
+	return d.db.Delete(key, nil)
+}
+
+// NewBatch creates a write-only key-value store that buffers changes to its host
+// database until a final write is called.
+func (d *Database) NewBatch() highdb.Batch {
+	return &batch{
+		b: d.db.NewBatch(),
+	}
+}
+
+// upperBound returns the upper bound for the given prefix
+func upperBound(prefix []byte) (limit []byte) {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		c := prefix[i]
+		if c == 0xff {
+			continue
+		}
+		limit = make([]byte, i+1)
+		copy(limit, prefix)
+		limit[i] = c + 1
+		break
+	}
+	return limit
+}
+
+// NewIterator creates a binary-alphabetical iterator over a subset
+// of database content with a particular key prefix, starting at a particular
+// initial key (or after, if it does not exist).
+func (d *Database) NewIterator(prefix []byte, start []byte) highdb.Iterator {
+	iter := d.db.NewIter(&pebble.IterOptions{
+		LowerBound: append(common.CopyBytes(prefix), start...),
+		UpperBound: upperBound(prefix),
+	})
+	iter.First()
+	return &pebbleIterator{iter: iter, moved: true}
+}
+
+// Stat returns a particular internal stat of the database. The only supported
+// property is "pebble.stats", the metrics table of all the levels.
+func (d *Database) Stat(property string) (string, error) {
+	if property != statsProperty {
+		return "", errUnknownProperty
+	}
+	return d.db.Metrics().String(), nil
+}
+
+// Compact flattens the underlying data store for the given key range. In essence,
+// deleted and overwritten versions are discarded, and the data is rearranged to
+// reduce the cost of operations needed to access them.
+//
+// A nil start is treated as a key before all keys in the data store; a nil limit
+// is treated as a key after all keys in the data store. If both is nil then it
+// will compact entire data store.
+func (d *Database) Compact(start []byte, limit []byte) error {
+	// Pebble has no way to represent the end of the key range, use a key larger
+	// than any in the database instead. Trie nodes may share a prefix of 0xff-s,
+	// so only a full hash sized key of 0xff-s is guaranteed to be larger.
+	if limit == nil {
+		limit = bytes.Repeat([]byte{0xff}, common.HashLength)
+	}
+	return d.db.Compact(start, limit, true)
+}
+
+// Path returns the path to the database directory.
+func (d *Database) Path() string {
+	return d.fn
+}
+
+// meter periodically retrieves internal pebble counters and reports them to
+// the metrics subsystem.
+func (d *Database) meter(refresh time.Duration) {
+	var (
+		errc  chan error
+		timer = time.NewTimer(refresh)
+
+		// Current and previous values of the cumulative counters
+		compTimes   [2]int64
+		stallTimes  [2]int64
+		stallCounts [2]int64
+		compWrites  [2]int64
+		compReads   [2]int64
+		nWrites     [2]int64
+	)
+	defer timer.Stop()
+
+	// Iterate ad infinitum and collect the stats
+	for i := 1; errc == nil; i++ {
+		var (
+			compWrite int64
+			compRead  int64
+			nWrite    int64
+
+			stats = d.db.Metrics()
+		)
+		compTimes[i%2] = atomic.LoadInt64(&d.compTime)
+		stallTimes[i%2] = atomic.LoadInt64(&d.stallTime)
+		stallCounts[i%2] = atomic.LoadInt64(&d.stallCount)
+
+		for _, level := range stats.Levels {
+			nWrite += int64(level.BytesCompacted)
+			nWrite += int64(level.BytesFlushed)
+			compWrite += int64(level.BytesCompacted)
+			compRead += int64(level.BytesRead)
+		}
+		nWrite += int64(stats.WAL.BytesWritten)
+
+		compWrites[i%2] = compWrite
+		compReads[i%2] = compRead
+		nWrites[i%2] = nWrite
+
+		d.writeDelayNMeter.Mark(stallCounts[i%2] - stallCounts[(i-1)%2])
+		d.writeDelayMeter.Mark(stallTimes[i%2] - stallTimes[(i-1)%2])
+		d.compTimeMeter.Mark(compTimes[i%2] - compTimes[(i-1)%2])
+		d.compReadMeter.Mark(compReads[i%2] - compReads[(i-1)%2])
+		d.compWriteMeter.Mark(compWrites[i%2] - compWrites[(i-1)%2])
+		d.diskWriteMeter.Mark(nWrites[i%2] - nWrites[(i-1)%2])
+		d.diskSizeGauge.Update(int64(stats.DiskSpaceUsage()))
+		d.memCompGauge.Update(stats.Flush.Count)
+		d.level0CompGauge.Update(int64(atomic.LoadUint32(&d.level0Comp)))
+		d.nonlevel0CompGauge.Update(int64(atomic.LoadUint32(&d.nonLevel0Comp)))
+
+		// Sleep a bit, then repeat the stats collection
+		select {
+		case errc = <-d.quitChan:
+			// Quit requesting, stop hammering the database
+		case <-timer.C:
+			timer.Reset(refresh)
+			// Timeout, gather a new set of stats
+		}
+	}
+	errc <- nil
+}
+
+// batch is a write-only batch that commits changes to its host database
+// when Write is called. A batch cannot be used concurrently.
+type batch struct {
+	b    *pebble.Batch
+	size int
+}
+
+// Put inserts the given value into the batch for later committing.
+func (b *batch) Put(key, value []byte) error {
+	b.b.Set(key, value, nil)
+	b.size += len(value)
+	return nil
+}
+
+// Delete inserts the a key removal into the batch for later committing.
+func (b *batch) Delete(key []byte) error {
+	b.b.Delete(key, nil)
+	b.size++
+	return nil
+}
+
+// ValueSize retrieves the amount of data queued up for writing.
+func (b *batch) ValueSize() int {
+	return b.size
+}
+
+// Write flushes any accumulated data to disk.
+func (b *batch) Write() error {
+	return b.b.Commit(pebble.NoSync)
+}
+
+// Reset resets the batch for reuse.
+func (b *batch) Reset() {
+	b.b.Reset()
+	b.size = 0
+}
+
+// Replay replays the batch contents.
+func (b *batch) Replay(w highdb.KeyValueWriter) error {
+	reader := b.b.Reader()
+	for {
+		kind, k, v, ok := reader.Next()
+		if !ok {
+			break
+		}
+		// The (k,v) slices might be overwritten if the batch is reset/reused,
+		// and the receiver should copy them if they are to be retained long-term.
+		if kind == pebble.InternalKeyKindSet {
+			if err := w.Put(k, v); err != nil {
+				return err
+			}
+		} else if kind == pebble.InternalKeyKindDelete {
+			if err := w.Delete(k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pebbleIterator is a wrapper of underlying iterator in storage engine.
+// The purpose of this structure is to implement the missing APIs.
+type pebbleIterator struct {
+	iter     *pebble.Iterator
+	moved    bool
+	released bool
+}
+
+// Next moves the iterator to the next key/value pair. It returns whether the
+// iterator is exhausted.
+func (iter *pebbleIterator) Next() bool {
+	if iter.moved {
+		iter.moved = false
+		return iter.iter.Valid()
+	}
+	return iter.iter.Next()
+}
+
+// Error returns any accumulated error. Exhausting all the key/value pairs
+// is not considered to be an error.
+func (iter *pebbleIterator) Error() error {
+	return iter.iter.Error()
+}
+
+// Key returns the key of the current key/value pair, or nil if done. The caller
+// should not modify the contents of the returned slice, and its contents may
+// change on the next call to Next.
+func (iter *pebbleIterator) Key() []byte {
+	return iter.iter.Key()
+}
+
+// Value returns the value of the current key/value pair, or nil if done. The
+// caller should not modify the contents of the returned slice, and its contents
+// may change on the next call to Next.
+func (iter *pebbleIterator) Value() []byte {
+	return iter.iter.Value()
+}
+
+// Release releases associated resources. Release should always succeed and can
+// be called multiple times without causing error.
+func (iter *pebbleIterator) Release() {
+	if !iter.released {
+		iter.iter.Close()
+		iter.released = true
+	}
+}
@@ -103,6 +103,22 @@ type SyncProgress struct {
 	HighestBlock  uint64 // Highest alleged block number in the chain
 	PulledStates  uint64 // Number of state trie entries already downloaded
 	KnownStates   uint64 // Total number of state trie entries known about
+
+	// Snap sync progress, zero if not snap syncing
+	SyncedAccounts      uint64 // Number of accounts downloaded
+	SyncedAccountBytes  uint64 // Number of account trie bytes persisted to disk
+	SyncedBytecodes     uint64 // Number of bytecodes downloaded
+	SyncedBytecodeBytes uint64 // Number of bytecode bytes downloaded
+	SyncedStorage       uint64 // Number of storage slots downloaded
+	SyncedStorageBytes  uint64 // Number of storage trie bytes persisted to disk
+
+	HealedTrienodes     uint64 // Number of state trie nodes downloaded during healing
+	HealedTrienodeBytes uint64 // Number of state trie bytes persisted to disk during healing
+	HealedBytecodes     uint64 // Number of bytecodes downloaded during healing
+	HealedBytecodeBytes uint64 // Number of bytecode bytes persisted to disk during healing
+	HealingPending      uint64 // Number of state trie nodes and bytecodes pending healing
+
+	EstimatedCompletion uint64 // Estimated unix time of the state download completion (0 = unknown)
 }
 
 // ChainSyncReader wraps access to the node's current sync status. If there's no
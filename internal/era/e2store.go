@@ -0,0 +1,97 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// headerSize is the size of the header of each entry: type (2), length (4) and
+// reserved (2), all little endian.
+const headerSize = 8
+
+// errReservedNotZero is returned if the reserved bytes of an entry header are in
+// use, which no known version of the format does.
+var errReservedNotZero = errors.New("reserved header bytes not zero")
+
+// entry is a single typed, length prefixed record of an e2store file.
+type entry struct {
+	typ   uint16
+	value []byte
+}
+
+// writer appends entries to an e2store stream, tracking the amount of data
+// written to allow indexing the entries.
+type writer struct {
+	w       io.Writer
+	written uint64
+}
+
+// write appends an entry with the given type and value to the stream, returning
+// the offset at which the entry starts.
+func (w *writer) write(typ uint16, value []byte) (uint64, error) {
+	offset := w.written
+
+	record := make([]byte, headerSize+len(value))
+	binary.LittleEndian.PutUint16(record[0:2], typ)
+	binary.LittleEndian.PutUint32(record[2:6], uint32(len(value)))
+	copy(record[headerSize:], value)
+
+	n, err := w.w.Write(record)
+	w.written += uint64(n)
+	return offset, err
+}
+
+// readEntry reads the entry starting at the given offset, returning it along
+// with its total size including the header.
+func readEntry(r io.ReaderAt, offset int64) (*entry, int64, error) {
+	header := make([]byte, headerSize)
+	if _, err := r.ReadAt(header, offset); err != nil {
+		return nil, 0, err
+	}
+	if header[6] != 0 || header[7] != 0 {
+		return nil, 0, errReservedNotZero
+	}
+	var (
+		typ    = binary.LittleEndian.Uint16(header[0:2])
+		length = binary.LittleEndian.Uint32(header[2:6])
+		value  = make([]byte, length)
+	)
+	if _, err := r.ReadAt(value, offset+headerSize); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, 0, err
+	}
+	return &entry{typ: typ, value: value}, headerSize + int64(length), nil
+}
+
+// readEntryOf reads the entry starting at the given offset and ensures it is of
+// the expected type.
+func readEntryOf(r io.ReaderAt, offset int64, typ uint16) (*entry, int64, error) {
+	e, size, err := readEntry(r, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if e.typ != typ {
+		return nil, 0, fmt.Errorf("entry type mismatch at offset %d: have %#x, want %#x", offset, e.typ, typ)
+	}
+	return e, size, nil
+}
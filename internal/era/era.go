@@ -0,0 +1,386 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package era implements an archive format for the chain history, storing the
+// blocks, receipts and total difficulties of a fixed size epoch of the chain in
+// a single self-describing, verifiable file.
+//
+// An era file is an e2store stream, a sequence of typed and length prefixed
+// entries, laid out as:
+//
+//	Version | block-tuple* | Accumulator | BlockIndex
+//	block-tuple := CompressedHeader | CompressedBody | CompressedReceipts | TotalDifficulty
+//
+// Headers, bodies and receipts are RLP encoded and snappy compressed, with the
+// receipts in their consensus encoding. The accumulator commits to the hashes
+// and total difficulties of all the blocks of the epoch, and the block index
+// holds the number of the first block, the offsets of the block tuples and the
+// number of blocks, allowing random access from the end of the file.
+package era
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/core/types"
+	"github.com/420integrated/go-highcoin/crypto"
+	"github.com/420integrated/go-highcoin/rlp"
+	"github.com/420integrated/go-highcoin/trie"
+	"github.com/golang/snappy"
+)
+
+const (
+	typeVersion            uint16 = 0x3265
+	typeCompressedHeader   uint16 = 0x03
+	typeCompressedBody     uint16 = 0x04
+	typeCompressedReceipts uint16 = 0x05
+	typeTotalDifficulty    uint16 = 0x06
+	typeAccumulator        uint16 = 0x07
+	typeBlockIndex         uint16 = 0x3266
+
+	// MaxSize is the number of blocks in an epoch, the maximum number of blocks
+	// in an era file. Epoch N holds the blocks [N*MaxSize, (N+1)*MaxSize).
+	MaxSize = 8192
+)
+
+var (
+	errEmpty        = errors.New("era file without blocks")
+	errTooManyBlock = errors.New("too many blocks for an era file")
+	errFinalized    = errors.New("era file already finalized")
+)
+
+// Filename returns the canonical name of the era file of the given network and
+// epoch, with the accumulator root as a checksum.
+func Filename(network string, epoch uint64, root common.Hash) string {
+	return fmt.Sprintf("%s-%05d-%x.era", network, epoch, root[:4])
+}
+
+// ReadDir returns the paths of the era files of the given network found in the
+// directory, ordered by epoch.
+func ReadDir(dir, network string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, network+"-?????-????????.era"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// ComputeAccumulator calculates the root committing to the hashes and total
+// difficulties of the blocks of an epoch. The leaves are the hashes of the block
+// hash and total difficulty pairs, merkleized as a binary tree padded to the
+// epoch size, with the number of blocks mixed into the root.
+func ComputeAccumulator(hashes []common.Hash, tds []*big.Int) (common.Hash, error) {
+	if len(hashes) != len(tds) {
+		return common.Hash{}, fmt.Errorf("hash and difficulty count mismatch: %d != %d", len(hashes), len(tds))
+	}
+	if len(hashes) > MaxSize {
+		return common.Hash{}, errTooManyBlock
+	}
+	nodes := make([]common.Hash, MaxSize)
+	for i := range hashes {
+		td := common.BigToHash(tds[i])
+		nodes[i] = crypto.Keccak256Hash(hashes[i][:], td[:])
+	}
+	for len(nodes) > 1 {
+		for i := 0; i < len(nodes)/2; i++ {
+			nodes[i] = crypto.Keccak256Hash(nodes[2*i][:], nodes[2*i+1][:])
+		}
+		nodes = nodes[:len(nodes)/2]
+	}
+	var count common.Hash
+	binary.LittleEndian.PutUint64(count[:], uint64(len(hashes)))
+	return crypto.Keccak256Hash(nodes[0][:], count[:]), nil
+}
+
+// Builder writes the blocks of an epoch into an era file.
+type Builder struct {
+	w         writer
+	start     uint64
+	offsets   []uint64
+	hashes    []common.Hash
+	tds       []*big.Int
+	finalized bool
+}
+
+// NewBuilder creates an era file builder writing into the given stream.
+func NewBuilder(w io.Writer) *Builder {
+	return &Builder{w: writer{w: w}}
+}
+
+// Add appends a block along with its receipts and total difficulty. The blocks
+// must be added in order, without gaps.
+func (b *Builder) Add(block *types.Block, receipts types.Receipts, td *big.Int) error {
+	if b.finalized {
+		return errFinalized
+	}
+	if len(b.offsets) == 0 {
+		if _, err := b.w.write(typeVersion, nil); err != nil {
+			return err
+		}
+		b.start = block.NumberU64()
+	}
+	if len(b.offsets) == MaxSize {
+		return errTooManyBlock
+	}
+	if want := b.start + uint64(len(b.offsets)); block.NumberU64() != want {
+		return fmt.Errorf("block number mismatch: have %d, want %d", block.NumberU64(), want)
+	}
+	header, err := rlp.EncodeToBytes(block.Header())
+	if err != nil {
+		return err
+	}
+	body, err := rlp.EncodeToBytes(block.Body())
+	if err != nil {
+		return err
+	}
+	rs, err := rlp.EncodeToBytes(receipts)
+	if err != nil {
+		return err
+	}
+	offset, err := b.w.write(typeCompressedHeader, snappy.Encode(nil, header))
+	if err != nil {
+		return err
+	}
+	if _, err := b.w.write(typeCompressedBody, snappy.Encode(nil, body)); err != nil {
+		return err
+	}
+	if _, err := b.w.write(typeCompressedReceipts, snappy.Encode(nil, rs)); err != nil {
+		return err
+	}
+	if _, err := b.w.write(typeTotalDifficulty, common.BigToHash(td).Bytes()); err != nil {
+		return err
+	}
+	b.offsets = append(b.offsets, offset)
+	b.hashes = append(b.hashes, block.Hash())
+	b.tds = append(b.tds, new(big.Int).Set(td))
+	return nil
+}
+
+// Finalize writes the accumulator and the block index, completing the era file.
+// The accumulator root is returned.
+func (b *Builder) Finalize() (common.Hash, error) {
+	if b.finalized {
+		return common.Hash{}, errFinalized
+	}
+	if len(b.offsets) == 0 {
+		return common.Hash{}, errEmpty
+	}
+	root, err := ComputeAccumulator(b.hashes, b.tds)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if _, err := b.w.write(typeAccumulator, root[:]); err != nil {
+		return common.Hash{}, err
+	}
+	index := make([]byte, 16+8*len(b.offsets))
+	binary.LittleEndian.PutUint64(index, b.start)
+	for i, offset := range b.offsets {
+		binary.LittleEndian.PutUint64(index[8+8*i:], offset)
+	}
+	binary.LittleEndian.PutUint64(index[8+8*len(b.offsets):], uint64(len(b.offsets)))
+	if _, err := b.w.write(typeBlockIndex, index); err != nil {
+		return common.Hash{}, err
+	}
+	b.finalized = true
+	return root, nil
+}
+
+// Era is an open era file.
+type Era struct {
+	f       *os.File
+	start   uint64
+	offsets []int64
+}
+
+// Open opens the era file at the given path, loading its block index.
+func Open(path string) (*Era, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	e, err := newEra(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return e, nil
+}
+
+func newEra(f *os.File) (*Era, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	// The block count is the last field of the index at the end of the file
+	if info.Size() < headerSize+16 {
+		return nil, errEmpty
+	}
+	blob := make([]byte, 8)
+	if _, err := f.ReadAt(blob, info.Size()-8); err != nil {
+		return nil, err
+	}
+	count := binary.LittleEndian.Uint64(blob)
+	if count == 0 {
+		return nil, errEmpty
+	}
+	if count > MaxSize {
+		return nil, errTooManyBlock
+	}
+	index, _, err := readEntryOf(f, info.Size()-headerSize-16-8*int64(count), typeBlockIndex)
+	if err != nil {
+		return nil, err
+	}
+	e := &Era{
+		f:       f,
+		start:   binary.LittleEndian.Uint64(index.value),
+		offsets: make([]int64, count),
+	}
+	for i := range e.offsets {
+		e.offsets[i] = int64(binary.LittleEndian.Uint64(index.value[8+8*i:]))
+	}
+	return e, nil
+}
+
+// Close closes the era file.
+func (e *Era) Close() error {
+	return e.f.Close()
+}
+
+// Start returns the number of the first block in the era file.
+func (e *Era) Start() uint64 {
+	return e.start
+}
+
+// Count returns the number of blocks in the era file.
+func (e *Era) Count() uint64 {
+	return uint64(len(e.offsets))
+}
+
+// Accumulator returns the accumulator root stored in the era file.
+func (e *Era) Accumulator() (common.Hash, error) {
+	info, err := e.f.Stat()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	offset := info.Size() - headerSize - 16 - 8*int64(len(e.offsets)) - headerSize - common.HashLength
+	entry, _, err := readEntryOf(e.f, offset, typeAccumulator)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(entry.value), nil
+}
+
+// GetBlockByNumber retrieves a block along with its receipts and total difficulty
+// from the era file.
+func (e *Era) GetBlockByNumber(number uint64) (*types.Block, types.Receipts, *big.Int, error) {
+	if number < e.start || number >= e.start+e.Count() {
+		return nil, nil, nil, fmt.Errorf("block %d out of range [%d, %d)", number, e.start, e.start+e.Count())
+	}
+	var (
+		offset  = e.offsets[number-e.start]
+		entries = make([]*entry, 4)
+	)
+	for i, typ := range []uint16{typeCompressedHeader, typeCompressedBody, typeCompressedReceipts, typeTotalDifficulty} {
+		entry, size, err := readEntryOf(e.f, offset, typ)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		entries[i], offset = entry, offset+size
+	}
+	header := new(types.Header)
+	if err := decodeCompressed(entries[0].value, header); err != nil {
+		return nil, nil, nil, fmt.Errorf("block %d header: %v", number, err)
+	}
+	body := new(types.Body)
+	if err := decodeCompressed(entries[1].value, body); err != nil {
+		return nil, nil, nil, fmt.Errorf("block %d body: %v", number, err)
+	}
+	var receipts types.Receipts
+	if err := decodeCompressed(entries[2].value, &receipts); err != nil {
+		return nil, nil, nil, fmt.Errorf("block %d receipts: %v", number, err)
+	}
+	block := types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles)
+	return block, receipts, new(big.Int).SetBytes(entries[3].value), nil
+}
+
+// Verify checks the integrity of the era file: the blocks are linked by their
+// parent hashes, the bodies and receipts match the roots in the headers, the
+// total difficulties add up and the accumulator commits to all of them.
+func (e *Era) Verify() error {
+	var (
+		hashes = make([]common.Hash, 0, e.Count())
+		tds    = make([]*big.Int, 0, e.Count())
+		parent *types.Block
+	)
+	for number := e.start; number < e.start+e.Count(); number++ {
+		block, receipts, td, err := e.GetBlockByNumber(number)
+		if err != nil {
+			return err
+		}
+		if block.NumberU64() != number {
+			return fmt.Errorf("block %d: number mismatch: have %d", number, block.NumberU64())
+		}
+		if parent != nil {
+			if block.ParentHash() != parent.Hash() {
+				return fmt.Errorf("block %d: parent hash mismatch: have %x, want %x", number, block.ParentHash(), parent.Hash())
+			}
+			if want := new(big.Int).Add(tds[len(tds)-1], block.Difficulty()); td.Cmp(want) != 0 {
+				return fmt.Errorf("block %d: total difficulty mismatch: have %v, want %v", number, td, want)
+			}
+		}
+		if hash := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); hash != block.TxHash() {
+			return fmt.Errorf("block %d: transaction root mismatch: have %x, want %x", number, hash, block.TxHash())
+		}
+		if hash := types.CalcUncleHash(block.Uncles()); hash != block.UncleHash() {
+			return fmt.Errorf("block %d: uncle hash mismatch: have %x, want %x", number, hash, block.UncleHash())
+		}
+		if hash := types.DeriveSha(receipts, trie.NewStackTrie(nil)); hash != block.ReceiptHash() {
+			return fmt.Errorf("block %d: receipt root mismatch: have %x, want %x", number, hash, block.ReceiptHash())
+		}
+		hashes = append(hashes, block.Hash())
+		tds = append(tds, td)
+		parent = block
+	}
+	root, err := ComputeAccumulator(hashes, tds)
+	if err != nil {
+		return err
+	}
+	stored, err := e.Accumulator()
+	if err != nil {
+		return err
+	}
+	if root != stored {
+		return fmt.Errorf("accumulator mismatch: have %x, want %x", root, stored)
+	}
+	return nil
+}
+
+// decodeCompressed decompresses and RLP decodes an entry value.
+func decodeCompressed(blob []byte, val interface{}) error {
+	data, err := snappy.Decode(nil, blob)
+	if err != nil {
+		return err
+	}
+	return rlp.DecodeBytes(data, val)
+}
@@ -0,0 +1,160 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/consensus/ethash"
+	"github.com/420integrated/go-highcoin/core"
+	"github.com/420integrated/go-highcoin/core/rawdb"
+	"github.com/420integrated/go-highcoin/core/types"
+	"github.com/420integrated/go-highcoin/crypto"
+	"github.com/420integrated/go-highcoin/params"
+)
+
+// buildTestEra generates a chain with transactions and writes its blocks, except
+// the genesis, into an era file, returning its path.
+func buildTestEra(t *testing.T, dir string, n int) (string, []*types.Block, []types.Receipts) {
+	var (
+		key, _  = crypto.GenerateKey()
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &core.Genesis{Config: params.TestChainConfig, Alloc: core.GenesisAlloc{addr: {Balance: big.NewInt(1000000000)}}}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	blocks, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, n, func(i int, gen *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(addr), common.Address{0x01}, big.NewInt(1000), params.TxSmoke, nil, nil), signer, key)
+		gen.AddTx(tx)
+	})
+	f, err := ioutil.TempFile(dir, "")
+	if err != nil {
+		t.Fatalf("failed to create era file: %v", err)
+	}
+	defer f.Close()
+
+	builder := NewBuilder(f)
+	td := new(big.Int).Set(genesis.Difficulty())
+	for i, block := range blocks {
+		td.Add(td, block.Difficulty())
+		if err := builder.Add(block, receipts[i], td); err != nil {
+			t.Fatalf("failed to add block %d: %v", block.NumberU64(), err)
+		}
+	}
+	root, err := builder.Finalize()
+	if err != nil {
+		t.Fatalf("failed to finalize era file: %v", err)
+	}
+	path := filepath.Join(dir, Filename("test", 0, root))
+	if err := os.Rename(f.Name(), path); err != nil {
+		t.Fatalf("failed to rename era file: %v", err)
+	}
+	return path, blocks, receipts
+}
+
+// Tests that blocks written into an era file can be read back and verified.
+func TestEraRoundtrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path, blocks, receipts := buildTestEra(t, dir, 16)
+
+	e, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to open era file: %v", err)
+	}
+	defer e.Close()
+
+	if e.Start() != 1 || e.Count() != uint64(len(blocks)) {
+		t.Fatalf("range mismatch: have [%d, +%d), want [1, +%d)", e.Start(), e.Count(), len(blocks))
+	}
+	if err := e.Verify(); err != nil {
+		t.Fatalf("failed to verify era file: %v", err)
+	}
+	for i, want := range blocks {
+		block, rs, _, err := e.GetBlockByNumber(want.NumberU64())
+		if err != nil {
+			t.Fatalf("block %d: failed to read: %v", want.NumberU64(), err)
+		}
+		if block.Hash() != want.Hash() {
+			t.Errorf("block %d: hash mismatch: have %x, want %x", want.NumberU64(), block.Hash(), want.Hash())
+		}
+		if len(rs) != len(receipts[i]) || rs[0].CumulativeSmokeUsed != receipts[i][0].CumulativeSmokeUsed {
+			t.Errorf("block %d: receipts mismatch", want.NumberU64())
+		}
+	}
+	if _, _, _, err := e.GetBlockByNumber(uint64(len(blocks)) + 1); err == nil {
+		t.Errorf("out of range block retrieved")
+	}
+	files, err := ReadDir(dir, "test")
+	if err != nil || len(files) != 1 || files[0] != path {
+		t.Errorf("directory listing mismatch: have %v, %v, want %v", files, err, path)
+	}
+}
+
+// Tests that damage to the era file is detected by verification.
+func TestEraCorruption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path, _, _ := buildTestEra(t, dir, 4)
+
+	// Overwrite the total difficulty of the last block, leaving everything else
+	e, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to open era file: %v", err)
+	}
+	offset := e.offsets[len(e.offsets)-1]
+	for _, typ := range []uint16{typeCompressedHeader, typeCompressedBody, typeCompressedReceipts} {
+		_, size, err := readEntryOf(e.f, offset, typ)
+		if err != nil {
+			t.Fatalf("failed to read entry: %v", err)
+		}
+		offset += size
+	}
+	e.Close()
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("failed to open era file for writing: %v", err)
+	}
+	if _, err := f.WriteAt([]byte{0xff}, offset+headerSize+common.HashLength-1); err != nil {
+		t.Fatalf("failed to corrupt era file: %v", err)
+	}
+	f.Close()
+
+	if e, err = Open(path); err != nil {
+		t.Fatalf("failed to open corrupted era file: %v", err)
+	}
+	defer e.Close()
+
+	if err := e.Verify(); err == nil {
+		t.Fatalf("corrupted era file verified")
+	}
+}
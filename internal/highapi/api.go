@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sort"
 	"strings"
 	"time"
 
@@ -36,6 +37,7 @@ import (
 	"github.com/420integrated/go-highcoin/consensus/clique"
 	"github.com/420integrated/go-highcoin/consensus/ethash"
 	"github.com/420integrated/go-highcoin/core"
+	"github.com/420integrated/go-highcoin/core/state"
 	"github.com/420integrated/go-highcoin/core/types"
 	"github.com/420integrated/go-highcoin/core/vm"
 	"github.com/420integrated/go-highcoin/crypto"
@@ -64,6 +66,42 @@ func (s *PublicHighcoinAPI) SmokePrice(ctx context.Context) (*hexutil.Big, error
 	return (*hexutil.Big)(price), err
 }
 
+// feeHistoryResult is the result of a smoke price history request.
+type feeHistoryResult struct {
+	OldestBlock    *hexutil.Big     `json:"oldestBlock"`
+	SmokePrice     [][]*hexutil.Big `json:"smokePrice,omitempty"`
+	SmokeUsedRatio []float64        `json:"smokeUsedRatio"`
+	SmokeLimit     []hexutil.Uint64 `json:"smokeLimit"`
+}
+
+// FeeHistory returns the smoke price history of the requested range of blocks
+// ending with lastBlock: the given percentiles of the smoke prices paid in each
+// block, the ratio of smoke used and the smoke limit of the blocks.
+func (s *PublicHighcoinAPI) FeeHistory(ctx context.Context, blockCount hexutil.Uint, lastBlock rpc.BlockNumber, percentiles []float64) (*feeHistoryResult, error) {
+	oldest, prices, ratio, limit, err := s.b.FeeHistory(ctx, int(blockCount), lastBlock, percentiles)
+	if err != nil {
+		return nil, err
+	}
+	results := &feeHistoryResult{
+		OldestBlock:    (*hexutil.Big)(oldest),
+		SmokeUsedRatio: ratio,
+		SmokeLimit:     make([]hexutil.Uint64, len(limit)),
+	}
+	for i, v := range limit {
+		results.SmokeLimit[i] = hexutil.Uint64(v)
+	}
+	if prices != nil {
+		results.SmokePrice = make([][]*hexutil.Big, len(prices))
+		for i, p := range prices {
+			results.SmokePrice[i] = make([]*hexutil.Big, len(p))
+			for j, v := range p {
+				results.SmokePrice[i][j] = (*hexutil.Big)(v)
+			}
+		}
+	}
+	return results, nil
+}
+
 // Syncing returns false in case the node is currently not syncing with the network. It can be up to date or has not
 // yet received the latest block headers from its pears. In case it is synchronizing:
 // - startingBlock: block number this node started to synchronise from
@@ -71,6 +109,11 @@ func (s *PublicHighcoinAPI) SmokePrice(ctx context.Context) (*hexutil.Big, error
 // - highestBlock:  block number of the highest block header this node has received from peers
 // - pulledStates:  number of state entries processed until now
 // - knownStates:   number of known state entries that still need to be pulled
+//
+// During snap sync, the number of accounts, bytecodes and storage slots synced,
+// the number of trie nodes and bytecodes healed, the number of entries pending
+// healing and the estimated unix time of the state download completion are
+// returned too.
 func (s *PublicHighcoinAPI) Syncing() (interface{}, error) {
 	progress := s.b.Downloader().Progress()
 
@@ -85,6 +128,19 @@ func (s *PublicHighcoinAPI) Syncing() (interface{}, error) {
 		"highestBlock":  hexutil.Uint64(progress.HighestBlock),
 		"pulledStates":  hexutil.Uint64(progress.PulledStates),
 		"knownStates":   hexutil.Uint64(progress.KnownStates),
+
+		"syncedAccounts":      hexutil.Uint64(progress.SyncedAccounts),
+		"syncedAccountBytes":  hexutil.Uint64(progress.SyncedAccountBytes),
+		"syncedBytecodes":     hexutil.Uint64(progress.SyncedBytecodes),
+		"syncedBytecodeBytes": hexutil.Uint64(progress.SyncedBytecodeBytes),
+		"syncedStorage":       hexutil.Uint64(progress.SyncedStorage),
+		"syncedStorageBytes":  hexutil.Uint64(progress.SyncedStorageBytes),
+		"healedTrienodes":     hexutil.Uint64(progress.HealedTrienodes),
+		"healedTrienodeBytes": hexutil.Uint64(progress.HealedTrienodeBytes),
+		"healedBytecodes":     hexutil.Uint64(progress.HealedBytecodes),
+		"healedBytecodeBytes": hexutil.Uint64(progress.HealedBytecodeBytes),
+		"healingPending":      hexutil.Uint64(progress.HealingPending),
+		"estimatedCompletion": hexutil.Uint64(progress.EstimatedCompletion),
 	}, nil
 }
 
@@ -98,8 +154,144 @@ func NewPublicTxPoolAPI(b Backend) *PublicTxPoolAPI {
 	return &PublicTxPoolAPI{b}
 }
 
-// Content returns the transactions contained within the transaction pool.
-func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransaction {
+const (
+	// defaultTxPoolPageSize is the number of accounts in a page of the pool
+	// content if no limit is requested.
+	defaultTxPoolPageSize = 100
+
+	// maxTxPoolPageSize is the maximum number of accounts in a page of the pool
+	// content.
+	maxTxPoolPageSize = 1000
+)
+
+// TxPoolQuery selects a page of the transaction pool content. Pages consist of
+// the accounts with pooled transactions, ordered by address. The filters only
+// reduce the transactions returned for the accounts of a page, so a page may be
+// empty while further pages are available.
+type TxPoolQuery struct {
+	Cursor        *common.Address `json:"cursor"`        // First account of the page (nil = first account)
+	Limit         *hexutil.Uint   `json:"limit"`         // Maximum number of accounts of the page
+	From          *common.Address `json:"from"`          // Only return transactions of this sender
+	To            *common.Address `json:"to"`            // Only return transactions to this recipient
+	MinSmokePrice *hexutil.Big    `json:"minSmokePrice"` // Only return transactions paying at least this smoke price
+	State         string          `json:"state"`         // Only return "pending" or "queued" transactions
+}
+
+// TxPoolContentPage is a page of the transaction pool content.
+type TxPoolContentPage struct {
+	Pending map[string]map[string]*RPCTransaction `json:"pending"`
+	Queued  map[string]map[string]*RPCTransaction `json:"queued"`
+	Next    *common.Address                       `json:"next"` // Cursor of the next page (nil = last page)
+}
+
+// TxPoolInspectPage is a page of the flattened transaction pool content.
+type TxPoolInspectPage struct {
+	Pending map[string]map[string]string `json:"pending"`
+	Queued  map[string]map[string]string `json:"queued"`
+	Next    *common.Address              `json:"next"` // Cursor of the next page (nil = last page)
+}
+
+// walk iterates over the transactions in a page of the pool content selected by
+// the query, returning the cursor of the next page.
+func (s *PublicTxPoolAPI) walk(query *TxPoolQuery, visit func(queued bool, account common.Address, tx *types.Transaction)) (*common.Address, error) {
+	var pending, queued bool
+	switch query.State {
+	case "":
+		pending, queued = true, true
+	case "pending":
+		pending = true
+	case "queued":
+		queued = true
+	default:
+		return nil, fmt.Errorf("invalid transaction state %q", query.State)
+	}
+	limit := defaultTxPoolPageSize
+	if query.Limit != nil && *query.Limit > 0 {
+		limit = int(*query.Limit)
+	}
+	if limit > maxTxPoolPageSize {
+		limit = maxTxPoolPageSize
+	}
+	// Resolve the accounts of the requested page
+	var accounts []common.Address
+	if query.From != nil {
+		accounts = []common.Address{*query.From}
+	} else {
+		accounts = s.b.TxPoolAccounts()
+	}
+	if query.Cursor != nil {
+		start := sort.Search(len(accounts), func(i int) bool {
+			return bytes.Compare(accounts[i][:], query.Cursor[:]) >= 0
+		})
+		accounts = accounts[start:]
+	}
+	var next *common.Address
+	if len(accounts) > limit {
+		next = &accounts[limit]
+		accounts = accounts[:limit]
+	}
+	// Filter the transactions of the page
+	match := func(tx *types.Transaction) bool {
+		if query.To != nil && (tx.To() == nil || *tx.To() != *query.To) {
+			return false
+		}
+		if query.MinSmokePrice != nil && tx.SmokePriceIntCmp(query.MinSmokePrice.ToInt()) < 0 {
+			return false
+		}
+		return true
+	}
+	for _, account := range accounts {
+		pendingTxs, queuedTxs := s.b.TxPoolContentFrom(account)
+		if pending {
+			for _, tx := range pendingTxs {
+				if match(tx) {
+					visit(false, account, tx)
+				}
+			}
+		}
+		if queued {
+			for _, tx := range queuedTxs {
+				if match(tx) {
+					visit(true, account, tx)
+				}
+			}
+		}
+	}
+	return next, nil
+}
+
+// Content returns the transactions contained within the transaction pool. If a
+// query is given, only the selected page of the content is returned, along with
+// the cursor of the next page.
+func (s *PublicTxPoolAPI) Content(query *TxPoolQuery) (interface{}, error) {
+	if query == nil {
+		return s.content(), nil
+	}
+	page := &TxPoolContentPage{
+		Pending: make(map[string]map[string]*RPCTransaction),
+		Queued:  make(map[string]map[string]*RPCTransaction),
+	}
+	next, err := s.walk(query, func(queued bool, account common.Address, tx *types.Transaction) {
+		content := page.Pending
+		if queued {
+			content = page.Queued
+		}
+		dump := content[account.Hex()]
+		if dump == nil {
+			dump = make(map[string]*RPCTransaction)
+			content[account.Hex()] = dump
+		}
+		dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	page.Next = next
+	return page, nil
+}
+
+// content returns all the transactions contained within the transaction pool.
+func (s *PublicTxPoolAPI) content() map[string]map[string]map[string]*RPCTransaction {
 	content := map[string]map[string]map[string]*RPCTransaction{
 		"pending": make(map[string]map[string]*RPCTransaction),
 		"queued":  make(map[string]map[string]*RPCTransaction),
@@ -125,6 +317,29 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	return content
 }
 
+// ContentFrom returns the transactions contained within the transaction pool
+// sent by the given address.
+func (s *PublicTxPoolAPI) ContentFrom(addr common.Address) map[string]map[string]*RPCTransaction {
+	content := make(map[string]map[string]*RPCTransaction, 2)
+	pending, queue := s.b.TxPoolContentFrom(addr)
+
+	// Build the pending transactions
+	dump := make(map[string]*RPCTransaction, len(pending))
+	for _, tx := range pending {
+		dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+	}
+	content["pending"] = dump
+
+	// Build the queued transactions
+	dump = make(map[string]*RPCTransaction, len(queue))
+	for _, tx := range queue {
+		dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+	}
+	content["queued"] = dump
+
+	return content
+}
+
 // Status returns the number of pending and queued transaction in the pool.
 func (s *PublicTxPoolAPI) Status() map[string]hexutil.Uint {
 	pending, queue := s.b.Stats()
@@ -134,27 +349,57 @@ func (s *PublicTxPoolAPI) Status() map[string]hexutil.Uint {
 	}
 }
 
+// formatPoolTx flattens a transaction into an easily inspectable string.
+func formatPoolTx(tx *types.Transaction) string {
+	if to := tx.To(); to != nil {
+		return fmt.Sprintf("%s: %v marleys + %v smoke × %v marleys", tx.To().Hex(), tx.Value(), tx.Smoke(), tx.SmokePrice())
+	}
+	return fmt.Sprintf("contract creation: %v marleys + %v smoke × %v marleys", tx.Value(), tx.Smoke(), tx.SmokePrice())
+}
+
 // Inspect retrieves the content of the transaction pool and flattens it into an
-// easily inspectable list.
-func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
+// easily inspectable list. If a query is given, only the selected page of the
+// content is returned, along with the cursor of the next page.
+func (s *PublicTxPoolAPI) Inspect(query *TxPoolQuery) (interface{}, error) {
+	if query == nil {
+		return s.inspect(), nil
+	}
+	page := &TxPoolInspectPage{
+		Pending: make(map[string]map[string]string),
+		Queued:  make(map[string]map[string]string),
+	}
+	next, err := s.walk(query, func(queued bool, account common.Address, tx *types.Transaction) {
+		content := page.Pending
+		if queued {
+			content = page.Queued
+		}
+		dump := content[account.Hex()]
+		if dump == nil {
+			dump = make(map[string]string)
+			content[account.Hex()] = dump
+		}
+		dump[fmt.Sprintf("%d", tx.Nonce())] = formatPoolTx(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	page.Next = next
+	return page, nil
+}
+
+// inspect flattens all the transactions of the pool into an inspectable list.
+func (s *PublicTxPoolAPI) inspect() map[string]map[string]map[string]string {
 	content := map[string]map[string]map[string]string{
 		"pending": make(map[string]map[string]string),
 		"queued":  make(map[string]map[string]string),
 	}
 	pending, queue := s.b.TxPoolContent()
 
-	// Define a formatter to flatten a transaction into a string
-	var format = func(tx *types.Transaction) string {
-		if to := tx.To(); to != nil {
-			return fmt.Sprintf("%s: %v marleys + %v smoke × %v marleys", tx.To().Hex(), tx.Value(), tx.Smoke(), tx.SmokePrice())
-		}
-		return fmt.Sprintf("contract creation: %v marleys + %v smoke × %v marleys", tx.Value(), tx.Smoke(), tx.SmokePrice())
-	}
 	// Flatten the pending transactions
 	for account, txs := range pending {
 		dump := make(map[string]string)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = format(tx)
+			dump[fmt.Sprintf("%d", tx.Nonce())] = formatPoolTx(tx)
 		}
 		content["pending"][account.Hex()] = dump
 	}
@@ -162,7 +407,7 @@ func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
 	for account, txs := range queue {
 		dump := make(map[string]string)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = format(tx)
+			dump[fmt.Sprintf("%d", tx.Nonce())] = formatPoolTx(tx)
 		}
 		content["queued"][account.Hex()] = dump
 	}
@@ -798,13 +1043,13 @@ func (args *CallArgs) ToMessage(globalSmokeCap uint64) types.Message {
 	return msg
 }
 
-// account indicates the overriding fields of account during the execution of
-// a message call.
+// OverrideAccount indicates the overriding fields of account during the execution
+// of a message call.
 // Note, state and stateDiff can't be specified at the same time. If state is
 // set, message execution will only use the data in the given state. Otherwise
 // if statDiff is set, all diff will be applied first and then execute the call
 // message.
-type account struct {
+type OverrideAccount struct {
 	Nonce     *hexutil.Uint64              `json:"nonce"`
 	Code      *hexutil.Bytes               `json:"code"`
 	Balance   **hexutil.Big                `json:"balance"`
@@ -812,15 +1057,15 @@ type account struct {
 	StateDiff *map[common.Hash]common.Hash `json:"stateDiff"`
 }
 
-func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides map[common.Address]account, vmCfg vm.Config, timeout time.Duration, globalSmokeCap uint64) (*core.ExecutionResult, error) {
-	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
+// StateOverride is the collection of overridden accounts.
+type StateOverride map[common.Address]OverrideAccount
 
-	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
-	if state == nil || err != nil {
-		return nil, err
+// Apply overrides the fields of specified accounts into the given state.
+func (diff *StateOverride) Apply(state *state.StateDB) error {
+	if diff == nil {
+		return nil
 	}
-	// Override the fields of specified contracts before execution.
-	for addr, account := range overrides {
+	for addr, account := range *diff {
 		// Override account nonce.
 		if account.Nonce != nil {
 			state.SetNonce(addr, uint64(*account.Nonce))
@@ -834,7 +1079,7 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 			state.SetBalance(addr, (*big.Int)(*account.Balance))
 		}
 		if account.State != nil && account.StateDiff != nil {
-			return nil, fmt.Errorf("account %s has both 'state' and 'stateDiff'", addr.Hex())
+			return fmt.Errorf("account %s has both 'state' and 'stateDiff'", addr.Hex())
 		}
 		// Replace entire state if caller requires.
 		if account.State != nil {
@@ -847,6 +1092,77 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 			}
 		}
 	}
+	return nil
+}
+
+// BlockOverrides is a set of header fields to override when executing a message
+// call on top of a block.
+type BlockOverrides struct {
+	Number     *hexutil.Big    `json:"number"`
+	Difficulty *hexutil.Big    `json:"difficulty"`
+	Time       *hexutil.Big    `json:"time"`
+	SmokeLimit *hexutil.Uint64 `json:"smokeLimit"`
+	Coinbase   *common.Address `json:"coinbase"`
+}
+
+// Apply overrides the given header fields into the given block context.
+func (diff *BlockOverrides) Apply(blockCtx *vm.BlockContext) {
+	if diff == nil {
+		return
+	}
+	if diff.Number != nil {
+		blockCtx.BlockNumber = diff.Number.ToInt()
+	}
+	if diff.Difficulty != nil {
+		blockCtx.Difficulty = diff.Difficulty.ToInt()
+	}
+	if diff.Time != nil {
+		blockCtx.Time = diff.Time.ToInt()
+	}
+	if diff.SmokeLimit != nil {
+		blockCtx.SmokeLimit = uint64(*diff.SmokeLimit)
+	}
+	if diff.Coinbase != nil {
+		blockCtx.Coinbase = *diff.Coinbase
+	}
+}
+
+// ApplyHeader returns a copy of the given header with the overridden fields set,
+// so that an EVM created from it follows the chain rules of the overridden block.
+func (diff *BlockOverrides) ApplyHeader(header *types.Header) *types.Header {
+	header = types.CopyHeader(header)
+	if diff == nil {
+		return header
+	}
+	if diff.Number != nil {
+		header.Number = new(big.Int).Set(diff.Number.ToInt())
+	}
+	if diff.Difficulty != nil {
+		header.Difficulty = new(big.Int).Set(diff.Difficulty.ToInt())
+	}
+	if diff.Time != nil {
+		header.Time = diff.Time.ToInt().Uint64()
+	}
+	if diff.SmokeLimit != nil {
+		header.SmokeLimit = uint64(*diff.SmokeLimit)
+	}
+	if diff.Coinbase != nil {
+		header.Coinbase = *diff.Coinbase
+	}
+	return header
+}
+
+func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, vmCfg vm.Config, timeout time.Duration, globalSmokeCap uint64) (*core.ExecutionResult, error) {
+	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
+
+	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	// Override the fields of specified contracts before execution.
+	if err := overrides.Apply(state); err != nil {
+		return nil, err
+	}
 	// Setup context so it may be cancelled the call has completed
 	// or, in case of unmetered smoke, setup a context with a timeout.
 	var cancel context.CancelFunc
@@ -861,7 +1177,7 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 
 	// Get a new instance of the EVM.
 	msg := args.ToMessage(globalSmokeCap)
-	evm, vmError, err := b.GetEVM(ctx, msg, state, header)
+	evm, vmError, err := b.GetEVM(ctx, msg, state, header, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -925,12 +1241,8 @@ func (e *revertError) ErrorData() interface{} {
 //
 // Note, this function doesn't make and changes in the state/blockchain and is
 // useful to execute and retrieve values.
-func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *map[common.Address]account) (hexutil.Bytes, error) {
-	var accounts map[common.Address]account
-	if overrides != nil {
-		accounts = *overrides
-	}
-	result, err := DoCall(ctx, s.b, args, blockNrOrHash, accounts, vm.Config{}, 5*time.Second, s.b.RPCSmokeCap())
+func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride) (hexutil.Bytes, error) {
+	result, err := DoCall(ctx, s.b, args, blockNrOrHash, overrides, vm.Config{}, 5*time.Second, s.b.RPCSmokeCap())
 	if err != nil {
 		return nil, err
 	}
@@ -941,6 +1253,189 @@ func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNrOr
 	return result.Return(), result.Err
 }
 
+// BundleCall is a single entry of a simulated call bundle. It is either a plain
+// message call described by the embedded call arguments, or a signed and binary
+// encoded transaction if Raw is set.
+type BundleCall struct {
+	CallArgs
+	Raw *hexutil.Bytes `json:"raw"`
+}
+
+// bundleCallResult is the outcome of a single call within a simulated bundle.
+type bundleCallResult struct {
+	TxHash     *common.Hash   `json:"txHash,omitempty"`
+	ReturnData hexutil.Bytes  `json:"returnData"`
+	Logs       []*types.Log   `json:"logs"`
+	SmokeUsed  hexutil.Uint64 `json:"smokeUsed"`
+	Error      string         `json:"error,omitempty"`
+	Revert     hexutil.Bytes  `json:"revert,omitempty"`
+}
+
+// callBundleResult is the outcome of a simulated call bundle.
+type callBundleResult struct {
+	BlockNumber hexutil.Uint64      `json:"blockNumber"`
+	SmokeUsed   hexutil.Uint64      `json:"smokeUsed"`
+	Results     []*bundleCallResult `json:"results"`
+}
+
+// DoCallBundle executes the given calls in order on top of the state of the
+// requested block, each call seeing the state changes of the previous ones.
+//
+// Calls failing consensus checks (e.g. nonce or balance issues of signed
+// transactions) are reported in their result and leave the state untouched,
+// the remaining calls are still executed. The global smoke cap, if set, limits
+// the cumulative smoke of the whole bundle.
+func DoCallBundle(ctx context.Context, b Backend, calls []BundleCall, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides, timeout time.Duration, globalSmokeCap uint64) (*callBundleResult, error) {
+	defer func(start time.Time) { log.Debug("Executing EVM call bundle finished", "runtime", time.Since(start)) }(time.Now())
+
+	if len(calls) == 0 {
+		return nil, errors.New("empty call bundle")
+	}
+	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	// Override the fields of specified contracts before execution.
+	if err := overrides.Apply(state); err != nil {
+		return nil, err
+	}
+	// Override the block fields before any EVM is created, so the chain rules,
+	// precompiles and jump table follow the overridden block.
+	blockHeader := blockOverrides.ApplyHeader(header)
+
+	// Decode all signed transactions upfront to reject malformed bundles early
+	var (
+		signer = types.MakeSigner(b.ChainConfig(), blockHeader.Number)
+		txs    = make([]*types.Transaction, len(calls))
+	)
+	for i, call := range calls {
+		if call.Raw == nil {
+			continue
+		}
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(*call.Raw); err != nil {
+			return nil, fmt.Errorf("call %d: invalid transaction: %v", i, err)
+		}
+		txs[i] = tx
+	}
+	// Setup context so it may be cancelled the bundle has completed
+	// or, in case of unmetered smoke, setup a context with a timeout.
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	smokeCap := globalSmokeCap
+	if smokeCap == 0 {
+		smokeCap = math.MaxUint64
+	}
+	var (
+		gp      = new(core.SmokePool).AddSmoke(smokeCap)
+		results = make([]*bundleCallResult, 0, len(calls))
+		total   uint64
+	)
+	for i, call := range calls {
+		// Assemble the message to execute, either from the call or the signed tx
+		var (
+			msg    types.Message
+			txHash common.Hash
+			res    = new(bundleCallResult)
+		)
+		if tx := txs[i]; tx != nil {
+			if msg, err = tx.AsMessage(signer); err != nil {
+				return nil, fmt.Errorf("call %d: invalid transaction: %v", i, err)
+			}
+			txHash = tx.Hash()
+			res.TxHash = &txHash
+		} else {
+			if gp.Smoke() == 0 {
+				return nil, fmt.Errorf("call %d: bundle smoke cap %d exhausted", i, globalSmokeCap)
+			}
+			msg = call.ToMessage(gp.Smoke())
+		}
+		// Execute the message on top of the previous calls
+		evm, vmError, err := b.GetEVM(ctx, msg, state, blockHeader, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				evm.Cancel()
+			case <-done:
+			}
+		}()
+		state.Prepare(txHash, header.Hash(), i)
+		logs := len(state.GetLogs(txHash))
+		snap := state.Snapshot()
+
+		result, err := core.ApplyMessage(evm, msg, gp)
+		close(done)
+		if err := vmError(); err != nil {
+			return nil, err
+		}
+		// If the timer caused an abort, return an appropriate error message
+		if evm.Cancelled() {
+			return nil, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+		}
+		if err != nil {
+			state.RevertToSnapshot(snap)
+			res.Error = fmt.Sprintf("err: %v (supplied smoke %d)", err, msg.Smoke())
+			res.Logs = []*types.Log{}
+			results = append(results, res)
+			continue
+		}
+		state.Finalise(b.ChainConfig().IsEIP158(evm.Context.BlockNumber))
+
+		res.ReturnData = result.Return()
+		res.SmokeUsed = hexutil.Uint64(result.UsedSmoke)
+		res.Logs = state.GetLogs(txHash)[logs:]
+		if len(result.Revert()) > 0 {
+			revert := newRevertError(result)
+			res.Error, res.Revert = revert.Error(), result.Revert()
+		} else if result.Err != nil {
+			res.Error = result.Err.Error()
+		}
+		total += result.UsedSmoke
+		results = append(results, res)
+	}
+	return &callBundleResult{
+		BlockNumber: hexutil.Uint64(blockHeader.Number.Uint64()),
+		SmokeUsed:   hexutil.Uint64(total),
+		Results:     results,
+	}, nil
+}
+
+// Simulate executes an ordered list of message calls and signed transactions
+// on top of the state of the given block, each one seeing the state changes of
+// the previous ones. It returns the return data, logs, smoke used and any error
+// or revert reason of every call.
+//
+// The caller can specify a batch of contract fields and block header fields to
+// override, the same way as for high_call and debug_traceCall.
+//
+// Note, this function doesn't make any changes in the state/blockchain and is
+// useful to simulate dependent calls (e.g. approve and swap) or whole bundles.
+func (s *PublicBlockChainAPI) Simulate(ctx context.Context, calls []BundleCall, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides) (*callBundleResult, error) {
+	return DoCallBundle(ctx, s.b, calls, blockNrOrHash, overrides, blockOverrides, 5*time.Second, s.b.RPCSmokeCap())
+}
+
+// CallBundle executes a bundle of signed transactions in order on top of the
+// state of the given block. It's a shorthand for Simulate with only signed
+// transactions in the bundle.
+func (s *PublicBlockChainAPI) CallBundle(ctx context.Context, txs []hexutil.Bytes, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides) (*callBundleResult, error) {
+	calls := make([]BundleCall, len(txs))
+	for i := range txs {
+		calls[i].Raw = &txs[i]
+	}
+	return DoCallBundle(ctx, s.b, calls, blockNrOrHash, overrides, blockOverrides, 5*time.Second, s.b.RPCSmokeCap())
+}
+
 func DoEstimateSmoke(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, smokeCap uint64) (hexutil.Uint64, error) {
 	// Binary search the smoke requirement, as it may be higher than the amount used
 	var (
@@ -1060,6 +1555,119 @@ func (s *PublicBlockChainAPI) EstimateSmoke(ctx context.Context, args CallArgs,
 	return DoEstimateSmoke(ctx, s.b, args, bNrOrHash, s.b.RPCSmokeCap())
 }
 
+// accessListResult returns an optional accesslist
+// Its the result of the `high_createAccessList` RPC call.
+// It contains an error if the transaction itself failed.
+type accessListResult struct {
+	Accesslist *types.AccessList `json:"accessList"`
+	Error      string            `json:"error,omitempty"`
+	SmokeUsed  hexutil.Uint64    `json:"smokeUsed"`
+}
+
+// CreateAccessList creates a EIP-2930 type AccessList for the given transaction.
+// Reexec and BlockNrOrHash can be specified to create the accessList on top of a certain state.
+func (s *PublicBlockChainAPI) CreateAccessList(ctx context.Context, args CallArgs, blockNrOrHash *rpc.BlockNumberOrHash) (*accessListResult, error) {
+	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)
+	if blockNrOrHash != nil {
+		bNrOrHash = *blockNrOrHash
+	}
+	acl, smokeUsed, vmerr, err := AccessList(ctx, s.b, bNrOrHash, args)
+	if err != nil {
+		return nil, err
+	}
+	result := &accessListResult{Accesslist: &acl, SmokeUsed: hexutil.Uint64(smokeUsed)}
+	if vmerr != nil {
+		result.Error = vmerr.Error()
+	}
+	return result, nil
+}
+
+// maxAccessListIterations is the number of times AccessList executes a transaction
+// before giving up on the access list reaching a fixed point.
+const maxAccessListIterations = 16
+
+// AccessList creates an access list for the given transaction by repeatedly
+// executing it with an access list tracer until the touched accounts and
+// storage slots stop changing.
+// If the accesslist creation fails an error is returned.
+// If the transaction itself fails, an vmErr is returned.
+func AccessList(ctx context.Context, b Backend, blockNrOrHash rpc.BlockNumberOrHash, args CallArgs) (acl types.AccessList, smokeUsed uint64, vmErr error, err error) {
+	// Retrieve the execution context
+	db, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if db == nil || err != nil {
+		return nil, 0, nil, err
+	}
+	// Use zero address if sender unspecified, and extract the recipient
+	if args.From == nil {
+		args.From = new(common.Address)
+	}
+	var to common.Address
+	if args.To != nil {
+		to = *args.To
+	} else {
+		to = crypto.CreateAddress(*args.From, db.GetNonce(*args.From))
+	}
+	// If the smoke amount is not set, extract this as it will depend on access
+	// lists and we'll need to reestimate every time
+	nosmoke := args.Smoke == nil
+
+	// Retrieve the precompiles since they don't need to be added to the access list
+	precompiles := vm.ActivePrecompiles(b.ChainConfig().Rules(header.Number))
+
+	// Create an initial tracer
+	prevTracer := vm.NewAccessListTracer(nil, *args.From, to, precompiles)
+	if args.AccessList != nil {
+		prevTracer = vm.NewAccessListTracer(*args.AccessList, *args.From, to, precompiles)
+	}
+	for i := 0; ; i++ {
+		// Abort if the access list keeps changing, e.g. because the accessed
+		// accounts depend on the smoke left, which depends on the access list
+		if i >= maxAccessListIterations {
+			return nil, 0, nil, fmt.Errorf("access list did not converge after %d iterations", maxAccessListIterations)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, 0, nil, err
+		}
+		// Retrieve the current access list to expand
+		accessList := prevTracer.AccessList()
+		log.Trace("Creating access list", "input", accessList)
+
+		// Set the accesslist to the last al
+		args.AccessList = &accessList
+
+		// If no smoke amount was specified, each unique access list needs it's own
+		// smoke calculation. This is quite expensive, but we need to be accurate
+		// and it's convered by the sender only anyway.
+		if nosmoke {
+			args.Smoke = nil
+			smoke, err := DoEstimateSmoke(ctx, b, args, blockNrOrHash, b.RPCSmokeCap())
+			if err != nil {
+				return nil, 0, nil, err
+			}
+			args.Smoke = &smoke
+		}
+		// Copy the original db so we don't modify it
+		statedb := db.Copy()
+		msg := args.ToMessage(b.RPCSmokeCap())
+
+		// Apply the transaction with the access list tracer
+		tracer := vm.NewAccessListTracer(accessList, *args.From, to, precompiles)
+		config := vm.Config{Tracer: tracer, Debug: true}
+		vmenv, _, err := b.GetEVM(ctx, msg, statedb, header, &config)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		res, err := core.ApplyMessage(vmenv, msg, new(core.SmokePool).AddSmoke(msg.Smoke()))
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("failed to apply transaction: %v", err)
+		}
+		if tracer.Equal(prevTracer) {
+			return accessList, res.UsedSmoke, res.Err, nil
+		}
+		prevTracer = tracer
+	}
+}
+
 // ExecutionResult groups all structured logs emitted by the EVM
 // while replaying a transaction in debug mode as well as transaction
 // execution status, the amount of smoke used and the return value
@@ -1423,6 +2031,9 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context,
 func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
 	tx, blockHash, blockNumber, index, err := s.b.GetTransaction(ctx, hash)
 	if err != nil {
+		if errors.Is(err, core.ErrHistoryPruned) {
+			return nil, err
+		}
 		return nil, nil
 	}
 	receipts, err := s.b.GetReceipts(ctx, blockHash)
@@ -0,0 +1,470 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package highapi
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/common/hexutil"
+	"github.com/420integrated/go-highcoin/consensus/ethash"
+	"github.com/420integrated/go-highcoin/core"
+	"github.com/420integrated/go-highcoin/core/rawdb"
+	"github.com/420integrated/go-highcoin/core/state"
+	"github.com/420integrated/go-highcoin/core/types"
+	"github.com/420integrated/go-highcoin/core/vm"
+	"github.com/420integrated/go-highcoin/crypto"
+	"github.com/420integrated/go-highcoin/highdb"
+	"github.com/420integrated/go-highcoin/params"
+	"github.com/420integrated/go-highcoin/rpc"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	testBalance = big.NewInt(params.Highcoin)
+)
+
+// testBackend implements the Backend methods needed by the tested APIs on top
+// of a local chain. Methods not overridden panic through the nil Backend.
+type testBackend struct {
+	Backend
+
+	db    highdb.Database
+	chain *core.BlockChain
+}
+
+// newTestBackend creates a chain of n blocks on top of a genesis with the given
+// allocation, which additionally funds the test account.
+func newTestBackend(t *testing.T, n int, alloc core.GenesisAlloc, generator func(i int, b *core.BlockGen)) *testBackend {
+	return newTestBackendWithConfig(t, params.TestChainConfig, n, alloc, generator)
+}
+
+// newTestBackendWithConfig creates a test backend like newTestBackend, running
+// the chain with the given chain config.
+func newTestBackendWithConfig(t *testing.T, config *params.ChainConfig, n int, alloc core.GenesisAlloc, generator func(i int, b *core.BlockGen)) *testBackend {
+	if alloc == nil {
+		alloc = make(core.GenesisAlloc)
+	}
+	alloc[testAddr] = core.GenesisAccount{Balance: testBalance}
+
+	var (
+		engine = ethash.NewFaker()
+		gspec  = &core.Genesis{Config: config, Alloc: alloc}
+		gendb  = rawdb.NewMemoryDatabase()
+	)
+	blocks, _ := core.GenerateChain(gspec.Config, gspec.MustCommit(gendb), engine, gendb, n, generator)
+
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+	cacheConfig := &core.CacheConfig{
+		TrieCleanLimit:    256,
+		TrieDirtyLimit:    256,
+		TrieTimeLimit:     5 * time.Minute,
+		TrieDirtyDisabled: true, // Archive mode
+	}
+	chain, err := core.NewBlockChain(db, cacheConfig, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	t.Cleanup(chain.Stop)
+	return &testBackend{db: db, chain: chain}
+}
+
+func (b *testBackend) ChainDb() highdb.Database         { return b.db }
+func (b *testBackend) ChainConfig() *params.ChainConfig { return b.chain.Config() }
+func (b *testBackend) RPCSmokeCap() uint64              { return 25000000 }
+func (b *testBackend) CurrentBlock() *types.Block       { return b.chain.CurrentBlock() }
+func (b *testBackend) CurrentHeader() *types.Header     { return b.chain.CurrentHeader() }
+func (b *testBackend) GetTd(ctx context.Context, hash common.Hash) *big.Int {
+	return b.chain.GetTdByHash(hash)
+}
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber {
+		return b.chain.CurrentHeader(), nil
+	}
+	return b.chain.GetHeaderByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) HeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Header, error) {
+	if number, ok := blockNrOrHash.Number(); ok {
+		return b.HeaderByNumber(ctx, number)
+	}
+	hash, _ := blockNrOrHash.Hash()
+	if header := b.chain.GetHeaderByHash(hash); header != nil {
+		return header, nil
+	}
+	return nil, errors.New("header for hash not found")
+}
+
+func (b *testBackend) BlockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error) {
+	header, err := b.HeaderByNumberOrHash(ctx, blockNrOrHash)
+	if header == nil || err != nil {
+		return nil, err
+	}
+	return b.chain.GetBlock(header.Hash(), header.Number.Uint64()), nil
+}
+
+func (b *testBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	header, err := b.HeaderByNumberOrHash(ctx, blockNrOrHash)
+	if header == nil || err != nil {
+		return nil, nil, errors.New("header not found")
+	}
+	statedb, err := b.chain.StateAt(header.Root)
+	return statedb, header, err
+}
+
+func (b *testBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmConfig *vm.Config) (*vm.EVM, func() error, error) {
+	if vmConfig == nil {
+		vmConfig = b.chain.GetVMConfig()
+	}
+	txContext := core.NewEVMTxContext(msg)
+	context := core.NewEVMBlockContext(header, b.chain, nil)
+	return vm.NewEVM(context, txContext, state, b.chain.Config(), *vmConfig), func() error { return nil }, nil
+}
+
+func TestCreateAccessList(t *testing.T) {
+	var (
+		contract = common.HexToAddress("0xc0de")
+		other    = common.HexToAddress("0xbb")
+		// BALANCE(0xbb), SLOAD(1)
+		code = common.FromHex("60bb31506001545000")
+
+		// BALANCE(GAS): the accessed account depends on the smoke left, which
+		// depends on the access list, so the access list never converges
+		unstable = common.HexToAddress("0xdead")
+	)
+	backend := newTestBackend(t, 1, core.GenesisAlloc{
+		contract: {Balance: common.Big0, Code: code},
+		unstable: {Balance: common.Big0, Code: common.FromHex("5a315000")},
+	}, nil)
+	api := NewPublicBlockChainAPI(backend)
+	latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+
+	res, err := api.CreateAccessList(context.Background(), CallArgs{From: &testAddr, To: &contract}, &latest)
+	if err != nil {
+		t.Fatalf("failed to create access list: %v", err)
+	}
+	if res.Error != "" {
+		t.Fatalf("transaction failed: %v", res.Error)
+	}
+	want := types.AccessList{
+		{Address: contract, StorageKeys: []common.Hash{common.BigToHash(common.Big1)}},
+		{Address: other, StorageKeys: []common.Hash{}},
+	}
+	have := *res.Accesslist
+	if len(have) != len(want) {
+		t.Fatalf("access list length mismatch: have %d, want %d", len(have), len(want))
+	}
+	for _, tuple := range want {
+		found := false
+		for _, entry := range have {
+			if entry.Address == tuple.Address && len(entry.StorageKeys) == len(tuple.StorageKeys) {
+				found = len(tuple.StorageKeys) == 0 || entry.StorageKeys[0] == tuple.StorageKeys[0]
+			}
+		}
+		if !found {
+			t.Errorf("access list entry %x missing or wrong: %v", tuple.Address, have)
+		}
+	}
+	// Non-converging access lists must be aborted instead of looping for ever
+	smoke := hexutil.Uint64(1000000)
+	_, err = api.CreateAccessList(context.Background(), CallArgs{From: &testAddr, To: &unstable, Smoke: &smoke}, &latest)
+	if err == nil || !strings.Contains(err.Error(), "did not converge") {
+		t.Fatalf("unstable access list error mismatch: have %v, want convergence failure", err)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	var (
+		// SSTORE(0, SLOAD(0)+1), return the new value
+		counter = common.HexToAddress("0xc0de")
+		// SSTORE(0, 1), revert with the 32 byte word 0x2a
+		reverter = common.HexToAddress("0xdead")
+		// return NUMBER
+		number = common.HexToAddress("0xbeef")
+		// return CHAINID, only valid from Istanbul onwards
+		chainid = common.HexToAddress("0xcafe")
+	)
+	config := *params.TestChainConfig
+	config.IstanbulBlock = big.NewInt(100)
+	config.MuirGlacierBlock = nil
+	config.YoloV3Block = nil
+
+	backend := newTestBackendWithConfig(t, &config, 1, core.GenesisAlloc{
+		counter:  {Balance: common.Big0, Code: common.FromHex("6000546001018060005560005260206000f3")},
+		reverter: {Balance: common.Big0, Code: common.FromHex("6001600055602a60005260206000fd")},
+		number:   {Balance: common.Big0, Code: common.FromHex("4360005260206000f3")},
+		chainid:  {Balance: common.Big0, Code: common.FromHex("4660005260206000f3")},
+	}, nil)
+	api := NewPublicBlockChainAPI(backend)
+	latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+
+	call := func(to common.Address) BundleCall {
+		return BundleCall{CallArgs: CallArgs{From: &testAddr, To: &to}}
+	}
+	// Every call sees the state changes of the previous ones, reverted calls
+	// don't leave any changes behind
+	res, err := api.Simulate(context.Background(), []BundleCall{call(counter), call(reverter), call(counter)}, latest, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to simulate bundle: %v", err)
+	}
+	if len(res.Results) != 3 {
+		t.Fatalf("result count mismatch: have %d, want 3", len(res.Results))
+	}
+	if have := new(big.Int).SetBytes(res.Results[0].ReturnData); have.Uint64() != 1 {
+		t.Errorf("first call return mismatch: have %v, want 1", have)
+	}
+	if have := new(big.Int).SetBytes(res.Results[2].ReturnData); have.Uint64() != 2 {
+		t.Errorf("third call return mismatch: have %v, want 2", have)
+	}
+	if res.Results[1].Error == "" || new(big.Int).SetBytes(res.Results[1].Revert).Uint64() != 0x2a {
+		t.Errorf("reverted call mismatch: error %q, revert %x", res.Results[1].Error, res.Results[1].Revert)
+	}
+	var total uint64
+	for _, r := range res.Results {
+		total += uint64(r.SmokeUsed)
+	}
+	if uint64(res.SmokeUsed) != total {
+		t.Errorf("bundle smoke mismatch: have %d, want %d", res.SmokeUsed, total)
+	}
+	// The simulation must not touch the chain state
+	statedb, _, _ := backend.StateAndHeaderByNumberOrHash(context.Background(), latest)
+	if have := statedb.GetState(counter, common.Hash{}); have != (common.Hash{}) {
+		t.Errorf("simulation modified the chain state: %x", have)
+	}
+	// The smoke cap limits the cumulative smoke of the whole bundle
+	smokeCap := uint64(50000)
+	res, err = DoCallBundle(context.Background(), backend, []BundleCall{call(counter), call(counter)}, latest, nil, nil, 0, smokeCap)
+	if err != nil {
+		t.Fatalf("failed to simulate capped bundle: %v", err)
+	}
+	if res.Results[0].Error != "" {
+		t.Errorf("first capped call failed: %v", res.Results[0].Error)
+	}
+	if res.Results[1].Error == "" {
+		t.Errorf("second capped call succeeded beyond the smoke cap")
+	}
+	if uint64(res.SmokeUsed) > smokeCap {
+		t.Errorf("bundle smoke exceeds cap: have %d, cap %d", res.SmokeUsed, smokeCap)
+	}
+	_, err = DoCallBundle(context.Background(), backend, []BundleCall{call(counter), call(counter), call(counter)}, latest, nil, nil, 0, smokeCap)
+	if err == nil || !strings.Contains(err.Error(), "smoke cap") {
+		t.Errorf("exhausted smoke cap error mismatch: have %v", err)
+	}
+	// Block overrides are visible to the executed code and select the chain
+	// rules of the overridden block
+	res, err = api.Simulate(context.Background(), []BundleCall{call(number), call(chainid)}, latest, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to simulate bundle: %v", err)
+	}
+	if have := new(big.Int).SetBytes(res.Results[0].ReturnData); have.Uint64() != 1 {
+		t.Errorf("block number mismatch: have %v, want 1", have)
+	}
+	if res.BlockNumber != 1 {
+		t.Errorf("reported block number mismatch: have %d, want 1", res.BlockNumber)
+	}
+	if res.Results[1].Error == "" {
+		t.Errorf("CHAINID succeeded before Istanbul")
+	}
+	overrides := &BlockOverrides{Number: (*hexutil.Big)(big.NewInt(100))}
+	res, err = api.Simulate(context.Background(), []BundleCall{call(number), call(chainid)}, latest, nil, overrides)
+	if err != nil {
+		t.Fatalf("failed to simulate bundle: %v", err)
+	}
+	if have := new(big.Int).SetBytes(res.Results[0].ReturnData); have.Uint64() != 100 {
+		t.Errorf("overridden block number mismatch: have %v, want 100", have)
+	}
+	if res.BlockNumber != 100 {
+		t.Errorf("reported overridden block number mismatch: have %d, want 100", res.BlockNumber)
+	}
+	if res.Results[1].Error != "" {
+		t.Errorf("CHAINID failed with overridden Istanbul block: %v", res.Results[1].Error)
+	} else if have := new(big.Int).SetBytes(res.Results[1].ReturnData); have.Cmp(config.ChainID) != 0 {
+		t.Errorf("chain id mismatch: have %v, want %v", have, config.ChainID)
+	}
+}
+
+func TestCallBundle(t *testing.T) {
+	var (
+		backend   = newTestBackend(t, 1, nil, nil)
+		api       = NewPublicBlockChainAPI(backend)
+		latest    = rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		signer    = types.NewEIP155Signer(backend.ChainConfig().ChainID)
+		recipient = common.HexToAddress("0xbb")
+	)
+	sign := func(nonce uint64) hexutil.Bytes {
+		tx, err := types.SignTx(types.NewTransaction(nonce, recipient, big.NewInt(1000), params.TxSmoke, big.NewInt(1), nil), signer, testKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		blob, err := tx.MarshalBinary()
+		if err != nil {
+			t.Fatalf("failed to encode transaction: %v", err)
+		}
+		return blob
+	}
+	// The second transaction needs the nonce bump of the first one, the third
+	// reuses a consumed nonce and must fail without aborting the bundle
+	res, err := api.CallBundle(context.Background(), []hexutil.Bytes{sign(0), sign(1), sign(1)}, latest, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to call bundle: %v", err)
+	}
+	for i, want := range []bool{true, true, false} {
+		if ok := res.Results[i].Error == ""; ok != want {
+			t.Errorf("tx %d: success mismatch: have %v, want %v (error %q)", i, ok, want, res.Results[i].Error)
+		}
+	}
+	if want := hexutil.Uint64(2 * params.TxSmoke); res.SmokeUsed != want {
+		t.Errorf("bundle smoke mismatch: have %d, want %d", res.SmokeUsed, want)
+	}
+	// Malformed transactions reject the whole bundle
+	if _, err := api.CallBundle(context.Background(), []hexutil.Bytes{{0x01, 0x02}}, latest, nil, nil); err == nil {
+		t.Errorf("malformed transaction accepted")
+	}
+}
+
+// txPoolBackend serves a fixed transaction pool content.
+type txPoolBackend struct {
+	Backend
+
+	accounts []common.Address
+	pending  map[common.Address]types.Transactions
+	queued   map[common.Address]types.Transactions
+}
+
+func (b *txPoolBackend) TxPoolAccounts() []common.Address { return b.accounts }
+
+func (b *txPoolBackend) TxPoolContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	return b.pending[addr], b.queued[addr]
+}
+
+func TestTxPoolContentPages(t *testing.T) {
+	// Create five accounts with two pending transactions of different recipients
+	// and prices, and a queued contract creation each
+	var (
+		backend = &txPoolBackend{
+			pending: make(map[common.Address]types.Transactions),
+			queued:  make(map[common.Address]types.Transactions),
+		}
+		recipientA = common.HexToAddress("0xaa")
+		recipientB = common.HexToAddress("0xbb")
+	)
+	sign := func(tx *types.Transaction) *types.Transaction {
+		signed, err := types.SignTx(tx, types.HomesteadSigner{}, testKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		return signed
+	}
+	for i := byte(1); i <= 5; i++ {
+		account := common.Address{i}
+		backend.accounts = append(backend.accounts, account)
+		backend.pending[account] = types.Transactions{
+			sign(types.NewTransaction(0, recipientA, common.Big0, params.TxSmoke, big.NewInt(1), nil)),
+			sign(types.NewTransaction(1, recipientB, common.Big0, params.TxSmoke, big.NewInt(10), nil)),
+		}
+		backend.queued[account] = types.Transactions{
+			sign(types.NewContractCreation(5, common.Big0, 100000, big.NewInt(10), nil)),
+		}
+	}
+	api := NewPublicTxPoolAPI(backend)
+
+	limit := func(n uint) *hexutil.Uint { return (*hexutil.Uint)(&n) }
+	address := func(b ...byte) *common.Address {
+		addr := common.BytesToAddress(common.RightPadBytes(b, common.AddressLength))
+		return &addr
+	}
+	price := func(p int64) *hexutil.Big { return (*hexutil.Big)(big.NewInt(p)) }
+
+	// content flattens a page into the account and nonce lists per state
+	type content map[string][]string
+	flatten := func(txs map[string]map[string]*RPCTransaction) content {
+		flat := make(content)
+		for account, dump := range txs {
+			for nonce := range dump {
+				flat[account] = append(flat[account], nonce)
+			}
+			sort.Strings(flat[account])
+		}
+		return flat
+	}
+	accountsOf := func(from, to byte, nonces ...string) content {
+		flat := make(content)
+		for i := from; i <= to; i++ {
+			flat[common.Address{i}.Hex()] = nonces
+		}
+		return flat
+	}
+	tests := []struct {
+		query   TxPoolQuery
+		pending content
+		queued  content
+		next    *common.Address
+	}{
+		// Pages follow each other via the next cursor, the last one having none
+		{TxPoolQuery{Limit: limit(2)}, accountsOf(1, 2, "0", "1"), accountsOf(1, 2, "5"), address(3)},
+		{TxPoolQuery{Cursor: address(3), Limit: limit(2)}, accountsOf(3, 4, "0", "1"), accountsOf(3, 4, "5"), address(5)},
+		{TxPoolQuery{Cursor: address(5), Limit: limit(2)}, accountsOf(5, 5, "0", "1"), accountsOf(5, 5, "5"), nil},
+		{TxPoolQuery{Limit: limit(5)}, accountsOf(1, 5, "0", "1"), accountsOf(1, 5, "5"), nil},
+		{TxPoolQuery{}, accountsOf(1, 5, "0", "1"), accountsOf(1, 5, "5"), nil},
+
+		// Cursors between or past the accounts start at the next account
+		{TxPoolQuery{Cursor: address(2, 1), Limit: limit(1)}, accountsOf(3, 3, "0", "1"), accountsOf(3, 3, "5"), address(4)},
+		{TxPoolQuery{Cursor: address(0xff)}, content{}, content{}, nil},
+
+		// Filters reduce the transactions of the accounts in the page
+		{TxPoolQuery{From: address(2)}, accountsOf(2, 2, "0", "1"), accountsOf(2, 2, "5"), nil},
+		{TxPoolQuery{From: address(2), Cursor: address(3)}, content{}, content{}, nil},
+		{TxPoolQuery{To: &recipientA, Limit: limit(2)}, accountsOf(1, 2, "0"), content{}, address(3)},
+		{TxPoolQuery{MinSmokePrice: price(10), Limit: limit(2)}, accountsOf(1, 2, "1"), accountsOf(1, 2, "5"), address(3)},
+		{TxPoolQuery{MinSmokePrice: price(11)}, content{}, content{}, nil},
+		{TxPoolQuery{State: "pending", Limit: limit(2)}, accountsOf(1, 2, "0", "1"), content{}, address(3)},
+		{TxPoolQuery{State: "queued", Limit: limit(2)}, content{}, accountsOf(1, 2, "5"), address(3)},
+	}
+	for i, tt := range tests {
+		query := tt.query
+		res, err := api.Content(&query)
+		if err != nil {
+			t.Fatalf("test %d: failed to retrieve content: %v", i, err)
+		}
+		page := res.(*TxPoolContentPage)
+		if have := flatten(page.Pending); !reflect.DeepEqual(have, tt.pending) {
+			t.Errorf("test %d: pending mismatch: have %v, want %v", i, have, tt.pending)
+		}
+		if have := flatten(page.Queued); !reflect.DeepEqual(have, tt.queued) {
+			t.Errorf("test %d: queued mismatch: have %v, want %v", i, have, tt.queued)
+		}
+		if (page.Next == nil) != (tt.next == nil) || (page.Next != nil && *page.Next != *tt.next) {
+			t.Errorf("test %d: next cursor mismatch: have %v, want %v", i, page.Next, tt.next)
+		}
+	}
+	if _, err := api.Content(&TxPoolQuery{State: "mined"}); err == nil {
+		t.Errorf("invalid state filter accepted")
+	}
+}
@@ -453,6 +453,12 @@ web3._extend({
 			call: 'debug_storageRangeAt',
 			params: 5,
 		}),
+		new web3._extend.Method({
+			name: 'executionWitness',
+			call: 'debug_executionWitness',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'getModifiedAccountsByNumber',
 			call: 'debug_getModifiedAccountsByNumber',
@@ -563,6 +569,30 @@ web3._extend({
 			params: 3,
 			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'createAccessList',
+			call: 'high_createAccessList',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter],
+		}),
+		new web3._extend.Method({
+			name: 'feeHistory',
+			call: 'high_feeHistory',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'simulate',
+			call: 'high_simulate',
+			params: 4,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'callBundle',
+			call: 'high_callBundle',
+			params: 4,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter, null, null]
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -617,6 +647,11 @@ web3._extend({
 			call: 'miner_setRecommitInterval',
 			params: 1,
 		}),
+		new web3._extend.Method({
+			name: 'sendBundle',
+			call: 'miner_sendBundle',
+			params: 1,
+		}),
 		new web3._extend.Method({
 			name: 'getHashrate',
 			call: 'miner_getHashrate'
@@ -755,7 +790,13 @@ web3._extend({
 const TxpoolJs = `
 web3._extend({
 	property: 'txpool',
-	methods: [],
+	methods: [
+		new web3._extend.Method({
+			name: 'contentFrom',
+			call: 'txpool_contentFrom',
+			params: 1,
+		}),
+	],
 	properties:
 	[
 		new web3._extend.Property({
@@ -17,9 +17,11 @@
 package light
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"math/big"
+	"sort"
 	"sync"
 	"time"
 
@@ -505,6 +507,48 @@ func (pool *TxPool) Content() (map[common.Address]types.Transactions, map[common
 	return pending, queued
 }
 
+// ContentFrom retrieves the data content of the transaction pool, returning the
+// pending as well as queued transactions of this address, grouped by nonce.
+func (pool *TxPool) ContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	// Retrieve the pending transactions and sort by nonce
+	var pending types.Transactions
+	for _, tx := range pool.pending {
+		account, _ := types.Sender(pool.signer, tx)
+		if account != addr {
+			continue
+		}
+		pending = append(pending, tx)
+	}
+	sort.Sort(types.TxByNonce(pending))
+
+	// There are no queued transactions in a light pool, just return nil
+	return pending, nil
+}
+
+// Accounts retrieves the accounts with pending transactions in the pool,
+// sorted by address.
+func (pool *TxPool) Accounts() []common.Address {
+	pool.mu.RLock()
+	set := make(map[common.Address]struct{})
+	for _, tx := range pool.pending {
+		account, _ := types.Sender(pool.signer, tx)
+		set[account] = struct{}{}
+	}
+	pool.mu.RUnlock()
+
+	accounts := make([]common.Address, 0, len(set))
+	for addr := range set {
+		accounts = append(accounts, addr)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return bytes.Compare(accounts[i][:], accounts[j][:]) < 0
+	})
+	return accounts
+}
+
 // RemoveTransactions removes all given transactions from the pool.
 func (pool *TxPool) RemoveTransactions(txs types.Transactions) {
 	pool.mu.Lock()
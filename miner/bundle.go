@@ -0,0 +1,251 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/core"
+	"github.com/420integrated/go-highcoin/core/state"
+	"github.com/420integrated/go-highcoin/core/types"
+	"github.com/420integrated/go-highcoin/crypto"
+	"github.com/420integrated/go-highcoin/log"
+)
+
+const (
+	// maxBundles is the maximum number of bundles tracked by the miner across
+	// all the target blocks.
+	maxBundles = 1024
+
+	// maxBundleFutureBlocks is the maximum distance of the target block of a
+	// bundle from the current head.
+	maxBundleFutureBlocks = 256
+)
+
+var (
+	errEmptyBundle        = errors.New("bundle without transactions")
+	errBundleStale        = errors.New("bundle target block already mined")
+	errBundleTooFar       = errors.New("bundle target block too far in the future")
+	errBundleTimestamps   = errors.New("bundle minimum timestamp above maximum")
+	errBundlePoolOverflow = errors.New("too many pending bundles")
+	errBundleKnown        = errors.New("bundle already known")
+	errBundleReverted     = errors.New("bundle transaction reverted")
+)
+
+// Bundle is a group of transactions which must be included in the target block
+// together, in order, or not at all.
+type Bundle struct {
+	Txs               types.Transactions // Transactions of the bundle, in order of inclusion
+	BlockNumber       uint64             // Number of the block to include the bundle in
+	MinTimestamp      uint64             // Minimum timestamp of the block (0 = unrestricted)
+	MaxTimestamp      uint64             // Maximum timestamp of the block (0 = unrestricted)
+	RevertingTxHashes []common.Hash      // Transactions of the bundle allowed to revert
+}
+
+// Hash returns the identifier of the bundle, the hash of its transaction hashes.
+func (b *Bundle) Hash() common.Hash {
+	hashes := make([]byte, 0, len(b.Txs)*common.HashLength)
+	for _, tx := range b.Txs {
+		hashes = append(hashes, tx.Hash().Bytes()...)
+	}
+	return crypto.Keccak256Hash(hashes)
+}
+
+// canRevert reports whether the given transaction of the bundle may revert.
+func (b *Bundle) canRevert(hash common.Hash) bool {
+	for _, allowed := range b.RevertingTxHashes {
+		if allowed == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// validFor reports whether the bundle may be included in a block with the given
+// timestamp.
+func (b *Bundle) validFor(timestamp uint64) bool {
+	if b.MinTimestamp != 0 && timestamp < b.MinTimestamp {
+		return false
+	}
+	if b.MaxTimestamp != 0 && timestamp > b.MaxTimestamp {
+		return false
+	}
+	return true
+}
+
+// bundlePool tracks the bundles submitted to the miner, keyed by target block.
+type bundlePool struct {
+	bundles map[uint64][]*Bundle
+	count   int
+	lock    sync.Mutex
+}
+
+func newBundlePool() *bundlePool {
+	return &bundlePool{bundles: make(map[uint64][]*Bundle)}
+}
+
+// add validates a bundle against the current head block and stores it.
+func (p *bundlePool) add(bundle *Bundle, head uint64) error {
+	switch {
+	case len(bundle.Txs) == 0:
+		return errEmptyBundle
+	case bundle.BlockNumber <= head:
+		return fmt.Errorf("%w: target %d, head %d", errBundleStale, bundle.BlockNumber, head)
+	case bundle.BlockNumber > head+maxBundleFutureBlocks:
+		return fmt.Errorf("%w: target %d, head %d", errBundleTooFar, bundle.BlockNumber, head)
+	case bundle.MaxTimestamp != 0 && bundle.MinTimestamp > bundle.MaxTimestamp:
+		return errBundleTimestamps
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.prune(head + 1)
+	if p.count >= maxBundles {
+		return errBundlePoolOverflow
+	}
+	hash := bundle.Hash()
+	for _, known := range p.bundles[bundle.BlockNumber] {
+		if known.Hash() == hash {
+			return errBundleKnown
+		}
+	}
+	p.bundles[bundle.BlockNumber] = append(p.bundles[bundle.BlockNumber], bundle)
+	p.count++
+	return nil
+}
+
+// pending retrieves the bundles which may be included in the block with the
+// given number and timestamp, in order of submission.
+func (p *bundlePool) pending(number uint64, timestamp uint64) []*Bundle {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.prune(number)
+
+	var bundles []*Bundle
+	for _, bundle := range p.bundles[number] {
+		if bundle.validFor(timestamp) {
+			bundles = append(bundles, bundle)
+		}
+	}
+	return bundles
+}
+
+// prune drops all the bundles targeting blocks below the given number. The
+// pool lock is assumed to be held.
+func (p *bundlePool) prune(number uint64) {
+	for target, bundles := range p.bundles {
+		if target < number {
+			delete(p.bundles, target)
+			p.count -= len(bundles)
+		}
+	}
+}
+
+// simulatedBundle is a bundle simulated on top of the pending state.
+type simulatedBundle struct {
+	bundle *Bundle
+	profit *big.Int // Balance increase of the coinbase caused by the bundle
+}
+
+// applyBundle executes all the transactions of a bundle on top of the given
+// state, accumulating the used smoke into the header. If any transaction fails
+// or reverts without being allowed to, an error is returned and the state should
+// be considered corrupted.
+func (w *worker) applyBundle(bundle *Bundle, statedb *state.StateDB, smokePool *core.SmokePool, header *types.Header, coinbase common.Address, tcount int) ([]*types.Receipt, error) {
+	var receipts []*types.Receipt
+	for i, tx := range bundle.Txs {
+		statedb.Prepare(tx.Hash(), common.Hash{}, tcount+i)
+
+		receipt, err := core.ApplyTransaction(w.chainConfig, w.chain, &coinbase, smokePool, statedb, header, tx, &header.SmokeUsed, *w.chain.GetVMConfig())
+		if err != nil {
+			return nil, err
+		}
+		if receipt.Status == types.ReceiptStatusFailed && !bundle.canRevert(tx.Hash()) {
+			return nil, fmt.Errorf("%w: %x", errBundleReverted, tx.Hash())
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, nil
+}
+
+// simulateBundles executes each of the bundles on a copy of the pending state
+// and returns the valid ones, the most profitable first.
+func (w *worker) simulateBundles(bundles []*Bundle, coinbase common.Address) []*simulatedBundle {
+	env := w.current
+
+	var simulated []*simulatedBundle
+	for _, bundle := range bundles {
+		var (
+			statedb   = env.state.Copy()
+			smokePool = new(core.SmokePool).AddSmoke(env.smokePool.Smoke())
+			header    = types.CopyHeader(env.header)
+			balance   = statedb.GetBalance(coinbase)
+		)
+		if _, err := w.applyBundle(bundle, statedb, smokePool, header, coinbase, env.tcount); err != nil {
+			log.Debug("Bundle simulation failed", "hash", bundle.Hash(), "err", err)
+			continue
+		}
+		simulated = append(simulated, &simulatedBundle{
+			bundle: bundle,
+			profit: new(big.Int).Sub(statedb.GetBalance(coinbase), balance),
+		})
+	}
+	sort.SliceStable(simulated, func(i, j int) bool {
+		return simulated[i].profit.Cmp(simulated[j].profit) > 0
+	})
+	return simulated
+}
+
+// commitBundles simulates the bundles targeting the pending block and includes
+// the most profitable valid ones at the top of the block. Bundles invalidated by
+// the bundles included before them are skipped.
+func (w *worker) commitBundles(coinbase common.Address) {
+	env := w.current
+	if env.smokePool == nil {
+		env.smokePool = new(core.SmokePool).AddSmoke(env.header.SmokeLimit)
+	}
+	bundles := w.bundles.pending(env.header.Number.Uint64(), env.header.Time)
+	if len(bundles) == 0 {
+		return
+	}
+	for _, sim := range w.simulateBundles(bundles, coinbase) {
+		var (
+			snap      = env.state.Snapshot()
+			smokeLeft = env.smokePool.Smoke()
+			smokeUsed = env.header.SmokeUsed
+		)
+		receipts, err := w.applyBundle(sim.bundle, env.state, env.smokePool, env.header, coinbase, env.tcount)
+		if err != nil {
+			log.Debug("Bundle invalidated by earlier bundles", "hash", sim.bundle.Hash(), "err", err)
+			env.state.RevertToSnapshot(snap)
+			env.smokePool = new(core.SmokePool).AddSmoke(smokeLeft)
+			env.header.SmokeUsed = smokeUsed
+			continue
+		}
+		env.txs = append(env.txs, sim.bundle.Txs...)
+		env.receipts = append(env.receipts, receipts...)
+		env.tcount += len(sim.bundle.Txs)
+
+		log.Debug("Included bundle", "hash", sim.bundle.Hash(), "txs", len(sim.bundle.Txs), "profit", sim.profit)
+	}
+}
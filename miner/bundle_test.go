@@ -0,0 +1,152 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/consensus/ethash"
+	"github.com/420integrated/go-highcoin/core/rawdb"
+	"github.com/420integrated/go-highcoin/core/types"
+	"github.com/420integrated/go-highcoin/params"
+)
+
+func TestBundlePoolAdd(t *testing.T) {
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(0), params.TxSmoke, big.NewInt(1), nil)
+
+	tests := []struct {
+		bundle *Bundle
+		err    error
+	}{
+		{&Bundle{BlockNumber: 11}, errEmptyBundle},
+		{&Bundle{Txs: types.Transactions{tx}, BlockNumber: 10}, errBundleStale},
+		{&Bundle{Txs: types.Transactions{tx}, BlockNumber: 11 + maxBundleFutureBlocks}, errBundleTooFar},
+		{&Bundle{Txs: types.Transactions{tx}, BlockNumber: 11, MinTimestamp: 2, MaxTimestamp: 1}, errBundleTimestamps},
+		{&Bundle{Txs: types.Transactions{tx}, BlockNumber: 11, MinTimestamp: 1, MaxTimestamp: 2}, nil},
+		{&Bundle{Txs: types.Transactions{tx}, BlockNumber: 11}, errBundleKnown},
+		{&Bundle{Txs: types.Transactions{tx}, BlockNumber: 12}, nil},
+	}
+	pool := newBundlePool()
+	for i, tt := range tests {
+		if err := pool.add(tt.bundle, 10); !errors.Is(err, tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	// Check the timestamp filtering and the pruning of stale bundles
+	if bundles := pool.pending(11, 3); len(bundles) != 0 {
+		t.Errorf("bundle returned outside of its timestamp range")
+	}
+	if bundles := pool.pending(11, 2); len(bundles) != 1 {
+		t.Errorf("pending bundle count mismatch: have %d, want 1", len(bundles))
+	}
+	if bundles := pool.pending(12, 0); len(bundles) != 1 {
+		t.Errorf("pending bundle count mismatch: have %d, want 1", len(bundles))
+	}
+	if pool.count != 1 {
+		t.Errorf("stale bundles not pruned: have %d, want 1", pool.count)
+	}
+}
+
+// Tests that the worker includes the valid bundles at the top of the block, the
+// most profitable first, and skips invalid ones.
+func TestCommitBundles(t *testing.T) {
+	engine := ethash.NewFaker()
+	defer engine.Close()
+
+	w, _ := newTestWorker(t, ethashChainConfig, engine, rawdb.NewMemoryDatabase(), 0)
+	defer w.close()
+
+	var (
+		signer = types.LatestSigner(ethashChainConfig)
+		revert = common.FromHex("0x60006000fd") // revert(0, 0)
+	)
+	transfer := func(key *ecdsa.PrivateKey, price int64) *types.Transaction {
+		return types.MustSignNewTx(key, signer, &types.LegacyTx{
+			To:         &testUserAddress,
+			Value:      big.NewInt(1000),
+			Smoke:      params.TxSmoke,
+			SmokePrice: big.NewInt(price),
+		})
+	}
+	reverting := func(key *ecdsa.PrivateKey, price int64) *types.Transaction {
+		return types.MustSignNewTx(key, signer, &types.LegacyTx{
+			Smoke:      100000,
+			SmokePrice: big.NewInt(price),
+			Data:       revert,
+		})
+	}
+	var (
+		txA = transfer(testRemoteKeys[0], 5)
+		txB = transfer(testRemoteKeys[1], 10)
+		txC = reverting(testRemoteKeys[2], 4)
+		txD = reverting(testRemoteKeys[2], 1)
+		txE = transfer(testRemoteKeys[0], 1)
+	)
+	bundles := []*Bundle{
+		{Txs: types.Transactions{txA}, BlockNumber: 1},
+		{Txs: types.Transactions{txB}, BlockNumber: 1},
+		{Txs: types.Transactions{txC}, BlockNumber: 1},                                               // Reverts, rejected
+		{Txs: types.Transactions{txD}, BlockNumber: 1, RevertingTxHashes: []common.Hash{txD.Hash()}}, // Reverts, but allowed to
+		{Txs: types.Transactions{txE}, BlockNumber: 1},                                               // Conflicts with A
+		{Txs: types.Transactions{transfer(testUserKey, 100)}, BlockNumber: 1},                        // Unfunded
+		{Txs: types.Transactions{transfer(testRemoteKeys[1], 100)}, BlockNumber: 2},                  // Different block
+	}
+	for i, bundle := range bundles {
+		if err := w.addBundle(bundle); err != nil {
+			t.Fatalf("failed to add bundle %d: %v", i, err)
+		}
+	}
+	taskCh := make(chan *task, 1)
+	w.newTaskHook = func(task *task) {
+		if len(task.receipts) > 0 {
+			select {
+			case taskCh <- task:
+			default:
+			}
+		}
+	}
+	w.skipSealHook = func(task *task) bool { return true }
+	w.start()
+
+	select {
+	case task := <-taskCh:
+		want := []common.Hash{txB.Hash(), txA.Hash(), txD.Hash(), pendingTxs[0].Hash()}
+
+		txs := task.block.Transactions()
+		if len(txs) != len(want) {
+			t.Fatalf("transaction count mismatch: have %d, want %d", len(txs), len(want))
+		}
+		for i, tx := range txs {
+			if tx.Hash() != want[i] {
+				t.Errorf("transaction %d: hash mismatch: have %x, want %x", i, tx.Hash(), want[i])
+			}
+		}
+		if status := task.receipts[2].Status; status != types.ReceiptStatusFailed {
+			t.Errorf("allowed reverting transaction status mismatch: have %d, want %d", status, types.ReceiptStatusFailed)
+		}
+		if used := task.receipts[len(txs)-1].CumulativeSmokeUsed; used != task.block.SmokeUsed() {
+			t.Errorf("cumulative smoke mismatch: have %d, want %d", used, task.block.SmokeUsed())
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("new task timeout")
+	}
+}
@@ -50,6 +50,7 @@ type Config struct {
 	SmokePrice  *big.Int       // Minimum smoke price for mining a transaction
 	Recommit  time.Duration  // The time interval for miner to re-create mining work.
 	Noverify  bool           // Disable remote mining solution verification(only useful in ethash).
+	Ordering  OrderingPolicy `toml:"-"` // Transaction ordering policy of mined blocks (default = local-first)
 }
 
 // Miner creates blocks and searches for proof-of-work values.
@@ -215,6 +216,13 @@ func (miner *Miner) DisablePreseal() {
 	miner.worker.disablePreseal()
 }
 
+// SendBundle schedules a bundle of transactions for inclusion in its target
+// block. The transactions of the bundle are included together, in order, at the
+// top of the block, or not at all.
+func (miner *Miner) SendBundle(bundle *Bundle) error {
+	return miner.worker.addBundle(bundle)
+}
+
 // SubscribePendingLogs starts delivering logs from pending transactions
 // to the given channel.
 func (miner *Miner) SubscribePendingLogs(ch chan<- []*types.Log) event.Subscription {
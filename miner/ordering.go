@@ -0,0 +1,213 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/core/types"
+)
+
+// TransactionSet is an ordered set of transactions the worker fills a block from.
+// The set must honour the nonce order of the transactions of each account.
+type TransactionSet interface {
+	// Peek returns the next transaction to include, nil if the set is exhausted.
+	Peek() *types.Transaction
+
+	// Shift replaces the next transaction with the following one from the same
+	// account, if any.
+	Shift()
+
+	// Pop removes the next transaction along with all the remaining ones from
+	// the same account.
+	Pop()
+}
+
+// TransactionBatch is a set of transactions the worker includes in a row, while
+// leaving the reserved amount of smoke of the block unused.
+type TransactionBatch struct {
+	Txs     TransactionSet
+	Reserve uint64
+}
+
+// OrderingPolicy decides the order in which the pending transactions are included
+// in the blocks built by the worker.
+type OrderingPolicy interface {
+	// Order arranges the pending transactions, nonce sorted per account, into
+	// the batches to fill the block from, one after the other. The accounts
+	// considered local by the transaction pool are given too.
+	Order(signer types.Signer, pending map[common.Address]types.Transactions, locals []common.Address) []*TransactionBatch
+}
+
+// Names of the built-in ordering policies.
+const (
+	PriceOrderingName = "price"
+	FIFOOrderingName  = "fifo"
+	LocalOrderingName = "local"
+)
+
+// NewOrderingPolicy creates one of the built-in ordering policies by name. The
+// reserve is only used by the local-first policy.
+func NewOrderingPolicy(name string, reserve uint64) (OrderingPolicy, error) {
+	switch name {
+	case PriceOrderingName:
+		return PriceOrdering{}, nil
+	case FIFOOrderingName:
+		return FIFOOrdering{}, nil
+	case LocalOrderingName:
+		return LocalFirstOrdering{Reserve: reserve}, nil
+	default:
+		return nil, fmt.Errorf("unknown ordering policy %q", name)
+	}
+}
+
+// PriceOrdering includes the transactions with the highest smoke price first.
+type PriceOrdering struct{}
+
+// Order implements OrderingPolicy.
+func (PriceOrdering) Order(signer types.Signer, pending map[common.Address]types.Transactions, locals []common.Address) []*TransactionBatch {
+	if len(pending) == 0 {
+		return nil
+	}
+	return []*TransactionBatch{{Txs: types.NewTransactionsByPriceAndNonce(signer, pending)}}
+}
+
+// FIFOOrdering includes the transactions in the order they arrived at the node.
+type FIFOOrdering struct{}
+
+// Order implements OrderingPolicy.
+func (FIFOOrdering) Order(signer types.Signer, pending map[common.Address]types.Transactions, locals []common.Address) []*TransactionBatch {
+	if len(pending) == 0 {
+		return nil
+	}
+	return []*TransactionBatch{{Txs: newTransactionsByArrival(signer, pending)}}
+}
+
+// LocalFirstOrdering includes the transactions of local accounts before the ones
+// of remote accounts, both by smoke price. Remote transactions can't use the last
+// Reserve smoke of the block, keeping it available for local transactions
+// arriving until the block is sealed.
+//
+// This is the default policy.
+type LocalFirstOrdering struct {
+	Reserve uint64 // Smoke of each block reserved for local transactions
+}
+
+// Order implements OrderingPolicy.
+func (o LocalFirstOrdering) Order(signer types.Signer, pending map[common.Address]types.Transactions, locals []common.Address) []*TransactionBatch {
+	localTxs, remoteTxs := make(map[common.Address]types.Transactions), pending
+	for _, account := range locals {
+		if txs := remoteTxs[account]; len(txs) > 0 {
+			delete(remoteTxs, account)
+			localTxs[account] = txs
+		}
+	}
+	var batches []*TransactionBatch
+	if len(localTxs) > 0 {
+		batches = append(batches, &TransactionBatch{Txs: types.NewTransactionsByPriceAndNonce(signer, localTxs)})
+	}
+	if len(remoteTxs) > 0 {
+		batches = append(batches, &TransactionBatch{Txs: types.NewTransactionsByPriceAndNonce(signer, remoteTxs), Reserve: o.Reserve})
+	}
+	return batches
+}
+
+// txsByArrival implements the heap interface, ordering transactions by the time
+// they were first seen and by hash for identical times.
+type txsByArrival types.Transactions
+
+func (s txsByArrival) Len() int { return len(s) }
+func (s txsByArrival) Less(i, j int) bool {
+	if ti, tj := s[i].Time(), s[j].Time(); !ti.Equal(tj) {
+		return ti.Before(tj)
+	}
+	hi, hj := s[i].Hash(), s[j].Hash()
+	return bytes.Compare(hi[:], hj[:]) < 0
+}
+func (s txsByArrival) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s *txsByArrival) Push(x interface{}) {
+	*s = append(*s, x.(*types.Transaction))
+}
+
+func (s *txsByArrival) Pop() interface{} {
+	old := *s
+	n := len(old)
+	x := old[n-1]
+	*s = old[0 : n-1]
+	return x
+}
+
+// transactionsByArrival is a transaction set returning the transactions in their
+// order of arrival, while honouring the nonce order of each account.
+type transactionsByArrival struct {
+	txs    map[common.Address]types.Transactions // Per account nonce-sorted list of transactions
+	heads  txsByArrival                          // Next transaction for each unique account (arrival heap)
+	signer types.Signer                          // Signer for the set of transactions
+}
+
+// newTransactionsByArrival creates a transaction set that can retrieve transactions
+// by arrival in a nonce-honouring way.
+//
+// Note, the input map is reowned so the caller should not interact any more with
+// it after providing it to the constructor.
+func newTransactionsByArrival(signer types.Signer, txs map[common.Address]types.Transactions) *transactionsByArrival {
+	heads := make(txsByArrival, 0, len(txs))
+	for from, accTxs := range txs {
+		// Ensure the sender address is from the signer
+		if acc, _ := types.Sender(signer, accTxs[0]); acc != from {
+			delete(txs, from)
+			continue
+		}
+		heads = append(heads, accTxs[0])
+		txs[from] = accTxs[1:]
+	}
+	heap.Init(&heads)
+
+	return &transactionsByArrival{
+		txs:    txs,
+		heads:  heads,
+		signer: signer,
+	}
+}
+
+// Peek implements TransactionSet, returning the earliest arrived transaction.
+func (t *transactionsByArrival) Peek() *types.Transaction {
+	if len(t.heads) == 0 {
+		return nil
+	}
+	return t.heads[0]
+}
+
+// Shift implements TransactionSet.
+func (t *transactionsByArrival) Shift() {
+	acc, _ := types.Sender(t.signer, t.heads[0])
+	if txs, ok := t.txs[acc]; ok && len(txs) > 0 {
+		t.heads[0], t.txs[acc] = txs[0], txs[1:]
+		heap.Fix(&t.heads, 0)
+	} else {
+		heap.Pop(&t.heads)
+	}
+}
+
+// Pop implements TransactionSet.
+func (t *transactionsByArrival) Pop() {
+	heap.Pop(&t.heads)
+}
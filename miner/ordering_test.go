@@ -0,0 +1,92 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/core/types"
+	"github.com/420integrated/go-highcoin/params"
+)
+
+// Tests that the arrival ordered transaction set returns transactions by their
+// arrival time, while honouring the nonce order of each account.
+func TestTransactionsByArrival(t *testing.T) {
+	var (
+		signer = types.HomesteadSigner{}
+		keys   = []*ecdsa.PrivateKey{newTestKey(), newTestKey()}
+		groups = make(map[common.Address]types.Transactions)
+	)
+	// Create the transactions interleaved between the accounts, but out of nonce
+	// order within the accounts to check that nonces take precedence.
+	arrivals := []struct {
+		account int
+		nonce   uint64
+	}{{0, 1}, {1, 0}, {0, 0}, {1, 1}}
+
+	txs := make([]*types.Transaction, len(arrivals))
+	for i, arrival := range arrivals {
+		time.Sleep(time.Millisecond) // Ensure distinct arrival times
+		txs[i], _ = types.SignTx(types.NewTransaction(arrival.nonce, common.Address{}, big.NewInt(0), params.TxSmoke, big.NewInt(1), nil), signer, keys[arrival.account])
+	}
+	for _, i := range []int{2, 0, 1, 3} {
+		from, _ := types.Sender(signer, txs[i])
+		groups[from] = append(groups[from], txs[i])
+	}
+	set := newTransactionsByArrival(signer, groups)
+
+	var have []*types.Transaction
+	for tx := set.Peek(); tx != nil; tx = set.Peek() {
+		have = append(have, tx)
+		set.Shift()
+	}
+	want := []*types.Transaction{txs[1], txs[2], txs[0], txs[3]}
+	if len(have) != len(want) {
+		t.Fatalf("transaction count mismatch: have %d, want %d", len(have), len(want))
+	}
+	for i := range want {
+		if have[i].Hash() != want[i].Hash() {
+			t.Errorf("transaction %d: hash mismatch: have %x, want %x", i, have[i].Hash(), want[i].Hash())
+		}
+	}
+}
+
+func TestNewOrderingPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		reserve uint64
+		want    OrderingPolicy
+	}{
+		{PriceOrderingName, 0, PriceOrdering{}},
+		{FIFOOrderingName, 0, FIFOOrdering{}},
+		{LocalOrderingName, 21000, LocalFirstOrdering{Reserve: 21000}},
+		{"random", 0, nil},
+	}
+	for _, tt := range tests {
+		have, err := NewOrderingPolicy(tt.name, tt.reserve)
+		if (err != nil) != (tt.want == nil) {
+			t.Errorf("%s: error mismatch: have %v", tt.name, err)
+		}
+		if have != tt.want {
+			t.Errorf("%s: policy mismatch: have %#v, want %#v", tt.name, have, tt.want)
+		}
+	}
+}
@@ -128,6 +128,8 @@ type worker struct {
 	engine      consensus.Engine
 	high         Backend
 	chain       *core.BlockChain
+	ordering    OrderingPolicy
+	bundles     *bundlePool
 
 	// Feeds
 	pendingLogsFeed event.Feed
@@ -200,6 +202,7 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus
 		remoteUncles:       make(map[common.Hash]*types.Block),
 		unconfirmed:        newUnconfirmedBlocks(high.BlockChain(), miningLogAtDepth),
 		pendingTasks:       make(map[common.Hash]*task),
+		bundles:            newBundlePool(),
 		txsCh:              make(chan core.NewTxsEvent, txChanSize),
 		chainHeadCh:        make(chan core.ChainHeadEvent, chainHeadChanSize),
 		chainSideCh:        make(chan core.ChainSideEvent, chainSideChanSize),
@@ -211,6 +214,11 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus
 		resubmitIntervalCh: make(chan time.Duration),
 		resubmitAdjustCh:   make(chan *intervalAdjust, resubmitAdjustChanSize),
 	}
+	// Fall back to the local-first ordering if no policy was configured
+	worker.ordering = config.Ordering
+	if worker.ordering == nil {
+		worker.ordering = LocalFirstOrdering{}
+	}
 	// Subscribe NewTxsEvent for tx pool
 	worker.txsSub = high.TxPool().SubscribeNewTxsEvent(worker.txsCh)
 	// Subscribe events for blockchain
@@ -236,6 +244,12 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus
 	return worker
 }
 
+// addBundle validates a bundle against the current head and schedules it for
+// inclusion in its target block.
+func (w *worker) addBundle(bundle *Bundle) error {
+	return w.bundles.add(bundle, w.chain.CurrentBlock().NumberU64())
+}
+
 // setHighcoinbase sets the highcoinbase used to initialize the block coinbase field.
 func (w *worker) setHighcoinbase(addr common.Address) {
 	w.mu.Lock()
@@ -499,9 +513,10 @@ func (w *worker) mainLoop() {
 					acc, _ := types.Sender(w.current.signer, tx)
 					txs[acc] = append(txs[acc], tx)
 				}
-				txset := types.NewTransactionsByPriceAndNonce(w.current.signer, txs)
 				tcount := w.current.tcount
-				w.commitTransactions(txset, coinbase, nil)
+				for _, batch := range w.ordering.Order(w.current.signer, txs, w.high.TxPool().Locals()) {
+					w.commitTransactions(batch.Txs, batch.Reserve, coinbase, nil)
+				}
 				// Only update the snapshot if any new transactons were added
 				// to the pending block
 				if tcount != w.current.tcount {
@@ -747,7 +762,9 @@ func (w *worker) commitTransaction(tx *types.Transaction, coinbase common.Addres
 	return receipt.Logs, nil
 }
 
-func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coinbase common.Address, interrupt *int32) bool {
+// commitTransactions executes the transactions of the set on top of the pending
+// block, leaving the given amount of smoke of the block unused.
+func (w *worker) commitTransactions(txs TransactionSet, reserve uint64, coinbase common.Address, interrupt *int32) bool {
 	// Short circuit if current is nil
 	if w.current == nil {
 		return true
@@ -781,8 +798,8 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 			return atomic.LoadInt32(interrupt) == commitInterruptNewHead
 		}
 		// If we don't have enough smoke for any further transactions then we're done
-		if w.current.smokePool.Smoke() < params.TxSmoke {
-			log.Trace("Not enough smoke for further transactions", "have", w.current.smokePool, "want", params.TxSmoke)
+		if w.current.smokePool.Smoke() < params.TxSmoke+reserve {
+			log.Trace("Not enough smoke for further transactions", "have", w.current.smokePool, "want", params.TxSmoke, "reserved", reserve)
 			break
 		}
 		// Retrieve the next transaction and abort if all done
@@ -790,6 +807,12 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 		if tx == nil {
 			break
 		}
+		// Skip the account if the transaction would eat into the reserved smoke
+		if tx.Smoke() > w.current.smokePool.Smoke()-reserve {
+			log.Trace("Transaction exceeds unreserved smoke", "hash", tx.Hash(), "smoke", tx.Smoke(), "reserved", reserve)
+			txs.Pop()
+			continue
+		}
 		// Error may be ignored here. The error has already been checked
 		// during transaction acceptance is the transaction pool.
 		//
@@ -951,36 +974,25 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 		w.commit(uncles, nil, false, tstart)
 	}
 
+	// Include the submitted bundles at the top of the block
+	w.commitBundles(w.coinbase)
+
 	// Fill the block with all available pending transactions.
 	pending, err := w.high.TxPool().Pending()
 	if err != nil {
 		log.Error("Failed to fetch pending transactions", "err", err)
 		return
 	}
-	// Short circuit if there is no available pending transactions.
+	// Short circuit if there is no available pending transactions or bundles.
 	// But if we disable empty precommit already, ignore it. Since
 	// empty block is necessary to keep the liveness of the network.
-	if len(pending) == 0 && atomic.LoadUint32(&w.noempty) == 0 {
+	if len(pending) == 0 && env.tcount == 0 && atomic.LoadUint32(&w.noempty) == 0 {
 		w.updateSnapshot()
 		return
 	}
-	// Split the pending transactions into locals and remotes
-	localTxs, remoteTxs := make(map[common.Address]types.Transactions), pending
-	for _, account := range w.high.TxPool().Locals() {
-		if txs := remoteTxs[account]; len(txs) > 0 {
-			delete(remoteTxs, account)
-			localTxs[account] = txs
-		}
-	}
-	if len(localTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, localTxs)
-		if w.commitTransactions(txs, w.coinbase, interrupt) {
-			return
-		}
-	}
-	if len(remoteTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, remoteTxs)
-		if w.commitTransactions(txs, w.coinbase, interrupt) {
+	// Fill the block in the order chosen by the ordering policy
+	for _, batch := range w.ordering.Order(w.current.signer, pending, w.high.TxPool().Locals()) {
+		if w.commitTransactions(batch.Txs, batch.Reserve, w.coinbase, interrupt) {
 			return
 		}
 	}
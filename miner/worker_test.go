@@ -17,6 +17,7 @@
 package miner
 
 import (
+	"crypto/ecdsa"
 	"math/big"
 	"math/rand"
 	"sync/atomic"
@@ -61,6 +62,8 @@ var (
 	testUserKey, _  = crypto.GenerateKey()
 	testUserAddress = crypto.PubkeyToAddress(testUserKey.PublicKey)
 
+	testRemoteKeys = []*ecdsa.PrivateKey{newTestKey(), newTestKey(), newTestKey()}
+
 	// Test transactions
 	pendingTxs []*types.Transaction
 	newTxs     []*types.Transaction
@@ -118,6 +121,9 @@ func newTestWorkerBackend(t *testing.T, chainConfig *params.ChainConfig, engine
 		Config: chainConfig,
 		Alloc:  core.GenesisAlloc{testBankAddress: {Balance: testBankFunds}},
 	}
+	for _, key := range testRemoteKeys {
+		gspec.Alloc[crypto.PubkeyToAddress(key.PublicKey)] = core.GenesisAccount{Balance: testBankFunds}
+	}
 
 	switch e := engine.(type) {
 	case *clique.Clique:
@@ -190,10 +196,19 @@ func (b *testWorkerBackend) newRandomTx(creation bool) *types.Transaction {
 	return tx
 }
 
+func newTestKey() *ecdsa.PrivateKey {
+	key, _ := crypto.GenerateKey()
+	return key
+}
+
 func newTestWorker(t *testing.T, chainConfig *params.ChainConfig, engine consensus.Engine, db highdb.Database, blocks int) (*worker, *testWorkerBackend) {
+	return newTestWorkerWithConfig(t, testConfig, chainConfig, engine, db, blocks)
+}
+
+func newTestWorkerWithConfig(t *testing.T, config *Config, chainConfig *params.ChainConfig, engine consensus.Engine, db highdb.Database, blocks int) (*worker, *testWorkerBackend) {
 	backend := newTestWorkerBackend(t, chainConfig, engine, db, blocks)
 	backend.txPool.AddLocals(pendingTxs)
-	w := newWorker(testConfig, chainConfig, engine, backend, new(event.TypeMux), nil, false)
+	w := newWorker(config, chainConfig, engine, backend, new(event.TypeMux), nil, false)
 	w.setHighcoinbase(testBankAddress)
 	return w, backend
 }
@@ -516,3 +531,84 @@ func testAdjustInterval(t *testing.T, chainConfig *params.ChainConfig, engine co
 		t.Error("interval reset timeout")
 	}
 }
+
+func TestOrderingPrice(t *testing.T) {
+	testOrdering(t, PriceOrdering{}, []int{1, 2, 0, -1})
+}
+
+func TestOrderingFIFO(t *testing.T) {
+	testOrdering(t, FIFOOrdering{}, []int{-1, 0, 1, 2})
+}
+
+func TestOrderingLocalFirst(t *testing.T) {
+	testOrdering(t, nil, []int{-1, 1, 2, 0})
+}
+
+func TestOrderingLocalFirstReserve(t *testing.T) {
+	testOrdering(t, LocalFirstOrdering{Reserve: params.GenesisSmokeLimit - 3*params.TxSmoke}, []int{-1, 1, 2})
+}
+
+// testOrdering checks that the full block built by the worker includes the local
+// bank transaction and remote transactions with smoke prices 1, 3 and 2 (arriving
+// in this order) in the expected order of senders. The bank is denoted by -1, the
+// remote accounts by their index in testRemoteKeys.
+func testOrdering(t *testing.T, ordering OrderingPolicy, want []int) {
+	var (
+		engine = ethash.NewFaker()
+		config = *testConfig
+	)
+	defer engine.Close()
+
+	config.Ordering = ordering
+	w, b := newTestWorkerWithConfig(t, &config, ethashChainConfig, engine, rawdb.NewMemoryDatabase(), 0)
+	defer w.close()
+
+	var (
+		signer  = types.LatestSigner(ethashChainConfig)
+		remotes []*types.Transaction
+	)
+	for i, price := range []int64{1, 3, 2} {
+		time.Sleep(time.Millisecond) // Ensure distinct arrival times
+		remotes = append(remotes, types.MustSignNewTx(testRemoteKeys[i], signer, &types.LegacyTx{
+			To:         &testUserAddress,
+			Value:      big.NewInt(1000),
+			Smoke:      params.TxSmoke,
+			SmokePrice: big.NewInt(price),
+		}))
+	}
+	for i, err := range b.txPool.AddRemotesSync(remotes) {
+		if err != nil {
+			t.Fatalf("failed to add remote transaction %d: %v", i, err)
+		}
+	}
+	taskCh := make(chan *task, 1)
+	w.newTaskHook = func(task *task) {
+		if len(task.receipts) > 0 {
+			select {
+			case taskCh <- task:
+			default:
+			}
+		}
+	}
+	w.skipSealHook = func(task *task) bool { return true }
+	w.start()
+
+	select {
+	case task := <-taskCh:
+		txs := task.block.Transactions()
+		if len(txs) != len(want) {
+			t.Fatalf("transaction count mismatch: have %d, want %d", len(txs), len(want))
+		}
+		for i, tx := range txs {
+			wantAddr := testBankAddress
+			if want[i] >= 0 {
+				wantAddr = crypto.PubkeyToAddress(testRemoteKeys[want[i]].PublicKey)
+			}
+			if from, _ := types.Sender(signer, tx); from != wantAddr {
+				t.Errorf("transaction %d: sender mismatch: have %x, want %x", i, from, wantAddr)
+			}
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("new task timeout")
+	}
+}
@@ -184,6 +184,7 @@ func (api *privateAdminAPI) StartRPC(host *string, port *int, cors *string, apis
 		CorsAllowedOrigins: api.node.config.HTTPCors,
 		Vhosts:             api.node.config.HTTPVirtualHosts,
 		Modules:            api.node.config.HTTPModules,
+		limits:             api.node.config.rpcLimits(),
 	}
 	if cors != nil {
 		config.CorsAllowedOrigins = nil
@@ -207,7 +208,7 @@ func (api *privateAdminAPI) StartRPC(host *string, port *int, cors *string, apis
 	if err := api.node.http.setListenAddr(*host, *port); err != nil {
 		return false, err
 	}
-	if err := api.node.http.enableRPC(api.node.rpcAPIs, config); err != nil {
+	if err := api.node.http.enableRPC(api.node.unauthenticatedAPIs(), config); err != nil {
 		return false, err
 	}
 	if err := api.node.http.start(); err != nil {
@@ -243,6 +244,7 @@ func (api *privateAdminAPI) StartWS(host *string, port *int, allowedOrigins *str
 	config := wsConfig{
 		Modules: api.node.config.WSModules,
 		Origins: api.node.config.WSOrigins,
+		limits:  api.node.config.rpcLimits(),
 		// ExposeAll: api.node.config.WSExposeAll,
 	}
 	if apis != nil {
@@ -263,7 +265,7 @@ func (api *privateAdminAPI) StartWS(host *string, port *int, allowedOrigins *str
 	if err := server.setListenAddr(*host, *port); err != nil {
 		return false, err
 	}
-	if err := server.enableWS(api.node.rpcAPIs, config); err != nil {
+	if err := server.enableWS(api.node.unauthenticatedAPIs(), config); err != nil {
 		return false, err
 	}
 	if err := server.start(); err != nil {
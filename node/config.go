@@ -45,6 +45,7 @@ const (
 	datadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
 	datadirNodeDatabase    = "nodes"              // Path within the datadir to store the node infos
+	datadirJWTSecret       = "jwtsecret"          // Path within the datadir to the RPC authentication secret
 )
 
 // Config represents a small collection of configuration values to fine tune the
@@ -70,6 +71,10 @@ type Config struct {
 	// in memory.
 	DataDir string
 
+	// DBEngine is the key-value store backing the databases, "leveldb" or "pebble".
+	// If empty, the engine of an existing database is used, or leveldb for a new one.
+	DBEngine string `toml:",omitempty"`
+
 	// Configuration of peer-to-peer networking.
 	P2P p2p.Config
 
@@ -171,6 +176,48 @@ type Config struct {
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
 
+	// BatchRequestLimit is the maximum number of requests in a batch served over
+	// the HTTP and websocket RPC interfaces. Zero means no limit.
+	BatchRequestLimit int `toml:",omitempty"`
+
+	// BatchResponseMaxSize is the maximum number of response bytes of a batch served
+	// over the HTTP and websocket RPC interfaces. Zero means no limit.
+	BatchResponseMaxSize int `toml:",omitempty"`
+
+	// RPCRateLimits are the per-connection call rate limits of the HTTP and websocket
+	// RPC interfaces, keyed by API namespace. The limit keyed by "*" applies to all
+	// namespaces without a dedicated limit. HTTP requests are limited per remote host.
+	RPCRateLimits map[string]rpc.RateLimit `toml:",omitempty"`
+
+	// AuthHost is the host interface on which to start the authenticated RPC
+	// server, serving both HTTP and websocket requests. Every request needs to
+	// carry a JWT bearer token signed with the JWT secret. If this field is empty,
+	// no authenticated API endpoint will be started.
+	AuthHost string `toml:",omitempty"`
+
+	// AuthPort is the TCP port number on which to start the authenticated RPC
+	// server.
+	AuthPort int `toml:",omitempty"`
+
+	// AuthVirtualHosts is the list of virtual hostnames which are allowed on incoming
+	// requests to the authenticated RPC server.
+	AuthVirtualHosts []string `toml:",omitempty"`
+
+	// AuthModules is a list of API modules to expose via the authenticated RPC
+	// interface. If the module list is empty, all RPC API endpoints designated
+	// public will be exposed.
+	AuthModules []string `toml:",omitempty"`
+
+	// AuthRestrict removes the privileged admin, personal and debug modules from the
+	// unauthenticated HTTP and websocket RPC interfaces, making them available
+	// only to authenticated callers (and over IPC).
+	AuthRestrict bool `toml:",omitempty"`
+
+	// JWTSecret is the path to the hex encoded secret used to authenticate requests
+	// to the authenticated RPC server. A new secret is generated if the file does
+	// not exist. If empty, the secret is stored in the instance directory.
+	JWTSecret string `toml:",omitempty"`
+
 	// GraphQLCors is the Cross-Origin Resource Sharing header to send to requesting
 	// clients. Please be aware that CORS is a browser enforced security, it's fully
 	// useless for custom HTTP clients.
@@ -271,6 +318,34 @@ func DefaultWSEndpoint() string {
 	return config.WSEndpoint()
 }
 
+// AuthEndpoint resolves the authenticated RPC endpoint based on the configured
+// host interface and port parameters.
+func (c *Config) AuthEndpoint() string {
+	if c.AuthHost == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", c.AuthHost, c.AuthPort)
+}
+
+// JWTSecretPath resolves the path of the secret used to authenticate requests to
+// the authenticated RPC endpoint. An empty path means an ephemeral secret.
+func (c *Config) JWTSecretPath() string {
+	if c.JWTSecret != "" {
+		return c.ResolvePath(c.JWTSecret)
+	}
+	return c.ResolvePath(datadirJWTSecret)
+}
+
+// rpcLimits returns the request limits to apply to the HTTP and websocket RPC
+// servers.
+func (c *Config) rpcLimits() rpcLimits {
+	return rpcLimits{
+		batchItemLimit:     c.BatchRequestLimit,
+		batchResponseLimit: c.BatchResponseMaxSize,
+		rateLimits:         c.RPCRateLimits,
+	}
+}
+
 // ExtRPCEnabled returns the indicator if node enables the external
 // RPC(http, ws or graphql).
 func (c *Config) ExtRPCEnabled() bool {
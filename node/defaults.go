@@ -34,18 +34,25 @@ const (
 	DefaultWSPort      = 41999        // Default TCP port for the websocket RPC server
 	DefaultGraphQLHost = "localhost" // Default host interface for the GraphQL server
 	DefaultGraphQLPort = 8547        // Default TCP port for the GraphQL server
+	DefaultAuthHost    = "localhost" // Default host interface for the authenticated RPC server
+	DefaultAuthPort    = 41998       // Default TCP port for the authenticated RPC server
 )
 
 // DefaultConfig contains reasonable default settings.
 var DefaultConfig = Config{
-	DataDir:             DefaultDataDir(),
-	HTTPPort:            DefaultHTTPPort,
-	HTTPModules:         []string{"net", "web3"},
-	HTTPVirtualHosts:    []string{"localhost"},
-	HTTPTimeouts:        rpc.DefaultHTTPTimeouts,
-	WSPort:              DefaultWSPort,
-	WSModules:           []string{"net", "web3"},
-	GraphQLVirtualHosts: []string{"localhost"},
+	DataDir:              DefaultDataDir(),
+	HTTPPort:             DefaultHTTPPort,
+	HTTPModules:          []string{"net", "web3"},
+	HTTPVirtualHosts:     []string{"localhost"},
+	HTTPTimeouts:         rpc.DefaultHTTPTimeouts,
+	BatchRequestLimit:    1000,
+	BatchResponseMaxSize: 25 * 1000 * 1000,
+	WSPort:               DefaultWSPort,
+	WSModules:            []string{"net", "web3"},
+	GraphQLVirtualHosts:  []string{"localhost"},
+	AuthPort:             DefaultAuthPort,
+	AuthVirtualHosts:     []string{"localhost"},
+	AuthModules:          []string{"high", "net", "web3", "admin", "personal", "debug"},
 	P2P: p2p.Config{
 		ListenAddr: ":13013",
 		MaxPeers:   50,
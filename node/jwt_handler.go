@@ -0,0 +1,177 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/420integrated/go-highcoin/common"
+	"github.com/420integrated/go-highcoin/common/hexutil"
+	"github.com/420integrated/go-highcoin/log"
+)
+
+const (
+	jwtSecretLength  = 32               // Length of the HS256 secret in bytes
+	jwtExpiryTimeout = 60 * time.Second // Maximum allowed drift of the token issuance time
+)
+
+var (
+	errMissingToken     = errors.New("missing token")
+	errMalformedToken   = errors.New("malformed token")
+	errInvalidAlgorithm = errors.New("invalid signing algorithm")
+	errInvalidSignature = errors.New("invalid token signature")
+	errMissingIssuance  = errors.New("missing issued-at")
+	errStaleToken       = errors.New("stale token")
+	errFutureToken      = errors.New("future token")
+)
+
+// jwtHandler is a handler which only passes on requests carrying a valid HS256
+// JWT bearer token, signed with the configured secret and issued recently.
+type jwtHandler struct {
+	secret []byte
+	next   http.Handler
+}
+
+// newJWTHandler creates a http.Handler with JWT authentication support.
+func newJWTHandler(secret []byte, next http.Handler) http.Handler {
+	return &jwtHandler{
+		secret: secret,
+		next:   next,
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (h *jwtHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		http.Error(w, errMissingToken.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err := validateJWT(strings.TrimPrefix(auth, "Bearer "), h.secret, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// jwtHeader is the JOSE header of a JWT token.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// jwtClaims is the subset of the JWT claims checked by the node.
+type jwtClaims struct {
+	Iat *int64 `json:"iat"`
+}
+
+// validateJWT checks that the token is a HS256 signed JWT, signed by the given
+// secret and issued within jwtExpiryTimeout of the given time.
+func validateJWT(token string, secret []byte, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errMalformedToken
+	}
+	// Validate the header and make sure the token is signed with HS256. Any other
+	// algorithm, most notably "none", is rejected.
+	blob, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return errMalformedToken
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(blob, &header); err != nil {
+		return errMalformedToken
+	}
+	if header.Alg != "HS256" {
+		return errInvalidAlgorithm
+	}
+	// Verify the signature before looking into the claims
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return errMalformedToken
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return errInvalidSignature
+	}
+	// Signature valid, ensure the token was issued recently
+	if blob, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+		return errMalformedToken
+	}
+	var claims jwtClaims
+	if err := json.Unmarshal(blob, &claims); err != nil {
+		return errMalformedToken
+	}
+	if claims.Iat == nil {
+		return errMissingIssuance
+	}
+	issued := time.Unix(*claims.Iat, 0)
+	if issued.Before(now.Add(-jwtExpiryTimeout)) {
+		return errStaleToken
+	}
+	if issued.After(now.Add(jwtExpiryTimeout)) {
+		return errFutureToken
+	}
+	return nil
+}
+
+// obtainJWTSecret loads the hex encoded JWT secret from the given file, or
+// generates a new random one and persists it if the file does not exist yet.
+// If no file name is given, an ephemeral secret is generated.
+func obtainJWTSecret(fileName string) ([]byte, error) {
+	if data, err := ioutil.ReadFile(fileName); err == nil {
+		secret := common.FromHex(strings.TrimSpace(string(data)))
+		if len(secret) != jwtSecretLength {
+			return nil, fmt.Errorf("invalid JWT secret in %s: have %d bytes, want %d", fileName, len(secret), jwtSecretLength)
+		}
+		log.Info("Loaded JWT secret file", "path", fileName, "crc32", fmt.Sprintf("%#x", crc32.ChecksumIEEE(secret)))
+		return secret, nil
+	} else if fileName != "" && !os.IsNotExist(err) {
+		return nil, err
+	}
+	// No secret available yet, generate a fresh one
+	secret := make([]byte, jwtSecretLength)
+	if _, err := crand.Read(secret); err != nil {
+		return nil, err
+	}
+	if fileName == "" {
+		log.Warn("Generated ephemeral JWT secret", "secret", hexutil.Encode(secret))
+		return secret, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), 0700); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(fileName, []byte(hexutil.Encode(secret)), 0600); err != nil {
+		return nil, err
+	}
+	log.Info("Generated JWT secret", "path", fileName)
+	return secret, nil
+}
@@ -0,0 +1,197 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/420integrated/go-highcoin/rpc"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// issueJWT creates a JWT token with the given header and claims, signed by the
+// given secret.
+func issueJWT(secret []byte, header, claims string) string {
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString([]byte(header)) + "." + enc.EncodeToString([]byte(claims))
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+// issueHS256 creates a valid HS256 token issued at the given time.
+func issueHS256(secret []byte, iat time.Time) string {
+	return issueJWT(secret, `{"alg":"HS256","typ":"JWT"}`, fmt.Sprintf(`{"iat":%d}`, iat.Unix()))
+}
+
+func TestValidateJWT(t *testing.T) {
+	var (
+		secret = bytes.Repeat([]byte{0x42}, jwtSecretLength)
+		now    = time.Now()
+	)
+	tests := []struct {
+		token string
+		err   error
+	}{
+		{issueHS256(secret, now), nil},
+		{issueHS256(secret, now.Add(-jwtExpiryTimeout+time.Second)), nil},
+		{issueHS256(secret, now.Add(jwtExpiryTimeout-time.Second)), nil},
+		{issueHS256(secret, now.Add(-jwtExpiryTimeout-time.Second)), errStaleToken},
+		{issueHS256(secret, now.Add(jwtExpiryTimeout+time.Second)), errFutureToken},
+		{issueHS256(bytes.Repeat([]byte{0x43}, jwtSecretLength), now), errInvalidSignature},
+		{issueJWT(secret, `{"alg":"HS256"}`, `{}`), errMissingIssuance},
+		{issueJWT(secret, `{"alg":"none"}`, fmt.Sprintf(`{"iat":%d}`, now.Unix())), errInvalidAlgorithm},
+		{issueJWT(secret, `{"alg":"HS512"}`, fmt.Sprintf(`{"iat":%d}`, now.Unix())), errInvalidAlgorithm},
+		{issueJWT(secret, `{"alg":"HS256"}`, `not json`), errMalformedToken},
+		{"only.two", errMalformedToken},
+		{"", errMalformedToken},
+	}
+	for i, tt := range tests {
+		if err := validateJWT(tt.token, secret, now); err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+func TestObtainJWTSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwtsecret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Missing secrets should be generated and persisted
+	path := filepath.Join(dir, "sub", "jwtsecret")
+	secret, err := obtainJWTSecret(path)
+	if err != nil {
+		t.Fatalf("failed to generate secret: %v", err)
+	}
+	if len(secret) != jwtSecretLength {
+		t.Fatalf("secret length mismatch: have %d, want %d", len(secret), jwtSecretLength)
+	}
+	// Existing secrets should be loaded as is
+	reloaded, err := obtainJWTSecret(path)
+	if err != nil {
+		t.Fatalf("failed to load secret: %v", err)
+	}
+	if !bytes.Equal(secret, reloaded) {
+		t.Fatalf("secret mismatch: have %x, want %x", reloaded, secret)
+	}
+	// Invalid secrets should be rejected instead of overwritten
+	if err := ioutil.WriteFile(path, []byte("0xdeadbeef"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := obtainJWTSecret(path); err == nil {
+		t.Fatalf("invalid secret accepted")
+	}
+}
+
+// TestJWTHandler makes sure requests to the authenticated HTTP and WebSocket
+// endpoints need a valid token.
+func TestJWTHandler(t *testing.T) {
+	secret := bytes.Repeat([]byte{0x42}, jwtSecretLength)
+
+	srv := createAndStartServer(t, &httpConfig{jwtSecret: secret}, true, &wsConfig{jwtSecret: secret})
+	defer srv.stop()
+	url := "http://" + srv.listenAddr()
+
+	resp := rpcRequest(t, url)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = rpcRequest(t, url, "Authorization", "Bearer "+issueHS256(secret, time.Now().Add(-2*jwtExpiryTimeout)))
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = rpcRequest(t, url, "Authorization", "Bearer "+issueHS256(secret, time.Now()))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Check the WebSocket upgrade too
+	wsURL := "ws://" + srv.listenAddr()
+	if _, _, err := websocket.DefaultDialer.Dial(wsURL, nil); err == nil {
+		t.Errorf("unauthenticated WebSocket connection accepted")
+	}
+	headers := make(http.Header)
+	headers.Set("Authorization", "Bearer "+issueHS256(secret, time.Now()))
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, headers)
+	if err != nil {
+		t.Fatalf("authenticated WebSocket connection rejected: %v", err)
+	}
+	conn.Close()
+}
+
+// TestAuthRestrict makes sure privileged modules are not served over the plain
+// HTTP endpoint if they're restricted to authenticated callers.
+func TestAuthRestrict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwtsecret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := &Config{
+		HTTPHost:     "127.0.0.1",
+		HTTPModules:  []string{"admin", "debug", "rpc"},
+		AuthHost:     "127.0.0.1",
+		AuthModules:  []string{"admin", "debug"},
+		AuthRestrict: true,
+		JWTSecret:    filepath.Join(dir, "jwtsecret"),
+	}
+	node, err := New(conf)
+	if err != nil {
+		t.Fatalf("could not create node: %v", err)
+	}
+	node.RegisterAPIs([]rpc.API{{Namespace: "debug", Version: "1.0", Service: new(testService)}})
+	if err := node.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	defer node.Close()
+
+	request := func(url string, headers ...string) string {
+		resp := rpcRequest(t, url, headers...)
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body)
+	}
+	if modules := request(node.HTTPEndpoint()); strings.Contains(modules, "admin") || strings.Contains(modules, "debug") {
+		t.Errorf("privileged modules served over unauthenticated endpoint: %s", modules)
+	}
+	secret, err := obtainJWTSecret(conf.JWTSecret)
+	if err != nil {
+		t.Fatalf("failed to read JWT secret: %v", err)
+	}
+	token := issueHS256(secret, time.Now())
+	if modules := request(node.AuthEndpoint(), "Authorization", "Bearer "+token); !strings.Contains(modules, "admin") || !strings.Contains(modules, "debug") {
+		t.Errorf("privileged modules missing from authenticated endpoint: %s", modules)
+	}
+}
+
+// testService is an empty RPC service.
+type testService struct{}
+
+func (s *testService) Ping() string { return "pong" }
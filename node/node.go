@@ -54,6 +54,7 @@ type Node struct {
 	rpcAPIs       []rpc.API   // List of APIs currently provided by the node
 	http          *httpServer //
 	ws            *httpServer //
+	httpAuth      *httpServer // Authenticated HTTP and websocket server
 	ipc           *ipcServer  // Stores information about the ipc http server
 	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
 
@@ -146,6 +147,7 @@ func New(conf *Config) (*Node, error) {
 	// Configure RPC servers.
 	node.http = newHTTPServer(node.log, conf.HTTPTimeouts)
 	node.ws = newHTTPServer(node.log, rpc.DefaultHTTPTimeouts)
+	node.httpAuth = newHTTPServer(node.log, conf.HTTPTimeouts)
 	node.ipc = newIPCServer(node.log, conf.IPCEndpoint())
 
 	return node, nil
@@ -355,11 +357,12 @@ func (n *Node) startRPC() error {
 			Vhosts:             n.config.HTTPVirtualHosts,
 			Modules:            n.config.HTTPModules,
 			prefix:             n.config.HTTPPathPrefix,
+			limits:             n.config.rpcLimits(),
 		}
 		if err := n.http.setListenAddr(n.config.HTTPHost, n.config.HTTPPort); err != nil {
 			return err
 		}
-		if err := n.http.enableRPC(n.rpcAPIs, config); err != nil {
+		if err := n.http.enableRPC(n.unauthenticatedAPIs(), config); err != nil {
 			return err
 		}
 	}
@@ -371,11 +374,43 @@ func (n *Node) startRPC() error {
 			Modules: n.config.WSModules,
 			Origins: n.config.WSOrigins,
 			prefix:  n.config.WSPathPrefix,
+			limits:  n.config.rpcLimits(),
 		}
 		if err := server.setListenAddr(n.config.WSHost, n.config.WSPort); err != nil {
 			return err
 		}
-		if err := server.enableWS(n.rpcAPIs, config); err != nil {
+		if err := server.enableWS(n.unauthenticatedAPIs(), config); err != nil {
+			return err
+		}
+	}
+
+	// Configure the authenticated HTTP and WebSocket endpoint.
+	if n.config.AuthRestrict {
+		n.log.Info("Restricting privileged RPC modules to authenticated callers", "modules", "admin,personal,debug")
+	}
+	if n.config.AuthHost != "" {
+		secret, err := obtainJWTSecret(n.config.JWTSecretPath())
+		if err != nil {
+			return err
+		}
+		if err := n.httpAuth.setListenAddr(n.config.AuthHost, n.config.AuthPort); err != nil {
+			return err
+		}
+		httpConfig := httpConfig{
+			Vhosts:    n.config.AuthVirtualHosts,
+			Modules:   n.config.AuthModules,
+			jwtSecret: secret,
+			limits:    n.config.rpcLimits(),
+		}
+		if err := n.httpAuth.enableRPC(n.rpcAPIs, httpConfig); err != nil {
+			return err
+		}
+		wsConfig := wsConfig{
+			Modules:   n.config.AuthModules,
+			jwtSecret: secret,
+			limits:    n.config.rpcLimits(),
+		}
+		if err := n.httpAuth.enableWS(n.rpcAPIs, wsConfig); err != nil {
 			return err
 		}
 	}
@@ -383,7 +418,30 @@ func (n *Node) startRPC() error {
 	if err := n.http.start(); err != nil {
 		return err
 	}
-	return n.ws.start()
+	if err := n.ws.start(); err != nil {
+		return err
+	}
+	return n.httpAuth.start()
+}
+
+// privilegedModules are the API namespaces which are only served to authenticated
+// callers if the node is configured to restrict them.
+var privilegedModules = map[string]bool{"admin": true, "personal": true, "debug": true}
+
+// unauthenticatedAPIs returns the APIs which may be served over the unauthenticated
+// HTTP and WebSocket endpoints. If privileged APIs are restricted, these are
+// filtered out.
+func (n *Node) unauthenticatedAPIs() []rpc.API {
+	if !n.config.AuthRestrict {
+		return n.rpcAPIs
+	}
+	var apis []rpc.API
+	for _, api := range n.rpcAPIs {
+		if !privilegedModules[api.Namespace] {
+			apis = append(apis, api)
+		}
+	}
+	return apis
 }
 
 func (n *Node) wsServerForPort(port int) *httpServer {
@@ -396,6 +454,7 @@ func (n *Node) wsServerForPort(port int) *httpServer {
 func (n *Node) stopRPC() {
 	n.http.stop()
 	n.ws.stop()
+	n.httpAuth.stop()
 	n.ipc.stop()
 	n.stopInProc()
 }
@@ -530,6 +589,11 @@ func (n *Node) HTTPEndpoint() string {
 	return "http://" + n.http.listenAddr()
 }
 
+// AuthEndpoint returns the URL of the authenticated HTTP and WebSocket server.
+func (n *Node) AuthEndpoint() string {
+	return "http://" + n.httpAuth.listenAddr()
+}
+
 // WSEndpoint returns the current JSON-RPC over WebSocket endpoint.
 func (n *Node) WSEndpoint() string {
 	if n.http.wsAllowed() {
@@ -559,7 +623,7 @@ func (n *Node) OpenDatabase(name string, cache, handles int, namespace string) (
 	if n.config.DataDir == "" {
 		db = rawdb.NewMemoryDatabase()
 	} else {
-		db, err = rawdb.NewLevelDBDatabase(n.ResolvePath(name), cache, handles, namespace)
+		db, err = rawdb.NewKeyValueDatabase(n.config.DBEngine, n.ResolvePath(name), cache, handles, namespace)
 	}
 
 	if err == nil {
@@ -592,7 +656,7 @@ func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, freezer,
 		case !filepath.IsAbs(freezer):
 			freezer = n.ResolvePath(freezer)
 		}
-		db, err = rawdb.NewLevelDBDatabaseWithFreezer(root, cache, handles, freezer, namespace)
+		db, err = rawdb.NewKeyValueDatabaseWithFreezer(n.config.DBEngine, root, cache, handles, freezer, namespace)
 	}
 
 	if err == nil {
@@ -39,14 +39,33 @@ type httpConfig struct {
 	Modules            []string
 	CorsAllowedOrigins []string
 	Vhosts             []string
-	prefix             string // path prefix on which to mount http handler
+	prefix             string    // path prefix on which to mount http handler
+	jwtSecret          []byte    // optional JWT secret, requests need to be authenticated if set
+	limits             rpcLimits // batch and rate limits of the requests
 }
 
 // wsConfig is the JSON-RPC/Websocket configuration
 type wsConfig struct {
-	Origins []string
-	Modules []string
-	prefix  string // path prefix on which to mount ws handler
+	Origins   []string
+	Modules   []string
+	prefix    string    // path prefix on which to mount ws handler
+	jwtSecret []byte    // optional JWT secret, requests need to be authenticated if set
+	limits    rpcLimits // batch and rate limits of the requests
+}
+
+// rpcLimits are the request limits applied by an RPC server.
+type rpcLimits struct {
+	batchItemLimit     int
+	batchResponseLimit int
+	rateLimits         map[string]rpc.RateLimit
+}
+
+// apply configures the limits on the given RPC server.
+func (l rpcLimits) apply(srv *rpc.Server) {
+	srv.SetBatchLimits(l.batchItemLimit, l.batchResponseLimit)
+	if len(l.rateLimits) > 0 {
+		srv.SetRateLimits(l.rateLimits)
+	}
 }
 
 type rpcHandler struct {
@@ -251,7 +270,7 @@ func (h *httpServer) doStop() {
 
 	// Shut down the server.
 	httpHandler := h.httpHandler.Load().(*rpcHandler)
-	wsHandler := h.httpHandler.Load().(*rpcHandler)
+	wsHandler := h.wsHandler.Load().(*rpcHandler)
 	if httpHandler != nil {
 		h.httpHandler.Store((*rpcHandler)(nil))
 		httpHandler.server.Stop()
@@ -280,12 +299,17 @@ func (h *httpServer) enableRPC(apis []rpc.API, config httpConfig) error {
 
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
+	config.limits.apply(srv)
 	if err := RegisterApisFromWhitelist(apis, config.Modules, srv, false); err != nil {
 		return err
 	}
+	handler := NewHTTPHandlerStack(srv, config.CorsAllowedOrigins, config.Vhosts)
+	if len(config.jwtSecret) != 0 {
+		handler = newJWTHandler(config.jwtSecret, handler)
+	}
 	h.httpConfig = config
 	h.httpHandler.Store(&rpcHandler{
-		Handler: NewHTTPHandlerStack(srv, config.CorsAllowedOrigins, config.Vhosts),
+		Handler: handler,
 		server:  srv,
 	})
 	return nil
@@ -312,12 +336,17 @@ func (h *httpServer) enableWS(apis []rpc.API, config wsConfig) error {
 
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
+	config.limits.apply(srv)
 	if err := RegisterApisFromWhitelist(apis, config.Modules, srv, false); err != nil {
 		return err
 	}
+	handler := srv.WebsocketHandler(config.Origins)
+	if len(config.jwtSecret) != 0 {
+		handler = newJWTHandler(config.jwtSecret, handler)
+	}
 	h.wsConfig = config
 	h.wsHandler.Store(&rpcHandler{
-		Handler: srv.WebsocketHandler(config.Origins),
+		Handler: handler,
 		server:  srv,
 	})
 	return nil
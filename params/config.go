@@ -239,16 +239,16 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, new(EthashConfig), nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, new(EthashConfig), nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Highcoin core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), nil, nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), nil, new(EthashConfig), nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), nil, nil, nil, new(EthashConfig), nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -320,8 +320,10 @@ type ChainConfig struct {
 	IstanbulBlock       *big.Int `json:"istanbulBlock,omitempty"`       // Istanbul switch block (nil = no fork, 0 = already on istanbul)
 	MuirGlacierBlock    *big.Int `json:"muirGlacierBlock,omitempty"`    // Eip-2384 (bomb delay) switch block (nil = no fork, 0 = already activated)
 
-	YoloV3Block *big.Int `json:"yoloV3Block,omitempty"` // YOLO v3: Smoke repricings TODO @holiman add EIP references
-	EWASMBlock  *big.Int `json:"ewasmBlock,omitempty"`  // EWASM switch block (nil = no fork, 0 = already activated)
+	YoloV3Block  *big.Int `json:"yoloV3Block,omitempty"`  // YOLO v3: Smoke repricings TODO @holiman add EIP references
+	EIP3541Block *big.Int `json:"eip3541Block,omitempty"` // EIP-3541 (reject new code starting with 0xEF) switch block (nil = no fork, 0 = already activated)
+	EOFBlock     *big.Int `json:"eofBlock,omitempty"`     // EOF code containers (EIP-3540, EIP-3670) switch block (nil = no fork, 0 = already activated)
+	EWASMBlock   *big.Int `json:"ewasmBlock,omitempty"`   // EWASM switch block (nil = no fork, 0 = already activated)
 
 	// Various consensus engines
 	Ethash *EthashConfig `json:"ethash,omitempty"`
@@ -358,7 +360,7 @@ func (c *ChainConfig) String() string {
 	default:
 		engine = "unknown"
 	}
-	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Petersburg: %v Istanbul: %v, Muir Glacier: %v, YOLO v3: %v, Engine: %v}",
+	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Petersburg: %v Istanbul: %v, Muir Glacier: %v, YOLO v3: %v, EIP-3541: %v, EOF: %v, Engine: %v}",
 		c.ChainID,
 		c.HomesteadBlock,
 		c.DAOForkBlock,
@@ -372,6 +374,8 @@ func (c *ChainConfig) String() string {
 		c.IstanbulBlock,
 		c.MuirGlacierBlock,
 		c.YoloV3Block,
+		c.EIP3541Block,
+		c.EOFBlock,
 		engine,
 	)
 }
@@ -433,6 +437,20 @@ func (c *ChainConfig) IsYoloV3(num *big.Int) bool {
 	return isForked(c.YoloV3Block, num)
 }
 
+// IsEIP3541 returns if num is either equal to the EIP-3541 fork block or greater.
+func (c *ChainConfig) IsEIP3541(num *big.Int) bool {
+	return isForked(c.EIP3541Block, num)
+}
+
+// IsEOF returns if num is either equal to the EOF fork block or greater.
+//
+// Code starting with the EOF magic is executed as an EOF container after the
+// fork. EIP-3541 needs to be active by then, so no new legacy contract can start
+// with it; contracts deployed before EIP-3541 are not checked.
+func (c *ChainConfig) IsEOF(num *big.Int) bool {
+	return isForked(c.EOFBlock, num)
+}
+
 // IsEWASM returns if num represents a block number after the EWASM fork
 func (c *ChainConfig) IsEWASM(num *big.Int) bool {
 	return isForked(c.EWASMBlock, num)
@@ -477,6 +495,8 @@ func (c *ChainConfig) CheckConfigForkOrder() error {
 		{name: "istanbulBlock", block: c.IstanbulBlock},
 		{name: "muirGlacierBlock", block: c.MuirGlacierBlock, optional: true},
 		{name: "yoloV3Block", block: c.YoloV3Block},
+		{name: "eip3541Block", block: c.EIP3541Block, optional: true},
+		{name: "eofBlock", block: c.EOFBlock, optional: true},
 	} {
 		if lastFork.name != "" {
 			// Next one must be higher number
@@ -496,6 +516,11 @@ func (c *ChainConfig) CheckConfigForkOrder() error {
 			lastFork = cur
 		}
 	}
+	// Legacy contracts starting with the EOF magic would run differently after the
+	// EOF fork, so their deployment needs to be rejected by then.
+	if c.EOFBlock != nil && c.EIP3541Block == nil {
+		return fmt.Errorf("unsupported fork ordering: eip3541Block not enabled, but eofBlock enabled at %v", c.EOFBlock)
+	}
 	return nil
 }
 
@@ -543,6 +568,12 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.YoloV3Block, newcfg.YoloV3Block, head) {
 		return newCompatError("YOLOv3 fork block", c.YoloV3Block, newcfg.YoloV3Block)
 	}
+	if isForkIncompatible(c.EIP3541Block, newcfg.EIP3541Block, head) {
+		return newCompatError("EIP-3541 fork block", c.EIP3541Block, newcfg.EIP3541Block)
+	}
+	if isForkIncompatible(c.EOFBlock, newcfg.EOFBlock, head) {
+		return newCompatError("EOF fork block", c.EOFBlock, newcfg.EOFBlock)
+	}
 	if isForkIncompatible(c.EWASMBlock, newcfg.EWASMBlock, head) {
 		return newCompatError("ewasm fork block", c.EWASMBlock, newcfg.EWASMBlock)
 	}
@@ -613,7 +644,7 @@ type Rules struct {
 	ChainID                                                 *big.Int
 	IsHomestead, IsEIP150, IsEIP155, IsEIP158               bool
 	IsByzantium, IsConstantinople, IsPetersburg, IsIstanbul bool
-	IsYoloV3                                                bool
+	IsYoloV3, IsEIP3541, IsEOF                              bool
 }
 
 // Rules ensures c's ChainID is not nil.
@@ -633,5 +664,7 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 		IsPetersburg:     c.IsPetersburg(num),
 		IsIstanbul:       c.IsIstanbul(num),
 		IsYoloV3:         c.IsYoloV3(num),
+		IsEIP3541:        c.IsEIP3541(num),
+		IsEOF:            c.IsEOF(num),
 	}
 }
@@ -87,6 +87,23 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     30,
 			},
 		},
+		{
+			stored:  &ChainConfig{YoloV3Block: big.NewInt(0)},
+			new:     &ChainConfig{YoloV3Block: big.NewInt(0), EIP3541Block: big.NewInt(50), EOFBlock: big.NewInt(60)},
+			head:    40,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{YoloV3Block: big.NewInt(0)},
+			new:    &ChainConfig{YoloV3Block: big.NewInt(0), EIP3541Block: big.NewInt(30), EOFBlock: big.NewInt(60)},
+			head:   40,
+			wantErr: &ConfigCompatError{
+				What:         "EIP-3541 fork block",
+				StoredConfig: nil,
+				NewConfig:    big.NewInt(30),
+				RewindTo:     29,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -96,3 +113,27 @@ func TestCheckCompatible(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckConfigForkOrderEOF(t *testing.T) {
+	tests := []struct {
+		eip3541, eof *big.Int
+		fail         bool
+	}{
+		{eip3541: nil, eof: nil},
+		{eip3541: big.NewInt(5), eof: nil},
+		{eip3541: big.NewInt(0), eof: big.NewInt(0)},
+		{eip3541: big.NewInt(5), eof: big.NewInt(5)},
+		{eip3541: big.NewInt(5), eof: big.NewInt(10)},
+		{eip3541: nil, eof: big.NewInt(0), fail: true},
+		{eip3541: nil, eof: big.NewInt(10), fail: true},
+		{eip3541: big.NewInt(10), eof: big.NewInt(5), fail: true},
+	}
+	for i, tt := range tests {
+		config := *TestChainConfig
+		config.EIP3541Block, config.EOFBlock = tt.eip3541, tt.eof
+
+		if err := config.CheckConfigForkOrder(); (err != nil) != tt.fail {
+			t.Errorf("test %d: fork order check mismatch: have %v, want failure %v", i, err, tt.fail)
+		}
+	}
+}
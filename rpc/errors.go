@@ -25,6 +25,8 @@ var (
 	_ Error = new(invalidRequestError)
 	_ Error = new(invalidMessageError)
 	_ Error = new(invalidParamsError)
+	_ Error = new(responseTooLargeError)
+	_ Error = new(rateLimitedError)
 )
 
 const defaultErrorCode = -32000
@@ -72,3 +74,19 @@ type invalidParamsError struct{ message string }
 func (e *invalidParamsError) ErrorCode() int { return -32602 }
 
 func (e *invalidParamsError) Error() string { return e.message }
+
+// response exceeds the configured size limit
+type responseTooLargeError struct{ message string }
+
+func (e *responseTooLargeError) ErrorCode() int { return -32003 }
+
+func (e *responseTooLargeError) Error() string { return e.message }
+
+// request exceeds the configured rate limit
+type rateLimitedError struct{ namespace string }
+
+func (e *rateLimitedError) ErrorCode() int { return -32005 }
+
+func (e *rateLimitedError) Error() string {
+	return fmt.Sprintf("rate limit exceeded for %s namespace", e.namespace)
+}
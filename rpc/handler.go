@@ -19,6 +19,7 @@ package rpc
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
@@ -61,6 +62,7 @@ type handler struct {
 	conn           jsonWriter                     // where responses will be sent
 	log            log.Logger
 	allowSubscribe bool
+	limits         connLimits // batch and rate limits of the connection
 
 	subLock    sync.Mutex
 	serverSubs map[ID]*Subscription
@@ -71,7 +73,7 @@ type callProc struct {
 	notifiers []*Notifier
 }
 
-func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *serviceRegistry) *handler {
+func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *serviceRegistry, limits *connLimits) *handler {
 	rootCtx, cancelRoot := context.WithCancel(connCtx)
 	h := &handler{
 		reg:            reg,
@@ -88,6 +90,9 @@ func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *
 	if conn.remoteAddr() != "" {
 		h.log = h.log.New("conn", conn.remoteAddr())
 	}
+	if limits != nil {
+		h.limits = *limits
+	}
 	h.unsubscribeCb = newCallback(reflect.Value{}, reflect.ValueOf(h.unsubscribe))
 	return h
 }
@@ -101,7 +106,15 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 		})
 		return
 	}
-
+	// Reject batches exceeding the configured item limit as a whole
+	if h.limits.batchItemLimit != 0 && len(msgs) > h.limits.batchItemLimit {
+		batchLimitMeter.Mark(1)
+		h.startCallProc(func(cp *callProc) {
+			err := &invalidRequestError{fmt.Sprintf("batch too large: %d items, limit %d", len(msgs), h.limits.batchItemLimit)}
+			h.conn.writeJSON(cp.ctx, []*jsonrpcMessage{errorMessage(err)})
+		})
+		return
+	}
 	// Handle non-call messages first:
 	calls := make([]*jsonrpcMessage, 0, len(msgs))
 	for _, msg := range msgs {
@@ -114,11 +127,30 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 	}
 	// Process calls on a goroutine because they may block indefinitely:
 	h.startCallProc(func(cp *callProc) {
-		answers := make([]*jsonrpcMessage, 0, len(msgs))
-		for _, msg := range calls {
-			if answer := h.handleCallMsg(cp, msg); answer != nil {
-				answers = append(answers, answer)
+		var (
+			answers = make([]*jsonrpcMessage, 0, len(msgs))
+			size    int
+		)
+		for i, msg := range calls {
+			answer := h.handleCallMsg(cp, msg)
+			if answer == nil {
+				continue
+			}
+			// If the batch response grew too large, replace the offending and all
+			// remaining answers with errors instead of executing them
+			size += answer.size()
+			if h.limits.batchResponseLimit != 0 && size > h.limits.batchResponseLimit {
+				responseLimitMeter.Mark(1)
+				err := &responseTooLargeError{fmt.Sprintf("batch response too large: limit %d bytes", h.limits.batchResponseLimit)}
+				answers = append(answers, msg.errorResponse(err))
+				for _, msg := range calls[i+1:] {
+					if msg.isCall() {
+						answers = append(answers, msg.errorResponse(err))
+					}
+				}
+				break
 			}
+			answers = append(answers, answer)
 		}
 		h.addSubscriptions(cp.notifiers)
 		if len(answers) > 0 {
@@ -329,6 +361,10 @@ func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage
 	if callb == nil {
 		return msg.errorResponse(&methodNotFoundError{method: msg.Method})
 	}
+	if callb != h.unsubscribeCb && !h.limits.limiter.allow(msg.namespace()) {
+		rateLimitMeter.Mark(1)
+		return msg.errorResponse(&rateLimitedError{msg.namespace()})
+	}
 	args, err := parsePositionalArguments(msg.Params, callb.argTypes)
 	if err != nil {
 		return msg.errorResponse(&invalidParamsError{err.Error()})
@@ -367,6 +403,10 @@ func (h *handler) handleSubscribe(cp *callProc, msg *jsonrpcMessage) *jsonrpcMes
 	if callb == nil {
 		return msg.errorResponse(&subscriptionNotFoundError{namespace, name})
 	}
+	if !h.limits.limiter.allow(namespace) {
+		rateLimitMeter.Mark(1)
+		return msg.errorResponse(&rateLimitedError{namespace})
+	}
 
 	// Parse subscription name arg too, but remove it before calling the callback.
 	argTypes := append([]reflect.Type{stringType}, callb.argTypes...)
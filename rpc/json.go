@@ -106,6 +106,16 @@ func (msg *jsonrpcMessage) response(result interface{}) *jsonrpcMessage {
 	return &jsonrpcMessage{Version: vsn, ID: msg.ID, Result: enc}
 }
 
+// size returns the approximate encoded size of the message, used to enforce the
+// response size limits.
+func (msg *jsonrpcMessage) size() int {
+	size := len(msg.ID) + len(msg.Method) + len(msg.Params) + len(msg.Result)
+	if msg.Error != nil {
+		size += len(msg.Error.Message)
+	}
+	return size
+}
+
 func errorMessage(err error) *jsonrpcMessage {
 	msg := &jsonrpcMessage{Version: vsn, ID: null, Error: &jsonError{
 		Code:    defaultErrorCode,
@@ -0,0 +1,150 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"net"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultRateLimitNamespace is the key of the rate limit applied to all the
+	// namespaces without a dedicated limit.
+	DefaultRateLimitNamespace = "*"
+
+	// httpLimiterCacheSize is the number of HTTP clients whose rate limiters are
+	// tracked. HTTP requests don't share a persistent connection, so the limiters
+	// are keyed by the remote host instead.
+	httpLimiterCacheSize = 4096
+)
+
+// RateLimit is a token bucket rate limit applied to the method calls of a single
+// connection.
+type RateLimit struct {
+	Rate  float64 // Number of calls allowed per second on average
+	Burst int     // Maximum number of calls allowed at once
+}
+
+// connLimits are the limits applied to the requests of a single connection.
+type connLimits struct {
+	batchItemLimit     int          // Maximum number of requests in a batch (0 = unlimited)
+	batchResponseLimit int          // Maximum number of response bytes of a batch (0 = unlimited)
+	limiter            *rateLimiter // Method call rate limiter (nil = unlimited)
+}
+
+// rateLimiter is a set of token buckets, keyed by method namespace.
+type rateLimiter struct {
+	limits  map[string]RateLimit
+	buckets map[string]*rate.Limiter
+	lock    sync.Mutex
+}
+
+// newRateLimiter creates a rate limiter enforcing the given limits. If there
+// are no limits configured, nil is returned.
+func newRateLimiter(limits map[string]RateLimit) *rateLimiter {
+	if len(limits) == 0 {
+		return nil
+	}
+	return &rateLimiter{
+		limits:  limits,
+		buckets: make(map[string]*rate.Limiter),
+	}
+}
+
+// allow reports whether a call in the given namespace may proceed, consuming a
+// token from the bucket of the namespace if so.
+func (l *rateLimiter) allow(namespace string) bool {
+	if l == nil {
+		return true
+	}
+	key := namespace
+	limit, ok := l.limits[namespace]
+	if !ok {
+		if limit, ok = l.limits[DefaultRateLimitNamespace]; !ok {
+			return true
+		}
+		key = DefaultRateLimitNamespace
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	bucket := l.buckets[key]
+	if bucket == nil {
+		bucket = rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst)
+		l.buckets[key] = bucket
+	}
+	return bucket.Allow()
+}
+
+// SetBatchLimits sets limits applied to batch requests. There are two limits:
+// 'itemLimit' is the maximum number of items in a batch, 'responseLimit' is the
+// maximum number of response bytes across all the responses of a batch. Zero
+// disables the respective limit.
+//
+// This method should be called before processing any requests via ServeCodec,
+// ServeHTTP, ServeListener etc.
+func (s *Server) SetBatchLimits(itemLimit, responseLimit int) {
+	s.batchItemLimit = itemLimit
+	s.batchResponseLimit = responseLimit
+}
+
+// SetRateLimits sets the per-connection method call rate limits, keyed by method
+// namespace. The limit keyed by DefaultRateLimitNamespace applies to all the
+// namespaces without their own limit. HTTP requests are limited per remote host.
+//
+// This method should be called before processing any requests via ServeCodec,
+// ServeHTTP, ServeListener etc.
+func (s *Server) SetRateLimits(limits map[string]RateLimit) {
+	s.rateLimits = make(map[string]RateLimit, len(limits))
+	for namespace, limit := range limits {
+		s.rateLimits[namespace] = limit
+	}
+	s.httpLimiters, _ = lru.New(httpLimiterCacheSize)
+}
+
+// connLimits creates the limits to apply to a new connection. HTTP connections
+// share their rate limiters across requests from the same remote host.
+func (s *Server) connLimits(codec ServerCodec, http bool) *connLimits {
+	limits := &connLimits{
+		batchItemLimit:     s.batchItemLimit,
+		batchResponseLimit: s.batchResponseLimit,
+	}
+	if len(s.rateLimits) == 0 {
+		return limits
+	}
+	if !http {
+		limits.limiter = newRateLimiter(s.rateLimits)
+		return limits
+	}
+	host, _, err := net.SplitHostPort(codec.remoteAddr())
+	if err != nil {
+		host = codec.remoteAddr()
+	}
+	s.httpLimitersLock.Lock()
+	defer s.httpLimitersLock.Unlock()
+
+	if limiter, ok := s.httpLimiters.Get(host); ok {
+		limits.limiter = limiter.(*rateLimiter)
+	} else {
+		limits.limiter = newRateLimiter(s.rateLimits)
+		s.httpLimiters.Add(host, limits.limiter)
+	}
+	return limits
+}
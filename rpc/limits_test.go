@@ -0,0 +1,120 @@
+// Copyright 2021 The go-highcoin Authors
+// This file is part of the go-highcoin library.
+//
+// The go-highcoin library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-highcoin library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-highcoin library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// limitsTestConn serves a single codec connection of the given server and
+// returns a function which sends a request line and reads back the response.
+func limitsTestConn(t *testing.T, server *Server) (func(string) string, func()) {
+	clientConn, serverConn := net.Pipe()
+	go server.ServeCodec(NewCodec(serverConn), 0)
+
+	readbuf := bufio.NewReader(clientConn)
+	roundtrip := func(request string) string {
+		clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+		if _, err := io.WriteString(clientConn, request+"\n"); err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+		resp, err := readbuf.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read error: %v", err)
+		}
+		return strings.TrimRight(resp, "\r\n")
+	}
+	return roundtrip, func() { clientConn.Close() }
+}
+
+func TestBatchItemLimit(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	server.SetBatchLimits(2, 0)
+
+	roundtrip, closeConn := limitsTestConn(t, server)
+	defer closeConn()
+
+	var (
+		allowed  = `[{"jsonrpc":"2.0","id":1,"method":"test_noArgsRets"},{"jsonrpc":"2.0","id":2,"method":"test_noArgsRets"}]`
+		rejected = `[{"jsonrpc":"2.0","id":1,"method":"test_noArgsRets"},{"jsonrpc":"2.0","id":2,"method":"test_noArgsRets"},{"jsonrpc":"2.0","id":3,"method":"test_noArgsRets"}]`
+	)
+	if have, want := roundtrip(allowed), `[{"jsonrpc":"2.0","id":1,"result":null},{"jsonrpc":"2.0","id":2,"result":null}]`; have != want {
+		t.Errorf("wrong response to batch within limit\nhave: %s\nwant: %s", have, want)
+	}
+	if have, want := roundtrip(rejected), `[{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"batch too large: 3 items, limit 2"}}]`; have != want {
+		t.Errorf("wrong response to batch over limit\nhave: %s\nwant: %s", have, want)
+	}
+}
+
+func TestBatchResponseLimit(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	server.SetBatchLimits(0, 100)
+
+	roundtrip, closeConn := limitsTestConn(t, server)
+	defer closeConn()
+
+	var (
+		request = `[{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]},{"jsonrpc":"2.0","id":2,"method":"test_echo","params":["` + strings.Repeat("x", 100) + `",2]},{"jsonrpc":"2.0","id":3,"method":"test_echo","params":["x",3]}]`
+		want    = `[{"jsonrpc":"2.0","id":1,"result":{"String":"x","Int":1,"Args":null}},` +
+			`{"jsonrpc":"2.0","id":2,"error":{"code":-32003,"message":"batch response too large: limit 100 bytes"}},` +
+			`{"jsonrpc":"2.0","id":3,"error":{"code":-32003,"message":"batch response too large: limit 100 bytes"}}]`
+	)
+	if have := roundtrip(request); have != want {
+		t.Errorf("wrong response to oversized batch\nhave: %s\nwant: %s", have, want)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	server.SetRateLimits(map[string]RateLimit{
+		"test":                    {Rate: 0.001, Burst: 2},
+		DefaultRateLimitNamespace: {Rate: 0.001, Burst: 1},
+	})
+	roundtrip, closeConn := limitsTestConn(t, server)
+	defer closeConn()
+
+	tests := []struct {
+		request, want string
+	}{
+		{`{"jsonrpc":"2.0","id":1,"method":"test_noArgsRets"}`, `{"jsonrpc":"2.0","id":1,"result":null}`},
+		{`{"jsonrpc":"2.0","id":2,"method":"test_noArgsRets"}`, `{"jsonrpc":"2.0","id":2,"result":null}`},
+		{`{"jsonrpc":"2.0","id":3,"method":"test_noArgsRets"}`, `{"jsonrpc":"2.0","id":3,"error":{"code":-32005,"message":"rate limit exceeded for test namespace"}}`},
+		// Namespaces without a dedicated limit share the default bucket
+		{`{"jsonrpc":"2.0","id":4,"method":"rpc_modules"}`, `{"jsonrpc":"2.0","id":4,"result":{"nftest":"1.0","rpc":"1.0","test":"1.0"}}`},
+		{`{"jsonrpc":"2.0","id":5,"method":"rpc_modules"}`, `{"jsonrpc":"2.0","id":5,"error":{"code":-32005,"message":"rate limit exceeded for rpc namespace"}}`},
+	}
+	for i, tt := range tests {
+		if have := roundtrip(tt.request); have != tt.want {
+			t.Errorf("test %d: wrong response\nhave: %s\nwant: %s", i, have, tt.want)
+		}
+	}
+	// Limits are tracked per connection, a new one should start afresh
+	roundtrip2, closeConn2 := limitsTestConn(t, server)
+	defer closeConn2()
+
+	if have, want := roundtrip2(tests[0].request), tests[0].want; have != want {
+		t.Errorf("wrong response on fresh connection\nhave: %s\nwant: %s", have, want)
+	}
+}
@@ -27,6 +27,10 @@ var (
 	successfulRequestGauge = metrics.NewRegisteredGauge("rpc/success", nil)
 	failedReqeustGauge     = metrics.NewRegisteredGauge("rpc/failure", nil)
 	rpcServingTimer        = metrics.NewRegisteredTimer("rpc/duration/all", nil)
+
+	batchLimitMeter    = metrics.NewRegisteredMeter("rpc/limits/batch", nil)    // Batches rejected due to too many items
+	responseLimitMeter = metrics.NewRegisteredMeter("rpc/limits/response", nil) // Batches truncated due to response size
+	rateLimitMeter     = metrics.NewRegisteredMeter("rpc/limits/rate", nil)     // Calls rejected due to rate limits
 )
 
 func newRPCServingTimer(method string, valid bool) metrics.Timer {
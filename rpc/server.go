@@ -19,10 +19,12 @@ package rpc
 import (
 	"context"
 	"io"
+	"sync"
 	"sync/atomic"
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/420integrated/go-highcoin/log"
+	lru "github.com/hashicorp/golang-lru"
 )
 
 const MetadataApi = "rpc"
@@ -46,6 +48,12 @@ type Server struct {
 	idgen    func() ID
 	run      int32
 	codecs   mapset.Set
+
+	batchItemLimit     int                  // Maximum number of requests in a batch
+	batchResponseLimit int                  // Maximum number of response bytes of a batch
+	rateLimits         map[string]RateLimit // Per-connection call rate limits by namespace
+	httpLimiters       *lru.Cache           // Rate limiters of HTTP clients, keyed by remote host
+	httpLimitersLock   sync.Mutex           // Ensures HTTP clients get a single limiter
 }
 
 // NewServer creates a new server instance with no registered handlers.
@@ -83,7 +91,7 @@ func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	s.codecs.Add(codec)
 	defer s.codecs.Remove(codec)
 
-	c := initClient(codec, s.idgen, &s.services)
+	c := initClient(codec, s.idgen, &s.services, s.connLimits(codec, false))
 	<-codec.closed()
 	c.Close()
 }
@@ -97,7 +105,7 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec) {
 		return
 	}
 
-	h := newHandler(ctx, codec, s.idgen, &s.services)
+	h := newHandler(ctx, codec, s.idgen, &s.services, s.connLimits(codec, true))
 	h.allowSubscribe = false
 	defer h.close(io.EOF, nil)
 
@@ -167,6 +167,22 @@ var Forks = map[string]*params.ChainConfig{
 		IstanbulBlock:       big.NewInt(0),
 		YoloV3Block:         big.NewInt(0),
 	},
+	// EVM Object Format containers (EIP-3540 and EIP-3670, along with EIP-3541) on
+	// top of Berlin, not scheduled on any public network
+	"EOF": {
+		ChainID:             big.NewInt(1),
+		HomesteadBlock:      big.NewInt(0),
+		EIP150Block:         big.NewInt(0),
+		EIP155Block:         big.NewInt(0),
+		EIP158Block:         big.NewInt(0),
+		ByzantiumBlock:      big.NewInt(0),
+		ConstantinopleBlock: big.NewInt(0),
+		PetersburgBlock:     big.NewInt(0),
+		IstanbulBlock:       big.NewInt(0),
+		YoloV3Block:         big.NewInt(0),
+		EIP3541Block:        big.NewInt(0),
+		EOFBlock:            big.NewInt(0),
+	},
 }
 
 // Returns the set of defined fork names
@@ -54,6 +54,7 @@ var (
 	vmTestDir          = filepath.Join(baseDir, "VMTests")
 	rlpTestDir         = filepath.Join(baseDir, "RLPTests")
 	difficultyTestDir  = filepath.Join(baseDir, "BasicTests")
+	eofStateTestDir    = filepath.Join(".", "eof")
 )
 
 func readJSON(reader io.Reader, value interface{}) error {
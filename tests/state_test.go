@@ -94,6 +94,28 @@ func TestState(t *testing.T) {
 	}
 }
 
+// TestEOFState runs the state tests of EVM Object Format containers, which are
+// maintained in this repository rather than in the shared test suite.
+func TestEOFState(t *testing.T) {
+	t.Parallel()
+
+	st := new(testMatcher)
+	st.walk(t, eofStateTestDir, func(t *testing.T, name string, test *StateTest) {
+		for _, subtest := range test.Subtests() {
+			subtest := subtest
+			key := fmt.Sprintf("%s/%d", subtest.Fork, subtest.Index)
+			name := name + "/" + key
+
+			t.Run(key, func(t *testing.T) {
+				withTrace(t, test.smokeLimit(subtest), func(vmconfig vm.Config) error {
+					_, _, err := test.Run(subtest, vmconfig, false)
+					return st.checkFailure(t, name, err)
+				})
+			})
+		}
+	})
+}
+
 // Transactions with smokeLimit above this value will not get a VM trace on failure.
 const traceErrorLimit = 400000
 